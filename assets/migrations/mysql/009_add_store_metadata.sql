@@ -0,0 +1,5 @@
+-- +goose Up
+ALTER TABLE store ADD COLUMN metadata LONGBLOB;
+
+-- +goose Down
+ALTER TABLE store DROP COLUMN metadata;
@@ -122,9 +122,48 @@ func bindRunFlagsFunc(flags *pflag.FlagSet) func(*cobra.Command, []string) {
 		util.MustBindPFlag("datastore.connMaxLifetime", flags.Lookup("datastore-conn-max-lifetime"))
 		util.MustBindEnv("datastore.connMaxLifetime", "OPENFGA_DATASTORE_CONN_MAX_LIFETIME", "OPENFGA_DATASTORE_CONNMAXLIFETIME")
 
+		util.MustBindPFlag("datastore.readTimeout", flags.Lookup("datastore-read-timeout"))
+		util.MustBindEnv("datastore.readTimeout", "OPENFGA_DATASTORE_READ_TIMEOUT", "OPENFGA_DATASTORE_READTIMEOUT")
+
+		util.MustBindPFlag("datastore.writeTimeout", flags.Lookup("datastore-write-timeout"))
+		util.MustBindEnv("datastore.writeTimeout", "OPENFGA_DATASTORE_WRITE_TIMEOUT", "OPENFGA_DATASTORE_WRITETIMEOUT")
+
 		util.MustBindPFlag("datastore.metrics.enabled", flags.Lookup("datastore-metrics-enabled"))
 		util.MustBindEnv("datastore.metrics.enabled", "OPENFGA_DATASTORE_METRICS_ENABLED")
 
+		util.MustBindPFlag("datastore.circuitBreaker.enabled", flags.Lookup("datastore-circuit-breaker-enabled"))
+		util.MustBindEnv("datastore.circuitBreaker.enabled", "OPENFGA_DATASTORE_CIRCUIT_BREAKER_ENABLED")
+
+		util.MustBindPFlag("datastore.circuitBreaker.failureRateThreshold", flags.Lookup("datastore-circuit-breaker-failure-rate-threshold"))
+		util.MustBindEnv("datastore.circuitBreaker.failureRateThreshold", "OPENFGA_DATASTORE_CIRCUIT_BREAKER_FAILURE_RATE_THRESHOLD")
+
+		util.MustBindPFlag("datastore.circuitBreaker.minRequests", flags.Lookup("datastore-circuit-breaker-min-requests"))
+		util.MustBindEnv("datastore.circuitBreaker.minRequests", "OPENFGA_DATASTORE_CIRCUIT_BREAKER_MIN_REQUESTS")
+
+		util.MustBindPFlag("datastore.circuitBreaker.window", flags.Lookup("datastore-circuit-breaker-window"))
+		util.MustBindEnv("datastore.circuitBreaker.window", "OPENFGA_DATASTORE_CIRCUIT_BREAKER_WINDOW")
+
+		util.MustBindPFlag("datastore.circuitBreaker.cooldown", flags.Lookup("datastore-circuit-breaker-cooldown"))
+		util.MustBindEnv("datastore.circuitBreaker.cooldown", "OPENFGA_DATASTORE_CIRCUIT_BREAKER_COOLDOWN")
+
+		util.MustBindPFlag("datastore.circuitBreaker.halfOpenProbes", flags.Lookup("datastore-circuit-breaker-half-open-probes"))
+		util.MustBindEnv("datastore.circuitBreaker.halfOpenProbes", "OPENFGA_DATASTORE_CIRCUIT_BREAKER_HALF_OPEN_PROBES")
+
+		util.MustBindPFlag("datastore.retry.enabled", flags.Lookup("datastore-retry-enabled"))
+		util.MustBindEnv("datastore.retry.enabled", "OPENFGA_DATASTORE_RETRY_ENABLED")
+
+		util.MustBindPFlag("datastore.retry.maxAttempts", flags.Lookup("datastore-retry-max-attempts"))
+		util.MustBindEnv("datastore.retry.maxAttempts", "OPENFGA_DATASTORE_RETRY_MAX_ATTEMPTS")
+
+		util.MustBindPFlag("datastore.retry.initialBackoff", flags.Lookup("datastore-retry-initial-backoff"))
+		util.MustBindEnv("datastore.retry.initialBackoff", "OPENFGA_DATASTORE_RETRY_INITIAL_BACKOFF")
+
+		util.MustBindPFlag("datastore.retry.maxBackoff", flags.Lookup("datastore-retry-max-backoff"))
+		util.MustBindEnv("datastore.retry.maxBackoff", "OPENFGA_DATASTORE_RETRY_MAX_BACKOFF")
+
+		util.MustBindPFlag("datastore.retry.writes", flags.Lookup("datastore-retry-writes"))
+		util.MustBindEnv("datastore.retry.writes", "OPENFGA_DATASTORE_RETRY_WRITES")
+
 		util.MustBindPFlag("playground.enabled", flags.Lookup("playground-enabled"))
 		util.MustBindEnv("playground.enabled", "OPENFGA_PLAYGROUND_ENABLED")
 
@@ -161,6 +200,9 @@ func bindRunFlagsFunc(flags *pflag.FlagSet) func(*cobra.Command, []string) {
 		util.MustBindPFlag("trace.serviceName", flags.Lookup("trace-service-name"))
 		util.MustBindEnv("trace.serviceName", "OPENFGA_TRACE_SERVICE_NAME")
 
+		util.MustBindPFlag("trace.highCardinalityAttributes", flags.Lookup("trace-high-cardinality-attributes"))
+		util.MustBindEnv("trace.highCardinalityAttributes", "OPENFGA_TRACE_HIGH_CARDINALITY_ATTRIBUTES")
+
 		util.MustBindPFlag("metrics.enabled", flags.Lookup("metrics-enabled"))
 		util.MustBindEnv("metrics.enabled", "OPENFGA_METRICS_ENABLED")
 
@@ -170,21 +212,45 @@ func bindRunFlagsFunc(flags *pflag.FlagSet) func(*cobra.Command, []string) {
 		util.MustBindPFlag("metrics.enableRPCHistograms", flags.Lookup("metrics-enable-rpc-histograms"))
 		util.MustBindEnv("metrics.enableRPCHistograms", "OPENFGA_METRICS_ENABLE_RPC_HISTOGRAMS")
 
+		util.MustBindPFlag("metrics.enableCheckRewriteHistograms", flags.Lookup("metrics-enable-check-rewrite-histograms"))
+		util.MustBindEnv("metrics.enableCheckRewriteHistograms", "OPENFGA_METRICS_ENABLE_CHECK_REWRITE_HISTOGRAMS")
+
+		util.MustBindPFlag("metrics.datastoreErrorMaxStores", flags.Lookup("metrics-datastore-error-max-stores"))
+		util.MustBindEnv("metrics.datastoreErrorMaxStores", "OPENFGA_METRICS_DATASTORE_ERROR_MAX_STORES", "OPENFGA_METRICS_DATASTOREERRORMAXSTORES")
+
 		util.MustBindPFlag("maxChecksPerBatchCheck", flags.Lookup("max-checks-per-batch-check"))
 		util.MustBindEnv("maxChecksPerBatchCheck", "OPENFGA_MAX_CHECKS_PER_BATCH_CHECK")
 
+		util.MustBindPFlag("maxChecksPerStreamedBatchCheck", flags.Lookup("max-checks-per-streamed-batch-check"))
+		util.MustBindEnv("maxChecksPerStreamedBatchCheck", "OPENFGA_MAX_CHECKS_PER_STREAMED_BATCH_CHECK")
+
+		util.MustBindPFlag("maxContextualTuples", flags.Lookup("max-contextual-tuples"))
+		util.MustBindEnv("maxContextualTuples", "OPENFGA_MAX_CONTEXTUAL_TUPLES")
+
+		util.MustBindPFlag("conditionCurrentTimeGranularity", flags.Lookup("condition-current-time-granularity"))
+		util.MustBindEnv("conditionCurrentTimeGranularity", "OPENFGA_CONDITION_CURRENT_TIME_GRANULARITY")
+
+		util.MustBindPFlag("modelIndexFiles", flags.Lookup("model-index-files"))
+		util.MustBindEnv("modelIndexFiles", "OPENFGA_MODEL_INDEX_FILES")
+
 		util.MustBindPFlag("maxConcurrentChecksPerBatchCheck", flags.Lookup("max-concurrent-checks-per-batch-check"))
 		util.MustBindEnv("maxConcurrentChecksPerBatchCheck", "OPENFGA_MAX_CONCURRENT_CHECKS_PER_BATCH_CHECK")
 
 		util.MustBindPFlag("maxTuplesPerWrite", flags.Lookup("max-tuples-per-write"))
 		util.MustBindEnv("maxTuplesPerWrite", "OPENFGA_MAX_TUPLES_PER_WRITE", "OPENFGA_MAXTUPLESPERWRITE")
 
+		util.MustBindPFlag("duplicateWriteBehavior", flags.Lookup("duplicate-write-behavior"))
+		util.MustBindEnv("duplicateWriteBehavior", "OPENFGA_DUPLICATE_WRITE_BEHAVIOR")
+
 		util.MustBindPFlag("maxTypesPerAuthorizationModel", flags.Lookup("max-types-per-authorization-model"))
 		util.MustBindEnv("maxTypesPerAuthorizationModel", "OPENFGA_MAX_TYPES_PER_AUTHORIZATION_MODEL", "OPENFGA_MAXTYPESPERAUTHORIZATIONMODEL")
 
 		util.MustBindPFlag("maxAuthorizationModelSizeInBytes", flags.Lookup("max-authorization-model-size-in-bytes"))
 		util.MustBindEnv("maxAuthorizationModelSizeInBytes", "OPENFGA_MAX_AUTHORIZATION_MODEL_SIZE_IN_BYTES", "OPENFGA_MAXAUTHORIZATIONMODELSIZEINBYTES")
 
+		util.MustBindPFlag("maxRelationBranches", flags.Lookup("max-relation-branches"))
+		util.MustBindEnv("maxRelationBranches", "OPENFGA_MAX_RELATION_BRANCHES", "OPENFGA_MAXRELATIONBRANCHES")
+
 		util.MustBindPFlag("maxConcurrentReadsForListObjects", flags.Lookup("max-concurrent-reads-for-list-objects"))
 		util.MustBindEnv("maxConcurrentReadsForListObjects", "OPENFGA_MAX_CONCURRENT_READS_FOR_LIST_OBJECTS", "OPENFGA_MAXCONCURRENTREADSFORLISTOBJECTS")
 
@@ -200,18 +266,45 @@ func bindRunFlagsFunc(flags *pflag.FlagSet) func(*cobra.Command, []string) {
 		util.MustBindPFlag("changelogHorizonOffset", flags.Lookup("changelog-horizon-offset"))
 		util.MustBindEnv("changelogHorizonOffset", "OPENFGA_CHANGELOG_HORIZON_OFFSET", "OPENFGA_CHANGELOGHORIZONOFFSET")
 
+		util.MustBindPFlag("changelogCompactionInterval", flags.Lookup("changelog-compaction-interval"))
+		util.MustBindEnv("changelogCompactionInterval", "OPENFGA_CHANGELOG_COMPACTION_INTERVAL")
+
+		util.MustBindPFlag("changelogRetention", flags.Lookup("changelog-retention"))
+		util.MustBindEnv("changelogRetention", "OPENFGA_CHANGELOG_RETENTION")
+
 		util.MustBindPFlag("resolveNodeLimit", flags.Lookup("resolve-node-limit"))
 		util.MustBindEnv("resolveNodeLimit", "OPENFGA_RESOLVE_NODE_LIMIT", "OPENFGA_RESOLVENODELIMIT")
 
+		util.MustBindPFlag("resolveDepthLimit", flags.Lookup("resolve-depth-limit"))
+		util.MustBindEnv("resolveDepthLimit", "OPENFGA_RESOLVE_DEPTH_LIMIT", "OPENFGA_RESOLVEDEPTHLIMIT")
+
 		util.MustBindPFlag("resolveNodeBreadthLimit", flags.Lookup("resolve-node-breadth-limit"))
 		util.MustBindEnv("resolveNodeBreadthLimit", "OPENFGA_RESOLVE_NODE_BREADTH_LIMIT", "OPENFGA_RESOLVENODEBREADTHLIMIT")
 
+		util.MustBindPFlag("resolutionStrategy", flags.Lookup("resolution-strategy"))
+		util.MustBindEnv("resolutionStrategy", "OPENFGA_RESOLUTION_STRATEGY", "OPENFGA_RESOLUTIONSTRATEGY")
+
+		util.MustBindPFlag("checkConcurrencyBudget", flags.Lookup("check-concurrency-budget"))
+		util.MustBindEnv("checkConcurrencyBudget", "OPENFGA_CHECK_CONCURRENCY_BUDGET")
+
+		util.MustBindPFlag("checkPerRequestConcurrencyLimit", flags.Lookup("check-per-request-concurrency-limit"))
+		util.MustBindEnv("checkPerRequestConcurrencyLimit", "OPENFGA_CHECK_PER_REQUEST_CONCURRENCY_LIMIT")
+
+		util.MustBindPFlag("maxUsersetFanout", flags.Lookup("max-userset-fanout"))
+		util.MustBindEnv("maxUsersetFanout", "OPENFGA_MAX_USERSET_FANOUT")
+
+		util.MustBindPFlag("usersetFanoutBehavior", flags.Lookup("userset-fanout-behavior"))
+		util.MustBindEnv("usersetFanoutBehavior", "OPENFGA_USERSET_FANOUT_BEHAVIOR")
+
 		util.MustBindPFlag("listObjectsDeadline", flags.Lookup("listObjects-deadline"))
 		util.MustBindEnv("listObjectsDeadline", "OPENFGA_LIST_OBJECTS_DEADLINE", "OPENFGA_LISTOBJECTSDEADLINE")
 
 		util.MustBindPFlag("listObjectsMaxResults", flags.Lookup("listObjects-max-results"))
 		util.MustBindEnv("listObjectsMaxResults", "OPENFGA_LIST_OBJECTS_MAX_RESULTS", "OPENFGA_LISTOBJECTSMAXRESULTS")
 
+		util.MustBindPFlag("listObjectsOrderBy", flags.Lookup("listObjects-order-by"))
+		util.MustBindEnv("listObjectsOrderBy", "OPENFGA_LIST_OBJECTS_ORDER_BY")
+
 		util.MustBindPFlag("listUsersDeadline", flags.Lookup("listUsers-deadline"))
 		util.MustBindEnv("listUsersDeadline", "OPENFGA_LIST_USERS_DEADLINE", "OPENFGA_LISTUSERSDEADLINE")
 
@@ -221,6 +314,9 @@ func bindRunFlagsFunc(flags *pflag.FlagSet) func(*cobra.Command, []string) {
 		util.MustBindPFlag("checkCache.limit", flags.Lookup("check-cache-limit"))
 		util.MustBindEnv("checkCache.limit", "OPENFGA_CHECK_CACHE_LIMIT")
 
+		util.MustBindPFlag("checkCache.costBudget", flags.Lookup("check-cache-cost-budget"))
+		util.MustBindEnv("checkCache.costBudget", "OPENFGA_CHECK_CACHE_COST_BUDGET")
+
 		// The below configuration is deprecated in favour of OPENFGA_CHECK_CACHE_LIMIT
 		util.MustBindPFlag("cache.limit", flags.Lookup("check-query-cache-limit"))
 		util.MustBindEnv("cache.limit", "OPENFGA_CHECK_QUERY_CACHE_LIMIT")
@@ -246,6 +342,18 @@ func bindRunFlagsFunc(flags *pflag.FlagSet) func(*cobra.Command, []string) {
 		util.MustBindPFlag("checkQueryCache.ttl", flags.Lookup("check-query-cache-ttl"))
 		util.MustBindEnv("checkQueryCache.ttl", "OPENFGA_CHECK_QUERY_CACHE_TTL")
 
+		util.MustBindPFlag("checkQueryCache.storeMinEntries", flags.Lookup("check-query-cache-store-min-entries"))
+		util.MustBindEnv("checkQueryCache.storeMinEntries", "OPENFGA_CHECK_QUERY_CACHE_STORE_MIN_ENTRIES")
+
+		util.MustBindPFlag("checkQueryCache.storeMaxEntries", flags.Lookup("check-query-cache-store-max-entries"))
+		util.MustBindEnv("checkQueryCache.storeMaxEntries", "OPENFGA_CHECK_QUERY_CACHE_STORE_MAX_ENTRIES")
+
+		util.MustBindPFlag("checkQueryCache.storeBudget", flags.Lookup("check-query-cache-store-budget"))
+		util.MustBindEnv("checkQueryCache.storeBudget", "OPENFGA_CHECK_QUERY_CACHE_STORE_BUDGET")
+
+		util.MustBindPFlag("checkQueryCache.uncachedObjectTypes", flags.Lookup("check-query-cache-uncached-object-types"))
+		util.MustBindEnv("checkQueryCache.uncachedObjectTypes", "OPENFGA_CHECK_QUERY_CACHE_UNCACHED_OBJECT_TYPES")
+
 		util.MustBindPFlag("listObjectsIteratorCache.enabled", flags.Lookup("list-objects-iterator-cache-enabled"))
 		util.MustBindEnv("listObjectsIteratorCache.enabled", "OPENFGA_LIST_OBJECTS_ITERATOR_CACHE_ENABLED")
 
@@ -255,6 +363,15 @@ func bindRunFlagsFunc(flags *pflag.FlagSet) func(*cobra.Command, []string) {
 		util.MustBindPFlag("listObjectsIteratorCache.ttl", flags.Lookup("list-objects-iterator-cache-ttl"))
 		util.MustBindEnv("listObjectsIteratorCache.ttl", "OPENFGA_LIST_OBJECTS_ITERATOR_CACHE_TTL")
 
+		util.MustBindPFlag("listObjectsQueryCache.enabled", flags.Lookup("list-objects-query-cache-enabled"))
+		util.MustBindEnv("listObjectsQueryCache.enabled", "OPENFGA_LIST_OBJECTS_QUERY_CACHE_ENABLED")
+
+		util.MustBindPFlag("listObjectsQueryCache.maxResults", flags.Lookup("list-objects-query-cache-max-results"))
+		util.MustBindEnv("listObjectsQueryCache.maxResults", "OPENFGA_LIST_OBJECTS_QUERY_CACHE_MAX_RESULTS")
+
+		util.MustBindPFlag("listObjectsQueryCache.ttl", flags.Lookup("list-objects-query-cache-ttl"))
+		util.MustBindEnv("listObjectsQueryCache.ttl", "OPENFGA_LIST_OBJECTS_QUERY_CACHE_TTL")
+
 		util.MustBindPFlag("sharedIterator.enabled", flags.Lookup("shared-iterator-enabled"))
 		util.MustBindEnv("sharedIterator.enabled", "OPENFGA_SHARED_ITERATOR_ENABLED")
 
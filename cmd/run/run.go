@@ -73,7 +73,9 @@ import (
 	"github.com/openfga/openfga/pkg/storage/postgres"
 	"github.com/openfga/openfga/pkg/storage/sqlcommon"
 	"github.com/openfga/openfga/pkg/storage/sqlite"
+	"github.com/openfga/openfga/pkg/storage/storagewrappers"
 	"github.com/openfga/openfga/pkg/telemetry"
+	"github.com/openfga/openfga/pkg/typesystem"
 )
 
 const (
@@ -169,8 +171,34 @@ func NewRunCommand() *cobra.Command {
 
 	flags.Duration("datastore-conn-max-lifetime", defaultConfig.Datastore.ConnMaxLifetime, "the maximum amount of time a connection to the datastore may be reused")
 
+	flags.Duration("datastore-read-timeout", defaultConfig.Datastore.ReadTimeout, "the maximum amount of time a single tuple read from the datastore may take (0 means no timeout). Only applies to the postgres and mysql datastores")
+
+	flags.Duration("datastore-write-timeout", defaultConfig.Datastore.WriteTimeout, "the maximum amount of time a single tuple write to the datastore may take (0 means no timeout). Only applies to the postgres and mysql datastores")
+
 	flags.Bool("datastore-metrics-enabled", defaultConfig.Datastore.Metrics.Enabled, "enable/disable sql metrics")
 
+	flags.Bool("datastore-circuit-breaker-enabled", defaultConfig.Datastore.CircuitBreaker.Enabled, "enable/disable the circuit breakers that fail the datastore reads and writes fast while the datastore is failing")
+
+	flags.Float64("datastore-circuit-breaker-failure-rate-threshold", defaultConfig.Datastore.CircuitBreaker.FailureRateThreshold, "the rate of failed datastore operations, between 0 and 1, over a window at which a circuit breaker opens")
+
+	flags.Int("datastore-circuit-breaker-min-requests", defaultConfig.Datastore.CircuitBreaker.MinRequests, "the minimum number of datastore operations in a window for a circuit breaker to open")
+
+	flags.Duration("datastore-circuit-breaker-window", defaultConfig.Datastore.CircuitBreaker.Window, "the duration over which the failure rate of the datastore operations is computed")
+
+	flags.Duration("datastore-circuit-breaker-cooldown", defaultConfig.Datastore.CircuitBreaker.Cooldown, "the time for which an open circuit breaker fails the datastore operations fast before it lets a few through")
+
+	flags.Int("datastore-circuit-breaker-half-open-probes", defaultConfig.Datastore.CircuitBreaker.HalfOpenProbes, "the number of datastore operations that a half-open circuit breaker lets through to test whether the datastore recovered")
+
+	flags.Bool("datastore-retry-enabled", defaultConfig.Datastore.Retry.Enabled, "enable/disable retrying the datastore operations that fail with a transient error, e.g. a deadlock or a dropped connection. Only applies to the postgres and mysql datastores")
+
+	flags.Int("datastore-retry-max-attempts", defaultConfig.Datastore.Retry.MaxAttempts, "the maximum number of attempts of a datastore operation, including the first one")
+
+	flags.Duration("datastore-retry-initial-backoff", defaultConfig.Datastore.Retry.InitialBackoff, "the time waited before the first retry of a datastore operation. It grows exponentially for the next ones")
+
+	flags.Duration("datastore-retry-max-backoff", defaultConfig.Datastore.Retry.MaxBackoff, "the maximum time waited before a retry of a datastore operation")
+
+	flags.Bool("datastore-retry-writes", defaultConfig.Datastore.Retry.Writes, "enable/disable retrying the datastore writes, which may have been committed if they failed because of the connection")
+
 	flags.Bool("playground-enabled", defaultConfig.Playground.Enabled, "enable/disable the OpenFGA Playground")
 
 	flags.Int("playground-port", defaultConfig.Playground.Port, "the port to serve the local OpenFGA Playground on")
@@ -195,22 +223,40 @@ func NewRunCommand() *cobra.Command {
 
 	flags.String("trace-service-name", defaultConfig.Trace.ServiceName, "the service name included in sampled traces.")
 
+	flags.Bool("trace-high-cardinality-attributes", defaultConfig.Trace.HighCardinalityAttributes, "add the tuple keys of the Check subproblems, i.e. their objects and users, to their spans. Meant for debugging, the tuple keys may hold personal data.")
+
 	flags.Bool("metrics-enabled", defaultConfig.Metrics.Enabled, "enable/disable prometheus metrics on the '/metrics' endpoint")
 
 	flags.String("metrics-addr", defaultConfig.Metrics.Addr, "the host:port address to serve the prometheus metrics server on")
 
 	flags.Bool("metrics-enable-rpc-histograms", defaultConfig.Metrics.EnableRPCHistograms, "enables prometheus histogram metrics for RPC latency distributions")
 
+	flags.Bool("metrics-enable-check-rewrite-histograms", defaultConfig.Metrics.EnableCheckRewriteHistograms, "enables a prometheus histogram of the time Checks spend evaluating each rewrite operation, excluding the time spent in its operands and dispatched subproblems")
+
+	flags.Int("metrics-datastore-error-max-stores", defaultConfig.Metrics.DatastoreErrorMaxStores, "the maximum number of stores whose datastore errors are counted under their own store label. The errors of any other store are counted under the label 'other'.")
+
 	flags.Uint32("max-concurrent-checks-per-batch-check", defaultConfig.MaxConcurrentChecksPerBatchCheck, "the maximum number of checks that can be processed concurrently in a batch check request")
 
 	flags.Uint32("max-checks-per-batch-check", defaultConfig.MaxChecksPerBatchCheck, "the maximum number of tuples allowed in a BatchCheck request")
 
+	flags.Uint32("max-checks-per-streamed-batch-check", defaultConfig.MaxChecksPerStreamedBatchCheck, "the maximum number of tuples allowed in a streamed BatchCheck")
+
+	flags.Uint32("max-contextual-tuples", defaultConfig.MaxContextualTuples, "the maximum number of contextual tuples allowed in a Check, BatchCheck item or ListObjects request")
+
+	flags.Duration("condition-current-time-granularity", defaultConfig.ConditionCurrentTimeGranularity, "the granularity of the 'current_time' parameter provided to the conditions that declare it when the request doesn't. The current time is rounded up to a multiple of it so that requests share their cache entries, and grants expire early rather than late. As a result, grants that start at a time (e.g. 'current_time > start') apply up to one granularity early. 0 disables providing the current time.")
+
+	flags.StringSlice("model-index-files", defaultConfig.ModelIndexFiles, "the files of the precomputed indexes of authorization models to load at startup. An index must be built from the model as it's stored")
+
 	flags.Int("max-tuples-per-write", defaultConfig.MaxTuplesPerWrite, "the maximum allowed number of tuples per Write transaction")
 
+	flags.String("duplicate-write-behavior", string(defaultConfig.DuplicateWriteBehavior), "defines how a Write handles a tuple that already exists when the request doesn't specify it, either 'error', 'ignore' or 'upsert'")
+
 	flags.Int("max-types-per-authorization-model", defaultConfig.MaxTypesPerAuthorizationModel, "the maximum allowed number of type definitions per authorization model")
 
 	flags.Int("max-authorization-model-size-in-bytes", defaultConfig.MaxAuthorizationModelSizeInBytes, "the maximum size in bytes allowed for persisting an Authorization Model.")
 
+	flags.Int("max-relation-branches", defaultConfig.MaxRelationBranches, "the maximum number of branches (the operands of unions, intersections and exclusions) allowed in the definition of a single relation when persisting an Authorization Model.")
+
 	flags.Uint32("max-concurrent-reads-for-list-users", defaultConfig.MaxConcurrentReadsForListUsers, "the maximum allowed number of concurrent datastore reads in a single ListUsers query. A high number will consume more connections from the datastore pool and will attempt to prioritize performance for the request at the expense of other queries performance.")
 
 	flags.Uint32("max-concurrent-reads-for-list-objects", defaultConfig.MaxConcurrentReadsForListObjects, "the maximum allowed number of concurrent datastore reads in a single ListObjects or StreamedListObjects query. A high number will consume more connections from the datastore pool and will attempt to prioritize performance for the request at the expense of other queries performance.")
@@ -221,20 +267,40 @@ func NewRunCommand() *cobra.Command {
 
 	flags.Int("changelog-horizon-offset", defaultConfig.ChangelogHorizonOffset, "the offset (in minutes) from the current time. Changes that occur after this offset will not be included in the response of ReadChanges")
 
+	flags.Duration("changelog-compaction-interval", defaultConfig.ChangelogCompactionInterval, "how often the changelog of every store is compacted, removing the changes older than the changelog retention. ReadChanges continuation tokens older than the retention become invalid. 0 disables the compaction.")
+
+	flags.Duration("changelog-retention", defaultConfig.ChangelogRetention, "how long the changes are kept in the changelog when it's compacted")
+
 	flags.Uint32("resolve-node-limit", defaultConfig.ResolveNodeLimit, "maximum resolution depth to attempt before throwing an error (defines how deeply nested an authorization model can be before a query errors out).")
 
+	flags.Uint32("resolve-depth-limit", defaultConfig.ResolveDepthLimit, "maximum number of rewrites a Check can expand in any one path before throwing an error, including the rewrites resolved without a dispatch. 0 means no limit.")
+
 	flags.Uint32("resolve-node-breadth-limit", defaultConfig.ResolveNodeBreadthLimit, "defines how many nodes on a given level can be evaluated concurrently in a Check resolution tree")
 
+	flags.String("resolution-strategy", string(defaultConfig.ResolutionStrategy), "defines how the subproblems of a Check resolution tree are scheduled, either 'breadth-first' or 'depth-first'. 'depth-first' issues fewer concurrent datastore reads")
+
+	flags.Uint32("check-concurrency-budget", defaultConfig.CheckConcurrencyBudget, "bounds the number of subproblems that all the Checks evaluate concurrently, on top of the resolve node breadth limit. 0 means no limit")
+
+	flags.Uint32("check-per-request-concurrency-limit", defaultConfig.CheckPerRequestConcurrencyLimit, "bounds the number of subproblems that a single Check evaluates concurrently, so that one Check can't spend the whole check concurrency budget. 0 means no limit")
+
+	flags.Uint32("max-userset-fanout", defaultConfig.MaxUsersetFanout, "caps the number of userset tuples that are expanded for a single object and relation of a query. 0 doesn't cap the fan-out")
+
+	flags.String("userset-fanout-behavior", string(defaultConfig.UsersetFanoutBehavior), "defines what happens when the usersets of an object and relation exceed the max userset fan-out, either 'error' or 'deny'")
+
 	flags.Duration("listObjects-deadline", defaultConfig.ListObjectsDeadline, "the timeout deadline for serving ListObjects and StreamedListObjects requests")
 
 	flags.Uint32("listObjects-max-results", defaultConfig.ListObjectsMaxResults, "the maximum results to return in non-streaming ListObjects API responses. If 0, all results can be returned")
 
+	flags.String("listObjects-order-by", string(defaultConfig.ListObjectsOrderBy), "the order of the objects returned by the ListObjects APIs, either 'none' or 'object-id'. Ordering StreamedListObjects buffers its objects, up to listObjects-max-results, until all are found")
+
 	flags.Duration("listUsers-deadline", defaultConfig.ListUsersDeadline, "the timeout deadline for serving ListUsers requests. If 0, there is no deadline")
 
 	flags.Uint32("listUsers-max-results", defaultConfig.ListUsersMaxResults, "the maximum results to return in ListUsers API responses. If 0, all results can be returned")
 
 	flags.Uint32("check-cache-limit", defaultConfig.CheckCache.Limit, "if check-query-cache-enabled or check-iterator-cache-enabled, this is the size limit of the cache")
 
+	flags.Int64("check-cache-cost-budget", defaultConfig.CheckCache.CostBudget, "if set, the cache is bounded by the estimated total size in bytes of its entries instead of check-cache-limit")
+
 	flags.Bool("shared-iterator-enabled", defaultConfig.SharedIterator.Enabled, "enabling sharing of datastore iterators with different consumers. Each iterator is the result of a database query, for example usersets related to a specific object, or objects related to a specific user, up to a certain number of tuples per iterator.")
 
 	flags.Uint32("shared-iterator-limit", defaultConfig.SharedIterator.Limit, "if shared-iterator-enabled is enabled, this is the limit of the number of iterators that can be shared.")
@@ -251,12 +317,26 @@ func NewRunCommand() *cobra.Command {
 
 	flags.Duration("list-objects-iterator-cache-ttl", defaultConfig.ListObjectsIteratorCache.TTL, "if caching of datastore iterators of ListObjects requests is enabled, this is the TTL of each value")
 
+	flags.Bool("list-objects-query-cache-enabled", defaultConfig.ListObjectsQueryCache.Enabled, "enable caching of ListObjects results. The key is the store, the authorization model, the type, the relation, the user, the contextual tuples and the context of the request, and the value is the list of objects. The cache is stored in-memory and entries are invalidated after the configured TTL, or sooner if the cache controller observes a write to the store. If the request's consistency is HIGHER_CONSISTENCY, this cache is not used.")
+
+	flags.Uint32("list-objects-query-cache-max-results", defaultConfig.ListObjectsQueryCache.MaxResults, "if caching of ListObjects results is enabled, this is the limit of objects in a result to cache. Larger results are not cached.")
+
+	flags.Duration("list-objects-query-cache-ttl", defaultConfig.ListObjectsQueryCache.TTL, "if caching of ListObjects results is enabled, this is the TTL of each value")
+
 	flags.Bool("check-query-cache-enabled", defaultConfig.CheckQueryCache.Enabled, "enable caching of Check requests. For example, if you have a relation define viewer: owner or editor, and the query is Check(user:anne, viewer, doc:1), we'll evaluate the owner relation and the editor relation and cache both results: (user:anne, viewer, doc:1) -> allowed=true and (user:anne, owner, doc:1) -> allowed=true. The cache is stored in-memory; the cached values are overwritten on every change in the result, and cleared after the configured TTL. This flag improves latency, but turns Check and ListObjects into eventually consistent APIs. If the request's consistency is HIGHER_CONSISTENCY, this cache is not used.")
 
 	flags.Uint32("check-query-cache-limit", defaultConfig.CheckCache.Limit, "DEPRECATED: Use check-cache-limit instead. If caching of Check and ListObjects calls is enabled, this is the size limit of the cache")
 
 	flags.Duration("check-query-cache-ttl", defaultConfig.CheckQueryCache.TTL, "if check-query-cache-enabled, this is the TTL of each value")
 
+	flags.Uint32("check-query-cache-store-min-entries", defaultConfig.CheckQueryCache.StoreMinEntries, "if check-query-cache-enabled, this is the number of cached results of each store that are never evicted to make room for the results of other stores")
+
+	flags.Uint32("check-query-cache-store-max-entries", defaultConfig.CheckQueryCache.StoreMaxEntries, "if check-query-cache-enabled, this is the maximum number of cached results of each store. 0 means no limit")
+
+	flags.Uint32("check-query-cache-store-budget", defaultConfig.CheckQueryCache.StoreBudget, "if check-query-cache-enabled, this is the number of cached results shared by all the stores, once it's spent the results of the stores holding the most are evicted first. 0 means no limit")
+
+	flags.StringSlice("check-query-cache-uncached-object-types", defaultConfig.CheckQueryCache.UncachedObjectTypes, "if check-query-cache-enabled, these are the object types whose Check subproblems are never cached")
+
 	flags.Bool("cache-controller-enabled", defaultConfig.CacheController.Enabled, "enabling dynamic invalidation of check query cache and check iterator cache based on whether there are recent tuple writes. If enabled, cache will be invalidated when either 1) there are tuples written to the store OR 2) the check query cache or check iterator cache TTL has expired.")
 
 	flags.Duration("cache-controller-ttl", defaultConfig.CacheController.TTL, "if cache controller is enabled, control how frequent read changes are invoked internally to query for recent tuple writes to the store.")
@@ -376,6 +456,24 @@ func convertStringArrayToUintArray(stringArray []string) []uint {
 	return uintArray
 }
 
+// readModelIndexes reads the precomputed indexes of authorization models serialized in the files, see
+// typesystem.Index.Marshal.
+func readModelIndexes(files []string) ([]*typesystem.Index, error) {
+	indexes := make([]*typesystem.Index, 0, len(files))
+	for _, file := range files {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read the model index '%s': %w", file, err)
+		}
+		idx, err := typesystem.UnmarshalIndex(data)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse the model index '%s': %w", file, err)
+		}
+		indexes = append(indexes, idx)
+	}
+	return indexes, nil
+}
+
 // telemetryConfig returns the function that must be called to shut down tracing.
 // The context provided to this function should be error-free, or shut down will be incomplete.
 func (s *ServerContext) telemetryConfig(config *serverconfig.Config) func() error {
@@ -427,6 +525,8 @@ func (s *ServerContext) datastoreConfig(config *serverconfig.Config) (storage.Op
 		sqlcommon.WithMaxIdleConns(config.Datastore.MaxIdleConns),
 		sqlcommon.WithConnMaxIdleTime(config.Datastore.ConnMaxIdleTime),
 		sqlcommon.WithConnMaxLifetime(config.Datastore.ConnMaxLifetime),
+		sqlcommon.WithReadTimeout(config.Datastore.ReadTimeout),
+		sqlcommon.WithWriteTimeout(config.Datastore.WriteTimeout),
 	}
 
 	if config.Datastore.Metrics.Enabled {
@@ -658,17 +758,47 @@ func (s *ServerContext) Run(ctx context.Context, config *serverconfig.Config) er
 		}()
 	}
 
+	modelIndexes, err := readModelIndexes(config.ModelIndexFiles)
+	if err != nil {
+		return err
+	}
+
 	svr := server.MustNewServerWithOpts(
 		server.WithDatastore(datastore),
+		server.WithDatastoreCircuitBreaker(config.Datastore.CircuitBreaker.Enabled, storagewrappers.CircuitBreakerPolicy{
+			FailureRateThreshold: config.Datastore.CircuitBreaker.FailureRateThreshold,
+			MinRequests:          config.Datastore.CircuitBreaker.MinRequests,
+			Window:               config.Datastore.CircuitBreaker.Window,
+			Cooldown:             config.Datastore.CircuitBreaker.Cooldown,
+			HalfOpenProbes:       config.Datastore.CircuitBreaker.HalfOpenProbes,
+		}),
+		server.WithDatastoreCircuitBreakerName(config.Datastore.Engine),
+		server.WithDatastoreRetries(config.Datastore.Retry.Enabled, storagewrappers.RetryPolicy{
+			MaxAttempts:    config.Datastore.Retry.MaxAttempts,
+			InitialBackoff: config.Datastore.Retry.InitialBackoff,
+			MaxBackoff:     config.Datastore.Retry.MaxBackoff,
+			RetryWrites:    config.Datastore.Retry.Writes,
+		}),
 		server.WithContinuationTokenSerializer(continuationTokenSerializer),
 		server.WithAuthorizationModelCacheSize(config.Datastore.MaxCacheSize),
 		server.WithLogger(s.Logger),
 		server.WithTransport(gateway.NewRPCTransport(s.Logger)),
 		server.WithResolveNodeLimit(config.ResolveNodeLimit),
+		server.WithResolveDepthLimit(config.ResolveDepthLimit),
+		server.WithCheckRewriteLatencyMetrics(config.Metrics.EnableCheckRewriteHistograms),
+		server.WithCheckTraceHighCardinalityAttributes(config.Trace.HighCardinalityAttributes),
 		server.WithResolveNodeBreadthLimit(config.ResolveNodeBreadthLimit),
+		server.WithResolutionStrategy(config.ResolutionStrategy),
+		server.WithCheckConcurrencyBudget(config.CheckConcurrencyBudget),
+		server.WithCheckPerRequestConcurrencyLimit(config.CheckPerRequestConcurrencyLimit),
+		server.WithMaxUsersetFanout(config.MaxUsersetFanout),
+		server.WithUsersetFanoutBehavior(config.UsersetFanoutBehavior),
+		server.WithDuplicateWriteBehavior(config.DuplicateWriteBehavior),
 		server.WithChangelogHorizonOffset(config.ChangelogHorizonOffset),
+		server.WithChangelogCompaction(config.ChangelogCompactionInterval, config.ChangelogRetention),
 		server.WithListObjectsDeadline(config.ListObjectsDeadline),
 		server.WithListObjectsMaxResults(config.ListObjectsMaxResults),
+		server.WithListObjectsOrderBy(config.ListObjectsOrderBy),
 		server.WithListUsersDeadline(config.ListUsersDeadline),
 		server.WithListUsersMaxResults(config.ListUsersMaxResults),
 		server.WithMaxConcurrentReadsForListObjects(config.MaxConcurrentReadsForListObjects),
@@ -677,14 +807,19 @@ func (s *ServerContext) Run(ctx context.Context, config *serverconfig.Config) er
 		server.WithCacheControllerEnabled(config.CacheController.Enabled),
 		server.WithCacheControllerTTL(config.CacheController.TTL),
 		server.WithCheckCacheLimit(config.CheckCache.Limit),
+		server.WithCheckCacheCostBudget(config.CheckCache.CostBudget),
 		server.WithCheckIteratorCacheEnabled(config.CheckIteratorCache.Enabled),
 		server.WithCheckIteratorCacheMaxResults(config.CheckIteratorCache.MaxResults),
 		server.WithCheckIteratorCacheTTL(config.CheckIteratorCache.TTL),
 		server.WithCheckQueryCacheEnabled(config.CheckQueryCache.Enabled),
 		server.WithCheckQueryCacheTTL(config.CheckQueryCache.TTL),
+		server.WithCheckQueryCacheStoreLimits(config.CheckQueryCache.StoreMinEntries, config.CheckQueryCache.StoreMaxEntries, config.CheckQueryCache.StoreBudget),
+		server.WithCheckQueryCacheUncachedObjectTypes(config.CheckQueryCache.UncachedObjectTypes...),
 		server.WithRequestDurationByQueryHistogramBuckets(convertStringArrayToUintArray(config.RequestDurationDatastoreQueryCountBuckets)),
 		server.WithRequestDurationByDispatchCountHistogramBuckets(convertStringArrayToUintArray(config.RequestDurationDispatchCountBuckets)),
 		server.WithMaxAuthorizationModelSizeInBytes(config.MaxAuthorizationModelSizeInBytes),
+		server.WithMaxRelationBranches(config.MaxRelationBranches),
+		server.WithDatastoreErrorMetricMaxStores(config.Metrics.DatastoreErrorMaxStores),
 		server.WithContextPropagationToDatastore(config.ContextPropagationToDatastore),
 		server.WithDispatchThrottlingCheckResolverEnabled(config.CheckDispatchThrottling.Enabled),
 		server.WithDispatchThrottlingCheckResolverFrequency(config.CheckDispatchThrottling.Frequency),
@@ -704,7 +839,14 @@ func (s *ServerContext) Run(ctx context.Context, config *serverconfig.Config) er
 		server.WithListObjectsIteratorCacheEnabled(config.ListObjectsIteratorCache.Enabled),
 		server.WithListObjectsIteratorCacheMaxResults(config.ListObjectsIteratorCache.MaxResults),
 		server.WithListObjectsIteratorCacheTTL(config.ListObjectsIteratorCache.TTL),
+		server.WithListObjectsQueryCacheEnabled(config.ListObjectsQueryCache.Enabled),
+		server.WithListObjectsQueryCacheMaxResults(config.ListObjectsQueryCache.MaxResults),
+		server.WithListObjectsQueryCacheTTL(config.ListObjectsQueryCache.TTL),
 		server.WithMaxChecksPerBatchCheck(config.MaxChecksPerBatchCheck),
+		server.WithMaxChecksPerStreamedBatchCheck(config.MaxChecksPerStreamedBatchCheck),
+		server.WithMaxContextualTuples(config.MaxContextualTuples),
+		server.WithConditionCurrentTimeGranularity(config.ConditionCurrentTimeGranularity),
+		server.WithModelIndexes(modelIndexes...),
 		server.WithMaxConcurrentChecksPerBatchCheck(config.MaxConcurrentChecksPerBatchCheck),
 		server.WithSharedIteratorEnabled(config.SharedIterator.Enabled),
 		server.WithSharedIteratorLimit(config.SharedIterator.Limit),
@@ -729,6 +871,12 @@ func (s *ServerContext) Run(ctx context.Context, config *serverconfig.Config) er
 		zap.Any("config", config),
 	)
 
+	// the requests are tracked last, so that the requests rejected by the other interceptors are not
+	serverOpts = append(serverOpts,
+		grpc.ChainUnaryInterceptor(svr.DrainUnaryInterceptor()),
+		grpc.ChainStreamInterceptor(svr.DrainStreamInterceptor()),
+	)
+
 	// nosemgrep: grpc-server-insecure-connection
 	grpcServer := grpc.NewServer(serverOpts...)
 	openfgav1.RegisterOpenFGAServiceServer(grpcServer, svr)
@@ -959,7 +1107,13 @@ func (s *ServerContext) Run(ctx context.Context, config *serverconfig.Config) er
 		}
 	}
 
-	grpcServer.GracefulStop()
+	// stop accepting requests and let the ones in flight finish, then stop the gRPC server before the server,
+	// and so the datastore, is closed
+	if unfinished := svr.Drain(ctx); unfinished > 0 {
+		grpcServer.Stop()
+	} else {
+		grpcServer.GracefulStop()
+	}
 
 	svr.Close()
 
@@ -999,6 +999,32 @@ func TestHTTPServerEnabled(t *testing.T) {
 	testutils.EnsureServiceHealthy(t, cfg.GRPC.Addr, cfg.HTTP.Addr, nil)
 }
 
+func TestReadModelIndexes(t *testing.T) {
+	model := testutils.MustTransformDSLToProtoWithID(`
+		model
+			schema 1.1
+		type user
+		type doc
+			relations
+				define viewer: [user]`)
+
+	idx, err := typesystem.BuildIndex(model)
+	require.NoError(t, err)
+	data, err := idx.Marshal()
+	require.NoError(t, err)
+
+	file := path.Join(t.TempDir(), "index.json")
+	require.NoError(t, os.WriteFile(file, data, 0o600))
+
+	indexes, err := readModelIndexes([]string{file})
+	require.NoError(t, err)
+	require.Len(t, indexes, 1)
+	require.Equal(t, model.GetId(), indexes[0].AuthorizationModelID())
+
+	_, err = readModelIndexes([]string{path.Join(t.TempDir(), "missing.json")})
+	require.ErrorContains(t, err, "failed to read the model index")
+}
+
 func TestDefaultConfig(t *testing.T) {
 	cfg, err := ReadConfig()
 	require.NoError(t, err)
@@ -1035,6 +1061,50 @@ func TestDefaultConfig(t *testing.T) {
 	require.True(t, val.Exists())
 	require.False(t, val.Bool())
 
+	val = res.Get("properties.datastore.properties.circuitBreaker.properties.enabled.default")
+	require.True(t, val.Exists())
+	require.Equal(t, val.Bool(), cfg.Datastore.CircuitBreaker.Enabled)
+
+	val = res.Get("properties.datastore.properties.circuitBreaker.properties.failureRateThreshold.default")
+	require.True(t, val.Exists())
+	require.InDelta(t, val.Float(), cfg.Datastore.CircuitBreaker.FailureRateThreshold, 0)
+
+	val = res.Get("properties.datastore.properties.circuitBreaker.properties.minRequests.default")
+	require.True(t, val.Exists())
+	require.EqualValues(t, val.Int(), cfg.Datastore.CircuitBreaker.MinRequests)
+
+	val = res.Get("properties.datastore.properties.circuitBreaker.properties.window.default")
+	require.True(t, val.Exists())
+	require.Equal(t, val.String(), cfg.Datastore.CircuitBreaker.Window.String())
+
+	val = res.Get("properties.datastore.properties.circuitBreaker.properties.cooldown.default")
+	require.True(t, val.Exists())
+	require.Equal(t, val.String(), cfg.Datastore.CircuitBreaker.Cooldown.String())
+
+	val = res.Get("properties.datastore.properties.circuitBreaker.properties.halfOpenProbes.default")
+	require.True(t, val.Exists())
+	require.EqualValues(t, val.Int(), cfg.Datastore.CircuitBreaker.HalfOpenProbes)
+
+	val = res.Get("properties.datastore.properties.retry.properties.enabled.default")
+	require.True(t, val.Exists())
+	require.Equal(t, val.Bool(), cfg.Datastore.Retry.Enabled)
+
+	val = res.Get("properties.datastore.properties.retry.properties.maxAttempts.default")
+	require.True(t, val.Exists())
+	require.EqualValues(t, val.Int(), cfg.Datastore.Retry.MaxAttempts)
+
+	val = res.Get("properties.datastore.properties.retry.properties.initialBackoff.default")
+	require.True(t, val.Exists())
+	require.Equal(t, val.String(), cfg.Datastore.Retry.InitialBackoff.String())
+
+	val = res.Get("properties.datastore.properties.retry.properties.maxBackoff.default")
+	require.True(t, val.Exists())
+	require.Equal(t, val.String(), cfg.Datastore.Retry.MaxBackoff.String())
+
+	val = res.Get("properties.datastore.properties.retry.properties.writes.default")
+	require.True(t, val.Exists())
+	require.Equal(t, val.Bool(), cfg.Datastore.Retry.Writes)
+
 	val = res.Get("properties.grpc.properties.addr.default")
 	require.True(t, val.Exists())
 	require.Equal(t, val.String(), cfg.GRPC.Addr)
@@ -1095,6 +1165,14 @@ func TestDefaultConfig(t *testing.T) {
 	require.True(t, val.Exists())
 	require.EqualValues(t, val.Int(), cfg.MaxChecksPerBatchCheck)
 
+	val = res.Get("properties.maxChecksPerStreamedBatchCheck.default")
+	require.True(t, val.Exists())
+	require.EqualValues(t, val.Int(), cfg.MaxChecksPerStreamedBatchCheck)
+
+	val = res.Get("properties.maxContextualTuples.default")
+	require.True(t, val.Exists())
+	require.EqualValues(t, val.Int(), cfg.MaxContextualTuples)
+
 	val = res.Get("properties.maxConditionEvaluationCost.default")
 	require.True(t, val.Exists())
 	require.Equal(t, val.Uint(), cfg.MaxConditionEvaluationCost)
@@ -1111,6 +1189,14 @@ func TestDefaultConfig(t *testing.T) {
 	require.True(t, val.Exists())
 	require.EqualValues(t, val.Int(), cfg.ResolveNodeBreadthLimit)
 
+	val = res.Get("properties.checkConcurrencyBudget.default")
+	require.True(t, val.Exists())
+	require.EqualValues(t, val.Int(), cfg.CheckConcurrencyBudget)
+
+	val = res.Get("properties.checkPerRequestConcurrencyLimit.default")
+	require.True(t, val.Exists())
+	require.EqualValues(t, val.Int(), cfg.CheckPerRequestConcurrencyLimit)
+
 	val = res.Get("properties.resolveNodeLimit.default")
 	require.True(t, val.Exists())
 	require.EqualValues(t, val.Int(), cfg.ResolveNodeLimit)
@@ -1123,6 +1209,20 @@ func TestDefaultConfig(t *testing.T) {
 	require.True(t, val.Exists())
 	require.Equal(t, val.Bool(), cfg.HTTP.TLS.Enabled)
 
+	val = res.Get("properties.changelogCompactionInterval.default")
+	require.True(t, val.Exists())
+	require.Equal(t, val.String(), cfg.ChangelogCompactionInterval.String())
+
+	val = res.Get("properties.changelogRetention.default")
+	require.True(t, val.Exists())
+	retention, err := time.ParseDuration(val.String())
+	require.NoError(t, err)
+	require.Equal(t, retention, cfg.ChangelogRetention)
+
+	val = res.Get("properties.conditionCurrentTimeGranularity.default")
+	require.True(t, val.Exists())
+	require.Equal(t, val.String(), cfg.ConditionCurrentTimeGranularity.String())
+
 	val = res.Get("properties.listObjectsDeadline.default")
 	require.True(t, val.Exists())
 	require.Equal(t, val.String(), cfg.ListObjectsDeadline.String())
@@ -1131,6 +1231,10 @@ func TestDefaultConfig(t *testing.T) {
 	require.True(t, val.Exists())
 	require.EqualValues(t, val.Int(), cfg.ListObjectsMaxResults)
 
+	val = res.Get("properties.listObjectsOrderBy.default")
+	require.True(t, val.Exists())
+	require.Equal(t, val.String(), string(cfg.ListObjectsOrderBy))
+
 	val = res.Get("properties.listUsersDeadline.default")
 	require.True(t, val.Exists())
 	require.Equal(t, val.String(), cfg.ListUsersDeadline.String())
@@ -1155,10 +1259,18 @@ func TestDefaultConfig(t *testing.T) {
 	require.True(t, val.Exists())
 	require.Equal(t, val.Bool(), cfg.Metrics.EnableRPCHistograms)
 
+	val = res.Get("properties.metrics.properties.enableCheckRewriteHistograms.default")
+	require.True(t, val.Exists())
+	require.Equal(t, val.Bool(), cfg.Metrics.EnableCheckRewriteHistograms)
+
 	val = res.Get("properties.trace.properties.serviceName.default")
 	require.True(t, val.Exists())
 	require.Equal(t, val.String(), cfg.Trace.ServiceName)
 
+	val = res.Get("properties.trace.properties.highCardinalityAttributes.default")
+	require.True(t, val.Exists())
+	require.Equal(t, val.Bool(), cfg.Trace.HighCardinalityAttributes)
+
 	val = res.Get("properties.trace.properties.otlp.properties.endpoint.default")
 	require.True(t, val.Exists())
 	require.Equal(t, val.String(), cfg.Trace.OTLP.Endpoint)
@@ -1175,6 +1287,18 @@ func TestDefaultConfig(t *testing.T) {
 	require.True(t, val.Exists())
 	require.Equal(t, val.Bool(), cfg.CheckQueryCache.Enabled)
 
+	val = res.Get("properties.checkQueryCache.properties.storeMinEntries.default")
+	require.True(t, val.Exists())
+	require.EqualValues(t, val.Int(), cfg.CheckQueryCache.StoreMinEntries)
+
+	val = res.Get("properties.checkQueryCache.properties.storeMaxEntries.default")
+	require.True(t, val.Exists())
+	require.EqualValues(t, val.Int(), cfg.CheckQueryCache.StoreMaxEntries)
+
+	val = res.Get("properties.checkQueryCache.properties.storeBudget.default")
+	require.True(t, val.Exists())
+	require.EqualValues(t, val.Int(), cfg.CheckQueryCache.StoreBudget)
+
 	val = res.Get("properties.checkQueryCache.properties.ttl.default")
 	require.True(t, val.Exists())
 	require.Equal(t, val.String(), cfg.CheckQueryCache.TTL.String())
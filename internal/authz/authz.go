@@ -157,7 +157,7 @@ func (a *Authorizer) getRelation(apiMethod apimethod.APIMethod) (string, error)
 		return CanCallListObjects, nil
 	case apimethod.Check, apimethod.BatchCheck:
 		return CanCallCheck, nil
-	case apimethod.ListUsers:
+	case apimethod.ListUsers, apimethod.StreamedListUsers:
 		return CanCallListUsers, nil
 	case apimethod.WriteAssertions:
 		return CanCallWriteAssertions, nil
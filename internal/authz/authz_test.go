@@ -42,6 +42,7 @@ func TestGetRelation(t *testing.T) {
 		{method: apimethod.Check, expectedResult: CanCallCheck},
 		{method: apimethod.BatchCheck, expectedResult: CanCallCheck},
 		{method: apimethod.ListUsers, expectedResult: CanCallListUsers},
+		{method: apimethod.StreamedListUsers, expectedResult: CanCallListUsers},
 		{method: apimethod.WriteAssertions, expectedResult: CanCallWriteAssertions},
 		{method: apimethod.ReadAssertions, expectedResult: CanCallReadAssertions},
 		{method: apimethod.WriteAuthorizationModel, expectedResult: CanCallWriteAuthorizationModels},
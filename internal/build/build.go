@@ -13,9 +13,5 @@ var (
 	// Date is the date when the app was built.
 	Date = "unknown"
 
-	// MinimumSupportedDatastoreSchemaRevision refers to the minimum schema version that is required to run
-	// this specific build of OpenFGA. Refer to the `assets/migrations` artifacts for more information.
-	MinimumSupportedDatastoreSchemaRevision int64 = 4
-
 	ProjectName = "openfga"
 )
@@ -63,6 +63,7 @@ type resolveCheckRequest interface {
 	GetTupleKey() *openfgav1.TupleKey
 	GetConsistency() openfgav1.ConsistencyPreference
 	GetContext() *structpb.Struct
+	GetWildcardPolicy() tuple.WildcardPolicy
 }
 
 func IteratorReadUsersetTuples(ctx context.Context,
@@ -122,6 +123,9 @@ func IteratorReadStartingFromUser(ctx context.Context,
 
 	relationReference := typesystem.DirectRelationReference(objectType, relation)
 	hasPubliclyAssignedType, _ := typesys.IsPubliclyAssignable(relationReference, userType)
+	if !req.GetWildcardPolicy().AppliesTo(user) {
+		hasPubliclyAssignedType = false
+	}
 
 	iter, err := ds.ReadStartingWithUser(ctx, storeID,
 		storage.ReadStartingWithUserFilter{
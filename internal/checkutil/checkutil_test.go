@@ -218,6 +218,10 @@ func (m *mockResolveCheckRequest) GetContext() *structpb.Struct {
 	return m.Context
 }
 
+func (m *mockResolveCheckRequest) GetWildcardPolicy() tuple.WildcardPolicy {
+	return tuple.WildcardPolicyIncludeAnonymous
+}
+
 func TestIteratorReadStartingFromUser(t *testing.T) {
 	tests := []struct {
 		name             string
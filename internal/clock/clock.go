@@ -0,0 +1,54 @@
+// Package clock provides a source of the current time that can be replaced in tests.
+package clock
+
+import (
+	"sync"
+	"time"
+)
+
+// Clock returns the current time.
+type Clock interface {
+	Now() time.Time
+}
+
+type realClock struct{}
+
+// New returns a Clock that reads the system time.
+func New() Clock {
+	return realClock{}
+}
+
+func (realClock) Now() time.Time {
+	return time.Now()
+}
+
+// Since returns the time elapsed since t according to the clock c.
+func Since(c Clock, t time.Time) time.Duration {
+	return c.Now().Sub(t)
+}
+
+// Fake is a Clock whose time only changes when it is advanced. It is safe for concurrent use.
+type Fake struct {
+	mu  sync.Mutex
+	now time.Time
+}
+
+var _ Clock = (*Fake)(nil)
+
+// NewFake returns a Fake clock set to now.
+func NewFake(now time.Time) *Fake {
+	return &Fake{now: now}
+}
+
+func (f *Fake) Now() time.Time {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.now
+}
+
+// Advance moves the time of the clock forward by d.
+func (f *Fake) Advance(d time.Duration) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.now = f.now.Add(d)
+}
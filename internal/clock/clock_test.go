@@ -0,0 +1,25 @@
+package clock
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestFake(t *testing.T) {
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	c := NewFake(start)
+	require.Equal(t, start, c.Now())
+	require.Zero(t, Since(c, start))
+
+	c.Advance(time.Minute)
+	require.Equal(t, start.Add(time.Minute), c.Now())
+	require.Equal(t, time.Minute, Since(c, start))
+}
+
+func TestNew(t *testing.T) {
+	before := time.Now()
+	now := New().Now()
+	require.False(t, now.Before(before))
+}
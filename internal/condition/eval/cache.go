@@ -0,0 +1,126 @@
+package eval
+
+import (
+	"context"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"google.golang.org/protobuf/types/known/structpb"
+
+	"github.com/openfga/openfga/internal/condition"
+	"github.com/openfga/openfga/pkg/storage"
+)
+
+// EvaluationCache memoizes the results of the conditions evaluated by EvaluateTupleCondition, by the name of the
+// condition and the context that it's evaluated against, i.e. both the context of the request and the context of
+// the tuple. A condition referenced by many tuples with the same context is then evaluated once.
+//
+// The cache is meant to live as long as a single request: it is never pruned, and the results depend on the
+// model that defines the conditions.
+type EvaluationCache struct {
+	mu      sync.RWMutex
+	results map[string]*condition.EvaluationResult
+	// contextKeys memoizes the encoding of the request contexts, which every evaluation of a request shares.
+	contextKeys map[*structpb.Struct]string
+	hits        atomic.Uint64
+}
+
+// NewEvaluationCache returns an empty EvaluationCache.
+func NewEvaluationCache() *EvaluationCache {
+	return &EvaluationCache{
+		results:     make(map[string]*condition.EvaluationResult),
+		contextKeys: make(map[*structpb.Struct]string),
+	}
+}
+
+// Hits returns the number of evaluations that were served from the cache.
+func (c *EvaluationCache) Hits() uint64 {
+	if c == nil {
+		return 0
+	}
+	return c.hits.Load()
+}
+
+func (c *EvaluationCache) get(key string) (*condition.EvaluationResult, bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	result, ok := c.results[key]
+	if ok {
+		c.hits.Add(1)
+	}
+	return result, ok
+}
+
+func (c *EvaluationCache) set(key string, result *condition.EvaluationResult) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.results[key] = result
+}
+
+// key returns the key of the evaluation of the condition against the contexts. The contexts are written with
+// their canonical encoding rather than hashed, so that the evaluations against different contexts can't collide.
+// The request context is encoded once per request, see contextKey.
+func (c *EvaluationCache) key(conditionName string, reqContext, tupleContext *structpb.Struct) (string, error) {
+	reqContextKey, err := c.contextKey(reqContext)
+	if err != nil {
+		return "", err
+	}
+
+	var b strings.Builder
+
+	// the name is prefixed by its length, so that the contexts can't be confused with the end of the name
+	b.WriteString(strconv.Itoa(len(conditionName)) + ":" + conditionName)
+	b.WriteString(reqContextKey)
+	if err := writeContextKey(&b, tupleContext); err != nil {
+		return "", err
+	}
+	return b.String(), nil
+}
+
+// contextKey returns the encoding of the request context, which is memoized by the request context since the
+// evaluations of a request share the same one.
+func (c *EvaluationCache) contextKey(reqContext *structpb.Struct) (string, error) {
+	c.mu.RLock()
+	key, ok := c.contextKeys[reqContext]
+	c.mu.RUnlock()
+	if ok {
+		return key, nil
+	}
+
+	var b strings.Builder
+	if err := writeContextKey(&b, reqContext); err != nil {
+		return "", err
+	}
+	key = b.String()
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.contextKeys[reqContext] = key
+	return key, nil
+}
+
+func writeContextKey(b *strings.Builder, ctx *structpb.Struct) error {
+	b.WriteString("{")
+	if err := storage.WriteStructCacheKey(b, ctx); err != nil {
+		return err
+	}
+	b.WriteString("}")
+	return nil
+}
+
+type evaluationCacheContextKey struct{}
+
+// ContextWithEvaluationCache returns a context whose condition evaluations are memoized by the cache.
+func ContextWithEvaluationCache(ctx context.Context, cache *EvaluationCache) context.Context {
+	return context.WithValue(ctx, evaluationCacheContextKey{}, cache)
+}
+
+// EvaluationCacheFromContext returns the EvaluationCache of the context, or nil if there is none.
+func EvaluationCacheFromContext(ctx context.Context) *EvaluationCache {
+	cache, _ := ctx.Value(evaluationCacheContextKey{}).(*EvaluationCache)
+	return cache
+}
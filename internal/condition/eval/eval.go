@@ -44,6 +44,26 @@ func EvaluateTupleCondition(
 		attribute.String("condition_name", conditionName)))
 	defer span.End()
 
+	tupleContext := tupleCondition.GetContext()
+
+	cache := EvaluationCacheFromContext(ctx)
+	var cacheKey string
+	if cache != nil {
+		var err error
+		cacheKey, err = cache.key(conditionName, context, tupleContext)
+		if err != nil {
+			err = condition.NewEvaluationError(conditionName, err)
+			telemetry.TraceError(span, err)
+			return nil, err
+		}
+
+		if cachedResult, ok := cache.get(cacheKey); ok {
+			span.SetAttributes(attribute.Bool("condition_met", cachedResult.ConditionMet), attribute.Bool("from_cache", true))
+			result := *cachedResult
+			return &result, nil
+		}
+	}
+
 	start := time.Now()
 
 	evaluableCondition, ok := typesys.GetCondition(conditionName)
@@ -63,7 +83,6 @@ func EvaluateTupleCondition(
 		contextFields = []map[string]*structpb.Value{context.GetFields()}
 	}
 
-	tupleContext := tupleCondition.GetContext()
 	if tupleContext != nil {
 		contextFields = append(contextFields, tupleContext.GetFields())
 	}
@@ -81,5 +100,11 @@ func EvaluateTupleCondition(
 		attribute.String("condition_cost", strconv.FormatUint(conditionResult.Cost, 10)),
 		attribute.StringSlice("condition_missing_params", conditionResult.MissingParameters),
 	)
+
+	if cache != nil {
+		cachedResult := conditionResult
+		cache.set(cacheKey, &cachedResult)
+	}
+
 	return &conditionResult, nil
 }
@@ -109,6 +109,83 @@ func TestEvaluateTupleCondition(t *testing.T) {
 	}
 }
 
+func TestEvaluateTupleConditionWithCache(t *testing.T) {
+	ts, err := typesystem.NewAndValidate(context.Background(), parser.MustTransformDSLToProto(`
+		model
+			schema 1.1
+
+		type user
+
+		type document
+			relations
+				define can_view: [user with in_range]
+
+		condition in_range(x: int, max: int) {
+			x < max
+		}`))
+	require.NoError(t, err)
+
+	cache := NewEvaluationCache()
+	ctx := ContextWithEvaluationCache(context.Background(), cache)
+	require.Same(t, cache, EvaluationCacheFromContext(ctx))
+
+	evaluate := func(t *testing.T, reqContext map[string]interface{}, tupleContext map[string]interface{}) bool {
+		reqStruct, err := structpb.NewStruct(reqContext)
+		require.NoError(t, err)
+		tupleStruct, err := structpb.NewStruct(tupleContext)
+		require.NoError(t, err)
+
+		tk := tuple.NewTupleKeyWithCondition("document:1", "can_view", "user:maria", "in_range", tupleStruct)
+		result, err := EvaluateTupleCondition(ctx, tk, ts, reqStruct)
+		require.NoError(t, err)
+		return result.ConditionMet
+	}
+
+	require.True(t, evaluate(t, map[string]interface{}{"x": 1}, map[string]interface{}{"max": 10}))
+	require.Equal(t, uint64(0), cache.Hits())
+
+	// the same contexts, with the fields of the tuple of another object
+	require.True(t, evaluate(t, map[string]interface{}{"x": 1}, map[string]interface{}{"max": 10}))
+	require.Equal(t, uint64(1), cache.Hits())
+
+	// another request context, or another tuple context, is evaluated
+	require.False(t, evaluate(t, map[string]interface{}{"x": 10}, map[string]interface{}{"max": 10}))
+	require.True(t, evaluate(t, map[string]interface{}{"x": 10}, map[string]interface{}{"max": 11}))
+	require.Equal(t, uint64(1), cache.Hits())
+
+	// the fields of the request context are not confused with the ones of the tuple context
+	require.True(t, evaluate(t, map[string]interface{}{"x": 10, "max": 11}, nil))
+	require.Equal(t, uint64(1), cache.Hits())
+
+	t.Run("request_context_is_encoded_once", func(t *testing.T) {
+		cache := NewEvaluationCache()
+		ctx := ContextWithEvaluationCache(context.Background(), cache)
+
+		reqStruct, err := structpb.NewStruct(map[string]interface{}{"x": 1})
+		require.NoError(t, err)
+		for i := range 3 {
+			tupleStruct, err := structpb.NewStruct(map[string]interface{}{"max": i})
+			require.NoError(t, err)
+
+			tk := tuple.NewTupleKeyWithCondition("document:1", "can_view", "user:maria", "in_range", tupleStruct)
+			_, err = EvaluateTupleCondition(ctx, tk, ts, reqStruct)
+			require.NoError(t, err)
+		}
+
+		require.Len(t, cache.contextKeys, 1)
+		require.Len(t, cache.results, 3)
+	})
+
+	t.Run("errors_are_not_cached", func(t *testing.T) {
+		tk := tuple.NewTupleKeyWithCondition("document:1", "can_view", "user:maria", "unknown", nil)
+		for range 2 {
+			_, err := EvaluateTupleCondition(ctx, tk, ts, nil)
+			require.ErrorContains(t, err, "condition was not found")
+		}
+		require.Equal(t, uint64(1), cache.Hits())
+	})
+}
+
 // TestDefaultCELEvaluationCost is used to ensure we don't decreasee the default evaluation cost
 // of CEL expressions, which would break API compatibility.
 //
@@ -0,0 +1,268 @@
+package graph
+
+import (
+	"container/heap"
+	"container/list"
+	"sync"
+	"time"
+)
+
+// StoreCacheLimits partitions the entries that a CachedCheckResolver caches by store, so that the Checks of
+// one store can't evict all the entries of the others, see WithStoreCacheLimits.
+type StoreCacheLimits struct {
+	// MinEntries is the number of entries of each store that are never evicted to make room for the entries
+	// of other stores.
+	MinEntries int
+	// MaxEntries is the maximum number of entries of each store. Zero doesn't bound them.
+	MaxEntries int
+	// Budget is the number of entries shared by all the stores. Once it's spent, the entries of the store
+	// holding the most entries above MinEntries are evicted first. Zero doesn't bound them. The cache must
+	// be able to hold the budget, otherwise its own evictions apply regardless of the store.
+	Budget int
+}
+
+// cachedEntry is an entry cached for a store, in the order the entries of the store were cached.
+type cachedEntry struct {
+	cacheKey string
+	added    time.Time
+	expiry   time.Time
+}
+
+type storePartition struct {
+	storeID string
+	order   *list.List // of *cachedEntry, the oldest first.
+	entries map[string]*list.Element
+	// index is the position of the partition in the partitionHeap.
+	index int
+}
+
+// partitionHeap orders the partitions by their number of entries, the largest first, so that the victim of
+// the budget is found without scanning all the stores.
+type partitionHeap []*storePartition
+
+func (h partitionHeap) Len() int { return len(h) }
+
+func (h partitionHeap) Less(i, j int) bool { return len(h[i].entries) > len(h[j].entries) }
+
+func (h partitionHeap) Swap(i, j int) {
+	h[i], h[j] = h[j], h[i]
+	h[i].index = i
+	h[j].index = j
+}
+
+func (h *partitionHeap) Push(x any) {
+	partition := x.(*storePartition)
+	partition.index = len(*h)
+	*h = append(*h, partition)
+}
+
+func (h *partitionHeap) Pop() any {
+	old := *h
+	partition := old[len(old)-1]
+	old[len(old)-1] = nil
+	*h = old[:len(old)-1]
+	return partition
+}
+
+// storeCachePartitions tracks the entries cached for each store and decides which ones are evicted to respect
+// the StoreCacheLimits. The entries of a store are evicted in the order they were cached.
+type storeCachePartitions struct {
+	limits StoreCacheLimits
+
+	mu     sync.Mutex
+	stores map[string]*storePartition // GUARDED_BY(mu).
+	// bySize holds the partitions of stores, the largest first.
+	bySize partitionHeap // GUARDED_BY(mu).
+	// keys maps the key of every entry to the store that cached it last, so that the entries that the cache
+	// evicts by itself can be forgotten, see removeEvicted.
+	keys  map[string]string // GUARDED_BY(mu).
+	total int               // GUARDED_BY(mu).
+}
+
+func newStoreCachePartitions(limits StoreCacheLimits) *storeCachePartitions {
+	return &storeCachePartitions{
+		limits: limits,
+		stores: make(map[string]*storePartition),
+		keys:   make(map[string]string),
+	}
+}
+
+// evictedEntry is an entry that must be removed from the cache.
+type evictedEntry struct {
+	storeID  string
+	cacheKey string
+}
+
+// add records an entry cached for the store at added, and returns the entries to evict from the cache to
+// respect the limits.
+func (p *storeCachePartitions) add(storeID, cacheKey string, added, expiry time.Time) []evictedEntry {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	partition, ok := p.stores[storeID]
+	if !ok {
+		partition = &storePartition{storeID: storeID, order: list.New(), entries: make(map[string]*list.Element)}
+		p.stores[storeID] = partition
+		heap.Push(&p.bySize, partition)
+	}
+
+	if elem, ok := partition.entries[cacheKey]; ok {
+		// the entry was cached again, e.g. after it was invalidated
+		entry := elem.Value.(*cachedEntry)
+		entry.added, entry.expiry = added, expiry
+		partition.order.MoveToBack(elem)
+	} else {
+		partition.entries[cacheKey] = partition.order.PushBack(&cachedEntry{cacheKey: cacheKey, added: added, expiry: expiry})
+		p.keys[cacheKey] = storeID
+		p.total++
+	}
+	p.pruneExpired(partition, added)
+	p.fixLocked(partition)
+
+	var evicted []evictedEntry
+	for p.limits.MaxEntries > 0 && len(partition.entries) > p.limits.MaxEntries {
+		evicted = append(evicted, p.evictOldest(partition))
+	}
+
+	for p.limits.Budget > 0 && p.total > p.limits.Budget {
+		victim := p.largestAboveMin(added)
+		if victim == nil {
+			// every store holds at most its minimum
+			break
+		}
+		evicted = append(evicted, p.evictOldest(victim))
+	}
+	return evicted
+}
+
+// pruneExpired forgets the oldest entries of the partition that expired from the cache by themselves. The
+// caller must fix the position of the partition in bySize afterward.
+func (p *storeCachePartitions) pruneExpired(partition *storePartition, now time.Time) {
+	for front := partition.order.Front(); front != nil; front = partition.order.Front() {
+		entry := front.Value.(*cachedEntry)
+		if now.Before(entry.expiry) {
+			return
+		}
+		partition.order.Remove(front)
+		delete(partition.entries, entry.cacheKey)
+		p.forgetKey(partition.storeID, entry.cacheKey)
+		p.total--
+	}
+}
+
+func (p *storeCachePartitions) evictOldest(partition *storePartition) evictedEntry {
+	entry := partition.order.Front().Value.(*cachedEntry)
+	p.removeLocked(partition.storeID, entry.cacheKey)
+	return evictedEntry{storeID: partition.storeID, cacheKey: entry.cacheKey}
+}
+
+// largestAboveMin returns the partition of the store holding the most entries above the minimum, or nil if
+// there is none. The expired entries of the largest partitions are pruned first, so that a store isn't
+// evicted for the entries that already left the cache.
+func (p *storeCachePartitions) largestAboveMin(now time.Time) *storePartition {
+	for len(p.bySize) > 0 {
+		largest := p.bySize[0]
+		size := len(largest.entries)
+		p.pruneExpired(largest, now)
+		if len(largest.entries) == size {
+			if size <= p.limits.MinEntries {
+				return nil
+			}
+			return largest
+		}
+		p.fixLocked(largest)
+	}
+	return nil
+}
+
+// remove forgets an entry of the store that was removed from the cache.
+func (p *storeCachePartitions) remove(storeID, cacheKey string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.removeLocked(storeID, cacheKey)
+}
+
+// removeEvicted forgets an entry that the cache evicted by itself, unless the key was cached again since the
+// entry was added.
+func (p *storeCachePartitions) removeEvicted(cacheKey string, added time.Time) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	storeID, ok := p.keys[cacheKey]
+	if !ok {
+		return
+	}
+	partition, ok := p.stores[storeID]
+	if !ok {
+		return
+	}
+	if elem, ok := partition.entries[cacheKey]; ok && elem.Value.(*cachedEntry).added.Equal(added) {
+		p.removeLocked(storeID, cacheKey)
+	}
+}
+
+func (p *storeCachePartitions) removeLocked(storeID, cacheKey string) {
+	partition, ok := p.stores[storeID]
+	if !ok {
+		return
+	}
+	if elem, ok := partition.entries[cacheKey]; ok {
+		partition.order.Remove(elem)
+		delete(partition.entries, cacheKey)
+		p.forgetKey(storeID, cacheKey)
+		p.total--
+	}
+	p.fixLocked(partition)
+}
+
+// forgetKey removes the key from keys, unless another store cached it since.
+func (p *storeCachePartitions) forgetKey(storeID, cacheKey string) {
+	if p.keys[cacheKey] == storeID {
+		delete(p.keys, cacheKey)
+	}
+}
+
+// fixLocked restores the position of the partition in bySize after its entries changed, and forgets the
+// partition once it's empty.
+func (p *storeCachePartitions) fixLocked(partition *storePartition) {
+	if len(partition.entries) > 0 {
+		heap.Fix(&p.bySize, partition.index)
+		return
+	}
+	heap.Remove(&p.bySize, partition.index)
+	delete(p.stores, partition.storeID)
+}
+
+// removeStore forgets all the entries of the store.
+func (p *storeCachePartitions) removeStore(storeID string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	partition, ok := p.stores[storeID]
+	if !ok {
+		return
+	}
+	for cacheKey := range partition.entries {
+		p.forgetKey(storeID, cacheKey)
+	}
+	p.total -= len(partition.entries)
+	heap.Remove(&p.bySize, partition.index)
+	delete(p.stores, storeID)
+}
+
+// occupancy returns the number of entries that each store holds in the cache.
+func (p *storeCachePartitions) occupancy(now time.Time) map[string]int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	occupancy := make(map[string]int, len(p.stores))
+	for _, partition := range append([]*storePartition(nil), p.bySize...) {
+		p.pruneExpired(partition, now)
+		p.fixLocked(partition)
+		if len(partition.entries) > 0 {
+			occupancy[partition.storeID] = len(partition.entries)
+		}
+	}
+	return occupancy
+}
@@ -0,0 +1,148 @@
+package graph
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"go.uber.org/goleak"
+	"go.uber.org/mock/gomock"
+
+	"github.com/openfga/openfga/pkg/tuple"
+)
+
+func TestStoreCachePartitions(t *testing.T) {
+	now := time.Now()
+	expiry := now.Add(time.Hour)
+
+	keys := func(evicted []evictedEntry) []string {
+		var keys []string
+		for _, e := range evicted {
+			keys = append(keys, e.storeID+"/"+e.cacheKey)
+		}
+		return keys
+	}
+
+	t.Run("max_entries_per_store", func(t *testing.T) {
+		p := newStoreCachePartitions(StoreCacheLimits{MaxEntries: 2})
+		require.Empty(t, p.add("a", "1", now, expiry))
+		require.Empty(t, p.add("a", "2", now, expiry))
+		require.Empty(t, p.add("b", "1", now, expiry))
+
+		// the oldest entry of the store is evicted
+		require.Equal(t, []string{"a/1"}, keys(p.add("a", "3", now, expiry)))
+		require.Equal(t, map[string]int{"a": 2, "b": 1}, p.occupancy(now))
+	})
+
+	t.Run("budget_evicts_the_largest_store_above_its_minimum", func(t *testing.T) {
+		p := newStoreCachePartitions(StoreCacheLimits{MinEntries: 3, Budget: 6})
+		for i := range 5 {
+			require.Empty(t, p.add("busy", fmt.Sprint(i), now, expiry))
+		}
+		require.Empty(t, p.add("quiet", "0", now, expiry))
+
+		require.Equal(t, []string{"busy/0"}, keys(p.add("quiet", "1", now, expiry)))
+		require.Equal(t, []string{"busy/1"}, keys(p.add("quiet", "2", now, expiry)))
+
+		// the busy store is down to its minimum, so the quiet store now evicts its own entries
+		require.Equal(t, []string{"quiet/0"}, keys(p.add("quiet", "3", now, expiry)))
+		require.Equal(t, map[string]int{"busy": 3, "quiet": 3}, p.occupancy(now))
+	})
+
+	t.Run("cached_again", func(t *testing.T) {
+		p := newStoreCachePartitions(StoreCacheLimits{MaxEntries: 2})
+		require.Empty(t, p.add("a", "1", now, expiry))
+		require.Empty(t, p.add("a", "2", now, expiry))
+		require.Empty(t, p.add("a", "1", now, expiry))
+
+		require.Equal(t, []string{"a/2"}, keys(p.add("a", "3", now, expiry)))
+	})
+
+	t.Run("expired_and_removed_entries_are_forgotten", func(t *testing.T) {
+		p := newStoreCachePartitions(StoreCacheLimits{MaxEntries: 2})
+		require.Empty(t, p.add("a", "1", now, now.Add(time.Second)))
+		require.Empty(t, p.add("a", "2", now, expiry))
+		require.Empty(t, p.add("b", "1", now, expiry))
+
+		require.Equal(t, map[string]int{"a": 1, "b": 1}, p.occupancy(now.Add(time.Minute)))
+		require.Empty(t, p.add("a", "3", now.Add(time.Minute), expiry))
+
+		p.remove("a", "2")
+		p.removeStore("b")
+		require.Equal(t, map[string]int{"a": 1}, p.occupancy(now.Add(time.Minute)))
+	})
+
+	t.Run("entries_evicted_by_the_cache_are_forgotten", func(t *testing.T) {
+		p := newStoreCachePartitions(StoreCacheLimits{MaxEntries: 2})
+		require.Empty(t, p.add("a", "1", now, expiry))
+		require.Empty(t, p.add("a", "2", now, expiry))
+
+		// an eviction of an entry that was cached again since is ignored
+		require.Empty(t, p.add("a", "1", now.Add(time.Second), expiry))
+		p.removeEvicted("1", now)
+		require.Equal(t, map[string]int{"a": 2}, p.occupancy(now))
+
+		p.removeEvicted("2", now)
+		require.Equal(t, map[string]int{"a": 1}, p.occupancy(now))
+		require.Empty(t, p.add("a", "3", now, expiry))
+	})
+
+	t.Run("budget_with_many_stores", func(t *testing.T) {
+		p := newStoreCachePartitions(StoreCacheLimits{MinEntries: 1, Budget: 10})
+		for i := range 10 {
+			require.Empty(t, p.add(fmt.Sprint(i), "0", now, expiry))
+		}
+
+		// every store holds its minimum, the store that caches more evicts its own entries
+		require.Equal(t, []string{"3/0"}, keys(p.add("3", "1", now, expiry)))
+		require.Equal(t, []string{"3/1"}, keys(p.add("3", "2", now, expiry)))
+		require.Len(t, p.occupancy(now), 10)
+	})
+}
+
+func TestResolveCheckWithStoreCacheLimits(t *testing.T) {
+	t.Cleanup(func() {
+		goleak.VerifyNone(t)
+	})
+
+	ctrl := gomock.NewController(t)
+
+	dut, err := NewCachedCheckResolver(
+		WithCacheTTL(time.Hour),
+		WithStoreCacheLimits(StoreCacheLimits{MinEntries: 1, MaxEntries: 2}),
+	)
+	require.NoError(t, err)
+	t.Cleanup(dut.Close)
+
+	mockResolver := NewMockCheckResolver(ctrl)
+	dut.SetDelegate(mockResolver)
+
+	resolve := func(storeID, object string) {
+		req, err := NewResolveCheckRequest(ResolveCheckRequestParams{
+			StoreID:              storeID,
+			AuthorizationModelID: "33",
+			TupleKey:             tuple.NewTupleKey(object, "reader", "user:XYZ"),
+		})
+		require.NoError(t, err)
+
+		resp, err := dut.ResolveCheck(context.Background(), req)
+		require.NoError(t, err)
+		require.True(t, resp.GetAllowed())
+	}
+
+	// document:1 of the busy store is evicted by document:3, so it's resolved again
+	mockResolver.EXPECT().ResolveCheck(gomock.Any(), gomock.Any()).Times(5).Return(&ResolveCheckResponse{Allowed: true}, nil)
+	resolve("busy", "document:1")
+	resolve("busy", "document:2")
+	resolve("busy", "document:3")
+	resolve("quiet", "document:1")
+	resolve("busy", "document:1")
+	resolve("quiet", "document:1")
+
+	require.Equal(t, map[string]int{"busy": 2, "quiet": 1}, dut.Stats().StoreEntries)
+
+	dut.InvalidateStore(context.Background(), "busy")
+	require.Equal(t, map[string]int{"quiet": 1}, dut.Stats().StoreEntries)
+}
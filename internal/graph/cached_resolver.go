@@ -2,7 +2,15 @@ package graph
 
 import (
 	"context"
+	"encoding/gob"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
 	"strconv"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/cespare/xxhash/v2"
@@ -11,10 +19,13 @@ import (
 	"go.opentelemetry.io/otel/attribute"
 	"go.opentelemetry.io/otel/trace"
 	"go.uber.org/zap"
+	"golang.org/x/sync/singleflight"
 
 	openfgav1 "github.com/openfga/api/proto/openfga/v1"
 
 	"github.com/openfga/openfga/internal/build"
+	"github.com/openfga/openfga/internal/clock"
+	"github.com/openfga/openfga/internal/utils"
 	"github.com/openfga/openfga/pkg/logger"
 	"github.com/openfga/openfga/pkg/storage"
 	"github.com/openfga/openfga/pkg/telemetry"
@@ -44,13 +55,73 @@ var (
 		Name:      "check_cache_invalid_hit_count",
 		Help:      "The total number of cache hits for ResolveCheck that were discarded because they were invalidated.",
 	})
+
+	checkCacheStaleFallbackCounter = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: build.ProjectName,
+		Name:      "check_cache_stale_fallback_count",
+		Help:      "The total number of ResolveCheck calls that were served from a stale cache entry because the delegate failed with a datastore error.",
+	})
+
+	checkCacheStaleGraceCounter = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: build.ProjectName,
+		Name:      "check_cache_stale_grace_count",
+		Help:      "The total number of ResolveCheck calls with MINIMIZE_LATENCY that were served from a cache entry invalidated within the stale grace, while the entry was refreshed in the background.",
+	})
+
+	checkCacheErrorCounter = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: build.ProjectName,
+		Name:      "check_cache_error_count",
+		Help:      "The total number of check cache operations that failed, labeled by operation. A failed read is treated as a cache miss.",
+	}, []string{"operation"})
+
+	conditionEvaluationCacheHitCounter = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: build.ProjectName,
+		Name:      "condition_evaluation_cache_hit_count",
+		Help:      "The total number of condition evaluations of Checks that were served from the condition results memoized for the Check.",
+	})
 )
 
+// CacheMode determines whether the CachedCheckResolver reads and writes the cache for a request.
+type CacheMode int
+
+const (
+	// CacheReadWrite serves cached results and caches the results that are resolved. It is the default.
+	CacheReadWrite CacheMode = iota
+	// CacheReadOnly serves cached results without caching the results that are resolved.
+	CacheReadOnly
+	// CacheWriteOnly caches the results that are resolved without serving cached results, e.g. to warm
+	// the cache from a background job.
+	CacheWriteOnly
+	// CacheOff neither serves cached results nor caches the results that are resolved.
+	CacheOff
+)
+
+// reads reports whether cached results are served in the mode.
+func (m CacheMode) reads() bool {
+	return m == CacheReadWrite || m == CacheReadOnly
+}
+
+// writes reports whether resolved results are cached in the mode.
+func (m CacheMode) writes() bool {
+	return m == CacheReadWrite || m == CacheWriteOnly
+}
+
 var _ storage.CacheItem = (*CheckResponseCacheEntry)(nil)
 
 type CheckResponseCacheEntry struct {
 	LastModified  time.Time
 	CheckResponse *ResolveCheckResponse
+	// Negative is whether the cached response denies the Check, in which case the entry expires after the
+	// negative result TTL of the resolver.
+	Negative bool
+	// TTL is the duration after LastModified at which the entry expires, including its jitter. Zero uses
+	// the TTL of the resolver.
+	TTL time.Duration
+}
+
+func init() {
+	// the entries are encoded with gob by the storage.CheckCache implementations backed by an external store
+	gob.Register(&CheckResponseCacheEntry{})
 }
 
 func (c *CheckResponseCacheEntry) CacheEntityType() string {
@@ -61,12 +132,59 @@ func (c *CheckResponseCacheEntry) CacheEntityType() string {
 // delegating the request to some underlying CheckResolver.
 type CachedCheckResolver struct {
 	delegate CheckResolver
-	cache    storage.InMemoryCache[any]
+	cache    storage.CheckCache
 	cacheTTL time.Duration
 	logger   logger.Logger
-	// allocatedCache is used to denote whether the cache is allocated by this struct.
+	// usersetInvalidation discards the entries of an 'object#relation' that the cache controller has
+	// invalidated since they were cached.
+	usersetInvalidation bool
+	// invalidations is the cache that the invalidations of the cache controller are read from, see
+	// WithInvalidationCache. It is nil if they are read from the cache.
+	invalidations storage.CheckCache
+	// clock is the source of the time at which entries are cached and compared against the TTL.
+	clock clock.Clock
+	// maxStaleness is the maximum age of a cache entry that may be served when the delegate
+	// fails with a datastore error. Zero disables the stale fallback.
+	maxStaleness time.Duration
+	// staleGrace is how long before the last cache invalidation an entry may have been cached and still
+	// be served to the requests with MINIMIZE_LATENCY, while it's refreshed in the background. Zero
+	// disables it.
+	staleGrace time.Duration
+	// refreshing holds the cache keys being refreshed in the background, and refreshes tracks the
+	// goroutines refreshing them.
+	refreshing sync.Map
+	refreshes  sync.WaitGroup
+	// cacheNegativeResults is whether responses that deny the Check are cached.
+	cacheNegativeResults bool
+	// negativeResultTTL is the TTL of the cached responses that deny the Check. Zero uses cacheTTL.
+	negativeResultTTL time.Duration
+	// ttlJitter is the fraction of the TTL by which the TTL of each entry is randomly shortened.
+	ttlJitter float64
+	// newCacheKeyHasher returns the hash that digests the cache keys, see WithCacheKeyHasher.
+	newCacheKeyHasher func() hash.Hash64
+	// lookups and hits count the cache lookups of this resolver and those that were served from the cache.
+	lookups atomic.Uint64
+	hits    atomic.Uint64
+	// flights collapses the concurrent identical requests that miss the cache into a single delegate call.
+	flights singleflight.Group
+	// storeKeys indexes the keys cached by this resolver by store for InvalidateStore, since the keys are
+	// hashes that can't be matched by store.
+	storeKeys cacheKeyIndex
+	// storePartitions bounds the entries cached by this resolver for each store, nil if they aren't bounded.
+	storePartitions *storeCachePartitions
+	// uncachedObjectTypes are the object types whose Check sub-problems are never cached.
+	uncachedObjectTypes map[string]struct{}
+	// primaryReadRelations are the 'objectType#relation' pairs per store that are read from the primary, the
+	// sub-problems that may read them are never cached, see WithCachePrimaryReadRelations.
+	primaryReadRelations map[string][]string
+	// highCardinalityTraceAttributes adds the tuple key of the subproblems to the spans, see checkSpanAttributes.
+	highCardinalityTraceAttributes bool
+	// cacheCostBudget is the budget of the estimated total size of the entries of the cache allocated by this
+	// resolver, see WithCacheCostBudget. Zero bounds the number of entries instead.
+	cacheCostBudget int64
+	// allocatedCache is the cache allocated by this struct, if any.
 	// If so, CachedCheckResolver is responsible for cleaning up.
-	allocatedCache bool
+	allocatedCache storage.InMemoryCache[any]
 }
 
 var _ CheckResolver = (*CachedCheckResolver)(nil)
@@ -86,11 +204,30 @@ func WithCacheTTL(ttl time.Duration) CachedCheckResolverOpt {
 // Note that the original cache will not be stopped as it may still be used by others. It is up to the caller
 // to check whether the original cache should be stopped.
 func WithExistingCache(cache storage.InMemoryCache[any]) CachedCheckResolverOpt {
+	return func(ccr *CachedCheckResolver) {
+		ccr.cache = storage.NewInMemoryCheckCache(cache)
+	}
+}
+
+// WithCheckCache sets the cache to the specified storage.CheckCache, e.g. one backed by Redis or memcached
+// and shared by the servers of a deployment. The errors of the cache are logged and treated as cache misses.
+// As with WithExistingCache, the cache is not stopped by Close.
+func WithCheckCache(cache storage.CheckCache) CachedCheckResolverOpt {
 	return func(ccr *CachedCheckResolver) {
 		ccr.cache = cache
 	}
 }
 
+// WithCacheCostBudget bounds the estimated total size in bytes of the entries of the cache allocated by the
+// resolver, see storage.CacheEntryCost, rather than their number. It doesn't apply to a cache provided with
+// WithExistingCache or WithCheckCache, which may be created with storage.WithCostFunc and storage.CacheEntryCost
+// instead.
+func WithCacheCostBudget(budget int64) CachedCheckResolverOpt {
+	return func(ccr *CachedCheckResolver) {
+		ccr.cacheCostBudget = budget
+	}
+}
+
 // WithLogger sets the logger for the cached check resolver.
 func WithLogger(logger logger.Logger) CachedCheckResolverOpt {
 	return func(ccr *CachedCheckResolver) {
@@ -98,6 +235,119 @@ func WithLogger(logger logger.Logger) CachedCheckResolverOpt {
 	}
 }
 
+// WithUsersetInvalidation discards a cached subproblem, e.g. 'group:eng#member@user:bob', once the cache
+// controller has observed a change to the tuples of its 'object#relation', e.g. 'group:eng#member', after
+// the subproblem was cached. This is meant for the memberships of usersets cached with WithUsersetCaching,
+// which are shared by many Checks and are invalidated as soon as their membership changes. The invalidations
+// are read from the cache of the resolver, or from the cache set with WithInvalidationCache.
+func WithUsersetInvalidation(enabled bool) CachedCheckResolverOpt {
+	return func(ccr *CachedCheckResolver) {
+		ccr.usersetInvalidation = enabled
+	}
+}
+
+// WithInvalidationCache sets the cache that the invalidations of WithUsersetInvalidation are read from, i.e.
+// the cache that the cache controller writes them to, for a resolver that keeps its entries in another cache,
+// e.g. one set with WithCheckCache.
+func WithInvalidationCache(cache storage.InMemoryCache[any]) CachedCheckResolverOpt {
+	return func(ccr *CachedCheckResolver) {
+		ccr.invalidations = storage.NewInMemoryCheckCache(cache)
+	}
+}
+
+// WithClock sets the clock used to timestamp cache entries and to determine whether they have expired.
+// It defaults to the system clock.
+func WithClock(c clock.Clock) CachedCheckResolverOpt {
+	return func(ccr *CachedCheckResolver) {
+		ccr.clock = c
+	}
+}
+
+// WithStaleFallback enables serving the last known cached result for a Check sub-problem when the
+// delegate fails with a datastore error, as long as the cached entry is not older than maxStaleness.
+// Such responses are marked as degraded. Requests with HIGHER_CONSISTENCY never use the fallback.
+func WithStaleFallback(maxStaleness time.Duration) CachedCheckResolverOpt {
+	return func(ccr *CachedCheckResolver) {
+		ccr.maxStaleness = maxStaleness
+	}
+}
+
+// WithStoreCacheLimits partitions the entries cached by the resolver by store: each store holds at most
+// MaxEntries entries, and once the Budget shared by the stores is spent, the entries of the stores holding
+// the most entries are evicted first, down to MinEntries. This keeps the Checks of a busy store from
+// evicting the entries of the others from a cache shared by many stores.
+func WithStoreCacheLimits(limits StoreCacheLimits) CachedCheckResolverOpt {
+	return func(ccr *CachedCheckResolver) {
+		ccr.storePartitions = newStoreCachePartitions(limits)
+	}
+}
+
+// WithStaleGrace lets the requests with MINIMIZE_LATENCY be served a cached entry that was invalidated,
+// i.e. cached before the last cache invalidation of the store, as long as it was cached less than the grace
+// before the invalidation. The entry is then refreshed in the background, without blocking the request.
+// This trades freshness for latency on hot paths. Requests with any other consistency never use it.
+func WithStaleGrace(grace time.Duration) CachedCheckResolverOpt {
+	return func(ccr *CachedCheckResolver) {
+		ccr.staleGrace = grace
+	}
+}
+
+// WithCacheNegativeResults sets whether responses that deny the Check are cached. It defaults to true.
+// When disabled, denied responses are returned without being stored, so that they are recomputed as
+// soon as the tuples that grant them are written.
+func WithCacheNegativeResults(enabled bool) CachedCheckResolverOpt {
+	return func(ccr *CachedCheckResolver) {
+		ccr.cacheNegativeResults = enabled
+	}
+}
+
+// WithNegativeResultTTL sets the TTL of the cached responses that deny the Check, e.g. to expire them
+// sooner than the responses that allow it. It defaults to the TTL set by WithCacheTTL.
+func WithNegativeResultTTL(ttl time.Duration) CachedCheckResolverOpt {
+	return func(ccr *CachedCheckResolver) {
+		ccr.negativeResultTTL = ttl
+	}
+}
+
+// WithCacheTTLJitter shortens the TTL of each cache entry by a random fraction of the TTL of up to the given
+// fraction, so that the entries cached at once, e.g. by a burst of identical sub-problems, don't all expire
+// at once. The TTL of an entry is uniformly distributed within [ttl*(1-fraction), ttl], where ttl is the
+// TTL of positive or negative results. It defaults to 0, i.e. no jitter, and must be within [0, 1].
+func WithCacheTTLJitter(fraction float64) CachedCheckResolverOpt {
+	return func(ccr *CachedCheckResolver) {
+		ccr.ttlJitter = fraction
+	}
+}
+
+// WithCacheKeyHasher sets the hash that digests the cache key of each Check sub-problem. It defaults to
+// xxhash, whose 64-bit keys are compact but may collide once the cache holds the sub-problems of many stores,
+// in which case a Check could be served the cached response of another one. A hash with a wider digest, e.g.
+// 128 bits, makes collisions negligible at the expense of the memory of longer keys. Only the digest changes:
+// the hashed content of a key is the same whatever the hash, see BuildCacheKeyWithHasher.
+func WithCacheKeyHasher(newHasher func() hash.Hash64) CachedCheckResolverOpt {
+	return func(ccr *CachedCheckResolver) {
+		ccr.newCacheKeyHasher = newHasher
+	}
+}
+
+// WithUncachedObjectTypes disables the cache for the Check sub-problems whose object is of one of the types,
+// e.g. short-lived objects whose tuples change faster than the cache TTL. Such sub-problems are neither read
+// from nor written to the cache, and are always delegated.
+//
+// This is a coarse control: it only applies to the sub-problems of those object types. The sub-problems of
+// other types whose result depends on them, e.g. 'document:1#viewer' granted through 'session:1#owner', are
+// still cached. The contextual tuples of a request are part of the cache keys, so sub-problems of other types
+// that are resolved from contextual tuples referencing those types are cached for that set of contextual
+// tuples only.
+func WithUncachedObjectTypes(objectTypes ...string) CachedCheckResolverOpt {
+	return func(ccr *CachedCheckResolver) {
+		ccr.uncachedObjectTypes = make(map[string]struct{}, len(objectTypes))
+		for _, objectType := range objectTypes {
+			ccr.uncachedObjectTypes[objectType] = struct{}{}
+		}
+	}
+}
+
 // NewCachedCheckResolver constructs a CheckResolver that delegates Check resolution to the provided delegate,
 // but before delegating the query to the delegate a cache-key lookup is made to see if the Check sub-problem
 // has already recently been computed. If the Check sub-problem is in the cache, then the response is returned
@@ -105,8 +355,11 @@ func WithLogger(logger logger.Logger) CachedCheckResolverOpt {
 // NOTE: the ResolveCheck's resolution data will be set as the default values as we actually did no database lookup.
 func NewCachedCheckResolver(opts ...CachedCheckResolverOpt) (*CachedCheckResolver, error) {
 	checker := &CachedCheckResolver{
-		cacheTTL: defaultCacheTTL,
-		logger:   logger.NewNoopLogger(),
+		cacheTTL:             defaultCacheTTL,
+		logger:               logger.NewNoopLogger(),
+		clock:                clock.New(),
+		cacheNegativeResults: true,
+		newCacheKeyHasher:    newDefaultCacheKeyHasher,
 	}
 	checker.delegate = checker
 
@@ -114,17 +367,31 @@ func NewCachedCheckResolver(opts ...CachedCheckResolverOpt) (*CachedCheckResolve
 		opt(checker)
 	}
 
+	if checker.ttlJitter < 0 || checker.ttlJitter > 1 {
+		return nil, fmt.Errorf("cache TTL jitter must be between 0 and 1, got %v", checker.ttlJitter)
+	}
+
+	if checker.newCacheKeyHasher == nil {
+		return nil, errors.New("cache key hasher must not be nil")
+	}
+
 	if checker.cache == nil {
-		checker.allocatedCache = true
 		cacheOptions := []storage.InMemoryLRUCacheOpt[any]{
 			storage.WithMaxCacheSize[any](defaultMaxCacheSize),
 		}
+		if checker.cacheCostBudget > 0 {
+			cacheOptions = []storage.InMemoryLRUCacheOpt[any]{
+				storage.WithMaxCacheSize[any](checker.cacheCostBudget),
+				storage.WithCostFunc(storage.CacheEntryCost),
+			}
+		}
 
-		var err error
-		checker.cache, err = storage.NewInMemoryLRUCache[any](cacheOptions...)
+		cache, err := storage.NewInMemoryLRUCache[any](cacheOptions...)
 		if err != nil {
 			return nil, err
 		}
+		checker.allocatedCache = cache
+		checker.cache = storage.NewInMemoryCheckCache(cache)
 	}
 
 	return checker, nil
@@ -140,11 +407,80 @@ func (c *CachedCheckResolver) GetDelegate() CheckResolver {
 	return c.delegate
 }
 
+// CacheStats is a snapshot of the cache statistics of a CachedCheckResolver.
+type CacheStats struct {
+	// Lookups is the number of times the resolver looked up a Check sub-problem in the cache.
+	Lookups uint64
+	// Hits is the number of lookups that were served from the cache.
+	Hits uint64
+	// Misses is the number of lookups that were not served from the cache, because the sub-problem
+	// was not cached or its entry was no longer valid.
+	Misses uint64
+	// HitRatio is Hits divided by Lookups, or zero if there were no lookups.
+	HitRatio float64
+	// Entries is the number of entries in the cache.
+	Entries int
+	// Evictions is the number of entries that were evicted from the cache to make room for others.
+	Evictions uint64
+	// StoreEntries is the number of entries that each store holds in the cache, keyed by store ID. It's
+	// only reported with WithStoreCacheLimits.
+	StoreEntries map[string]int
+}
+
+// sizedCache is implemented by the caches that report their number of entries and evictions,
+// e.g. storage.InMemoryLRUCache.
+type sizedCache interface {
+	Len() int
+	Evictions() uint64
+}
+
+// Stats returns the cache statistics of the resolver since it was created. Lookups, hits and misses are
+// counted per resolver, whereas entries and evictions are those of the cache, which may be shared with
+// others through WithExistingCache. Entries and evictions are zero if the cache doesn't report them.
+// It is safe to call Stats concurrently with ResolveCheck.
+func (c *CachedCheckResolver) Stats() CacheStats {
+	// hits are loaded first so that they never exceed lookups, which are counted before them
+	hits := c.hits.Load()
+	lookups := c.lookups.Load()
+
+	stats := CacheStats{
+		Lookups: lookups,
+		Hits:    hits,
+		Misses:  lookups - hits,
+	}
+	if lookups > 0 {
+		stats.HitRatio = float64(hits) / float64(lookups)
+	}
+	var cache any = c.cache
+	if inMemory, ok := cache.(*storage.InMemoryCheckCache); ok {
+		cache = inMemory.Unwrap()
+	}
+	if sized, ok := cache.(sizedCache); ok {
+		stats.Entries = sized.Len()
+		stats.Evictions = sized.Evictions()
+	}
+	if c.storePartitions != nil {
+		stats.StoreEntries = c.storePartitions.occupancy(c.clock.Now())
+	}
+	return stats
+}
+
 // Close will deallocate resource allocated by the CachedCheckResolver
-// It will not deallocate cache if it has been passed in from WithExistingCache.
+// It will not deallocate cache if it has been passed in from WithExistingCache or WithCheckCache.
 func (c *CachedCheckResolver) Close() {
-	if c.allocatedCache {
-		c.cache.Stop()
+	c.refreshes.Wait()
+	if c.allocatedCache != nil {
+		c.allocatedCache.Stop()
+	}
+}
+
+// WithCachePrimaryReadRelations disables the cache for the Check sub-problems that may read the tuples of the
+// 'objectType#relation' pairs of a store that are read from the primary, see WithPrimaryReadRelations. These
+// are the sub-problems of the pairs and of the relations that reach them through their rewrite or their
+// directly related usersets. Serving them from the cache would defeat the primary reads.
+func WithCachePrimaryReadRelations(relations map[string][]string) CachedCheckResolverOpt {
+	return func(ccr *CachedCheckResolver) {
+		ccr.primaryReadRelations = relations
 	}
 }
 
@@ -153,16 +489,55 @@ func (c *CachedCheckResolver) ResolveCheck(
 	req *ResolveCheckRequest,
 ) (*ResolveCheckResponse, error) {
 	span := trace.SpanFromContext(ctx)
+	span.SetAttributes(checkSpanAttributes(req, c.highCardinalityTraceAttributes)...)
 
-	cacheKey := BuildCacheKey(*req)
+	cacheMode := req.GetCacheMode()
+	var reason cacheBypassReason
+	switch {
+	case cacheMode == CacheOff:
+		reason = cacheBypassCacheOff
+	case c.isUncachedObjectType(req):
+		reason = cacheBypassUncachedObjectType
+	case readsPrimaryRelation(ctx, req, c.primaryReadRelations[req.GetStoreID()]):
+		reason = cacheBypassPrimaryRead
+	}
+	if reason != "" {
+		span.SetAttributes(
+			attribute.Bool("skip_cache", true),
+			attribute.String("cache_outcome", string(cacheOutcomeBypass)),
+			attribute.String("cache_bypass_reason", string(reason)),
+		)
+		return c.delegate.ResolveCheck(ctx, req)
+	}
 
-	tryCache := req.Consistency != openfgav1.ConsistencyPreference_HIGHER_CONSISTENCY
+	cacheKey := BuildCacheKeyWithHasher(*req, req.GetCacheNamespace(), c.newCacheKeyHasher)
+
+	tryCache := cacheMode.reads() && req.Consistency != openfgav1.ConsistencyPreference_HIGHER_CONSISTENCY
+	switch {
+	case !cacheMode.reads():
+		span.SetAttributes(
+			attribute.String("cache_outcome", string(cacheOutcomeBypass)),
+			attribute.String("cache_bypass_reason", string(cacheBypassWriteOnly)),
+		)
+	case !tryCache:
+		span.SetAttributes(
+			attribute.String("cache_outcome", string(cacheOutcomeBypass)),
+			attribute.String("cache_bypass_reason", string(cacheBypassConsistency)),
+		)
+	}
+
+	// staleEntry holds the entry that may be served if the delegate fails with a datastore error.
+	var staleEntry *CheckResponseCacheEntry
+	if c.maxStaleness > 0 {
+		ctx = contextWithDatastoreErrorReader(ctx)
+	}
 
 	if tryCache {
+		span.SetAttributes(attribute.String("cache_outcome", string(cacheOutcomeMiss)))
 		checkCacheTotalCounter.Inc()
-		if cachedResp := c.cache.Get(cacheKey); cachedResp != nil {
-			res := cachedResp.(*CheckResponseCacheEntry)
-			isValid := res.LastModified.After(req.LastCacheInvalidationTime)
+		c.lookups.Add(1)
+		if res := c.getCached(ctx, req, cacheKey); res != nil {
+			isValid := res.LastModified.After(req.LastCacheInvalidationTime) && !c.isExpired(res) && !c.isUsersetInvalidated(ctx, req, res)
 			c.logger.Debug("CachedCheckResolver found cache key",
 				zap.String("store_id", req.GetStoreID()),
 				zap.String("authorization_model_id", req.GetAuthorizationModelID()),
@@ -171,13 +546,33 @@ func (c *CachedCheckResolver) ResolveCheck(
 
 			span.SetAttributes(attribute.Bool("cached", isValid))
 			if isValid {
+				span.SetAttributes(attribute.String("cache_outcome", string(cacheOutcomeHit)))
 				checkCacheHitCounter.Inc()
+				c.hits.Add(1)
 				// return a copy to avoid races across goroutines
-				return res.CheckResponse.clone(), nil
+				return tracedFromCache(req, req.GetTupleKey(), res.CheckResponse.clone()), nil
+			}
+
+			if c.isWithinStaleGrace(ctx, req, res) {
+				checkCacheStaleGraceCounter.Inc()
+				span.SetAttributes(
+					attribute.Bool("stale_grace", true),
+					attribute.String("cache_outcome", string(cacheOutcomeHit)),
+				)
+				c.refreshInBackground(ctx, req, cacheKey, cacheMode)
+				// return a copy to avoid races across goroutines. The responses derived from it are stale as well,
+				// so they are degraded and aren't cached
+				graceResp := tracedFromCache(req, req.GetTupleKey(), res.CheckResponse.clone())
+				graceResp.ResolutionMetadata.Degraded = true
+				return graceResp, nil
 			}
 
 			// we tried the cache and hit an invalid entry
 			checkCacheInvalidHit.Inc()
+
+			if c.maxStaleness > 0 && clock.Since(c.clock, res.LastModified) <= c.maxStaleness {
+				staleEntry = res
+			}
 		} else {
 			c.logger.Debug("CachedCheckResolver not found cache key",
 				zap.String("store_id", req.GetStoreID()),
@@ -186,13 +581,169 @@ func (c *CachedCheckResolver) ResolveCheck(
 		}
 	}
 
-	// not in cache, or consistency options experimental flag is set, and consistency param set to HIGHER_CONSISTENCY
-	resp, err := c.delegate.ResolveCheck(ctx, req)
+	// not in cache, or consistency options experimental flag is set, and consistency param set to HIGHER_CONSISTENCY.
+	// Only the requests that read the cache share their resolution, the others ask for a fresh result.
+	var (
+		resp *ResolveCheckResponse
+		err  error
+	)
+	// the trace of a shared resolution would be the one of the request that resolved it, so traced requests
+	// resolve their own
+	if tryCache && !req.GetTrace() {
+		resp, err = c.resolveShared(ctx, req, cacheKey, cacheMode)
+	} else {
+		resp, err = c.resolveAndCache(ctx, req, cacheKey, cacheMode)
+	}
 	if err != nil {
+		if staleEntry != nil && isDatastoreError(err) {
+			c.logger.Warn("CachedCheckResolver serving stale cache entry due to datastore error",
+				zap.String("store_id", req.GetStoreID()),
+				zap.String("authorization_model_id", req.GetAuthorizationModelID()),
+				zap.String("tuple_key", req.GetTupleKey().String()),
+				zap.Error(err))
+			checkCacheStaleFallbackCounter.Inc()
+			span.SetAttributes(attribute.Bool("degraded", true))
+
+			staleResp := tracedFromCache(req, req.GetTupleKey(), staleEntry.CheckResponse.clone())
+			staleResp.ResolutionMetadata.Degraded = true
+			return staleResp, nil
+		}
+
 		telemetry.TraceError(span, err)
 		return nil, err
 	}
 
+	return resp, nil
+}
+
+// isWithinStaleGrace reports whether the entry may be served to the request although it was cached before
+// the last cache invalidation, see WithStaleGrace.
+func (c *CachedCheckResolver) isWithinStaleGrace(ctx context.Context, req *ResolveCheckRequest, res *CheckResponseCacheEntry) bool {
+	if c.staleGrace <= 0 || req.GetConsistency() != openfgav1.ConsistencyPreference_MINIMIZE_LATENCY {
+		return false
+	}
+
+	// the grace only applies to the entries that are invalid because of the last invalidation
+	if c.isExpired(res) || c.isUsersetInvalidated(ctx, req, res) {
+		return false
+	}
+
+	return req.GetLastCacheInvalidationTime().Sub(res.LastModified) <= c.staleGrace
+}
+
+// refreshInBackground resolves the request again and caches its response, without blocking the caller. The
+// refresh outlives the request, but not its deadline. Concurrent refreshes of the same entry are skipped.
+// The refresh doesn't use MINIMIZE_LATENCY, so that its subproblems aren't served within the stale grace.
+func (c *CachedCheckResolver) refreshInBackground(
+	ctx context.Context,
+	req *ResolveCheckRequest,
+	cacheKey string,
+	cacheMode CacheMode,
+) {
+	if !cacheMode.writes() {
+		return
+	}
+
+	if _, refreshing := c.refreshing.LoadOrStore(cacheKey, struct{}{}); refreshing {
+		return
+	}
+
+	refreshCtx, cancel := context.WithoutCancel(ctx), context.CancelFunc(func() {})
+	if deadline, ok := ctx.Deadline(); ok {
+		refreshCtx, cancel = context.WithDeadline(refreshCtx, deadline)
+	}
+	refreshReq := req.clone()
+	refreshReq.Consistency = openfgav1.ConsistencyPreference_UNSPECIFIED
+
+	c.refreshes.Add(1)
+	go func() {
+		defer c.refreshes.Done()
+		defer c.refreshing.Delete(cacheKey)
+		defer cancel()
+
+		if _, err := c.resolveAndCache(refreshCtx, refreshReq, cacheKey, cacheMode); err != nil {
+			c.logger.Debug("CachedCheckResolver failed to refresh cache entry",
+				zap.String("store_id", refreshReq.GetStoreID()),
+				zap.String("authorization_model_id", refreshReq.GetAuthorizationModelID()),
+				zap.String("tuple_key", refreshReq.GetTupleKey().String()),
+				zap.Error(err))
+		}
+	}()
+}
+
+// resolveShared resolves the request with the delegate once for all the concurrent identical requests,
+// and returns a copy of the shared response to each of them. A request stops waiting for the shared
+// resolution once its context is done.
+func (c *CachedCheckResolver) resolveShared(
+	ctx context.Context,
+	req *ResolveCheckRequest,
+	cacheKey string,
+	cacheMode CacheMode,
+) (*ResolveCheckResponse, error) {
+	// a request that doesn't write the cache must not stand in for one that does
+	flightKey := cacheKey + "|" + strconv.Itoa(int(cacheMode))
+
+	// leader is only read once the result is received, after the resolution set it
+	leader := false
+	flight := c.flights.DoChan(flightKey, func() (any, error) {
+		leader = true
+		return c.resolveAndCache(ctx, req, cacheKey, cacheMode)
+	})
+
+	var result singleflight.Result
+	select {
+	case result = <-flight:
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+
+	v, err, shared := result.Val, result.Err, result.Shared
+	if leader {
+		if err != nil {
+			return nil, err
+		}
+		resp := v.(*ResolveCheckResponse)
+		if shared {
+			// return a copy to avoid races across goroutines
+			return resp.clone(), nil
+		}
+		return resp, nil
+	}
+
+	// the leader may have been cancelled or timed out while this request wasn't, and whether a cycle is detected
+	// or the resolution depth is exceeded depends on the path to the request, so the request resolves these
+	// outcomes itself
+	if err != nil {
+		if ctx.Err() == nil && (errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded)) {
+			return c.resolveAndCache(ctx, req, cacheKey, cacheMode)
+		}
+		if errors.Is(err, ErrResolutionDepthExceeded) {
+			return c.resolveAndCache(ctx, req, cacheKey, cacheMode)
+		}
+		return nil, err
+	}
+
+	resp := v.(*ResolveCheckResponse)
+	if resp.GetCycleDetected() {
+		return c.resolveAndCache(ctx, req, cacheKey, cacheMode)
+	}
+	return resp.clone(), nil
+}
+
+// resolveAndCache resolves the request with the delegate and caches the response if the cache mode writes it.
+func (c *CachedCheckResolver) resolveAndCache(
+	ctx context.Context,
+	req *ResolveCheckRequest,
+	cacheKey string,
+	cacheMode CacheMode,
+) (*ResolveCheckResponse, error) {
+	span := trace.SpanFromContext(ctx)
+
+	resp, err := c.delegate.ResolveCheck(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+
 	// when the response indicates cycle detected. The result is indeterminate because the
 	// parent of the cycle could have resolved to true. Thus, we don't save the result and let
 	// the parent handle it.
@@ -205,20 +756,347 @@ func (c *CachedCheckResolver) ResolveCheck(
 		return resp, nil
 	}
 
+	// a response that was derived from stale entries would be cached as fresh, and outlive them
+	if resp.GetDegraded() {
+		span.SetAttributes(attribute.Bool("degraded", true))
+		return resp, nil
+	}
+
+	// the oracles consulted for the response are only cached for the duration of the request
+	if req.GetRequestMetadata().usersetOracleConsulted() {
+		span.SetAttributes(attribute.Bool("userset_oracle_consulted", true))
+		return resp, nil
+	}
+
+	negative := !resp.GetAllowed()
+	if !cacheMode.writes() || (negative && !c.cacheNegativeResults) {
+		return resp, nil
+	}
+
 	clonedResp := resp.clone()
 
-	c.cache.Set(cacheKey, &CheckResponseCacheEntry{LastModified: time.Now(), CheckResponse: clonedResp}, c.cacheTTL)
+	// when the stale fallback is enabled, entries are retained for up to maxStaleness so they can
+	// still be served on datastore errors
+	now := c.clock.Now()
+	ttl := c.jitteredTTL(negative)
+	retention := max(ttl, c.maxStaleness)
+	err = c.cache.Set(ctx, cacheKey, &CheckResponseCacheEntry{
+		LastModified:  now,
+		CheckResponse: clonedResp,
+		Negative:      negative,
+		TTL:           ttl,
+	}, retention)
+	if err != nil {
+		c.logCacheError(ctx, "write", err,
+			zap.String("store_id", req.GetStoreID()),
+			zap.String("authorization_model_id", req.GetAuthorizationModelID()),
+			zap.String("tuple_key", req.GetTupleKey().String()))
+		return resp, nil
+	}
+	c.storeKeys.add(req.GetStoreID(), cacheKey, now.Add(retention), now)
+	if c.storePartitions != nil {
+		for _, evicted := range c.storePartitions.add(req.GetStoreID(), cacheKey, now, now.Add(retention)) {
+			c.storeKeys.remove(evicted.cacheKey)
+			c.deleteCached(ctx, evicted.cacheKey)
+		}
+	}
 	return resp, nil
 }
 
+// Invalidate removes the cached response of the request, e.g. after a Write changed the tuples it depends on.
+// A response that is being resolved concurrently may still be cached afterward.
+func (c *CachedCheckResolver) Invalidate(ctx context.Context, req *ResolveCheckRequest) {
+	cacheKey := BuildCacheKeyWithHasher(*req, req.GetCacheNamespace(), c.newCacheKeyHasher)
+	c.storeKeys.remove(cacheKey)
+	if c.storePartitions != nil {
+		c.storePartitions.remove(req.GetStoreID(), cacheKey)
+	}
+	c.deleteCached(ctx, cacheKey)
+}
+
+// InvalidateStore removes the responses of the store that were cached by this resolver, without scanning the
+// cache. The responses cached by other resolvers that share the cache through WithExistingCache are kept.
+// A response that is being resolved concurrently may still be cached afterward.
+func (c *CachedCheckResolver) InvalidateStore(ctx context.Context, storeID string) {
+	if c.storePartitions != nil {
+		c.storePartitions.removeStore(storeID)
+	}
+	for _, cacheKey := range c.storeKeys.removeStore(storeID) {
+		c.deleteCached(ctx, cacheKey)
+	}
+}
+
+// OnCacheEviction removes the key of an entry that left the cache, e.g. that the cache evicted to make room
+// for others, from the index of the keys cached by this resolver and from the entries counted against the
+// limits of its store, see WithStoreCacheLimits. It's meant to be called from the eviction callback of the
+// cache, see storage.WithEvictionCallback. The entries that this resolver didn't cache, and the entries whose
+// key was cached again since, are ignored.
+func (c *CachedCheckResolver) OnCacheEviction(cacheKey string, value any) {
+	entry, ok := value.(*CheckResponseCacheEntry)
+	if !ok {
+		return
+	}
+	c.storeKeys.removeEvicted(cacheKey, entry.LastModified)
+	if c.storePartitions != nil {
+		c.storePartitions.removeEvicted(cacheKey, entry.LastModified)
+	}
+}
+
+// getCached returns the cache entry of the key, or nil if the key isn't cached. The cache errors are logged
+// and treated as misses, as are the values that aren't check responses, e.g. undecodable ones.
+func (c *CachedCheckResolver) getCached(ctx context.Context, req *ResolveCheckRequest, cacheKey string) *CheckResponseCacheEntry {
+	cached, err := c.cache.Get(ctx, cacheKey)
+	if err != nil {
+		c.logCacheError(ctx, "read", err,
+			zap.String("store_id", req.GetStoreID()),
+			zap.String("authorization_model_id", req.GetAuthorizationModelID()),
+			zap.String("tuple_key", req.GetTupleKey().String()))
+		return nil
+	}
+	entry, _ := cached.(*CheckResponseCacheEntry)
+	if entry == nil || entry.CheckResponse == nil {
+		return nil
+	}
+	return entry
+}
+
+// deleteCached removes the key from the cache, logging the failure to do so.
+func (c *CachedCheckResolver) deleteCached(ctx context.Context, cacheKey string) {
+	if err := c.cache.Delete(ctx, cacheKey); err != nil {
+		c.logCacheError(ctx, "delete", err)
+	}
+}
+
+// logCacheError logs the failure of the cache to perform the operation, i.e. read, write or delete, and
+// counts it in checkCacheErrorCounter.
+func (c *CachedCheckResolver) logCacheError(ctx context.Context, operation string, err error, fields ...zap.Field) {
+	checkCacheErrorCounter.WithLabelValues(operation).Inc()
+	c.logger.WarnWithContext(ctx, "CachedCheckResolver failed to "+operation+" cache entry",
+		append(fields, zap.Error(err))...)
+}
+
+// cacheKeyIndexShards is the number of shards of a cacheKeyIndex, so that the Checks cached concurrently
+// don't contend on a single lock.
+const cacheKeyIndexShards = 64
+
+// cacheKeyIndex indexes cache keys by store. Each key is kept until it is removed, until its entry leaves the
+// cache, see CachedCheckResolver.OnCacheEviction, or until the expiry of its entry, after which the entry has
+// left the cache anyway, so the index doesn't outgrow the entries cached within a TTL, even when the evictions
+// of the cache aren't reported. The keys are sharded by cache key.
+type cacheKeyIndex struct {
+	shards [cacheKeyIndexShards]cacheKeyIndexShard
+}
+
+type cacheKeyIndexShard struct {
+	mu        sync.Mutex
+	keys      map[string]indexedCacheKey     // GUARDED_BY(mu).
+	byStore   map[string]map[string]struct{} // GUARDED_BY(mu), store => cache keys.
+	nextPrune time.Time                      // GUARDED_BY(mu).
+}
+
+// indexedCacheKey is the entry of a cache key in a cacheKeyIndex.
+type indexedCacheKey struct {
+	storeID string
+	// added is the time at which the entry was cached, which tells the entry apart from the entries cached
+	// later with the same key.
+	added  time.Time
+	expiry time.Time
+}
+
+func (i *cacheKeyIndex) shard(cacheKey string) *cacheKeyIndexShard {
+	return &i.shards[xxhash.Sum64String(cacheKey)%cacheKeyIndexShards]
+}
+
+func (i *cacheKeyIndex) add(storeID, cacheKey string, expiry, now time.Time) {
+	shard := i.shard(cacheKey)
+	shard.mu.Lock()
+	defer shard.mu.Unlock()
+
+	if shard.keys == nil {
+		shard.keys = make(map[string]indexedCacheKey)
+		shard.byStore = make(map[string]map[string]struct{})
+	}
+
+	// the expired keys are pruned at most once per retention, so the shard holds the keys added within
+	// about two retentions and the cost of the pruning is amortized over them
+	if !now.Before(shard.nextPrune) {
+		shard.prune(now)
+		shard.nextPrune = expiry
+	}
+
+	if indexed, ok := shard.keys[cacheKey]; ok && indexed.storeID != storeID {
+		shard.removeLocked(cacheKey, indexed.storeID)
+	}
+	shard.keys[cacheKey] = indexedCacheKey{storeID: storeID, added: now, expiry: expiry}
+
+	keys, ok := shard.byStore[storeID]
+	if !ok {
+		keys = make(map[string]struct{})
+		shard.byStore[storeID] = keys
+	}
+	keys[cacheKey] = struct{}{}
+}
+
+func (s *cacheKeyIndexShard) prune(now time.Time) {
+	for cacheKey, indexed := range s.keys {
+		if !now.Before(indexed.expiry) {
+			s.removeLocked(cacheKey, indexed.storeID)
+		}
+	}
+}
+
+func (s *cacheKeyIndexShard) removeLocked(cacheKey, storeID string) {
+	delete(s.keys, cacheKey)
+	if keys, ok := s.byStore[storeID]; ok {
+		delete(keys, cacheKey)
+		if len(keys) == 0 {
+			delete(s.byStore, storeID)
+		}
+	}
+}
+
+func (i *cacheKeyIndex) remove(cacheKey string) {
+	shard := i.shard(cacheKey)
+	shard.mu.Lock()
+	defer shard.mu.Unlock()
+
+	if indexed, ok := shard.keys[cacheKey]; ok {
+		shard.removeLocked(cacheKey, indexed.storeID)
+	}
+}
+
+// removeEvicted removes the key of an entry that left the cache, unless the key was cached again since the
+// entry was added.
+func (i *cacheKeyIndex) removeEvicted(cacheKey string, added time.Time) {
+	shard := i.shard(cacheKey)
+	shard.mu.Lock()
+	defer shard.mu.Unlock()
+
+	if indexed, ok := shard.keys[cacheKey]; ok && indexed.added.Equal(added) {
+		shard.removeLocked(cacheKey, indexed.storeID)
+	}
+}
+
+// removeStore removes the keys of the store from the index and returns them.
+func (i *cacheKeyIndex) removeStore(storeID string) []string {
+	var cacheKeys []string
+	for n := range i.shards {
+		shard := &i.shards[n]
+		shard.mu.Lock()
+		for cacheKey := range shard.byStore[storeID] {
+			delete(shard.keys, cacheKey)
+			cacheKeys = append(cacheKeys, cacheKey)
+		}
+		delete(shard.byStore, storeID)
+		shard.mu.Unlock()
+	}
+	return cacheKeys
+}
+
+// ttl returns the TTL of the cached responses that deny the Check if negative is true, and of the cached
+// responses that allow it otherwise.
+func (c *CachedCheckResolver) ttl(negative bool) time.Duration {
+	if negative && c.negativeResultTTL > 0 {
+		return c.negativeResultTTL
+	}
+	return c.cacheTTL
+}
+
+// isUncachedObjectType returns whether the cache is disabled for the object type of the request, see
+// WithUncachedObjectTypes.
+func (c *CachedCheckResolver) isUncachedObjectType(req *ResolveCheckRequest) bool {
+	if len(c.uncachedObjectTypes) == 0 {
+		return false
+	}
+	_, ok := c.uncachedObjectTypes[tuple.GetType(req.GetTupleKey().GetObject())]
+	return ok
+}
+
+// jitteredTTL returns the TTL of a new entry, shortened by a random fraction of up to ttlJitter.
+func (c *CachedCheckResolver) jitteredTTL(negative bool) time.Duration {
+	ttl := c.ttl(negative)
+	maxJitter := time.Duration(float64(ttl) * c.ttlJitter)
+	return utils.JitterDuration(ttl-maxJitter, maxJitter)
+}
+
+// isExpired reports whether the entry is older than its TTL according to the clock of the resolver.
+// Entries retained for the stale fallback can be found in the cache past their TTL.
+func (c *CachedCheckResolver) isExpired(entry *CheckResponseCacheEntry) bool {
+	ttl := entry.TTL
+	if ttl == 0 {
+		ttl = c.ttl(entry.Negative)
+	}
+	return clock.Since(c.clock, entry.LastModified) >= ttl
+}
+
+// isUsersetInvalidated reports whether the cache controller invalidated the store, or the 'object#relation'
+// of the request, after the entry was cached. It is always false unless usersetInvalidation is enabled.
+func (c *CachedCheckResolver) isUsersetInvalidated(ctx context.Context, req *ResolveCheckRequest, entry *CheckResponseCacheEntry) bool {
+	if !c.usersetInvalidation {
+		return false
+	}
+
+	invalidations := c.invalidations
+	if invalidations == nil {
+		invalidations = c.cache
+	}
+
+	keys := []string{
+		storage.GetInvalidIteratorCacheKey(req.GetStoreID()),
+		storage.GetInvalidIteratorByObjectRelationCacheKey(req.GetStoreID(), req.GetTupleKey().GetObject(), req.GetTupleKey().GetRelation()),
+	}
+	for _, key := range keys {
+		// a cache that fails or that doesn't hold the invalidations of the cache controller can't prove that
+		// the entry was invalidated
+		cached, _ := invalidations.Get(ctx, key)
+		if invalid, ok := cached.(*storage.InvalidEntityCacheEntry); ok && invalid.LastModified.After(entry.LastModified) {
+			return true
+		}
+	}
+	return false
+}
+
+// isDatastoreError reports whether err was returned by the datastore while resolving the request, as opposed
+// to e.g. a cancelled request or an error that any retry would deterministically reproduce. Only the reads
+// of the reader that contextWithDatastoreErrorReader installs are recognized.
+func isDatastoreError(err error) bool {
+	var dsErr *datastoreError
+	return errors.As(err, &dsErr)
+}
+
 func BuildCacheKey(req ResolveCheckRequest) string {
-	tup := tuple.From(req.GetTupleKey())
-	cacheKeyString := tup.String() + req.GetInvariantCacheKey()
+	return BuildCacheKeyWithNamespace(req, "")
+}
 
-	hasher := xxhash.New()
+// BuildCacheKeyWithNamespace builds the cache key of the request within the namespace, so that identical
+// requests of different namespaces, e.g. the tenants that share a store, don't share cache entries. The
+// empty namespace builds the same key as BuildCacheKey.
+func BuildCacheKeyWithNamespace(req ResolveCheckRequest, namespace string) string {
+	return BuildCacheKeyWithHasher(req, namespace, newDefaultCacheKeyHasher)
+}
+
+// BuildCacheKeyWithHasher builds the cache key of the request within the namespace as
+// BuildCacheKeyWithNamespace does, digested by the hash returned by newHasher. A 64-bit digest is formatted
+// as a decimal number, as the default xxhash keys are, and a wider one as a hexadecimal string.
+func BuildCacheKeyWithHasher(req ResolveCheckRequest, namespace string, newHasher func() hash.Hash64) string {
+	hasher := newHasher()
+
+	// the hashes of package hash never return an error from Write, ignoring
+	if namespace != "" {
+		// a tuple key never contains whitespace, so the namespaced keys can't collide with the others
+		_, _ = io.WriteString(hasher, namespace+" ")
+	}
 
-	// Digest.WriteString returns int and a nil error, ignoring
-	_, _ = hasher.WriteString(cacheKeyString)
+	tup := tuple.From(req.GetTupleKey())
+	_, _ = io.WriteString(hasher, tup.String()+req.GetInvariantCacheKey())
 
+	if hasher.Size() > 8 {
+		return hex.EncodeToString(hasher.Sum(nil))
+	}
 	return strconv.FormatUint(hasher.Sum64(), 10)
 }
+
+// newDefaultCacheKeyHasher returns the xxhash hash that digests the cache keys by default.
+func newDefaultCacheKeyHasher() hash.Hash64 {
+	return xxhash.New()
+}
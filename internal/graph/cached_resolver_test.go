@@ -1,19 +1,34 @@
 package graph
 
 import (
+	"bytes"
 	"context"
+	"encoding/binary"
+	"encoding/gob"
+	"encoding/hex"
+	"errors"
 	"fmt"
+	"hash"
+	"hash/fnv"
+	"strconv"
 	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 
+	"github.com/cespare/xxhash/v2"
 	"github.com/stretchr/testify/require"
 	"go.uber.org/goleak"
 	"go.uber.org/mock/gomock"
 
 	openfgav1 "github.com/openfga/api/proto/openfga/v1"
 
+	"github.com/openfga/openfga/internal/clock"
+	"github.com/openfga/openfga/internal/mocks"
+	"github.com/openfga/openfga/pkg/storage"
+	"github.com/openfga/openfga/pkg/testutils"
 	"github.com/openfga/openfga/pkg/tuple"
+	"github.com/openfga/openfga/pkg/typesystem"
 )
 
 func TestResolveCheckFromCache(t *testing.T) {
@@ -538,11 +553,1051 @@ func TestResolveCheck_ConcurrentCachedReadsAndWrites(t *testing.T) {
 	}
 }
 
+func TestResolveCheckSingleflight(t *testing.T) {
+	t.Cleanup(func() {
+		goleak.VerifyNone(t)
+	})
+
+	resolveConcurrently := func(t *testing.T, consistency openfgav1.ConsistencyPreference) (int32, []*ResolveCheckResponse) {
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+
+		dut, err := NewCachedCheckResolver(WithCacheTTL(time.Hour))
+		require.NoError(t, err)
+		defer dut.Close()
+
+		var calls atomic.Int32
+		mockResolver := NewMockCheckResolver(ctrl)
+		mockResolver.EXPECT().ResolveCheck(gomock.Any(), gomock.Any()).AnyTimes().DoAndReturn(
+			func(_ context.Context, _ *ResolveCheckRequest) (*ResolveCheckResponse, error) {
+				calls.Add(1)
+				time.Sleep(100 * time.Millisecond)
+				return &ResolveCheckResponse{Allowed: true}, nil
+			})
+		dut.SetDelegate(mockResolver)
+
+		resps := make([]*ResolveCheckResponse, 50)
+		errs := make([]error, 50)
+		var wg sync.WaitGroup
+		for i := range resps {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				resps[i], errs[i] = dut.ResolveCheck(context.Background(), &ResolveCheckRequest{
+					StoreID:              "12",
+					AuthorizationModelID: "33",
+					TupleKey:             tuple.NewTupleKey("document:abc", "reader", "user:XYZ"),
+					Consistency:          consistency,
+				})
+			}()
+		}
+		wg.Wait()
+
+		for _, err := range errs {
+			require.NoError(t, err)
+		}
+		return calls.Load(), resps
+	}
+
+	t.Run("identical_misses_share_one_delegate_call", func(t *testing.T) {
+		calls, resps := resolveConcurrently(t, openfgav1.ConsistencyPreference_UNSPECIFIED)
+		require.Equal(t, int32(1), calls)
+
+		for i, resp := range resps {
+			require.True(t, resp.GetAllowed())
+			// every caller gets its own copy of the shared response
+			for _, other := range resps[:i] {
+				require.NotSame(t, other, resp)
+			}
+		}
+	})
+
+	t.Run("higher_consistency_does_not_share", func(t *testing.T) {
+		calls, _ := resolveConcurrently(t, openfgav1.ConsistencyPreference_HIGHER_CONSISTENCY)
+		require.Equal(t, int32(50), calls)
+	})
+
+	newRequest := func(depth uint32) *ResolveCheckRequest {
+		return &ResolveCheckRequest{
+			StoreID:              "12",
+			AuthorizationModelID: "33",
+			TupleKey:             tuple.NewTupleKey("document:abc", "reader", "user:XYZ"),
+			RequestMetadata:      &ResolveCheckRequestMetadata{Depth: depth},
+		}
+	}
+
+	t.Run("waiter_stops_once_its_context_is_done", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+
+		dut, err := NewCachedCheckResolver(WithCacheTTL(time.Hour))
+		require.NoError(t, err)
+		defer dut.Close()
+
+		started := make(chan struct{})
+		release := make(chan struct{})
+		mockResolver := NewMockCheckResolver(ctrl)
+		mockResolver.EXPECT().ResolveCheck(gomock.Any(), gomock.Any()).DoAndReturn(
+			func(_ context.Context, _ *ResolveCheckRequest) (*ResolveCheckResponse, error) {
+				close(started)
+				<-release
+				return &ResolveCheckResponse{Allowed: true}, nil
+			})
+		dut.SetDelegate(mockResolver)
+
+		leaderDone := make(chan error, 1)
+		go func() {
+			_, err := dut.ResolveCheck(context.Background(), newRequest(0))
+			leaderDone <- err
+		}()
+		<-started
+
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+		defer cancel()
+		_, err = dut.ResolveCheck(ctx, newRequest(0))
+		require.ErrorIs(t, err, context.DeadlineExceeded)
+
+		close(release)
+		require.NoError(t, <-leaderDone)
+	})
+
+	t.Run("depth_errors_are_resolved_again", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+
+		dut, err := NewCachedCheckResolver(WithCacheTTL(time.Hour))
+		require.NoError(t, err)
+		defer dut.Close()
+
+		// the resolution of the request at depth 1 exceeds the depth, the one at depth 0 doesn't
+		started := make(chan struct{})
+		release := make(chan struct{})
+		mockResolver := NewMockCheckResolver(ctrl)
+		mockResolver.EXPECT().ResolveCheck(gomock.Any(), gomock.Any()).Times(2).DoAndReturn(
+			func(_ context.Context, req *ResolveCheckRequest) (*ResolveCheckResponse, error) {
+				if req.GetRequestMetadata().Depth > 0 {
+					close(started)
+					<-release
+					return nil, ErrResolutionDepthExceeded
+				}
+				return &ResolveCheckResponse{Allowed: true}, nil
+			})
+		dut.SetDelegate(mockResolver)
+
+		leaderDone := make(chan error, 1)
+		go func() {
+			_, err := dut.ResolveCheck(context.Background(), newRequest(1))
+			leaderDone <- err
+		}()
+		<-started
+
+		go func() {
+			time.Sleep(10 * time.Millisecond)
+			close(release)
+		}()
+		resp, err := dut.ResolveCheck(context.Background(), newRequest(0))
+		require.NoError(t, err)
+		require.True(t, resp.GetAllowed())
+		require.ErrorIs(t, <-leaderDone, ErrResolutionDepthExceeded)
+	})
+}
+
 func TestResolveCheckExpired(t *testing.T) {
 	ctrl := gomock.NewController(t)
 	defer ctrl.Finish()
 
-	ctx := context.Background()
+	ctx := context.Background()
+
+	req := &ResolveCheckRequest{
+		StoreID:              "12",
+		AuthorizationModelID: "33",
+		TupleKey:             tuple.NewTupleKey("document:abc", "reader", "user:XYZ"),
+		RequestMetadata:      NewCheckRequestMetadata(),
+	}
+
+	result := &ResolveCheckResponse{Allowed: true}
+	initialMockResolver := NewMockCheckResolver(ctrl)
+	initialMockResolver.EXPECT().ResolveCheck(gomock.Any(), req).Times(2).Return(result, nil)
+
+	fakeClock := clock.NewFake(time.Now())
+
+	// expect first call to result in actual resolve call
+	dut, err := NewCachedCheckResolver(WithCacheTTL(time.Minute), WithClock(fakeClock))
+	require.NoError(t, err)
+	defer dut.Close()
+
+	dut.SetDelegate(initialMockResolver)
+
+	actualResult, err := dut.ResolveCheck(ctx, req)
+	require.Equal(t, result.Allowed, actualResult.Allowed)
+	require.NoError(t, err)
+
+	// just before the TTL the result is served from the cache
+	fakeClock.Advance(time.Minute - time.Nanosecond)
+
+	actualResult, err = dut.ResolveCheck(ctx, req)
+	require.Equal(t, result.Allowed, actualResult.Allowed)
+	require.NoError(t, err)
+
+	// subsequent call would have cache timeout and result in new ResolveCheck
+	fakeClock.Advance(time.Nanosecond)
+
+	actualResult, err = dut.ResolveCheck(ctx, req)
+	require.Equal(t, result.Allowed, actualResult.Allowed)
+	require.NoError(t, err)
+}
+
+func TestResolveCheckNegativeResults(t *testing.T) {
+	t.Cleanup(func() {
+		goleak.VerifyNone(t)
+	})
+
+	ctx := context.Background()
+
+	tests := []struct {
+		name             string
+		opts             []CachedCheckResolverOpt
+		allowed          bool
+		advance          time.Duration
+		expectedResolves int
+		expectedCached   bool
+	}{
+		{
+			name:             "negative_results_cached_by_default",
+			advance:          time.Minute - time.Nanosecond,
+			expectedResolves: 1,
+			expectedCached:   true,
+		},
+		{
+			name:             "negative_results_not_cached",
+			opts:             []CachedCheckResolverOpt{WithCacheNegativeResults(false)},
+			expectedResolves: 2,
+		},
+		{
+			name:             "positive_results_cached_without_negative_results",
+			opts:             []CachedCheckResolverOpt{WithCacheNegativeResults(false)},
+			allowed:          true,
+			expectedResolves: 1,
+			expectedCached:   true,
+		},
+		{
+			name:             "negative_result_ttl",
+			opts:             []CachedCheckResolverOpt{WithNegativeResultTTL(10 * time.Second)},
+			advance:          10 * time.Second,
+			expectedResolves: 2,
+			expectedCached:   true,
+		},
+		{
+			name:             "negative_result_ttl_does_not_apply_to_positive_results",
+			opts:             []CachedCheckResolverOpt{WithNegativeResultTTL(10 * time.Second)},
+			allowed:          true,
+			advance:          10 * time.Second,
+			expectedResolves: 1,
+			expectedCached:   true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			fakeClock := clock.NewFake(time.Now())
+
+			dut, err := NewCachedCheckResolver(append([]CachedCheckResolverOpt{WithCacheTTL(time.Minute), WithClock(fakeClock)}, test.opts...)...)
+			require.NoError(t, err)
+			defer dut.Close()
+
+			mockResolver := NewMockCheckResolver(ctrl)
+			mockResolver.EXPECT().ResolveCheck(gomock.Any(), gomock.Any()).Times(test.expectedResolves).Return(&ResolveCheckResponse{Allowed: test.allowed}, nil)
+			dut.SetDelegate(mockResolver)
+
+			req := &ResolveCheckRequest{
+				StoreID:              "12",
+				AuthorizationModelID: "33",
+				TupleKey:             tuple.NewTupleKey("document:abc", "reader", "user:XYZ"),
+				RequestMetadata:      NewCheckRequestMetadata(),
+			}
+
+			resp, err := dut.ResolveCheck(ctx, req)
+			require.NoError(t, err)
+			require.Equal(t, test.allowed, resp.GetAllowed())
+
+			entry, ok := cachedValue(t, dut, BuildCacheKey(*req)).(*CheckResponseCacheEntry)
+			require.Equal(t, test.expectedCached, ok)
+			if ok {
+				require.Equal(t, !test.allowed, entry.Negative)
+			}
+
+			fakeClock.Advance(test.advance)
+
+			resp, err = dut.ResolveCheck(ctx, req)
+			require.NoError(t, err)
+			require.Equal(t, test.allowed, resp.GetAllowed())
+		})
+	}
+}
+
+func TestResolveCheckTTLJitter(t *testing.T) {
+	t.Cleanup(func() {
+		goleak.VerifyNone(t)
+	})
+
+	ctx := context.Background()
+
+	t.Run("invalid_fraction", func(t *testing.T) {
+		for _, fraction := range []float64{-0.1, 1.1} {
+			_, err := NewCachedCheckResolver(WithCacheTTLJitter(fraction))
+			require.ErrorContains(t, err, "cache TTL jitter must be between 0 and 1")
+		}
+	})
+
+	tests := []struct {
+		name        string
+		opts        []CachedCheckResolverOpt
+		minPositive time.Duration
+		minNegative time.Duration
+	}{
+		{
+			name:        "no_jitter_by_default",
+			minPositive: time.Minute,
+			minNegative: 10 * time.Second,
+		},
+		{
+			name:        "jitter_per_entry",
+			opts:        []CachedCheckResolverOpt{WithCacheTTLJitter(0.5)},
+			minPositive: 30 * time.Second,
+			minNegative: 5 * time.Second,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			fakeClock := clock.NewFake(time.Now())
+
+			dut, err := NewCachedCheckResolver(append([]CachedCheckResolverOpt{
+				WithCacheTTL(time.Minute),
+				WithNegativeResultTTL(10 * time.Second),
+				WithClock(fakeClock),
+			}, test.opts...)...)
+			require.NoError(t, err)
+			defer dut.Close()
+
+			mockResolver := NewMockCheckResolver(ctrl)
+			mockResolver.EXPECT().ResolveCheck(gomock.Any(), gomock.Any()).AnyTimes().DoAndReturn(
+				func(_ context.Context, req *ResolveCheckRequest) (*ResolveCheckResponse, error) {
+					return &ResolveCheckResponse{Allowed: req.GetTupleKey().GetRelation() == "viewer"}, nil
+				})
+			dut.SetDelegate(mockResolver)
+
+			ttls := map[bool]map[time.Duration]struct{}{true: {}, false: {}}
+			for i := 0; i < 50; i++ {
+				for _, relation := range []string{"viewer", "editor"} {
+					req := &ResolveCheckRequest{
+						StoreID:              "12",
+						AuthorizationModelID: "33",
+						TupleKey:             tuple.NewTupleKey(fmt.Sprintf("document:%d", i), relation, "user:XYZ"),
+						RequestMetadata:      NewCheckRequestMetadata(),
+					}
+					resp, err := dut.ResolveCheck(ctx, req)
+					require.NoError(t, err)
+
+					entry, ok := cachedValue(t, dut, BuildCacheKey(*req)).(*CheckResponseCacheEntry)
+					require.True(t, ok)
+
+					maxTTL, minTTL := time.Minute, test.minPositive
+					if !resp.GetAllowed() {
+						maxTTL, minTTL = 10*time.Second, test.minNegative
+					}
+					require.GreaterOrEqual(t, entry.TTL, minTTL)
+					require.LessOrEqual(t, entry.TTL, maxTTL)
+					ttls[resp.GetAllowed()][entry.TTL] = struct{}{}
+				}
+			}
+
+			jittered := test.minPositive != time.Minute
+			require.Equal(t, jittered, len(ttls[true]) > 1)
+			require.Equal(t, jittered, len(ttls[false]) > 1)
+		})
+	}
+
+	t.Run("entries_expire_after_their_ttl", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+
+		fakeClock := clock.NewFake(time.Now())
+
+		dut, err := NewCachedCheckResolver(WithCacheTTL(time.Minute), WithCacheTTLJitter(0.5), WithClock(fakeClock))
+		require.NoError(t, err)
+		defer dut.Close()
+
+		mockResolver := NewMockCheckResolver(ctrl)
+		mockResolver.EXPECT().ResolveCheck(gomock.Any(), gomock.Any()).Times(2).Return(&ResolveCheckResponse{Allowed: true}, nil)
+		dut.SetDelegate(mockResolver)
+
+		req := &ResolveCheckRequest{
+			StoreID:              "12",
+			AuthorizationModelID: "33",
+			TupleKey:             tuple.NewTupleKey("document:abc", "reader", "user:XYZ"),
+			RequestMetadata:      NewCheckRequestMetadata(),
+		}
+
+		_, err = dut.ResolveCheck(ctx, req)
+		require.NoError(t, err)
+
+		entry, ok := cachedValue(t, dut, BuildCacheKey(*req)).(*CheckResponseCacheEntry)
+		require.True(t, ok)
+
+		// just before its TTL the entry is served from the cache
+		fakeClock.Advance(entry.TTL - time.Nanosecond)
+		_, err = dut.ResolveCheck(ctx, req)
+		require.NoError(t, err)
+
+		fakeClock.Advance(time.Nanosecond)
+		_, err = dut.ResolveCheck(ctx, req)
+		require.NoError(t, err)
+	})
+}
+
+func TestResolveCheckInvalidationWindow(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	ctx := context.Background()
+
+	fakeClock := clock.NewFake(time.Now())
+	cachedAt := fakeClock.Now()
+
+	newRequest := func(lastInvalidation time.Time) *ResolveCheckRequest {
+		return &ResolveCheckRequest{
+			StoreID:                   "12",
+			AuthorizationModelID:      "33",
+			TupleKey:                  tuple.NewTupleKey("document:abc", "reader", "user:XYZ"),
+			RequestMetadata:           NewCheckRequestMetadata(),
+			LastCacheInvalidationTime: lastInvalidation,
+		}
+	}
+
+	mockResolver := NewMockCheckResolver(ctrl)
+	mockResolver.EXPECT().ResolveCheck(gomock.Any(), gomock.Any()).Times(2).Return(&ResolveCheckResponse{Allowed: true}, nil)
+
+	dut, err := NewCachedCheckResolver(WithCacheTTL(time.Hour), WithClock(fakeClock))
+	require.NoError(t, err)
+	defer dut.Close()
+
+	dut.SetDelegate(mockResolver)
+
+	_, err = dut.ResolveCheck(ctx, newRequest(time.Time{}))
+	require.NoError(t, err)
+
+	fakeClock.Advance(time.Minute)
+
+	// a write before the entry was cached does not invalidate it
+	_, err = dut.ResolveCheck(ctx, newRequest(cachedAt.Add(-time.Second)))
+	require.NoError(t, err)
+
+	// a write after the entry was cached invalidates it
+	_, err = dut.ResolveCheck(ctx, newRequest(cachedAt.Add(time.Second)))
+	require.NoError(t, err)
+}
+
+func TestResolveCheckUsersetInvalidation(t *testing.T) {
+	ctx := context.Background()
+
+	req := &ResolveCheckRequest{
+		StoreID:              "12",
+		AuthorizationModelID: "33",
+		TupleKey:             tuple.NewTupleKey("group:eng", "member", "user:bob"),
+		RequestMetadata:      NewCheckRequestMetadata(),
+	}
+
+	tests := []struct {
+		name             string
+		enabled          bool
+		checkCache       bool
+		invalidatedKey   string
+		expectedResolves int
+	}{
+		{
+			name:             "membership_change_invalidates_the_userset",
+			enabled:          true,
+			invalidatedKey:   storage.GetInvalidIteratorByObjectRelationCacheKey("12", "group:eng", "member"),
+			expectedResolves: 2,
+		},
+		{
+			name:             "store_invalidation_invalidates_the_userset",
+			enabled:          true,
+			invalidatedKey:   storage.GetInvalidIteratorCacheKey("12"),
+			expectedResolves: 2,
+		},
+		{
+			name:             "invalidations_are_read_from_the_invalidation_cache",
+			enabled:          true,
+			checkCache:       true,
+			invalidatedKey:   storage.GetInvalidIteratorByObjectRelationCacheKey("12", "group:eng", "member"),
+			expectedResolves: 2,
+		},
+		{
+			name:             "change_to_another_userset_is_ignored",
+			enabled:          true,
+			invalidatedKey:   storage.GetInvalidIteratorByObjectRelationCacheKey("12", "group:sales", "member"),
+			expectedResolves: 1,
+		},
+		{
+			name:             "disabled",
+			invalidatedKey:   storage.GetInvalidIteratorByObjectRelationCacheKey("12", "group:eng", "member"),
+			expectedResolves: 1,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			cache, err := storage.NewInMemoryLRUCache[any]()
+			require.NoError(t, err)
+			defer cache.Stop()
+
+			fakeClock := clock.NewFake(time.Now())
+
+			cacheOption := WithExistingCache(cache)
+			if test.checkCache {
+				// the entries are kept in another cache than the one the invalidations are written to
+				cacheOption = WithCheckCache(&encodingCheckCache{})
+			}
+
+			dut, err := NewCachedCheckResolver(
+				cacheOption,
+				WithInvalidationCache(cache),
+				WithCacheTTL(time.Hour),
+				WithClock(fakeClock),
+				WithUsersetInvalidation(test.enabled),
+			)
+			require.NoError(t, err)
+			defer dut.Close()
+
+			mockResolver := NewMockCheckResolver(ctrl)
+			mockResolver.EXPECT().ResolveCheck(gomock.Any(), gomock.Any()).Times(test.expectedResolves).Return(&ResolveCheckResponse{Allowed: true}, nil)
+			dut.SetDelegate(mockResolver)
+
+			_, err = dut.ResolveCheck(ctx, req)
+			require.NoError(t, err)
+
+			cache.Set(test.invalidatedKey, &storage.InvalidEntityCacheEntry{LastModified: fakeClock.Now().Add(time.Second)}, time.Hour)
+
+			_, err = dut.ResolveCheck(ctx, req)
+			require.NoError(t, err)
+		})
+	}
+}
+
+func TestResolveCheckLastChangelogRecent(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	ctx := context.Background()
+
+	req := &ResolveCheckRequest{
+		StoreID:              "12",
+		AuthorizationModelID: "33",
+		TupleKey: &openfgav1.TupleKey{
+			Object:   "document:abc",
+			Relation: "reader",
+			User:     "user:XYZ",
+		},
+		RequestMetadata:           NewCheckRequestMetadata(),
+		LastCacheInvalidationTime: time.Now().Add(5 * time.Minute),
+	}
+
+	result := &ResolveCheckResponse{Allowed: true}
+	initialMockResolver := NewMockCheckResolver(ctrl)
+	initialMockResolver.EXPECT().ResolveCheck(gomock.Any(), req).Times(2).Return(result, nil)
+
+	// expect first call to result in actual resolve call
+	dut, err := NewCachedCheckResolver(WithCacheTTL(1 * time.Hour))
+	require.NoError(t, err)
+	defer dut.Close()
+
+	dut.SetDelegate(initialMockResolver)
+
+	actualResult, err := dut.ResolveCheck(ctx, req)
+	require.Equal(t, result.Allowed, actualResult.Allowed)
+	require.NoError(t, err)
+
+	actualResult, err = dut.ResolveCheck(ctx, req)
+	require.Equal(t, result.Allowed, actualResult.Allowed)
+	require.NoError(t, err)
+}
+
+func TestResolveCheckStaleFallback(t *testing.T) {
+	t.Cleanup(func() {
+		goleak.VerifyNone(t)
+	})
+
+	ctx := context.Background()
+	datastoreErr := errors.New("connection refused")
+
+	newRequest := func(consistency openfgav1.ConsistencyPreference) *ResolveCheckRequest {
+		return &ResolveCheckRequest{
+			StoreID:              "12",
+			AuthorizationModelID: "33",
+			TupleKey:             tuple.NewTupleKey("document:abc", "reader", "user:XYZ"),
+			RequestMetadata:      NewCheckRequestMetadata(),
+			Consistency:          consistency,
+			// every cached entry is invalidated, so the delegate is always called
+			LastCacheInvalidationTime: time.Now().Add(5 * time.Minute),
+		}
+	}
+
+	tests := []struct {
+		name             string
+		opts             []CachedCheckResolverOpt
+		consistency      openfgav1.ConsistencyPreference
+		readErr          error
+		delegateErr      error
+		expectedErr      error
+		expectedDegraded bool
+	}{
+		{
+			name:             "datastore_error_serves_stale_entry",
+			opts:             []CachedCheckResolverOpt{WithStaleFallback(time.Minute)},
+			readErr:          datastoreErr,
+			expectedDegraded: true,
+		},
+		{
+			name:        "disabled_by_default",
+			readErr:     datastoreErr,
+			expectedErr: datastoreErr,
+		},
+		{
+			name:        "higher_consistency_never_falls_back",
+			opts:        []CachedCheckResolverOpt{WithStaleFallback(time.Minute)},
+			consistency: openfgav1.ConsistencyPreference_HIGHER_CONSISTENCY,
+			readErr:     datastoreErr,
+			expectedErr: datastoreErr,
+		},
+		{
+			name:        "context_cancellation_is_not_a_datastore_error",
+			opts:        []CachedCheckResolverOpt{WithStaleFallback(time.Minute)},
+			readErr:     context.Canceled,
+			expectedErr: context.Canceled,
+		},
+		{
+			name:        "errors_not_returned_by_the_datastore_are_not_datastore_errors",
+			opts:        []CachedCheckResolverOpt{WithStaleFallback(time.Minute)},
+			delegateErr: datastoreErr,
+			expectedErr: datastoreErr,
+		},
+		{
+			name:        "entry_older_than_max_staleness",
+			opts:        []CachedCheckResolverOpt{WithStaleFallback(time.Nanosecond)},
+			readErr:     datastoreErr,
+			expectedErr: datastoreErr,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			dut, err := NewCachedCheckResolver(append([]CachedCheckResolverOpt{WithCacheTTL(time.Hour)}, test.opts...)...)
+			require.NoError(t, err)
+			defer dut.Close()
+
+			mockResolver := NewMockCheckResolver(ctrl)
+			dut.SetDelegate(mockResolver)
+
+			mockReader := mocks.NewMockRelationshipTupleReader(ctrl)
+			mockReader.EXPECT().ReadUserTuple(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).AnyTimes().Return(nil, test.readErr)
+			ctx := storage.ContextWithRelationshipTupleReader(ctx, mockReader)
+
+			gomock.InOrder(
+				mockResolver.EXPECT().ResolveCheck(gomock.Any(), gomock.Any()).Return(&ResolveCheckResponse{Allowed: true}, nil),
+				mockResolver.EXPECT().ResolveCheck(gomock.Any(), gomock.Any()).DoAndReturn(
+					func(ctx context.Context, req *ResolveCheckRequest) (*ResolveCheckResponse, error) {
+						if test.delegateErr != nil {
+							return nil, test.delegateErr
+						}
+						ds, _ := storage.RelationshipTupleReaderFromContext(ctx)
+						_, err := ds.ReadUserTuple(ctx, req.GetStoreID(), req.GetTupleKey(), storage.ReadUserTupleOptions{})
+						return nil, err
+					}),
+			)
+
+			resp, err := dut.ResolveCheck(ctx, newRequest(test.consistency))
+			require.NoError(t, err)
+			require.True(t, resp.GetAllowed())
+			require.False(t, resp.GetDegraded())
+
+			resp, err = dut.ResolveCheck(ctx, newRequest(test.consistency))
+			if test.expectedErr != nil {
+				require.ErrorIs(t, err, test.expectedErr)
+				require.Nil(t, resp)
+				return
+			}
+
+			require.NoError(t, err)
+			require.True(t, resp.GetAllowed())
+			require.Equal(t, test.expectedDegraded, resp.GetDegraded())
+		})
+	}
+}
+
+func TestResolveCheckDoesNotCacheDegradedResponses(t *testing.T) {
+	t.Cleanup(func() {
+		goleak.VerifyNone(t)
+	})
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	dut, err := NewCachedCheckResolver(WithCacheTTL(time.Hour), WithStaleFallback(time.Minute))
+	require.NoError(t, err)
+	defer dut.Close()
+
+	mockResolver := NewMockCheckResolver(ctrl)
+	dut.SetDelegate(mockResolver)
+
+	req := &ResolveCheckRequest{
+		StoreID:              "12",
+		AuthorizationModelID: "33",
+		TupleKey:             tuple.NewTupleKey("document:abc", "reader", "user:XYZ"),
+		RequestMetadata:      NewCheckRequestMetadata(),
+	}
+
+	// a parent that was resolved from stale entries of its subproblems is resolved again
+	degraded := &ResolveCheckResponse{Allowed: true, ResolutionMetadata: ResolveCheckResponseMetadata{Degraded: true}}
+	gomock.InOrder(
+		mockResolver.EXPECT().ResolveCheck(gomock.Any(), gomock.Any()).Return(degraded, nil),
+		mockResolver.EXPECT().ResolveCheck(gomock.Any(), gomock.Any()).Return(&ResolveCheckResponse{Allowed: true}, nil),
+	)
+
+	resp, err := dut.ResolveCheck(context.Background(), req)
+	require.NoError(t, err)
+	require.True(t, resp.GetDegraded())
+
+	resp, err = dut.ResolveCheck(context.Background(), req)
+	require.NoError(t, err)
+	require.True(t, resp.GetAllowed())
+	require.False(t, resp.GetDegraded())
+}
+
+func TestResolveCheckStaleGrace(t *testing.T) {
+	t.Cleanup(func() {
+		goleak.VerifyNone(t)
+	})
+
+	ctx := context.Background()
+
+	newRequest := func(consistency openfgav1.ConsistencyPreference, lastCacheInvalidationTime time.Time) *ResolveCheckRequest {
+		return &ResolveCheckRequest{
+			StoreID:                   "12",
+			AuthorizationModelID:      "33",
+			TupleKey:                  tuple.NewTupleKey("document:abc", "reader", "user:XYZ"),
+			RequestMetadata:           NewCheckRequestMetadata(),
+			Consistency:               consistency,
+			LastCacheInvalidationTime: lastCacheInvalidationTime,
+		}
+	}
+
+	// newResolver caches an allowed response, and returns the time of an invalidation right after it
+	newResolver := func(t *testing.T, grace time.Duration) (*CachedCheckResolver, *MockCheckResolver, time.Time) {
+		ctrl := gomock.NewController(t)
+
+		dut, err := NewCachedCheckResolver(WithCacheTTL(time.Hour), WithStaleGrace(grace))
+		require.NoError(t, err)
+		t.Cleanup(dut.Close)
+
+		mockResolver := NewMockCheckResolver(ctrl)
+		dut.SetDelegate(mockResolver)
+
+		mockResolver.EXPECT().ResolveCheck(gomock.Any(), gomock.Any()).Return(&ResolveCheckResponse{Allowed: true}, nil)
+		resp, err := dut.ResolveCheck(ctx, newRequest(openfgav1.ConsistencyPreference_UNSPECIFIED, time.Time{}))
+		require.NoError(t, err)
+		require.True(t, resp.GetAllowed())
+
+		time.Sleep(time.Millisecond)
+		return dut, mockResolver, time.Now()
+	}
+
+	t.Run("minimize_latency_serves_the_entry_and_refreshes_it", func(t *testing.T) {
+		dut, mockResolver, invalidation := newResolver(t, time.Minute)
+
+		release := make(chan struct{})
+		mockResolver.EXPECT().ResolveCheck(gomock.Any(), gomock.Any()).DoAndReturn(
+			func(_ context.Context, req *ResolveCheckRequest) (*ResolveCheckResponse, error) {
+				<-release
+				// the subproblems of the refresh aren't served within the grace
+				require.NotEqual(t, openfgav1.ConsistencyPreference_MINIMIZE_LATENCY, req.GetConsistency())
+				return &ResolveCheckResponse{Allowed: false}, nil
+			})
+
+		// the request isn't blocked by the refresh, and the responses derived from the entry aren't cached
+		resp, err := dut.ResolveCheck(ctx, newRequest(openfgav1.ConsistencyPreference_MINIMIZE_LATENCY, invalidation))
+		require.NoError(t, err)
+		require.True(t, resp.GetAllowed())
+		require.True(t, resp.GetDegraded())
+
+		close(release)
+		dut.refreshes.Wait()
+
+		// the refreshed entry was cached after the invalidation, so it's valid for any request
+		resp, err = dut.ResolveCheck(ctx, newRequest(openfgav1.ConsistencyPreference_UNSPECIFIED, invalidation))
+		require.NoError(t, err)
+		require.False(t, resp.GetAllowed())
+	})
+
+	t.Run("other_consistencies_resolve_the_request", func(t *testing.T) {
+		dut, mockResolver, invalidation := newResolver(t, time.Minute)
+		mockResolver.EXPECT().ResolveCheck(gomock.Any(), gomock.Any()).Return(&ResolveCheckResponse{Allowed: false}, nil)
+
+		resp, err := dut.ResolveCheck(ctx, newRequest(openfgav1.ConsistencyPreference_UNSPECIFIED, invalidation))
+		require.NoError(t, err)
+		require.False(t, resp.GetAllowed())
+	})
+
+	t.Run("entry_cached_before_the_grace", func(t *testing.T) {
+		dut, mockResolver, invalidation := newResolver(t, time.Nanosecond)
+		mockResolver.EXPECT().ResolveCheck(gomock.Any(), gomock.Any()).Return(&ResolveCheckResponse{Allowed: false}, nil)
+
+		resp, err := dut.ResolveCheck(ctx, newRequest(openfgav1.ConsistencyPreference_MINIMIZE_LATENCY, invalidation))
+		require.NoError(t, err)
+		require.False(t, resp.GetAllowed())
+	})
+}
+
+func TestResolveCheckCacheOff(t *testing.T) {
+	t.Cleanup(func() {
+		goleak.VerifyNone(t)
+	})
+
+	ctx := context.Background()
+
+	newRequest := func(skipCache bool) *ResolveCheckRequest {
+		cacheMode := CacheReadWrite
+		if skipCache {
+			cacheMode = CacheOff
+		}
+		req, err := NewResolveCheckRequest(ResolveCheckRequestParams{
+			StoreID:              "12",
+			AuthorizationModelID: "33",
+			TupleKey:             tuple.NewTupleKey("document:abc", "reader", "user:XYZ"),
+			CacheMode:            cacheMode,
+		})
+		require.NoError(t, err)
+		return req
+	}
+
+	t.Run("does_not_read_existing_entry", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+
+		dut, err := NewCachedCheckResolver(WithCacheTTL(time.Hour))
+		require.NoError(t, err)
+		defer dut.Close()
+
+		mockResolver := NewMockCheckResolver(ctrl)
+		dut.SetDelegate(mockResolver)
+
+		gomock.InOrder(
+			mockResolver.EXPECT().ResolveCheck(gomock.Any(), gomock.Any()).Return(&ResolveCheckResponse{Allowed: true}, nil),
+			mockResolver.EXPECT().ResolveCheck(gomock.Any(), gomock.Any()).Return(&ResolveCheckResponse{Allowed: false}, nil),
+		)
+
+		resp, err := dut.ResolveCheck(ctx, newRequest(false))
+		require.NoError(t, err)
+		require.True(t, resp.GetAllowed())
+
+		resp, err = dut.ResolveCheck(ctx, newRequest(true))
+		require.NoError(t, err)
+		require.False(t, resp.GetAllowed())
+
+		// the entry cached before is unaffected by the request that skipped the cache
+		resp, err = dut.ResolveCheck(ctx, newRequest(false))
+		require.NoError(t, err)
+		require.True(t, resp.GetAllowed())
+	})
+
+	t.Run("does_not_create_entry", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+
+		dut, err := NewCachedCheckResolver(WithCacheTTL(time.Hour))
+		require.NoError(t, err)
+		defer dut.Close()
+
+		mockResolver := NewMockCheckResolver(ctrl)
+		dut.SetDelegate(mockResolver)
+
+		gomock.InOrder(
+			mockResolver.EXPECT().ResolveCheck(gomock.Any(), gomock.Any()).Return(&ResolveCheckResponse{Allowed: true}, nil),
+			mockResolver.EXPECT().ResolveCheck(gomock.Any(), gomock.Any()).Return(&ResolveCheckResponse{Allowed: false}, nil),
+		)
+
+		req := newRequest(true)
+		resp, err := dut.ResolveCheck(ctx, req)
+		require.NoError(t, err)
+		require.True(t, resp.GetAllowed())
+		require.Nil(t, cachedValue(t, dut, BuildCacheKey(*req)))
+
+		resp, err = dut.ResolveCheck(ctx, newRequest(false))
+		require.NoError(t, err)
+		require.False(t, resp.GetAllowed())
+	})
+}
+
+func TestResolveCheckCacheMode(t *testing.T) {
+	t.Cleanup(func() {
+		goleak.VerifyNone(t)
+	})
+
+	ctx := context.Background()
+
+	tests := []struct {
+		name            string
+		mode            CacheMode
+		cached          bool
+		consistency     openfgav1.ConsistencyPreference
+		expectResolve   bool
+		expectedAllowed bool
+		// expectEntry is whether the cache has an entry after the request, whose result is entryAllowed
+		expectEntry  bool
+		entryAllowed bool
+	}{
+		{
+			name:            "read_write_serves_cached_result",
+			mode:            CacheReadWrite,
+			cached:          true,
+			expectedAllowed: true,
+			expectEntry:     true,
+			entryAllowed:    true,
+		},
+		{
+			name:            "read_write_caches_result",
+			mode:            CacheReadWrite,
+			expectResolve:   true,
+			expectedAllowed: false,
+			expectEntry:     true,
+		},
+		{
+			name:            "read_only_serves_cached_result",
+			mode:            CacheReadOnly,
+			cached:          true,
+			expectedAllowed: true,
+			expectEntry:     true,
+			entryAllowed:    true,
+		},
+		{
+			name:            "read_only_does_not_cache_result",
+			mode:            CacheReadOnly,
+			expectResolve:   true,
+			expectedAllowed: false,
+		},
+		{
+			name:            "write_only_overwrites_cached_result",
+			mode:            CacheWriteOnly,
+			cached:          true,
+			expectResolve:   true,
+			expectedAllowed: false,
+			expectEntry:     true,
+		},
+		{
+			name:            "off_neither_reads_nor_writes",
+			mode:            CacheOff,
+			cached:          true,
+			expectResolve:   true,
+			expectedAllowed: false,
+			expectEntry:     true,
+			entryAllowed:    true,
+		},
+		{
+			name:            "read_only_with_higher_consistency_does_not_read",
+			mode:            CacheReadOnly,
+			cached:          true,
+			consistency:     openfgav1.ConsistencyPreference_HIGHER_CONSISTENCY,
+			expectResolve:   true,
+			expectedAllowed: false,
+			expectEntry:     true,
+			entryAllowed:    true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			dut, err := NewCachedCheckResolver(WithCacheTTL(time.Hour))
+			require.NoError(t, err)
+			defer dut.Close()
+
+			mockResolver := NewMockCheckResolver(ctrl)
+			dut.SetDelegate(mockResolver)
+
+			newRequest := func(mode CacheMode, consistency openfgav1.ConsistencyPreference) *ResolveCheckRequest {
+				return &ResolveCheckRequest{
+					StoreID:              "12",
+					AuthorizationModelID: "33",
+					TupleKey:             tuple.NewTupleKey("document:abc", "reader", "user:XYZ"),
+					RequestMetadata:      NewCheckRequestMetadata(),
+					CacheMode:            mode,
+					Consistency:          consistency,
+				}
+			}
+
+			if test.cached {
+				mockResolver.EXPECT().ResolveCheck(gomock.Any(), gomock.Any()).Times(1).Return(&ResolveCheckResponse{Allowed: true}, nil)
+				_, err := dut.ResolveCheck(ctx, newRequest(CacheReadWrite, openfgav1.ConsistencyPreference_UNSPECIFIED))
+				require.NoError(t, err)
+			}
+
+			if test.expectResolve {
+				mockResolver.EXPECT().ResolveCheck(gomock.Any(), gomock.Any()).Times(1).Return(&ResolveCheckResponse{Allowed: false}, nil)
+			}
+
+			req := newRequest(test.mode, test.consistency)
+			resp, err := dut.ResolveCheck(ctx, req)
+			require.NoError(t, err)
+			require.Equal(t, test.expectedAllowed, resp.GetAllowed())
+
+			entry, ok := cachedValue(t, dut, BuildCacheKey(*req)).(*CheckResponseCacheEntry)
+			require.Equal(t, test.expectEntry, ok)
+			if ok {
+				require.Equal(t, test.entryAllowed, entry.CheckResponse.GetAllowed())
+			}
+		})
+	}
+}
+
+func TestCachedCheckResolverStats(t *testing.T) {
+	t.Cleanup(func() {
+		goleak.VerifyNone(t)
+	})
+
+	ctx := context.Background()
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	newResolver := func() *CachedCheckResolver {
+		dut, err := NewCachedCheckResolver()
+		require.NoError(t, err)
+		t.Cleanup(dut.Close)
+
+		mockResolver := NewMockCheckResolver(ctrl)
+		mockResolver.EXPECT().ResolveCheck(gomock.Any(), gomock.Any()).AnyTimes().Return(&ResolveCheckResponse{Allowed: true}, nil)
+		dut.SetDelegate(mockResolver)
+		return dut
+	}
+
+	dut := newResolver()
+	other := newResolver()
+
+	require.Equal(t, CacheStats{}, dut.Stats())
 
 	req := &ResolveCheckRequest{
 		StoreID:              "12",
@@ -551,65 +1606,376 @@ func TestResolveCheckExpired(t *testing.T) {
 		RequestMetadata:      NewCheckRequestMetadata(),
 	}
 
-	result := &ResolveCheckResponse{Allowed: true}
-	initialMockResolver := NewMockCheckResolver(ctrl)
-	initialMockResolver.EXPECT().ResolveCheck(gomock.Any(), req).Times(2).Return(result, nil)
+	// snapshots taken while ResolveCheck runs are consistent
+	var (
+		wg           sync.WaitGroup
+		inconsistent bool
+	)
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for i := 0; i < 100; i++ {
+			if stats := dut.Stats(); stats.Hits > stats.Lookups {
+				inconsistent = true
+			}
+		}
+	}()
 
-	// expect first call to result in actual resolve call
-	dut, err := NewCachedCheckResolver(WithCacheTTL(1 * time.Microsecond))
-	require.NoError(t, err)
-	defer dut.Close()
+	for i := 0; i < 4; i++ {
+		_, err := dut.ResolveCheck(ctx, req)
+		require.NoError(t, err)
+	}
+	wg.Wait()
+	require.False(t, inconsistent)
 
-	dut.SetDelegate(initialMockResolver)
+	stats := dut.Stats()
+	require.Equal(t, uint64(4), stats.Lookups)
+	require.Equal(t, uint64(3), stats.Hits)
+	require.Equal(t, uint64(1), stats.Misses)
+	require.InDelta(t, 0.75, stats.HitRatio, 0.0001)
+	require.Zero(t, stats.Evictions)
+	require.Eventually(t, func() bool {
+		return dut.Stats().Entries == 1
+	}, time.Second, 10*time.Millisecond)
 
-	actualResult, err := dut.ResolveCheck(ctx, req)
-	require.Equal(t, result.Allowed, actualResult.Allowed)
+	// resolvers count their lookups independently
+	require.Equal(t, CacheStats{}, other.Stats())
+}
+
+func TestResolveCheckCacheCostBudget(t *testing.T) {
+	t.Cleanup(func() {
+		goleak.VerifyNone(t)
+	})
+
+	ctx := context.Background()
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	dut, err := NewCachedCheckResolver(WithCacheCostBudget(3 * storage.CacheEntryCost(&CheckResponseCacheEntry{})))
 	require.NoError(t, err)
+	t.Cleanup(dut.Close)
 
-	// subsequent call would have cache timeout and result in new ResolveCheck
-	time.Sleep(5 * time.Microsecond)
+	mockResolver := NewMockCheckResolver(ctrl)
+	mockResolver.EXPECT().ResolveCheck(gomock.Any(), gomock.Any()).AnyTimes().Return(&ResolveCheckResponse{Allowed: true}, nil)
+	dut.SetDelegate(mockResolver)
 
-	actualResult, err = dut.ResolveCheck(ctx, req)
-	require.Equal(t, result.Allowed, actualResult.Allowed)
+	for i := 0; i < 10; i++ {
+		_, err := dut.ResolveCheck(ctx, &ResolveCheckRequest{
+			StoreID:              "12",
+			AuthorizationModelID: "33",
+			TupleKey:             tuple.NewTupleKey(fmt.Sprintf("document:%d", i), "reader", "user:XYZ"),
+			RequestMetadata:      NewCheckRequestMetadata(),
+		})
+		require.NoError(t, err)
+	}
+
+	// the budget holds 3 bare responses
+	require.Eventually(t, func() bool {
+		stats := dut.Stats()
+		return stats.Entries <= 3 && stats.Evictions >= 7
+	}, time.Second, 10*time.Millisecond)
+}
+
+// cachedValue returns the value that the resolver cached under the key.
+func cachedValue(t *testing.T, c *CachedCheckResolver, key string) any {
+	value, err := c.cache.Get(context.Background(), key)
 	require.NoError(t, err)
+	return value
 }
 
-func TestResolveCheckLastChangelogRecent(t *testing.T) {
-	ctrl := gomock.NewController(t)
-	defer ctrl.Finish()
+// encodingCheckCache is a storage.CheckCache that keeps the values encoded with gob, as a cache backed by an
+// external store would, and that fails every operation while failing is set.
+type encodingCheckCache struct {
+	mu      sync.Mutex
+	entries map[string][]byte
+	failing atomic.Bool
+}
+
+var errCheckCacheUnavailable = errors.New("check cache unavailable")
+
+func (c *encodingCheckCache) Get(_ context.Context, key string) (any, error) {
+	if c.failing.Load() {
+		return nil, errCheckCacheUnavailable
+	}
+
+	c.mu.Lock()
+	data, ok := c.entries[key]
+	c.mu.Unlock()
+	if !ok {
+		return nil, nil
+	}
+
+	var value any
+	if err := gob.NewDecoder(bytes.NewReader(data)).Decode(&value); err != nil {
+		return nil, err
+	}
+	return value, nil
+}
+
+func (c *encodingCheckCache) Set(_ context.Context, key string, value any, _ time.Duration) error {
+	if c.failing.Load() {
+		return errCheckCacheUnavailable
+	}
+
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(&value); err != nil {
+		return err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.entries == nil {
+		c.entries = map[string][]byte{}
+	}
+	c.entries[key] = buf.Bytes()
+	return nil
+}
+
+func (c *encodingCheckCache) Delete(_ context.Context, key string) error {
+	if c.failing.Load() {
+		return errCheckCacheUnavailable
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.entries, key)
+	return nil
+}
+
+func TestResolveCheckWithCheckCache(t *testing.T) {
+	t.Cleanup(func() {
+		goleak.VerifyNone(t)
+	})
 
 	ctx := context.Background()
 
-	req := &ResolveCheckRequest{
-		StoreID:              "12",
-		AuthorizationModelID: "33",
-		TupleKey: &openfgav1.TupleKey{
-			Object:   "document:abc",
-			Relation: "reader",
-			User:     "user:XYZ",
-		},
-		RequestMetadata:           NewCheckRequestMetadata(),
-		LastCacheInvalidationTime: time.Now().Add(5 * time.Minute),
+	setup := func(t *testing.T) (*CachedCheckResolver, *encodingCheckCache, *atomic.Int32) {
+		ctrl := gomock.NewController(t)
+		t.Cleanup(ctrl.Finish)
+
+		cache := &encodingCheckCache{}
+		dut, err := NewCachedCheckResolver(WithCheckCache(cache))
+		require.NoError(t, err)
+		t.Cleanup(dut.Close)
+
+		var calls atomic.Int32
+		mockResolver := NewMockCheckResolver(ctrl)
+		mockResolver.EXPECT().ResolveCheck(gomock.Any(), gomock.Any()).AnyTimes().DoAndReturn(
+			func(context.Context, *ResolveCheckRequest) (*ResolveCheckResponse, error) {
+				calls.Add(1)
+				return &ResolveCheckResponse{Allowed: true}, nil
+			})
+		dut.SetDelegate(mockResolver)
+
+		return dut, cache, &calls
 	}
 
-	result := &ResolveCheckResponse{Allowed: true}
-	initialMockResolver := NewMockCheckResolver(ctrl)
-	initialMockResolver.EXPECT().ResolveCheck(gomock.Any(), req).Times(2).Return(result, nil)
+	newRequest := func(t *testing.T) *ResolveCheckRequest {
+		req, err := NewResolveCheckRequest(ResolveCheckRequestParams{
+			StoreID:              "12",
+			AuthorizationModelID: "33",
+			TupleKey:             tuple.NewTupleKey("document:abc", "reader", "user:XYZ"),
+		})
+		require.NoError(t, err)
+		return req
+	}
 
-	// expect first call to result in actual resolve call
-	dut, err := NewCachedCheckResolver(WithCacheTTL(1 * time.Hour))
+	check := func(t *testing.T, dut *CachedCheckResolver) {
+		resp, err := dut.ResolveCheck(ctx, newRequest(t))
+		require.NoError(t, err)
+		require.True(t, resp.GetAllowed())
+	}
+
+	t.Run("serves_the_decoded_entries", func(t *testing.T) {
+		dut, cache, calls := setup(t)
+
+		check(t, dut)
+		require.Len(t, cache.entries, 1)
+
+		// the key format is the one of the in-memory cache
+		require.Contains(t, cache.entries, BuildCacheKey(*newRequest(t)))
+
+		check(t, dut)
+		require.Equal(t, int32(1), calls.Load())
+		require.Equal(t, uint64(1), dut.Stats().Hits)
+	})
+
+	t.Run("errors_are_cache_misses", func(t *testing.T) {
+		dut, cache, calls := setup(t)
+		cache.failing.Store(true)
+
+		check(t, dut)
+		check(t, dut)
+		require.Equal(t, int32(2), calls.Load())
+		require.Equal(t, uint64(0), dut.Stats().Hits)
+
+		dut.Invalidate(context.Background(), newRequest(t))
+
+		// the cache recovers
+		cache.failing.Store(false)
+		check(t, dut)
+		check(t, dut)
+		require.Equal(t, int32(3), calls.Load())
+	})
+}
+
+func TestCachedCheckResolverInvalidate(t *testing.T) {
+	t.Cleanup(func() {
+		goleak.VerifyNone(t)
+	})
+
+	ctx := context.Background()
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	dut, err := NewCachedCheckResolver(WithCacheTTL(time.Hour))
 	require.NoError(t, err)
-	defer dut.Close()
+	t.Cleanup(dut.Close)
 
-	dut.SetDelegate(initialMockResolver)
+	calls := map[string]int{}
+	var mu sync.Mutex
+	mockResolver := NewMockCheckResolver(ctrl)
+	mockResolver.EXPECT().ResolveCheck(gomock.Any(), gomock.Any()).AnyTimes().DoAndReturn(
+		func(_ context.Context, req *ResolveCheckRequest) (*ResolveCheckResponse, error) {
+			mu.Lock()
+			defer mu.Unlock()
+			calls[req.GetStoreID()+"/"+req.GetTupleKey().GetObject()]++
+			return &ResolveCheckResponse{Allowed: true}, nil
+		})
+	dut.SetDelegate(mockResolver)
 
-	actualResult, err := dut.ResolveCheck(ctx, req)
-	require.Equal(t, result.Allowed, actualResult.Allowed)
+	newRequest := func(storeID, object string) *ResolveCheckRequest {
+		req, err := NewResolveCheckRequest(ResolveCheckRequestParams{
+			StoreID:              storeID,
+			AuthorizationModelID: "33",
+			TupleKey:             tuple.NewTupleKey(object, "reader", "user:XYZ"),
+		})
+		require.NoError(t, err)
+		return req
+	}
+	requests := []*ResolveCheckRequest{
+		newRequest("store1", "document:1"),
+		newRequest("store1", "document:2"),
+		newRequest("store2", "document:1"),
+	}
+	resolveAll := func(t *testing.T) {
+		for _, req := range requests {
+			_, err := dut.ResolveCheck(ctx, req)
+			require.NoError(t, err)
+		}
+	}
+
+	resolveAll(t)
+	resolveAll(t)
+	require.Equal(t, map[string]int{"store1/document:1": 1, "store1/document:2": 1, "store2/document:1": 1}, calls)
+
+	dut.Invalidate(context.Background(), newRequest("store1", "document:1"))
+	resolveAll(t)
+	require.Equal(t, map[string]int{"store1/document:1": 2, "store1/document:2": 1, "store2/document:1": 1}, calls)
+
+	dut.InvalidateStore(context.Background(), "store1")
+	resolveAll(t)
+	require.Equal(t, map[string]int{"store1/document:1": 3, "store1/document:2": 2, "store2/document:1": 1}, calls)
+
+	// invalidating a store that has nothing cached is a noop
+	dut.InvalidateStore(context.Background(), "store3")
+	resolveAll(t)
+	require.Equal(t, map[string]int{"store1/document:1": 3, "store1/document:2": 2, "store2/document:1": 1}, calls)
+}
+
+func TestCacheKeyIndex(t *testing.T) {
+	now := time.Now()
+
+	// indexed returns the keys of the index by store
+	indexed := func(index *cacheKeyIndex) map[string][]string {
+		byStore := map[string][]string{}
+		for n := range index.shards {
+			for storeID, keys := range index.shards[n].byStore {
+				for cacheKey := range keys {
+					byStore[storeID] = append(byStore[storeID], cacheKey)
+				}
+			}
+		}
+		return byStore
+	}
+
+	var index cacheKeyIndex
+	index.add("store1", "a", now.Add(time.Minute), now)
+	index.add("store1", "b", now.Add(time.Hour), now)
+	index.add("store2", "c", now.Add(time.Minute), now)
+
+	index.remove("b")
+	require.Equal(t, map[string][]string{"store1": {"a"}, "store2": {"c"}}, indexed(&index))
+
+	// the key of an evicted entry is only removed if it wasn't cached again since
+	index.removeEvicted("a", now.Add(-time.Second))
+	require.Equal(t, map[string][]string{"store1": {"a"}, "store2": {"c"}}, indexed(&index))
+	index.removeEvicted("a", now)
+	require.Equal(t, map[string][]string{"store2": {"c"}}, indexed(&index))
+
+	// the keys of the entries that expired are pruned
+	later := now.Add(2 * time.Minute)
+	index.add("store1", "c", later.Add(time.Minute), later)
+	index.add("store1", "d", later.Add(time.Minute), later)
+	for n := range index.shards {
+		index.shards[n].mu.Lock()
+		index.shards[n].prune(later)
+		index.shards[n].mu.Unlock()
+	}
+	require.Len(t, indexed(&index), 1)
+	require.ElementsMatch(t, []string{"c", "d"}, indexed(&index)["store1"])
+
+	require.ElementsMatch(t, []string{"c", "d"}, index.removeStore("store1"))
+	require.Empty(t, index.removeStore("store1"))
+	require.Empty(t, indexed(&index))
+}
+
+func TestCachedCheckResolverOnCacheEviction(t *testing.T) {
+	t.Cleanup(func() {
+		goleak.VerifyNone(t)
+	})
+
+	ctx := context.Background()
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	var dut *CachedCheckResolver
+	cache, err := storage.NewInMemoryLRUCache([]storage.InMemoryLRUCacheOpt[any]{
+		storage.WithEvictionCallback(func(key string, value any, _ storage.EvictReason) {
+			dut.OnCacheEviction(key, value)
+		}),
+	}...)
 	require.NoError(t, err)
+	t.Cleanup(cache.Stop)
 
-	actualResult, err = dut.ResolveCheck(ctx, req)
-	require.Equal(t, result.Allowed, actualResult.Allowed)
+	dut, err = NewCachedCheckResolver(WithExistingCache(cache), WithCacheTTL(time.Hour))
 	require.NoError(t, err)
+	t.Cleanup(dut.Close)
+
+	mockResolver := NewMockCheckResolver(ctrl)
+	mockResolver.EXPECT().ResolveCheck(gomock.Any(), gomock.Any()).Return(&ResolveCheckResponse{Allowed: true}, nil)
+	dut.SetDelegate(mockResolver)
+
+	req, err := NewResolveCheckRequest(ResolveCheckRequestParams{
+		StoreID:              "store1",
+		AuthorizationModelID: "33",
+		TupleKey:             tuple.NewTupleKey("document:1", "reader", "user:XYZ"),
+	})
+	require.NoError(t, err)
+	_, err = dut.ResolveCheck(ctx, req)
+	require.NoError(t, err)
+
+	cacheKey := BuildCacheKeyWithHasher(*req, "", newDefaultCacheKeyHasher)
+	cache.Delete(cacheKey)
+
+	// the key leaves the index once the cache reports the removal of its entry
+	require.Eventually(t, func() bool {
+		shard := dut.storeKeys.shard(cacheKey)
+		shard.mu.Lock()
+		defer shard.mu.Unlock()
+		_, ok := shard.keys[cacheKey]
+		return !ok
+	}, time.Second, time.Millisecond)
 }
 
 func TestCachedCheckResolver_FieldsInResponse(t *testing.T) {
@@ -661,4 +2027,238 @@ func TestBuildCacheKey(t *testing.T) {
 
 	result := BuildCacheKey(*req)
 	require.NotEmpty(t, result)
+
+	t.Run("namespace", func(t *testing.T) {
+		require.Equal(t, result, BuildCacheKeyWithNamespace(*req, ""))
+		require.Equal(t, strconv.FormatUint(xxhash.Sum64String("document:abc#reader@user:XYZ"+req.GetInvariantCacheKey()), 10), result)
+
+		tenantA := BuildCacheKeyWithNamespace(*req, "tenant-a")
+		tenantB := BuildCacheKeyWithNamespace(*req, "tenant-b")
+		require.NotEqual(t, result, tenantA)
+		require.NotEqual(t, tenantA, tenantB)
+		require.Equal(t, tenantA, BuildCacheKeyWithNamespace(*req, "tenant-a"))
+	})
+
+	t.Run("namespaced_requests_do_not_share_entries", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+
+		dut, err := NewCachedCheckResolver()
+		require.NoError(t, err)
+		defer dut.Close()
+
+		mockResolver := NewMockCheckResolver(ctrl)
+		gomock.InOrder(
+			mockResolver.EXPECT().ResolveCheck(gomock.Any(), gomock.Any()).Return(&ResolveCheckResponse{Allowed: true}, nil),
+			mockResolver.EXPECT().ResolveCheck(gomock.Any(), gomock.Any()).Return(&ResolveCheckResponse{Allowed: false}, nil),
+		)
+		dut.SetDelegate(mockResolver)
+
+		for _, expected := range []struct {
+			namespace string
+			allowed   bool
+		}{{"tenant-a", true}, {"tenant-b", false}, {"tenant-a", true}, {"tenant-b", false}} {
+			namespaced := req.clone()
+			namespaced.CacheNamespace = expected.namespace
+			resp, err := dut.ResolveCheck(context.Background(), namespaced)
+			require.NoError(t, err)
+			require.Equal(t, expected.allowed, resp.GetAllowed())
+		}
+	})
+
+	t.Run("hasher", func(t *testing.T) {
+		require.Equal(t, result, BuildCacheKeyWithHasher(*req, "", newDefaultCacheKeyHasher))
+		require.Equal(t, BuildCacheKeyWithNamespace(*req, "tenant-a"), BuildCacheKeyWithHasher(*req, "tenant-a", newDefaultCacheKeyHasher))
+
+		// the wider digests are hex encoded, the hashed content is the same
+		expected := fnv.New128a()
+		_, _ = expected.Write([]byte("tenant-a document:abc#reader@user:XYZ" + req.GetInvariantCacheKey()))
+		require.Equal(t, hex.EncodeToString(expected.Sum(nil)), BuildCacheKeyWithHasher(*req, "tenant-a", newFNV128aHasher))
+	})
+
+	t.Run("resolver_with_hasher", func(t *testing.T) {
+		_, err := NewCachedCheckResolver(WithCacheKeyHasher(nil))
+		require.ErrorContains(t, err, "cache key hasher must not be nil")
+
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+
+		dut, err := NewCachedCheckResolver(WithCacheKeyHasher(newFNV128aHasher))
+		require.NoError(t, err)
+		defer dut.Close()
+
+		mockResolver := NewMockCheckResolver(ctrl)
+		mockResolver.EXPECT().ResolveCheck(gomock.Any(), gomock.Any()).Times(1).Return(&ResolveCheckResponse{Allowed: true}, nil)
+		dut.SetDelegate(mockResolver)
+
+		for range 2 {
+			resp, err := dut.ResolveCheck(context.Background(), req)
+			require.NoError(t, err)
+			require.True(t, resp.GetAllowed())
+		}
+
+		require.NotNil(t, cachedValue(t, dut, BuildCacheKeyWithHasher(*req, "", newFNV128aHasher)))
+		require.Nil(t, cachedValue(t, dut, result))
+
+		dut.Invalidate(context.Background(), req)
+		require.Nil(t, cachedValue(t, dut, BuildCacheKeyWithHasher(*req, "", newFNV128aHasher)))
+	})
+}
+
+// fnv128aHasher is a 128-bit hash.Hash64, whose Sum64 is the first half of its digest.
+type fnv128aHasher struct {
+	hash.Hash
+}
+
+func newFNV128aHasher() hash.Hash64 {
+	return fnv128aHasher{fnv.New128a()}
+}
+
+func (h fnv128aHasher) Sum64() uint64 {
+	return binary.BigEndian.Uint64(h.Sum(nil))
+}
+
+func TestResolveCheckUncachedObjectTypes(t *testing.T) {
+	t.Cleanup(func() {
+		goleak.VerifyNone(t)
+	})
+
+	ctrl := gomock.NewController(t)
+
+	dut, err := NewCachedCheckResolver(WithUncachedObjectTypes("session"))
+	require.NoError(t, err)
+	t.Cleanup(dut.Close)
+
+	mockResolver := NewMockCheckResolver(ctrl)
+	dut.SetDelegate(mockResolver)
+
+	resolve := func(object string) {
+		req, err := NewResolveCheckRequest(ResolveCheckRequestParams{
+			StoreID:              "12",
+			AuthorizationModelID: "33",
+			TupleKey:             tuple.NewTupleKey(object, "owner", "user:XYZ"),
+		})
+		require.NoError(t, err)
+
+		resp, err := dut.ResolveCheck(context.Background(), req)
+		require.NoError(t, err)
+		require.True(t, resp.GetAllowed())
+	}
+
+	// the session is resolved each time, the document only once
+	mockResolver.EXPECT().ResolveCheck(gomock.Any(), gomock.Any()).Times(3).Return(&ResolveCheckResponse{Allowed: true}, nil)
+	resolve("session:1")
+	resolve("session:1")
+	resolve("document:1")
+	resolve("document:1")
+
+	require.Equal(t, uint64(2), dut.Stats().Lookups)
+	require.Equal(t, uint64(1), dut.Stats().Hits)
+}
+
+func TestResolveCheckPrimaryReadRelations(t *testing.T) {
+	t.Cleanup(func() {
+		goleak.VerifyNone(t)
+	})
+
+	model := testutils.MustTransformDSLToProtoWithID(`
+		model
+			schema 1.1
+		type user
+		type group
+			relations
+				define member: [user]
+		type folder
+			relations
+				define viewer: [user]
+		type document
+			relations
+				define parent: [folder]
+				define owner: [user]
+				define editor: [user, group#member]
+				define viewer: editor or viewer from parent
+				define reader: owner`)
+	ts, err := typesystem.New(model)
+	require.NoError(t, err)
+
+	tests := []struct {
+		name      string
+		relations []string
+		object    string
+		relation  string
+		cached    bool
+	}{
+		{
+			name:      "primary_read_relation",
+			relations: []string{"document#owner"},
+			object:    "document:1",
+			relation:  "owner",
+		},
+		{
+			name:      "computed_relation",
+			relations: []string{"document#owner"},
+			object:    "document:1",
+			relation:  "reader",
+		},
+		{
+			name:      "directly_related_userset",
+			relations: []string{"group#member"},
+			object:    "document:1",
+			relation:  "viewer",
+		},
+		{
+			name:      "tuple_to_userset",
+			relations: []string{"folder#viewer"},
+			object:    "document:1",
+			relation:  "viewer",
+		},
+		{
+			name:      "unrelated_relation",
+			relations: []string{"document#owner"},
+			object:    "document:1",
+			relation:  "viewer",
+			cached:    true,
+		},
+		{
+			name:      "no_primary_read_relations",
+			relations: nil,
+			object:    "document:1",
+			relation:  "owner",
+			cached:    true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			dut, err := NewCachedCheckResolver(WithCachePrimaryReadRelations(map[string][]string{
+				"12": test.relations,
+			}))
+			require.NoError(t, err)
+			defer dut.Close()
+
+			expectedResolves := 2
+			if test.cached {
+				expectedResolves = 1
+			}
+			mockResolver := NewMockCheckResolver(ctrl)
+			mockResolver.EXPECT().ResolveCheck(gomock.Any(), gomock.Any()).Times(expectedResolves).Return(&ResolveCheckResponse{Allowed: true}, nil)
+			dut.SetDelegate(mockResolver)
+
+			ctx := typesystem.ContextWithTypesystem(context.Background(), ts)
+			for range 2 {
+				req, err := NewResolveCheckRequest(ResolveCheckRequestParams{
+					StoreID:              "12",
+					AuthorizationModelID: "33",
+					TupleKey:             tuple.NewTupleKey(test.object, test.relation, "user:XYZ"),
+				})
+				require.NoError(t, err)
+
+				_, err = dut.ResolveCheck(ctx, req)
+				require.NoError(t, err)
+			}
+		})
+	}
 }
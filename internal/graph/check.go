@@ -6,25 +6,32 @@ import (
 	"fmt"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/emirpasic/gods/sets/hashset"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
 	"github.com/sourcegraph/conc"
 	"github.com/sourcegraph/conc/panics"
 	"go.opentelemetry.io/otel"
 	"go.opentelemetry.io/otel/attribute"
 	"go.opentelemetry.io/otel/trace"
+	"golang.org/x/sync/semaphore"
 
 	openfgav1 "github.com/openfga/api/proto/openfga/v1"
 
+	"github.com/openfga/openfga/internal/build"
 	"github.com/openfga/openfga/internal/checkutil"
 	"github.com/openfga/openfga/internal/concurrency"
+	"github.com/openfga/openfga/internal/condition/eval"
 	openfgaErrors "github.com/openfga/openfga/internal/errors"
 	"github.com/openfga/openfga/internal/planner"
 	"github.com/openfga/openfga/internal/validation"
 	"github.com/openfga/openfga/pkg/logger"
 	serverconfig "github.com/openfga/openfga/pkg/server/config"
 	"github.com/openfga/openfga/pkg/storage"
+	"github.com/openfga/openfga/pkg/storage/storagewrappers"
 	"github.com/openfga/openfga/pkg/telemetry"
 	"github.com/openfga/openfga/pkg/tuple"
 	"github.com/openfga/openfga/pkg/typesystem"
@@ -32,11 +39,21 @@ import (
 
 var tracer = otel.Tracer("internal/graph/check")
 
+var detachedUnionBranchesGauge = promauto.NewGauge(prometheus.GaugeOpts{
+	Namespace: build.ProjectName,
+	Name:      "check_detached_union_branches",
+	Help:      "The number of branches of union relations that timed out and are still resolving, see WithUnionBranchTimeout.",
+})
+
 type setOperatorType int
 
 var (
 	ErrUnknownSetOperator = fmt.Errorf("%w: unexpected set operator type encountered", openfgaErrors.ErrUnknown)
 	ErrPanic              = errors.New("panic captured")
+	// ErrUnionBranchTimeout is returned by a branch of a union that doesn't resolve within the union branch timeout.
+	ErrUnionBranchTimeout = fmt.Errorf("%w: union branch timed out", context.DeadlineExceeded)
+	// ErrInsufficientDeadline is returned instead of resolving a subproblem when the deadline of the request is too near.
+	ErrInsufficientDeadline = fmt.Errorf("%w: insufficient time remaining before the deadline", context.DeadlineExceeded)
 )
 
 const (
@@ -58,6 +75,37 @@ type LocalChecker struct {
 	logger               logger.Logger
 	optimizationsEnabled bool
 	maxResolutionDepth   uint32
+	// the maximum number of rewrites expanded in one path, 0 for no limit
+	maxRewriteDepth    uint32
+	unionBranchTimeout time.Duration
+	// the branches of union relations that timed out and are still resolving, see withBranchTimeout
+	detachedBranches atomic.Int64
+	// the minimum time that must remain before the deadline of the request to resolve a subproblem
+	minRemainingDeadline time.Duration
+	resolutionStrategy   serverconfig.ResolutionStrategy
+	// whether the subtracted operand of a difference is resolved before its base
+	earlyDeny bool
+	// whether the memberships of usersets are always dispatched so they are cached as subproblems
+	usersetCaching bool
+	// whether the subproblems of negated subtrees are memoized within a request
+	negatedSubtreeMemoization bool
+	// map: 'objectType#relation' => oracle that resolves its usersets
+	usersetOracles map[string]UsersetOracle
+	// map: store ID => 'objectType#relation' pairs whose tuples are read from the primary
+	primaryReadRelations map[string][]string
+	// the maximum number of userset tuples expanded for one object and relation, 0 for no limit
+	maxUsersetFanout      uint32
+	usersetFanoutBehavior serverconfig.UsersetFanoutBehavior
+	// the subproblems evaluated concurrently by all the Checks, nil for no limit
+	concurrencyBudget *semaphore.Weighted
+	// the subproblems evaluated concurrently by each Check, 0 for no limit
+	perRequestConcurrencyLimit int
+	// whether the time spent evaluating each rewrite is recorded
+	rewriteLatencyMetrics bool
+	// whether the tuple keys of the subproblems are added to the spans, see checkSpanAttributes
+	highCardinalityTraceAttributes bool
+	// map: model ID => precomputed index of the model
+	modelIndexes map[string]*typesystem.Index
 }
 
 type LocalCheckerOption func(d *LocalChecker)
@@ -93,12 +141,106 @@ func WithMaxResolutionDepth(depth uint32) LocalCheckerOption {
 	}
 }
 
+// WithMaxRewriteDepth bounds the number of rewrites expanded in any one path of a Check. Unlike
+// WithMaxResolutionDepth, which only counts the dispatched subproblems, it also counts the rewrites
+// resolved in place, such as the computed usersets of a chain of relations. A depth of 0 doesn't
+// bound the rewrites.
+func WithMaxRewriteDepth(depth uint32) LocalCheckerOption {
+	return func(d *LocalChecker) {
+		d.maxRewriteDepth = depth
+	}
+}
+
 func WithUpstreamTimeout(timeout time.Duration) LocalCheckerOption {
 	return func(d *LocalChecker) {
 		d.upstreamTimeout = timeout
 	}
 }
 
+// WithUnionBranchTimeout bounds the time spent resolving each branch of a union relation. A branch
+// that doesn't resolve in time is abandoned and resolves to ErrUnionBranchTimeout, while the other
+// branches of the union continue. A timeout of 0 doesn't bound the branches.
+func WithUnionBranchTimeout(timeout time.Duration) LocalCheckerOption {
+	return func(d *LocalChecker) {
+		d.unionBranchTimeout = timeout
+	}
+}
+
+// WithMinRemainingDeadline fails a Check with ErrInsufficientDeadline instead of resolving a subproblem when less than
+// the given duration remains before the deadline of the request, so that no datastore reads are spent on requests
+// that can't complete in time. A duration of 0 resolves subproblems until the deadline is exceeded.
+func WithMinRemainingDeadline(d time.Duration) LocalCheckerOption {
+	return func(c *LocalChecker) {
+		c.minRemainingDeadline = d
+	}
+}
+
+// WithEarlyDeny resolves the subtracted operand of a difference relation, e.g. 'blocked' in 'viewer but not
+// blocked', before its base, and denies the Check without resolving the base if the subtracted operand is
+// allowed. The base is resolved only if the subtracted operand isn't allowed. This fails fast when a denial is
+// likely, at the cost of resolving the operands one after the other instead of concurrently.
+func WithEarlyDeny(enabled bool) LocalCheckerOption {
+	return func(d *LocalChecker) {
+		d.earlyDeny = enabled
+	}
+}
+
+// WithUsersetCaching dispatches the resolution of every userset related to an object, e.g.
+// 'group:eng#member@user:bob' when resolving 'document:1#viewer@user:bob', instead of resolving the
+// memberships within the strategies that don't dispatch them. When a CachedCheckResolver is in the chain of
+// resolvers, each membership is then cached as a subproblem of its own, which is reused by the Checks of all
+// the objects that the userset is related to.
+func WithUsersetCaching(enabled bool) LocalCheckerOption {
+	return func(d *LocalChecker) {
+		d.usersetCaching = enabled
+	}
+}
+
+// WithPrimaryReadRelations reads the tuples of the 'objectType#relation' pairs of a store, e.g. 'document#viewer',
+// with HIGHER_CONSISTENCY, whatever the consistency of the Check. With a datastore that reads from a replica
+// unless higher consistency is requested, the tuples of these relations are read from the primary while the
+// tuples of the other relations are still read from the replica.
+func WithPrimaryReadRelations(relations map[string][]string) LocalCheckerOption {
+	return func(d *LocalChecker) {
+		d.primaryReadRelations = relations
+	}
+}
+
+// WithResolutionStrategy selects how the subproblems of a Check are scheduled. With
+// serverconfig.DepthFirstResolution, the operands of a rewrite and the dispatched subproblems are
+// evaluated one at a time, while serverconfig.BreadthFirstResolution evaluates them concurrently, up
+// to the resolve node breadth limit. The strategy doesn't affect the outcome of a Check.
+func WithResolutionStrategy(strategy serverconfig.ResolutionStrategy) LocalCheckerOption {
+	return func(d *LocalChecker) {
+		d.resolutionStrategy = strategy
+	}
+}
+
+// WithConcurrencyBudget bounds the number of subproblems that all the Checks resolved by the checker evaluate
+// concurrently, on top of the breadth limit of each node. Once the budget is spent, the subproblems of the
+// Checks are evaluated sequentially until a slot is released, rather than queued for one, so that a Check
+// doesn't wait on the others. A budget of 0, the default, doesn't bound them.
+func WithConcurrencyBudget(n int) LocalCheckerOption {
+	return func(d *LocalChecker) {
+		d.concurrencyBudget = nil
+		if n > 0 {
+			d.concurrencyBudget = semaphore.NewWeighted(int64(n))
+		}
+	}
+}
+
+// WithPerRequestConcurrencyLimit bounds the number of subproblems that a single Check evaluates concurrently,
+// so that a Check with a large fan out, e.g. because of a pathological model, can't spend the whole budget set
+// by WithConcurrencyBudget and starve the other Checks. Each subproblem takes a slot of both the budget and the
+// partition of its Check. The root of a Check waits for a slot of its partition, until the deadline of the
+// Check, before spawning a subproblem, while the subproblems that can't acquire a slot for theirs evaluate them
+// sequentially. A limit of 0, the default, doesn't bound them.
+func WithPerRequestConcurrencyLimit(n int) LocalCheckerOption {
+	return func(d *LocalChecker) {
+		d.perRequestConcurrencyLimit = n
+	}
+}
+
 // NewLocalChecker constructs a LocalChecker that can be used to evaluate a Check
 // request locally.
 //
@@ -111,6 +253,10 @@ func NewLocalChecker(opts ...LocalCheckerOption) *LocalChecker {
 		upstreamTimeout:    serverconfig.DefaultRequestTimeout,
 		logger:             logger.NewNoopLogger(),
 		planner:            planner.NewNoopPlanner(),
+		resolutionStrategy: serverconfig.DefaultResolutionStrategy,
+
+		usersetFanoutBehavior:     serverconfig.DefaultUsersetFanoutBehavior,
+		negatedSubtreeMemoization: true,
 	}
 	// by default, a LocalChecker delegates/dispatches subproblems to itself (e.g. local dispatch) unless otherwise configured.
 	checker.delegate = checker
@@ -122,6 +268,14 @@ func NewLocalChecker(opts ...LocalCheckerOption) *LocalChecker {
 	return checker
 }
 
+// breadthLimit returns the number of subproblems of a node that are evaluated concurrently.
+func (c *LocalChecker) breadthLimit() int {
+	if c.resolutionStrategy == serverconfig.DepthFirstResolution {
+		return 1
+	}
+	return c.concurrencyLimit
+}
+
 // SetDelegate sets this LocalChecker's dispatch delegate.
 func (c *LocalChecker) SetDelegate(delegate CheckResolver) {
 	c.delegate = delegate
@@ -147,10 +301,11 @@ type CheckFuncReducer func(ctx context.Context, concurrencyLimit int, handlers .
 // evaluations in flight at any point.
 func resolver(ctx context.Context, concurrencyLimit int, resultChan chan<- checkOutcome, handlers ...CheckHandlerFunc) func() error {
 	limiter := make(chan struct{}, concurrencyLimit)
+	concurrencyLimiter := checkConcurrencyLimiterFromContext(ctx)
 
 	var wg conc.WaitGroup
 
-	checker := func(fn CheckHandlerFunc) {
+	checker := func(ctx context.Context, fn CheckHandlerFunc) {
 		defer func() {
 			<-limiter
 		}()
@@ -182,12 +337,32 @@ func resolver(ctx context.Context, concurrencyLimit int, resultChan chan<- check
 		for _, handler := range handlers {
 			fn := handler // capture loop var
 
+			// the handler waits for a slot of the breadth limit if it's spent, until the deadline of the Check
+			waiting := len(limiter) == cap(limiter)
+
 			select {
 			case limiter <- struct{}{}:
+				if waiting {
+					concurrencyLimiter.throttled(ctx, ResolveNodeBreadthLimitName)
+				}
+				acquired, err := concurrencyLimiter.acquire(ctx)
+				if err != nil {
+					<-limiter
+					break outer
+				}
+				if !acquired {
+					// the concurrency budget of the Check is spent, so the handler is evaluated sequentially
+					checker(ctx, fn)
+					continue
+				}
 				wg.Go(func() {
-					checker(fn)
+					defer concurrencyLimiter.release()
+					checker(contextWithHeldConcurrencySlot(ctx), fn)
 				})
 			case <-ctx.Done():
+				if waiting {
+					concurrencyLimiter.throttled(ctx, ResolveNodeBreadthLimitName)
+				}
 				break outer
 			}
 		}
@@ -224,7 +399,7 @@ func union(ctx context.Context, concurrencyLimit int, handlers ...CheckHandlerFu
 	}()
 
 	var elErr error
-	var cycleDetected bool
+	var cycleDetected, degraded bool
 	for i := 0; i < len(handlers); i++ {
 		select {
 		case result := <-resultChan:
@@ -237,6 +412,11 @@ func union(ctx context.Context, concurrencyLimit int, handlers ...CheckHandlerFu
 				cycleDetected = true
 			}
 
+			// a denial depends on every operand, an allowed outcome only on its own
+			if result.resp.GetDegraded() {
+				degraded = true
+			}
+
 			if result.resp.GetAllowed() {
 				resp = result.resp
 				return
@@ -256,12 +436,67 @@ func union(ctx context.Context, concurrencyLimit int, handlers ...CheckHandlerFu
 		Allowed: false,
 		ResolutionMetadata: ResolveCheckResponseMetadata{
 			CycleDetected: cycleDetected,
+			Degraded:      degraded,
 		},
 	}
 
 	return
 }
 
+// withBranchTimeout returns a CheckHandlerFunc that resolves to ErrUnionBranchTimeout if fn doesn't
+// resolve within the timeout. The context of fn is cancelled once the timeout elapses, but fn is not
+// waited for, so that a branch that doesn't observe the cancellation cannot hold up the reducer. Such a
+// branch is detached until it resolves, and counted by the check_detached_union_branches gauge.
+func (c *LocalChecker) withBranchTimeout(fn CheckHandlerFunc, timeout time.Duration) CheckHandlerFunc {
+	return func(ctx context.Context) (*ResolveCheckResponse, error) {
+		ctx, cancel := context.WithTimeoutCause(ctx, timeout, ErrUnionBranchTimeout)
+		defer cancel()
+
+		const (
+			attached int32 = iota
+			detached
+			resolved
+		)
+		var state atomic.Int32
+
+		outcome := make(chan checkOutcome, 1)
+		go func() {
+			defer func() {
+				if state.Swap(resolved) == detached {
+					c.detachedBranches.Add(-1)
+					detachedUnionBranchesGauge.Dec()
+				}
+			}()
+
+			recoveredError := panics.Try(func() {
+				resp, err := fn(ctx)
+				outcome <- checkOutcome{resp, err}
+			})
+			if recoveredError != nil {
+				outcome <- checkOutcome{nil, fmt.Errorf("%w: %s", ErrPanic, recoveredError.AsError())}
+			}
+		}()
+
+		select {
+		case res := <-outcome:
+			if res.err != nil && errors.Is(context.Cause(ctx), ErrUnionBranchTimeout) {
+				return nil, ErrUnionBranchTimeout
+			}
+			return res.resp, res.err
+		case <-ctx.Done():
+			// counted before the branch is detached, so that the count of a branch that resolves meanwhile
+			// doesn't go below zero
+			c.detachedBranches.Add(1)
+			detachedUnionBranchesGauge.Inc()
+			if !state.CompareAndSwap(attached, detached) {
+				c.detachedBranches.Add(-1)
+				detachedUnionBranchesGauge.Dec()
+			}
+			return nil, context.Cause(ctx)
+		}
+	}
+}
+
 // intersection implements a CheckFuncReducer that requires all of the provided CheckHandlerFunc to resolve
 // to an allowed outcome. The first falsey or erroneous outcome causes premature termination of the reducer.
 func intersection(ctx context.Context, concurrencyLimit int, handlers ...CheckHandlerFunc) (resp *ResolveCheckResponse, err error) {
@@ -288,6 +523,8 @@ func intersection(ctx context.Context, concurrencyLimit int, handlers ...CheckHa
 	}()
 
 	var elErr error
+	var score float64
+	var scored, degraded bool
 	for i := 0; i < len(handlers); i++ {
 		select {
 		case result := <-resultChan:
@@ -301,6 +538,16 @@ func intersection(ctx context.Context, concurrencyLimit int, handlers ...CheckHa
 				resp = result.resp
 				return
 			}
+
+			// an intersection is only as strong as its weakest operand
+			if !scored || result.resp.GetScore() < score {
+				score = result.resp.GetScore()
+				scored = true
+			}
+
+			if result.resp.GetDegraded() {
+				degraded = true
+			}
 		case <-ctx.Done():
 			err = ctx.Err()
 			return
@@ -315,6 +562,10 @@ func intersection(ctx context.Context, concurrencyLimit int, handlers ...CheckHa
 
 	resp = &ResolveCheckResponse{
 		Allowed: true,
+		Score:   score,
+		ResolutionMetadata: ResolveCheckResponseMetadata{
+			Degraded: degraded,
+		},
 	}
 
 	return
@@ -351,12 +602,12 @@ func exclusion(ctx context.Context, concurrencyLimit int, handlers ...CheckHandl
 	limiter <- struct{}{}
 	wg.Add(1)
 	go func() {
-		recoveredError := panics.Try(func() {
-			defer func() {
-				wg.Done()
-				<-limiter
-			}()
+		defer func() {
+			wg.Done()
+			<-limiter
+		}()
 
+		recoveredError := panics.Try(func() {
 			resp, err := baseHandler(ctx)
 			baseChan <- checkOutcome{resp, err}
 		})
@@ -369,12 +620,12 @@ func exclusion(ctx context.Context, concurrencyLimit int, handlers ...CheckHandl
 	limiter <- struct{}{}
 	wg.Add(1)
 	go func() {
-		recoveredError := panics.Try(func() {
-			defer func() {
-				wg.Done()
-				<-limiter
-			}()
+		defer func() {
+			wg.Done()
+			<-limiter
+		}()
 
+		recoveredError := panics.Try(func() {
 			resp, err := subHandler(ctx)
 			subChan <- checkOutcome{resp, err}
 		})
@@ -383,12 +634,10 @@ func exclusion(ctx context.Context, concurrencyLimit int, handlers ...CheckHandl
 		}
 	}()
 
-	response := &ResolveCheckResponse{
-		Allowed: false,
-	}
-
 	var baseErr error
 	var subErr error
+	var baseScore float64
+	var degraded bool
 
 	for i := 0; i < len(handlers); i++ {
 		select {
@@ -409,7 +658,12 @@ func exclusion(ctx context.Context, concurrencyLimit int, handlers ...CheckHandl
 			}
 
 			if !baseResult.resp.GetAllowed() {
-				return response, nil
+				return degradedDenial(baseResult.resp), nil
+			}
+
+			baseScore = baseResult.resp.GetScore()
+			if baseResult.resp.GetDegraded() {
+				degraded = true
 			}
 
 		case subResult := <-subChan:
@@ -429,7 +683,11 @@ func exclusion(ctx context.Context, concurrencyLimit int, handlers ...CheckHandl
 			}
 
 			if subResult.resp.GetAllowed() {
-				return response, nil
+				return degradedDenial(subResult.resp), nil
+			}
+
+			if subResult.resp.GetDegraded() {
+				degraded = true
 			}
 		case <-ctx.Done():
 			return nil, ctx.Err()
@@ -447,9 +705,93 @@ func exclusion(ctx context.Context, concurrencyLimit int, handlers ...CheckHandl
 
 	return &ResolveCheckResponse{
 		Allowed: true,
+		Score:   baseScore,
+		ResolutionMetadata: ResolveCheckResponseMetadata{
+			Degraded: degraded,
+		},
 	}, nil
 }
 
+// earlyDenyExclusion implements a CheckFuncReducer with the same outcomes as exclusion, but resolves the
+// 'sub' CheckHandlerFunc before the 'base' CheckHandlerFunc. If 'sub' resolves to an allowed outcome, the
+// 'base' CheckHandlerFunc is never resolved.
+func earlyDenyExclusion(ctx context.Context, _ int, handlers ...CheckHandlerFunc) (*ResolveCheckResponse, error) {
+	if len(handlers) != 2 {
+		return nil, fmt.Errorf("%w, expected two rewrite operands for exclusion operator, but got '%d'", openfgaErrors.ErrUnknown, len(handlers))
+	}
+
+	span := trace.SpanFromContext(ctx)
+
+	cycleResponse := &ResolveCheckResponse{
+		Allowed: false,
+		ResolutionMetadata: ResolveCheckResponseMetadata{
+			CycleDetected: true,
+		},
+	}
+
+	subResp, subErr := resolveHandler(ctx, handlers[1])
+	if subErr != nil {
+		telemetry.TraceError(span, subErr)
+	} else {
+		if subResp.GetCycleDetected() {
+			return cycleResponse, nil
+		}
+
+		if subResp.GetAllowed() {
+			span.SetAttributes(attribute.Bool("early_deny", true))
+			return degradedDenial(subResp), nil
+		}
+	}
+
+	baseResp, baseErr := resolveHandler(ctx, handlers[0])
+	if baseErr != nil {
+		telemetry.TraceError(span, baseErr)
+		return nil, errors.Join(baseErr, subErr)
+	}
+
+	if baseResp.GetCycleDetected() {
+		return cycleResponse, nil
+	}
+
+	if !baseResp.GetAllowed() {
+		return degradedDenial(baseResp), nil
+	}
+
+	if subErr != nil {
+		return nil, subErr
+	}
+
+	return &ResolveCheckResponse{
+		Allowed: true,
+		Score:   baseResp.GetScore(),
+		ResolutionMetadata: ResolveCheckResponseMetadata{
+			Degraded: baseResp.GetDegraded() || subResp.GetDegraded(),
+		},
+	}, nil
+}
+
+// degradedDenial returns the denial of an exclusion that was decided by the operand resp alone, which is
+// degraded if resp is.
+func degradedDenial(resp *ResolveCheckResponse) *ResolveCheckResponse {
+	return &ResolveCheckResponse{
+		Allowed: false,
+		ResolutionMetadata: ResolveCheckResponseMetadata{
+			Degraded: resp.GetDegraded(),
+		},
+	}
+}
+
+// resolveHandler resolves the CheckHandlerFunc, turning a panic into an error.
+func resolveHandler(ctx context.Context, handler CheckHandlerFunc) (resp *ResolveCheckResponse, err error) {
+	recoveredError := panics.Try(func() {
+		resp, err = handler(ctx)
+	})
+	if recoveredError != nil {
+		return nil, fmt.Errorf("%w: %s", ErrPanic, recoveredError.AsError())
+	}
+	return resp, err
+}
+
 // Close is a noop.
 func (c *LocalChecker) Close() {
 }
@@ -458,16 +800,47 @@ func (c *LocalChecker) Close() {
 // to the CheckResolver this LocalChecker was constructed with.
 func (c *LocalChecker) dispatch(_ context.Context, parentReq *ResolveCheckRequest, tk *openfgav1.TupleKey) CheckHandlerFunc {
 	return func(ctx context.Context) (*ResolveCheckResponse, error) {
+		key := tuple.TupleKeyToString(tk)
+		memo := c.negatedSubtreeMemoFor(ctx, parentReq, key)
+		if memo != nil {
+			if resp, ok := memo.load(key); ok {
+				resp = tracedFromCache(parentReq, tk, resp)
+				checkTraceFromContext(ctx).addChild(resp.GetTrace())
+				return decayScore(parentReq, resp), nil
+			}
+		}
+
+		var sessionKey string
+		session := checkSessionFor(parentReq, key)
+		if session != nil {
+			sessionKey = session.cacheKey(parentReq, key)
+			if resp, ok := session.load(sessionKey); ok {
+				resp = tracedFromCache(parentReq, tk, resp)
+				checkTraceFromContext(ctx).addChild(resp.GetTrace())
+				return decayScore(parentReq, resp), nil
+			}
+		}
+
 		parentReq.GetRequestMetadata().DispatchCounter.Add(1)
 		childRequest := parentReq.clone()
 		childRequest.TupleKey = tk
 		childRequest.GetRequestMetadata().Depth++
 
+		endChild := startRewriteChild(ctx)
 		resp, err := c.delegate.ResolveCheck(ctx, childRequest)
+		endChild()
 		if err != nil {
 			return nil, err
 		}
-		return resp, nil
+		checkTraceFromContext(ctx).addChild(resp.GetTrace())
+
+		if memo != nil {
+			memo.store(key, resp)
+		}
+		if session != nil && !parentReq.GetRequestMetadata().usersetOracleConsulted() {
+			session.store(sessionKey, resp)
+		}
+		return decayScore(parentReq, resp), nil
 	}
 }
 
@@ -477,15 +850,46 @@ var _ CheckResolver = (*LocalChecker)(nil)
 func (c *LocalChecker) ResolveCheck(
 	ctx context.Context,
 	req *ResolveCheckRequest,
+) (*ResolveCheckResponse, error) {
+	ctx = c.withConcurrencyLimiter(ctx, req)
+
+	if eval.EvaluationCacheFromContext(ctx) == nil {
+		// the results of the conditions depend on the context of the Check, so they are memoized for the Check only
+		cache := eval.NewEvaluationCache()
+		ctx = eval.ContextWithEvaluationCache(ctx, cache)
+		defer func() {
+			conditionEvaluationCacheHitCounter.Add(float64(cache.Hits()))
+		}()
+	}
+
+	resp, err := c.resolveCheck(ctx, req)
+	if err != nil || !req.GetTrace() || resp.GetTrace() != nil {
+		return resp, err
+	}
+
+	// the request was answered without evaluating the rewrite of the relation, e.g. because of a cycle
+	traced := resp.clone()
+	traced.Trace = newCheckTraceLeaf(req.GetTupleKey(), resp.GetAllowed(), false)
+	return traced, nil
+}
+
+func (c *LocalChecker) resolveCheck(
+	ctx context.Context,
+	req *ResolveCheckRequest,
 ) (*ResolveCheckResponse, error) {
 	if ctx.Err() != nil {
 		return nil, ctx.Err()
 	}
 
+	// dispatched subproblems are resolved through ResolveCheck, so this guards each of them before their reads
+	if c.minRemainingDeadline > 0 {
+		if deadline, ok := ctx.Deadline(); ok && time.Until(deadline) < c.minRemainingDeadline {
+			return nil, ErrInsufficientDeadline
+		}
+	}
+
 	ctx, span := tracer.Start(ctx, "ResolveCheck", trace.WithAttributes(
-		attribute.String("store_id", req.GetStoreID()),
-		attribute.String("resolver_type", "LocalChecker"),
-		attribute.String("tuple_key", tuple.TupleKeyWithConditionToString(req.GetTupleKey())),
+		append(checkSpanAttributes(req, c.highCardinalityTraceAttributes), attribute.String("resolver_type", "LocalChecker"))...,
 	))
 	defer span.End()
 
@@ -509,27 +913,50 @@ func (c *LocalChecker) ResolveCheck(
 	relation := tupleKey.GetRelation()
 
 	if tuple.IsSelfDefining(req.GetTupleKey()) {
-		return &ResolveCheckResponse{
-			Allowed: true,
-		}, nil
+		return grantedResponse(req, directScore), nil
 	}
 
 	typesys, ok := typesystem.TypesystemFromContext(ctx)
 	if !ok {
 		return nil, fmt.Errorf("%w: typesystem missing in context", openfgaErrors.ErrUnknown)
 	}
-	_, ok = storage.RelationshipTupleReaderFromContext(ctx)
+	ds, ok := storage.RelationshipTupleReaderFromContext(ctx)
 	if !ok {
 		return nil, fmt.Errorf("%w: relationship tuple reader datastore missing in context", openfgaErrors.ErrUnknown)
 	}
 
+	// the reader is wrapped once, dispatched subproblems inherit it
+	if _, traced := ds.(*checkTraceTupleReader); !traced {
+		if relations := c.primaryReadRelations[req.GetStoreID()]; len(relations) > 0 {
+			if _, wrapped := ds.(*storagewrappers.PrimaryReadTupleReader); !wrapped {
+				ds = storagewrappers.NewPrimaryReadTupleReader(ds, relations)
+				ctx = storage.ContextWithRelationshipTupleReader(ctx, ds)
+			}
+		}
+
+		if req.GetTrace() {
+			ctx = storage.ContextWithRelationshipTupleReader(ctx, &checkTraceTupleReader{RelationshipTupleReader: ds})
+		}
+	}
+
 	objectType, _ := tuple.SplitObject(object)
 	rel, err := typesys.GetRelation(objectType, relation)
 	if err != nil {
 		return nil, fmt.Errorf("relation '%s' undefined for object type '%s'", relation, objectType)
 	}
+	span.SetAttributes(attribute.String("operation", string(checkTraceOperation(rel.GetRewrite()))))
+
+	if req.IsFactAssumed(tupleKey) {
+		return grantedResponse(req, directScore), nil
+	}
+
+	if req.IsRelationExcluded(objectType, relation) {
+		return &ResolveCheckResponse{
+			Allowed: false,
+		}, nil
+	}
 
-	hasPath, err := typesys.PathExists(tupleKey.GetUser(), relation, objectType)
+	hasPath, err := c.pathExists(typesys, tupleKey.GetUser(), relation, objectType)
 	if err != nil {
 		return nil, err
 	}
@@ -539,6 +966,15 @@ func (c *LocalChecker) ResolveCheck(
 		}, nil
 	}
 
+	if oracle, ok := c.usersetOracles[tuple.ToObjectRelationString(objectType, relation)]; ok {
+		resp, err := checkUsersetOracle(ctx, req, oracle)
+		if err != nil {
+			telemetry.TraceError(span, err)
+			return nil, err
+		}
+		return resp, nil
+	}
+
 	resp, err := c.CheckRewrite(ctx, req, rel.GetRewrite())(ctx)
 	if err != nil {
 		telemetry.TraceError(span, err)
@@ -616,8 +1052,7 @@ func (c *LocalChecker) checkPublicAssignable(ctx context.Context, req *ResolveCh
 		}
 		// when we get to here, it means there is public wild card assigned
 		span.SetAttributes(attribute.Bool("allowed", true))
-		response.Allowed = true
-		return response, nil
+		return grantedResponse(req, wildcardScore), nil
 	}
 }
 
@@ -628,7 +1063,7 @@ func (c *LocalChecker) checkDirectUserTuple(ctx context.Context, req *ResolveChe
 
 	return func(ctx context.Context) (*ResolveCheckResponse, error) {
 		ctx, span := tracer.Start(ctx, "checkDirectUserTuple",
-			trace.WithAttributes(attribute.String("tuple_key", tuple.TupleKeyWithConditionToString(reqTupleKey))))
+			trace.WithAttributes(checkSpanAttributes(req, c.highCardinalityTraceAttributes)...))
 		defer span.End()
 
 		response := &ResolveCheckResponse{
@@ -666,7 +1101,7 @@ func (c *LocalChecker) checkDirectUserTuple(ctx context.Context, req *ResolveChe
 		}
 		if conditionMet {
 			span.SetAttributes(attribute.Bool("allowed", true))
-			response.Allowed = true
+			return grantedResponse(req, directScore), nil
 		}
 		return response, nil
 	}
@@ -722,6 +1157,23 @@ func (c *LocalChecker) profiledCheckHandler(keyPlan *planner.KeyPlan, strategy *
 	}
 }
 
+// requiresDispatchedResolution returns true if the memberships of the usersets, e.g. 'group:eng#member', must
+// be resolved by the default strategies of usersets and tupleToUserset rewrites, which dispatch each of them. The
+// other strategies, e.g. the recursive and weight 2 ones, resolve the memberships without dispatching them, so
+// they can't resolve a user that is a userset, can't honor excluded relations, assumed facts nor the oracles of
+// the usersets, can't score the path taken, and can't share the memberships resolved within a session, cache
+// them as subproblems nor trace them either.
+func (c *LocalChecker) requiresDispatchedResolution(req *ResolveCheckRequest, usersets []*openfgav1.RelationReference) bool {
+	return tuple.IsObjectRelation(req.GetTupleKey().GetUser()) ||
+		len(req.GetExcludedRelations()) > 0 ||
+		len(req.GetAssumedFacts()) > 0 ||
+		req.GetScoring() != nil ||
+		req.GetSession() != nil ||
+		req.GetTrace() ||
+		c.usersetCaching ||
+		c.hasUsersetOracle(usersets)
+}
+
 func (c *LocalChecker) checkDirectUsersetTuples(ctx context.Context, req *ResolveCheckRequest) CheckHandlerFunc {
 	typesys, _ := typesystem.TypesystemFromContext(ctx)
 	reqTupleKey := req.GetTupleKey()
@@ -736,11 +1188,19 @@ func (c *LocalChecker) checkDirectUsersetTuples(ctx context.Context, req *Resolv
 		userType := tuple.GetType(reqTupleKey.GetUser())
 
 		directlyRelatedUsersetTypes, _ := typesys.DirectlyRelatedUsersets(objectType, relation)
-		isUserset := tuple.IsObjectRelation(reqTupleKey.GetUser())
 
-		// if user in request is userset, we do not have additional strategies to apply
-		if isUserset {
-			iter, err := checkutil.IteratorReadUsersetTuples(ctx, req, directlyRelatedUsersetTypes)
+		// the usersets of the node count towards the fan-out, whichever iterator reads them
+		var fanout atomic.Uint32
+		readUsersets := func(usersets []*openfgav1.RelationReference) (storage.TupleKeyIterator, error) {
+			iter, err := checkutil.IteratorReadUsersetTuples(ctx, req, usersets)
+			if err != nil {
+				return nil, err
+			}
+			return c.limitUsersetFanout(iter, &fanout), nil
+		}
+
+		if c.requiresDispatchedResolution(req, directlyRelatedUsersetTypes) {
+			iter, err := readUsersets(directlyRelatedUsersetTypes)
 			if err != nil {
 				return nil, err
 			}
@@ -766,7 +1226,7 @@ func (c *LocalChecker) checkDirectUsersetTuples(ctx context.Context, req *Resolv
 
 		// if the type#relation is resolvable recursively, then it can only be resolved recursively
 		if typesys.UsersetUseRecursiveResolver(objectType, relation, userType) {
-			iter, err := checkutil.IteratorReadUsersetTuples(ctx, req, directlyRelatedUsersetTypes)
+			iter, err := readUsersets(directlyRelatedUsersetTypes)
 			if err != nil {
 				return nil, err
 			}
@@ -802,7 +1262,7 @@ func (c *LocalChecker) checkDirectUsersetTuples(ctx context.Context, req *Resolv
 					continue
 				}
 				usersets := []*openfgav1.RelationReference{userset}
-				iter, err := checkutil.IteratorReadUsersetTuples(ctx, req, usersets)
+				iter, err := readUsersets(usersets)
 				if err != nil {
 					return nil, err
 				}
@@ -825,7 +1285,7 @@ func (c *LocalChecker) checkDirectUsersetTuples(ctx context.Context, req *Resolv
 			// for all usersets could not be resolved through weight2 resolver, resolve them all through the default resolver.
 			// they all resolved as a group rather than individually.
 			if len(remainingUsersetTypes) > 0 {
-				iter, err := checkutil.IteratorReadUsersetTuples(ctx, req, remainingUsersetTypes)
+				iter, err := readUsersets(remainingUsersetTypes)
 				if err != nil {
 					return nil, err
 				}
@@ -840,7 +1300,7 @@ func (c *LocalChecker) checkDirectUsersetTuples(ctx context.Context, req *Resolv
 					continue
 				}
 				usersets := []*openfgav1.RelationReference{userset}
-				iter, err := checkutil.IteratorReadUsersetTuples(ctx, req, usersets)
+				iter, err := readUsersets(usersets)
 				if err != nil {
 					return nil, err
 				}
@@ -851,7 +1311,7 @@ func (c *LocalChecker) checkDirectUsersetTuples(ctx context.Context, req *Resolv
 			// for all usersets could not be resolved through weight2 resolver, resolve them all through the default resolver.
 			// they all resolved as a group rather than individually.
 			if len(remainingUsersetTypes) > 0 {
-				iter, err := checkutil.IteratorReadUsersetTuples(ctx, req, remainingUsersetTypes)
+				iter, err := readUsersets(remainingUsersetTypes)
 				if err != nil {
 					return nil, err
 				}
@@ -860,7 +1320,7 @@ func (c *LocalChecker) checkDirectUsersetTuples(ctx context.Context, req *Resolv
 			}
 		}
 
-		return union(ctx, c.concurrencyLimit, resolvers...)
+		return unionReducer(req)(ctx, c.breadthLimit(), resolvers...)
 	}
 }
 
@@ -885,19 +1345,32 @@ func (c *LocalChecker) checkDirect(parentctx context.Context, req *ResolveCheckR
 
 		var checkFuncs []CheckHandlerFunc
 
-		if shouldCheckDirectTuple(ctx, req.GetTupleKey()) {
+		checkDirectTuple := shouldCheckDirectTuple(ctx, req.GetTupleKey())
+		if checkDirectTuple {
 			checkFuncs = []CheckHandlerFunc{c.checkDirectUserTuple(parentctx, req)}
 		}
 
-		if shouldCheckPublicAssignable(ctx, reqTupleKey) {
+		if shouldCheckPublicAssignable(ctx, reqTupleKey) && req.GetWildcardPolicy().AppliesTo(reqTupleKey.GetUser()) {
 			checkFuncs = append(checkFuncs, c.checkPublicAssignable(parentctx, req))
 		}
 
-		if len(directlyRelatedUsersetTypes) > 0 {
+		if len(directlyRelatedUsersetTypes) > 0 && c.usersetsMayContainUser(typesys, req, directlyRelatedUsersetTypes) {
 			checkFuncs = append(checkFuncs, c.checkDirectUsersetTuples(parentctx, req))
 		}
 
-		resp, err := union(ctx, c.concurrencyLimit, checkFuncs...)
+		// if the user can only be granted the relation by a tuple of its own, reading that tuple resolves
+		// the relation, so it's read inline rather than by the union
+		if checkDirectTuple && len(checkFuncs) == 1 && req.GetScoring() == nil {
+			span.SetAttributes(attribute.Bool("direct_tuple_only", true))
+			resp, err := checkFuncs[0](ctx)
+			if err != nil {
+				telemetry.TraceError(span, err)
+				return nil, err
+			}
+			return resp, nil
+		}
+
+		resp, err := unionReducer(req)(ctx, c.breadthLimit(), checkFuncs...)
 		if err != nil {
 			telemetry.TraceError(span, err)
 			return nil, err
@@ -907,6 +1380,28 @@ func (c *LocalChecker) checkDirect(parentctx context.Context, req *ResolveCheckR
 	}
 }
 
+// usersetsMayContainUser returns false if, according to the typesystem, none of the usersets can contain the
+// user of the request, e.g. the usersets of the type#relation 'group#member' can't contain a user of type
+// 'user' if the members of groups are of other types. The tuples of such usersets needn't be read, since they
+// can't grant the relation to the user.
+func (c *LocalChecker) usersetsMayContainUser(typesys *typesystem.TypeSystem, req *ResolveCheckRequest, usersets []*openfgav1.RelationReference) bool {
+	user := req.GetTupleKey().GetUser()
+
+	// a user that is a userset is related to its own type#relation without a path, while assumed facts and
+	// the oracles of the usersets may grant memberships that the typesystem doesn't know of
+	if tuple.IsObjectRelation(user) || len(req.GetAssumedFacts()) > 0 || c.hasUsersetOracle(usersets) {
+		return true
+	}
+
+	for _, userset := range usersets {
+		hasPath, err := c.pathExists(typesys, user, userset.GetRelation(), userset.GetType())
+		if err != nil || hasPath {
+			return true
+		}
+	}
+	return false
+}
+
 // checkComputedUserset evaluates the Check request with the rewritten relation (e.g. the computed userset relation).
 func (c *LocalChecker) checkComputedUserset(_ context.Context, req *ResolveCheckRequest, rewrite *openfgav1.Userset) CheckHandlerFunc {
 	rewrittenTupleKey := tuple.NewTupleKey(
@@ -922,10 +1417,27 @@ func (c *LocalChecker) checkComputedUserset(_ context.Context, req *ResolveCheck
 		ctx, span := tracer.Start(ctx, "checkComputedUserset")
 		defer span.End()
 		// No dispatch here, as we don't want to increase resolution depth.
-		return c.ResolveCheck(ctx, childRequest)
+		resp, err := c.ResolveCheck(ctx, childRequest)
+		if err != nil {
+			return nil, err
+		}
+		checkTraceFromContext(ctx).addChild(resp.GetTrace())
+		return resp, nil
 	}
 }
 
+// ttuUsersets returns the usersets that the tupleToUserset rewrite 'computedRelation from tuplesetRelation' of
+// objectType relates to, e.g. 'folder#viewer' for 'viewer from parent' if the parents of objectType are folders.
+func ttuUsersets(typesys *typesystem.TypeSystem, objectType, tuplesetRelation, computedRelation string) []*openfgav1.RelationReference {
+	tuplesetTypes, _ := typesys.GetDirectlyRelatedUserTypes(objectType, tuplesetRelation)
+
+	usersets := make([]*openfgav1.RelationReference, 0, len(tuplesetTypes))
+	for _, tuplesetType := range tuplesetTypes {
+		usersets = append(usersets, typesystem.DirectRelationReference(tuplesetType.GetType(), computedRelation))
+	}
+	return usersets
+}
+
 // checkTTU looks up all tuples of the target tupleset relation on the provided object and for each one
 // of them evaluates the computed userset of the TTU rewrite rule for them.
 func (c *LocalChecker) checkTTU(parentctx context.Context, req *ResolveCheckRequest, rewrite *openfgav1.Userset) CheckHandlerFunc {
@@ -950,6 +1462,12 @@ func (c *LocalChecker) checkTTU(parentctx context.Context, req *ResolveCheckRequ
 		tk := req.GetTupleKey()
 		object := tk.GetObject()
 
+		if req.IsRelationExcluded(objectType, tuplesetRelation) {
+			return &ResolveCheckResponse{
+				Allowed: false,
+			}, nil
+		}
+
 		span.SetAttributes(
 			attribute.String("tupleset_relation", tuple.ToObjectRelationString(tuple.GetType(object), tuplesetRelation)),
 			attribute.String("computed_relation", computedRelation),
@@ -986,9 +1504,8 @@ func (c *LocalChecker) checkTTU(parentctx context.Context, req *ResolveCheckRequ
 		possibleStrategies := map[string]*planner.KeyPlanStrategy{
 			defaultResolver: defaultPlan,
 		}
-		isUserset := tuple.IsObjectRelation(tk.GetUser())
 
-		if !isUserset {
+		if !c.requiresDispatchedResolution(req, ttuUsersets(typesys, objectType, tuplesetRelation, computedRelation)) {
 			if typesys.TTUUseWeight2Resolver(objectType, relation, userType, rewrite.GetTupleToUserset()) {
 				possibleStrategies[weightTwoResolver] = weight2Plan
 				resolver = c.weight2TTU
@@ -1058,8 +1575,19 @@ func (c *LocalChecker) checkSetOperation(
 			reducerKey = "exclusion"
 		}
 
-		for _, child := range children {
-			handlers = append(handlers, c.CheckRewrite(ctx, req, child))
+		for i, child := range children {
+			handler := c.CheckRewrite(ctx, req, child)
+			if req.GetTrace() {
+				handler = tracedOperand(i, handler)
+			}
+			if setOpType == unionSetOperator && c.unionBranchTimeout > 0 {
+				handler = c.withBranchTimeout(handler, c.unionBranchTimeout)
+			}
+			handlers = append(handlers, handler)
+		}
+
+		if setOpType == exclusionSetOperator && len(handlers) == 2 {
+			handlers[1] = withinNegatedSubtree(handlers[1])
 		}
 	default:
 		return func(ctx context.Context) (*ResolveCheckResponse, error) {
@@ -1078,7 +1606,7 @@ func (c *LocalChecker) checkSetOperation(
 			span.End()
 		}()
 
-		resp, err = reducer(ctx, c.concurrencyLimit, handlers...)
+		resp, err = reducer(ctx, c.breadthLimit(), handlers...)
 		return resp, err
 	}
 }
@@ -1087,6 +1615,31 @@ func (c *LocalChecker) CheckRewrite(
 	ctx context.Context,
 	req *ResolveCheckRequest,
 	rewrite *openfgav1.Userset,
+) CheckHandlerFunc {
+	if c.maxRewriteDepth > 0 {
+		if req.GetRequestMetadata().RewriteDepth >= c.maxRewriteDepth {
+			return func(ctx context.Context) (*ResolveCheckResponse, error) {
+				return nil, ErrRewriteDepthExceeded
+			}
+		}
+		req = req.clone()
+		req.GetRequestMetadata().RewriteDepth++
+	}
+
+	handler := c.checkRewrite(ctx, req, rewrite)
+	if req.GetTrace() {
+		handler = tracedCheckHandler(req, rewrite, handler)
+	}
+	if c.rewriteLatencyMetrics {
+		handler = timedCheckHandler(rewrite, handler)
+	}
+	return handler
+}
+
+func (c *LocalChecker) checkRewrite(
+	ctx context.Context,
+	req *ResolveCheckRequest,
+	rewrite *openfgav1.Userset,
 ) CheckHandlerFunc {
 	switch rw := rewrite.GetUserset().(type) {
 	case *openfgav1.Userset_This:
@@ -1096,11 +1649,15 @@ func (c *LocalChecker) CheckRewrite(
 	case *openfgav1.Userset_TupleToUserset:
 		return c.checkTTU(ctx, req, rewrite)
 	case *openfgav1.Userset_Union:
-		return c.checkSetOperation(ctx, req, unionSetOperator, union, rw.Union.GetChild()...)
+		return c.checkSetOperation(ctx, req, unionSetOperator, unionReducer(req), rw.Union.GetChild()...)
 	case *openfgav1.Userset_Intersection:
 		return c.checkSetOperation(ctx, req, intersectionSetOperator, intersection, rw.Intersection.GetChild()...)
 	case *openfgav1.Userset_Difference:
-		return c.checkSetOperation(ctx, req, exclusionSetOperator, exclusion, rw.Difference.GetBase(), rw.Difference.GetSubtract())
+		reducer := exclusion
+		if c.earlyDeny {
+			reducer = earlyDenyExclusion
+		}
+		return c.checkSetOperation(ctx, req, exclusionSetOperator, reducer, rw.Difference.GetBase(), rw.Difference.GetSubtract())
 	default:
 		return func(ctx context.Context) (*ResolveCheckResponse, error) {
 			return nil, ErrUnknownSetOperator
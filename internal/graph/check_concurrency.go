@@ -0,0 +1,148 @@
+package graph
+
+import (
+	"context"
+	"errors"
+
+	"golang.org/x/sync/semaphore"
+)
+
+// checkConcurrencyLimiter bounds the subproblems of a Check that are evaluated concurrently, both by the
+// concurrency budget shared by all the Checks of a LocalChecker and by the partition of the budget of the
+// Check itself, see WithConcurrencyBudget and WithPerRequestConcurrencyLimit.
+//
+// The goroutines of the Check that don't hold a slot, i.e. the ones resolving the root of the Check, wait for a
+// slot of the partition until the Check is done, so that the partition bounds the subproblems in flight rather
+// than the ones spawned. The goroutines holding a slot wait for the subproblems they dispatch, so queueing them
+// for slots held by their ancestors could deadlock: a subproblem they can't acquire a slot for is evaluated
+// sequentially instead. The budget is never waited for, so that a Check doesn't wait on the others.
+type checkConcurrencyLimiter struct {
+	global     *semaphore.Weighted // nil for an unlimited budget
+	perRequest *semaphore.Weighted // nil for an unlimited partition
+
+	// metadata is the metadata of the Check, which records the limit that throttled it, see throttled.
+	metadata *ResolveCheckRequestMetadata
+}
+
+// The names of the limits that a Check waits for, which are reported by ResolveCheckRequestMetadata.ThrottledLimit
+// if the Check times out while waiting for one of them.
+const (
+	ResolveNodeBreadthLimitName    = "resolve_node_breadth_limit"
+	PerRequestConcurrencyLimitName = "check_per_request_concurrency_limit"
+)
+
+// tryAcquire acquires a slot of both the budget and the partition of the Check, and reports whether it did.
+func (l *checkConcurrencyLimiter) tryAcquire() bool {
+	if l == nil {
+		return true
+	}
+
+	if l.perRequest != nil && !l.perRequest.TryAcquire(1) {
+		return false
+	}
+
+	return l.tryAcquireGlobal()
+}
+
+// acquire acquires a slot of both the budget and the partition of the Check for a subproblem spawned from ctx,
+// and reports whether it did. Unless ctx holds a slot, it waits for a slot of the partition, and returns the
+// error of ctx if it is done first.
+func (l *checkConcurrencyLimiter) acquire(ctx context.Context) (bool, error) {
+	if l == nil {
+		return true, nil
+	}
+
+	if l.perRequest == nil || holdsConcurrencySlot(ctx) {
+		return l.tryAcquire(), nil
+	}
+
+	if l.perRequest.TryAcquire(1) {
+		return l.tryAcquireGlobal(), nil
+	}
+
+	if err := l.perRequest.Acquire(ctx, 1); err != nil {
+		l.throttled(ctx, PerRequestConcurrencyLimitName)
+		return false, err
+	}
+
+	return l.tryAcquireGlobal(), nil
+}
+
+// tryAcquireGlobal acquires a slot of the budget once a slot of the partition is acquired, which it releases if
+// the budget is spent.
+func (l *checkConcurrencyLimiter) tryAcquireGlobal() bool {
+	if l.global != nil && !l.global.TryAcquire(1) {
+		if l.perRequest != nil {
+			l.perRequest.Release(1)
+		}
+		return false
+	}
+
+	return true
+}
+
+// release releases a slot acquired by tryAcquire or acquire.
+func (l *checkConcurrencyLimiter) release() {
+	if l == nil {
+		return
+	}
+
+	if l.global != nil {
+		l.global.Release(1)
+	}
+	if l.perRequest != nil {
+		l.perRequest.Release(1)
+	}
+}
+
+// throttled records that the Check was throttled by the limit if ctx was done waiting for it because of its
+// deadline.
+func (l *checkConcurrencyLimiter) throttled(ctx context.Context, limit string) {
+	if l == nil || !errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		return
+	}
+	l.metadata.setThrottledLimit(limit)
+}
+
+type checkConcurrencyLimiterContextKey struct{}
+
+type heldConcurrencySlotContextKey struct{}
+
+// contextWithHeldConcurrencySlot returns a context for a subproblem evaluated with a slot of the concurrency
+// limiter of its Check.
+func contextWithHeldConcurrencySlot(ctx context.Context) context.Context {
+	return context.WithValue(ctx, heldConcurrencySlotContextKey{}, true)
+}
+
+// holdsConcurrencySlot returns true if ctx is the context of a subproblem, or of a subproblem of a subproblem,
+// evaluated with a slot of the concurrency limiter of its Check.
+func holdsConcurrencySlot(ctx context.Context) bool {
+	held, _ := ctx.Value(heldConcurrencySlotContextKey{}).(bool)
+	return held
+}
+
+// contextWithCheckConcurrencyLimiter returns a context with the concurrency limiter of the Check.
+func contextWithCheckConcurrencyLimiter(ctx context.Context, limiter *checkConcurrencyLimiter) context.Context {
+	return context.WithValue(ctx, checkConcurrencyLimiterContextKey{}, limiter)
+}
+
+// checkConcurrencyLimiterFromContext returns the concurrency limiter of the Check, or nil if the context isn't
+// the one of a Check.
+func checkConcurrencyLimiterFromContext(ctx context.Context) *checkConcurrencyLimiter {
+	limiter, _ := ctx.Value(checkConcurrencyLimiterContextKey{}).(*checkConcurrencyLimiter)
+	return limiter
+}
+
+// withConcurrencyLimiter returns the context of a request resolved by the checker with the concurrency limiter
+// of its Check, which is created once for the Check by the first of its requests that the checker resolves.
+func (c *LocalChecker) withConcurrencyLimiter(ctx context.Context, req *ResolveCheckRequest) context.Context {
+	if checkConcurrencyLimiterFromContext(ctx) != nil {
+		return ctx
+	}
+
+	limiter := &checkConcurrencyLimiter{global: c.concurrencyBudget, metadata: req.GetRequestMetadata()}
+	if c.perRequestConcurrencyLimit > 0 {
+		limiter.perRequest = semaphore.NewWeighted(int64(c.perRequestConcurrencyLimit))
+	}
+	return contextWithCheckConcurrencyLimiter(ctx, limiter)
+}
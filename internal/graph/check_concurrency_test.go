@@ -0,0 +1,363 @@
+package graph
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/oklog/ulid/v2"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/goleak"
+
+	openfgav1 "github.com/openfga/api/proto/openfga/v1"
+
+	"github.com/openfga/openfga/pkg/storage"
+	"github.com/openfga/openfga/pkg/storage/memory"
+	"github.com/openfga/openfga/pkg/testutils"
+	"github.com/openfga/openfga/pkg/tuple"
+	"github.com/openfga/openfga/pkg/typesystem"
+)
+
+func TestCheckConcurrencyLimiter(t *testing.T) {
+	t.Run("nil_limiter_is_unlimited", func(t *testing.T) {
+		var limiter *checkConcurrencyLimiter
+		require.Nil(t, checkConcurrencyLimiterFromContext(context.Background()))
+		require.True(t, limiter.tryAcquire())
+		limiter.release()
+	})
+
+	t.Run("checks_have_their_own_partition_of_the_budget", func(t *testing.T) {
+		checker := NewLocalChecker(WithConcurrencyBudget(3), WithPerRequestConcurrencyLimit(2))
+		t.Cleanup(checker.Close)
+
+		ctx := checker.withConcurrencyLimiter(context.Background(), nil)
+		first := checkConcurrencyLimiterFromContext(ctx)
+		require.NotNil(t, first)
+
+		// the subproblems of a Check share the limiter of the Check
+		require.Same(t, first, checkConcurrencyLimiterFromContext(checker.withConcurrencyLimiter(ctx, nil)))
+
+		require.True(t, first.tryAcquire())
+		require.True(t, first.tryAcquire())
+		require.False(t, first.tryAcquire(), "the partition of the Check is spent")
+
+		second := checkConcurrencyLimiterFromContext(checker.withConcurrencyLimiter(context.Background(), nil))
+		require.NotSame(t, first, second)
+		require.True(t, second.tryAcquire())
+		require.False(t, second.tryAcquire(), "the budget is spent")
+
+		first.release()
+		require.True(t, second.tryAcquire())
+	})
+
+	t.Run("root_waits_for_a_slot_of_the_partition", func(t *testing.T) {
+		checker := NewLocalChecker(WithPerRequestConcurrencyLimit(1))
+		t.Cleanup(checker.Close)
+
+		ctx := checker.withConcurrencyLimiter(context.Background(), nil)
+		limiter := checkConcurrencyLimiterFromContext(ctx)
+
+		acquired, err := limiter.acquire(ctx)
+		require.NoError(t, err)
+		require.True(t, acquired)
+
+		// a subproblem holding a slot doesn't wait for one
+		acquired, err = limiter.acquire(contextWithHeldConcurrencySlot(ctx))
+		require.NoError(t, err)
+		require.False(t, acquired)
+
+		// the root waits until a slot is released
+		released := make(chan struct{})
+		go func() {
+			time.Sleep(10 * time.Millisecond)
+			close(released)
+			limiter.release()
+		}()
+		acquired, err = limiter.acquire(ctx)
+		require.NoError(t, err)
+		require.True(t, acquired)
+		select {
+		case <-released:
+		default:
+			require.FailNow(t, "the slot was acquired before it was released")
+		}
+
+		// or until the Check is done
+		timeoutCtx, cancel := context.WithTimeout(ctx, 10*time.Millisecond)
+		defer cancel()
+		acquired, err = limiter.acquire(timeoutCtx)
+		require.ErrorIs(t, err, context.DeadlineExceeded)
+		require.False(t, acquired)
+
+		limiter.release()
+	})
+
+	t.Run("unlimited_checker", func(t *testing.T) {
+		checker := NewLocalChecker(WithConcurrencyBudget(0))
+		t.Cleanup(checker.Close)
+
+		limiter := checkConcurrencyLimiterFromContext(checker.withConcurrencyLimiter(context.Background(), nil))
+		require.NotNil(t, limiter)
+		for range 100 {
+			acquired, err := limiter.acquire(context.Background())
+			require.NoError(t, err)
+			require.True(t, acquired)
+		}
+	})
+}
+
+func TestCheckThrottledLimit(t *testing.T) {
+	t.Cleanup(func() {
+		goleak.VerifyNone(t)
+	})
+
+	ds := memory.New()
+	t.Cleanup(ds.Close)
+
+	storeID := ulid.Make().String()
+	model := testutils.MustTransformDSLToProtoWithID(`
+		model
+			schema 1.1
+		type user
+		type document
+			relations
+				define editor: [user]
+				define viewer: [user] or editor`)
+	ts, err := typesystem.New(model)
+	require.NoError(t, err)
+
+	// each read outlasts the deadline of the Check
+	reader := &concurrencyTrackingTupleReader{RelationshipTupleReader: ds, delay: 50 * time.Millisecond}
+
+	newRequest := func(t *testing.T) *ResolveCheckRequest {
+		req, err := NewResolveCheckRequest(ResolveCheckRequestParams{
+			StoreID:              storeID,
+			AuthorizationModelID: model.GetId(),
+			TupleKey:             tuple.NewTupleKey("document:1", "viewer", "user:anne"),
+		})
+		require.NoError(t, err)
+		return req
+	}
+
+	t.Run("resolve_node_breadth_limit", func(t *testing.T) {
+		checker := NewLocalChecker(WithResolveNodeBreadthLimit(1))
+		t.Cleanup(checker.Close)
+
+		ctx, cancel := context.WithTimeout(setRequestContext(context.Background(), ts, reader, nil), 10*time.Millisecond)
+		defer cancel()
+
+		req := newRequest(t)
+		_, err := checker.ResolveCheck(ctx, req)
+		require.ErrorIs(t, err, context.DeadlineExceeded)
+		require.Equal(t, ResolveNodeBreadthLimitName, req.GetRequestMetadata().ThrottledLimit())
+	})
+
+	t.Run("per_request_concurrency_limit", func(t *testing.T) {
+		checker := NewLocalChecker(WithPerRequestConcurrencyLimit(1))
+		t.Cleanup(checker.Close)
+
+		ctx, cancel := context.WithTimeout(setRequestContext(context.Background(), ts, reader, nil), 10*time.Millisecond)
+		defer cancel()
+
+		req := newRequest(t)
+		_, err := checker.ResolveCheck(ctx, req)
+		require.ErrorIs(t, err, context.DeadlineExceeded)
+		require.Equal(t, PerRequestConcurrencyLimitName, req.GetRequestMetadata().ThrottledLimit())
+	})
+
+	t.Run("not_throttled", func(t *testing.T) {
+		checker := NewLocalChecker()
+		t.Cleanup(checker.Close)
+
+		ctx, cancel := context.WithTimeout(setRequestContext(context.Background(), ts, reader, nil), 10*time.Millisecond)
+		defer cancel()
+
+		req := newRequest(t)
+		_, err := checker.ResolveCheck(ctx, req)
+		require.ErrorIs(t, err, context.DeadlineExceeded)
+		require.Empty(t, req.GetRequestMetadata().ThrottledLimit())
+	})
+}
+
+// concurrencyTrackingTupleReader records the maximum number of reads in flight at once.
+type concurrencyTrackingTupleReader struct {
+	storage.RelationshipTupleReader
+	delay time.Duration
+
+	inFlight    atomic.Int64
+	maxInFlight atomic.Int64
+}
+
+func (r *concurrencyTrackingTupleReader) track() func() {
+	inFlight := r.inFlight.Add(1)
+	for {
+		maxInFlight := r.maxInFlight.Load()
+		if inFlight <= maxInFlight || r.maxInFlight.CompareAndSwap(maxInFlight, inFlight) {
+			break
+		}
+	}
+	time.Sleep(r.delay)
+	return func() {
+		r.inFlight.Add(-1)
+	}
+}
+
+func (r *concurrencyTrackingTupleReader) ReadUserTuple(
+	ctx context.Context,
+	store string,
+	tupleKey *openfgav1.TupleKey,
+	options storage.ReadUserTupleOptions,
+) (*openfgav1.Tuple, error) {
+	defer r.track()()
+	return r.RelationshipTupleReader.ReadUserTuple(ctx, store, tupleKey, options)
+}
+
+// newConcurrencyTestStore writes a document whose viewers are the members of the given number of groups, none
+// of which user:anne is a member of, and returns the reader of the store.
+func newConcurrencyTestStore(t testing.TB, document string, groups int) (storage.RelationshipTupleReader, string) {
+	ds := memory.New()
+	t.Cleanup(ds.Close)
+
+	storeID := ulid.Make().String()
+	var tuples []*openfgav1.TupleKey
+	for i := range groups {
+		group := fmt.Sprintf("group:%d", i)
+		tuples = append(tuples,
+			tuple.NewTupleKey(document, "viewer", group+"#member"),
+			tuple.NewTupleKey(group, "member", "user:bob"),
+		)
+	}
+	for chunk := range slices.Chunk(tuples, 100) {
+		require.NoError(t, ds.Write(context.Background(), storeID, nil, chunk))
+	}
+	return ds, storeID
+}
+
+const concurrencyTestModel = `
+	model
+		schema 1.1
+
+	type user
+	type group
+		relations
+			define member: [user]
+	type document
+		relations
+			define viewer: [group#member]`
+
+func TestCheckPerRequestConcurrencyLimit(t *testing.T) {
+	t.Cleanup(func() {
+		goleak.VerifyNone(t)
+	})
+
+	ds, storeID := newConcurrencyTestStore(t, "document:1", 50)
+	reader := &concurrencyTrackingTupleReader{RelationshipTupleReader: ds, delay: time.Millisecond}
+
+	model := testutils.MustTransformDSLToProtoWithID(concurrencyTestModel)
+	ts, err := typesystem.New(model)
+	require.NoError(t, err)
+
+	for _, limit := range []int{1, 4} {
+		t.Run(fmt.Sprintf("limit_%d", limit), func(t *testing.T) {
+			reader.maxInFlight.Store(0)
+
+			// the memberships are dispatched so that each of them is a subproblem
+			checker := NewLocalChecker(WithUsersetCaching(true), WithPerRequestConcurrencyLimit(limit))
+			t.Cleanup(checker.Close)
+
+			req, err := NewResolveCheckRequest(ResolveCheckRequestParams{
+				StoreID:              storeID,
+				AuthorizationModelID: model.GetId(),
+				TupleKey:             tuple.NewTupleKey("document:1", "viewer", "user:anne"),
+			})
+			require.NoError(t, err)
+
+			resp, err := checker.ResolveCheck(setRequestContext(context.Background(), ts, reader, nil), req)
+			require.NoError(t, err)
+			require.False(t, resp.GetAllowed())
+
+			// the root of the Check waits for a slot rather than evaluating the subproblems sequentially
+			require.LessOrEqual(t, reader.maxInFlight.Load(), int64(limit))
+		})
+	}
+}
+
+// BenchmarkCheckConcurrencyPartition measures the latency of small Checks resolved alongside Checks with a
+// large fan out, with a concurrency budget shared by all the Checks, with and without partitioning the budget
+// by Check. On hosts with few cores the latency is bound by the CPU rather than the budget, but the subproblems
+// of the large Checks in flight at once still show the partition.
+func BenchmarkCheckConcurrencyPartition(b *testing.B) {
+	const budget = 32
+
+	ds, storeID := newConcurrencyTestStore(b, "document:large", 2000)
+	smallDS, smallStoreID := newConcurrencyTestStore(b, "document:small", 4)
+	reader := &concurrencyTrackingTupleReader{RelationshipTupleReader: ds, delay: 100 * time.Microsecond}
+	smallReader := &concurrencyTrackingTupleReader{RelationshipTupleReader: smallDS, delay: 100 * time.Microsecond}
+
+	model := testutils.MustTransformDSLToProtoWithID(concurrencyTestModel)
+	ts, err := typesystem.New(model)
+	require.NoError(b, err)
+
+	for name, perRequestLimit := range map[string]int{
+		"without_partition": 0,
+		"with_partition":    budget / 8,
+	} {
+		b.Run(name, func(b *testing.B) {
+			checker := NewLocalChecker(
+				WithUsersetCaching(true),
+				WithConcurrencyBudget(budget),
+				WithPerRequestConcurrencyLimit(perRequestLimit),
+			)
+			defer checker.Close()
+
+			check := func(ds storage.RelationshipTupleReader, storeID, object string) error {
+				req, err := NewResolveCheckRequest(ResolveCheckRequestParams{
+					StoreID:              storeID,
+					AuthorizationModelID: model.GetId(),
+					TupleKey:             tuple.NewTupleKey(object, "viewer", "user:anne"),
+				})
+				if err != nil {
+					return err
+				}
+				_, err = checker.ResolveCheck(setRequestContext(context.Background(), ts, ds, nil), req)
+				return err
+			}
+
+			// the large Checks are resolved in the background until the small ones are measured
+			reader.maxInFlight.Store(0)
+			ctx, cancel := context.WithCancel(context.Background())
+			var wg sync.WaitGroup
+			for range 4 {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					for ctx.Err() == nil {
+						_ = check(reader, storeID, "document:large")
+					}
+				}()
+			}
+
+			latencies := make([]time.Duration, 0, b.N)
+			b.ResetTimer()
+			for range b.N {
+				start := time.Now()
+				require.NoError(b, check(smallReader, smallStoreID, "document:small"))
+				latencies = append(latencies, time.Since(start))
+			}
+			b.StopTimer()
+
+			cancel()
+			wg.Wait()
+
+			slices.Sort(latencies)
+			b.ReportMetric(float64(latencies[len(latencies)/2].Microseconds()), "p50_us")
+			b.ReportMetric(float64(latencies[len(latencies)*99/100].Microseconds()), "p99_us")
+			// the subproblems of the large Checks in flight at once, which the partition bounds
+			b.ReportMetric(float64(reader.maxInFlight.Load()), "max_large_in_flight")
+		})
+	}
+}
@@ -0,0 +1,110 @@
+package graph
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+
+	openfgav1 "github.com/openfga/api/proto/openfga/v1"
+
+	"github.com/openfga/openfga/internal/build"
+)
+
+var checkRewriteDurationHistogram = promauto.NewHistogramVec(prometheus.HistogramOpts{
+	Namespace:                       build.ProjectName,
+	Name:                            "check_rewrite_duration_ms",
+	Help:                            "The time (in ms) spent evaluating the rewrites of the relations resolved by Checks, labeled by rewrite operation. The time spent in the operands and dispatched subproblems of a rewrite is excluded, so that the durations of the operations add up.",
+	Buckets:                         []float64{0.1, 0.5, 1, 2.5, 5, 10, 25, 50, 100, 250, 500, 1000},
+	NativeHistogramBucketFactor:     1.1,
+	NativeHistogramMaxBucketNumber:  100,
+	NativeHistogramMinResetDuration: time.Hour,
+}, []string{"operation"})
+
+// WithRewriteLatencyMetrics records the time spent evaluating each rewrite in the check_rewrite_duration_ms
+// histogram, labeled by the operation of the rewrite, e.g. union or tupleToUserset.
+func WithRewriteLatencyMetrics(enabled bool) LocalCheckerOption {
+	return func(d *LocalChecker) {
+		d.rewriteLatencyMetrics = enabled
+	}
+}
+
+// rewriteTimer measures the time that a rewrite spends on its own work, that is the time during which none
+// of its children, i.e. its operands or the subproblems it dispatches, is being evaluated. The children may
+// be evaluated concurrently, so the time of the children is the union of their intervals rather than their sum.
+type rewriteTimer struct {
+	mu       sync.Mutex
+	active   int           // GUARDED_BY(mu). The number of children being evaluated.
+	since    time.Time     // GUARDED_BY(mu). When the children being evaluated started to be.
+	children time.Duration // GUARDED_BY(mu). The time during which a child was being evaluated.
+}
+
+// startChild records that a child of the rewrite is being evaluated. The returned function must be called
+// once it is done.
+func (t *rewriteTimer) startChild() func() {
+	t.mu.Lock()
+	if t.active == 0 {
+		t.since = time.Now()
+	}
+	t.active++
+	t.mu.Unlock()
+
+	return func() {
+		t.mu.Lock()
+		defer t.mu.Unlock()
+		t.active--
+		if t.active == 0 {
+			t.children += time.Since(t.since)
+		}
+	}
+}
+
+// selfTime returns the time elapsed since start during which no child was being evaluated.
+func (t *rewriteTimer) selfTime(start time.Time) time.Duration {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	children := t.children
+	if t.active > 0 {
+		// the children abandoned once the outcome of the rewrite is known may still be running
+		children += time.Since(t.since)
+	}
+	return max(time.Since(start)-children, 0)
+}
+
+type rewriteTimerContextKey struct{}
+
+// rewriteTimerFromContext returns the timer of the rewrite being evaluated, or nil if the rewrite latency
+// metrics aren't enabled.
+func rewriteTimerFromContext(ctx context.Context) *rewriteTimer {
+	t, _ := ctx.Value(rewriteTimerContextKey{}).(*rewriteTimer)
+	return t
+}
+
+// startRewriteChild records that a child of the rewrite being evaluated, if any, is being evaluated. The
+// returned function must be called once it is done.
+func startRewriteChild(ctx context.Context) func() {
+	if t := rewriteTimerFromContext(ctx); t != nil {
+		return t.startChild()
+	}
+	return func() {}
+}
+
+// timedCheckHandler returns a CheckHandlerFunc that records the time that the handler of the rewrite spends
+// on its own work in checkRewriteDurationHistogram. The handler counts as a child of the rewrite evaluating it.
+func timedCheckHandler(rewrite *openfgav1.Userset, handler CheckHandlerFunc) CheckHandlerFunc {
+	operation := string(checkTraceOperation(rewrite))
+	return func(ctx context.Context) (*ResolveCheckResponse, error) {
+		defer startRewriteChild(ctx)()
+
+		timer := &rewriteTimer{}
+		start := time.Now()
+		defer func() {
+			checkRewriteDurationHistogram.WithLabelValues(operation).Observe(float64(timer.selfTime(start).Microseconds()) / 1000)
+		}()
+
+		return handler(context.WithValue(ctx, rewriteTimerContextKey{}, timer))
+	}
+}
@@ -0,0 +1,179 @@
+package graph
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/oklog/ulid/v2"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/goleak"
+
+	openfgav1 "github.com/openfga/api/proto/openfga/v1"
+
+	"github.com/openfga/openfga/internal/build"
+	"github.com/openfga/openfga/pkg/storage/memory"
+	"github.com/openfga/openfga/pkg/testutils"
+	"github.com/openfga/openfga/pkg/tuple"
+	"github.com/openfga/openfga/pkg/typesystem"
+)
+
+const rewriteLatencyTestModel = `
+	model
+		schema 1.1
+	type user
+	type group
+		relations
+			define member: [user]
+	type folder
+		relations
+			define viewer: [user]
+	type document
+		relations
+			define parent: [folder]
+			define owner: [user]
+			define blocked: [user]
+			define editor: [user, group#member] or owner
+			define viewer: (editor or viewer from parent) but not blocked`
+
+func rewriteDurationCount(t testing.TB, operation CheckTraceOperation) uint64 {
+	families, err := prometheus.DefaultGatherer.Gather()
+	require.NoError(t, err)
+
+	for _, family := range families {
+		if family.GetName() != build.ProjectName+"_check_rewrite_duration_ms" {
+			continue
+		}
+		for _, metric := range family.GetMetric() {
+			for _, label := range metric.GetLabel() {
+				if label.GetName() == "operation" && label.GetValue() == string(operation) {
+					return metric.GetHistogram().GetSampleCount()
+				}
+			}
+		}
+	}
+	return 0
+}
+
+func TestRewriteTimer(t *testing.T) {
+	timer := &rewriteTimer{}
+	start := time.Now()
+
+	// the concurrent children count once
+	endFirst := timer.startChild()
+	endSecond := timer.startChild()
+	time.Sleep(20 * time.Millisecond)
+	endFirst()
+	time.Sleep(5 * time.Millisecond)
+	endSecond()
+
+	time.Sleep(10 * time.Millisecond)
+
+	self := timer.selfTime(start)
+	require.GreaterOrEqual(t, self, 10*time.Millisecond)
+	require.LessOrEqual(t, self, time.Since(start)-25*time.Millisecond)
+}
+
+func TestRewriteLatencyMetrics(t *testing.T) {
+	t.Cleanup(func() {
+		goleak.VerifyNone(t)
+	})
+
+	ds := memory.New()
+	t.Cleanup(ds.Close)
+
+	storeID := ulid.Make().String()
+	require.NoError(t, ds.Write(context.Background(), storeID, nil, []*openfgav1.TupleKey{
+		tuple.NewTupleKey("document:1", "parent", "folder:1"),
+		tuple.NewTupleKey("folder:1", "viewer", "user:anne"),
+		tuple.NewTupleKey("document:1", "editor", "group:eng#member"),
+	}))
+
+	model := testutils.MustTransformDSLToProtoWithID(rewriteLatencyTestModel)
+	ts, err := typesystem.New(model)
+	require.NoError(t, err)
+
+	operations := []CheckTraceOperation{
+		CheckTraceThis, CheckTraceComputed, CheckTraceTupleToUserset, CheckTraceUnion, CheckTraceDifference,
+	}
+
+	check := func(t *testing.T, checker *LocalChecker) {
+		req, err := NewResolveCheckRequest(ResolveCheckRequestParams{
+			StoreID:              storeID,
+			AuthorizationModelID: model.GetId(),
+			TupleKey:             tuple.NewTupleKey("document:1", "viewer", "user:anne"),
+		})
+		require.NoError(t, err)
+
+		resp, err := checker.ResolveCheck(setRequestContext(context.Background(), ts, ds, nil), req)
+		require.NoError(t, err)
+		require.True(t, resp.GetAllowed())
+	}
+
+	t.Run("records_each_operation", func(t *testing.T) {
+		checker := NewLocalChecker(WithRewriteLatencyMetrics(true))
+		t.Cleanup(checker.Close)
+
+		before := map[CheckTraceOperation]uint64{}
+		for _, operation := range operations {
+			before[operation] = rewriteDurationCount(t, operation)
+		}
+
+		check(t, checker)
+
+		for _, operation := range operations {
+			require.Greater(t, rewriteDurationCount(t, operation), before[operation], operation)
+		}
+	})
+
+	t.Run("disabled", func(t *testing.T) {
+		checker := NewLocalChecker()
+		t.Cleanup(checker.Close)
+
+		before := rewriteDurationCount(t, CheckTraceUnion)
+		check(t, checker)
+		require.Equal(t, before, rewriteDurationCount(t, CheckTraceUnion))
+	})
+}
+
+// BenchmarkRewriteLatencyMetrics measures the overhead of recording the time spent in each rewrite, which
+// is expected to stay within a few percent of the latency of a Check.
+func BenchmarkRewriteLatencyMetrics(b *testing.B) {
+	ds := memory.New()
+	b.Cleanup(ds.Close)
+
+	storeID := ulid.Make().String()
+	require.NoError(b, ds.Write(context.Background(), storeID, nil, []*openfgav1.TupleKey{
+		tuple.NewTupleKey("document:1", "parent", "folder:1"),
+		tuple.NewTupleKey("folder:1", "viewer", "user:anne"),
+		tuple.NewTupleKey("document:1", "editor", "group:eng#member"),
+	}))
+
+	model := testutils.MustTransformDSLToProtoWithID(rewriteLatencyTestModel)
+	ts, err := typesystem.New(model)
+	require.NoError(b, err)
+
+	for name, enabled := range map[string]bool{
+		"disabled": false,
+		"enabled":  true,
+	} {
+		b.Run(name, func(b *testing.B) {
+			checker := NewLocalChecker(WithRewriteLatencyMetrics(enabled))
+			defer checker.Close()
+
+			b.ResetTimer()
+			for range b.N {
+				req, err := NewResolveCheckRequest(ResolveCheckRequestParams{
+					StoreID:              storeID,
+					AuthorizationModelID: model.GetId(),
+					TupleKey:             tuple.NewTupleKey("document:1", "viewer", "user:anne"),
+				})
+				require.NoError(b, err)
+
+				_, err = checker.ResolveCheck(setRequestContext(context.Background(), ts, ds, nil), req)
+				require.NoError(b, err)
+			}
+		})
+	}
+}
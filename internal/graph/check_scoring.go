@@ -0,0 +1,169 @@
+package graph
+
+import (
+	"context"
+	"errors"
+	"strconv"
+)
+
+// CheckScoring is the rubric used to score the strength of the relationship between a user and an
+// object when a Check is resolved in scoring mode. A grant through a direct relationship tuple scores
+// DirectScore and a grant through a typed wildcard scores WildcardScore. Every hop through a userset or
+// a tuple to userset rewrite multiplies the score of the path by HopDecay. When several paths grant
+// access, the highest scoring one wins.
+type CheckScoring struct {
+	DirectScore   float64
+	WildcardScore float64
+	HopDecay      float64
+}
+
+// DefaultCheckScoring derives the score from the path depth: a direct grant scores 1, every hop halves
+// the score, and a wildcard grant scores lower than any direct grant up to three hops away.
+var DefaultCheckScoring = CheckScoring{
+	DirectScore:   1,
+	WildcardScore: 0.1,
+	HopDecay:      0.5,
+}
+
+var ErrInvalidCheckScoring = errors.New("invalid check scoring")
+
+// Validate returns an error if the rubric cannot produce meaningful scores.
+func (s *CheckScoring) Validate() error {
+	if s.DirectScore <= 0 {
+		return errors.Join(ErrInvalidCheckScoring, errors.New("direct score must be positive"))
+	}
+
+	if s.WildcardScore < 0 {
+		return errors.Join(ErrInvalidCheckScoring, errors.New("wildcard score must not be negative"))
+	}
+
+	if s.HopDecay <= 0 || s.HopDecay > 1 {
+		return errors.Join(ErrInvalidCheckScoring, errors.New("hop decay must be in the range (0, 1]"))
+	}
+
+	return nil
+}
+
+// cacheKey returns a representation of the rubric that is used to tell apart the cached results of
+// Checks resolved with different rubrics.
+func (s *CheckScoring) cacheKey() string {
+	if s == nil {
+		return ""
+	}
+
+	return strconv.FormatFloat(s.DirectScore, 'g', -1, 64) + "," +
+		strconv.FormatFloat(s.WildcardScore, 'g', -1, 64) + "," +
+		strconv.FormatFloat(s.HopDecay, 'g', -1, 64)
+}
+
+// grantedResponse returns an allowed response scored with score if the request is resolved in
+// scoring mode.
+func grantedResponse(req *ResolveCheckRequest, score func(*CheckScoring) float64) *ResolveCheckResponse {
+	resp := &ResolveCheckResponse{
+		Allowed: true,
+	}
+
+	if scoring := req.GetScoring(); scoring != nil {
+		resp.Score = score(scoring)
+	}
+
+	return resp
+}
+
+func directScore(s *CheckScoring) float64 {
+	return s.DirectScore
+}
+
+func wildcardScore(s *CheckScoring) float64 {
+	return s.WildcardScore
+}
+
+// decayScore returns the response of a sub-problem as seen from its parent one hop away.
+func decayScore(req *ResolveCheckRequest, resp *ResolveCheckResponse) *ResolveCheckResponse {
+	scoring := req.GetScoring()
+	if scoring == nil || !resp.GetAllowed() {
+		return resp
+	}
+
+	// the response may be shared with the cache, so it is not modified in place
+	decayed := resp.clone()
+	decayed.Score = resp.GetScore() * scoring.HopDecay
+	decayed.Trace = resp.GetTrace()
+	return decayed
+}
+
+// unionMaxScore implements a CheckFuncReducer that, like union, requires any of the provided
+// CheckHandlerFunc to resolve to an allowed outcome. Instead of terminating on the first allowed
+// outcome, it evaluates every handler and yields the allowed outcome with the highest score.
+func unionMaxScore(ctx context.Context, concurrencyLimit int, handlers ...CheckHandlerFunc) (resp *ResolveCheckResponse, err error) {
+	ctx, cancel := context.WithCancel(ctx)
+	resultChan := make(chan checkOutcome, len(handlers))
+
+	drain := resolver(ctx, concurrencyLimit, resultChan, handlers...)
+
+	defer func() {
+		cancel()
+		drainErr := drain()
+		if drainErr != nil {
+			err = drainErr
+			resp = nil
+		}
+		close(resultChan)
+	}()
+
+	var elErr error
+	var cycleDetected, degraded bool
+	var best *ResolveCheckResponse
+	for i := 0; i < len(handlers); i++ {
+		select {
+		case result := <-resultChan:
+			if result.err != nil {
+				elErr = result.err
+				continue
+			}
+
+			if result.resp.GetCycleDetected() {
+				cycleDetected = true
+			}
+
+			if result.resp.GetDegraded() {
+				degraded = true
+			}
+
+			if result.resp.GetAllowed() && (best == nil || result.resp.GetScore() > best.GetScore()) {
+				best = result.resp
+			}
+		case <-ctx.Done():
+			err = ctx.Err()
+			return
+		}
+	}
+
+	if best != nil {
+		resp = best
+		return
+	}
+
+	if elErr != nil {
+		err = elErr
+		return
+	}
+
+	resp = &ResolveCheckResponse{
+		Allowed: false,
+		ResolutionMetadata: ResolveCheckResponseMetadata{
+			CycleDetected: cycleDetected,
+			Degraded:      degraded,
+		},
+	}
+
+	return
+}
+
+// unionReducer returns the CheckFuncReducer used to resolve unions of the request.
+func unionReducer(req *ResolveCheckRequest) CheckFuncReducer {
+	if req.GetScoring() != nil {
+		return unionMaxScore
+	}
+	return union
+}
@@ -0,0 +1,91 @@
+package graph
+
+import (
+	"sync"
+)
+
+// CheckSession memoizes the outcomes of the subproblems, e.g. the group memberships of a user, that are
+// resolved by the Checks of a client-scoped session. Unlike the Check cache, its outcomes are only
+// shared by the Checks that are resolved with the session, for as long as the session is open. Outcomes
+// don't expire while the session is open, so the Checks of a session may not observe the tuples written
+// after the session resolved a subproblem.
+type CheckSession struct {
+	mu         sync.Mutex
+	maxEntries int
+	results    map[string]*ResolveCheckResponse // map: cache key => *ResolveCheckResponse
+	closed     bool
+}
+
+// NewCheckSession returns an open session that memoizes the outcomes of at most maxEntries subproblems.
+// Once the session is full, the outcomes of further subproblems are not memoized.
+func NewCheckSession(maxEntries int) *CheckSession {
+	return &CheckSession{
+		maxEntries: maxEntries,
+		results:    make(map[string]*ResolveCheckResponse),
+	}
+}
+
+// Len returns the number of memoized outcomes.
+func (s *CheckSession) Len() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.results)
+}
+
+// Close frees the memoized outcomes. Checks that are resolved with a closed session don't memoize outcomes.
+func (s *CheckSession) Close() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.closed = true
+	s.results = nil
+}
+
+// checkSessionFor returns the session of the request if the subproblem of 'key' that is dispatched from
+// the parent request should be memoized, otherwise nil.
+func checkSessionFor(parentReq *ResolveCheckRequest, key string) *CheckSession {
+	session := parentReq.GetSession()
+	if session == nil {
+		return nil
+	}
+
+	// a subproblem that is on the path to the parent is a cycle, which must be detected as such
+	if _, visited := parentReq.VisitedPaths[key]; visited {
+		return nil
+	}
+
+	return session
+}
+
+// cacheKey returns the key of the subproblem of 'key' within the session. It accounts for the invariant
+// parts of the request, e.g. the model and the contextual tuples, which may differ across the Checks of a session.
+func (s *CheckSession) cacheKey(parentReq *ResolveCheckRequest, key string) string {
+	return key + parentReq.GetInvariantCacheKey()
+}
+
+func (s *CheckSession) load(key string) (*ResolveCheckResponse, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	resp, ok := s.results[key]
+	if !ok {
+		return nil, false
+	}
+	return resp.clone(), true
+}
+
+// store memoizes the outcome of a subproblem. Outcomes that depend on the path that the subproblem was
+// reached through, i.e. outcomes that detected a cycle, and outcomes derived from stale cache entries are
+// not memoized.
+func (s *CheckSession) store(key string, resp *ResolveCheckResponse) {
+	if resp.GetCycleDetected() || resp.GetDegraded() {
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.closed || len(s.results) >= s.maxEntries {
+		return
+	}
+	s.results[key] = resp.clone()
+}
@@ -0,0 +1,40 @@
+package graph
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestCheckSessionIsBounded(t *testing.T) {
+	session := NewCheckSession(1)
+
+	session.store("a", &ResolveCheckResponse{Allowed: true})
+	session.store("b", &ResolveCheckResponse{Allowed: true})
+	require.Equal(t, 1, session.Len())
+
+	resp, ok := session.load("a")
+	require.True(t, ok)
+	require.True(t, resp.GetAllowed())
+
+	_, ok = session.load("b")
+	require.False(t, ok)
+
+	t.Run("outcomes_that_detected_a_cycle_are_not_memoized", func(t *testing.T) {
+		session := NewCheckSession(10)
+		session.store("a", &ResolveCheckResponse{ResolutionMetadata: ResolveCheckResponseMetadata{CycleDetected: true}})
+		require.Zero(t, session.Len())
+	})
+
+	t.Run("closed_session_frees_and_stops_memoizing", func(t *testing.T) {
+		session := NewCheckSession(10)
+		session.store("a", &ResolveCheckResponse{Allowed: true})
+		session.Close()
+		require.Zero(t, session.Len())
+
+		session.store("b", &ResolveCheckResponse{Allowed: true})
+		require.Zero(t, session.Len())
+		_, ok := session.load("a")
+		require.False(t, ok)
+	})
+}
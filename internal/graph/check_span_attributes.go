@@ -0,0 +1,71 @@
+package graph
+
+import (
+	"go.opentelemetry.io/otel/attribute"
+
+	"github.com/openfga/openfga/pkg/tuple"
+)
+
+// cacheOutcome is the outcome of the lookup of a Check subproblem in the cache, it is set on the span of the
+// subproblem as the cache_outcome attribute.
+type cacheOutcome string
+
+const (
+	// cacheOutcomeHit is the outcome of a subproblem served from the cache.
+	cacheOutcomeHit cacheOutcome = "hit"
+	// cacheOutcomeMiss is the outcome of a subproblem that wasn't cached, or whose entry was no longer valid.
+	cacheOutcomeMiss cacheOutcome = "miss"
+	// cacheOutcomeBypass is the outcome of a subproblem that didn't look up the cache, see cacheBypassReason.
+	cacheOutcomeBypass cacheOutcome = "bypass"
+)
+
+// cacheBypassReason is why a Check subproblem didn't look up the cache, it is set on the span of the subproblem
+// as the cache_bypass_reason attribute.
+type cacheBypassReason string
+
+const (
+	// cacheBypassCacheOff is the reason of the subproblems of the requests that disabled the cache.
+	cacheBypassCacheOff cacheBypassReason = "cache_off"
+	// cacheBypassWriteOnly is the reason of the subproblems of the requests that only refresh the cache.
+	cacheBypassWriteOnly cacheBypassReason = "write_only"
+	// cacheBypassUncachedObjectType is the reason of the subproblems of an object type that is never cached.
+	cacheBypassUncachedObjectType cacheBypassReason = "uncached_object_type"
+	// cacheBypassPrimaryRead is the reason of the subproblems that may read relations from the primary.
+	cacheBypassPrimaryRead cacheBypassReason = "primary_read"
+	// cacheBypassConsistency is the reason of the subproblems of the requests with HIGHER_CONSISTENCY.
+	cacheBypassConsistency cacheBypassReason = "consistency"
+)
+
+// checkSpanAttributes returns the attributes that describe the Check subproblem of the request on a span. They are
+// of low cardinality, i.e. the store, the model, the object type and the relation, so that the spans can be
+// aggregated and don't leak the users or the objects of the requests. The tuple key of the subproblem is added
+// with highCardinality, e.g. for debugging.
+func checkSpanAttributes(req *ResolveCheckRequest, highCardinality bool) []attribute.KeyValue {
+	attributes := []attribute.KeyValue{
+		attribute.String("store_id", req.GetStoreID()),
+		attribute.String("authorization_model_id", req.GetAuthorizationModelID()),
+		attribute.String("object_type", tuple.GetType(req.GetTupleKey().GetObject())),
+		attribute.String("relation", req.GetTupleKey().GetRelation()),
+	}
+	if highCardinality {
+		attributes = append(attributes, attribute.String("tuple_key", tuple.TupleKeyWithConditionToString(req.GetTupleKey())))
+	}
+	return attributes
+}
+
+// WithHighCardinalityTraceAttributes adds the tuple key of each subproblem, i.e. its object and user, to the spans
+// of the LocalChecker, e.g. for debugging. The tuple keys may hold personal data and make the spans impossible to
+// aggregate, so they are left out by default.
+func WithHighCardinalityTraceAttributes(enabled bool) LocalCheckerOption {
+	return func(d *LocalChecker) {
+		d.highCardinalityTraceAttributes = enabled
+	}
+}
+
+// WithCacheHighCardinalityTraceAttributes adds the tuple key of each subproblem to the spans of the
+// CachedCheckResolver, see WithHighCardinalityTraceAttributes.
+func WithCacheHighCardinalityTraceAttributes(enabled bool) CachedCheckResolverOpt {
+	return func(ccr *CachedCheckResolver) {
+		ccr.highCardinalityTraceAttributes = enabled
+	}
+}
@@ -0,0 +1,133 @@
+package graph
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/attribute"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.uber.org/goleak"
+	"go.uber.org/mock/gomock"
+
+	openfgav1 "github.com/openfga/api/proto/openfga/v1"
+
+	"github.com/openfga/openfga/pkg/tuple"
+)
+
+func TestCheckSpanAttributes(t *testing.T) {
+	req, err := NewResolveCheckRequest(ResolveCheckRequestParams{
+		StoreID:              "12",
+		AuthorizationModelID: "33",
+		TupleKey:             tuple.NewTupleKey("document:abc", "reader", "user:XYZ"),
+	})
+	require.NoError(t, err)
+
+	attributes := attribute.NewSet(checkSpanAttributes(req, false)...)
+	require.Equal(t, attribute.NewSet(
+		attribute.String("store_id", "12"),
+		attribute.String("authorization_model_id", "33"),
+		attribute.String("object_type", "document"),
+		attribute.String("relation", "reader"),
+	), attributes)
+
+	attributes = attribute.NewSet(checkSpanAttributes(req, true)...)
+	tupleKey, ok := attributes.Value("tuple_key")
+	require.True(t, ok)
+	require.Equal(t, "document:abc#reader@user:XYZ", tupleKey.AsString())
+}
+
+func TestCachedCheckResolverSpanAttributes(t *testing.T) {
+	t.Cleanup(func() {
+		goleak.VerifyNone(t)
+	})
+
+	setup := func(t *testing.T, opts ...CachedCheckResolverOpt) *CachedCheckResolver {
+		ctrl := gomock.NewController(t)
+		t.Cleanup(ctrl.Finish)
+
+		dut, err := NewCachedCheckResolver(opts...)
+		require.NoError(t, err)
+		t.Cleanup(dut.Close)
+
+		mockResolver := NewMockCheckResolver(ctrl)
+		mockResolver.EXPECT().ResolveCheck(gomock.Any(), gomock.Any()).AnyTimes().Return(&ResolveCheckResponse{Allowed: true}, nil)
+		dut.SetDelegate(mockResolver)
+
+		return dut
+	}
+
+	newRequest := func(t *testing.T, consistency openfgav1.ConsistencyPreference) *ResolveCheckRequest {
+		req, err := NewResolveCheckRequest(ResolveCheckRequestParams{
+			StoreID:              "12",
+			AuthorizationModelID: "33",
+			TupleKey:             tuple.NewTupleKey("document:abc", "reader", "user:XYZ"),
+			Consistency:          consistency,
+		})
+		require.NoError(t, err)
+		return req
+	}
+
+	// resolveCheck resolves the request within a recorded span and returns the attributes set on it
+	resolveCheck := func(t *testing.T, dut *CachedCheckResolver, req *ResolveCheckRequest) attribute.Set {
+		recorder := tracetest.NewSpanRecorder()
+		tp := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+		t.Cleanup(func() {
+			_ = tp.Shutdown(context.Background())
+		})
+
+		ctx, span := tp.Tracer("test").Start(context.Background(), "ResolveCheck")
+		_, err := dut.ResolveCheck(ctx, req)
+		require.NoError(t, err)
+		span.End()
+
+		spans := recorder.Ended()
+		require.Len(t, spans, 1)
+		return attribute.NewSet(spans[0].Attributes()...)
+	}
+
+	requireAttribute := func(t *testing.T, attributes attribute.Set, key, expected string) {
+		value, ok := attributes.Value(attribute.Key(key))
+		require.True(t, ok, key)
+		require.Equal(t, expected, value.AsString(), key)
+	}
+
+	t.Run("miss_then_hit", func(t *testing.T) {
+		dut := setup(t)
+
+		attributes := resolveCheck(t, dut, newRequest(t, openfgav1.ConsistencyPreference_UNSPECIFIED))
+		requireAttribute(t, attributes, "cache_outcome", string(cacheOutcomeMiss))
+		requireAttribute(t, attributes, "store_id", "12")
+		requireAttribute(t, attributes, "authorization_model_id", "33")
+		requireAttribute(t, attributes, "object_type", "document")
+		requireAttribute(t, attributes, "relation", "reader")
+		require.False(t, attributes.HasValue("tuple_key"))
+
+		attributes = resolveCheck(t, dut, newRequest(t, openfgav1.ConsistencyPreference_UNSPECIFIED))
+		requireAttribute(t, attributes, "cache_outcome", string(cacheOutcomeHit))
+	})
+
+	t.Run("bypass_due_to_consistency", func(t *testing.T) {
+		dut := setup(t)
+
+		attributes := resolveCheck(t, dut, newRequest(t, openfgav1.ConsistencyPreference_HIGHER_CONSISTENCY))
+		requireAttribute(t, attributes, "cache_outcome", string(cacheOutcomeBypass))
+		requireAttribute(t, attributes, "cache_bypass_reason", string(cacheBypassConsistency))
+	})
+
+	t.Run("bypass_of_uncached_object_types", func(t *testing.T) {
+		dut := setup(t, WithUncachedObjectTypes("document"))
+
+		attributes := resolveCheck(t, dut, newRequest(t, openfgav1.ConsistencyPreference_UNSPECIFIED))
+		requireAttribute(t, attributes, "cache_outcome", string(cacheOutcomeBypass))
+		requireAttribute(t, attributes, "cache_bypass_reason", string(cacheBypassUncachedObjectType))
+	})
+
+	t.Run("high_cardinality_attributes", func(t *testing.T) {
+		dut := setup(t, WithCacheHighCardinalityTraceAttributes(true))
+
+		attributes := resolveCheck(t, dut, newRequest(t, openfgav1.ConsistencyPreference_UNSPECIFIED))
+		requireAttribute(t, attributes, "tuple_key", "document:abc#reader@user:XYZ")
+	})
+}
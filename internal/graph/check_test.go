@@ -4,12 +4,15 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"strings"
 	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 
 	"github.com/emirpasic/gods/sets/hashset"
 	"github.com/oklog/ulid/v2"
+	promtestutil "github.com/prometheus/client_golang/prometheus/testutil"
 	"github.com/stretchr/testify/require"
 	"go.uber.org/goleak"
 	"go.uber.org/mock/gomock"
@@ -19,8 +22,10 @@ import (
 	parser "github.com/openfga/language/pkg/go/transformer"
 
 	"github.com/openfga/openfga/internal/condition"
+	"github.com/openfga/openfga/internal/condition/eval"
 	openfgaErrors "github.com/openfga/openfga/internal/errors"
 	"github.com/openfga/openfga/internal/mocks"
+	serverconfig "github.com/openfga/openfga/pkg/server/config"
 	"github.com/openfga/openfga/pkg/storage"
 	"github.com/openfga/openfga/pkg/storage/memory"
 	"github.com/openfga/openfga/pkg/testutils"
@@ -511,6 +516,166 @@ func TestExclusionCheckFuncReducer(t *testing.T) {
 	})
 }
 
+func TestEarlyDenyExclusionCheckFuncReducer(t *testing.T) {
+	t.Cleanup(func() {
+		goleak.VerifyNone(t)
+	})
+
+	ctx := context.Background()
+
+	handlers := map[string]CheckHandlerFunc{
+		"true":          trueHandler,
+		"false":         falseHandler,
+		"err":           generalErrorHandler,
+		"errResolution": depthExceededHandler,
+		"cycle":         cyclicErrorHandler,
+		"panic":         func(context.Context) (*ResolveCheckResponse, error) { panic("oops") },
+	}
+
+	t.Run("requires_exactly_two_handlers", func(t *testing.T) {
+		_, err := earlyDenyExclusion(ctx, 1, falseHandler)
+		require.ErrorIs(t, err, openfgaErrors.ErrUnknown)
+	})
+
+	for baseName, base := range handlers {
+		for subName, sub := range handlers {
+			t.Run(baseName+"_butnot_"+subName+"_same_outcome_as_exclusion", func(t *testing.T) {
+				expected, expectedErr := exclusion(ctx, 10, base, sub)
+				resp, err := earlyDenyExclusion(ctx, 10, base, sub)
+				if expectedErr != nil {
+					require.Error(t, err)
+					require.Nil(t, resp)
+					return
+				}
+				require.NoError(t, err)
+				require.Equal(t, expected.GetAllowed(), resp.GetAllowed())
+			})
+		}
+	}
+
+	t.Run("allowed_sub_handler_skips_the_base_handler", func(t *testing.T) {
+		var baseCalled bool
+		base := func(context.Context) (*ResolveCheckResponse, error) {
+			baseCalled = true
+			return trueHandler(ctx)
+		}
+
+		resp, err := earlyDenyExclusion(ctx, 10, base, trueHandler)
+		require.NoError(t, err)
+		require.False(t, resp.GetAllowed())
+		require.False(t, baseCalled)
+
+		resp, err = earlyDenyExclusion(ctx, 10, base, falseHandler)
+		require.NoError(t, err)
+		require.True(t, resp.GetAllowed())
+		require.True(t, baseCalled)
+	})
+
+	t.Run("should_error_if_handler_panics", func(t *testing.T) {
+		_, err := earlyDenyExclusion(ctx, 10, handlers["panic"], falseHandler)
+		require.ErrorIs(t, err, ErrPanic)
+	})
+}
+
+func TestCheckFuncReducersPropagateDegraded(t *testing.T) {
+	t.Cleanup(func() {
+		goleak.VerifyNone(t)
+	})
+
+	ctx := context.Background()
+
+	degradedHandler := func(allowed bool) CheckHandlerFunc {
+		return func(context.Context) (*ResolveCheckResponse, error) {
+			return &ResolveCheckResponse{
+				Allowed:            allowed,
+				ResolutionMetadata: ResolveCheckResponseMetadata{Degraded: true},
+			}, nil
+		}
+	}
+
+	tests := []struct {
+		name             string
+		reducer          CheckFuncReducer
+		handlers         []CheckHandlerFunc
+		expectedAllowed  bool
+		expectedDegraded bool
+	}{
+		{
+			name:             "union_denial_depends_on_every_operand",
+			reducer:          union,
+			handlers:         []CheckHandlerFunc{falseHandler, degradedHandler(false)},
+			expectedDegraded: true,
+		},
+		{
+			name:            "union_allowed_outcome_depends_on_its_own_operand",
+			reducer:         union,
+			handlers:        []CheckHandlerFunc{trueHandler},
+			expectedAllowed: true,
+		},
+		{
+			name:             "union_max_score_denial_depends_on_every_operand",
+			reducer:          unionMaxScore,
+			handlers:         []CheckHandlerFunc{falseHandler, degradedHandler(false)},
+			expectedDegraded: true,
+		},
+		{
+			name:             "intersection_allowed_outcome_depends_on_every_operand",
+			reducer:          intersection,
+			handlers:         []CheckHandlerFunc{trueHandler, degradedHandler(true)},
+			expectedAllowed:  true,
+			expectedDegraded: true,
+		},
+		{
+			name:             "intersection_denial_depends_on_the_denied_operand",
+			reducer:          intersection,
+			handlers:         []CheckHandlerFunc{degradedHandler(false)},
+			expectedDegraded: true,
+		},
+		{
+			name:             "exclusion_allowed_outcome_depends_on_the_base",
+			reducer:          exclusion,
+			handlers:         []CheckHandlerFunc{degradedHandler(true), falseHandler},
+			expectedAllowed:  true,
+			expectedDegraded: true,
+		},
+		{
+			name:             "exclusion_allowed_outcome_depends_on_the_sub",
+			reducer:          exclusion,
+			handlers:         []CheckHandlerFunc{trueHandler, degradedHandler(false)},
+			expectedAllowed:  true,
+			expectedDegraded: true,
+		},
+		{
+			name:             "exclusion_denial_depends_on_the_allowed_sub",
+			reducer:          exclusion,
+			handlers:         []CheckHandlerFunc{trueHandler, degradedHandler(true)},
+			expectedDegraded: true,
+		},
+		{
+			name:             "early_deny_exclusion_allowed_outcome_depends_on_the_sub",
+			reducer:          earlyDenyExclusion,
+			handlers:         []CheckHandlerFunc{trueHandler, degradedHandler(false)},
+			expectedAllowed:  true,
+			expectedDegraded: true,
+		},
+		{
+			name:             "early_deny_exclusion_denial_depends_on_the_base",
+			reducer:          earlyDenyExclusion,
+			handlers:         []CheckHandlerFunc{degradedHandler(false), falseHandler},
+			expectedDegraded: true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			resp, err := test.reducer(ctx, 10, test.handlers...)
+			require.NoError(t, err)
+			require.Equal(t, test.expectedAllowed, resp.GetAllowed())
+			require.Equal(t, test.expectedDegraded, resp.GetDegraded())
+		})
+	}
+}
+
 func TestIntersectionCheckFuncReducer(t *testing.T) {
 	t.Cleanup(func() {
 		goleak.VerifyNone(t)
@@ -1052,778 +1217,1187 @@ func TestCheckWithOneConcurrentGoroutineCausesNoDeadlock(t *testing.T) {
 	require.True(t, resp.Allowed)
 }
 
-func TestCheckConditions(t *testing.T) {
+// blockingReader is a RelationshipTupleReader whose ReadUserTuple hangs for the given relation until
+// released, regardless of the cancellation of its context.
+type blockingReader struct {
+	storage.RelationshipTupleReader
+	relation string
+	release  chan struct{}
+}
+
+func (r *blockingReader) ReadUserTuple(ctx context.Context, store string, tupleKey *openfgav1.TupleKey, options storage.ReadUserTupleOptions) (*openfgav1.Tuple, error) {
+	if tupleKey.GetRelation() == r.relation {
+		<-r.release
+		return nil, storage.ErrNotFound
+	}
+	return r.RelationshipTupleReader.ReadUserTuple(ctx, store, tupleKey, options)
+}
+
+func TestCheckWithUnionBranchTimeout(t *testing.T) {
 	ds := memory.New()
+	defer ds.Close()
 
 	storeID := ulid.Make().String()
 
-	tkConditionContext, err := structpb.NewStruct(map[string]interface{}{
-		"param1": "ok",
+	err := ds.Write(context.Background(), storeID, nil, []*openfgav1.TupleKey{
+		tuple.NewTupleKey("document:1", "viewer", "user:jon"),
 	})
 	require.NoError(t, err)
 
-	model := parser.MustTransformDSLToProto(`
+	model := testutils.MustTransformDSLToProtoWithID(`
 		model
 			schema 1.1
 
 		type user
-
-		type folder
+		type document
 			relations
+				define blocked: [user]
 				define viewer: [user]
+				define can_view: blocked or viewer`)
 
-		type group
-			relations
-				define member: [user, group#member with condition1]
-
-			type document
-				relations
-				define parent: [folder with condition1]
-					define viewer: [group#member] or viewer from parent
-
-		condition condition1(param1: string) {
-			param1 == "ok"
-		}`)
-
-	tuples := []*openfgav1.TupleKey{
-		tuple.NewTupleKeyWithCondition("document:x", "parent", "folder:x", "condition1", tkConditionContext),
-		tuple.NewTupleKeyWithCondition("document:x", "parent", "folder:y", "condition1", nil),
-		tuple.NewTupleKey("folder:y", "viewer", "user:bob"),
-		tuple.NewTupleKey("document:1", "viewer", "group:eng#member"),
-		tuple.NewTupleKey("document:1", "viewer", "group:eng#member"),
-		tuple.NewTupleKeyWithCondition("group:eng", "member", "group:fga#member", "condition1", nil),
-		tuple.NewTupleKey("group:fga", "member", "user:jon"),
-	}
-
-	err = ds.Write(context.Background(), storeID, nil, tuples)
+	ts, err := typesystem.New(model)
 	require.NoError(t, err)
 
-	checker, checkResolverCloser, err := NewOrderedCheckResolvers().Build()
-	require.NoError(t, err)
-	t.Cleanup(checkResolverCloser)
+	reader := &blockingReader{RelationshipTupleReader: ds, relation: "blocked", release: make(chan struct{})}
 
-	typesys, err := typesystem.NewAndValidate(
-		context.Background(),
-		model,
-	)
-	require.NoError(t, err)
+	checker := NewLocalChecker(WithUnionBranchTimeout(50 * time.Millisecond))
+	t.Cleanup(checker.Close)
 
-	ctx := setRequestContext(context.Background(), typesys, ds, nil)
+	t.Run("allowed_by_the_branch_that_completes", func(t *testing.T) {
+		ctx := setRequestContext(context.Background(), ts, reader, nil)
 
-	conditionContext, err := structpb.NewStruct(map[string]interface{}{
-		"param1": "notok",
+		start := time.Now()
+		resp, err := checker.ResolveCheck(ctx, &ResolveCheckRequest{
+			StoreID:         storeID,
+			TupleKey:        tuple.NewTupleKey("document:1", "can_view", "user:jon"),
+			RequestMetadata: NewCheckRequestMetadata(),
+		})
+		require.NoError(t, err)
+		require.True(t, resp.GetAllowed())
+		require.Less(t, time.Since(start), time.Second)
 	})
-	require.NoError(t, err)
 
-	resp, err := checker.ResolveCheck(ctx, &ResolveCheckRequest{
-		StoreID:              storeID,
-		AuthorizationModelID: model.GetId(),
-		TupleKey:             tuple.NewTupleKey("document:x", "parent", "folder:x"),
-		RequestMetadata:      NewCheckRequestMetadata(),
-		Context:              conditionContext,
-	})
-	require.NoError(t, err)
-	require.True(t, resp.Allowed)
+	t.Run("timed_out_branch_is_an_error_when_no_branch_allows", func(t *testing.T) {
+		ctx := setRequestContext(context.Background(), ts, reader, nil)
 
-	resp, err = checker.ResolveCheck(ctx, &ResolveCheckRequest{
-		StoreID:              storeID,
-		AuthorizationModelID: model.GetId(),
-		TupleKey:             tuple.NewTupleKey("document:1", "viewer", "user:jon"),
-		RequestMetadata:      NewCheckRequestMetadata(),
-		Context:              conditionContext,
+		start := time.Now()
+		_, err := checker.ResolveCheck(ctx, &ResolveCheckRequest{
+			StoreID:         storeID,
+			TupleKey:        tuple.NewTupleKey("document:1", "can_view", "user:bob"),
+			RequestMetadata: NewCheckRequestMetadata(),
+		})
+		require.ErrorIs(t, err, ErrUnionBranchTimeout)
+		require.ErrorIs(t, err, context.DeadlineExceeded)
+		require.Less(t, time.Since(start), time.Second)
+
+		// the read of the timed out branch doesn't observe the cancellation, so the branch is still detached
+		require.Positive(t, checker.detachedBranches.Load())
+		require.Positive(t, promtestutil.ToFloat64(detachedUnionBranchesGauge))
 	})
-	require.NoError(t, err)
-	require.False(t, resp.Allowed)
 
-	resp, err = checker.ResolveCheck(ctx, &ResolveCheckRequest{
-		StoreID:              storeID,
-		AuthorizationModelID: model.GetId(),
-		TupleKey:             tuple.NewTupleKey("document:x", "viewer", "user:bob"),
-		RequestMetadata:      NewCheckRequestMetadata(),
-		Context:              conditionContext,
+	t.Run("detached_branches_end_once_they_resolve", func(t *testing.T) {
+		close(reader.release)
+
+		require.Eventually(t, func() bool {
+			return checker.detachedBranches.Load() == 0 && promtestutil.ToFloat64(detachedUnionBranchesGauge) == 0
+		}, time.Second, 10*time.Millisecond)
 	})
-	require.NoError(t, err)
-	require.False(t, resp.Allowed)
 }
 
-func TestCheckDispatchCount(t *testing.T) {
-	ds := memory.New()
-
-	t.Run("dispatch_count_ttu", func(t *testing.T) {
-		storeID := ulid.Make().String()
+// delayingReader delays the reads of tuples by the given duration.
+type delayingReader struct {
+	storage.RelationshipTupleReader
+	delay time.Duration
+}
 
-		model := parser.MustTransformDSLToProto(`
-			model
-				schema 1.1
+func (r *delayingReader) Read(ctx context.Context, store string, tupleKey *openfgav1.TupleKey, options storage.ReadOptions) (storage.TupleIterator, error) {
+	time.Sleep(r.delay)
+	return r.RelationshipTupleReader.Read(ctx, store, tupleKey, options)
+}
 
-			type user
+func TestCheckWithMinRemainingDeadline(t *testing.T) {
+	ds := memory.New()
+	defer ds.Close()
 
-			type folder
-				relations
-					define viewer: [user] or viewer from parent
-					define parent: [folder]
+	storeID := ulid.Make().String()
 
-			type doc
-				relations
-					define viewer: [user] or viewer from parent
-					define parent: [folder]
-			`)
+	err := ds.Write(context.Background(), storeID, nil, []*openfgav1.TupleKey{
+		tuple.NewTupleKey("document:1", "parent", "folder:x"),
+		tuple.NewTupleKey("folder:x", "viewer", "user:jon"),
+	})
+	require.NoError(t, err)
 
-		err := ds.Write(context.Background(), storeID, nil, []*openfgav1.TupleKey{
-			tuple.NewTupleKey("folder:C", "viewer", "user:jon"),
-			tuple.NewTupleKey("folder:B", "parent", "folder:C"),
-			tuple.NewTupleKey("folder:A", "parent", "folder:B"),
-			tuple.NewTupleKey("doc:readme", "parent", "folder:A"),
-		})
-		require.NoError(t, err)
+	// the group userset keeps 'viewer from parent' off the weight 2 resolver, which resolves without dispatching
+	model := testutils.MustTransformDSLToProtoWithID(`
+		model
+			schema 1.1
 
-		checker := NewLocalChecker(WithMaxResolutionDepth(5))
+		type user
+		type group
+			relations
+				define member: [user]
+		type folder
+			relations
+				define blocked: [user]
+				define viewer: [user, group#member] but not blocked
+		type document
+			relations
+				define parent: [folder]
+				define viewer: viewer from parent`)
 
-		typesys, err := typesystem.NewAndValidate(
-			context.Background(),
-			model,
-		)
-		require.NoError(t, err)
+	ts, err := typesystem.New(model)
+	require.NoError(t, err)
 
-		ctx := setRequestContext(context.Background(), typesys, ds, nil)
+	resolve := func(t *testing.T, checker *LocalChecker, timeout time.Duration) (*ResolveCheckResponse, []string, error) {
+		reader := &relationRecordingReader{RelationshipTupleReader: &delayingReader{RelationshipTupleReader: ds, delay: 100 * time.Millisecond}}
 
-		checkRequestMetadata := NewCheckRequestMetadata()
+		ctx := context.Background()
+		if timeout > 0 {
+			var cancel context.CancelFunc
+			ctx, cancel = context.WithTimeout(ctx, timeout)
+			t.Cleanup(cancel)
+		}
+		ctx = setRequestContext(ctx, ts, reader, nil)
 
 		resp, err := checker.ResolveCheck(ctx, &ResolveCheckRequest{
-			StoreID:              storeID,
-			AuthorizationModelID: model.GetId(),
-			TupleKey:             tuple.NewTupleKey("doc:readme", "viewer", "user:jon"),
-			RequestMetadata:      checkRequestMetadata,
+			StoreID:         storeID,
+			TupleKey:        tuple.NewTupleKey("document:1", "viewer", "user:jon"),
+			RequestMetadata: NewCheckRequestMetadata(),
 		})
-		require.NoError(t, err)
-		require.True(t, resp.Allowed)
+		return resp, reader.relations, err
+	}
 
-		require.Equal(t, uint32(1), checkRequestMetadata.DispatchCounter.Load())
+	t.Run("fails_before_resolving_when_the_deadline_is_near", func(t *testing.T) {
+		checker := NewLocalChecker(WithMinRemainingDeadline(time.Second))
+		t.Cleanup(checker.Close)
 
-		t.Run("direct_lookup_requires_no_dispatch", func(t *testing.T) {
-			checkRequestMetadata := NewCheckRequestMetadata()
+		_, relations, err := resolve(t, checker, 500*time.Millisecond)
+		require.ErrorIs(t, err, ErrInsufficientDeadline)
+		require.ErrorIs(t, err, context.DeadlineExceeded)
+		require.Empty(t, relations)
+	})
 
-			resp, err := checker.ResolveCheck(ctx, &ResolveCheckRequest{
-				StoreID:              storeID,
-				AuthorizationModelID: model.GetId(),
-				TupleKey:             tuple.NewTupleKey("doc:readme", "parent", "folder:A"),
-				RequestMetadata:      checkRequestMetadata,
-			})
-			require.NoError(t, err)
-			require.True(t, resp.Allowed)
+	t.Run("fails_before_dispatching_a_subproblem_when_the_deadline_is_near", func(t *testing.T) {
+		checker := NewLocalChecker(WithMinRemainingDeadline(time.Second))
+		t.Cleanup(checker.Close)
 
-			require.Zero(t, checkRequestMetadata.DispatchCounter.Load())
-		})
+		// the read of the parents leaves less than the minimum before the deadline
+		_, relations, err := resolve(t, checker, 1050*time.Millisecond)
+		require.ErrorIs(t, err, ErrInsufficientDeadline)
+		require.NotContains(t, relations, "viewer")
+		require.NotContains(t, relations, "blocked")
 	})
 
-	t.Run("dispatch_count_multiple_direct_userset_lookups", func(t *testing.T) {
-		storeID := ulid.Make().String()
+	t.Run("resolves_with_enough_time_remaining", func(t *testing.T) {
+		checker := NewLocalChecker(WithMinRemainingDeadline(100 * time.Millisecond))
+		t.Cleanup(checker.Close)
 
-		model := parser.MustTransformDSLToProto(`
-			model
-				schema 1.1
+		resp, _, err := resolve(t, checker, 10*time.Second)
+		require.NoError(t, err)
+		require.True(t, resp.GetAllowed())
+	})
 
-			type user
+	t.Run("resolves_without_deadline", func(t *testing.T) {
+		checker := NewLocalChecker(WithMinRemainingDeadline(time.Second))
+		t.Cleanup(checker.Close)
 
-			type group
-				relations
-					define other: [user]
-					define member: [user, group#member] or other
-
-			type document
-				relations
-					define viewer: [group#member]
-			`)
-
-		err := ds.Write(context.Background(), storeID, nil, []*openfgav1.TupleKey{
-			tuple.NewTupleKey("group:1", "member", "user:jon"),
-			tuple.NewTupleKey("group:eng", "member", "group:1#member"),
-			tuple.NewTupleKey("group:eng", "member", "group:2#member"),
-			tuple.NewTupleKey("group:eng", "member", "group:3#member"),
-			tuple.NewTupleKey("document:1", "viewer", "group:eng#member"),
-		})
+		resp, _, err := resolve(t, checker, 0)
 		require.NoError(t, err)
+		require.True(t, resp.GetAllowed())
+	})
+}
 
-		checker := NewLocalChecker(WithMaxResolutionDepth(5))
+// relationRecordingReader records the relations of the tuples that are read.
+type relationRecordingReader struct {
+	storage.RelationshipTupleReader
+	mu        sync.Mutex
+	relations []string
+}
 
-		typesys, err := typesystem.NewAndValidate(
-			context.Background(),
-			model,
-		)
-		require.NoError(t, err)
+func (r *relationRecordingReader) record(relation string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.relations = append(r.relations, relation)
+}
 
-		ctx := setRequestContext(context.Background(), typesys, ds, nil)
-		checkRequestMetadata := NewCheckRequestMetadata()
+func (r *relationRecordingReader) ReadUserTuple(ctx context.Context, store string, tupleKey *openfgav1.TupleKey, options storage.ReadUserTupleOptions) (*openfgav1.Tuple, error) {
+	r.record(tupleKey.GetRelation())
+	return r.RelationshipTupleReader.ReadUserTuple(ctx, store, tupleKey, options)
+}
 
-		resp, err := checker.ResolveCheck(ctx, &ResolveCheckRequest{
-			StoreID:              storeID,
-			AuthorizationModelID: model.GetId(),
-			TupleKey:             tuple.NewTupleKey("document:1", "viewer", "user:jon"),
-			RequestMetadata:      checkRequestMetadata,
-		})
-		require.NoError(t, err)
-		require.True(t, resp.Allowed)
+func (r *relationRecordingReader) ReadUsersetTuples(ctx context.Context, store string, filter storage.ReadUsersetTuplesFilter, options storage.ReadUsersetTuplesOptions) (storage.TupleIterator, error) {
+	r.record(filter.Relation)
+	return r.RelationshipTupleReader.ReadUsersetTuples(ctx, store, filter, options)
+}
 
-		require.GreaterOrEqual(t, checkRequestMetadata.DispatchCounter.Load(), uint32(1))
-		require.LessOrEqual(t, checkRequestMetadata.DispatchCounter.Load(), uint32(4))
+func (r *relationRecordingReader) ReadStartingWithUser(ctx context.Context, store string, filter storage.ReadStartingWithUserFilter, options storage.ReadStartingWithUserOptions) (storage.TupleIterator, error) {
+	r.record(filter.Relation)
+	return r.RelationshipTupleReader.ReadStartingWithUser(ctx, store, filter, options)
+}
 
-		checkRequestMetadata = NewCheckRequestMetadata()
+func TestCheckWithEarlyDeny(t *testing.T) {
+	ds := memory.New()
+	defer ds.Close()
 
-		resp, err = checker.ResolveCheck(ctx, &ResolveCheckRequest{
-			StoreID:              storeID,
-			AuthorizationModelID: model.GetId(),
-			TupleKey:             tuple.NewTupleKey("document:1", "viewer", "user:other"),
-			RequestMetadata:      checkRequestMetadata,
-		})
-		require.NoError(t, err)
-		require.False(t, resp.Allowed)
+	storeID := ulid.Make().String()
 
-		require.Equal(t, uint32(1), checkRequestMetadata.DispatchCounter.Load())
+	err := ds.Write(context.Background(), storeID, nil, []*openfgav1.TupleKey{
+		tuple.NewTupleKey("document:1", "viewer", "user:jon"),
+		tuple.NewTupleKey("document:1", "viewer", "user:bob"),
+		tuple.NewTupleKey("document:1", "blocked", "user:bob"),
 	})
-	t.Run("dispatch_count_computed_userset_lookups", func(t *testing.T) {
-		storeID := ulid.Make().String()
-
-		model := parser.MustTransformDSLToProto(`
-			model
-				schema 1.1
+	require.NoError(t, err)
 
-			type user
+	model := testutils.MustTransformDSLToProtoWithID(`
+		model
+			schema 1.1
 
-			type document
-				relations
-					define owner: [user]
-					define editor: [user] or owner`)
+		type user
+		type group
+			relations
+				define member: [user]
+		type document
+			relations
+				define blocked: [user]
+				define viewer: [user, group#member]
+				define can_view: viewer but not blocked`)
 
-		err := ds.Write(context.Background(), storeID, nil, []*openfgav1.TupleKey{
-			tuple.NewTupleKey("document:1", "owner", "user:jon"),
-			tuple.NewTupleKey("document:2", "editor", "user:will"),
-		})
-		require.NoError(t, err)
+	ts, err := typesystem.New(model)
+	require.NoError(t, err)
 
-		checker := NewLocalChecker(WithMaxResolutionDepth(5))
+	checker := NewLocalChecker(WithEarlyDeny(true))
+	t.Cleanup(checker.Close)
 
-		typesys, err := typesystem.NewAndValidate(
-			context.Background(),
-			model,
-		)
-		require.NoError(t, err)
+	check := func(t *testing.T, user string) (bool, []string) {
+		reader := &relationRecordingReader{RelationshipTupleReader: ds}
+		ctx := setRequestContext(context.Background(), ts, reader, nil)
 
-		ctx := setRequestContext(context.Background(), typesys, ds, nil)
-		checkRequestMetadata := NewCheckRequestMetadata()
 		resp, err := checker.ResolveCheck(ctx, &ResolveCheckRequest{
-			StoreID:              storeID,
-			AuthorizationModelID: model.GetId(),
-			TupleKey:             tuple.NewTupleKey("document:1", "owner", "user:jon"),
-			RequestMetadata:      checkRequestMetadata,
+			StoreID:         storeID,
+			TupleKey:        tuple.NewTupleKey("document:1", "can_view", user),
+			RequestMetadata: NewCheckRequestMetadata(),
 		})
 		require.NoError(t, err)
-		require.True(t, resp.Allowed)
+		return resp.GetAllowed(), reader.relations
+	}
 
-		require.Zero(t, checkRequestMetadata.DispatchCounter.Load())
+	t.Run("deny_tuple_short_circuits_before_the_base_is_read", func(t *testing.T) {
+		allowed, relations := check(t, "user:bob")
+		require.False(t, allowed)
+		require.Equal(t, []string{"blocked"}, relations)
+	})
 
-		checkRequestMetadata = NewCheckRequestMetadata()
+	t.Run("base_is_resolved_without_a_deny_tuple", func(t *testing.T) {
+		allowed, relations := check(t, "user:jon")
+		require.True(t, allowed)
+		require.Equal(t, "blocked", relations[0])
+		require.Contains(t, relations, "viewer")
+	})
 
-		resp, err = checker.ResolveCheck(ctx, &ResolveCheckRequest{
-			StoreID:              storeID,
-			AuthorizationModelID: model.GetId(),
-			TupleKey:             tuple.NewTupleKey("document:2", "editor", "user:will"),
-			RequestMetadata:      checkRequestMetadata,
-		})
-		require.NoError(t, err)
-		require.True(t, resp.Allowed)
+	t.Run("denied_without_base", func(t *testing.T) {
+		allowed, _ := check(t, "user:maria")
+		require.False(t, allowed)
+	})
+}
 
-		require.LessOrEqual(t, checkRequestMetadata.DispatchCounter.Load(), uint32(1))
+// concurrencyCountingReader records the maximum number of reads that are in flight at once.
+type concurrencyCountingReader struct {
+	storage.RelationshipTupleReader
+	inflight    atomic.Int32
+	maxInflight atomic.Int32
+}
 
-		checkRequestMetadata = NewCheckRequestMetadata()
-		resp, err = checker.ResolveCheck(ctx, &ResolveCheckRequest{
-			StoreID:              storeID,
-			AuthorizationModelID: model.GetId(),
-			TupleKey:             tuple.NewTupleKey("document:2", "editor", "user:jon"),
-			RequestMetadata:      checkRequestMetadata,
-		})
-		require.NoError(t, err)
-		require.False(t, resp.Allowed)
-		require.Equal(t, uint32(0), checkRequestMetadata.DispatchCounter.Load())
-	})
+func (r *concurrencyCountingReader) track() func() {
+	n := r.inflight.Add(1)
+	for {
+		current := r.maxInflight.Load()
+		if n <= current || r.maxInflight.CompareAndSwap(current, n) {
+			break
+		}
+	}
+	// give concurrent reads the chance to overlap
+	time.Sleep(5 * time.Millisecond)
+	return func() { r.inflight.Add(-1) }
 }
 
-func TestUnionCheckFuncReducer(t *testing.T) {
+func (r *concurrencyCountingReader) ReadUserTuple(ctx context.Context, store string, tupleKey *openfgav1.TupleKey, options storage.ReadUserTupleOptions) (*openfgav1.Tuple, error) {
+	defer r.track()()
+	return r.RelationshipTupleReader.ReadUserTuple(ctx, store, tupleKey, options)
+}
+
+func (r *concurrencyCountingReader) ReadUsersetTuples(ctx context.Context, store string, filter storage.ReadUsersetTuplesFilter, options storage.ReadUsersetTuplesOptions) (storage.TupleIterator, error) {
+	defer r.track()()
+	return r.RelationshipTupleReader.ReadUsersetTuples(ctx, store, filter, options)
+}
+
+func (r *concurrencyCountingReader) Read(ctx context.Context, store string, tupleKey *openfgav1.TupleKey, options storage.ReadOptions) (storage.TupleIterator, error) {
+	defer r.track()()
+	return r.RelationshipTupleReader.Read(ctx, store, tupleKey, options)
+}
+
+func TestCheckWithResolutionStrategy(t *testing.T) {
 	t.Cleanup(func() {
 		goleak.VerifyNone(t)
 	})
 
-	ctx := context.Background()
+	ds := memory.New()
+	t.Cleanup(ds.Close)
 
-	concurrencyLimit := 10
+	storeID := ulid.Make().String()
 
-	falseHandler := func(context.Context) (*ResolveCheckResponse, error) {
-		return &ResolveCheckResponse{
-			Allowed: false,
-		}, nil
-	}
+	err := ds.Write(context.Background(), storeID, nil, []*openfgav1.TupleKey{
+		tuple.NewTupleKey("document:1", "owner", "user:jon"),
+		tuple.NewTupleKey("document:1", "viewer", "group:eng#member"),
+		tuple.NewTupleKey("document:1", "viewer", "group:sales#member"),
+		tuple.NewTupleKey("document:1", "parent", "folder:x"),
+		tuple.NewTupleKey("document:1", "blocked", "user:bob"),
+		tuple.NewTupleKey("group:sales", "member", "user:maria"),
+		tuple.NewTupleKey("group:eng", "member", "user:bob"),
+		tuple.NewTupleKey("folder:x", "viewer", "user:anne"),
+	})
+	require.NoError(t, err)
 
-	trueHandler := func(context.Context) (*ResolveCheckResponse, error) {
-		return &ResolveCheckResponse{
-			Allowed: true,
-		}, nil
+	model := testutils.MustTransformDSLToProtoWithID(`
+		model
+			schema 1.1
+
+		type user
+		type group
+			relations
+				define member: [user]
+		type folder
+			relations
+				define viewer: [user]
+		type document
+			relations
+				define blocked: [user]
+				define owner: [user]
+				define editor: [user]
+				define parent: [folder]
+				define viewer: [user, group#member] or editor or owner or viewer from parent
+				define can_view: viewer but not blocked
+				define can_edit: editor and owner`)
+
+	ts, err := typesystem.New(model)
+	require.NoError(t, err)
+
+	checks := []struct {
+		tupleKey *openfgav1.TupleKey
+		allowed  bool
+	}{
+		{tuple.NewTupleKey("document:1", "viewer", "user:jon"), true},
+		{tuple.NewTupleKey("document:1", "viewer", "user:maria"), true},
+		{tuple.NewTupleKey("document:1", "viewer", "user:anne"), true},
+		{tuple.NewTupleKey("document:1", "viewer", "user:unknown"), false},
+		{tuple.NewTupleKey("document:1", "can_view", "user:maria"), true},
+		{tuple.NewTupleKey("document:1", "can_view", "user:bob"), false},
+		{tuple.NewTupleKey("document:1", "can_edit", "user:jon"), false},
 	}
 
-	t.Run("no_handlers_return_allowed_false", func(t *testing.T) {
-		resp, err := union(ctx, concurrencyLimit)
-		require.NoError(t, err)
-		require.NotNil(t, resp)
-		require.False(t, resp.GetAllowed())
-		require.False(t, resp.GetCycleDetected())
-	})
+	resolve := func(t *testing.T, strategy serverconfig.ResolutionStrategy) ([]bool, int32) {
+		checker := NewLocalChecker(WithResolutionStrategy(strategy))
+		t.Cleanup(checker.Close)
 
-	t.Run("true_or_true_return_true", func(t *testing.T) {
-		resp, err := union(ctx, concurrencyLimit, trueHandler, trueHandler)
-		require.NoError(t, err)
-		require.NotNil(t, resp)
-		require.True(t, resp.GetAllowed())
-		require.False(t, resp.GetCycleDetected())
-	})
+		reader := &concurrencyCountingReader{RelationshipTupleReader: ds}
 
-	t.Run("true_or_false_return_true", func(t *testing.T) {
-		resp, err := union(ctx, concurrencyLimit, trueHandler, falseHandler)
-		require.NoError(t, err)
-		require.NotNil(t, resp)
-		require.True(t, resp.GetAllowed())
-		require.False(t, resp.GetCycleDetected())
-	})
+		var decisions []bool
+		for _, check := range checks {
+			ctx := setRequestContext(context.Background(), ts, reader, nil)
+			resp, err := checker.ResolveCheck(ctx, &ResolveCheckRequest{
+				StoreID:         storeID,
+				TupleKey:        check.tupleKey,
+				RequestMetadata: NewCheckRequestMetadata(),
+			})
+			require.NoError(t, err)
+			decisions = append(decisions, resp.GetAllowed())
+		}
+		return decisions, reader.maxInflight.Load()
+	}
 
-	t.Run("false_or_true_return_true", func(t *testing.T) {
-		resp, err := union(ctx, concurrencyLimit, falseHandler, trueHandler)
-		require.NoError(t, err)
-		require.NotNil(t, resp)
-		require.True(t, resp.GetAllowed())
-		require.False(t, resp.GetCycleDetected())
-	})
+	breadthFirstDecisions, breadthFirstMaxReads := resolve(t, serverconfig.BreadthFirstResolution)
+	depthFirstDecisions, depthFirstMaxReads := resolve(t, serverconfig.DepthFirstResolution)
 
-	t.Run("false_or_false_return_false", func(t *testing.T) {
-		resp, err := union(ctx, concurrencyLimit, falseHandler, falseHandler)
-		require.NoError(t, err)
-		require.NotNil(t, resp)
-		require.False(t, resp.GetAllowed())
-		require.False(t, resp.GetCycleDetected())
-	})
+	for i, check := range checks {
+		require.Equal(t, check.allowed, breadthFirstDecisions[i], tuple.TupleKeyToString(check.tupleKey))
+	}
+	require.Equal(t, breadthFirstDecisions, depthFirstDecisions)
 
-	t.Run("true_or_err_return_true", func(t *testing.T) {
-		resp, err := union(ctx, concurrencyLimit, trueHandler, generalErrorHandler)
-		require.NoError(t, err)
-		require.NotNil(t, resp)
-		require.True(t, resp.GetAllowed())
-		require.False(t, resp.GetCycleDetected())
-	})
+	require.Equal(t, int32(1), depthFirstMaxReads)
+	require.Greater(t, breadthFirstMaxReads, depthFirstMaxReads)
+}
 
-	t.Run("true_or_errResolutionDepth_return_true", func(t *testing.T) {
-		resp, err := union(ctx, concurrencyLimit, trueHandler, depthExceededHandler)
-		require.NoError(t, err)
-		require.NotNil(t, resp)
-		require.True(t, resp.GetAllowed())
-		require.False(t, resp.GetCycleDetected())
-	})
+func TestCheckConditions(t *testing.T) {
+	ds := memory.New()
 
-	t.Run("true_or_cycle_return_true", func(t *testing.T) {
-		resp, err := union(ctx, concurrencyLimit, trueHandler, cyclicErrorHandler)
-		require.NoError(t, err)
-		require.NotNil(t, resp)
-		require.True(t, resp.GetAllowed())
-		require.False(t, resp.GetCycleDetected())
-	})
+	storeID := ulid.Make().String()
 
-	t.Run("false_or_err_return_err", func(t *testing.T) {
-		resp, err := union(ctx, concurrencyLimit, falseHandler, generalErrorHandler)
-		require.EqualError(t, err, simulatedDBErrorMessage)
-		require.Nil(t, resp)
+	tkConditionContext, err := structpb.NewStruct(map[string]interface{}{
+		"param1": "ok",
 	})
+	require.NoError(t, err)
 
-	t.Run("false_or_errResolutionDepth_return_err", func(t *testing.T) {
-		resp, err := union(ctx, concurrencyLimit, falseHandler, depthExceededHandler)
-		require.ErrorIs(t, err, ErrResolutionDepthExceeded)
-		require.Nil(t, resp)
-	})
+	model := parser.MustTransformDSLToProto(`
+		model
+			schema 1.1
 
-	t.Run("false_or_cycle_return_false", func(t *testing.T) {
-		resp, err := union(ctx, concurrencyLimit, falseHandler, cyclicErrorHandler)
-		require.NoError(t, err)
-		require.NotNil(t, resp)
-		require.False(t, resp.GetAllowed())
-		require.True(t, resp.GetCycleDetected())
-	})
+		type user
 
-	t.Run("err_or_true_return_true", func(t *testing.T) {
-		resp, err := union(ctx, concurrencyLimit, generalErrorHandler, trueHandler)
-		require.NoError(t, err)
-		require.NotNil(t, resp)
-		require.True(t, resp.GetAllowed())
-		require.False(t, resp.GetCycleDetected())
-	})
+		type folder
+			relations
+				define viewer: [user]
 
-	t.Run("cycle_or_true_return_true", func(t *testing.T) {
-		resp, err := union(ctx, concurrencyLimit, cyclicErrorHandler, trueHandler)
-		require.NoError(t, err)
-		require.NotNil(t, resp)
-		require.True(t, resp.GetAllowed())
-		require.False(t, resp.GetCycleDetected())
-	})
+		type group
+			relations
+				define member: [user, group#member with condition1]
 
-	t.Run("err_or_false_return_err", func(t *testing.T) {
-		resp, err := union(ctx, concurrencyLimit, generalErrorHandler, falseHandler)
-		require.EqualError(t, err, simulatedDBErrorMessage)
-		require.Nil(t, resp)
-	})
+			type document
+				relations
+				define parent: [folder with condition1]
+					define viewer: [group#member] or viewer from parent
 
-	t.Run("errResolutionDepth_or_false_return_err", func(t *testing.T) {
-		resp, err := union(ctx, concurrencyLimit, depthExceededHandler, falseHandler)
-		require.ErrorIs(t, err, ErrResolutionDepthExceeded)
-		require.Nil(t, resp)
-	})
+		condition condition1(param1: string) {
+			param1 == "ok"
+		}`)
 
-	t.Run("cycle_or_false_return_false", func(t *testing.T) {
-		resp, err := union(ctx, concurrencyLimit, cyclicErrorHandler, falseHandler)
-		require.NoError(t, err)
-		require.NotNil(t, resp)
-		require.False(t, resp.GetAllowed())
-		require.True(t, resp.GetCycleDetected())
-	})
+	tuples := []*openfgav1.TupleKey{
+		tuple.NewTupleKeyWithCondition("document:x", "parent", "folder:x", "condition1", tkConditionContext),
+		tuple.NewTupleKeyWithCondition("document:x", "parent", "folder:y", "condition1", nil),
+		tuple.NewTupleKey("folder:y", "viewer", "user:bob"),
+		tuple.NewTupleKey("document:1", "viewer", "group:eng#member"),
+		tuple.NewTupleKey("document:1", "viewer", "group:eng#member"),
+		tuple.NewTupleKeyWithCondition("group:eng", "member", "group:fga#member", "condition1", nil),
+		tuple.NewTupleKey("group:fga", "member", "user:jon"),
+	}
 
-	t.Run("cycle_or_err_return_err", func(t *testing.T) {
-		resp, err := union(ctx, concurrencyLimit, cyclicErrorHandler, generalErrorHandler)
-		require.EqualError(t, err, simulatedDBErrorMessage)
-		require.Nil(t, resp)
-	})
+	err = ds.Write(context.Background(), storeID, nil, tuples)
+	require.NoError(t, err)
 
-	t.Run("cycle_or_errResolutionDepth_return_err", func(t *testing.T) {
-		resp, err := union(ctx, concurrencyLimit, cyclicErrorHandler, depthExceededHandler)
-		require.ErrorIs(t, err, ErrResolutionDepthExceeded)
-		require.Nil(t, resp)
-	})
+	checker, checkResolverCloser, err := NewOrderedCheckResolvers().Build()
+	require.NoError(t, err)
+	t.Cleanup(checkResolverCloser)
 
-	t.Run("err_or_cycle_return_err", func(t *testing.T) {
-		resp, err := union(ctx, concurrencyLimit, generalErrorHandler, cyclicErrorHandler)
-		require.EqualError(t, err, simulatedDBErrorMessage)
-		require.Nil(t, resp)
-	})
+	typesys, err := typesystem.NewAndValidate(
+		context.Background(),
+		model,
+	)
+	require.NoError(t, err)
 
-	t.Run("errResolutionDepth_or_cycle_return_err", func(t *testing.T) {
-		resp, err := union(ctx, concurrencyLimit, depthExceededHandler, cyclicErrorHandler)
-		require.ErrorIs(t, err, ErrResolutionDepthExceeded)
-		require.Nil(t, resp)
-	})
+	ctx := setRequestContext(context.Background(), typesys, ds, nil)
 
-	t.Run("cycle_or_cycle_return_false", func(t *testing.T) {
-		resp, err := union(ctx, concurrencyLimit, cyclicErrorHandler, cyclicErrorHandler)
-		require.NoError(t, err)
-		require.NotNil(t, resp)
-		require.False(t, resp.GetAllowed())
-		require.True(t, resp.GetCycleDetected())
+	conditionContext, err := structpb.NewStruct(map[string]interface{}{
+		"param1": "notok",
 	})
+	require.NoError(t, err)
 
-	t.Run("false_or_cycle_or_err", func(t *testing.T) {
-		resp, err := union(ctx, concurrencyLimit, falseHandler, cyclicErrorHandler, generalErrorHandler)
-		require.EqualError(t, err, simulatedDBErrorMessage)
-		require.Nil(t, resp)
+	resp, err := checker.ResolveCheck(ctx, &ResolveCheckRequest{
+		StoreID:              storeID,
+		AuthorizationModelID: model.GetId(),
+		TupleKey:             tuple.NewTupleKey("document:x", "parent", "folder:x"),
+		RequestMetadata:      NewCheckRequestMetadata(),
+		Context:              conditionContext,
 	})
+	require.NoError(t, err)
+	require.True(t, resp.Allowed)
 
-	t.Run("err_or_err_return_err", func(t *testing.T) {
-		resp, err := union(ctx, concurrencyLimit, falseHandler, generalErrorHandler, generalErrorHandler)
-		require.ErrorContains(t, err, simulatedDBErrorMessage)
-		require.Nil(t, resp)
+	resp, err = checker.ResolveCheck(ctx, &ResolveCheckRequest{
+		StoreID:              storeID,
+		AuthorizationModelID: model.GetId(),
+		TupleKey:             tuple.NewTupleKey("document:1", "viewer", "user:jon"),
+		RequestMetadata:      NewCheckRequestMetadata(),
+		Context:              conditionContext,
 	})
+	require.NoError(t, err)
+	require.False(t, resp.Allowed)
 
-	t.Run("errResolutionDepth_or_errResolutionDepth_return_errResolutionDepth", func(t *testing.T) {
-		resp, err := union(ctx, concurrencyLimit, falseHandler, depthExceededHandler, depthExceededHandler)
-		require.ErrorIs(t, err, ErrResolutionDepthExceeded)
-		require.Nil(t, resp)
+	resp, err = checker.ResolveCheck(ctx, &ResolveCheckRequest{
+		StoreID:              storeID,
+		AuthorizationModelID: model.GetId(),
+		TupleKey:             tuple.NewTupleKey("document:x", "viewer", "user:bob"),
+		RequestMetadata:      NewCheckRequestMetadata(),
+		Context:              conditionContext,
 	})
+	require.NoError(t, err)
+	require.False(t, resp.Allowed)
+}
 
-	t.Run("should_return_allowed_true_if_truthy_handler_evaluated_before_handler_cancels_via_context", func(t *testing.T) {
-		ctx, cancel := context.WithTimeout(ctx, 10*time.Millisecond)
-		t.Cleanup(cancel)
-
-		resp, err := union(ctx, concurrencyLimit, trueHandler)
-		require.NoError(t, err)
-		require.True(t, resp.GetAllowed())
-	})
-	t.Run("should_handle_cancellations_through_context", func(t *testing.T) {
-		ctx, cancel := context.WithTimeout(ctx, 10*time.Millisecond)
-		t.Cleanup(cancel)
+func TestCheckDispatchCount(t *testing.T) {
+	ds := memory.New()
 
-		slowHandler := func(context.Context) (*ResolveCheckResponse, error) {
-			time.Sleep(50 * time.Millisecond)
-			return &ResolveCheckResponse{}, nil
-		}
+	t.Run("dispatch_count_ttu", func(t *testing.T) {
+		storeID := ulid.Make().String()
 
-		resp, err := union(ctx, concurrencyLimit, slowHandler, falseHandler)
-		require.ErrorIs(t, err, context.DeadlineExceeded)
-		require.Nil(t, resp)
-	})
+		model := parser.MustTransformDSLToProto(`
+			model
+				schema 1.1
 
-	t.Run("should_handle_context_timeouts_even_with_eventual_truthy_handler", func(t *testing.T) {
-		ctx, cancel := context.WithTimeout(ctx, 10*time.Millisecond)
-		t.Cleanup(cancel)
+			type user
 
-		trueHandler := func(context.Context) (*ResolveCheckResponse, error) {
-			time.Sleep(25 * time.Millisecond)
-			return &ResolveCheckResponse{
-				Allowed: true,
-			}, nil
-		}
+			type folder
+				relations
+					define viewer: [user] or viewer from parent
+					define parent: [folder]
 
-		resp, err := union(ctx, concurrencyLimit, trueHandler, falseHandler)
-		require.ErrorIs(t, err, context.DeadlineExceeded)
-		require.Nil(t, resp)
-	})
+			type doc
+				relations
+					define viewer: [user] or viewer from parent
+					define parent: [folder]
+			`)
 
-	t.Run("should_return_true_with_slow_falsey_handler", func(t *testing.T) {
-		ctx, cancel := context.WithTimeout(ctx, 10*time.Millisecond)
-		t.Cleanup(cancel)
+		err := ds.Write(context.Background(), storeID, nil, []*openfgav1.TupleKey{
+			tuple.NewTupleKey("folder:C", "viewer", "user:jon"),
+			tuple.NewTupleKey("folder:B", "parent", "folder:C"),
+			tuple.NewTupleKey("folder:A", "parent", "folder:B"),
+			tuple.NewTupleKey("doc:readme", "parent", "folder:A"),
+		})
+		require.NoError(t, err)
 
-		falseSlowHandler := func(context.Context) (*ResolveCheckResponse, error) {
-			time.Sleep(25 * time.Millisecond)
-			return &ResolveCheckResponse{
-				Allowed: false,
-			}, nil
-		}
+		checker := NewLocalChecker(WithMaxResolutionDepth(5))
 
-		resp, err := union(ctx, concurrencyLimit, trueHandler, falseSlowHandler)
+		typesys, err := typesystem.NewAndValidate(
+			context.Background(),
+			model,
+		)
 		require.NoError(t, err)
-		require.True(t, resp.GetAllowed())
-	})
 
-	t.Run("return_error_if_context_cancelled_before_resolution", func(t *testing.T) {
-		ctx, cancel := context.WithCancel(context.Background())
-		t.Cleanup(cancel)
+		ctx := setRequestContext(context.Background(), typesys, ds, nil)
 
-		var wg sync.WaitGroup
+		checkRequestMetadata := NewCheckRequestMetadata()
 
-		wg.Add(1)
-		go func() {
-			defer wg.Done()
-			time.Sleep(10 * time.Millisecond)
-			cancel()
-		}()
+		resp, err := checker.ResolveCheck(ctx, &ResolveCheckRequest{
+			StoreID:              storeID,
+			AuthorizationModelID: model.GetId(),
+			TupleKey:             tuple.NewTupleKey("doc:readme", "viewer", "user:jon"),
+			RequestMetadata:      checkRequestMetadata,
+		})
+		require.NoError(t, err)
+		require.True(t, resp.Allowed)
 
-		slowTrueHandler := func(context.Context) (*ResolveCheckResponse, error) {
-			time.Sleep(50 * time.Millisecond)
-			return &ResolveCheckResponse{
-				Allowed: true,
-			}, nil
-		}
+		require.Equal(t, uint32(1), checkRequestMetadata.DispatchCounter.Load())
 
-		resp, err := union(ctx, concurrencyLimit, slowTrueHandler)
-		require.ErrorIs(t, err, context.Canceled)
-		require.Nil(t, resp)
+		t.Run("direct_lookup_requires_no_dispatch", func(t *testing.T) {
+			checkRequestMetadata := NewCheckRequestMetadata()
 
-		wg.Wait() // just to make sure to avoid test leaks
-	})
+			resp, err := checker.ResolveCheck(ctx, &ResolveCheckRequest{
+				StoreID:              storeID,
+				AuthorizationModelID: model.GetId(),
+				TupleKey:             tuple.NewTupleKey("doc:readme", "parent", "folder:A"),
+				RequestMetadata:      checkRequestMetadata,
+			})
+			require.NoError(t, err)
+			require.True(t, resp.Allowed)
 
-	t.Run("return_allowed:true_if_truthy_handler_evaluated_before_context_cancelled", func(t *testing.T) {
-		ctx, cancel := context.WithCancel(context.Background())
-		t.Cleanup(cancel)
+			require.Zero(t, checkRequestMetadata.DispatchCounter.Load())
+		})
+	})
 
-		var wg sync.WaitGroup
-
-		wg.Add(1)
-		go func() {
-			defer wg.Done()
-			time.Sleep(10 * time.Millisecond)
-			cancel()
-		}()
-
-		resp, err := intersection(ctx, concurrencyLimit, trueHandler)
-		require.NoError(t, err)
-		require.True(t, resp.GetAllowed())
-
-		wg.Wait() // just to make sure to avoid test leaks
-	})
-
-	t.Run("should_error_if_handler_panics", func(t *testing.T) {
-		panicHandler := func(context.Context) (*ResolveCheckResponse, error) {
-			panic(panicErr)
-		}
-
-		resp, err := union(ctx, concurrencyLimit, panicHandler)
-		require.ErrorContains(t, err, panicErr)
-		require.ErrorIs(t, err, ErrPanic)
-		require.Nil(t, resp)
-	})
-}
-
-func TestResolveCheckCallsPathExists(t *testing.T) {
-	ds := memory.New()
-	t.Cleanup(ds.Close)
-
-	checker := NewLocalChecker()
-	t.Cleanup(checker.Close)
-
-	ctrl := gomock.NewController(t)
-	t.Cleanup(ctrl.Finish)
-
-	mockDelegate := NewMockCheckResolver(ctrl)
-	checker.SetDelegate(mockDelegate)
-
-	// assert that we never call dispatch
-	mockDelegate.EXPECT().ResolveCheck(gomock.Any(), gomock.Any()).Times(0)
+	t.Run("dispatch_count_multiple_direct_userset_lookups", func(t *testing.T) {
+		storeID := ulid.Make().String()
 
-	storeID := ulid.Make().String()
-	model := testutils.MustTransformDSLToProtoWithID(`
+		model := parser.MustTransformDSLToProto(`
 			model
 				schema 1.1
-			type user
-			type document
-				relations
-					define x: y
-					define y: x
-		`)
 
-	ts, err := typesystem.New(model)
-	require.NoError(t, err)
+			type user
 
-	ctx := setRequestContext(context.Background(), ts, ds, nil)
+			type group
+				relations
+					define other: [user]
+					define member: [user, group#member] or other
 
-	resp, err := checker.ResolveCheck(ctx, &ResolveCheckRequest{
-		StoreID:              storeID,
-		AuthorizationModelID: model.GetId(),
-		TupleKey:             tuple.NewTupleKey("document:1", "y", "user:maria"),
-		RequestMetadata:      NewCheckRequestMetadata(),
-	})
-	require.NoError(t, err)
-	require.NotNil(t, resp)
-	require.False(t, resp.GetAllowed())
+			type document
+				relations
+					define viewer: [group#member]
+			`)
 
-	// Without PathExists check, this would cycle
-	require.False(t, resp.GetCycleDetected())
-}
+		err := ds.Write(context.Background(), storeID, nil, []*openfgav1.TupleKey{
+			tuple.NewTupleKey("group:1", "member", "user:jon"),
+			tuple.NewTupleKey("group:eng", "member", "group:1#member"),
+			tuple.NewTupleKey("group:eng", "member", "group:2#member"),
+			tuple.NewTupleKey("group:eng", "member", "group:3#member"),
+			tuple.NewTupleKey("document:1", "viewer", "group:eng#member"),
+		})
+		require.NoError(t, err)
 
-func TestResolveCheckCallsCycleDetection(t *testing.T) {
-	ds := memory.New()
-	t.Cleanup(ds.Close)
+		checker := NewLocalChecker(WithMaxResolutionDepth(5))
 
-	checker := NewLocalChecker()
-	t.Cleanup(checker.Close)
+		typesys, err := typesystem.NewAndValidate(
+			context.Background(),
+			model,
+		)
+		require.NoError(t, err)
 
-	ctrl := gomock.NewController(t)
-	t.Cleanup(ctrl.Finish)
+		ctx := setRequestContext(context.Background(), typesys, ds, nil)
+		checkRequestMetadata := NewCheckRequestMetadata()
 
-	mockDelegate := NewMockCheckResolver(ctrl)
-	checker.SetDelegate(mockDelegate)
+		resp, err := checker.ResolveCheck(ctx, &ResolveCheckRequest{
+			StoreID:              storeID,
+			AuthorizationModelID: model.GetId(),
+			TupleKey:             tuple.NewTupleKey("document:1", "viewer", "user:jon"),
+			RequestMetadata:      checkRequestMetadata,
+		})
+		require.NoError(t, err)
+		require.True(t, resp.Allowed)
 
-	// assert that we never call dispatch
-	mockDelegate.EXPECT().ResolveCheck(gomock.Any(), gomock.Any()).Times(0)
+		require.GreaterOrEqual(t, checkRequestMetadata.DispatchCounter.Load(), uint32(1))
+		require.LessOrEqual(t, checkRequestMetadata.DispatchCounter.Load(), uint32(4))
 
-	t.Run("returns_true_if_path_visited", func(t *testing.T) {
-		cyclicalTuple := tuple.NewTupleKey("document:1", "viewer", "user:maria")
+		checkRequestMetadata = NewCheckRequestMetadata()
 
-		resp, err := checker.ResolveCheck(context.Background(), &ResolveCheckRequest{
-			StoreID:         ulid.Make().String(),
-			TupleKey:        cyclicalTuple, // here
-			RequestMetadata: NewCheckRequestMetadata(),
-			VisitedPaths: map[string]struct{}{
-				tuple.TupleKeyToString(cyclicalTuple): {}, // and here
-			},
+		resp, err = checker.ResolveCheck(ctx, &ResolveCheckRequest{
+			StoreID:              storeID,
+			AuthorizationModelID: model.GetId(),
+			TupleKey:             tuple.NewTupleKey("document:1", "viewer", "user:other"),
+			RequestMetadata:      checkRequestMetadata,
 		})
-
 		require.NoError(t, err)
-		require.NotNil(t, resp)
-		require.False(t, resp.GetAllowed())
-		require.True(t, resp.GetCycleDetected())
-		require.NotNil(t, resp.ResolutionMetadata)
-	})
+		require.False(t, resp.Allowed)
 
-	t.Run("returns_true_if_cycle_exists", func(t *testing.T) {
+		require.Equal(t, uint32(1), checkRequestMetadata.DispatchCounter.Load())
+	})
+	t.Run("dispatch_count_computed_userset_lookups", func(t *testing.T) {
 		storeID := ulid.Make().String()
-		model := testutils.MustTransformDSLToProtoWithID(`
+
+		model := parser.MustTransformDSLToProto(`
 			model
 				schema 1.1
+
 			type user
+
 			type document
 				relations
-					define x: y
-					define y: x`)
+					define owner: [user]
+					define editor: [user] or owner`)
 
-		ts, err := typesystem.New(model)
+		err := ds.Write(context.Background(), storeID, nil, []*openfgav1.TupleKey{
+			tuple.NewTupleKey("document:1", "owner", "user:jon"),
+			tuple.NewTupleKey("document:2", "editor", "user:will"),
+		})
 		require.NoError(t, err)
 
-		ctx := setRequestContext(context.Background(), ts, ds, nil)
+		checker := NewLocalChecker(WithMaxResolutionDepth(5))
+
+		typesys, err := typesystem.NewAndValidate(
+			context.Background(),
+			model,
+		)
+		require.NoError(t, err)
 
+		ctx := setRequestContext(context.Background(), typesys, ds, nil)
+		checkRequestMetadata := NewCheckRequestMetadata()
 		resp, err := checker.ResolveCheck(ctx, &ResolveCheckRequest{
 			StoreID:              storeID,
 			AuthorizationModelID: model.GetId(),
-			TupleKey:             tuple.NewTupleKey("document:1", "y", "document:2#x"),
-			RequestMetadata:      NewCheckRequestMetadata(),
+			TupleKey:             tuple.NewTupleKey("document:1", "owner", "user:jon"),
+			RequestMetadata:      checkRequestMetadata,
 		})
 		require.NoError(t, err)
-		require.NotNil(t, resp)
-		require.False(t, resp.GetAllowed())
-		require.True(t, resp.GetCycleDetected())
-	})
-}
+		require.True(t, resp.Allowed)
 
-func TestDispatch(t *testing.T) {
-	ctrl := gomock.NewController(t)
-	defer ctrl.Finish()
-	checker := NewLocalChecker()
-	defer checker.Close()
-	mockResolver := NewMockCheckResolver(ctrl)
-	checker.SetDelegate(mockResolver)
+		require.Zero(t, checkRequestMetadata.DispatchCounter.Load())
 
-	parentReq := &ResolveCheckRequest{
-		TupleKey:        tuple.NewTupleKeyWithCondition("document:doc1", "viewer", "user:maria", "condition1", nil),
-		RequestMetadata: NewCheckRequestMetadata(),
-	}
-	tk := tuple.NewTupleKeyWithCondition("group:1", "member", "user:maria", "condition1", nil)
+		checkRequestMetadata = NewCheckRequestMetadata()
 
-	expectedReq := &ResolveCheckRequest{
-		TupleKey:        tuple.NewTupleKeyWithCondition("group:1", "member", "user:maria", "condition1", nil),
-		RequestMetadata: NewCheckRequestMetadata(),
-	}
-	expectedReq.GetRequestMetadata().Depth++
+		resp, err = checker.ResolveCheck(ctx, &ResolveCheckRequest{
+			StoreID:              storeID,
+			AuthorizationModelID: model.GetId(),
+			TupleKey:             tuple.NewTupleKey("document:2", "editor", "user:will"),
+			RequestMetadata:      checkRequestMetadata,
+		})
+		require.NoError(t, err)
+		require.True(t, resp.Allowed)
 
-	var req *ResolveCheckRequest
-	mockResolver.EXPECT().ResolveCheck(gomock.Any(), gomock.AssignableToTypeOf(req)).DoAndReturn(
-		func(_ context.Context, req *ResolveCheckRequest) (*ResolveCheckResponse, error) {
-			require.Equal(t, expectedReq.GetTupleKey(), req.GetTupleKey())
-			require.Equal(t, expectedReq.GetRequestMetadata().Depth, req.GetRequestMetadata().Depth)
-			require.Equal(t, uint32(1), req.GetRequestMetadata().DispatchCounter.Load())
-			return nil, nil
+		require.LessOrEqual(t, checkRequestMetadata.DispatchCounter.Load(), uint32(1))
+
+		checkRequestMetadata = NewCheckRequestMetadata()
+		resp, err = checker.ResolveCheck(ctx, &ResolveCheckRequest{
+			StoreID:              storeID,
+			AuthorizationModelID: model.GetId(),
+			TupleKey:             tuple.NewTupleKey("document:2", "editor", "user:jon"),
+			RequestMetadata:      checkRequestMetadata,
 		})
-	dispatch := checker.dispatch(context.Background(), parentReq, tk)
-	_, _ = dispatch(context.Background())
+		require.NoError(t, err)
+		require.False(t, resp.Allowed)
+		require.Equal(t, uint32(0), checkRequestMetadata.DispatchCounter.Load())
+	})
 }
 
-func TestCheckTTU(t *testing.T) {
+func TestUnionCheckFuncReducer(t *testing.T) {
 	t.Cleanup(func() {
 		goleak.VerifyNone(t)
 	})
 
-	// model
-	//	schema 1.1
-	// type user
-	// type group
-	//	relations
-	//		define member: [user] or member from parent
-	//		define parent: [group]
+	ctx := context.Background()
 
-	ttuRewrite := &openfgav1.Userset{
-		Userset: &openfgav1.Userset_TupleToUserset{
-			TupleToUserset: &openfgav1.TupleToUserset{
-				Tupleset: &openfgav1.ObjectRelation{
-					Relation: "parent",
-				},
-				ComputedUserset: &openfgav1.ObjectRelation{
-					Relation: "member",
-				},
-			},
-		},
+	concurrencyLimit := 10
+
+	falseHandler := func(context.Context) (*ResolveCheckResponse, error) {
+		return &ResolveCheckResponse{
+			Allowed: false,
+		}, nil
 	}
-	model := &openfgav1.AuthorizationModel{
-		SchemaVersion: typesystem.SchemaVersion1_1,
-		TypeDefinitions: []*openfgav1.TypeDefinition{
-			{
-				Type: "user",
-			},
+
+	trueHandler := func(context.Context) (*ResolveCheckResponse, error) {
+		return &ResolveCheckResponse{
+			Allowed: true,
+		}, nil
+	}
+
+	t.Run("no_handlers_return_allowed_false", func(t *testing.T) {
+		resp, err := union(ctx, concurrencyLimit)
+		require.NoError(t, err)
+		require.NotNil(t, resp)
+		require.False(t, resp.GetAllowed())
+		require.False(t, resp.GetCycleDetected())
+	})
+
+	t.Run("true_or_true_return_true", func(t *testing.T) {
+		resp, err := union(ctx, concurrencyLimit, trueHandler, trueHandler)
+		require.NoError(t, err)
+		require.NotNil(t, resp)
+		require.True(t, resp.GetAllowed())
+		require.False(t, resp.GetCycleDetected())
+	})
+
+	t.Run("true_or_false_return_true", func(t *testing.T) {
+		resp, err := union(ctx, concurrencyLimit, trueHandler, falseHandler)
+		require.NoError(t, err)
+		require.NotNil(t, resp)
+		require.True(t, resp.GetAllowed())
+		require.False(t, resp.GetCycleDetected())
+	})
+
+	t.Run("false_or_true_return_true", func(t *testing.T) {
+		resp, err := union(ctx, concurrencyLimit, falseHandler, trueHandler)
+		require.NoError(t, err)
+		require.NotNil(t, resp)
+		require.True(t, resp.GetAllowed())
+		require.False(t, resp.GetCycleDetected())
+	})
+
+	t.Run("false_or_false_return_false", func(t *testing.T) {
+		resp, err := union(ctx, concurrencyLimit, falseHandler, falseHandler)
+		require.NoError(t, err)
+		require.NotNil(t, resp)
+		require.False(t, resp.GetAllowed())
+		require.False(t, resp.GetCycleDetected())
+	})
+
+	t.Run("true_or_err_return_true", func(t *testing.T) {
+		resp, err := union(ctx, concurrencyLimit, trueHandler, generalErrorHandler)
+		require.NoError(t, err)
+		require.NotNil(t, resp)
+		require.True(t, resp.GetAllowed())
+		require.False(t, resp.GetCycleDetected())
+	})
+
+	t.Run("true_or_errResolutionDepth_return_true", func(t *testing.T) {
+		resp, err := union(ctx, concurrencyLimit, trueHandler, depthExceededHandler)
+		require.NoError(t, err)
+		require.NotNil(t, resp)
+		require.True(t, resp.GetAllowed())
+		require.False(t, resp.GetCycleDetected())
+	})
+
+	t.Run("true_or_cycle_return_true", func(t *testing.T) {
+		resp, err := union(ctx, concurrencyLimit, trueHandler, cyclicErrorHandler)
+		require.NoError(t, err)
+		require.NotNil(t, resp)
+		require.True(t, resp.GetAllowed())
+		require.False(t, resp.GetCycleDetected())
+	})
+
+	t.Run("false_or_err_return_err", func(t *testing.T) {
+		resp, err := union(ctx, concurrencyLimit, falseHandler, generalErrorHandler)
+		require.EqualError(t, err, simulatedDBErrorMessage)
+		require.Nil(t, resp)
+	})
+
+	t.Run("false_or_errResolutionDepth_return_err", func(t *testing.T) {
+		resp, err := union(ctx, concurrencyLimit, falseHandler, depthExceededHandler)
+		require.ErrorIs(t, err, ErrResolutionDepthExceeded)
+		require.Nil(t, resp)
+	})
+
+	t.Run("false_or_cycle_return_false", func(t *testing.T) {
+		resp, err := union(ctx, concurrencyLimit, falseHandler, cyclicErrorHandler)
+		require.NoError(t, err)
+		require.NotNil(t, resp)
+		require.False(t, resp.GetAllowed())
+		require.True(t, resp.GetCycleDetected())
+	})
+
+	t.Run("err_or_true_return_true", func(t *testing.T) {
+		resp, err := union(ctx, concurrencyLimit, generalErrorHandler, trueHandler)
+		require.NoError(t, err)
+		require.NotNil(t, resp)
+		require.True(t, resp.GetAllowed())
+		require.False(t, resp.GetCycleDetected())
+	})
+
+	t.Run("cycle_or_true_return_true", func(t *testing.T) {
+		resp, err := union(ctx, concurrencyLimit, cyclicErrorHandler, trueHandler)
+		require.NoError(t, err)
+		require.NotNil(t, resp)
+		require.True(t, resp.GetAllowed())
+		require.False(t, resp.GetCycleDetected())
+	})
+
+	t.Run("err_or_false_return_err", func(t *testing.T) {
+		resp, err := union(ctx, concurrencyLimit, generalErrorHandler, falseHandler)
+		require.EqualError(t, err, simulatedDBErrorMessage)
+		require.Nil(t, resp)
+	})
+
+	t.Run("errResolutionDepth_or_false_return_err", func(t *testing.T) {
+		resp, err := union(ctx, concurrencyLimit, depthExceededHandler, falseHandler)
+		require.ErrorIs(t, err, ErrResolutionDepthExceeded)
+		require.Nil(t, resp)
+	})
+
+	t.Run("cycle_or_false_return_false", func(t *testing.T) {
+		resp, err := union(ctx, concurrencyLimit, cyclicErrorHandler, falseHandler)
+		require.NoError(t, err)
+		require.NotNil(t, resp)
+		require.False(t, resp.GetAllowed())
+		require.True(t, resp.GetCycleDetected())
+	})
+
+	t.Run("cycle_or_err_return_err", func(t *testing.T) {
+		resp, err := union(ctx, concurrencyLimit, cyclicErrorHandler, generalErrorHandler)
+		require.EqualError(t, err, simulatedDBErrorMessage)
+		require.Nil(t, resp)
+	})
+
+	t.Run("cycle_or_errResolutionDepth_return_err", func(t *testing.T) {
+		resp, err := union(ctx, concurrencyLimit, cyclicErrorHandler, depthExceededHandler)
+		require.ErrorIs(t, err, ErrResolutionDepthExceeded)
+		require.Nil(t, resp)
+	})
+
+	t.Run("err_or_cycle_return_err", func(t *testing.T) {
+		resp, err := union(ctx, concurrencyLimit, generalErrorHandler, cyclicErrorHandler)
+		require.EqualError(t, err, simulatedDBErrorMessage)
+		require.Nil(t, resp)
+	})
+
+	t.Run("errResolutionDepth_or_cycle_return_err", func(t *testing.T) {
+		resp, err := union(ctx, concurrencyLimit, depthExceededHandler, cyclicErrorHandler)
+		require.ErrorIs(t, err, ErrResolutionDepthExceeded)
+		require.Nil(t, resp)
+	})
+
+	t.Run("cycle_or_cycle_return_false", func(t *testing.T) {
+		resp, err := union(ctx, concurrencyLimit, cyclicErrorHandler, cyclicErrorHandler)
+		require.NoError(t, err)
+		require.NotNil(t, resp)
+		require.False(t, resp.GetAllowed())
+		require.True(t, resp.GetCycleDetected())
+	})
+
+	t.Run("false_or_cycle_or_err", func(t *testing.T) {
+		resp, err := union(ctx, concurrencyLimit, falseHandler, cyclicErrorHandler, generalErrorHandler)
+		require.EqualError(t, err, simulatedDBErrorMessage)
+		require.Nil(t, resp)
+	})
+
+	t.Run("err_or_err_return_err", func(t *testing.T) {
+		resp, err := union(ctx, concurrencyLimit, falseHandler, generalErrorHandler, generalErrorHandler)
+		require.ErrorContains(t, err, simulatedDBErrorMessage)
+		require.Nil(t, resp)
+	})
+
+	t.Run("errResolutionDepth_or_errResolutionDepth_return_errResolutionDepth", func(t *testing.T) {
+		resp, err := union(ctx, concurrencyLimit, falseHandler, depthExceededHandler, depthExceededHandler)
+		require.ErrorIs(t, err, ErrResolutionDepthExceeded)
+		require.Nil(t, resp)
+	})
+
+	t.Run("should_return_allowed_true_if_truthy_handler_evaluated_before_handler_cancels_via_context", func(t *testing.T) {
+		ctx, cancel := context.WithTimeout(ctx, 10*time.Millisecond)
+		t.Cleanup(cancel)
+
+		resp, err := union(ctx, concurrencyLimit, trueHandler)
+		require.NoError(t, err)
+		require.True(t, resp.GetAllowed())
+	})
+	t.Run("should_handle_cancellations_through_context", func(t *testing.T) {
+		ctx, cancel := context.WithTimeout(ctx, 10*time.Millisecond)
+		t.Cleanup(cancel)
+
+		slowHandler := func(context.Context) (*ResolveCheckResponse, error) {
+			time.Sleep(50 * time.Millisecond)
+			return &ResolveCheckResponse{}, nil
+		}
+
+		resp, err := union(ctx, concurrencyLimit, slowHandler, falseHandler)
+		require.ErrorIs(t, err, context.DeadlineExceeded)
+		require.Nil(t, resp)
+	})
+
+	t.Run("should_handle_context_timeouts_even_with_eventual_truthy_handler", func(t *testing.T) {
+		ctx, cancel := context.WithTimeout(ctx, 10*time.Millisecond)
+		t.Cleanup(cancel)
+
+		trueHandler := func(context.Context) (*ResolveCheckResponse, error) {
+			time.Sleep(25 * time.Millisecond)
+			return &ResolveCheckResponse{
+				Allowed: true,
+			}, nil
+		}
+
+		resp, err := union(ctx, concurrencyLimit, trueHandler, falseHandler)
+		require.ErrorIs(t, err, context.DeadlineExceeded)
+		require.Nil(t, resp)
+	})
+
+	t.Run("should_return_true_with_slow_falsey_handler", func(t *testing.T) {
+		ctx, cancel := context.WithTimeout(ctx, 10*time.Millisecond)
+		t.Cleanup(cancel)
+
+		falseSlowHandler := func(context.Context) (*ResolveCheckResponse, error) {
+			time.Sleep(25 * time.Millisecond)
+			return &ResolveCheckResponse{
+				Allowed: false,
+			}, nil
+		}
+
+		resp, err := union(ctx, concurrencyLimit, trueHandler, falseSlowHandler)
+		require.NoError(t, err)
+		require.True(t, resp.GetAllowed())
+	})
+
+	t.Run("return_error_if_context_cancelled_before_resolution", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		t.Cleanup(cancel)
+
+		var wg sync.WaitGroup
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			time.Sleep(10 * time.Millisecond)
+			cancel()
+		}()
+
+		slowTrueHandler := func(context.Context) (*ResolveCheckResponse, error) {
+			time.Sleep(50 * time.Millisecond)
+			return &ResolveCheckResponse{
+				Allowed: true,
+			}, nil
+		}
+
+		resp, err := union(ctx, concurrencyLimit, slowTrueHandler)
+		require.ErrorIs(t, err, context.Canceled)
+		require.Nil(t, resp)
+
+		wg.Wait() // just to make sure to avoid test leaks
+	})
+
+	t.Run("return_allowed:true_if_truthy_handler_evaluated_before_context_cancelled", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		t.Cleanup(cancel)
+
+		var wg sync.WaitGroup
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			time.Sleep(10 * time.Millisecond)
+			cancel()
+		}()
+
+		resp, err := intersection(ctx, concurrencyLimit, trueHandler)
+		require.NoError(t, err)
+		require.True(t, resp.GetAllowed())
+
+		wg.Wait() // just to make sure to avoid test leaks
+	})
+
+	t.Run("should_error_if_handler_panics", func(t *testing.T) {
+		panicHandler := func(context.Context) (*ResolveCheckResponse, error) {
+			panic(panicErr)
+		}
+
+		resp, err := union(ctx, concurrencyLimit, panicHandler)
+		require.ErrorContains(t, err, panicErr)
+		require.ErrorIs(t, err, ErrPanic)
+		require.Nil(t, resp)
+	})
+}
+
+func TestResolveCheckCallsPathExists(t *testing.T) {
+	ds := memory.New()
+	t.Cleanup(ds.Close)
+
+	checker := NewLocalChecker()
+	t.Cleanup(checker.Close)
+
+	ctrl := gomock.NewController(t)
+	t.Cleanup(ctrl.Finish)
+
+	mockDelegate := NewMockCheckResolver(ctrl)
+	checker.SetDelegate(mockDelegate)
+
+	// assert that we never call dispatch
+	mockDelegate.EXPECT().ResolveCheck(gomock.Any(), gomock.Any()).Times(0)
+
+	storeID := ulid.Make().String()
+	model := testutils.MustTransformDSLToProtoWithID(`
+			model
+				schema 1.1
+			type user
+			type document
+				relations
+					define x: y
+					define y: x
+		`)
+
+	ts, err := typesystem.New(model)
+	require.NoError(t, err)
+
+	ctx := setRequestContext(context.Background(), ts, ds, nil)
+
+	resp, err := checker.ResolveCheck(ctx, &ResolveCheckRequest{
+		StoreID:              storeID,
+		AuthorizationModelID: model.GetId(),
+		TupleKey:             tuple.NewTupleKey("document:1", "y", "user:maria"),
+		RequestMetadata:      NewCheckRequestMetadata(),
+	})
+	require.NoError(t, err)
+	require.NotNil(t, resp)
+	require.False(t, resp.GetAllowed())
+
+	// Without PathExists check, this would cycle
+	require.False(t, resp.GetCycleDetected())
+}
+
+func TestResolveCheckCallsCycleDetection(t *testing.T) {
+	ds := memory.New()
+	t.Cleanup(ds.Close)
+
+	checker := NewLocalChecker()
+	t.Cleanup(checker.Close)
+
+	ctrl := gomock.NewController(t)
+	t.Cleanup(ctrl.Finish)
+
+	mockDelegate := NewMockCheckResolver(ctrl)
+	checker.SetDelegate(mockDelegate)
+
+	// assert that we never call dispatch
+	mockDelegate.EXPECT().ResolveCheck(gomock.Any(), gomock.Any()).Times(0)
+
+	t.Run("returns_true_if_path_visited", func(t *testing.T) {
+		cyclicalTuple := tuple.NewTupleKey("document:1", "viewer", "user:maria")
+
+		resp, err := checker.ResolveCheck(context.Background(), &ResolveCheckRequest{
+			StoreID:         ulid.Make().String(),
+			TupleKey:        cyclicalTuple, // here
+			RequestMetadata: NewCheckRequestMetadata(),
+			VisitedPaths: map[string]struct{}{
+				tuple.TupleKeyToString(cyclicalTuple): {}, // and here
+			},
+		})
+
+		require.NoError(t, err)
+		require.NotNil(t, resp)
+		require.False(t, resp.GetAllowed())
+		require.True(t, resp.GetCycleDetected())
+		require.NotNil(t, resp.ResolutionMetadata)
+	})
+
+	t.Run("returns_true_if_cycle_exists", func(t *testing.T) {
+		storeID := ulid.Make().String()
+		model := testutils.MustTransformDSLToProtoWithID(`
+			model
+				schema 1.1
+			type user
+			type document
+				relations
+					define x: y
+					define y: x`)
+
+		ts, err := typesystem.New(model)
+		require.NoError(t, err)
+
+		ctx := setRequestContext(context.Background(), ts, ds, nil)
+
+		resp, err := checker.ResolveCheck(ctx, &ResolveCheckRequest{
+			StoreID:              storeID,
+			AuthorizationModelID: model.GetId(),
+			TupleKey:             tuple.NewTupleKey("document:1", "y", "document:2#x"),
+			RequestMetadata:      NewCheckRequestMetadata(),
+		})
+		require.NoError(t, err)
+		require.NotNil(t, resp)
+		require.False(t, resp.GetAllowed())
+		require.True(t, resp.GetCycleDetected())
+	})
+}
+
+func TestDispatch(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	checker := NewLocalChecker()
+	defer checker.Close()
+	mockResolver := NewMockCheckResolver(ctrl)
+	checker.SetDelegate(mockResolver)
+
+	parentReq := &ResolveCheckRequest{
+		TupleKey:        tuple.NewTupleKeyWithCondition("document:doc1", "viewer", "user:maria", "condition1", nil),
+		RequestMetadata: NewCheckRequestMetadata(),
+	}
+	tk := tuple.NewTupleKeyWithCondition("group:1", "member", "user:maria", "condition1", nil)
+
+	expectedReq := &ResolveCheckRequest{
+		TupleKey:        tuple.NewTupleKeyWithCondition("group:1", "member", "user:maria", "condition1", nil),
+		RequestMetadata: NewCheckRequestMetadata(),
+	}
+	expectedReq.GetRequestMetadata().Depth++
+
+	var req *ResolveCheckRequest
+	mockResolver.EXPECT().ResolveCheck(gomock.Any(), gomock.AssignableToTypeOf(req)).DoAndReturn(
+		func(_ context.Context, req *ResolveCheckRequest) (*ResolveCheckResponse, error) {
+			require.Equal(t, expectedReq.GetTupleKey(), req.GetTupleKey())
+			require.Equal(t, expectedReq.GetRequestMetadata().Depth, req.GetRequestMetadata().Depth)
+			require.Equal(t, uint32(1), req.GetRequestMetadata().DispatchCounter.Load())
+			return nil, nil
+		})
+	dispatch := checker.dispatch(context.Background(), parentReq, tk)
+	_, _ = dispatch(context.Background())
+}
+
+func TestCheckTTU(t *testing.T) {
+	t.Cleanup(func() {
+		goleak.VerifyNone(t)
+	})
+
+	// model
+	//	schema 1.1
+	// type user
+	// type group
+	//	relations
+	//		define member: [user] or member from parent
+	//		define parent: [group]
+
+	ttuRewrite := &openfgav1.Userset{
+		Userset: &openfgav1.Userset_TupleToUserset{
+			TupleToUserset: &openfgav1.TupleToUserset{
+				Tupleset: &openfgav1.ObjectRelation{
+					Relation: "parent",
+				},
+				ComputedUserset: &openfgav1.ObjectRelation{
+					Relation: "member",
+				},
+			},
+		},
+	}
+	model := &openfgav1.AuthorizationModel{
+		SchemaVersion: typesystem.SchemaVersion1_1,
+		TypeDefinitions: []*openfgav1.TypeDefinition{
+			{
+				Type: "user",
+			},
 			{
 				Type: "group",
 				Relations: map[string]*openfgav1.Userset{
@@ -1994,35 +2568,187 @@ func TestCheckDirectUserTuple(t *testing.T) {
 			expectedError:      fmt.Errorf("mock_erorr"),
 		},
 	}
-	for _, tt := range tests {
-		t.Run(tt.name, func(t *testing.T) {
-			t.Parallel()
-			ctrl := gomock.NewController(t)
-			defer ctrl.Finish()
-			storeID := ulid.Make().String()
-			ds := mocks.NewMockRelationshipTupleReader(ctrl)
-
-			ds.EXPECT().ReadUserTuple(gomock.Any(), storeID, tt.reqTupleKey, gomock.Any()).Times(1).Return(tt.readUserTuple, tt.readUserTupleError)
-
-			ts, err := typesystem.New(tt.model)
-			require.NoError(t, err)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+			storeID := ulid.Make().String()
+			ds := mocks.NewMockRelationshipTupleReader(ctrl)
+
+			ds.EXPECT().ReadUserTuple(gomock.Any(), storeID, tt.reqTupleKey, gomock.Any()).Times(1).Return(tt.readUserTuple, tt.readUserTupleError)
+
+			ts, err := typesystem.New(tt.model)
+			require.NoError(t, err)
+
+			ctx := setRequestContext(context.Background(), ts, ds, nil)
+
+			contextStruct, err := structpb.NewStruct(tt.context)
+			require.NoError(t, err)
+
+			checker := NewLocalChecker()
+			function := checker.checkDirectUserTuple(ctx, &ResolveCheckRequest{
+				StoreID:              storeID,
+				AuthorizationModelID: ulid.Make().String(),
+				TupleKey:             tt.reqTupleKey,
+				Context:              contextStruct,
+				RequestMetadata:      NewCheckRequestMetadata(),
+			})
+			resp, err := function(ctx)
+			require.Equal(t, tt.expectedError, err)
+			require.Equal(t, tt.expected, resp)
+		})
+	}
+}
+
+func TestCheckDirectTupleOnly(t *testing.T) {
+	t.Cleanup(func() {
+		goleak.VerifyNone(t)
+	})
+
+	model := testutils.MustTransformDSLToProtoWithID(`
+		model
+			schema 1.1
+		type user
+		type employee
+		type group
+			relations
+				define member: [user, employee]
+		type team
+			relations
+				define member: [employee]
+		type repo
+			relations
+				define reader: [user, team#member]`)
+	ts, err := typesystem.New(model)
+	require.NoError(t, err)
+
+	tests := []struct {
+		name          string
+		tupleKey      *openfgav1.TupleKey
+		readUserTuple *openfgav1.Tuple
+		readErr       error
+		expected      bool
+	}{
+		{
+			name:          "granted",
+			tupleKey:      tuple.NewTupleKey("group:1", "member", "user:anne"),
+			readUserTuple: &openfgav1.Tuple{Key: tuple.NewTupleKey("group:1", "member", "user:anne")},
+			expected:      true,
+		},
+		{
+			name:     "not_granted",
+			tupleKey: tuple.NewTupleKey("group:1", "member", "employee:bob"),
+			readErr:  storage.ErrNotFound,
+			expected: false,
+		},
+		{
+			// the members of teams are employees, so only the tuple of the user can grant it repo#reader
+			name:     "relation_with_usersets_of_other_types",
+			tupleKey: tuple.NewTupleKey("repo:1", "reader", "user:anne"),
+			readErr:  storage.ErrNotFound,
+			expected: false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			storeID := ulid.Make().String()
+			ds := mocks.NewMockRelationshipTupleReader(ctrl)
+
+			// any other read, e.g. ReadUsersetTuples, fails the test
+			ds.EXPECT().ReadUserTuple(gomock.Any(), storeID, tt.tupleKey, gomock.Any()).Times(1).Return(tt.readUserTuple, tt.readErr)
+
+			checker := NewLocalChecker()
+			t.Cleanup(checker.Close)
+
+			req, err := NewResolveCheckRequest(ResolveCheckRequestParams{
+				StoreID:              storeID,
+				AuthorizationModelID: model.GetId(),
+				TupleKey:             tt.tupleKey,
+			})
+			require.NoError(t, err)
+
+			resp, err := checker.ResolveCheck(setRequestContext(context.Background(), ts, ds, nil), req)
+			require.NoError(t, err)
+			require.Equal(t, tt.expected, resp.GetAllowed())
+		})
+	}
+}
+
+func TestRequiresDispatchedResolution(t *testing.T) {
+	usersets := []*openfgav1.RelationReference{typesystem.DirectRelationReference("group", "member")}
+	tk := tuple.NewTupleKey("document:1", "viewer", "user:anne")
+
+	tests := []struct {
+		name     string
+		opts     []LocalCheckerOption
+		req      *ResolveCheckRequest
+		expected bool
+	}{
+		{
+			name:     "none",
+			req:      &ResolveCheckRequest{TupleKey: tk},
+			expected: false,
+		},
+		{
+			name:     "userset_user",
+			req:      &ResolveCheckRequest{TupleKey: tuple.NewTupleKey("document:1", "viewer", "group:eng#member")},
+			expected: true,
+		},
+		{
+			name:     "excluded_relations",
+			req:      &ResolveCheckRequest{TupleKey: tk, ExcludedRelations: map[string]struct{}{"group#member": {}}},
+			expected: true,
+		},
+		{
+			name:     "assumed_facts",
+			req:      &ResolveCheckRequest{TupleKey: tk, AssumedFacts: map[string]struct{}{"group:eng#member@user:anne": {}}},
+			expected: true,
+		},
+		{
+			name:     "scoring",
+			req:      &ResolveCheckRequest{TupleKey: tk, Scoring: &DefaultCheckScoring},
+			expected: true,
+		},
+		{
+			name:     "session",
+			req:      &ResolveCheckRequest{TupleKey: tk, Session: NewCheckSession(10)},
+			expected: true,
+		},
+		{
+			name:     "trace",
+			req:      &ResolveCheckRequest{TupleKey: tk, Trace: true},
+			expected: true,
+		},
+		{
+			name:     "userset_caching",
+			opts:     []LocalCheckerOption{WithUsersetCaching(true)},
+			req:      &ResolveCheckRequest{TupleKey: tk},
+			expected: true,
+		},
+		{
+			name:     "oracle_of_the_usersets",
+			opts:     []LocalCheckerOption{WithUsersetOracle("group", "member", &staticUsersetOracle{})},
+			req:      &ResolveCheckRequest{TupleKey: tk},
+			expected: true,
+		},
+		{
+			name:     "oracle_of_other_usersets",
+			opts:     []LocalCheckerOption{WithUsersetOracle("team", "member", &staticUsersetOracle{})},
+			req:      &ResolveCheckRequest{TupleKey: tk},
+			expected: false,
+		},
+	}
 
-			ctx := setRequestContext(context.Background(), ts, ds, nil)
-
-			contextStruct, err := structpb.NewStruct(tt.context)
-			require.NoError(t, err)
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			checker := NewLocalChecker(test.opts...)
+			t.Cleanup(checker.Close)
 
-			checker := NewLocalChecker()
-			function := checker.checkDirectUserTuple(ctx, &ResolveCheckRequest{
-				StoreID:              storeID,
-				AuthorizationModelID: ulid.Make().String(),
-				TupleKey:             tt.reqTupleKey,
-				Context:              contextStruct,
-				RequestMetadata:      NewCheckRequestMetadata(),
-			})
-			resp, err := function(ctx)
-			require.Equal(t, tt.expectedError, err)
-			require.Equal(t, tt.expected, resp)
+			require.Equal(t, test.expected, checker.requiresDispatchedResolution(test.req, usersets))
 		})
 	}
 }
@@ -2510,136 +3236,589 @@ func TestStreamedLookupUsersetFromIterator(t *testing.T) {
 		},
 	}
 
-	for _, tt := range tests {
-		t.Run(tt.name, func(t *testing.T) {
-			t.Parallel()
-			ctrl := gomock.NewController(t)
-			defer ctrl.Finish()
-			storeID := ulid.Make().String()
-			ds := mocks.NewMockRelationshipTupleReader(ctrl)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+			storeID := ulid.Make().String()
+			ds := mocks.NewMockRelationshipTupleReader(ctrl)
+
+			model := parser.MustTransformDSLToProto(`
+					model
+						schema 1.1
+
+					type user
+					type group
+						relations
+							define member: [user, group#member]
+`)
+
+			ts, err := typesystem.New(model)
+			require.NoError(t, err)
+
+			ctx := setRequestContext(context.Background(), ts, ds, nil)
+
+			restrictions, err := ts.DirectlyRelatedUsersets("group", "member")
+			require.NoError(t, err)
+			if tt.iteratorHasError {
+				ds.EXPECT().ReadUsersetTuples(gomock.Any(), storeID, storage.ReadUsersetTuplesFilter{
+					Object:                      "group:1",
+					Relation:                    "member",
+					AllowedUserTypeRestrictions: restrictions,
+				}, gomock.Any()).Times(1).Return(mocks.NewErrorTupleIterator(tt.readUsersetTuples), tt.readUsersetTuplesError)
+			} else {
+				ds.EXPECT().ReadUsersetTuples(gomock.Any(), storeID, storage.ReadUsersetTuplesFilter{
+					Object:                      "group:1",
+					Relation:                    "member",
+					AllowedUserTypeRestrictions: restrictions,
+				}, gomock.Any()).Times(1).Return(storage.NewStaticTupleIterator(tt.readUsersetTuples), tt.readUsersetTuplesError)
+			}
+
+			req := &ResolveCheckRequest{
+				StoreID:              storeID,
+				AuthorizationModelID: ulid.Make().String(),
+				TupleKey:             tuple.NewTupleKey("group:1", "member", "user:maria"),
+				RequestMetadata:      NewCheckRequestMetadata(),
+			}
+
+			cancellableCtx, cancelFunc := context.WithCancel(context.Background())
+			if tt.contextDone {
+				cancelFunc()
+			} else {
+				defer cancelFunc()
+			}
+
+			mapper, err := buildRecursiveMapper(ctx, req, &recursiveMapping{
+				kind:                        storage.UsersetKind,
+				allowedUserTypeRestrictions: restrictions,
+			})
+			if tt.readUsersetTuplesError != nil {
+				require.Equal(t, tt.readUsersetTuplesError, err)
+				return
+			}
+
+			userToUsersetMessageChan := streamedLookupUsersetFromIterator(cancellableCtx, mapper)
+
+			var userToUsersetMessages []usersetMessage
+
+			for userToUsersetMessage := range userToUsersetMessageChan {
+				userToUsersetMessages = append(userToUsersetMessages, userToUsersetMessage)
+			}
+
+			require.Equal(t, tt.expected, userToUsersetMessages)
+		})
+	}
+
+	t.Run("should_error_if_panic_occurs", func(t *testing.T) {
+		ctx := context.Background()
+		iter := &mockPanicIterator[string]{}
+		userToUsersetMessageChan := streamedLookupUsersetFromIterator(ctx, iter)
+
+		for userToUsersetMessage := range userToUsersetMessageChan {
+			require.ErrorContains(t, userToUsersetMessage.err, panicErr)
+			require.ErrorIs(t, userToUsersetMessage.err, ErrPanic)
+			require.Empty(t, userToUsersetMessage.userset)
+		}
+	})
+}
+
+func TestProcessUsersetMessage(t *testing.T) {
+	t.Cleanup(func() {
+		goleak.VerifyNone(t)
+	})
+
+	tests := []struct {
+		name                 string
+		userset              string
+		matchingUserset      []string
+		expectedFound        bool
+		expectedInputUserset []string
+	}{
+		{
+			name:                 "match",
+			userset:              "b",
+			matchingUserset:      []string{"a", "b"},
+			expectedFound:        true,
+			expectedInputUserset: []string{"b"},
+		},
+		{
+			name:                 "not_match",
+			userset:              "c",
+			matchingUserset:      []string{"a", "b"},
+			expectedFound:        false,
+			expectedInputUserset: []string{"c"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			inputSortedSet := hashset.New()
+			matchingSortedSet := hashset.New()
+			for _, match := range tt.matchingUserset {
+				matchingSortedSet.Add(match)
+			}
+			output := processUsersetMessage(tt.userset, inputSortedSet, matchingSortedSet)
+			require.Equal(t, tt.expectedFound, output)
+			res := make([]string, 0, inputSortedSet.Size())
+			for _, v := range inputSortedSet.Values() {
+				res = append(res, v.(string))
+			}
+			require.Equal(t, tt.expectedInputUserset, res)
+		})
+	}
+}
+
+func TestCheckWithUsersetCaching(t *testing.T) {
+	ds := memory.New()
+	defer ds.Close()
+
+	storeID := ulid.Make().String()
+
+	err := ds.Write(context.Background(), storeID, nil, []*openfgav1.TupleKey{
+		tuple.NewTupleKey("document:1", "viewer", "group:eng#member"),
+		tuple.NewTupleKey("document:2", "viewer", "group:eng#member"),
+		tuple.NewTupleKey("group:eng", "member", "user:bob"),
+	})
+	require.NoError(t, err)
+
+	model := testutils.MustTransformDSLToProtoWithID(`
+		model
+			schema 1.1
+
+		type user
+		type group
+			relations
+				define member: [user]
+		type document
+			relations
+				define viewer: [user, group#member]`)
+
+	ts, err := typesystem.New(model)
+	require.NoError(t, err)
+
+	// countMemberReads resolves 'viewer' of both documents for user:bob and returns the number of
+	// reads of the members of the group.
+	countMemberReads := func(t *testing.T, usersetCaching bool) int {
+		cachedResolver, err := NewCachedCheckResolver(WithCacheTTL(time.Hour))
+		require.NoError(t, err)
+		t.Cleanup(cachedResolver.Close)
+
+		checker := NewLocalChecker(WithUsersetCaching(usersetCaching))
+		t.Cleanup(checker.Close)
+
+		cachedResolver.SetDelegate(checker)
+		checker.SetDelegate(cachedResolver)
+
+		reader := &relationRecordingReader{RelationshipTupleReader: ds}
+		for _, object := range []string{"document:1", "document:2"} {
+			ctx := setRequestContext(context.Background(), ts, reader, nil)
+			resp, err := cachedResolver.ResolveCheck(ctx, &ResolveCheckRequest{
+				StoreID:              storeID,
+				AuthorizationModelID: model.GetId(),
+				TupleKey:             tuple.NewTupleKey(object, "viewer", "user:bob"),
+				RequestMetadata:      NewCheckRequestMetadata(),
+			})
+			require.NoError(t, err)
+			require.True(t, resp.GetAllowed())
+		}
+
+		var memberReads int
+		for _, relation := range reader.relations {
+			if relation == "member" {
+				memberReads++
+			}
+		}
+		return memberReads
+	}
+
+	t.Run("group_is_resolved_once_for_both_documents", func(t *testing.T) {
+		require.Equal(t, 1, countMemberReads(t, true))
+	})
+
+	t.Run("group_is_resolved_for_each_document_without_userset_caching", func(t *testing.T) {
+		require.Equal(t, 2, countMemberReads(t, false))
+	})
+}
+
+// replicatedReader reads from the primary with HIGHER_CONSISTENCY, and from the replica otherwise.
+type replicatedReader struct {
+	storage.RelationshipTupleReader // the replica
+	primary                         storage.RelationshipTupleReader
+}
+
+func (r *replicatedReader) source(consistency storage.ConsistencyOptions) storage.RelationshipTupleReader {
+	if consistency.Preference == openfgav1.ConsistencyPreference_HIGHER_CONSISTENCY {
+		return r.primary
+	}
+	return r.RelationshipTupleReader
+}
+
+func (r *replicatedReader) Read(ctx context.Context, store string, tk *openfgav1.TupleKey, options storage.ReadOptions) (storage.TupleIterator, error) {
+	return r.source(options.Consistency).Read(ctx, store, tk, options)
+}
+
+func (r *replicatedReader) ReadUserTuple(ctx context.Context, store string, tk *openfgav1.TupleKey, options storage.ReadUserTupleOptions) (*openfgav1.Tuple, error) {
+	return r.source(options.Consistency).ReadUserTuple(ctx, store, tk, options)
+}
+
+func (r *replicatedReader) ReadUsersetTuples(ctx context.Context, store string, filter storage.ReadUsersetTuplesFilter, options storage.ReadUsersetTuplesOptions) (storage.TupleIterator, error) {
+	return r.source(options.Consistency).ReadUsersetTuples(ctx, store, filter, options)
+}
+
+func (r *replicatedReader) ReadStartingWithUser(ctx context.Context, store string, filter storage.ReadStartingWithUserFilter, options storage.ReadStartingWithUserOptions) (storage.TupleIterator, error) {
+	return r.source(options.Consistency).ReadStartingWithUser(ctx, store, filter, options)
+}
+
+func TestCheckWithPrimaryReadRelations(t *testing.T) {
+	primary := memory.New()
+	defer primary.Close()
+	replica := memory.New()
+	defer replica.Close()
+
+	storeID := ulid.Make().String()
+
+	// the replica lags behind and doesn't have the tuples yet
+	err := primary.Write(context.Background(), storeID, nil, []*openfgav1.TupleKey{
+		tuple.NewTupleKey("document:1", "viewer", "user:anne"),
+		tuple.NewTupleKey("document:1", "editor", "user:anne"),
+		tuple.NewTupleKey("document:1", "owner", "group:eng#member"),
+		tuple.NewTupleKey("group:eng", "member", "user:anne"),
+	})
+	require.NoError(t, err)
+
+	model := testutils.MustTransformDSLToProtoWithID(`
+		model
+			schema 1.1
+
+		type user
+		type group
+			relations
+				define member: [user]
+		type document
+			relations
+				define viewer: [user]
+				define editor: [user]
+				define owner: [group#member]`)
+
+	ts, err := typesystem.New(model)
+	require.NoError(t, err)
+
+	checker := NewLocalChecker(WithPrimaryReadRelations(map[string][]string{
+		storeID: {"document#viewer", "document#owner", "group#member"},
+	}))
+	t.Cleanup(checker.Close)
+
+	tests := []struct {
+		name     string
+		relation string
+		allowed  bool
+	}{
+		{
+			name:     "designated_relation_reads_from_primary",
+			relation: "viewer",
+			allowed:  true,
+		},
+		{
+			name:     "designated_userset_relation_reads_from_primary",
+			relation: "owner",
+			allowed:  true,
+		},
+		{
+			name:     "other_relation_reads_from_replica",
+			relation: "editor",
+			allowed:  false,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			reader := &replicatedReader{RelationshipTupleReader: replica, primary: primary}
+			ctx := setRequestContext(context.Background(), ts, reader, nil)
+			resp, err := checker.ResolveCheck(ctx, &ResolveCheckRequest{
+				StoreID:              storeID,
+				AuthorizationModelID: model.GetId(),
+				TupleKey:             tuple.NewTupleKey("document:1", test.relation, "user:anne"),
+				RequestMetadata:      NewCheckRequestMetadata(),
+			})
+			require.NoError(t, err)
+			require.Equal(t, test.allowed, resp.GetAllowed())
+		})
+	}
+}
+
+func TestCheckWithMaxUsersetFanout(t *testing.T) {
+	ds := memory.New()
+	defer ds.Close()
 
-			model := parser.MustTransformDSLToProto(`
-					model
-						schema 1.1
+	storeID := ulid.Make().String()
 
-					type user
-					type group
-						relations
-							define member: [user, group#member]
-`)
+	err := ds.Write(context.Background(), storeID, nil, []*openfgav1.TupleKey{
+		// document:1 is over-shared
+		tuple.NewTupleKey("document:1", "viewer", "group:a#member"),
+		tuple.NewTupleKey("document:1", "viewer", "group:b#member"),
+		tuple.NewTupleKey("document:1", "viewer", "group:c#member"),
+		tuple.NewTupleKey("document:2", "viewer", "group:a#member"),
+		tuple.NewTupleKey("document:2", "viewer", "group:b#member"),
+		tuple.NewTupleKey("group:b", "member", "user:anne"),
+	})
+	require.NoError(t, err)
 
-			ts, err := typesystem.New(model)
-			require.NoError(t, err)
+	model := testutils.MustTransformDSLToProtoWithID(`
+		model
+			schema 1.1
 
-			ctx := setRequestContext(context.Background(), ts, ds, nil)
+		type user
+		type group
+			relations
+				define member: [user]
+		type document
+			relations
+				define viewer: [user, group#member]`)
 
-			restrictions, err := ts.DirectlyRelatedUsersets("group", "member")
-			require.NoError(t, err)
-			if tt.iteratorHasError {
-				ds.EXPECT().ReadUsersetTuples(gomock.Any(), storeID, storage.ReadUsersetTuplesFilter{
-					Object:                      "group:1",
-					Relation:                    "member",
-					AllowedUserTypeRestrictions: restrictions,
-				}, gomock.Any()).Times(1).Return(mocks.NewErrorTupleIterator(tt.readUsersetTuples), tt.readUsersetTuplesError)
-			} else {
-				ds.EXPECT().ReadUsersetTuples(gomock.Any(), storeID, storage.ReadUsersetTuplesFilter{
-					Object:                      "group:1",
-					Relation:                    "member",
-					AllowedUserTypeRestrictions: restrictions,
-				}, gomock.Any()).Times(1).Return(storage.NewStaticTupleIterator(tt.readUsersetTuples), tt.readUsersetTuplesError)
-			}
+	ts, err := typesystem.New(model)
+	require.NoError(t, err)
 
-			req := &ResolveCheckRequest{
-				StoreID:              storeID,
-				AuthorizationModelID: ulid.Make().String(),
-				TupleKey:             tuple.NewTupleKey("group:1", "member", "user:maria"),
-				RequestMetadata:      NewCheckRequestMetadata(),
-			}
+	check := func(t *testing.T, checker *LocalChecker, object, user string) (*ResolveCheckResponse, error) {
+		ctx := setRequestContext(context.Background(), ts, ds, nil)
+		return checker.ResolveCheck(ctx, &ResolveCheckRequest{
+			StoreID:              storeID,
+			AuthorizationModelID: model.GetId(),
+			TupleKey:             tuple.NewTupleKey(object, "viewer", user),
+			RequestMetadata:      NewCheckRequestMetadata(),
+		})
+	}
 
-			cancellableCtx, cancelFunc := context.WithCancel(context.Background())
-			if tt.contextDone {
-				cancelFunc()
-			} else {
-				defer cancelFunc()
-			}
+	t.Run("object_within_the_cap_resolves", func(t *testing.T) {
+		checker := NewLocalChecker(WithMaxUsersetFanout(2))
+		t.Cleanup(checker.Close)
 
-			mapper, err := buildRecursiveMapper(ctx, req, &recursiveMapping{
-				kind:                        storage.UsersetKind,
-				allowedUserTypeRestrictions: restrictions,
-			})
-			if tt.readUsersetTuplesError != nil {
-				require.Equal(t, tt.readUsersetTuplesError, err)
-				return
-			}
+		resp, err := check(t, checker, "document:2", "user:anne")
+		require.NoError(t, err)
+		require.True(t, resp.GetAllowed())
+	})
 
-			userToUsersetMessageChan := streamedLookupUsersetFromIterator(cancellableCtx, mapper)
+	t.Run("object_exceeding_the_cap_errors", func(t *testing.T) {
+		checker := NewLocalChecker(WithMaxUsersetFanout(2))
+		t.Cleanup(checker.Close)
 
-			var userToUsersetMessages []usersetMessage
+		// a userset user is resolved by expanding every userset, while the other strategies may deny
+		// before the usersets beyond the cap are read
+		_, err := check(t, checker, "document:1", "group:x#member")
+		require.ErrorIs(t, err, ErrUsersetFanoutExceeded)
+	})
 
-			for userToUsersetMessage := range userToUsersetMessageChan {
-				userToUsersetMessages = append(userToUsersetMessages, userToUsersetMessage)
-			}
+	t.Run("object_exceeding_the_cap_is_denied", func(t *testing.T) {
+		checker := NewLocalChecker(WithMaxUsersetFanout(2), WithUsersetFanoutBehavior(serverconfig.UsersetFanoutDeny))
+		t.Cleanup(checker.Close)
 
-			require.Equal(t, tt.expected, userToUsersetMessages)
+		resp, err := check(t, checker, "document:1", "user:bob")
+		require.NoError(t, err)
+		require.False(t, resp.GetAllowed())
+	})
+
+	t.Run("no_cap", func(t *testing.T) {
+		checker := NewLocalChecker()
+		t.Cleanup(checker.Close)
+
+		resp, err := check(t, checker, "document:1", "user:anne")
+		require.NoError(t, err)
+		require.True(t, resp.GetAllowed())
+	})
+}
+
+func TestCheckConditionEvaluationCache(t *testing.T) {
+	t.Cleanup(func() {
+		goleak.VerifyNone(t)
+	})
+
+	ds := memory.New()
+	t.Cleanup(ds.Close)
+
+	storeID := ulid.Make().String()
+	model := testutils.MustTransformDSLToProtoWithID(`
+		model
+			schema 1.1
+
+		type user
+		type document
+			relations
+				define viewer: [user with in_region]
+				define editor: [user with in_region]
+				define can_edit: viewer and editor
+
+		condition in_region(region: string) {
+			region == "eu"
+		}`)
+
+	var tuples []*openfgav1.TupleKey
+	for i := range 5 {
+		tuples = append(tuples, tuple.NewTupleKeyWithCondition(fmt.Sprintf("document:%d", i), "viewer", "user:anne", "in_region", nil))
+	}
+	tuples = append(tuples, tuple.NewTupleKeyWithCondition("document:0", "editor", "user:anne", "in_region", nil))
+	require.NoError(t, ds.Write(context.Background(), storeID, nil, tuples))
+
+	ts, err := typesystem.New(model)
+	require.NoError(t, err)
+
+	// the operands are evaluated one at a time, so the second evaluation of a condition is always a hit
+	checker := NewLocalChecker(WithResolutionStrategy(serverconfig.DepthFirstResolution))
+	t.Cleanup(checker.Close)
+
+	check := func(t *testing.T, object, relation, region string) bool {
+		reqContext, err := structpb.NewStruct(map[string]interface{}{"region": region})
+		require.NoError(t, err)
+
+		req, err := NewResolveCheckRequest(ResolveCheckRequestParams{
+			StoreID:              storeID,
+			AuthorizationModelID: model.GetId(),
+			TupleKey:             tuple.NewTupleKey(object, relation, "user:anne"),
+			Context:              reqContext,
 		})
+		require.NoError(t, err)
+
+		resp, err := checker.ResolveCheck(setRequestContext(context.Background(), ts, ds, nil), req)
+		require.NoError(t, err)
+		return resp.GetAllowed()
 	}
 
-	t.Run("should_error_if_panic_occurs", func(t *testing.T) {
-		ctx := context.Background()
-		iter := &mockPanicIterator[string]{}
-		userToUsersetMessageChan := streamedLookupUsersetFromIterator(ctx, iter)
+	t.Run("evaluations_are_memoized_within_a_check", func(t *testing.T) {
+		cache := eval.NewEvaluationCache()
+		ctx := eval.ContextWithEvaluationCache(setRequestContext(context.Background(), ts, ds, nil), cache)
 
-		for userToUsersetMessage := range userToUsersetMessageChan {
-			require.ErrorContains(t, userToUsersetMessage.err, panicErr)
-			require.ErrorIs(t, userToUsersetMessage.err, ErrPanic)
-			require.Empty(t, userToUsersetMessage.userset)
+		for i := range 5 {
+			req, err := NewResolveCheckRequest(ResolveCheckRequestParams{
+				StoreID:              storeID,
+				AuthorizationModelID: model.GetId(),
+				TupleKey:             tuple.NewTupleKey(fmt.Sprintf("document:%d", i), "viewer", "user:anne"),
+				Context:              testutils.MustNewStruct(t, map[string]interface{}{"region": "eu"}),
+			})
+			require.NoError(t, err)
+
+			resp, err := checker.ResolveCheck(ctx, req)
+			require.NoError(t, err)
+			require.True(t, resp.GetAllowed())
 		}
+		require.Equal(t, uint64(4), cache.Hits())
+	})
+
+	t.Run("each_check_has_its_own_cache", func(t *testing.T) {
+		hits := promtestutil.ToFloat64(conditionEvaluationCacheHitCounter)
+
+		// the tuples of viewer and editor have the same condition and context
+		require.True(t, check(t, "document:0", "can_edit", "eu"))
+		require.InDelta(t, hits+1, promtestutil.ToFloat64(conditionEvaluationCacheHitCounter), 0)
+
+		require.True(t, check(t, "document:0", "viewer", "eu"))
+		require.InDelta(t, hits+1, promtestutil.ToFloat64(conditionEvaluationCacheHitCounter), 0)
 	})
 }
 
-func TestProcessUsersetMessage(t *testing.T) {
+func TestCheckUnreachableRelationWithoutReads(t *testing.T) {
 	t.Cleanup(func() {
 		goleak.VerifyNone(t)
 	})
 
-	tests := []struct {
-		name                 string
-		userset              string
-		matchingUserset      []string
-		expectedFound        bool
-		expectedInputUserset []string
-	}{
-		{
-			name:                 "match",
-			userset:              "b",
-			matchingUserset:      []string{"a", "b"},
-			expectedFound:        true,
-			expectedInputUserset: []string{"b"},
-		},
-		{
-			name:                 "not_match",
-			userset:              "c",
-			matchingUserset:      []string{"a", "b"},
-			expectedFound:        false,
-			expectedInputUserset: []string{"c"},
-		},
+	ctrl := gomock.NewController(t)
+	t.Cleanup(ctrl.Finish)
+
+	// the datastore isn't expected to be called
+	ds := mocks.NewMockRelationshipTupleReader(ctrl)
+
+	model := testutils.MustTransformDSLToProtoWithID(`
+		model
+			schema 1.1
+		type user
+		type employee
+		type group
+			relations
+				define member: [employee]
+		type document
+			relations
+				define owner: [employee, group#member]
+				define editor: owner
+				define viewer: [user] or editor`)
+
+	ts, err := typesystem.New(model)
+	require.NoError(t, err)
+
+	checker := NewLocalChecker()
+	t.Cleanup(checker.Close)
+
+	for _, relation := range []string{"owner", "editor"} {
+		req, err := NewResolveCheckRequest(ResolveCheckRequestParams{
+			StoreID:              ulid.Make().String(),
+			AuthorizationModelID: model.GetId(),
+			TupleKey:             tuple.NewTupleKey("document:1", relation, "user:anne"),
+		})
+		require.NoError(t, err)
+
+		resp, err := checker.ResolveCheck(setRequestContext(context.Background(), ts, ds, nil), req)
+		require.NoError(t, err)
+		require.False(t, resp.GetAllowed())
+		require.Zero(t, resp.GetResolutionMetadata().DatastoreQueryCount)
 	}
+}
 
-	for _, tt := range tests {
-		t.Run(tt.name, func(t *testing.T) {
-			t.Parallel()
-			inputSortedSet := hashset.New()
-			matchingSortedSet := hashset.New()
-			for _, match := range tt.matchingUserset {
-				matchingSortedSet.Add(match)
-			}
-			output := processUsersetMessage(tt.userset, inputSortedSet, matchingSortedSet)
-			require.Equal(t, tt.expectedFound, output)
-			res := make([]string, 0, inputSortedSet.Size())
-			for _, v := range inputSortedSet.Values() {
-				res = append(res, v.(string))
-			}
-			require.Equal(t, tt.expectedInputUserset, res)
+func TestCheckRewriteDepthLimit(t *testing.T) {
+	t.Cleanup(func() {
+		goleak.VerifyNone(t)
+	})
+
+	ds := memory.New()
+	t.Cleanup(ds.Close)
+
+	storeID := ulid.Make().String()
+	require.NoError(t, ds.Write(context.Background(), storeID, nil, []*openfgav1.TupleKey{
+		tuple.NewTupleKey("document:1", "r10", "user:anne"),
+	}))
+
+	// r0 is a chain of 10 computed relations, which are resolved without a dispatch
+	var dsl strings.Builder
+	dsl.WriteString(`
+		model
+			schema 1.1
+		type user
+		type document
+			relations
+				define r10: [user]`)
+	for i := 9; i >= 0; i-- {
+		fmt.Fprintf(&dsl, "\n\t\t\t\tdefine r%d: r%d", i, i+1)
+	}
+
+	model := testutils.MustTransformDSLToProtoWithID(dsl.String())
+	ts, err := typesystem.New(model)
+	require.NoError(t, err)
+
+	check := func(opts ...LocalCheckerOption) (*ResolveCheckResponse, error) {
+		checker := NewLocalChecker(opts...)
+		t.Cleanup(checker.Close)
+
+		req, err := NewResolveCheckRequest(ResolveCheckRequestParams{
+			StoreID:              storeID,
+			AuthorizationModelID: model.GetId(),
+			TupleKey:             tuple.NewTupleKey("document:1", "r0", "user:anne"),
 		})
+		require.NoError(t, err)
+
+		return checker.ResolveCheck(setRequestContext(context.Background(), ts, ds, nil), req)
 	}
+
+	t.Run("under_the_node_limit_without_a_depth_limit", func(t *testing.T) {
+		resp, err := check(WithMaxResolutionDepth(2))
+		require.NoError(t, err)
+		require.True(t, resp.GetAllowed())
+	})
+
+	t.Run("within_the_depth_limit", func(t *testing.T) {
+		resp, err := check(WithMaxResolutionDepth(2), WithMaxRewriteDepth(11))
+		require.NoError(t, err)
+		require.True(t, resp.GetAllowed())
+	})
+
+	t.Run("exceeds_the_depth_limit", func(t *testing.T) {
+		_, err := check(WithMaxResolutionDepth(2), WithMaxRewriteDepth(10))
+		require.ErrorIs(t, err, ErrRewriteDepthExceeded)
+	})
 }
@@ -0,0 +1,321 @@
+package graph
+
+import (
+	"context"
+	"slices"
+	"strings"
+	"sync"
+
+	openfgav1 "github.com/openfga/api/proto/openfga/v1"
+
+	"github.com/openfga/openfga/pkg/storage"
+	"github.com/openfga/openfga/pkg/tuple"
+)
+
+// CheckTraceOperation is the rewrite operation that a node of a CheckTrace evaluates.
+type CheckTraceOperation string
+
+const (
+	CheckTraceThis           CheckTraceOperation = "this"
+	CheckTraceComputed       CheckTraceOperation = "computed"
+	CheckTraceTupleToUserset CheckTraceOperation = "tupleToUserset"
+	CheckTraceUnion          CheckTraceOperation = "union"
+	CheckTraceIntersection   CheckTraceOperation = "intersection"
+	CheckTraceDifference     CheckTraceOperation = "difference"
+)
+
+// CheckTrace is a node of the resolution tree of a Check resolved in trace mode. Every rewrite that is evaluated
+// is a node, whose children are the operands of a set operation, the rewritten relation of a computed userset,
+// or the subproblems dispatched by a direct relation or a tuple to userset rewrite.
+//
+// A subproblem that is answered without evaluating a rewrite, e.g. from the check cache or because it's part of
+// a cycle, is a leaf without an operation. The branches that are abandoned once the outcome of their parent is
+// known, e.g. the other operands of a union once one of them is allowed, are omitted.
+type CheckTrace struct {
+	// TupleKey is the 'object#relation@user' subproblem that the node evaluates.
+	TupleKey string
+	// Operation is the rewrite operation evaluated, empty for a subproblem answered without evaluating a rewrite.
+	Operation CheckTraceOperation
+	// Reads are the datastore reads made to evaluate the node, e.g. 'ReadUserTuple document:1#viewer@user:anne'.
+	// The reads made by its children are reported on the children.
+	Reads []string
+	// Allowed is the outcome of the node.
+	Allowed bool
+	// Contributed reports whether the outcome of the node determined the outcome of the Check. For example, the
+	// operands of an allowed union that are not allowed didn't contribute, and neither did their children.
+	Contributed bool
+	// FromCache reports whether the outcome was served from the check cache, or from the outcomes of the
+	// subproblems memoized during the Check or its session, rather than resolved.
+	FromCache bool
+	// Children are the nodes evaluated to resolve this one. The operands of a set operation are in the order of
+	// the rewrite, the other children in the order of their TupleKey.
+	Children []*CheckTrace
+
+	mu sync.Mutex
+	// completed is set once the outcome of the node is known, the node isn't modified afterward.
+	completed bool
+	// operands holds the operands of a set operation by their position in the rewrite until the node completes.
+	operands []*CheckTrace
+}
+
+// newCheckTraceLeaf returns the completed node of a subproblem answered without evaluating a rewrite.
+func newCheckTraceLeaf(tk *openfgav1.TupleKey, allowed, fromCache bool) *CheckTrace {
+	node := &CheckTrace{
+		TupleKey:  tuple.TupleKeyToString(tk),
+		FromCache: fromCache,
+	}
+	node.complete(allowed)
+	return node
+}
+
+// tracedFromCache returns the response of a subproblem served from the cache, with a leaf node of the
+// CheckTrace if the request is resolved in trace mode.
+func tracedFromCache(req *ResolveCheckRequest, tk *openfgav1.TupleKey, resp *ResolveCheckResponse) *ResolveCheckResponse {
+	if !req.GetTrace() {
+		return resp
+	}
+
+	traced := resp.clone()
+	traced.Trace = newCheckTraceLeaf(tk, resp.GetAllowed(), true)
+	return traced
+}
+
+// addRead records a datastore read made to evaluate the node.
+func (t *CheckTrace) addRead(read string) {
+	if t == nil {
+		return
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if !t.completed {
+		t.Reads = append(t.Reads, read)
+	}
+}
+
+// addChild records a child of the node. The children resolved after the node completed are dropped.
+func (t *CheckTrace) addChild(child *CheckTrace) {
+	if t == nil || child == nil {
+		return
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if !t.completed {
+		t.Children = append(t.Children, child)
+	}
+}
+
+// setOperand records the operand at position i of the set operation of the node.
+func (t *CheckTrace) setOperand(i int, operand *CheckTrace) {
+	if t == nil || operand == nil {
+		return
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.completed {
+		return
+	}
+	if i >= len(t.operands) {
+		t.operands = append(t.operands, make([]*CheckTrace, i+1-len(t.operands))...)
+	}
+	t.operands[i] = operand
+}
+
+// complete records the outcome of the node and whether each of its children contributed to it.
+func (t *CheckTrace) complete(allowed bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.completed = true
+	t.Allowed = allowed
+	t.Contributed = true
+
+	if t.operands != nil {
+		t.Children = t.Children[:0]
+		for i, operand := range t.operands {
+			if operand == nil {
+				continue
+			}
+			if !t.operandContributed(i, operand.Allowed) {
+				operand.clearContributed()
+			}
+			t.Children = append(t.Children, operand)
+		}
+		t.operands = nil
+		return
+	}
+
+	slices.SortStableFunc(t.Children, func(a, b *CheckTrace) int {
+		return strings.Compare(a.TupleKey, b.TupleKey)
+	})
+	for _, child := range t.Children {
+		if !t.operandContributed(0, child.Allowed) {
+			child.clearContributed()
+		}
+	}
+}
+
+// operandContributed reports whether an operand at position i with the given outcome determined the
+// outcome of the node. The children of the operations other than set operations are operands of a union.
+func (t *CheckTrace) operandContributed(i int, allowed bool) bool {
+	switch t.Operation {
+	case CheckTraceIntersection:
+		return t.Allowed || !allowed
+	case CheckTraceDifference:
+		if t.Allowed {
+			return true
+		}
+		// the base denied, or the subtracted operand allowed
+		return (i == 0) != allowed
+	default:
+		return !t.Allowed || allowed
+	}
+}
+
+// clearContributed marks the node and its children as not contributing to the outcome of the Check.
+func (t *CheckTrace) clearContributed() {
+	t.mu.Lock()
+	t.Contributed = false
+	children := t.Children
+	t.mu.Unlock()
+
+	for _, child := range children {
+		child.clearContributed()
+	}
+}
+
+type checkTraceContextKey struct{}
+
+// contextWithCheckTrace returns a context with the node of the CheckTrace being evaluated.
+func contextWithCheckTrace(ctx context.Context, node *CheckTrace) context.Context {
+	return context.WithValue(ctx, checkTraceContextKey{}, node)
+}
+
+// checkTraceFromContext returns the node of the CheckTrace being evaluated, or nil if the Check isn't resolved
+// in trace mode.
+func checkTraceFromContext(ctx context.Context) *CheckTrace {
+	node, _ := ctx.Value(checkTraceContextKey{}).(*CheckTrace)
+	return node
+}
+
+// checkTraceOperation returns the operation of the rewrite.
+func checkTraceOperation(rewrite *openfgav1.Userset) CheckTraceOperation {
+	switch rewrite.GetUserset().(type) {
+	case *openfgav1.Userset_This:
+		return CheckTraceThis
+	case *openfgav1.Userset_ComputedUserset:
+		return CheckTraceComputed
+	case *openfgav1.Userset_TupleToUserset:
+		return CheckTraceTupleToUserset
+	case *openfgav1.Userset_Union:
+		return CheckTraceUnion
+	case *openfgav1.Userset_Intersection:
+		return CheckTraceIntersection
+	case *openfgav1.Userset_Difference:
+		return CheckTraceDifference
+	default:
+		return ""
+	}
+}
+
+// tracedCheckHandler returns a CheckHandlerFunc that evaluates the handler of the rewrite of the request as a
+// node of the CheckTrace, which is returned in the Trace of the response.
+func tracedCheckHandler(req *ResolveCheckRequest, rewrite *openfgav1.Userset, handler CheckHandlerFunc) CheckHandlerFunc {
+	return func(ctx context.Context) (*ResolveCheckResponse, error) {
+		node := &CheckTrace{
+			TupleKey:  tuple.TupleKeyToString(req.GetTupleKey()),
+			Operation: checkTraceOperation(rewrite),
+		}
+
+		resp, err := handler(contextWithCheckTrace(ctx, node))
+		if err != nil {
+			return nil, err
+		}
+
+		node.complete(resp.GetAllowed())
+
+		// the response may be shared with the other operands of the parent, so it is not modified in place
+		traced := resp.clone()
+		traced.Trace = node
+		return traced, nil
+	}
+}
+
+// tracedOperand returns a CheckHandlerFunc that records the node of the handler as the operand at position i
+// of the set operation being evaluated.
+func tracedOperand(i int, handler CheckHandlerFunc) CheckHandlerFunc {
+	return func(ctx context.Context) (*ResolveCheckResponse, error) {
+		resp, err := handler(ctx)
+		if err != nil {
+			return nil, err
+		}
+
+		checkTraceFromContext(ctx).setOperand(i, resp.GetTrace())
+		return resp, nil
+	}
+}
+
+// checkTraceTupleReader records the reads of a Check resolved in trace mode on the node of the CheckTrace
+// being evaluated.
+type checkTraceTupleReader struct {
+	storage.RelationshipTupleReader
+}
+
+var _ storage.RelationshipTupleReader = (*checkTraceTupleReader)(nil)
+
+// Read see [storage.RelationshipTupleReader].Read.
+func (r *checkTraceTupleReader) Read(
+	ctx context.Context,
+	store string,
+	tupleKey *openfgav1.TupleKey,
+	options storage.ReadOptions,
+) (storage.TupleIterator, error) {
+	checkTraceFromContext(ctx).addRead("Read " + tuple.TupleKeyToString(tupleKey))
+	return r.RelationshipTupleReader.Read(ctx, store, tupleKey, options)
+}
+
+// ReadPage see [storage.RelationshipTupleReader].ReadPage.
+func (r *checkTraceTupleReader) ReadPage(
+	ctx context.Context,
+	store string,
+	tupleKey *openfgav1.TupleKey,
+	options storage.ReadPageOptions,
+) ([]*openfgav1.Tuple, string, error) {
+	checkTraceFromContext(ctx).addRead("ReadPage " + tuple.TupleKeyToString(tupleKey))
+	return r.RelationshipTupleReader.ReadPage(ctx, store, tupleKey, options)
+}
+
+// ReadUserTuple see [storage.RelationshipTupleReader].ReadUserTuple.
+func (r *checkTraceTupleReader) ReadUserTuple(
+	ctx context.Context,
+	store string,
+	tupleKey *openfgav1.TupleKey,
+	options storage.ReadUserTupleOptions,
+) (*openfgav1.Tuple, error) {
+	checkTraceFromContext(ctx).addRead("ReadUserTuple " + tuple.TupleKeyToString(tupleKey))
+	return r.RelationshipTupleReader.ReadUserTuple(ctx, store, tupleKey, options)
+}
+
+// ReadUsersetTuples see [storage.RelationshipTupleReader].ReadUsersetTuples.
+func (r *checkTraceTupleReader) ReadUsersetTuples(
+	ctx context.Context,
+	store string,
+	filter storage.ReadUsersetTuplesFilter,
+	options storage.ReadUsersetTuplesOptions,
+) (storage.TupleIterator, error) {
+	checkTraceFromContext(ctx).addRead("ReadUsersetTuples " + tuple.ToObjectRelationString(filter.Object, filter.Relation))
+	return r.RelationshipTupleReader.ReadUsersetTuples(ctx, store, filter, options)
+}
+
+// ReadStartingWithUser see [storage.RelationshipTupleReader].ReadStartingWithUser.
+func (r *checkTraceTupleReader) ReadStartingWithUser(
+	ctx context.Context,
+	store string,
+	filter storage.ReadStartingWithUserFilter,
+	options storage.ReadStartingWithUserOptions,
+) (storage.TupleIterator, error) {
+	checkTraceFromContext(ctx).addRead("ReadStartingWithUser " + tuple.ToObjectRelationString(filter.ObjectType, filter.Relation))
+	return r.RelationshipTupleReader.ReadStartingWithUser(ctx, store, filter, options)
+}
@@ -0,0 +1,232 @@
+package graph
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/oklog/ulid/v2"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/goleak"
+
+	openfgav1 "github.com/openfga/api/proto/openfga/v1"
+
+	"github.com/openfga/openfga/pkg/storage/memory"
+	"github.com/openfga/openfga/pkg/testutils"
+	"github.com/openfga/openfga/pkg/tuple"
+	"github.com/openfga/openfga/pkg/typesystem"
+)
+
+// renderCheckTrace renders the trace with a line per node and per read, indented by depth.
+func renderCheckTrace(trace *CheckTrace) string {
+	var b strings.Builder
+	var render func(node *CheckTrace, depth int)
+	render = func(node *CheckTrace, depth int) {
+		indent := strings.Repeat("  ", depth)
+		fmt.Fprintf(&b, "%s%s %s allowed=%t contributed=%t", indent, node.Operation, node.TupleKey, node.Allowed, node.Contributed)
+		if node.FromCache {
+			b.WriteString(" from_cache")
+		}
+		b.WriteString("\n")
+		for _, read := range node.Reads {
+			fmt.Fprintf(&b, "%s  read %s\n", indent, read)
+		}
+		for _, child := range node.Children {
+			render(child, depth+1)
+		}
+	}
+	render(trace, 0)
+	return b.String()
+}
+
+func TestCheckTrace(t *testing.T) {
+	t.Cleanup(func() {
+		goleak.VerifyNone(t)
+	})
+
+	ds := memory.New()
+	t.Cleanup(ds.Close)
+
+	storeID := ulid.Make().String()
+	require.NoError(t, ds.Write(context.Background(), storeID, nil, []*openfgav1.TupleKey{
+		tuple.NewTupleKey("document:1", "editor", "user:anne"),
+		tuple.NewTupleKey("document:1", "parent", "folder:a"),
+		tuple.NewTupleKey("document:1", "parent", "folder:b"),
+		tuple.NewTupleKey("document:2", "parent", "folder:a"),
+		tuple.NewTupleKey("folder:a", "viewer", "user:anne"),
+	}))
+
+	model := testutils.MustTransformDSLToProtoWithID(`
+		model
+			schema 1.1
+
+		type user
+		type folder
+			relations
+				define viewer: [user]
+		type document
+			relations
+				define parent: [folder]
+				define blocked: [user]
+				define editor: [user]
+				define can_edit: editor but not blocked
+				define viewer: viewer from parent`)
+
+	ts, err := typesystem.New(model)
+	require.NoError(t, err)
+
+	cachedResolver, err := NewCachedCheckResolver(WithCacheTTL(time.Hour))
+	require.NoError(t, err)
+	t.Cleanup(cachedResolver.Close)
+
+	checker := NewLocalChecker()
+	t.Cleanup(checker.Close)
+
+	cachedResolver.SetDelegate(checker)
+	checker.SetDelegate(cachedResolver)
+
+	check := func(t *testing.T, tk *openfgav1.TupleKey, traced bool) *ResolveCheckResponse {
+		req, err := NewResolveCheckRequest(ResolveCheckRequestParams{
+			StoreID:              storeID,
+			AuthorizationModelID: model.GetId(),
+			TupleKey:             tk,
+			Trace:                traced,
+		})
+		require.NoError(t, err)
+
+		ctx := setRequestContext(context.Background(), ts, ds, nil)
+		resp, err := cachedResolver.ResolveCheck(ctx, req)
+		require.NoError(t, err)
+		return resp
+	}
+
+	t.Run("set_operation", func(t *testing.T) {
+		resp := check(t, tuple.NewTupleKey("document:1", "can_edit", "user:anne"), true)
+		require.True(t, resp.GetAllowed())
+		require.Equal(t, `difference document:1#can_edit@user:anne allowed=true contributed=true
+  computed document:1#can_edit@user:anne allowed=true contributed=true
+    this document:1#editor@user:anne allowed=true contributed=true
+      read ReadUserTuple document:1#editor@user:anne
+  computed document:1#can_edit@user:anne allowed=false contributed=true
+    this document:1#blocked@user:anne allowed=false contributed=true
+      read ReadUserTuple document:1#blocked@user:anne
+`, renderCheckTrace(resp.GetTrace()))
+	})
+
+	t.Run("without_trace", func(t *testing.T) {
+		resp := check(t, tuple.NewTupleKey("document:1", "can_edit", "user:anne"), false)
+		require.True(t, resp.GetAllowed())
+		require.Nil(t, resp.GetTrace())
+	})
+
+	t.Run("dispatched_subproblems", func(t *testing.T) {
+		resp := check(t, tuple.NewTupleKey("document:1", "viewer", "user:bob"), true)
+		require.False(t, resp.GetAllowed())
+		require.Equal(t, `tupleToUserset document:1#viewer@user:bob allowed=false contributed=true
+  read Read document:1#parent@
+  this folder:a#viewer@user:bob allowed=false contributed=true
+    read ReadUserTuple folder:a#viewer@user:bob
+  this folder:b#viewer@user:bob allowed=false contributed=true
+    read ReadUserTuple folder:b#viewer@user:bob
+`, renderCheckTrace(resp.GetTrace()))
+	})
+
+	t.Run("cache_hits", func(t *testing.T) {
+		resp := check(t, tuple.NewTupleKey("folder:a", "viewer", "user:anne"), false)
+		require.True(t, resp.GetAllowed())
+
+		resp = check(t, tuple.NewTupleKey("document:2", "viewer", "user:anne"), true)
+		require.True(t, resp.GetAllowed())
+		require.Equal(t, `tupleToUserset document:2#viewer@user:anne allowed=true contributed=true
+  read Read document:2#parent@
+   folder:a#viewer@user:anne allowed=true contributed=true from_cache
+`, renderCheckTrace(resp.GetTrace()))
+
+		resp = check(t, tuple.NewTupleKey("document:2", "viewer", "user:anne"), true)
+		require.True(t, resp.GetAllowed())
+		require.Equal(t, ` document:2#viewer@user:anne allowed=true contributed=true from_cache
+`, renderCheckTrace(resp.GetTrace()))
+	})
+
+	t.Run("cached_responses_have_no_trace", func(t *testing.T) {
+		resp := check(t, tuple.NewTupleKey("document:2", "viewer", "user:anne"), false)
+		require.True(t, resp.GetAllowed())
+		require.Nil(t, resp.GetTrace())
+	})
+}
+
+func TestCheckTraceContributed(t *testing.T) {
+	leaf := func(tupleKey string, allowed bool) *CheckTrace {
+		node := &CheckTrace{TupleKey: tupleKey, Operation: CheckTraceThis}
+		node.complete(allowed)
+		return node
+	}
+
+	t.Run("allowed_union", func(t *testing.T) {
+		denied := &CheckTrace{TupleKey: "denied", Operation: CheckTraceComputed}
+		denied.addChild(leaf("denied_child", false))
+		denied.complete(false)
+
+		union := &CheckTrace{Operation: CheckTraceUnion}
+		union.setOperand(1, leaf("allowed", true))
+		union.setOperand(0, denied)
+		union.complete(true)
+
+		require.Len(t, union.Children, 2)
+		require.Equal(t, "denied", union.Children[0].TupleKey)
+		require.False(t, union.Children[0].Contributed)
+		require.False(t, union.Children[0].Children[0].Contributed)
+		require.True(t, union.Children[1].Contributed)
+	})
+
+	t.Run("denied_union", func(t *testing.T) {
+		union := &CheckTrace{Operation: CheckTraceUnion}
+		union.setOperand(0, leaf("a", false))
+		union.setOperand(1, leaf("b", false))
+		union.complete(false)
+
+		require.True(t, union.Children[0].Contributed)
+		require.True(t, union.Children[1].Contributed)
+	})
+
+	t.Run("denied_intersection", func(t *testing.T) {
+		intersection := &CheckTrace{Operation: CheckTraceIntersection}
+		intersection.setOperand(0, leaf("a", true))
+		intersection.setOperand(1, leaf("b", false))
+		intersection.complete(false)
+
+		require.False(t, intersection.Children[0].Contributed)
+		require.True(t, intersection.Children[1].Contributed)
+	})
+
+	t.Run("difference_denied_by_the_subtracted_operand", func(t *testing.T) {
+		difference := &CheckTrace{Operation: CheckTraceDifference}
+		difference.setOperand(0, leaf("base", true))
+		difference.setOperand(1, leaf("subtract", true))
+		difference.complete(false)
+
+		require.False(t, difference.Children[0].Contributed)
+		require.True(t, difference.Children[1].Contributed)
+	})
+
+	t.Run("difference_denied_by_the_base", func(t *testing.T) {
+		difference := &CheckTrace{Operation: CheckTraceDifference}
+		difference.setOperand(0, leaf("base", false))
+		difference.complete(false)
+
+		require.Len(t, difference.Children, 1)
+		require.True(t, difference.Children[0].Contributed)
+	})
+
+	t.Run("children_resolved_after_completion_are_dropped", func(t *testing.T) {
+		node := &CheckTrace{Operation: CheckTraceThis}
+		node.complete(true)
+
+		node.addChild(leaf("late", false))
+		node.addRead("late read")
+		require.Empty(t, node.Children)
+		require.Empty(t, node.Reads)
+	})
+}
@@ -0,0 +1,142 @@
+package graph
+
+import (
+	"context"
+	"errors"
+
+	openfgav1 "github.com/openfga/api/proto/openfga/v1"
+
+	"github.com/openfga/openfga/pkg/storage"
+)
+
+type datastoreErrorsMarkedCtxKey struct{}
+
+// datastoreError marks an error returned by the datastore while resolving a Check, see isDatastoreError.
+type datastoreError struct {
+	err error
+}
+
+func (e *datastoreError) Error() string {
+	return e.err.Error()
+}
+
+func (e *datastoreError) Unwrap() error {
+	return e.err
+}
+
+// markDatastoreError marks err as a datastore error, unless it's one of the outcomes of a successful read
+// or a cancellation of the request.
+func markDatastoreError(err error) error {
+	if err == nil ||
+		errors.Is(err, storage.ErrIteratorDone) ||
+		errors.Is(err, storage.ErrNotFound) ||
+		errors.Is(err, context.Canceled) ||
+		errors.Is(err, context.DeadlineExceeded) {
+		return err
+	}
+	return &datastoreError{err: err}
+}
+
+// contextWithDatastoreErrorReader wraps the relationship tuple reader of the context so that the errors it
+// returns are marked as datastore errors. The reader is wrapped once, dispatched subproblems inherit it.
+func contextWithDatastoreErrorReader(ctx context.Context) context.Context {
+	if ctx.Value(datastoreErrorsMarkedCtxKey{}) != nil {
+		return ctx
+	}
+
+	ds, ok := storage.RelationshipTupleReaderFromContext(ctx)
+	if !ok {
+		return ctx
+	}
+
+	ctx = context.WithValue(ctx, datastoreErrorsMarkedCtxKey{}, struct{}{})
+	return storage.ContextWithRelationshipTupleReader(ctx, &datastoreErrorTupleReader{RelationshipTupleReader: ds})
+}
+
+// datastoreErrorTupleReader is a storage.RelationshipTupleReader that marks the errors of the reads, including
+// the ones of the returned iterators, as datastore errors.
+type datastoreErrorTupleReader struct {
+	storage.RelationshipTupleReader
+}
+
+var _ storage.RelationshipTupleReader = (*datastoreErrorTupleReader)(nil)
+
+// Read see [storage.RelationshipTupleReader].Read.
+func (r *datastoreErrorTupleReader) Read(
+	ctx context.Context,
+	store string,
+	tupleKey *openfgav1.TupleKey,
+	options storage.ReadOptions,
+) (storage.TupleIterator, error) {
+	iter, err := r.RelationshipTupleReader.Read(ctx, store, tupleKey, options)
+	if err != nil {
+		return nil, markDatastoreError(err)
+	}
+	return &datastoreErrorTupleIterator{iter}, nil
+}
+
+// ReadPage see [storage.RelationshipTupleReader].ReadPage.
+func (r *datastoreErrorTupleReader) ReadPage(
+	ctx context.Context,
+	store string,
+	tupleKey *openfgav1.TupleKey,
+	options storage.ReadPageOptions,
+) ([]*openfgav1.Tuple, string, error) {
+	tuples, token, err := r.RelationshipTupleReader.ReadPage(ctx, store, tupleKey, options)
+	return tuples, token, markDatastoreError(err)
+}
+
+// ReadUserTuple see [storage.RelationshipTupleReader].ReadUserTuple.
+func (r *datastoreErrorTupleReader) ReadUserTuple(
+	ctx context.Context,
+	store string,
+	tupleKey *openfgav1.TupleKey,
+	options storage.ReadUserTupleOptions,
+) (*openfgav1.Tuple, error) {
+	t, err := r.RelationshipTupleReader.ReadUserTuple(ctx, store, tupleKey, options)
+	return t, markDatastoreError(err)
+}
+
+// ReadUsersetTuples see [storage.RelationshipTupleReader].ReadUsersetTuples.
+func (r *datastoreErrorTupleReader) ReadUsersetTuples(
+	ctx context.Context,
+	store string,
+	filter storage.ReadUsersetTuplesFilter,
+	options storage.ReadUsersetTuplesOptions,
+) (storage.TupleIterator, error) {
+	iter, err := r.RelationshipTupleReader.ReadUsersetTuples(ctx, store, filter, options)
+	if err != nil {
+		return nil, markDatastoreError(err)
+	}
+	return &datastoreErrorTupleIterator{iter}, nil
+}
+
+// ReadStartingWithUser see [storage.RelationshipTupleReader].ReadStartingWithUser.
+func (r *datastoreErrorTupleReader) ReadStartingWithUser(
+	ctx context.Context,
+	store string,
+	filter storage.ReadStartingWithUserFilter,
+	options storage.ReadStartingWithUserOptions,
+) (storage.TupleIterator, error) {
+	iter, err := r.RelationshipTupleReader.ReadStartingWithUser(ctx, store, filter, options)
+	if err != nil {
+		return nil, markDatastoreError(err)
+	}
+	return &datastoreErrorTupleIterator{iter}, nil
+}
+
+// datastoreErrorTupleIterator marks the errors of the iterator as datastore errors, as the iterators of
+// the SQL datastores query the datastore lazily.
+type datastoreErrorTupleIterator struct {
+	storage.TupleIterator
+}
+
+func (i *datastoreErrorTupleIterator) Next(ctx context.Context) (*openfgav1.Tuple, error) {
+	t, err := i.TupleIterator.Next(ctx)
+	return t, markDatastoreError(err)
+}
+
+func (i *datastoreErrorTupleIterator) Head(ctx context.Context) (*openfgav1.Tuple, error) {
+	t, err := i.TupleIterator.Head(ctx)
+	return t, markDatastoreError(err)
+}
@@ -49,8 +49,11 @@ type dispatchParams struct {
 }
 
 type dispatchMsg struct {
-	err            error
-	shortCircuit   bool
+	err          error
+	shortCircuit bool
+	// resolved is an outcome that is known without dispatching, used in scoring mode
+	// where an allowed outcome doesn't short circuit the evaluation.
+	resolved       *ResolveCheckResponse
 	dispatchParams *dispatchParams
 }
 
@@ -74,7 +77,7 @@ func (c *LocalChecker) defaultUserset(_ context.Context, req *ResolveCheckReques
 			return nil
 		})
 
-		return c.consumeDispatches(ctx, c.concurrencyLimit, dispatchChan)
+		return c.consumeDispatches(ctx, c.breadthLimit(), dispatchChan, req.GetScoring() != nil)
 	}
 }
 
@@ -101,6 +104,11 @@ func (c *LocalChecker) produceUsersetDispatches(ctx context.Context, req *Resolv
 			wildcardType := tuple.GetType(usersetObject)
 
 			if tuple.GetType(reqTupleKey.GetUser()) == wildcardType {
+				if req.GetScoring() != nil {
+					// a path through another userset may score higher than the wildcard
+					concurrency.TrySendThroughChannel(ctx, dispatchMsg{resolved: grantedResponse(req, wildcardScore)}, dispatches)
+					continue
+				}
 				concurrency.TrySendThroughChannel(ctx, dispatchMsg{shortCircuit: true}, dispatches)
 				break
 			}
@@ -136,7 +144,7 @@ func (c *LocalChecker) defaultTTU(_ context.Context, req *ResolveCheckRequest, r
 			return nil
 		})
 
-		return c.consumeDispatches(ctx, c.concurrencyLimit, dispatchChan)
+		return c.consumeDispatches(ctx, c.breadthLimit(), dispatchChan, req.GetScoring() != nil)
 	}
 }
 
@@ -172,7 +180,9 @@ func (c *LocalChecker) produceTTUDispatches(ctx context.Context, computedRelatio
 	}
 }
 
-func (c *LocalChecker) consumeDispatches(ctx context.Context, limit int, dispatchChan chan dispatchMsg) (*ResolveCheckResponse, error) {
+// consumeDispatches returns the first allowed outcome of the dispatches. If scored is true, it consumes
+// every dispatch and returns the allowed outcome with the highest score instead.
+func (c *LocalChecker) consumeDispatches(ctx context.Context, limit int, dispatchChan chan dispatchMsg, scored bool) (*ResolveCheckResponse, error) {
 	cancellableCtx, cancel := context.WithCancel(ctx)
 	outcomeChannel := c.processDispatches(cancellableCtx, limit, dispatchChan)
 
@@ -198,15 +208,28 @@ ConsumerLoop:
 			if outcome.resp.GetResolutionMetadata().CycleDetected {
 				finalResult.ResolutionMetadata.CycleDetected = true
 			}
+			// a denial depends on every dispatch, an allowed outcome only on its own, which isn't modified
+			if outcome.resp.GetDegraded() && !finalResult.Allowed {
+				finalResult.ResolutionMetadata.Degraded = true
+			}
 
 			if outcome.resp.Allowed {
-				finalErr = nil
-				finalResult = outcome.resp
-				break ConsumerLoop
+				if !scored {
+					finalErr = nil
+					finalResult = outcome.resp
+					break ConsumerLoop
+				}
+				if !finalResult.Allowed || outcome.resp.GetScore() > finalResult.GetScore() {
+					finalResult = outcome.resp
+				}
 			}
 		}
 	}
 	cancel() // prevent further processing of other checks
+	// an allowed outcome takes precedence over errors from other paths
+	if finalResult.Allowed {
+		finalErr = nil
+	}
 	// context cancellation from upstream (e.g. client)
 	if ctx.Err() != nil {
 		finalErr = ctx.Err()
@@ -222,6 +245,7 @@ ConsumerLoop:
 func (c *LocalChecker) processDispatches(ctx context.Context, limit int, dispatchChan chan dispatchMsg) <-chan checkOutcome {
 	outcomes := make(chan checkOutcome, limit)
 	dispatchPool := concurrency.NewPool(ctx, limit)
+	concurrencyLimiter := checkConcurrencyLimiterFromContext(ctx)
 
 	go func() {
 		defer func() {
@@ -242,6 +266,10 @@ func (c *LocalChecker) processDispatches(ctx context.Context, limit int, dispatc
 					concurrency.TrySendThroughChannel(ctx, checkOutcome{err: msg.err}, outcomes)
 					break // continue
 				}
+				if msg.resolved != nil {
+					concurrency.TrySendThroughChannel(ctx, checkOutcome{resp: msg.resolved}, outcomes)
+					break // continue
+				}
 				if msg.shortCircuit {
 					resp := &ResolveCheckResponse{
 						Allowed: true,
@@ -251,7 +279,7 @@ func (c *LocalChecker) processDispatches(ctx context.Context, limit int, dispatc
 				}
 
 				if msg.dispatchParams != nil {
-					dispatchPool.Go(func(ctx context.Context) error {
+					dispatch := func(ctx context.Context) {
 						recoveredError := panics.Try(func() {
 							resp, err := c.dispatch(ctx, msg.dispatchParams.parentReq, msg.dispatchParams.tk)(ctx)
 							concurrency.TrySendThroughChannel(ctx, checkOutcome{resp: resp, err: err}, outcomes)
@@ -263,6 +291,21 @@ func (c *LocalChecker) processDispatches(ctx context.Context, limit int, dispatc
 								outcomes,
 							)
 						}
+					}
+
+					acquired, err := concurrencyLimiter.acquire(ctx)
+					if err != nil {
+						return
+					}
+					if !acquired {
+						// the concurrency budget of the Check is spent, so the subproblem is evaluated sequentially
+						dispatch(ctx)
+						break // continue
+					}
+
+					dispatchPool.Go(func(ctx context.Context) error {
+						defer concurrencyLimiter.release()
+						dispatch(contextWithHeldConcurrencySlot(ctx))
 						return nil
 					})
 				}
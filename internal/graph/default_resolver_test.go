@@ -909,7 +909,7 @@ func TestConsumeDispatch(t *testing.T) {
 			}
 			close(dispatchMsgChan)
 
-			resp, err := checker.consumeDispatches(ctx, tt.limit, dispatchMsgChan)
+			resp, err := checker.consumeDispatches(ctx, tt.limit, dispatchMsgChan, false)
 			require.Equal(t, tt.expectedError, err)
 			require.Equal(t, tt.expected, resp)
 		})
@@ -929,7 +929,7 @@ func TestConsumeDispatch(t *testing.T) {
 		}
 		close(dispatchChan)
 
-		_, err := checker.consumeDispatches(ctx, 1, dispatchChan)
+		_, err := checker.consumeDispatches(ctx, 1, dispatchChan, false)
 
 		require.ErrorContains(t, err, "invalid memory address or nil pointer")
 		require.ErrorIs(t, err, ErrPanic)
@@ -21,6 +21,7 @@ const (
 
 var (
 	ErrResolutionDepthExceeded = errors.New("resolution depth exceeded")
+	ErrRewriteDepthExceeded    = errors.New("rewrite depth exceeded")
 )
 
 type findEdgeOption int
@@ -0,0 +1,25 @@
+package graph
+
+import (
+	"github.com/openfga/openfga/pkg/typesystem"
+)
+
+// WithModelIndexes sets the precomputed indexes of the models, keyed by model ID, see typesystem.BuildIndex. The
+// Checks against a model with an index look up whether the user can be related to a relation in the index
+// instead of analyzing the model, and fall back to the typesystem for what the index doesn't hold.
+func WithModelIndexes(indexes map[string]*typesystem.Index) LocalCheckerOption {
+	return func(d *LocalChecker) {
+		d.modelIndexes = indexes
+	}
+}
+
+// pathExists returns whether there is a path from the user to the objectType#relation in the model of the
+// typesystem, see typesystem.TypeSystem.PathExists, from the index of the model if there is one.
+func (c *LocalChecker) pathExists(typesys *typesystem.TypeSystem, user, relation, objectType string) (bool, error) {
+	if idx, ok := c.modelIndexes[typesys.GetAuthorizationModelID()]; ok {
+		if exists, ok := idx.PathExists(user, relation, objectType); ok {
+			return exists, nil
+		}
+	}
+	return typesys.PathExists(user, relation, objectType)
+}
@@ -0,0 +1,69 @@
+package graph
+
+import (
+	"context"
+	"sync"
+)
+
+// negatedSubtreeMemo caches the outcomes of the subproblems that are dispatched while resolving the
+// negated (subtracted) operand of an exclusion, for the duration of a Check request. Relations like
+// 'viewer but not banned' often resolve the same negated subproblems, e.g. 'org:acme#banned@user:jon',
+// for many sibling subproblems of a request. It is shared by all sub-problems of the request.
+type negatedSubtreeMemo struct {
+	results sync.Map // map: 'object#relation@user' => *ResolveCheckResponse
+}
+
+type negatedSubtreeCtxKey struct{}
+
+// WithNegatedSubtreeMemoization enables or disables the memoization of the subproblems resolved for the
+// negated operand of exclusions within a Check request. It is enabled by default. Outcomes are never
+// shared across requests, so they always reflect the tuples and the consistency of the request.
+func WithNegatedSubtreeMemoization(enabled bool) LocalCheckerOption {
+	return func(d *LocalChecker) {
+		d.negatedSubtreeMemoization = enabled
+	}
+}
+
+// withinNegatedSubtree returns a CheckHandlerFunc that marks the subproblems dispatched by fn as part
+// of a negated subtree.
+func withinNegatedSubtree(fn CheckHandlerFunc) CheckHandlerFunc {
+	return func(ctx context.Context) (*ResolveCheckResponse, error) {
+		return fn(context.WithValue(ctx, negatedSubtreeCtxKey{}, true))
+	}
+}
+
+// negatedSubtreeMemoFor returns the memo of the request if the subproblem of 'key' that is dispatched
+// from the parent request should be memoized, otherwise nil.
+func (c *LocalChecker) negatedSubtreeMemoFor(ctx context.Context, parentReq *ResolveCheckRequest, key string) *negatedSubtreeMemo {
+	if !c.negatedSubtreeMemoization {
+		return nil
+	}
+
+	if negated, _ := ctx.Value(negatedSubtreeCtxKey{}).(bool); !negated {
+		return nil
+	}
+
+	// a subproblem that is on the path to the parent is a cycle, which must be detected as such
+	if _, visited := parentReq.VisitedPaths[key]; visited {
+		return nil
+	}
+
+	return parentReq.GetRequestMetadata().getNegatedSubtreeMemo()
+}
+
+func (m *negatedSubtreeMemo) load(key string) (*ResolveCheckResponse, bool) {
+	resp, ok := m.results.Load(key)
+	if !ok {
+		return nil, false
+	}
+	return resp.(*ResolveCheckResponse).clone(), true
+}
+
+// store memoizes the outcome of a subproblem. Outcomes that depend on the path that the subproblem was
+// reached through, i.e. outcomes that detected a cycle, are not memoized.
+func (m *negatedSubtreeMemo) store(key string, resp *ResolveCheckResponse) {
+	if resp.GetCycleDetected() {
+		return
+	}
+	m.results.Store(key, resp.clone())
+}
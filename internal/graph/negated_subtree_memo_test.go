@@ -0,0 +1,198 @@
+package graph
+
+import (
+	"context"
+	"slices"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/oklog/ulid/v2"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/goleak"
+
+	openfgav1 "github.com/openfga/api/proto/openfga/v1"
+
+	serverconfig "github.com/openfga/openfga/pkg/server/config"
+	"github.com/openfga/openfga/pkg/storage"
+	"github.com/openfga/openfga/pkg/storage/memory"
+	"github.com/openfga/openfga/pkg/testutils"
+	"github.com/openfga/openfga/pkg/tuple"
+	"github.com/openfga/openfga/pkg/typesystem"
+)
+
+// relationReadCountingReader counts the reads of the tuples of a relation, which take delay each.
+type relationReadCountingReader struct {
+	storage.RelationshipTupleReader
+	relation string
+	delay    time.Duration
+	reads    atomic.Int32
+}
+
+func (r *relationReadCountingReader) ReadUserTuple(ctx context.Context, store string, tupleKey *openfgav1.TupleKey, options storage.ReadUserTupleOptions) (*openfgav1.Tuple, error) {
+	if tupleKey.GetRelation() == r.relation {
+		r.reads.Add(1)
+		time.Sleep(r.delay)
+	}
+	return r.RelationshipTupleReader.ReadUserTuple(ctx, store, tupleKey, options)
+}
+
+const negatedSubtreeMemoTestModel = `
+	model
+		schema 1.1
+
+	type user
+	type group
+		relations
+			define member: [user]
+	type org
+		relations
+			define banned: [user, group#member]
+	type folder
+		relations
+			define org: [org]
+			define viewer: [user]
+			define can_view: viewer but not banned from org
+	type document
+		relations
+			define parent: [folder]
+			define can_view: can_view from parent`
+
+// newNegatedSubtreeMemoTestStore writes a document with the given number of parent folders, which user:jon and
+// user:maria are viewers of and which all belong to org:acme, which bans user:jon.
+func newNegatedSubtreeMemoTestStore(t testing.TB, numFolders int) (storage.OpenFGADatastore, string) {
+	ds := memory.New()
+	t.Cleanup(ds.Close)
+
+	storeID := ulid.Make().String()
+	tuples := []*openfgav1.TupleKey{
+		tuple.NewTupleKey("org:acme", "banned", "user:jon"),
+	}
+	for i := 0; i < numFolders; i++ {
+		folder := "folder:" + strconv.Itoa(i)
+		tuples = append(tuples,
+			tuple.NewTupleKey("document:1", "parent", folder),
+			tuple.NewTupleKey(folder, "org", "org:acme"),
+			tuple.NewTupleKey(folder, "viewer", "user:jon"),
+			tuple.NewTupleKey(folder, "viewer", "user:maria"),
+		)
+	}
+	for chunk := range slices.Chunk(tuples, 100) {
+		require.NoError(t, ds.Write(context.Background(), storeID, nil, chunk))
+	}
+	return ds, storeID
+}
+
+func TestCheckWithNegatedSubtreeMemoization(t *testing.T) {
+	t.Cleanup(func() {
+		goleak.VerifyNone(t)
+	})
+
+	const numFolders = 20
+	ds, storeID := newNegatedSubtreeMemoTestStore(t, numFolders)
+
+	model := testutils.MustTransformDSLToProtoWithID(negatedSubtreeMemoTestModel)
+	ts, err := typesystem.New(model)
+	require.NoError(t, err)
+
+	check := func(t *testing.T, checker *LocalChecker, user string) (bool, int32) {
+		reader := &relationReadCountingReader{RelationshipTupleReader: ds, relation: "banned"}
+		ctx := setRequestContext(context.Background(), ts, reader, nil)
+
+		resp, err := checker.ResolveCheck(ctx, &ResolveCheckRequest{
+			StoreID:         storeID,
+			TupleKey:        tuple.NewTupleKey("document:1", "can_view", user),
+			RequestMetadata: NewCheckRequestMetadata(),
+		})
+		require.NoError(t, err)
+		return resp.GetAllowed(), reader.reads.Load()
+	}
+
+	t.Run("resolves_a_shared_negated_subproblem_once", func(t *testing.T) {
+		// resolve the siblings one at a time, so that every sibling can reuse the outcome of the previous ones
+		memoized := NewLocalChecker(WithResolutionStrategy(serverconfig.DepthFirstResolution))
+		t.Cleanup(memoized.Close)
+		unmemoized := NewLocalChecker(WithResolutionStrategy(serverconfig.DepthFirstResolution), WithNegatedSubtreeMemoization(false))
+		t.Cleanup(unmemoized.Close)
+
+		allowed, reads := check(t, unmemoized, "user:jon")
+		require.False(t, allowed)
+		require.Equal(t, int32(numFolders), reads)
+
+		allowed, reads = check(t, memoized, "user:jon")
+		require.False(t, allowed)
+		require.Equal(t, int32(1), reads)
+	})
+
+	t.Run("same_outcomes_as_without_memoization", func(t *testing.T) {
+		memoized := NewLocalChecker()
+		t.Cleanup(memoized.Close)
+		unmemoized := NewLocalChecker(WithNegatedSubtreeMemoization(false))
+		t.Cleanup(unmemoized.Close)
+
+		for _, user := range []string{"user:jon", "user:maria", "user:unknown"} {
+			expected, _ := check(t, unmemoized, user)
+			allowed, _ := check(t, memoized, user)
+			require.Equal(t, expected, allowed, user)
+		}
+	})
+
+	t.Run("reflects_membership_changes_of_the_negated_relation", func(t *testing.T) {
+		checker := NewLocalChecker()
+		t.Cleanup(checker.Close)
+
+		allowed, _ := check(t, checker, "user:maria")
+		require.True(t, allowed)
+
+		require.NoError(t, ds.Write(context.Background(), storeID, nil, []*openfgav1.TupleKey{
+			tuple.NewTupleKey("org:acme", "banned", "user:maria"),
+		}))
+		allowed, _ = check(t, checker, "user:maria")
+		require.False(t, allowed)
+
+		require.NoError(t, ds.Write(context.Background(), storeID, []*openfgav1.TupleKeyWithoutCondition{
+			{Object: "org:acme", Relation: "banned", User: "user:jon"},
+		}, nil))
+		allowed, _ = check(t, checker, "user:jon")
+		require.True(t, allowed)
+	})
+}
+
+// BenchmarkCheckWithNegatedSubtreeMemoization measures the latency of a Check on a document whose many parent
+// folders share the negated relation of their org, with and without memoizing the negated subproblems.
+func BenchmarkCheckWithNegatedSubtreeMemoization(b *testing.B) {
+	ds, storeID := newNegatedSubtreeMemoTestStore(b, 200)
+
+	model := testutils.MustTransformDSLToProtoWithID(negatedSubtreeMemoTestModel)
+	ts, err := typesystem.New(model)
+	require.NoError(b, err)
+
+	for name, enabled := range map[string]bool{
+		"without_memo": false,
+		"with_memo":    true,
+	} {
+		b.Run(name, func(b *testing.B) {
+			checker := NewLocalChecker(WithNegatedSubtreeMemoization(enabled))
+			defer checker.Close()
+
+			// user:jon is banned, so that every folder has to be resolved to deny the Check
+			reader := &relationReadCountingReader{RelationshipTupleReader: ds, relation: "banned", delay: 100 * time.Microsecond}
+			ctx := setRequestContext(context.Background(), ts, reader, nil)
+
+			b.ResetTimer()
+			for range b.N {
+				resp, err := checker.ResolveCheck(ctx, &ResolveCheckRequest{
+					StoreID:         storeID,
+					TupleKey:        tuple.NewTupleKey("document:1", "can_view", "user:jon"),
+					RequestMetadata: NewCheckRequestMetadata(),
+				})
+				require.NoError(b, err)
+				require.False(b, resp.GetAllowed())
+			}
+			b.StopTimer()
+
+			b.ReportMetric(float64(reader.reads.Load())/float64(b.N), "banned_reads/op")
+		})
+	}
+}
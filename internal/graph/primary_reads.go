@@ -0,0 +1,82 @@
+package graph
+
+import (
+	"context"
+	"slices"
+
+	openfgav1 "github.com/openfga/api/proto/openfga/v1"
+
+	"github.com/openfga/openfga/pkg/tuple"
+	"github.com/openfga/openfga/pkg/typesystem"
+)
+
+// readsPrimaryRelation returns whether the resolution of the subproblem of the request may read the tuples of
+// one of the 'objectType#relation' pairs that are read from the primary, i.e. whether the relation of the
+// request is one of them or reaches one of them through its rewrite or its directly related usersets. Without
+// a typesystem in the context, only the relation of the request is considered.
+func readsPrimaryRelation(ctx context.Context, req *ResolveCheckRequest, relations []string) bool {
+	if len(relations) == 0 {
+		return false
+	}
+
+	objectType := tuple.GetType(req.GetTupleKey().GetObject())
+	relation := req.GetTupleKey().GetRelation()
+
+	typesys, ok := typesystem.TypesystemFromContext(ctx)
+	if !ok {
+		return slices.Contains(relations, tuple.ToObjectRelationString(objectType, relation))
+	}
+
+	return reachesRelation(typesys, objectType, relation, relations, map[string]struct{}{})
+}
+
+// reachesRelation walks the relations that the resolution of objectType#relation may evaluate and returns
+// whether one of them is one of the targets. Relations that are not defined in the model are skipped.
+func reachesRelation(typesys *typesystem.TypeSystem, objectType, relation string, targets []string, visited map[string]struct{}) bool {
+	key := tuple.ToObjectRelationString(objectType, relation)
+	if slices.Contains(targets, key) {
+		return true
+	}
+	if _, ok := visited[key]; ok {
+		return false
+	}
+	visited[key] = struct{}{}
+
+	rel, err := typesys.GetRelation(objectType, relation)
+	if err != nil {
+		return false
+	}
+
+	result, _ := typesystem.WalkUsersetRewrite(rel.GetRewrite(), func(r *openfgav1.Userset) interface{} {
+		switch rw := r.GetUserset().(type) {
+		case *openfgav1.Userset_This:
+			for _, related := range rel.GetTypeInfo().GetDirectlyRelatedUserTypes() {
+				if related.GetRelation() != "" && reachesRelation(typesys, related.GetType(), related.GetRelation(), targets, visited) {
+					return true
+				}
+			}
+		case *openfgav1.Userset_ComputedUserset:
+			if reachesRelation(typesys, objectType, rw.ComputedUserset.GetRelation(), targets, visited) {
+				return true
+			}
+		case *openfgav1.Userset_TupleToUserset:
+			tupleset := rw.TupleToUserset.GetTupleset().GetRelation()
+			if reachesRelation(typesys, objectType, tupleset, targets, visited) {
+				return true
+			}
+
+			tuplesetRel, err := typesys.GetRelation(objectType, tupleset)
+			if err != nil {
+				return nil
+			}
+			for _, related := range tuplesetRel.GetTypeInfo().GetDirectlyRelatedUserTypes() {
+				if reachesRelation(typesys, related.GetType(), rw.TupleToUserset.GetComputedUserset().GetRelation(), targets, visited) {
+					return true
+				}
+			}
+		}
+		return nil
+	})
+
+	return result != nil
+}
@@ -282,7 +282,7 @@ func (c *LocalChecker) breadthFirstRecursiveMatch(ctx context.Context, req *Reso
 	relation := req.GetTupleKey().GetRelation()
 	user := req.GetTupleKey().GetUser()
 
-	pool := concurrency.NewPool(ctx, c.concurrencyLimit)
+	pool := concurrency.NewPool(ctx, c.breadthLimit())
 	mu := &sync.Mutex{}
 	nextUsersetLevel := hashset.New()
 
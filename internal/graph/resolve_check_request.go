@@ -13,6 +13,7 @@ import (
 	openfgav1 "github.com/openfga/api/proto/openfga/v1"
 
 	"github.com/openfga/openfga/pkg/storage"
+	"github.com/openfga/openfga/pkg/tuple"
 )
 
 type ResolveCheckRequest struct {
@@ -25,6 +26,26 @@ type ResolveCheckRequest struct {
 	VisitedPaths              map[string]struct{}
 	Consistency               openfgav1.ConsistencyPreference
 	LastCacheInvalidationTime time.Time
+	// ExcludedRelations is the set of 'objectType#relation' pairs that are treated as
+	// having no members during resolution. It is shared by all sub-problems and must not be modified.
+	ExcludedRelations map[string]struct{}
+	// AssumedFacts is the set of 'object#relation@user' subproblems that are treated as allowed
+	// without being resolved. It is shared by all sub-problems and must not be modified.
+	AssumedFacts map[string]struct{}
+	// Scoring enables scoring mode with the given rubric when set.
+	Scoring *CheckScoring
+	// Session memoizes the outcomes of subproblems across the Checks of a session when set.
+	Session *CheckSession
+	// WildcardPolicy determines the users that typed wildcards apply to.
+	WildcardPolicy tuple.WildcardPolicy
+	// CacheMode determines whether the check cache is read and written for this request and its sub-problems.
+	// Unlike HIGHER_CONSISTENCY, it doesn't change how the datastore is read.
+	CacheMode CacheMode
+	// CacheNamespace separates the cache entries of this request and its sub-problems from those of the
+	// requests of other namespaces, see BuildCacheKeyWithNamespace.
+	CacheNamespace string
+	// Trace resolves the request in trace mode, reporting its resolution tree in the Trace of the response.
+	Trace bool
 
 	// Invariant parts of a check request are those that don't change in sub-problems
 	// AuthorizationModelID, StoreID, Context, and ContextualTuples.
@@ -38,6 +59,10 @@ type ResolveCheckRequestMetadata struct {
 	// When we jump one level, we increment it by 1. If it hits maxResolutionDepth (resolveNodeLimit), we throw ErrResolutionDepthExceeded.
 	Depth uint32
 
+	// RewriteDepth is the number of rewrites expanded in the current path, including the ones that are resolved
+	// without a dispatch, e.g. computed usersets. If it exceeds maxRewriteDepth, we throw ErrRewriteDepthExceeded.
+	RewriteDepth uint32
+
 	// DispatchCounter is the address to a shared counter that keeps track of how many calls to ResolveCheck we had to do
 	// to solve the root/parent problem.
 	// The contents of this counter will be written by concurrent goroutines.
@@ -46,6 +71,16 @@ type ResolveCheckRequestMetadata struct {
 
 	// WasThrottled indicates whether the request was throttled
 	WasThrottled *atomic.Bool
+
+	// throttledLimit is the name of the first limit whose wait was cut short by the deadline of the root/parent
+	// problem, see ThrottledLimit.
+	throttledLimit *atomic.Pointer[string]
+
+	// usersetOracleMemo caches the results of the userset oracles consulted while solving the root/parent problem.
+	usersetOracleMemo *usersetOracleMemo
+
+	// negatedSubtreeMemo caches the outcomes of the negated subproblems resolved while solving the root/parent problem.
+	negatedSubtreeMemo *negatedSubtreeMemo
 }
 
 type ResolveCheckRequestParams struct {
@@ -56,13 +91,76 @@ type ResolveCheckRequestParams struct {
 	Consistency               openfgav1.ConsistencyPreference
 	LastCacheInvalidationTime time.Time
 	AuthorizationModelID      string
+	// ExcludedRelations are 'objectType#relation' pairs to treat as having no members.
+	ExcludedRelations []string
+	// AssumedFacts are subproblems to treat as allowed, on top of the stored and contextual tuples.
+	AssumedFacts []*openfgav1.TupleKey
+	// Scoring enables scoring mode with the given rubric when set.
+	Scoring *CheckScoring
+	// Session memoizes the outcomes of subproblems across the Checks of a session when set.
+	Session *CheckSession
+	// WildcardPolicy determines the users that typed wildcards apply to.
+	WildcardPolicy tuple.WildcardPolicy
+	// CacheMode determines whether the check cache is read and written for the request.
+	CacheMode CacheMode
+	// CacheNamespace separates the cache entries of the request from those of other namespaces.
+	CacheNamespace string
+	// ModelDeltaFingerprint identifies the changes layered over the authorization model
+	// AuthorizationModelID, if the request is resolved against a merged model.
+	ModelDeltaFingerprint string
+	// Trace resolves the request in trace mode.
+	Trace bool
 }
 
 func NewCheckRequestMetadata() *ResolveCheckRequestMetadata {
 	return &ResolveCheckRequestMetadata{
-		DispatchCounter: new(atomic.Uint32),
-		WasThrottled:    new(atomic.Bool),
+		DispatchCounter:    new(atomic.Uint32),
+		WasThrottled:       new(atomic.Bool),
+		throttledLimit:     new(atomic.Pointer[string]),
+		usersetOracleMemo:  &usersetOracleMemo{},
+		negatedSubtreeMemo: &negatedSubtreeMemo{},
+	}
+}
+
+// ThrottledLimit returns the name of the first limit, e.g. ResolveNodeBreadthLimitName, that a subproblem of the
+// root/parent problem was still waiting for when its deadline passed, or "" if none was.
+func (m *ResolveCheckRequestMetadata) ThrottledLimit() string {
+	if m == nil || m.throttledLimit == nil {
+		return ""
+	}
+	if limit := m.throttledLimit.Load(); limit != nil {
+		return *limit
+	}
+	return ""
+}
+
+func (m *ResolveCheckRequestMetadata) setThrottledLimit(limit string) {
+	if m == nil || m.throttledLimit == nil {
+		return
+	}
+	m.throttledLimit.CompareAndSwap(nil, &limit)
+}
+
+func (m *ResolveCheckRequestMetadata) getUsersetOracleMemo() *usersetOracleMemo {
+	if m == nil {
+		return nil
+	}
+	return m.usersetOracleMemo
+}
+
+// usersetOracleConsulted returns true if a userset oracle has been consulted while solving the root/parent
+// problem. The outcomes of such problems must not outlive the request, as the oracle may answer differently
+// for the next one.
+func (m *ResolveCheckRequestMetadata) usersetOracleConsulted() bool {
+	memo := m.getUsersetOracleMemo()
+	return memo != nil && memo.consulted.Load()
+}
+
+func (m *ResolveCheckRequestMetadata) getNegatedSubtreeMemo() *negatedSubtreeMemo {
+	if m == nil {
+		return nil
 	}
+	return m.negatedSubtreeMemo
 }
 
 func NewResolveCheckRequest(
@@ -76,6 +174,22 @@ func NewResolveCheckRequest(
 		return nil, errors.New("missing store_id")
 	}
 
+	var excludedRelations map[string]struct{}
+	if len(params.ExcludedRelations) > 0 {
+		excludedRelations = make(map[string]struct{}, len(params.ExcludedRelations))
+		for _, excluded := range params.ExcludedRelations {
+			excludedRelations[excluded] = struct{}{}
+		}
+	}
+
+	var assumedFacts map[string]struct{}
+	if len(params.AssumedFacts) > 0 {
+		assumedFacts = make(map[string]struct{}, len(params.AssumedFacts))
+		for _, fact := range params.AssumedFacts {
+			assumedFacts[tuple.TupleKeyToString(fact)] = struct{}{}
+		}
+	}
+
 	r := &ResolveCheckRequest{
 		StoreID:              params.StoreID,
 		AuthorizationModelID: params.AuthorizationModelID,
@@ -87,14 +201,27 @@ func NewResolveCheckRequest(
 		Consistency:          params.Consistency,
 		// avoid having to read from cache consistently by propagating it
 		LastCacheInvalidationTime: params.LastCacheInvalidationTime,
+		ExcludedRelations:         excludedRelations,
+		AssumedFacts:              assumedFacts,
+		Scoring:                   params.Scoring,
+		Session:                   params.Session,
+		WildcardPolicy:            params.WildcardPolicy,
+		CacheMode:                 params.CacheMode,
+		CacheNamespace:            params.CacheNamespace,
+		Trace:                     params.Trace,
 	}
 
 	keyBuilder := &strings.Builder{}
 	err := storage.WriteInvariantCheckCacheKey(keyBuilder, &storage.CheckCacheKeyParams{
-		StoreID:              params.StoreID,
-		AuthorizationModelID: params.AuthorizationModelID,
-		ContextualTuples:     params.ContextualTuples.GetTupleKeys(),
-		Context:              params.Context,
+		StoreID:               params.StoreID,
+		AuthorizationModelID:  params.AuthorizationModelID,
+		ContextualTuples:      params.ContextualTuples.GetTupleKeys(),
+		Context:               params.Context,
+		ExcludedRelations:     params.ExcludedRelations,
+		AssumedFacts:          params.AssumedFacts,
+		Scoring:               params.Scoring.cacheKey(),
+		WildcardPolicy:        wildcardPolicyCacheKey(params.WildcardPolicy),
+		ModelDeltaFingerprint: params.ModelDeltaFingerprint,
 	})
 	if err != nil {
 		return nil, err
@@ -110,9 +237,13 @@ func (r *ResolveCheckRequest) clone() *ResolveCheckRequest {
 	origRequestMetadata := r.GetRequestMetadata()
 	if origRequestMetadata != nil {
 		requestMetadata = &ResolveCheckRequestMetadata{
-			DispatchCounter: origRequestMetadata.DispatchCounter,
-			Depth:           origRequestMetadata.Depth,
-			WasThrottled:    origRequestMetadata.WasThrottled,
+			DispatchCounter:    origRequestMetadata.DispatchCounter,
+			Depth:              origRequestMetadata.Depth,
+			RewriteDepth:       origRequestMetadata.RewriteDepth,
+			WasThrottled:       origRequestMetadata.WasThrottled,
+			throttledLimit:     origRequestMetadata.throttledLimit,
+			usersetOracleMemo:  origRequestMetadata.usersetOracleMemo,
+			negatedSubtreeMemo: origRequestMetadata.negatedSubtreeMemo,
 		}
 	}
 
@@ -131,6 +262,14 @@ func (r *ResolveCheckRequest) clone() *ResolveCheckRequest {
 		VisitedPaths:              maps.Clone(r.GetVisitedPaths()),
 		Consistency:               r.GetConsistency(),
 		LastCacheInvalidationTime: r.GetLastCacheInvalidationTime(),
+		ExcludedRelations:         r.GetExcludedRelations(),
+		AssumedFacts:              r.GetAssumedFacts(),
+		Scoring:                   r.GetScoring(),
+		Session:                   r.GetSession(),
+		WildcardPolicy:            r.GetWildcardPolicy(),
+		CacheMode:                 r.GetCacheMode(),
+		CacheNamespace:            r.GetCacheNamespace(),
+		Trace:                     r.GetTrace(),
 		invariantCacheKey:         r.GetInvariantCacheKey(),
 	}
 }
@@ -198,6 +337,89 @@ func (r *ResolveCheckRequest) GetLastCacheInvalidationTime() time.Time {
 	return r.LastCacheInvalidationTime
 }
 
+func (r *ResolveCheckRequest) GetExcludedRelations() map[string]struct{} {
+	if r == nil {
+		return nil
+	}
+	return r.ExcludedRelations
+}
+
+// IsRelationExcluded returns true if the objectType#relation is treated as having no members.
+func (r *ResolveCheckRequest) IsRelationExcluded(objectType, relation string) bool {
+	if len(r.GetExcludedRelations()) == 0 {
+		return false
+	}
+	_, ok := r.ExcludedRelations[tuple.ToObjectRelationString(objectType, relation)]
+	return ok
+}
+
+func (r *ResolveCheckRequest) GetAssumedFacts() map[string]struct{} {
+	if r == nil {
+		return nil
+	}
+	return r.AssumedFacts
+}
+
+// IsFactAssumed returns true if the object#relation@user of the tuple key is treated as allowed.
+func (r *ResolveCheckRequest) IsFactAssumed(tupleKey *openfgav1.TupleKey) bool {
+	if len(r.GetAssumedFacts()) == 0 {
+		return false
+	}
+	_, ok := r.AssumedFacts[tuple.TupleKeyToString(tupleKey)]
+	return ok
+}
+
+func (r *ResolveCheckRequest) GetScoring() *CheckScoring {
+	if r == nil {
+		return nil
+	}
+	return r.Scoring
+}
+
+func (r *ResolveCheckRequest) GetSession() *CheckSession {
+	if r == nil {
+		return nil
+	}
+	return r.Session
+}
+
+func (r *ResolveCheckRequest) GetWildcardPolicy() tuple.WildcardPolicy {
+	if r == nil {
+		return tuple.WildcardPolicyIncludeAnonymous
+	}
+	return r.WildcardPolicy
+}
+
+func (r *ResolveCheckRequest) GetCacheMode() CacheMode {
+	if r == nil {
+		return CacheReadWrite
+	}
+	return r.CacheMode
+}
+
+func (r *ResolveCheckRequest) GetCacheNamespace() string {
+	if r == nil {
+		return ""
+	}
+	return r.CacheNamespace
+}
+
+func (r *ResolveCheckRequest) GetTrace() bool {
+	if r == nil {
+		return false
+	}
+	return r.Trace
+}
+
+// wildcardPolicyCacheKey returns the part of the cache key that identifies the wildcard policy. It is empty
+// for the default policy, so that the cache keys of the stores with the default policy are unchanged.
+func wildcardPolicyCacheKey(policy tuple.WildcardPolicy) string {
+	if policy == tuple.WildcardPolicyIncludeAnonymous {
+		return ""
+	}
+	return policy.String()
+}
+
 func (r *ResolveCheckRequest) GetInvariantCacheKey() string {
 	if r == nil {
 		return ""
@@ -10,19 +10,29 @@ type ResolveCheckResponseMetadata struct {
 	CycleDetected bool
 	// The total time it took to resolve the check request.
 	Duration time.Duration
+	// Indicates the response was derived from a stale cache entry, served
+	// because the datastore could not be read or within the stale grace
+	// of the cache. Degraded responses are not cached.
+	Degraded bool
 }
 
-// clone clones the provided ResolveCheckResponse.
+// clone clones the provided ResolveCheckResponse, without its Trace.
 func (r *ResolveCheckResponse) clone() *ResolveCheckResponse {
 	return &ResolveCheckResponse{
 		Allowed:            r.GetAllowed(),
+		Score:              r.GetScore(),
 		ResolutionMetadata: r.GetResolutionMetadata(),
 	}
 }
 
 type ResolveCheckResponse struct {
-	Allowed            bool
+	Allowed bool
+	// Score is the strength of the winning path of an allowed Check resolved in scoring mode.
+	// It is zero when the Check is not allowed or is not resolved in scoring mode.
+	Score              float64
 	ResolutionMetadata ResolveCheckResponseMetadata
+	// Trace is the resolution tree of a request resolved in trace mode. It is nil otherwise.
+	Trace *CheckTrace
 }
 
 func (r *ResolveCheckResponse) GetCycleDetected() bool {
@@ -32,6 +42,13 @@ func (r *ResolveCheckResponse) GetCycleDetected() bool {
 	return r.GetResolutionMetadata().CycleDetected
 }
 
+func (r *ResolveCheckResponse) GetDegraded() bool {
+	if r == nil {
+		return false
+	}
+	return r.GetResolutionMetadata().Degraded
+}
+
 func (r *ResolveCheckResponse) GetAllowed() bool {
 	if r == nil {
 		return false
@@ -39,9 +56,23 @@ func (r *ResolveCheckResponse) GetAllowed() bool {
 	return r.Allowed
 }
 
+func (r *ResolveCheckResponse) GetScore() float64 {
+	if r == nil {
+		return 0
+	}
+	return r.Score
+}
+
 func (r *ResolveCheckResponse) GetResolutionMetadata() ResolveCheckResponseMetadata {
 	if r == nil {
 		return ResolveCheckResponseMetadata{}
 	}
 	return r.ResolutionMetadata
 }
+
+func (r *ResolveCheckResponse) GetTrace() *CheckTrace {
+	if r == nil {
+		return nil
+	}
+	return r.Trace
+}
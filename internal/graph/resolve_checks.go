@@ -0,0 +1,128 @@
+package graph
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/sourcegraph/conc/panics"
+)
+
+// ResolveChecksError reports the requests of a ResolveChecks call that failed.
+type ResolveChecksError struct {
+	// Errs holds the error of each request, at the position of the request. It is nil for the requests
+	// that were resolved.
+	Errs []error
+}
+
+func (e *ResolveChecksError) Error() string {
+	var (
+		failed int
+		first  error
+	)
+	for _, err := range e.Errs {
+		if err != nil {
+			failed++
+			if first == nil {
+				first = err
+			}
+		}
+	}
+	return fmt.Sprintf("%d of %d checks failed, first error: %v", failed, len(e.Errs), first)
+}
+
+// Unwrap returns the errors of the requests that failed.
+func (e *ResolveChecksError) Unwrap() []error {
+	var errs []error
+	for _, err := range e.Errs {
+		if err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errs
+}
+
+// ResolveChecks resolves a batch of independent Checks, e.g. the candidate objects of ListObjects, through the
+// dispatch delegate of the checker, so that they go through the resolvers that wrap it, e.g. the check cache and
+// the throttling, see the ResolveChecks function.
+func (c *LocalChecker) ResolveChecks(ctx context.Context, reqs []*ResolveCheckRequest) ([]*ResolveCheckResponse, error) {
+	return ResolveChecks(ctx, c.delegate, reqs, c.concurrencyLimit)
+}
+
+// ResolveChecks resolves a batch of independent Checks with the resolver, with at most concurrencyLimit in flight.
+// Identical requests, which have the same cache key, are resolved once and share their response, so the requests
+// must be built with NewResolveCheckRequest to be deduplicated. The response of each request is returned at its
+// position. If any request fails, the responses of the others are still returned, along with a
+// *ResolveChecksError that holds the error of each request. The requests that aren't started before the context
+// is done fail with its error.
+func ResolveChecks(ctx context.Context, resolver CheckResolver, reqs []*ResolveCheckRequest, concurrencyLimit int) ([]*ResolveCheckResponse, error) {
+	// map: position of a request => position of the first identical request, which is resolved for both
+	resolvedBy := make([]int, len(reqs))
+	firstByKey := make(map[string]int, len(reqs))
+	for i, req := range reqs {
+		resolvedBy[i] = i
+
+		// without an invariant cache key, the cache keys of requests with different stores, models or contexts collide
+		if req.GetInvariantCacheKey() == "" {
+			continue
+		}
+
+		key := BuildCacheKeyWithNamespace(*req, req.GetCacheNamespace())
+		if first, ok := firstByKey[key]; ok {
+			resolvedBy[i] = first
+			continue
+		}
+		firstByKey[key] = i
+	}
+
+	resps := make([]*ResolveCheckResponse, len(reqs))
+	errs := make([]error, len(reqs))
+
+	limiter := make(chan struct{}, max(concurrencyLimit, 1))
+	var wg sync.WaitGroup
+	for i, req := range reqs {
+		if resolvedBy[i] != i {
+			continue
+		}
+
+		select {
+		case limiter <- struct{}{}:
+		case <-ctx.Done():
+			errs[i] = ctx.Err()
+			continue
+		}
+
+		wg.Add(1)
+		go func() {
+			defer func() {
+				<-limiter
+				wg.Done()
+			}()
+
+			recoveredError := panics.Try(func() {
+				resps[i], errs[i] = resolver.ResolveCheck(ctx, req)
+			})
+			if recoveredError != nil {
+				resps[i], errs[i] = nil, fmt.Errorf("%w: %s", ErrPanic, recoveredError.AsError())
+			}
+		}()
+	}
+	wg.Wait()
+
+	failed := false
+	for i := range reqs {
+		if first := resolvedBy[i]; first != i {
+			if resps[first] != nil {
+				// a copy avoids races between the callers of identical requests
+				resps[i] = resps[first].clone()
+			}
+			errs[i] = errs[first]
+		}
+		failed = failed || errs[i] != nil
+	}
+
+	if failed {
+		return resps, &ResolveChecksError{Errs: errs}
+	}
+	return resps, nil
+}
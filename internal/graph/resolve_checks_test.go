@@ -0,0 +1,201 @@
+package graph
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+
+	"github.com/oklog/ulid/v2"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/goleak"
+
+	openfgav1 "github.com/openfga/api/proto/openfga/v1"
+
+	"github.com/openfga/openfga/pkg/storage"
+	"github.com/openfga/openfga/pkg/storage/memory"
+	"github.com/openfga/openfga/pkg/testutils"
+	"github.com/openfga/openfga/pkg/tuple"
+	"github.com/openfga/openfga/pkg/typesystem"
+)
+
+// objectCountingReader counts the user tuples read per object.
+type objectCountingReader struct {
+	storage.RelationshipTupleReader
+	mu    sync.Mutex
+	reads map[string]int
+}
+
+func (r *objectCountingReader) ReadUserTuple(ctx context.Context, store string, tupleKey *openfgav1.TupleKey, options storage.ReadUserTupleOptions) (*openfgav1.Tuple, error) {
+	r.mu.Lock()
+	r.reads[tupleKey.GetObject()]++
+	r.mu.Unlock()
+	return r.RelationshipTupleReader.ReadUserTuple(ctx, store, tupleKey, options)
+}
+
+func TestResolveChecks(t *testing.T) {
+	t.Cleanup(func() {
+		goleak.VerifyNone(t)
+	})
+
+	ds := memory.New()
+	t.Cleanup(ds.Close)
+
+	storeID := ulid.Make().String()
+
+	err := ds.Write(context.Background(), storeID, nil, []*openfgav1.TupleKey{
+		tuple.NewTupleKey("document:1", "viewer", "user:anne"),
+	})
+	require.NoError(t, err)
+
+	model := testutils.MustTransformDSLToProtoWithID(`
+		model
+			schema 1.1
+
+		type user
+		type document
+			relations
+				define viewer: [user]`)
+
+	ts, err := typesystem.New(model)
+	require.NoError(t, err)
+
+	checker := NewLocalChecker(WithResolveNodeBreadthLimit(2))
+	t.Cleanup(checker.Close)
+
+	newRequest := func(t *testing.T, object, relation string) *ResolveCheckRequest {
+		req, err := NewResolveCheckRequest(ResolveCheckRequestParams{
+			StoreID:              storeID,
+			AuthorizationModelID: model.GetId(),
+			TupleKey:             tuple.NewTupleKey(object, relation, "user:anne"),
+		})
+		require.NoError(t, err)
+		return req
+	}
+
+	t.Run("deduplicates_identical_requests", func(t *testing.T) {
+		reader := &objectCountingReader{RelationshipTupleReader: ds, reads: map[string]int{}}
+		ctx := typesystem.ContextWithTypesystem(context.Background(), ts)
+		ctx = storage.ContextWithRelationshipTupleReader(ctx, reader)
+
+		resps, err := checker.ResolveChecks(ctx, []*ResolveCheckRequest{
+			newRequest(t, "document:1", "viewer"),
+			newRequest(t, "document:2", "viewer"),
+			newRequest(t, "document:1", "viewer"),
+			newRequest(t, "document:3", "editor"),
+			newRequest(t, "document:1", "viewer"),
+		})
+
+		var resolveChecksErr *ResolveChecksError
+		require.ErrorAs(t, err, &resolveChecksErr)
+		require.Len(t, resolveChecksErr.Errs, 5)
+		for i, err := range resolveChecksErr.Errs {
+			if i == 3 {
+				require.ErrorContains(t, err, "relation 'editor' undefined")
+				continue
+			}
+			require.NoError(t, err)
+		}
+
+		require.Len(t, resps, 5)
+		require.True(t, resps[0].GetAllowed())
+		require.False(t, resps[1].GetAllowed())
+		require.True(t, resps[2].GetAllowed())
+		require.Nil(t, resps[3])
+		require.True(t, resps[4].GetAllowed())
+
+		// identical requests get copies of the same response
+		require.NotSame(t, resps[0], resps[2])
+		require.NotSame(t, resps[0], resps[4])
+
+		require.Equal(t, map[string]int{"document:1": 1, "document:2": 1}, reader.reads)
+	})
+
+	t.Run("bounded_concurrency", func(t *testing.T) {
+		reader := &concurrencyCountingReader{RelationshipTupleReader: ds}
+		ctx := typesystem.ContextWithTypesystem(context.Background(), ts)
+		ctx = storage.ContextWithRelationshipTupleReader(ctx, reader)
+
+		reqs := make([]*ResolveCheckRequest, 0, 10)
+		for i := 0; i < 10; i++ {
+			reqs = append(reqs, newRequest(t, fmt.Sprintf("document:%d", i), "viewer"))
+		}
+
+		resps, err := checker.ResolveChecks(ctx, reqs)
+		require.NoError(t, err)
+		for i, resp := range resps {
+			require.Equal(t, i == 1, resp.GetAllowed())
+		}
+		require.LessOrEqual(t, reader.maxInflight.Load(), int32(2))
+	})
+
+	t.Run("through_the_resolver_chain", func(t *testing.T) {
+		ctx := typesystem.ContextWithTypesystem(context.Background(), ts)
+		ctx = storage.ContextWithRelationshipTupleReader(ctx, ds)
+
+		cachedResolver, err := NewCachedCheckResolver()
+		require.NoError(t, err)
+		t.Cleanup(cachedResolver.Close)
+
+		localChecker := NewLocalChecker()
+		t.Cleanup(localChecker.Close)
+		cachedResolver.SetDelegate(localChecker)
+		localChecker.SetDelegate(cachedResolver)
+
+		resps, err := ResolveChecks(ctx, cachedResolver, []*ResolveCheckRequest{
+			newRequest(t, "document:1", "viewer"),
+			newRequest(t, "document:2", "viewer"),
+		}, 2)
+		require.NoError(t, err)
+		require.True(t, resps[0].GetAllowed())
+		require.False(t, resps[1].GetAllowed())
+
+		// the responses are cached by the resolver that wraps the checker, so they outlive the tuple
+		err = ds.Write(context.Background(), storeID, []*openfgav1.TupleKeyWithoutCondition{
+			tuple.TupleKeyToTupleKeyWithoutCondition(tuple.NewTupleKey("document:1", "viewer", "user:anne")),
+		}, nil)
+		require.NoError(t, err)
+		t.Cleanup(func() {
+			err := ds.Write(context.Background(), storeID, nil, []*openfgav1.TupleKey{
+				tuple.NewTupleKey("document:1", "viewer", "user:anne"),
+			})
+			require.NoError(t, err)
+		})
+
+		resps, err = localChecker.ResolveChecks(ctx, []*ResolveCheckRequest{
+			newRequest(t, "document:1", "viewer"),
+		})
+		require.NoError(t, err)
+		require.True(t, resps[0].GetAllowed())
+	})
+
+	t.Run("cancelled_context", func(t *testing.T) {
+		ctx := typesystem.ContextWithTypesystem(context.Background(), ts)
+		ctx = storage.ContextWithRelationshipTupleReader(ctx, ds)
+		ctx, cancel := context.WithCancel(ctx)
+		cancel()
+
+		resps, err := ResolveChecks(ctx, &blockingResolver{}, []*ResolveCheckRequest{
+			newRequest(t, "document:1", "viewer"),
+			newRequest(t, "document:2", "viewer"),
+			newRequest(t, "document:3", "viewer"),
+		}, 1)
+
+		var resolveChecksErr *ResolveChecksError
+		require.ErrorAs(t, err, &resolveChecksErr)
+		require.Len(t, resps, 3)
+		for _, err := range resolveChecksErr.Errs {
+			require.ErrorIs(t, err, context.Canceled)
+		}
+	})
+}
+
+// blockingResolver resolves the Checks once their context is done, with its error.
+type blockingResolver struct {
+	CheckResolver
+}
+
+func (r *blockingResolver) ResolveCheck(ctx context.Context, _ *ResolveCheckRequest) (*ResolveCheckResponse, error) {
+	<-ctx.Done()
+	return nil, ctx.Err()
+}
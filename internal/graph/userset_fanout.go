@@ -0,0 +1,76 @@
+package graph
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+
+	openfgav1 "github.com/openfga/api/proto/openfga/v1"
+
+	serverconfig "github.com/openfga/openfga/pkg/server/config"
+	"github.com/openfga/openfga/pkg/storage"
+)
+
+// ErrUsersetFanoutExceeded is returned when the usersets related to an object through a relation
+// outnumber the maximum userset fan-out, see WithMaxUsersetFanout.
+var ErrUsersetFanoutExceeded = errors.New("userset fan-out exceeded")
+
+// WithMaxUsersetFanout caps the number of userset tuples, e.g. 'document:1#viewer@group:eng#member', that are
+// expanded for a single object and relation. The tuples are counted after the ones of userset types that the
+// model doesn't allow are filtered out. What happens when the cap is exceeded is set by WithUsersetFanoutBehavior.
+// A limit of 0 doesn't cap the fan-out.
+func WithMaxUsersetFanout(limit uint32) LocalCheckerOption {
+	return func(d *LocalChecker) {
+		d.maxUsersetFanout = limit
+	}
+}
+
+// WithUsersetFanoutBehavior sets what happens when the usersets of an object and relation exceed the maximum
+// userset fan-out. With serverconfig.UsersetFanoutError the Check fails with ErrUsersetFanoutExceeded, while
+// with serverconfig.UsersetFanoutDeny the usersets beyond the cap aren't expanded, so the users that are only
+// related through them are denied.
+func WithUsersetFanoutBehavior(behavior serverconfig.UsersetFanoutBehavior) LocalCheckerOption {
+	return func(d *LocalChecker) {
+		d.usersetFanoutBehavior = behavior
+	}
+}
+
+// fanoutLimitedIterator is an iterator over the userset tuples of a node that shares the count of the
+// tuples it returned with the other iterators of the node.
+type fanoutLimitedIterator struct {
+	storage.TupleKeyIterator
+	count    *atomic.Uint32
+	limit    uint32
+	behavior serverconfig.UsersetFanoutBehavior
+}
+
+var _ storage.TupleKeyIterator = (*fanoutLimitedIterator)(nil)
+
+func (f *fanoutLimitedIterator) Next(ctx context.Context) (*openfgav1.TupleKey, error) {
+	tk, err := f.TupleKeyIterator.Next(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	if f.count.Add(1) > f.limit {
+		if f.behavior == serverconfig.UsersetFanoutDeny {
+			return nil, storage.ErrIteratorDone
+		}
+		return nil, ErrUsersetFanoutExceeded
+	}
+
+	return tk, nil
+}
+
+// limitUsersetFanout caps the number of tuples returned by the iterators of one node, which share the count.
+func (c *LocalChecker) limitUsersetFanout(iter storage.TupleKeyIterator, count *atomic.Uint32) storage.TupleKeyIterator {
+	if c.maxUsersetFanout == 0 {
+		return iter
+	}
+	return &fanoutLimitedIterator{
+		TupleKeyIterator: iter,
+		count:            count,
+		limit:            c.maxUsersetFanout,
+		behavior:         c.usersetFanoutBehavior,
+	}
+}
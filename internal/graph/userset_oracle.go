@@ -0,0 +1,119 @@
+package graph
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+
+	openfgav1 "github.com/openfga/api/proto/openfga/v1"
+
+	"github.com/openfga/openfga/pkg/tuple"
+)
+
+// UsersetOracle resolves the membership of users in the usersets of a relation from a system external
+// to OpenFGA, e.g. the group memberships of an identity provider.
+type UsersetOracle interface {
+	// IsMember returns true if the user is a member of the userset 'object#relation'.
+	IsMember(ctx context.Context, object, relation, user string) (bool, error)
+}
+
+// WithUsersetOracle designates the oracle that resolves the membership of users in the usersets of
+// 'objectType#relation'. The LocalChecker consults the oracle instead of evaluating the rewrite of the
+// relation, so neither the stored tuples nor the contextual tuples of the relation are read. The results
+// of the oracle are cached for the duration of a Check request only: the outcomes of requests that
+// consulted an oracle are neither cached by the CachedCheckResolver nor memoized by Check sessions.
+func WithUsersetOracle(objectType, relation string, oracle UsersetOracle) LocalCheckerOption {
+	return func(d *LocalChecker) {
+		if d.usersetOracles == nil {
+			d.usersetOracles = make(map[string]UsersetOracle)
+		}
+		d.usersetOracles[tuple.ToObjectRelationString(objectType, relation)] = oracle
+	}
+}
+
+// hasUsersetOracle returns true if an oracle resolves the memberships of any of the usersets, e.g. 'group#member'.
+// The memberships of such usersets must be dispatched to be resolved by the oracle, rather than read from the
+// tuples by the strategies that don't dispatch them.
+func (c *LocalChecker) hasUsersetOracle(usersets []*openfgav1.RelationReference) bool {
+	for _, userset := range usersets {
+		if _, ok := c.usersetOracles[tuple.ToObjectRelationString(userset.GetType(), userset.GetRelation())]; ok {
+			return true
+		}
+	}
+	return false
+}
+
+// usersetOracleMemo caches the results of the oracles for the duration of a Check request.
+// It is shared by all sub-problems of the request.
+type usersetOracleMemo struct {
+	results sync.Map // map: 'object#relation@user' => *usersetOracleResult
+
+	// consulted is set once an oracle has been consulted while solving the request
+	consulted atomic.Bool
+}
+
+// usersetOracleResult is the result of an oracle lookup, which is available once done is closed.
+type usersetOracleResult struct {
+	done     chan struct{}
+	isMember bool
+	err      error
+}
+
+// checkUsersetOracle resolves the request with the oracle. Sub-problems of the same request that ask for the
+// same tuple, even concurrently, share a single lookup. Failed lookups are not cached.
+func checkUsersetOracle(ctx context.Context, req *ResolveCheckRequest, oracle UsersetOracle) (*ResolveCheckResponse, error) {
+	ctx, span := tracer.Start(ctx, "checkUsersetOracle")
+	defer span.End()
+
+	tk := req.GetTupleKey()
+
+	memo := req.GetRequestMetadata().getUsersetOracleMemo()
+	if memo == nil {
+		isMember, err := oracle.IsMember(ctx, tk.GetObject(), tk.GetRelation(), tk.GetUser())
+		if err != nil {
+			return nil, err
+		}
+		return usersetOracleResponse(req, isMember), nil
+	}
+	memo.consulted.Store(true)
+
+	key := tuple.TupleKeyToString(tk)
+	result := &usersetOracleResult{done: make(chan struct{})}
+	if existing, loaded := memo.results.LoadOrStore(key, result); loaded {
+		existing := existing.(*usersetOracleResult)
+		select {
+		case <-existing.done:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+
+		if existing.err == nil {
+			return usersetOracleResponse(req, existing.isMember), nil
+		}
+		// the lookup failed for the sub-problem that made it, e.g. because it was cancelled
+		return checkUsersetOracle(ctx, req, oracle)
+	}
+
+	result.isMember, result.err = oracle.IsMember(ctx, tk.GetObject(), tk.GetRelation(), tk.GetUser())
+	if result.err != nil {
+		memo.results.Delete(key)
+	}
+	close(result.done)
+
+	if result.err != nil {
+		return nil, result.err
+	}
+
+	return usersetOracleResponse(req, result.isMember), nil
+}
+
+func usersetOracleResponse(req *ResolveCheckRequest, isMember bool) *ResolveCheckResponse {
+	if !isMember {
+		return &ResolveCheckResponse{
+			Allowed: false,
+		}
+	}
+
+	// the oracle vouches for the membership directly
+	return grantedResponse(req, directScore)
+}
@@ -0,0 +1,204 @@
+package graph
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+
+	"github.com/oklog/ulid/v2"
+	"github.com/stretchr/testify/require"
+
+	openfgav1 "github.com/openfga/api/proto/openfga/v1"
+
+	"github.com/openfga/openfga/pkg/storage/memory"
+	"github.com/openfga/openfga/pkg/testutils"
+	"github.com/openfga/openfga/pkg/tuple"
+	"github.com/openfga/openfga/pkg/typesystem"
+)
+
+// staticUsersetOracle is a UsersetOracle backed by a fixed set of 'object#relation@user' memberships.
+type staticUsersetOracle struct {
+	members map[string]bool
+	calls   atomic.Int32
+}
+
+func (o *staticUsersetOracle) IsMember(_ context.Context, object, relation, user string) (bool, error) {
+	o.calls.Add(1)
+	return o.members[tuple.TupleKeyToString(tuple.NewTupleKey(object, relation, user))], nil
+}
+
+func TestCheckWithUsersetOracle(t *testing.T) {
+	ds := memory.New()
+	t.Cleanup(ds.Close)
+
+	storeID := ulid.Make().String()
+
+	err := ds.Write(context.Background(), storeID, nil, []*openfgav1.TupleKey{
+		tuple.NewTupleKey("document:1", "viewer", "group:eng#member"),
+		tuple.NewTupleKey("document:1", "editor", "group:eng#member"),
+		tuple.NewTupleKey("document:1", "viewer", "team:ops#member"),
+		// membership tuples of groups are ignored in favor of the oracle
+		tuple.NewTupleKey("group:eng", "member", "user:jon"),
+		tuple.NewTupleKey("team:ops", "member", "user:maria"),
+	})
+	require.NoError(t, err)
+
+	model := testutils.MustTransformDSLToProtoWithID(`
+		model
+			schema 1.1
+
+		type user
+		type group
+			relations
+				define member: [user]
+		type team
+			relations
+				define member: [user]
+		type document
+			relations
+				define editor: [group#member]
+				define viewer: [group#member, team#member] or editor`)
+
+	ts, err := typesystem.New(model)
+	require.NoError(t, err)
+
+	tests := []struct {
+		name    string
+		user    string
+		allowed bool
+	}{
+		{
+			name:    "oracle_grants_membership_without_tuples",
+			user:    "user:anne",
+			allowed: true,
+		},
+		{
+			name:    "oracle_overrides_membership_tuples",
+			user:    "user:jon",
+			allowed: false,
+		},
+		{
+			name:    "relations_without_an_oracle_fall_back_to_tuples",
+			user:    "user:maria",
+			allowed: true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			oracle := &staticUsersetOracle{members: map[string]bool{
+				"group:eng#member@user:anne": true,
+			}}
+
+			checker := NewLocalChecker(WithUsersetOracle("group", "member", oracle))
+			t.Cleanup(checker.Close)
+
+			ctx := setRequestContext(context.Background(), ts, ds, nil)
+			resp, err := checker.ResolveCheck(ctx, &ResolveCheckRequest{
+				StoreID:         storeID,
+				TupleKey:        tuple.NewTupleKey("document:1", "viewer", test.user),
+				RequestMetadata: NewCheckRequestMetadata(),
+			})
+			require.NoError(t, err)
+			require.Equal(t, test.allowed, resp.GetAllowed())
+		})
+	}
+
+	t.Run("oracle_results_are_cached_within_the_request", func(t *testing.T) {
+		oracle := &staticUsersetOracle{}
+
+		checker := NewLocalChecker(WithUsersetOracle("group", "member", oracle))
+		t.Cleanup(checker.Close)
+
+		// group:eng#member is reached both through viewer and through editor
+		ctx := setRequestContext(context.Background(), ts, ds, nil)
+		resp, err := checker.ResolveCheck(ctx, &ResolveCheckRequest{
+			StoreID:         storeID,
+			TupleKey:        tuple.NewTupleKey("document:1", "viewer", "user:bob"),
+			RequestMetadata: NewCheckRequestMetadata(),
+		})
+		require.NoError(t, err)
+		require.False(t, resp.GetAllowed())
+		require.Equal(t, int32(1), oracle.calls.Load())
+	})
+
+	t.Run("oracle_results_are_not_cached_across_requests", func(t *testing.T) {
+		oracle := &staticUsersetOracle{members: map[string]bool{
+			"group:eng#member@user:anne": true,
+		}}
+
+		cachedResolver, err := NewCachedCheckResolver()
+		require.NoError(t, err)
+		t.Cleanup(cachedResolver.Close)
+
+		checker := NewLocalChecker(WithUsersetOracle("group", "member", oracle))
+		t.Cleanup(checker.Close)
+		cachedResolver.SetDelegate(checker)
+		checker.SetDelegate(cachedResolver)
+
+		check := func() bool {
+			req, err := NewResolveCheckRequest(ResolveCheckRequestParams{
+				StoreID:              storeID,
+				AuthorizationModelID: model.GetId(),
+				TupleKey:             tuple.NewTupleKey("document:1", "viewer", "user:anne"),
+			})
+			require.NoError(t, err)
+
+			ctx := setRequestContext(context.Background(), ts, ds, nil)
+			resp, err := cachedResolver.ResolveCheck(ctx, req)
+			require.NoError(t, err)
+			return resp.GetAllowed()
+		}
+
+		require.True(t, check())
+
+		// the membership is revoked in the system of the oracle
+		oracle.members = nil
+		require.False(t, check())
+	})
+}
+
+func TestHasUsersetOracle(t *testing.T) {
+	checker := NewLocalChecker(WithUsersetOracle("group", "member", &staticUsersetOracle{}))
+	t.Cleanup(checker.Close)
+
+	require.False(t, checker.hasUsersetOracle(nil))
+	require.False(t, checker.hasUsersetOracle([]*openfgav1.RelationReference{
+		typesystem.DirectRelationReference("team", "member"),
+		typesystem.DirectRelationReference("group", "admin"),
+	}))
+	require.True(t, checker.hasUsersetOracle([]*openfgav1.RelationReference{
+		typesystem.DirectRelationReference("team", "member"),
+		typesystem.DirectRelationReference("group", "member"),
+	}))
+
+	t.Run("usersets_of_tuple_to_userset", func(t *testing.T) {
+		model := testutils.MustTransformDSLToProtoWithID(`
+			model
+				schema 1.1
+
+			type user
+			type group
+				relations
+					define member: [user]
+			type folder
+				relations
+					define member: [user]
+			type document
+				relations
+					define parent: [group, folder]
+					define viewer: member from parent`)
+
+		ts, err := typesystem.New(model)
+		require.NoError(t, err)
+
+		usersets := ttuUsersets(ts, "document", "parent", "member")
+		require.Equal(t, []*openfgav1.RelationReference{
+			typesystem.DirectRelationReference("group", "member"),
+			typesystem.DirectRelationReference("folder", "member"),
+		}, usersets)
+		require.True(t, checker.hasUsersetOracle(usersets))
+
+		require.False(t, checker.hasUsersetOracle(ttuUsersets(ts, "document", "parent", "viewer")))
+	})
+}
@@ -78,6 +78,18 @@ func (m *MockInMemoryCache[T]) EXPECT() *MockInMemoryCacheMockRecorder[T] {
 	return m.recorder
 }
 
+// Clear mocks base method.
+func (m *MockInMemoryCache[T]) Clear() {
+	m.ctrl.T.Helper()
+	m.ctrl.Call(m, "Clear")
+}
+
+// Clear indicates an expected call of Clear.
+func (mr *MockInMemoryCacheMockRecorder[T]) Clear() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Clear", reflect.TypeOf((*MockInMemoryCache[T])(nil).Clear))
+}
+
 // Delete mocks base method.
 func (m *MockInMemoryCache[T]) Delete(key string) {
 	m.ctrl.T.Helper()
@@ -12,6 +12,7 @@ package mocks
 import (
 	context "context"
 	reflect "reflect"
+	time "time"
 
 	openfgav1 "github.com/openfga/api/proto/openfga/v1"
 	storage "github.com/openfga/openfga/pkg/storage"
@@ -308,6 +309,45 @@ func (mr *MockRelationshipTupleWriterMockRecorder) Write(ctx, store, d, w any, o
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Write", reflect.TypeOf((*MockRelationshipTupleWriter)(nil).Write), varargs...)
 }
 
+// MockBestEffortTupleWriter is a mock of BestEffortTupleWriter interface.
+type MockBestEffortTupleWriter struct {
+	ctrl     *gomock.Controller
+	recorder *MockBestEffortTupleWriterMockRecorder
+	isgomock struct{}
+}
+
+// MockBestEffortTupleWriterMockRecorder is the mock recorder for MockBestEffortTupleWriter.
+type MockBestEffortTupleWriterMockRecorder struct {
+	mock *MockBestEffortTupleWriter
+}
+
+// NewMockBestEffortTupleWriter creates a new mock instance.
+func NewMockBestEffortTupleWriter(ctrl *gomock.Controller) *MockBestEffortTupleWriter {
+	mock := &MockBestEffortTupleWriter{ctrl: ctrl}
+	mock.recorder = &MockBestEffortTupleWriterMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockBestEffortTupleWriter) EXPECT() *MockBestEffortTupleWriterMockRecorder {
+	return m.recorder
+}
+
+// WriteBestEffort mocks base method.
+func (m *MockBestEffortTupleWriter) WriteBestEffort(ctx context.Context, store string, writes storage.Writes) ([]storage.TupleWriteResult, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "WriteBestEffort", ctx, store, writes)
+	ret0, _ := ret[0].([]storage.TupleWriteResult)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// WriteBestEffort indicates an expected call of WriteBestEffort.
+func (mr *MockBestEffortTupleWriterMockRecorder) WriteBestEffort(ctx, store, writes any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "WriteBestEffort", reflect.TypeOf((*MockBestEffortTupleWriter)(nil).WriteBestEffort), ctx, store, writes)
+}
+
 // MockAuthorizationModelReadBackend is a mock of AuthorizationModelReadBackend interface.
 type MockAuthorizationModelReadBackend struct {
 	ctrl     *gomock.Controller
@@ -612,6 +652,35 @@ func (mr *MockStoresBackendMockRecorder) ListStores(ctx, options any) *gomock.Ca
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListStores", reflect.TypeOf((*MockStoresBackend)(nil).ListStores), ctx, options)
 }
 
+// ReadStoreMetadata mocks base method.
+func (m *MockStoresBackend) ReadStoreMetadata(ctx context.Context, store string) (*storage.StoreMetadata, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ReadStoreMetadata", ctx, store)
+	ret0, _ := ret[0].(*storage.StoreMetadata)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ReadStoreMetadata indicates an expected call of ReadStoreMetadata.
+func (mr *MockStoresBackendMockRecorder) ReadStoreMetadata(ctx, store any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ReadStoreMetadata", reflect.TypeOf((*MockStoresBackend)(nil).ReadStoreMetadata), ctx, store)
+}
+
+// WriteStoreMetadata mocks base method.
+func (m *MockStoresBackend) WriteStoreMetadata(ctx context.Context, store string, metadata *storage.StoreMetadata) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "WriteStoreMetadata", ctx, store, metadata)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// WriteStoreMetadata indicates an expected call of WriteStoreMetadata.
+func (mr *MockStoresBackendMockRecorder) WriteStoreMetadata(ctx, store, metadata any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "WriteStoreMetadata", reflect.TypeOf((*MockStoresBackend)(nil).WriteStoreMetadata), ctx, store, metadata)
+}
+
 // MockAssertionsBackend is a mock of AssertionsBackend interface.
 type MockAssertionsBackend struct {
 	ctrl     *gomock.Controller
@@ -689,6 +758,20 @@ func (m *MockChangelogBackend) EXPECT() *MockChangelogBackendMockRecorder {
 	return m.recorder
 }
 
+// CompactChangeLog mocks base method.
+func (m *MockChangelogBackend) CompactChangeLog(ctx context.Context, store string, olderThan time.Time) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CompactChangeLog", ctx, store, olderThan)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// CompactChangeLog indicates an expected call of CompactChangeLog.
+func (mr *MockChangelogBackendMockRecorder) CompactChangeLog(ctx, store, olderThan any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CompactChangeLog", reflect.TypeOf((*MockChangelogBackend)(nil).CompactChangeLog), ctx, store, olderThan)
+}
+
 // ReadChanges mocks base method.
 func (m *MockChangelogBackend) ReadChanges(ctx context.Context, store string, filter storage.ReadChangesFilter, options storage.ReadChangesOptions) ([]*openfgav1.TupleChange, string, error) {
 	m.ctrl.T.Helper()
@@ -741,6 +824,20 @@ func (mr *MockOpenFGADatastoreMockRecorder) Close() *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Close", reflect.TypeOf((*MockOpenFGADatastore)(nil).Close))
 }
 
+// CompactChangeLog mocks base method.
+func (m *MockOpenFGADatastore) CompactChangeLog(ctx context.Context, store string, olderThan time.Time) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CompactChangeLog", ctx, store, olderThan)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// CompactChangeLog indicates an expected call of CompactChangeLog.
+func (mr *MockOpenFGADatastoreMockRecorder) CompactChangeLog(ctx, store, olderThan any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CompactChangeLog", reflect.TypeOf((*MockOpenFGADatastore)(nil).CompactChangeLog), ctx, store, olderThan)
+}
+
 // CreateStore mocks base method.
 func (m *MockOpenFGADatastore) CreateStore(ctx context.Context, store *openfgav1.Store) (*openfgav1.Store, error) {
 	m.ctrl.T.Helper()
@@ -967,6 +1064,21 @@ func (mr *MockOpenFGADatastoreMockRecorder) ReadStartingWithUser(ctx, store, fil
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ReadStartingWithUser", reflect.TypeOf((*MockOpenFGADatastore)(nil).ReadStartingWithUser), ctx, store, filter, options)
 }
 
+// ReadStoreMetadata mocks base method.
+func (m *MockOpenFGADatastore) ReadStoreMetadata(ctx context.Context, store string) (*storage.StoreMetadata, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ReadStoreMetadata", ctx, store)
+	ret0, _ := ret[0].(*storage.StoreMetadata)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ReadStoreMetadata indicates an expected call of ReadStoreMetadata.
+func (mr *MockOpenFGADatastoreMockRecorder) ReadStoreMetadata(ctx, store any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ReadStoreMetadata", reflect.TypeOf((*MockOpenFGADatastore)(nil).ReadStoreMetadata), ctx, store)
+}
+
 // ReadUserTuple mocks base method.
 func (m *MockOpenFGADatastore) ReadUserTuple(ctx context.Context, store string, tupleKey *openfgav1.TupleKey, options storage.ReadUserTupleOptions) (*openfgav1.Tuple, error) {
 	m.ctrl.T.Helper()
@@ -1043,3 +1155,17 @@ func (mr *MockOpenFGADatastoreMockRecorder) WriteAuthorizationModel(ctx, store,
 	mr.mock.ctrl.T.Helper()
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "WriteAuthorizationModel", reflect.TypeOf((*MockOpenFGADatastore)(nil).WriteAuthorizationModel), ctx, store, model)
 }
+
+// WriteStoreMetadata mocks base method.
+func (m *MockOpenFGADatastore) WriteStoreMetadata(ctx context.Context, store string, metadata *storage.StoreMetadata) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "WriteStoreMetadata", ctx, store, metadata)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// WriteStoreMetadata indicates an expected call of WriteStoreMetadata.
+func (mr *MockOpenFGADatastoreMockRecorder) WriteStoreMetadata(ctx, store, metadata any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "WriteStoreMetadata", reflect.TypeOf((*MockOpenFGADatastore)(nil).WriteStoreMetadata), ctx, store, metadata)
+}
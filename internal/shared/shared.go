@@ -49,6 +49,26 @@ type SharedDatastoreResources struct {
 	ShadowCacheController cachecontroller.CacheController
 	Logger                logger.Logger
 	SharedIteratorStorage *sharediterator.Storage
+
+	evictionListenersMu sync.RWMutex
+	evictionListeners   []func(key string, value any) // GUARDED_BY(evictionListenersMu).
+}
+
+// OnCheckCacheEviction registers a function that is called with the key and the value of the entries that
+// leave CheckCache, see storage.WithEvictionCallback. It is only called if CheckCache was created by
+// NewSharedDatastoreResources, not for a cache set by other means.
+func (s *SharedDatastoreResources) OnCheckCacheEviction(listener func(key string, value any)) {
+	s.evictionListenersMu.Lock()
+	defer s.evictionListenersMu.Unlock()
+	s.evictionListeners = append(s.evictionListeners, listener)
+}
+
+func (s *SharedDatastoreResources) notifyCheckCacheEviction(key string, value any, _ storage.EvictReason) {
+	s.evictionListenersMu.RLock()
+	defer s.evictionListenersMu.RUnlock()
+	for _, listener := range s.evictionListeners {
+		listener(key, value)
+	}
 }
 
 func NewSharedDatastoreResources(
@@ -71,9 +91,9 @@ func NewSharedDatastoreResources(
 
 	if settings.ShouldCreateNewCache() {
 		var err error
-		s.CheckCache, err = storage.NewInMemoryLRUCache([]storage.InMemoryLRUCacheOpt[any]{
-			storage.WithMaxCacheSize[any](int64(settings.CheckCacheLimit)),
-		}...)
+		s.CheckCache, err = storage.NewInMemoryLRUCache(append(checkCacheSizeOptions(settings),
+			storage.WithEvictionCallback(s.notifyCheckCacheEviction),
+		)...)
 		if err != nil {
 			return nil, err
 		}
@@ -92,9 +112,7 @@ func NewSharedDatastoreResources(
 
 	if settings.ShouldCreateShadowNewCache() {
 		var err error
-		s.ShadowCheckCache, err = storage.NewInMemoryLRUCache([]storage.InMemoryLRUCacheOpt[any]{
-			storage.WithMaxCacheSize[any](int64(settings.CheckCacheLimit)),
-		}...)
+		s.ShadowCheckCache, err = storage.NewInMemoryLRUCache(checkCacheSizeOptions(settings)...)
 		if err != nil {
 			return nil, err
 		}
@@ -111,6 +129,20 @@ func NewSharedDatastoreResources(
 	return s, nil
 }
 
+// checkCacheSizeOptions bounds the size of the check caches by the cost budget of the settings, if any, or else
+// by their limit of entries.
+func checkCacheSizeOptions(settings serverconfig.CacheSettings) []storage.InMemoryLRUCacheOpt[any] {
+	if settings.CheckCacheCostBudget > 0 {
+		return []storage.InMemoryLRUCacheOpt[any]{
+			storage.WithMaxCacheSize[any](settings.CheckCacheCostBudget),
+			storage.WithCostFunc(storage.CacheEntryCost),
+		}
+	}
+	return []storage.InMemoryLRUCacheOpt[any]{
+		storage.WithMaxCacheSize[any](int64(settings.CheckCacheLimit)),
+	}
+}
+
 func (s *SharedDatastoreResources) Close() {
 	// wait for any goroutines still in flight before
 	// closing the cache instance to avoid data races
@@ -3,6 +3,7 @@ package shared
 import (
 	"context"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/require"
 	"go.uber.org/mock/gomock"
@@ -11,6 +12,7 @@ import (
 	"github.com/openfga/openfga/internal/cachecontroller"
 	mockstorage "github.com/openfga/openfga/internal/mocks"
 	"github.com/openfga/openfga/pkg/server/config"
+	"github.com/openfga/openfga/pkg/storage"
 )
 
 func TestSharedDatastoreResources(t *testing.T) {
@@ -50,6 +52,32 @@ func TestSharedDatastoreResources(t *testing.T) {
 		require.Equal(t, s.CheckCache, s.ShadowCheckCache)
 	})
 
+	t.Run("with_cache_cost_budget", func(t *testing.T) {
+		settings := config.CacheSettings{
+			CheckCacheLimit:           1,
+			CheckCacheCostBudget:      4096,
+			CheckIteratorCacheEnabled: true,
+		}
+
+		s, err := NewSharedDatastoreResources(sharedCtx, sharedSf, mockDatastore, settings)
+		require.NoError(t, err)
+		t.Cleanup(s.Close)
+
+		// the budget holds several small entries, although the limit of entries is 1
+		s.CheckCache.Set("small-1", &storage.TupleIteratorCacheEntry{}, time.Hour)
+		s.CheckCache.Set("small-2", &storage.TupleIteratorCacheEntry{}, time.Hour)
+		require.NotNil(t, s.CheckCache.Get("small-1"))
+		require.NotNil(t, s.CheckCache.Get("small-2"))
+
+		// but not an entry whose tuples exceed it
+		large := &storage.TupleIteratorCacheEntry{Tuples: make([]*storage.TupleRecord, 100)}
+		for i := range large.Tuples {
+			large.Tuples[i] = &storage.TupleRecord{ObjectType: "document", ObjectID: "1", Relation: "viewer"}
+		}
+		s.CheckCache.Set("large", large, time.Hour)
+		require.Nil(t, s.CheckCache.Get("large"))
+	})
+
 	t.Run("with_cache_controller", func(t *testing.T) {
 		settings := config.CacheSettings{
 			CheckCacheLimit:           1,
@@ -16,9 +16,11 @@ const (
 	Write                   APIMethod = "Write"
 	ListObjects             APIMethod = "ListObjects"
 	StreamedListObjects     APIMethod = "StreamedListObjects"
+	CountObjects            APIMethod = "CountObjects"
 	Check                   APIMethod = "Check"
 	BatchCheck              APIMethod = "BatchCheck"
 	ListUsers               APIMethod = "ListUsers"
+	StreamedListUsers       APIMethod = "StreamedListUsers"
 	WriteAssertions         APIMethod = "WriteAssertions"
 	ReadAssertions          APIMethod = "ReadAssertions"
 	WriteAuthorizationModel APIMethod = "WriteAuthorizationModel"
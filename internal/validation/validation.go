@@ -4,6 +4,7 @@ import (
 	"errors"
 	"fmt"
 	"reflect"
+	"strings"
 
 	openfgav1 "github.com/openfga/api/proto/openfga/v1"
 
@@ -360,3 +361,31 @@ func ValidateUser(typesys *typesystem.TypeSystem, user string) error {
 
 	return nil
 }
+
+// ValidateUserIsTyped returns an error suggesting the expected user types if the user has no type
+// (e.g. 'bob' instead of 'user:bob') while the model requires typed users. Without a type, the user
+// can't be related to 'objectType#relation', so this makes the mistake explicit rather than yielding
+// no results. Models that allow untyped users (schema 1.0) accept any user.
+func ValidateUserIsTyped(typesys *typesystem.TypeSystem, user, objectType, relation string) error {
+	if !typesystem.IsSchemaVersionSupported(typesys.GetSchemaVersion()) {
+		return nil
+	}
+
+	userObject, _ := tuple.SplitObjectRelation(user)
+	if user == "" || tuple.GetType(userObject) != "" {
+		return nil
+	}
+
+	expectedTypes := typesys.UntypedUserTypes(user, objectType, relation)
+	if len(expectedTypes) == 0 {
+		return fmt.Errorf("the 'user' field '%s' must be prefixed with its type (e.g. 'user:%s')", user, userObject)
+	}
+
+	suggestions := make([]string, 0, len(expectedTypes))
+	for _, userType := range expectedTypes {
+		suggestions = append(suggestions, "'"+tuple.BuildObject(userType, userObject)+"'")
+	}
+
+	return fmt.Errorf("the 'user' field '%s' must be prefixed with its type, '%s#%s' expects users of type %s (e.g. %s)",
+		user, objectType, relation, strings.Join(expectedTypes, ", "), strings.Join(suggestions, " or "))
+}
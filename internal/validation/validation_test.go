@@ -4,10 +4,12 @@ import (
 	"fmt"
 	"testing"
 
+	"github.com/oklog/ulid/v2"
 	"github.com/stretchr/testify/require"
 
 	openfgav1 "github.com/openfga/api/proto/openfga/v1"
 
+	"github.com/openfga/openfga/pkg/testutils"
 	"github.com/openfga/openfga/pkg/tuple"
 	"github.com/openfga/openfga/pkg/typesystem"
 )
@@ -1206,3 +1208,50 @@ func BenchmarkValidateTupleForRead(b *testing.B) {
 		require.NoError(b, err)
 	}
 }
+
+func TestValidateUserIsTyped(t *testing.T) {
+	t.Run("typed_users_required", func(t *testing.T) {
+		model := testutils.MustTransformDSLToProtoWithID(`
+			model
+				schema 1.1
+			type user
+			type employee
+			type group
+				relations
+					define member: [user, employee]
+			type document
+				relations
+					define owner: [user]
+					define viewer: [group#member] or owner`)
+		typesys, err := typesystem.New(model)
+		require.NoError(t, err)
+
+		err = ValidateUserIsTyped(typesys, "bob", "document", "owner")
+		require.EqualError(t, err, "the 'user' field 'bob' must be prefixed with its type, 'document#owner' expects users of type user (e.g. 'user:bob')")
+
+		err = ValidateUserIsTyped(typesys, "bob", "document", "viewer")
+		require.EqualError(t, err, "the 'user' field 'bob' must be prefixed with its type, 'document#viewer' expects users of type employee, user (e.g. 'employee:bob' or 'user:bob')")
+
+		require.NoError(t, ValidateUserIsTyped(typesys, "user:bob", "document", "viewer"))
+		require.NoError(t, ValidateUserIsTyped(typesys, "group:eng#member", "document", "viewer"))
+		require.NoError(t, ValidateUserIsTyped(typesys, "user:*", "document", "viewer"))
+	})
+
+	t.Run("untyped_users_allowed", func(t *testing.T) {
+		typesys, err := typesystem.New(&openfgav1.AuthorizationModel{
+			Id:            ulid.Make().String(),
+			SchemaVersion: typesystem.SchemaVersion1_0,
+			TypeDefinitions: []*openfgav1.TypeDefinition{
+				{
+					Type: "document",
+					Relations: map[string]*openfgav1.Userset{
+						"viewer": typesystem.This(),
+					},
+				},
+			},
+		})
+		require.NoError(t, err)
+
+		require.NoError(t, ValidateUserIsTyped(typesys, "bob", "document", "viewer"))
+	})
+}
@@ -0,0 +1,110 @@
+// Package decisionlog serializes the outcome of a Check into a versioned JSON document, whose schema
+// is Schema, so that the decisions of OpenFGA can be ingested by systems that audit them.
+package decisionlog
+
+import (
+	_ "embed"
+	"encoding/json"
+
+	openfgav1 "github.com/openfga/api/proto/openfga/v1"
+
+	"github.com/openfga/openfga/pkg/tuple"
+)
+
+// SchemaVersion identifies the version of the schema of the serialized decisions.
+const SchemaVersion = "openfga.decision/v1"
+
+const (
+	DecisionAllow = "allow"
+	DecisionDeny  = "deny"
+)
+
+// Schema is the JSON schema of the serialized decisions.
+//
+//go:embed decision.schema.json
+var Schema []byte
+
+// TupleKey is the relationship that was checked.
+type TupleKey struct {
+	Object   string `json:"object"`
+	Relation string `json:"relation"`
+	User     string `json:"user"`
+}
+
+// Decision is the outcome of a Check, see Schema for the meaning of each field.
+type Decision struct {
+	SchemaVersion        string   `json:"schema_version"`
+	StoreID              string   `json:"store_id"`
+	AuthorizationModelID string   `json:"authorization_model_id"`
+	TupleKey             TupleKey `json:"tuple_key"`
+	ContextualTuples     []string `json:"contextual_tuples,omitempty"`
+	Decision             string   `json:"decision"`
+	Score                *float64 `json:"score,omitempty"`
+	CycleDetected        bool     `json:"cycle_detected"`
+	Degraded             bool     `json:"degraded"`
+	Path                 *Step    `json:"path,omitempty"`
+}
+
+// Step is a subproblem evaluated by the Check that determined its decision. The steps that were evaluated but
+// didn't determine the decision, e.g. the operands of an allowed union that weren't allowed, are omitted.
+type Step struct {
+	// TupleKey is the 'object#relation@user' subproblem of the step.
+	TupleKey string `json:"tuple_key"`
+	// Operation is the rewrite operation evaluated by the step, e.g. 'union', empty for a subproblem answered
+	// without evaluating a rewrite.
+	Operation string `json:"operation,omitempty"`
+	Allowed   bool   `json:"allowed"`
+	// FromCache reports whether the outcome of the step was served from a cache rather than resolved.
+	FromCache bool    `json:"from_cache"`
+	Children  []*Step `json:"children,omitempty"`
+}
+
+// Outcome is the outcome of the resolution of a Check.
+type Outcome struct {
+	Allowed bool
+	// Score is the score of an allowed Check resolved in scoring mode, nil otherwise.
+	Score         *float64
+	CycleDetected bool
+	Degraded      bool
+	// Path is the path evaluated by the Check, nil if it wasn't recorded.
+	Path *Step
+}
+
+// NewDecision builds the decision of the Check of the tuple key in the store, resolved against the model.
+func NewDecision(
+	storeID, modelID string,
+	tupleKey *openfgav1.CheckRequestTupleKey,
+	contextualTuples []*openfgav1.TupleKey,
+	outcome Outcome,
+) *Decision {
+	d := &Decision{
+		SchemaVersion:        SchemaVersion,
+		StoreID:              storeID,
+		AuthorizationModelID: modelID,
+		TupleKey: TupleKey{
+			Object:   tupleKey.GetObject(),
+			Relation: tupleKey.GetRelation(),
+			User:     tupleKey.GetUser(),
+		},
+		Decision:      DecisionDeny,
+		CycleDetected: outcome.CycleDetected,
+		Degraded:      outcome.Degraded,
+		Path:          outcome.Path,
+	}
+
+	for _, tk := range contextualTuples {
+		d.ContextualTuples = append(d.ContextualTuples, tuple.TupleKeyToString(tk))
+	}
+
+	if outcome.Allowed {
+		d.Decision = DecisionAllow
+		d.Score = outcome.Score
+	}
+
+	return d
+}
+
+// Marshal serializes the decision into a JSON document that conforms to Schema.
+func (d *Decision) Marshal() ([]byte, error) {
+	return json.Marshal(d)
+}
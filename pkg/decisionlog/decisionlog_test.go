@@ -0,0 +1,187 @@
+package decisionlog
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/tidwall/gjson"
+
+	openfgav1 "github.com/openfga/api/proto/openfga/v1"
+
+	"github.com/openfga/openfga/pkg/tuple"
+)
+
+// validate reports the violations of the value of the subset of JSON schema used by Schema, whose references
+// are resolved against the root schema.
+func validate(t *testing.T, root, schema gjson.Result, value any, path string) {
+	t.Helper()
+
+	if ref := schema.Get(gjson.Escape("$ref")); ref.Exists() {
+		schema = root.Get(strings.ReplaceAll(strings.TrimPrefix(ref.String(), "#/"), "/", "."))
+		require.True(t, schema.Exists(), "%s references unknown schema %s", path, ref.String())
+	}
+
+	switch schema.Get("type").String() {
+	case "object":
+		obj, ok := value.(map[string]any)
+		require.True(t, ok, "%s must be an object", path)
+
+		for _, required := range schema.Get("required").Array() {
+			require.Contains(t, obj, required.String(), "%s misses required property", path)
+		}
+
+		properties := schema.Get("properties")
+		for name, v := range obj {
+			property := properties.Get(gjson.Escape(name))
+			if schema.Get("additionalProperties").Exists() && !schema.Get("additionalProperties").Bool() {
+				require.True(t, property.Exists(), "%s has unknown property %s", path, name)
+			}
+			if property.Exists() {
+				validate(t, root, property, v, path+"."+name)
+			}
+		}
+	case "array":
+		items, ok := value.([]any)
+		require.True(t, ok, "%s must be an array", path)
+		for _, item := range items {
+			validate(t, root, schema.Get("items"), item, path+"[]")
+		}
+	case "string":
+		s, ok := value.(string)
+		require.True(t, ok, "%s must be a string", path)
+		if enum := schema.Get("enum"); enum.Exists() {
+			var values []string
+			for _, e := range enum.Array() {
+				values = append(values, e.String())
+			}
+			require.Contains(t, values, s, "%s is not one of the enum values", path)
+		}
+	case "number":
+		_, ok := value.(float64)
+		require.True(t, ok, "%s must be a number", path)
+	case "boolean":
+		_, ok := value.(bool)
+		require.True(t, ok, "%s must be a boolean", path)
+	default:
+		t.Fatalf("unsupported schema type at %s", path)
+	}
+}
+
+func TestDecision(t *testing.T) {
+	const (
+		storeID = "01JA4D7C8Y9Z0A1B2C3D4E5F6G"
+		modelID = "01JA4D7C8Y9Z0A1B2C3D4E5F6H"
+	)
+
+	schema := gjson.ParseBytes(Schema)
+	require.True(t, schema.IsObject())
+
+	score := 1.0
+	tests := []struct {
+		name             string
+		tupleKey         *openfgav1.CheckRequestTupleKey
+		contextualTuples []*openfgav1.TupleKey
+		outcome          Outcome
+		expected         string
+	}{
+		{
+			name:     "allowed",
+			tupleKey: tuple.NewCheckRequestTupleKey("document:1", "viewer", "user:anne"),
+			outcome:  Outcome{Allowed: true},
+			expected: `{
+				"schema_version": "openfga.decision/v1",
+				"store_id": "` + storeID + `",
+				"authorization_model_id": "` + modelID + `",
+				"tuple_key": {"object": "document:1", "relation": "viewer", "user": "user:anne"},
+				"decision": "allow",
+				"cycle_detected": false,
+				"degraded": false
+			}`,
+		},
+		{
+			name:     "allowed_with_score",
+			tupleKey: tuple.NewCheckRequestTupleKey("document:1", "viewer", "user:anne"),
+			outcome:  Outcome{Allowed: true, Score: &score},
+			expected: `{
+				"schema_version": "openfga.decision/v1",
+				"store_id": "` + storeID + `",
+				"authorization_model_id": "` + modelID + `",
+				"tuple_key": {"object": "document:1", "relation": "viewer", "user": "user:anne"},
+				"decision": "allow",
+				"score": 1,
+				"cycle_detected": false,
+				"degraded": false
+			}`,
+		},
+		{
+			name:     "allowed_with_path",
+			tupleKey: tuple.NewCheckRequestTupleKey("document:1", "can_view", "user:anne"),
+			outcome: Outcome{
+				Allowed: true,
+				Path: &Step{
+					TupleKey:  "document:1#can_view@user:anne",
+					Operation: "union",
+					Allowed:   true,
+					Children: []*Step{{
+						TupleKey:  "document:1#viewer@user:anne",
+						Operation: "this",
+						Allowed:   true,
+						FromCache: true,
+					}},
+				},
+			},
+			expected: `{
+				"schema_version": "openfga.decision/v1",
+				"store_id": "` + storeID + `",
+				"authorization_model_id": "` + modelID + `",
+				"tuple_key": {"object": "document:1", "relation": "can_view", "user": "user:anne"},
+				"decision": "allow",
+				"cycle_detected": false,
+				"degraded": false,
+				"path": {
+					"tuple_key": "document:1#can_view@user:anne",
+					"operation": "union",
+					"allowed": true,
+					"from_cache": false,
+					"children": [{
+						"tuple_key": "document:1#viewer@user:anne",
+						"operation": "this",
+						"allowed": true,
+						"from_cache": true
+					}]
+				}
+			}`,
+		},
+		{
+			name:             "denied_with_contextual_tuples",
+			tupleKey:         tuple.NewCheckRequestTupleKey("document:2", "viewer", "user:anne"),
+			contextualTuples: []*openfgav1.TupleKey{tuple.NewTupleKey("document:3", "viewer", "user:anne")},
+			// the score of a denied Check isn't reported
+			outcome: Outcome{Score: &score},
+			expected: `{
+				"schema_version": "openfga.decision/v1",
+				"store_id": "` + storeID + `",
+				"authorization_model_id": "` + modelID + `",
+				"tuple_key": {"object": "document:2", "relation": "viewer", "user": "user:anne"},
+				"contextual_tuples": ["document:3#viewer@user:anne"],
+				"decision": "deny",
+				"cycle_detected": false,
+				"degraded": false
+			}`,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			b, err := NewDecision(storeID, modelID, test.tupleKey, test.contextualTuples, test.outcome).Marshal()
+			require.NoError(t, err)
+			require.JSONEq(t, test.expected, string(b))
+
+			var value any
+			require.NoError(t, json.Unmarshal(b, &value))
+			validate(t, schema, schema, value, "$")
+		})
+	}
+}
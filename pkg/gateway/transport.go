@@ -17,6 +17,12 @@ type Transport interface {
 	SetHeader(ctx context.Context, key, value string)
 }
 
+// TrailerTransport is implemented by the transports that can send trailing metadata with the status of a response.
+type TrailerTransport interface {
+	// SetTrailer sets a trailing metadata with a key and a value, which is sent with the status of the response.
+	SetTrailer(ctx context.Context, key, value string)
+}
+
 // NoopTransport defines a no-op transport.
 type NoopTransport struct {
 }
@@ -37,6 +43,7 @@ type RPCTransport struct {
 }
 
 var _ Transport = (*RPCTransport)(nil)
+var _ TrailerTransport = (*RPCTransport)(nil)
 
 // NewRPCTransport returns a transport for gRPC.
 func NewRPCTransport(l logger.Logger) *RPCTransport {
@@ -54,3 +61,15 @@ func (g *RPCTransport) SetHeader(ctx context.Context, key, value string) {
 		)
 	}
 }
+
+// SetTrailer tries to set a trailing metadata. If an error occurred, it logs an error.
+func (g *RPCTransport) SetTrailer(ctx context.Context, key, value string) {
+	if err := grpc.SetTrailer(ctx, metadata.Pairs(key, value)); err != nil {
+		g.logger.ErrorWithContext(
+			ctx,
+			"failed to set grpc trailer",
+			zap.Error(err),
+			zap.String("trailer", key),
+		)
+	}
+}
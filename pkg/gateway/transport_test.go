@@ -19,4 +19,9 @@ func TestRPCTransport(t *testing.T) {
 	log := logs.All()[0]
 
 	require.Contains(t, log.Message, "failed to set grpc header")
+
+	transport.SetTrailer(context.Background(), "test", "test")
+	log = logs.All()[1]
+
+	require.Contains(t, log.Message, "failed to set grpc trailer")
 }
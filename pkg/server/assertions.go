@@ -17,6 +17,7 @@ import (
 	"github.com/openfga/openfga/pkg/middleware/validator"
 	"github.com/openfga/openfga/pkg/server/commands"
 	"github.com/openfga/openfga/pkg/telemetry"
+	"github.com/openfga/openfga/pkg/tuple"
 )
 
 func (s *Server) WriteAssertions(ctx context.Context, req *openfgav1.WriteAssertionsRequest) (*openfgav1.WriteAssertionsResponse, error) {
@@ -93,3 +94,121 @@ func (s *Server) ReadAssertions(ctx context.Context, req *openfgav1.ReadAssertio
 	q := commands.NewReadAssertionsQuery(s.datastore, commands.WithReadAssertionsQueryLogger(s.logger))
 	return q.Execute(ctx, req.GetStoreId(), typesys.GetAuthorizationModelID())
 }
+
+// AssertionResult is the outcome of running one assertion, see RunAssertions.
+type AssertionResult struct {
+	Assertion *openfgav1.Assertion
+	// Expected is the expectation of the assertion.
+	Expected bool
+	// Allowed is the result of the Check, it is false if the Check failed.
+	Allowed bool
+	// Passed reports whether the Check succeeded and returned the expectation.
+	Passed bool
+	// Err is the error of the Check, e.g. when the assertion refers to a relation that the model doesn't define
+	// or its resolution exceeds the resolve node limit.
+	Err error
+}
+
+// AssertionsReport is the report of RunAssertions.
+type AssertionsReport struct {
+	StoreID              string
+	AuthorizationModelID string
+	// Results are the results of the assertions, in the order in which the assertions are stored.
+	Results []AssertionResult
+	Passed  int
+	Failed  int
+}
+
+// RunAssertionsOption configures RunAssertions.
+type RunAssertionsOption func(*runAssertionsOptions)
+
+type runAssertionsOptions struct {
+	contextualTuples bool
+}
+
+// WithAssertionContextualTuples runs each assertion with the contextual tuples stored with it, in addition to the
+// tuples of the store. By default, the assertions are run against the tuples of the store only.
+func WithAssertionContextualTuples() RunAssertionsOption {
+	return func(o *runAssertionsOptions) {
+		o.contextualTuples = true
+	}
+}
+
+// RunAssertions reads the assertions stored for the authorization model and runs each of them as a Check against
+// the model, e.g. to verify in CI that a model still satisfies its assertions after edits. An empty modelID runs
+// the assertions of the latest model. The Checks are resolved like the Check API, so they share its resolver and
+// its limits, e.g. the resolve node limit, and the context of an assertion is used to evaluate the conditions.
+// The error of an individual Check is reported in its result and fails the assertion, RunAssertions only returns
+// an error if the assertions can't be read or the context is done.
+func (s *Server) RunAssertions(ctx context.Context, storeID, modelID string, opts ...RunAssertionsOption) (*AssertionsReport, error) {
+	ctx, span := tracer.Start(ctx, "RunAssertions", trace.WithAttributes(
+		attribute.String("store_id", storeID),
+	))
+	defer span.End()
+
+	var options runAssertionsOptions
+	for _, opt := range opts {
+		opt(&options)
+	}
+
+	err := s.checkAuthz(ctx, storeID, apimethod.ReadAssertions)
+	if err != nil {
+		return nil, err
+	}
+
+	typesys, err := s.resolveTypesystem(ctx, storeID, modelID)
+	if err != nil {
+		return nil, err
+	}
+
+	q := commands.NewReadAssertionsQuery(s.datastore, commands.WithReadAssertionsQueryLogger(s.logger))
+	assertions, err := q.Execute(ctx, storeID, typesys.GetAuthorizationModelID())
+	if err != nil {
+		return nil, err
+	}
+
+	report := &AssertionsReport{
+		StoreID:              storeID,
+		AuthorizationModelID: typesys.GetAuthorizationModelID(),
+		Results:              make([]AssertionResult, 0, len(assertions.GetAssertions())),
+	}
+
+	for _, assertion := range assertions.GetAssertions() {
+		tk := assertion.GetTupleKey()
+		req := &openfgav1.CheckRequest{
+			StoreId:              storeID,
+			AuthorizationModelId: typesys.GetAuthorizationModelID(),
+			TupleKey:             tuple.NewCheckRequestTupleKey(tk.GetObject(), tk.GetRelation(), tk.GetUser()),
+			Context:              assertion.GetContext(),
+		}
+		if options.contextualTuples {
+			req.ContextualTuples = &openfgav1.ContextualTupleKeys{TupleKeys: assertion.GetContextualTuples()}
+		}
+
+		resp, err := s.Check(ctx, req)
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return nil, ctxErr
+		}
+
+		result := AssertionResult{
+			Assertion: assertion,
+			Expected:  assertion.GetExpectation(),
+			Allowed:   resp.GetAllowed(),
+			Err:       err,
+		}
+		result.Passed = err == nil && result.Allowed == result.Expected
+		if result.Passed {
+			report.Passed++
+		} else {
+			report.Failed++
+		}
+		report.Results = append(report.Results, result)
+	}
+
+	span.SetAttributes(
+		attribute.Int("assertions_passed", report.Passed),
+		attribute.Int("assertions_failed", report.Failed),
+	)
+
+	return report, nil
+}
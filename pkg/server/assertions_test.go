@@ -0,0 +1,185 @@
+package server
+
+import (
+	"context"
+	"testing"
+
+	"github.com/oklog/ulid/v2"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/goleak"
+
+	openfgav1 "github.com/openfga/api/proto/openfga/v1"
+	parser "github.com/openfga/language/pkg/go/transformer"
+
+	serverErrors "github.com/openfga/openfga/pkg/server/errors"
+	"github.com/openfga/openfga/pkg/storage/memory"
+	"github.com/openfga/openfga/pkg/tuple"
+)
+
+func TestRunAssertions(t *testing.T) {
+	t.Cleanup(func() {
+		goleak.VerifyNone(t)
+	})
+
+	ctx := context.Background()
+
+	// the github sample model
+	model := parser.MustTransformDSLToProto(`
+		model
+			schema 1.1
+		type user
+		type team
+			relations
+				define member: [user, team#member]
+		type organization
+			relations
+				define owner: [user]
+				define member: [user] or owner
+				define repo_admin: [user, organization#member]
+				define repo_reader: [user, organization#member]
+				define repo_writer: [user, organization#member]
+		type repo
+			relations
+				define owner: [organization]
+				define admin: [user, team#member] or repo_admin from owner
+				define maintainer: [user, team#member] or admin
+				define writer: [user, team#member] or maintainer or repo_writer from owner
+				define triager: [user, team#member] or writer
+				define reader: [user, team#member] or triager or repo_reader from owner`)
+
+	setup := func(t *testing.T, assertions []*openfgav1.Assertion, opts ...OpenFGAServiceV1Option) (*Server, string, string) {
+		s := MustNewServerWithOpts(append([]OpenFGAServiceV1Option{WithDatastore(memory.New())}, opts...)...)
+		t.Cleanup(s.Close)
+
+		createStoreResp, err := s.CreateStore(ctx, &openfgav1.CreateStoreRequest{Name: "github"})
+		require.NoError(t, err)
+		storeID := createStoreResp.GetId()
+
+		writeModelResp, err := s.WriteAuthorizationModel(ctx, &openfgav1.WriteAuthorizationModelRequest{
+			StoreId:         storeID,
+			TypeDefinitions: model.GetTypeDefinitions(),
+			SchemaVersion:   model.GetSchemaVersion(),
+		})
+		require.NoError(t, err)
+		modelID := writeModelResp.GetAuthorizationModelId()
+
+		_, err = s.Write(ctx, &openfgav1.WriteRequest{
+			StoreId: storeID,
+			Writes: &openfgav1.WriteRequestWrites{TupleKeys: []*openfgav1.TupleKey{
+				tuple.NewTupleKey("organization:openfga", "owner", "user:anne"),
+				tuple.NewTupleKey("organization:openfga", "repo_reader", "organization:openfga#member"),
+				tuple.NewTupleKey("organization:openfga", "member", "user:beth"),
+				tuple.NewTupleKey("repo:openfga/openfga", "owner", "organization:openfga"),
+				tuple.NewTupleKey("team:core", "member", "user:charles"),
+				tuple.NewTupleKey("repo:openfga/openfga", "writer", "team:core#member"),
+			}},
+		})
+		require.NoError(t, err)
+
+		_, err = s.WriteAssertions(ctx, &openfgav1.WriteAssertionsRequest{
+			StoreId:              storeID,
+			AuthorizationModelId: modelID,
+			Assertions:           assertions,
+		})
+		require.NoError(t, err)
+
+		return s, storeID, modelID
+	}
+
+	assertion := func(object, relation, user string, expectation bool, contextualTuples ...*openfgav1.TupleKey) *openfgav1.Assertion {
+		return &openfgav1.Assertion{
+			TupleKey:         &openfgav1.AssertionTupleKey{Object: object, Relation: relation, User: user},
+			Expectation:      expectation,
+			ContextualTuples: contextualTuples,
+		}
+	}
+
+	t.Run("all_assertions_pass", func(t *testing.T) {
+		s, storeID, modelID := setup(t, []*openfgav1.Assertion{
+			assertion("repo:openfga/openfga", "reader", "user:anne", true),
+			assertion("repo:openfga/openfga", "reader", "user:beth", true),
+			assertion("repo:openfga/openfga", "writer", "user:beth", false),
+			assertion("repo:openfga/openfga", "triager", "user:charles", true),
+			assertion("repo:openfga/openfga", "admin", "user:charles", false),
+		})
+
+		report, err := s.RunAssertions(ctx, storeID, modelID)
+		require.NoError(t, err)
+		require.Equal(t, storeID, report.StoreID)
+		require.Equal(t, modelID, report.AuthorizationModelID)
+		require.Equal(t, 5, report.Passed)
+		require.Zero(t, report.Failed)
+		for _, result := range report.Results {
+			require.True(t, result.Passed)
+			require.NoError(t, result.Err)
+			require.Equal(t, result.Expected, result.Allowed)
+		}
+	})
+
+	t.Run("reports_the_failed_assertions", func(t *testing.T) {
+		s, storeID, _ := setup(t, []*openfgav1.Assertion{
+			assertion("repo:openfga/openfga", "reader", "user:anne", true),
+			assertion("repo:openfga/openfga", "admin", "user:beth", true),
+		})
+
+		// the latest model
+		report, err := s.RunAssertions(ctx, storeID, "")
+		require.NoError(t, err)
+		require.Equal(t, 1, report.Passed)
+		require.Equal(t, 1, report.Failed)
+		require.Len(t, report.Results, 2)
+
+		failed := report.Results[1]
+		require.False(t, failed.Passed)
+		require.NoError(t, failed.Err)
+		require.True(t, failed.Expected)
+		require.False(t, failed.Allowed)
+		require.Equal(t, "user:beth", failed.Assertion.GetTupleKey().GetUser())
+	})
+
+	t.Run("contextual_tuples", func(t *testing.T) {
+		s, storeID, modelID := setup(t, []*openfgav1.Assertion{
+			assertion("repo:openfga/openfga", "admin", "user:dan", true,
+				tuple.NewTupleKey("repo:openfga/openfga", "admin", "user:dan"),
+			),
+		})
+
+		report, err := s.RunAssertions(ctx, storeID, modelID)
+		require.NoError(t, err)
+		require.Equal(t, 1, report.Failed)
+
+		report, err = s.RunAssertions(ctx, storeID, modelID, WithAssertionContextualTuples())
+		require.NoError(t, err)
+		require.Equal(t, 1, report.Passed)
+	})
+
+	t.Run("respects_the_resolve_node_limit", func(t *testing.T) {
+		s, storeID, modelID := setup(t, []*openfgav1.Assertion{
+			assertion("repo:openfga/openfga", "admin", "user:erin", true),
+		}, WithResolveNodeLimit(2))
+
+		_, err := s.Write(ctx, &openfgav1.WriteRequest{
+			StoreId: storeID,
+			Writes: &openfgav1.WriteRequestWrites{TupleKeys: []*openfgav1.TupleKey{
+				tuple.NewTupleKey("repo:openfga/openfga", "admin", "team:a#member"),
+				tuple.NewTupleKey("team:a", "member", "team:b#member"),
+				tuple.NewTupleKey("team:b", "member", "team:c#member"),
+				tuple.NewTupleKey("team:c", "member", "user:erin"),
+			}},
+		})
+		require.NoError(t, err)
+
+		report, err := s.RunAssertions(ctx, storeID, modelID)
+		require.NoError(t, err)
+		require.Equal(t, 1, report.Failed)
+		require.ErrorIs(t, report.Results[0].Err, serverErrors.ErrAuthorizationModelResolutionTooComplex)
+	})
+
+	t.Run("model_not_found", func(t *testing.T) {
+		s, storeID, _ := setup(t, nil)
+
+		modelID := ulid.Make().String()
+		_, err := s.RunAssertions(ctx, storeID, modelID)
+		require.ErrorIs(t, err, serverErrors.AuthorizationModelNotFound(modelID))
+	})
+}
@@ -17,6 +17,7 @@ import (
 	"github.com/openfga/openfga/pkg/middleware/validator"
 	"github.com/openfga/openfga/pkg/server/commands"
 	"github.com/openfga/openfga/pkg/telemetry"
+	"github.com/openfga/openfga/pkg/typesystem"
 )
 
 func (s *Server) ReadAuthorizationModel(ctx context.Context, req *openfgav1.ReadAuthorizationModelRequest) (*openfgav1.ReadAuthorizationModelResponse, error) {
@@ -46,6 +47,69 @@ func (s *Server) ReadAuthorizationModel(ctx context.Context, req *openfgav1.Read
 	return q.Execute(ctx, req)
 }
 
+// GetRelationGraph returns the dependency graph between the relations of an authorization model.
+// If modelID is empty, the latest authorization model of the store is used.
+func (s *Server) GetRelationGraph(ctx context.Context, storeID, modelID string) (*typesystem.RelationGraph, error) {
+	ctx, span := tracer.Start(ctx, "GetRelationGraph", trace.WithAttributes(
+		attribute.String("store_id", storeID),
+		attribute.KeyValue{Key: authorizationModelIDKey, Value: attribute.StringValue(modelID)},
+	))
+	defer span.End()
+
+	ctx = telemetry.ContextWithRPCInfo(ctx, telemetry.RPCInfo{
+		Service: s.serviceName,
+		Method:  apimethod.ReadAuthorizationModel.String(),
+	})
+
+	err := s.checkAuthz(ctx, storeID, apimethod.ReadAuthorizationModel)
+	if err != nil {
+		return nil, err
+	}
+
+	typesys, err := s.resolveTypesystem(ctx, storeID, modelID)
+	if err != nil {
+		return nil, err
+	}
+
+	return typesys.GetRelationGraph(), nil
+}
+
+// ModelPreloadResult is the outcome of preloading one authorization model with PreloadModels.
+type ModelPreloadResult struct {
+	// ModelID is the ID of the model. When the latest model of the store is preloaded, it's the ID
+	// of the model that was resolved, or empty if there is none.
+	ModelID string
+	Err     error
+}
+
+// PreloadModels reads the given authorization models of a store and caches them, so that the first
+// requests evaluated against them don't have to read them from the datastore, e.g. right after a deploy.
+// If no model is given, the latest model of the store is preloaded. There is one result per model.
+func (s *Server) PreloadModels(ctx context.Context, storeID string, modelIDs ...string) []ModelPreloadResult {
+	ctx, span := tracer.Start(ctx, "PreloadModels", trace.WithAttributes(
+		attribute.String("store_id", storeID),
+	))
+	defer span.End()
+
+	if len(modelIDs) == 0 {
+		modelIDs = []string{""}
+	}
+
+	results := make([]ModelPreloadResult, 0, len(modelIDs))
+	for _, modelID := range modelIDs {
+		// resolving the model caches both its typesystem and the model read from the datastore
+		typesys, err := s.typesystemResolver(ctx, storeID, modelID)
+		if err != nil {
+			telemetry.TraceError(span, err)
+			results = append(results, ModelPreloadResult{ModelID: modelID, Err: err})
+			continue
+		}
+		results = append(results, ModelPreloadResult{ModelID: typesys.GetAuthorizationModelID()})
+	}
+
+	return results
+}
+
 func (s *Server) WriteAuthorizationModel(ctx context.Context, req *openfgav1.WriteAuthorizationModelRequest) (*openfgav1.WriteAuthorizationModelResponse, error) {
 	ctx, span := tracer.Start(ctx, apimethod.WriteAuthorizationModel.String(), trace.WithAttributes(
 		attribute.String("store_id", req.GetStoreId()),
@@ -71,6 +135,7 @@ func (s *Server) WriteAuthorizationModel(ctx context.Context, req *openfgav1.Wri
 	c := commands.NewWriteAuthorizationModelCommand(s.datastore,
 		commands.WithWriteAuthModelLogger(s.logger),
 		commands.WithWriteAuthModelMaxSizeInBytes(s.maxAuthorizationModelSizeInBytes),
+		commands.WithWriteAuthModelMaxRelationBranches(s.maxRelationBranches),
 	)
 	res, err := c.Execute(ctx, req)
 	if err != nil {
@@ -82,6 +147,44 @@ func (s *Server) WriteAuthorizationModel(ctx context.Context, req *openfgav1.Wri
 	return res, nil
 }
 
+// ValidateAuthorizationModel validates the authorization model of the request like WriteAuthorizationModel does,
+// e.g. its size, the type restrictions and the cycles of its relations, and returns the same errors, but it
+// doesn't write the model, e.g. to lint models in CI. The ID of a model is generated when it is written, so the
+// AuthorizationModelId of the response is always empty.
+func (s *Server) ValidateAuthorizationModel(ctx context.Context, req *openfgav1.WriteAuthorizationModelRequest) (*openfgav1.WriteAuthorizationModelResponse, error) {
+	ctx, span := tracer.Start(ctx, "ValidateAuthorizationModel", trace.WithAttributes(
+		attribute.String("store_id", req.GetStoreId()),
+	))
+	defer span.End()
+
+	if !validator.RequestIsValidatedFromContext(ctx) {
+		if err := req.Validate(); err != nil {
+			return nil, status.Error(codes.InvalidArgument, err.Error())
+		}
+	}
+
+	ctx = telemetry.ContextWithRPCInfo(ctx, telemetry.RPCInfo{
+		Service: s.serviceName,
+		Method:  apimethod.WriteAuthorizationModel.String(),
+	})
+
+	err := s.checkAuthz(ctx, req.GetStoreId(), apimethod.WriteAuthorizationModel)
+	if err != nil {
+		return nil, err
+	}
+
+	c := commands.NewWriteAuthorizationModelCommand(s.datastore,
+		commands.WithWriteAuthModelLogger(s.logger),
+		commands.WithWriteAuthModelMaxSizeInBytes(s.maxAuthorizationModelSizeInBytes),
+		commands.WithWriteAuthModelMaxRelationBranches(s.maxRelationBranches),
+	)
+	if err := c.Validate(ctx, req); err != nil {
+		return nil, err
+	}
+
+	return &openfgav1.WriteAuthorizationModelResponse{}, nil
+}
+
 func (s *Server) ReadAuthorizationModels(ctx context.Context, req *openfgav1.ReadAuthorizationModelsRequest) (*openfgav1.ReadAuthorizationModelsResponse, error) {
 	ctx, span := tracer.Start(ctx, apimethod.ReadAuthorizationModels.String(), trace.WithAttributes(
 		attribute.String("store_id", req.GetStoreId()),
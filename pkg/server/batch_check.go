@@ -9,6 +9,7 @@ import (
 	"go.opentelemetry.io/otel/trace"
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
 
 	openfgav1 "github.com/openfga/api/proto/openfga/v1"
 
@@ -18,6 +19,7 @@ import (
 	"github.com/openfga/openfga/pkg/middleware/validator"
 	"github.com/openfga/openfga/pkg/server/commands"
 	serverErrors "github.com/openfga/openfga/pkg/server/errors"
+	"github.com/openfga/openfga/pkg/storage"
 	"github.com/openfga/openfga/pkg/telemetry"
 )
 
@@ -29,9 +31,82 @@ func (s *Server) BatchCheck(ctx context.Context, req *openfgav1.BatchCheckReques
 	))
 	defer span.End()
 
+	ctx, cmd, params, err := s.prepareBatchCheck(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+
+	result, metadata, err := cmd.Execute(ctx, params)
+	if err != nil {
+		return nil, s.handleBatchCheckError(span, req.GetStoreId(), err)
+	}
+
+	const methodName = "batchcheck"
+
+	var batchResult = map[string]*openfgav1.BatchCheckSingleResult{}
+	for correlationID, outcome := range result {
+		batchResult[string(correlationID)] = s.observeBatchCheckOutcome(req.GetStoreId(), methodName, outcome)
+	}
+
+	s.observeBatchCheck(ctx, span, methodName, metadata)
+
+	return &openfgav1.BatchCheckResponse{Result: batchResult}, nil
+}
+
+// StreamedBatchCheck runs the checks of the request like BatchCheck, but sends the result of each check
+// as soon as it's resolved, in no particular order, instead of returning them all at once. It accepts
+// larger batches than BatchCheck, see WithMaxChecksPerStreamedBatchCheck. As for BatchCheck, a check that
+// fails is sent with its error and doesn't fail the others.
+//
+// send is never called concurrently. If it returns an error, the checks not resolved yet are abandoned
+// and the error is returned. The API doesn't define a streaming BatchCheck, so this is only available to
+// the code embedding the server.
+func (s *Server) StreamedBatchCheck(
+	ctx context.Context,
+	req *openfgav1.BatchCheckRequest,
+	send func(correlationID string, result *openfgav1.BatchCheckSingleResult) error,
+) error {
+	ctx, span := tracer.Start(ctx, "StreamedBatchCheck", trace.WithAttributes(
+		attribute.KeyValue{Key: "store_id", Value: attribute.StringValue(req.GetStoreId())},
+		attribute.KeyValue{Key: "batch_size", Value: attribute.IntValue(len(req.GetChecks()))},
+		attribute.KeyValue{Key: "consistency", Value: attribute.StringValue(req.GetConsistency().String())},
+	))
+	defer span.End()
+
+	ctx, cmd, params, err := s.prepareBatchCheck(ctx, req)
+	if err != nil {
+		return err
+	}
+
+	const methodName = "streamedbatchcheck"
+
+	metadata, err := cmd.ExecuteStreamed(ctx, params, func(correlationID commands.CorrelationID, outcome *commands.BatchCheckOutcome) error {
+		return send(string(correlationID), s.observeBatchCheckOutcome(req.GetStoreId(), methodName, outcome))
+	})
+	if err != nil {
+		return s.handleBatchCheckError(span, req.GetStoreId(), err)
+	}
+
+	s.observeBatchCheck(ctx, span, methodName, metadata)
+
+	return nil
+}
+
+// prepareBatchCheck validates and authorizes a BatchCheck request, and returns the command that runs its
+// checks along with its parameters.
+func (s *Server) prepareBatchCheck(
+	ctx context.Context,
+	req *openfgav1.BatchCheckRequest,
+) (context.Context, *commands.BatchCheckQuery, *commands.BatchCheckCommandParams, error) {
 	if !validator.RequestIsValidatedFromContext(ctx) {
 		if err := req.Validate(); err != nil {
-			return nil, status.Error(codes.InvalidArgument, err.Error())
+			return nil, nil, nil, status.Error(codes.InvalidArgument, err.Error())
+		}
+	}
+
+	for _, check := range req.GetChecks() {
+		if err := s.validateContextualTuples(check.GetContextualTuples().GetTupleKeys()); err != nil {
+			return nil, nil, nil, err
 		}
 	}
 
@@ -43,12 +118,27 @@ func (s *Server) BatchCheck(ctx context.Context, req *openfgav1.BatchCheckReques
 	storeID := req.GetStoreId()
 	err := s.checkAuthz(ctx, storeID, apimethod.BatchCheck)
 	if err != nil {
-		return nil, err
+		return nil, nil, nil, err
+	}
+
+	consistency, err := s.resolveConsistency(ctx, storeID, req.GetConsistency())
+	if err != nil {
+		return nil, nil, nil, err
 	}
 
+	ctx = storage.ContextWithConsistency(ctx, consistency)
 	typesys, err := s.resolveTypesystem(ctx, storeID, req.GetAuthorizationModelId())
 	if err != nil {
-		return nil, err
+		return nil, nil, nil, err
+	}
+
+	checks := make([]*openfgav1.BatchCheckItem, 0, len(req.GetChecks()))
+	for _, check := range req.GetChecks() {
+		if checkContext := s.conditionContext(typesys, check.GetContext()); checkContext != check.GetContext() {
+			check = proto.CloneOf(check)
+			check.Context = checkContext
+		}
+		checks = append(checks, check)
 	}
 
 	cmd := commands.NewBatchCheckCommand(
@@ -58,29 +148,44 @@ func (s *Server) BatchCheck(ctx context.Context, req *openfgav1.BatchCheckReques
 		commands.WithBatchCheckCacheOptions(s.sharedDatastoreResources, s.cacheSettings),
 		commands.WithBatchCheckCommandLogger(s.logger),
 		commands.WithBatchCheckMaxChecksPerBatch(s.maxChecksPerBatchCheck),
+		commands.WithBatchCheckMaxChecksPerStreamedBatch(s.maxChecksPerStreamedBatchCheck),
 		commands.WithBatchCheckMaxConcurrentChecks(s.maxConcurrentChecksPerBatch),
 		commands.WithBatchCheckDatastoreThrottler(s.checkDatastoreThrottleThreshold, s.checkDatastoreThrottleDuration),
+		commands.WithBatchCheckDispatchThrottlingFrequency(s.checkDispatchThrottlingFrequency),
+		commands.WithBatchCheckWildcardPolicy(s.wildcardPolicy(storeID)),
 	)
 
-	result, metadata, err := cmd.Execute(ctx, &commands.BatchCheckCommandParams{
+	return ctx, cmd, &commands.BatchCheckCommandParams{
 		AuthorizationModelID: typesys.GetAuthorizationModelID(),
-		Checks:               req.GetChecks(),
-		Consistency:          req.GetConsistency(),
+		Checks:               checks,
+		Consistency:          consistency,
 		StoreID:              storeID,
-	})
-
-	if err != nil {
-		telemetry.TraceError(span, err)
-		var batchValidationError *commands.BatchCheckValidationError
-		if errors.As(err, &batchValidationError) {
-			return nil, serverErrors.ValidationError(err)
-		}
+	}, nil
+}
 
-		return nil, err
+func (s *Server) handleBatchCheckError(span trace.Span, storeID string, err error) error {
+	telemetry.TraceError(span, err)
+	var batchValidationError *commands.BatchCheckValidationError
+	if errors.As(err, &batchValidationError) {
+		return serverErrors.ValidationError(err)
 	}
 
-	methodName := "batchcheck"
+	s.observeDatastoreError(storeID, err)
+	return err
+}
+
+// observeBatchCheckOutcome records the metrics of the outcome of a check of a batch, and returns the
+// outcome as sent back via the api.
+func (s *Server) observeBatchCheckOutcome(storeID, methodName string, outcome *commands.BatchCheckOutcome) *openfgav1.BatchCheckSingleResult {
+	if outcome.Err != nil {
+		s.observeDatastoreError(storeID, commands.CheckCommandErrorToServerError(outcome.Err))
+	}
+	s.emitCheckDurationMetric(outcome.CheckResponse.GetResolutionMetadata(), methodName)
+	return transformCheckResultToProto(outcome)
+}
 
+// observeBatchCheck records the metrics of a batch once all its checks are resolved.
+func (s *Server) observeBatchCheck(ctx context.Context, span trace.Span, methodName string, metadata *commands.BatchCheckMetadata) {
 	dispatchCount := float64(metadata.DispatchCount)
 	grpc_ctxtags.Extract(ctx).Set(dispatchCountHistogramName, dispatchCount)
 	span.SetAttributes(attribute.Float64(dispatchCountHistogramName, dispatchCount))
@@ -96,6 +201,9 @@ func (s *Server) BatchCheck(ctx context.Context, req *openfgav1.BatchCheckReques
 		throttledRequestCounter.WithLabelValues(s.serviceName, methodName).Add(float64(metadata.ThrottleCount))
 	}
 	grpc_ctxtags.Extract(ctx).Set("request.throttled", throttled)
+	if metadata.RetryAfter > 0 {
+		s.setRetryAfterTrailer(ctx, metadata.RetryAfter)
+	}
 
 	queryCount := float64(metadata.DatastoreQueryCount)
 	span.SetAttributes(attribute.Float64(datastoreQueryCountHistogramName, queryCount))
@@ -108,15 +216,7 @@ func (s *Server) BatchCheck(ctx context.Context, req *openfgav1.BatchCheckReques
 	span.SetAttributes(attribute.Int(duplicateChecks, metadata.DuplicateCheckCount))
 	grpc_ctxtags.Extract(ctx).Set(duplicateChecks, metadata.DuplicateCheckCount)
 
-	var batchResult = map[string]*openfgav1.BatchCheckSingleResult{}
-	for correlationID, outcome := range result {
-		batchResult[string(correlationID)] = transformCheckResultToProto(outcome)
-		s.emitCheckDurationMetric(outcome.CheckResponse.GetResolutionMetadata(), methodName)
-	}
-
 	grpc_ctxtags.Extract(ctx).Set(datastoreQueryCountHistogramName, metadata.DatastoreQueryCount)
-
-	return &openfgav1.BatchCheckResponse{Result: batchResult}, nil
 }
 
 // transformCheckResultToProto transforms the internal BatchCheckOutcome into the external-facing
@@ -150,8 +250,10 @@ func transformCheckCommandErrorToBatchCheckError(cmdErr error) *openfgav1.CheckE
 		err.Code = &openfgav1.CheckError_InputError{InputError: openfgav1.ErrorCode_validation_error}
 	case errors.As(cmdErr, &invalidTupleError):
 		err.Code = &openfgav1.CheckError_InputError{InputError: openfgav1.ErrorCode_invalid_tuple}
-	case errors.Is(cmdErr, graph.ErrResolutionDepthExceeded):
+	case errors.Is(cmdErr, graph.ErrResolutionDepthExceeded), errors.Is(cmdErr, graph.ErrRewriteDepthExceeded):
 		err.Code = &openfgav1.CheckError_InputError{InputError: openfgav1.ErrorCode_authorization_model_resolution_too_complex}
+	case errors.Is(cmdErr, graph.ErrUsersetFanoutExceeded):
+		err.Code = &openfgav1.CheckError_InputError{InputError: openfgav1.ErrorCode_exceeded_entity_limit}
 	case errors.Is(cmdErr, condition.ErrEvaluationFailed):
 		err.Code = &openfgav1.CheckError_InputError{InputError: openfgav1.ErrorCode_validation_error}
 	case errors.As(cmdErr, &throttledError):
@@ -11,12 +11,14 @@ import (
 	"go.uber.org/goleak"
 
 	openfgav1 "github.com/openfga/api/proto/openfga/v1"
+	parser "github.com/openfga/language/pkg/go/transformer"
 
 	"github.com/openfga/openfga/cmd/util"
 	"github.com/openfga/openfga/internal/condition"
 	"github.com/openfga/openfga/internal/graph"
 	"github.com/openfga/openfga/pkg/server/commands"
 	"github.com/openfga/openfga/pkg/server/config"
+	"github.com/openfga/openfga/pkg/storage/memory"
 	"github.com/openfga/openfga/pkg/testutils"
 	"github.com/openfga/openfga/pkg/tuple"
 )
@@ -223,6 +225,77 @@ func TestBatchCheckFailsIfTooManyChecks(t *testing.T) {
 	)
 	require.ErrorContains(t, err, msg)
 }
+func TestStreamedBatchCheck(t *testing.T) {
+	t.Cleanup(func() {
+		goleak.VerifyNone(t)
+	})
+
+	ctx := context.Background()
+	s := MustNewServerWithOpts(
+		WithDatastore(memory.New()),
+		WithMaxChecksPerStreamedBatchCheck(100),
+	)
+	t.Cleanup(s.Close)
+
+	createStoreResp, err := s.CreateStore(ctx, &openfgav1.CreateStoreRequest{Name: "streamed-batch-check"})
+	require.NoError(t, err)
+	storeID := createStoreResp.GetId()
+
+	model := parser.MustTransformDSLToProto(`
+		model
+			schema 1.1
+		type user
+		type doc
+			relations
+				define viewer: [user]`)
+	_, err = s.WriteAuthorizationModel(ctx, &openfgav1.WriteAuthorizationModelRequest{
+		StoreId:         storeID,
+		TypeDefinitions: model.GetTypeDefinitions(),
+		SchemaVersion:   model.GetSchemaVersion(),
+	})
+	require.NoError(t, err)
+
+	_, err = s.Write(ctx, &openfgav1.WriteRequest{
+		StoreId: storeID,
+		Writes: &openfgav1.WriteRequestWrites{
+			TupleKeys: []*openfgav1.TupleKey{tuple.NewTupleKey("doc:0", "viewer", "user:anne")},
+		},
+	})
+	require.NoError(t, err)
+
+	// more checks than a BatchCheck accepts
+	numChecks := config.DefaultMaxChecksPerBatchCheck + 10
+	checks := make([]*openfgav1.BatchCheckItem, 0, numChecks+1)
+	for i := 0; i < numChecks; i++ {
+		checks = append(checks, &openfgav1.BatchCheckItem{
+			TupleKey:      tuple.NewCheckRequestTupleKey(fmt.Sprintf("doc:%d", i), "viewer", "user:anne"),
+			CorrelationId: fmt.Sprintf("id%d", i),
+		})
+	}
+	// a check of an undefined relation fails on its own
+	checks = append(checks, &openfgav1.BatchCheckItem{
+		TupleKey:      tuple.NewCheckRequestTupleKey("doc:0", "editor", "user:anne"),
+		CorrelationId: "undefined",
+	})
+
+	req := &openfgav1.BatchCheckRequest{StoreId: storeID, Checks: checks}
+
+	_, err = s.BatchCheck(ctx, req)
+	require.Error(t, err)
+
+	results := map[string]*openfgav1.BatchCheckSingleResult{}
+	err = s.StreamedBatchCheck(ctx, req, func(correlationID string, result *openfgav1.BatchCheckSingleResult) error {
+		results[correlationID] = result
+		return nil
+	})
+	require.NoError(t, err)
+
+	require.Len(t, results, numChecks+1)
+	require.True(t, results["id0"].GetAllowed())
+	require.False(t, results["id1"].GetAllowed())
+	require.Equal(t, openfgav1.ErrorCode_validation_error, results["undefined"].GetError().GetInputError())
+}
+
 func TestTransformCheckCommandErrorToBatchCheckError(t *testing.T) {
 	t.Cleanup(func() {
 		goleak.VerifyNone(t)
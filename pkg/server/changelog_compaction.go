@@ -0,0 +1,110 @@
+package server
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.uber.org/zap"
+
+	"github.com/openfga/openfga/pkg/storage"
+)
+
+// WithChangelogCompaction makes the server compact the changelog of every store periodically, every
+// interval, removing the changes older than retention, see [storage.ChangelogBackend].CompactChangeLog.
+// ReadChanges requests that resume from a continuation token older than the retention then fail with
+// serverErrors.ErrChangelogCompacted, while the tokens past it stay valid. An interval of 0 disables
+// the compaction.
+func WithChangelogCompaction(interval, retention time.Duration) OpenFGAServiceV1Option {
+	return func(s *Server) {
+		s.changelogCompactionInterval = interval
+		s.changelogRetention = retention
+	}
+}
+
+// changelogCompactor compacts the changelogs of the stores of a datastore in the background.
+type changelogCompactor struct {
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+// startChangelogCompaction starts compacting the changelogs periodically, if enabled. The compaction
+// is stopped by Close.
+func (s *Server) startChangelogCompaction() {
+	if s.changelogCompactionInterval <= 0 {
+		return
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	c := &changelogCompactor{cancel: cancel}
+	s.changelogCompactor = c
+
+	ticker := time.NewTicker(s.changelogCompactionInterval)
+	c.wg.Add(1)
+	go func() {
+		defer c.wg.Done()
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ticker.C:
+				s.compactChangeLogs(ctx, time.Now().Add(-s.changelogRetention))
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+}
+
+// stopChangelogCompaction stops the compaction, abandoning the ongoing one, if any.
+func (s *Server) stopChangelogCompaction() {
+	if s.changelogCompactor == nil {
+		return
+	}
+	s.changelogCompactor.cancel()
+	s.changelogCompactor.wg.Wait()
+}
+
+// compactChangeLogs removes the changes older than olderThan from the changelog of every store. The
+// failure to compact a store is logged, and the compaction carries on with the other stores.
+func (s *Server) compactChangeLogs(ctx context.Context, olderThan time.Time) {
+	ctx, span := tracer.Start(ctx, "compactChangeLogs")
+	defer span.End()
+
+	var (
+		token     string
+		compacted int
+	)
+	for {
+		stores, next, err := s.datastore.ListStores(ctx, storage.ListStoresOptions{
+			Pagination: storage.NewPaginationOptions(storage.DefaultPageSize, token),
+		})
+		if err != nil {
+			s.logger.ErrorWithContext(ctx, "failed to list the stores to compact their changelog", zap.Error(err))
+			break
+		}
+
+		for _, store := range stores {
+			if ctx.Err() != nil {
+				// the server is closing
+				return
+			}
+
+			if err := s.datastore.CompactChangeLog(ctx, store.GetId(), olderThan); err != nil {
+				s.logger.ErrorWithContext(ctx, "failed to compact the changelog of the store",
+					zap.String("store_id", store.GetId()),
+					zap.Error(err))
+				continue
+			}
+			compacted++
+		}
+
+		if next == "" {
+			break
+		}
+		token = next
+	}
+
+	span.SetAttributes(attribute.Int("compacted_stores", compacted))
+}
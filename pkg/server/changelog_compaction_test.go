@@ -0,0 +1,85 @@
+package server
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"go.uber.org/goleak"
+
+	openfgav1 "github.com/openfga/api/proto/openfga/v1"
+
+	"github.com/openfga/openfga/pkg/storage"
+	"github.com/openfga/openfga/pkg/storage/memory"
+	"github.com/openfga/openfga/pkg/tuple"
+)
+
+func TestChangelogCompaction(t *testing.T) {
+	t.Cleanup(func() {
+		goleak.VerifyNone(t)
+	})
+
+	ctx := context.Background()
+
+	setup := func(t *testing.T, stores int, opts ...OpenFGAServiceV1Option) (*Server, storage.OpenFGADatastore, []string) {
+		ds := memory.New()
+		s := MustNewServerWithOpts(append([]OpenFGAServiceV1Option{WithDatastore(ds)}, opts...)...)
+		t.Cleanup(s.Close)
+
+		storeIDs := make([]string, 0, stores)
+		for range stores {
+			createStoreResp, err := s.CreateStore(ctx, &openfgav1.CreateStoreRequest{Name: "changelog-compaction"})
+			require.NoError(t, err)
+			storeIDs = append(storeIDs, createStoreResp.GetId())
+
+			err = ds.Write(ctx, createStoreResp.GetId(), nil, []*openfgav1.TupleKey{
+				tuple.NewTupleKey("doc:1", "viewer", "user:jon"),
+			})
+			require.NoError(t, err)
+		}
+
+		return s, ds, storeIDs
+	}
+
+	changes := func(t *testing.T, ds storage.OpenFGADatastore, storeID string) []*openfgav1.TupleChange {
+		changes, _, err := ds.ReadChanges(ctx, storeID, storage.ReadChangesFilter{}, storage.ReadChangesOptions{})
+		if err != nil {
+			require.ErrorIs(t, err, storage.ErrNotFound)
+		}
+		return changes
+	}
+
+	t.Run("compacts_every_store", func(t *testing.T) {
+		// more stores than a page of ListStores
+		s, ds, storeIDs := setup(t, storage.DefaultPageSize+1)
+
+		// ulids have millisecond precision
+		time.Sleep(2 * time.Millisecond)
+		s.compactChangeLogs(ctx, time.Now())
+
+		for _, storeID := range storeIDs {
+			require.Empty(t, changes(t, ds, storeID))
+		}
+	})
+
+	t.Run("keeps_the_changes_within_the_retention", func(t *testing.T) {
+		s, ds, storeIDs := setup(t, 1)
+
+		s.compactChangeLogs(ctx, time.Now().Add(-time.Hour))
+		require.Len(t, changes(t, ds, storeIDs[0]), 1)
+	})
+
+	t.Run("runs_periodically", func(t *testing.T) {
+		_, ds, storeIDs := setup(t, 1, WithChangelogCompaction(10*time.Millisecond, 20*time.Millisecond))
+
+		require.Eventually(t, func() bool {
+			return len(changes(t, ds, storeIDs[0])) == 0
+		}, time.Second, 10*time.Millisecond)
+	})
+
+	t.Run("disabled", func(t *testing.T) {
+		s, _, _ := setup(t, 1)
+		require.Nil(t, s.changelogCompactor)
+	})
+}
@@ -12,18 +12,54 @@ import (
 	"go.opentelemetry.io/otel/trace"
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
 
 	openfgav1 "github.com/openfga/api/proto/openfga/v1"
 
+	"github.com/openfga/openfga/internal/graph"
 	"github.com/openfga/openfga/internal/utils"
 	"github.com/openfga/openfga/internal/utils/apimethod"
+	"github.com/openfga/openfga/pkg/gateway"
 	"github.com/openfga/openfga/pkg/middleware/validator"
 	"github.com/openfga/openfga/pkg/server/commands"
 	serverErrors "github.com/openfga/openfga/pkg/server/errors"
+	"github.com/openfga/openfga/pkg/storage"
 	"github.com/openfga/openfga/pkg/telemetry"
+	"github.com/openfga/openfga/pkg/tuple"
+	"github.com/openfga/openfga/pkg/typesystem"
 )
 
 func (s *Server) Check(ctx context.Context, req *openfgav1.CheckRequest) (*openfgav1.CheckResponse, error) {
+	resp, err := s.check(ctx, req, checkOptions{})
+	if err != nil {
+		return nil, err
+	}
+
+	return &openfgav1.CheckResponse{
+		Allowed: resp.Allowed,
+	}, nil
+}
+
+// checkOptions are the options of a Check resolved by check that aren't part of its request.
+type checkOptions struct {
+	// trace resolves the Check in trace mode, see CheckWithTrace.
+	trace bool
+	// excludedRelations are the relations treated as having no members, see CheckWithExcludedRelations.
+	excludedRelations []string
+	// assumedFacts are the subproblems treated as allowed, see CheckWithAssumedFacts.
+	assumedFacts []*openfgav1.TupleKey
+	// scoring resolves the Check in scoring mode with the rubric, see CheckWithScore.
+	scoring *graph.CheckScoring
+}
+
+// shareable reports whether the outcome of the Check can be shared with the other Checks of the same request,
+// i.e. whether it neither simulates changes to the relationships of the store nor reports a score.
+func (o checkOptions) shareable() bool {
+	return len(o.excludedRelations) == 0 && len(o.assumedFacts) == 0 && o.scoring == nil
+}
+
+// check resolves the Check of the request with the options.
+func (s *Server) check(ctx context.Context, req *openfgav1.CheckRequest, opts checkOptions) (*graph.ResolveCheckResponse, error) {
 	const methodName = "check"
 
 	startTime := time.Now()
@@ -44,6 +80,10 @@ func (s *Server) Check(ctx context.Context, req *openfgav1.CheckRequest) (*openf
 		}
 	}
 
+	if err := s.validateContextualTuples(req.GetContextualTuples().GetTupleKeys()); err != nil {
+		return nil, err
+	}
+
 	ctx = telemetry.ContextWithRPCInfo(ctx, telemetry.RPCInfo{
 		Service: s.serviceName,
 		Method:  apimethod.Check.String(),
@@ -56,11 +96,40 @@ func (s *Server) Check(ctx context.Context, req *openfgav1.CheckRequest) (*openf
 
 	storeID := req.GetStoreId()
 
+	// deprecated relations are translated before resolution so that they share cache entries with their current names
+	if relation := s.resolveRelationAlias(ctx, storeID, tuple.GetType(tk.GetObject()), tk.GetRelation()); relation != tk.GetRelation() {
+		tk = tuple.NewCheckRequestTupleKey(tk.GetObject(), relation, tk.GetUser())
+	}
+
+	consistency, err := s.resolveConsistency(ctx, storeID, req.GetConsistency())
+	if err != nil {
+		return nil, err
+	}
+	if consistency != req.GetConsistency() {
+		req = proto.CloneOf(req)
+		req.Consistency = consistency
+		span.SetAttributes(attribute.String("consistency", consistency.String()))
+	}
+
+	// the model is read with the consistency of the request, e.g. from the primary with HIGHER_CONSISTENCY
+	ctx = storage.ContextWithConsistency(ctx, consistency)
 	typesys, err := s.resolveTypesystem(ctx, storeID, req.GetAuthorizationModelId())
 	if err != nil {
 		return nil, err
 	}
 
+	if reqContext := s.conditionContext(typesys, req.GetContext()); reqContext != req.GetContext() {
+		req = proto.CloneOf(req)
+		req.Context = reqContext
+	}
+
+	// only count relations defined in the model so that the label cardinality stays bounded,
+	// undefined relations are rejected by the check command below
+	objectType := tuple.GetType(tk.GetObject())
+	if _, err := typesys.GetRelation(objectType, tk.GetRelation()); err == nil {
+		checkRelationCounter.WithLabelValues(objectType, tk.GetRelation()).Inc()
+	}
+
 	checkQuery := commands.NewCheckCommand(
 		s.datastore,
 		s.checkResolver,
@@ -69,15 +138,49 @@ func (s *Server) Check(ctx context.Context, req *openfgav1.CheckRequest) (*openf
 		commands.WithCheckCommandMaxConcurrentReads(s.maxConcurrentReadsForCheck),
 		commands.WithCheckCommandCache(s.sharedDatastoreResources, s.cacheSettings),
 		commands.WithCheckDatastoreThrottler(s.checkDatastoreThrottleThreshold, s.checkDatastoreThrottleDuration),
+		commands.WithCheckDispatchThrottlingFrequency(s.checkDispatchThrottlingFrequency),
+		commands.WithCheckCommandWildcardPolicy(s.wildcardPolicy(storeID)),
 	)
 
-	resp, checkRequestMetadata, err := checkQuery.Execute(ctx, &commands.CheckCommandParams{
-		StoreID:          storeID,
-		TupleKey:         req.GetTupleKey(),
-		ContextualTuples: req.GetContextualTuples(),
-		Context:          req.GetContext(),
-		Consistency:      req.GetConsistency(),
-	})
+	// the path of the decision is recorded from the trace of the resolution
+	traced := opts.trace || (s.decisionLogger != nil && s.decisionLogPath)
+	cacheMode := checkCacheModeFromContext(ctx)
+	execute := func() (*graph.ResolveCheckResponse, *graph.ResolveCheckRequestMetadata, error) {
+		return checkQuery.Execute(ctx, &commands.CheckCommandParams{
+			StoreID:           storeID,
+			TupleKey:          tk,
+			ContextualTuples:  req.GetContextualTuples(),
+			Context:           req.GetContext(),
+			Consistency:       req.GetConsistency(),
+			ExcludedRelations: opts.excludedRelations,
+			AssumedFacts:      opts.assumedFacts,
+			Scoring:           opts.scoring,
+			CacheMode:         cacheMode,
+			Trace:             traced,
+		})
+	}
+
+	var (
+		resp                 *graph.ResolveCheckResponse
+		checkRequestMetadata *graph.ResolveCheckRequestMetadata
+	)
+	// the Checks with HIGHER_CONSISTENCY aren't answered with the result of a Check that started before them,
+	// the traced ones need the trace of their own resolution, the ones with another cache mode must not
+	// be answered with the result of a Check that doesn't use the cache like them, and the simulated or
+	// scored ones with the result of a Check that isn't resolved with the same options
+	if s.checkCoalescer != nil && req.GetConsistency() != openfgav1.ConsistencyPreference_HIGHER_CONSISTENCY &&
+		!traced && cacheMode == graph.CacheReadWrite && opts.shareable() {
+		key, keyErr := checkCoalescingKey(storeID, typesys.GetAuthorizationModelID(), tk, req, s.wildcardPolicy(storeID), s.maxContextualTuples)
+		if keyErr != nil {
+			return nil, serverErrors.HandleError("", keyErr)
+		}
+
+		result, coalesced := s.checkCoalescer.do(ctx, key, execute)
+		resp, checkRequestMetadata, err = result.resp, result.metadata, result.err
+		span.SetAttributes(attribute.Bool("coalesced", coalesced))
+	} else {
+		resp, checkRequestMetadata, err = execute()
+	}
 
 	endTime := time.Since(startTime).Milliseconds()
 
@@ -134,9 +237,8 @@ func (s *Server) Check(ctx context.Context, req *openfgav1.CheckRequest) (*openf
 	if err != nil {
 		telemetry.TraceError(span, err)
 		finalErr := commands.CheckCommandErrorToServerError(err)
-		if errors.Is(finalErr, serverErrors.ErrThrottledTimeout) {
-			throttledRequestCounter.WithLabelValues(s.serviceName, methodName).Inc()
-		}
+		s.observeRetryableError(ctx, methodName, finalErr)
+		s.observeDatastoreError(req.GetStoreId(), finalErr)
 		// should we define all metrics in one place that is accessible from everywhere (including LocalChecker!)
 		// and add a wrapper helper that automatically injects the service name tag?
 		return nil, finalErr
@@ -148,9 +250,243 @@ func (s *Server) Check(ctx context.Context, req *openfgav1.CheckRequest) (*openf
 		attribute.Bool("cycle_detected", resp.GetCycleDetected()),
 		attribute.Bool("allowed", resp.GetAllowed()))
 
-	res := &openfgav1.CheckResponse{
-		Allowed: resp.Allowed,
+	if !resp.GetAllowed() && s.checkObjectRegistry != nil {
+		if err := s.checkObjectExists(ctx, storeID, tk.GetObject()); err != nil {
+			telemetry.TraceError(span, err)
+			return nil, err
+		}
+	}
+
+	s.setDatastoreQueryCountHeader(ctx, resp)
+	s.logDecision(ctx, storeID, typesys.GetAuthorizationModelID(), tk, req.GetContextualTuples().GetTupleKeys(), resp)
+
+	return resp, nil
+}
+
+// checkObjectExists consults the object registry after a denied Check. It returns a not found error if the
+// object isn't registered, and otherwise sets the ObjectExistsHeader.
+func (s *Server) checkObjectExists(ctx context.Context, storeID, object string) error {
+	exists, err := s.checkObjectRegistry.ObjectExists(ctx, storeID, object)
+	if err != nil {
+		return serverErrors.HandleError("", err)
+	}
+	if !exists {
+		return serverErrors.ObjectNotFound(object)
 	}
 
-	return res, nil
+	s.transport.SetHeader(ctx, ObjectExistsHeader, "true")
+	return nil
+}
+
+// CheckWithModelDelta resolves a Check against the authorization model of the request merged with
+// the given delta. The merged model is validated and used for this request only, it is never persisted.
+// This allows iterating on changes to a published model without writing a new model for every change.
+func (s *Server) CheckWithModelDelta(ctx context.Context, req *openfgav1.CheckRequest, delta *typesystem.ModelDelta) (*openfgav1.CheckResponse, error) {
+	const methodName = "check"
+
+	tk := req.GetTupleKey()
+	ctx, span := tracer.Start(ctx, "CheckWithModelDelta", trace.WithAttributes(
+		attribute.KeyValue{Key: "store_id", Value: attribute.StringValue(req.GetStoreId())},
+		attribute.KeyValue{Key: "object", Value: attribute.StringValue(tk.GetObject())},
+		attribute.KeyValue{Key: "relation", Value: attribute.StringValue(tk.GetRelation())},
+		attribute.KeyValue{Key: "user", Value: attribute.StringValue(tk.GetUser())},
+	))
+	defer span.End()
+
+	if !validator.RequestIsValidatedFromContext(ctx) {
+		if err := req.Validate(); err != nil {
+			return nil, status.Error(codes.InvalidArgument, err.Error())
+		}
+	}
+
+	if err := s.validateContextualTuples(req.GetContextualTuples().GetTupleKeys()); err != nil {
+		return nil, err
+	}
+
+	ctx = telemetry.ContextWithRPCInfo(ctx, telemetry.RPCInfo{
+		Service: s.serviceName,
+		Method:  apimethod.Check.String(),
+	})
+
+	err := s.checkAuthz(ctx, req.GetStoreId(), apimethod.Check)
+	if err != nil {
+		return nil, err
+	}
+
+	storeID := req.GetStoreId()
+
+	consistency, err := s.resolveConsistency(ctx, storeID, req.GetConsistency())
+	if err != nil {
+		return nil, err
+	}
+
+	ctx = storage.ContextWithConsistency(ctx, consistency)
+	baseTypesys, err := s.resolveTypesystem(ctx, storeID, req.GetAuthorizationModelId())
+	if err != nil {
+		return nil, err
+	}
+
+	baseModel, err := s.datastore.ReadAuthorizationModel(ctx, storeID, baseTypesys.GetAuthorizationModelID())
+	if err != nil {
+		return nil, serverErrors.HandleError("", err)
+	}
+
+	typesys, err := typesystem.NewAndValidate(ctx, typesystem.ApplyModelDelta(baseModel, delta))
+	if err != nil {
+		return nil, serverErrors.InvalidAuthorizationModelInput(err)
+	}
+
+	// the conditions of the delta may declare the current time as well
+	reqContext := s.conditionContext(typesys, req.GetContext())
+
+	fingerprint, err := delta.Fingerprint()
+	if err != nil {
+		return nil, serverErrors.HandleError("", err)
+	}
+	span.SetAttributes(attribute.String("model_delta_fingerprint", fingerprint))
+
+	checkQuery := commands.NewCheckCommand(
+		s.datastore,
+		s.checkResolver,
+		typesys,
+		commands.WithCheckCommandLogger(s.logger),
+		commands.WithCheckCommandMaxConcurrentReads(s.maxConcurrentReadsForCheck),
+		commands.WithCheckCommandCache(s.sharedDatastoreResources, s.cacheSettings),
+		commands.WithCheckDatastoreThrottler(s.checkDatastoreThrottleThreshold, s.checkDatastoreThrottleDuration),
+		commands.WithCheckDispatchThrottlingFrequency(s.checkDispatchThrottlingFrequency),
+		commands.WithCheckCommandWildcardPolicy(s.wildcardPolicy(storeID)),
+	)
+
+	resp, _, err := checkQuery.Execute(ctx, &commands.CheckCommandParams{
+		StoreID:               storeID,
+		TupleKey:              req.GetTupleKey(),
+		ContextualTuples:      req.GetContextualTuples(),
+		Context:               reqContext,
+		Consistency:           consistency,
+		CacheMode:             checkCacheModeFromContext(ctx),
+		ModelDeltaFingerprint: fingerprint,
+	})
+	if err != nil {
+		telemetry.TraceError(span, err)
+		finalErr := commands.CheckCommandErrorToServerError(err)
+		s.observeRetryableError(ctx, methodName, finalErr)
+		return nil, finalErr
+	}
+
+	span.SetAttributes(attribute.Bool("allowed", resp.GetAllowed()))
+	s.setDatastoreQueryCountHeader(ctx, resp)
+
+	return &openfgav1.CheckResponse{
+		Allowed: resp.GetAllowed(),
+	}, nil
+}
+
+// CheckWithTrace resolves a Check like Check and returns the resolution tree along with the response, e.g. to
+// explain why the Check was allowed or denied. Every node of the tree reports the subproblem and rewrite operation
+// that it evaluates, the datastore reads it made, and whether it contributed to the decision. The subproblems
+// served from the check cache are reported as such. Tracing is meant for debugging, it adds overhead to the Check.
+func (s *Server) CheckWithTrace(ctx context.Context, req *openfgav1.CheckRequest) (*openfgav1.CheckResponse, *CheckTrace, error) {
+	ctx, span := tracer.Start(ctx, "CheckWithTrace")
+	defer span.End()
+
+	resp, err := s.check(ctx, req, checkOptions{trace: true})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return &openfgav1.CheckResponse{
+		Allowed: resp.GetAllowed(),
+	}, newCheckTrace(resp.GetTrace()), nil
+}
+
+// CheckWithExcludedRelations resolves a Check like Check while treating the excluded relations, given as
+// 'objectType#relation', as having no members, e.g. to simulate the access a user has without a given role.
+// The tuples and rewrites of the excluded relations aren't evaluated. At most commands.MaxExcludedRelations
+// relations can be excluded, and they must be defined in the model of the Check.
+func (s *Server) CheckWithExcludedRelations(ctx context.Context, req *openfgav1.CheckRequest, excludedRelations []string) (*openfgav1.CheckResponse, error) {
+	ctx, span := tracer.Start(ctx, "CheckWithExcludedRelations", trace.WithAttributes(
+		attribute.StringSlice("excluded_relations", excludedRelations),
+	))
+	defer span.End()
+
+	resp, err := s.check(ctx, req, checkOptions{excludedRelations: excludedRelations})
+	if err != nil {
+		return nil, err
+	}
+
+	return &openfgav1.CheckResponse{
+		Allowed: resp.GetAllowed(),
+	}, nil
+}
+
+// CheckWithAssumedFacts resolves a Check like Check while treating the assumed facts, i.e. 'object#relation@user'
+// subproblems, as allowed without resolving them, on top of the stored and contextual tuples, e.g. to simulate
+// the access a user would have if they were granted a role. Unlike contextual tuples, the facts aren't subject
+// to the type restrictions of the model, and must not have a condition. At most commands.MaxAssumedFacts facts
+// can be assumed.
+func (s *Server) CheckWithAssumedFacts(ctx context.Context, req *openfgav1.CheckRequest, assumedFacts []*openfgav1.TupleKey) (*openfgav1.CheckResponse, error) {
+	ctx, span := tracer.Start(ctx, "CheckWithAssumedFacts", trace.WithAttributes(
+		attribute.Int("assumed_facts", len(assumedFacts)),
+	))
+	defer span.End()
+
+	resp, err := s.check(ctx, req, checkOptions{assumedFacts: assumedFacts})
+	if err != nil {
+		return nil, err
+	}
+
+	return &openfgav1.CheckResponse{
+		Allowed: resp.GetAllowed(),
+	}, nil
+}
+
+// CheckWithScore resolves a Check like Check in scoring mode, and returns the score of the strength of the
+// relationship between the user and the object along with the response, e.g. to rank the objects a user can
+// access. The score is derived from the path that grants access with the rubric, see CheckScoring, and is
+// 0 if the Check is denied.
+func (s *Server) CheckWithScore(ctx context.Context, req *openfgav1.CheckRequest, scoring CheckScoring) (*openfgav1.CheckResponse, float64, error) {
+	ctx, span := tracer.Start(ctx, "CheckWithScore")
+	defer span.End()
+
+	resp, err := s.check(ctx, req, checkOptions{scoring: scoring.graphCheckScoring()})
+	if err != nil {
+		return nil, 0, err
+	}
+
+	score := resp.GetScore()
+	if !resp.GetAllowed() {
+		score = 0
+	}
+	span.SetAttributes(attribute.Float64("score", score))
+
+	return &openfgav1.CheckResponse{
+		Allowed: resp.GetAllowed(),
+	}, score, nil
+}
+
+// setDatastoreQueryCountHeader sets the DatastoreQueryCountHeader to the datastore query count of the resolution.
+func (s *Server) setDatastoreQueryCountHeader(ctx context.Context, resp *graph.ResolveCheckResponse) {
+	s.transport.SetHeader(ctx, DatastoreQueryCountHeader, strconv.FormatUint(uint64(resp.GetResolutionMetadata().DatastoreQueryCount), 10))
+}
+
+// observeRetryableError counts the request as throttled if it failed with a ThrottledError, and sets the
+// RetryAfterKey trailing metadata of the response if its error suggests a delay before retrying.
+func (s *Server) observeRetryableError(ctx context.Context, methodName string, err error) {
+	var throttledErr *serverErrors.ThrottledError
+	var unavailableErr *serverErrors.DatastoreUnavailableError
+	switch {
+	case errors.As(err, &throttledErr):
+		throttledRequestCounter.WithLabelValues(s.serviceName, methodName).Inc()
+		s.setRetryAfterTrailer(ctx, throttledErr.RetryAfter)
+	case errors.As(err, &unavailableErr):
+		s.setRetryAfterTrailer(ctx, unavailableErr.RetryAfter)
+	}
+}
+
+// setRetryAfterTrailer sets the RetryAfterKey trailing metadata of the response, if the transport can send
+// trailing metadata.
+func (s *Server) setRetryAfterTrailer(ctx context.Context, retryAfter time.Duration) {
+	if t, ok := s.transport.(gateway.TrailerTransport); ok {
+		t.SetTrailer(ctx, serverErrors.RetryAfterKey, serverErrors.RetryAfterSeconds(retryAfter))
+	}
 }
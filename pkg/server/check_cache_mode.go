@@ -0,0 +1,49 @@
+package server
+
+import (
+	"context"
+
+	"github.com/openfga/openfga/internal/graph"
+)
+
+// CheckCacheMode determines whether the check cache is read and written while resolving a Check, see
+// ContextWithCheckCacheMode.
+type CheckCacheMode int
+
+const (
+	// CheckCacheReadWrite serves cached results and caches the results that are resolved. It is the default.
+	CheckCacheReadWrite CheckCacheMode = iota
+	// CheckCacheReadOnly serves cached results without caching the results that are resolved.
+	CheckCacheReadOnly
+	// CheckCacheWriteOnly caches the results that are resolved without serving cached results, e.g. to warm
+	// the cache from a background job.
+	CheckCacheWriteOnly
+	// CheckCacheOff neither serves cached results nor caches the results that are resolved, e.g. to verify
+	// a fix without waiting for cached results to expire.
+	CheckCacheOff
+)
+
+type checkCacheModeCtxKey struct{}
+
+// ContextWithCheckCacheMode returns a context that sets the cache mode of the Checks served with it, i.e.
+// of Check, CheckWithTrace, CheckWithModelDelta and CheckInSession. Unlike HIGHER_CONSISTENCY, the cache mode doesn't change how
+// the datastore is read.
+func ContextWithCheckCacheMode(parent context.Context, mode CheckCacheMode) context.Context {
+	return context.WithValue(parent, checkCacheModeCtxKey{}, mode)
+}
+
+// checkCacheModeFromContext returns the cache mode of the Checks served with the context, see
+// ContextWithCheckCacheMode.
+func checkCacheModeFromContext(ctx context.Context) graph.CacheMode {
+	mode, _ := ctx.Value(checkCacheModeCtxKey{}).(CheckCacheMode)
+	switch mode {
+	case CheckCacheReadOnly:
+		return graph.CacheReadOnly
+	case CheckCacheWriteOnly:
+		return graph.CacheWriteOnly
+	case CheckCacheOff:
+		return graph.CacheOff
+	default:
+		return graph.CacheReadWrite
+	}
+}
@@ -0,0 +1,76 @@
+package server
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"go.uber.org/goleak"
+
+	openfgav1 "github.com/openfga/api/proto/openfga/v1"
+	parser "github.com/openfga/language/pkg/go/transformer"
+
+	"github.com/openfga/openfga/pkg/storage/memory"
+	"github.com/openfga/openfga/pkg/tuple"
+)
+
+func TestCheckCacheMode(t *testing.T) {
+	t.Cleanup(func() {
+		goleak.VerifyNone(t)
+	})
+
+	ctx := context.Background()
+	ds := memory.New()
+	s := MustNewServerWithOpts(
+		WithDatastore(ds),
+		WithCheckQueryCacheEnabled(true),
+		WithCheckQueryCacheTTL(time.Hour),
+	)
+	t.Cleanup(s.Close)
+
+	createStoreResp, err := s.CreateStore(ctx, &openfgav1.CreateStoreRequest{Name: "check-cache-mode"})
+	require.NoError(t, err)
+	storeID := createStoreResp.GetId()
+
+	model := parser.MustTransformDSLToProto(`
+		model
+			schema 1.1
+		type user
+		type doc
+			relations
+				define viewer: [user]`)
+	_, err = s.WriteAuthorizationModel(ctx, &openfgav1.WriteAuthorizationModelRequest{
+		StoreId:         storeID,
+		TypeDefinitions: model.GetTypeDefinitions(),
+		SchemaVersion:   model.GetSchemaVersion(),
+	})
+	require.NoError(t, err)
+
+	tk := tuple.NewTupleKey("doc:1", "viewer", "user:anne")
+	require.NoError(t, ds.Write(ctx, storeID, nil, []*openfgav1.TupleKey{tk}))
+
+	check := func(ctx context.Context) bool {
+		resp, err := s.Check(ctx, &openfgav1.CheckRequest{
+			StoreId:  storeID,
+			TupleKey: tuple.NewCheckRequestTupleKey(tk.GetObject(), tk.GetRelation(), tk.GetUser()),
+		})
+		require.NoError(t, err)
+		return resp.GetAllowed()
+	}
+
+	// the result isn't cached by a Check that doesn't write the cache
+	require.True(t, check(ContextWithCheckCacheMode(ctx, CheckCacheReadOnly)))
+	require.NoError(t, ds.Write(ctx, storeID, []*openfgav1.TupleKeyWithoutCondition{tuple.TupleKeyToTupleKeyWithoutCondition(tk)}, nil))
+	require.False(t, check(ctx))
+
+	// the result cached by a Check that doesn't read the cache is served to the others
+	require.NoError(t, ds.Write(ctx, storeID, nil, []*openfgav1.TupleKey{tk}))
+	require.True(t, check(ContextWithCheckCacheMode(ctx, CheckCacheWriteOnly)))
+	require.NoError(t, ds.Write(ctx, storeID, []*openfgav1.TupleKeyWithoutCondition{tuple.TupleKeyToTupleKeyWithoutCondition(tk)}, nil))
+	require.True(t, check(ctx))
+
+	// the cache is bypassed by a Check without the cache
+	require.False(t, check(ContextWithCheckCacheMode(ctx, CheckCacheOff)))
+	require.True(t, check(ctx))
+}
@@ -0,0 +1,158 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/cespare/xxhash/v2"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+
+	openfgav1 "github.com/openfga/api/proto/openfga/v1"
+
+	"github.com/openfga/openfga/internal/build"
+	"github.com/openfga/openfga/internal/graph"
+	"github.com/openfga/openfga/pkg/storage"
+	"github.com/openfga/openfga/pkg/tuple"
+)
+
+var checkCoalescedRequestCounter = promauto.NewCounter(prometheus.CounterOpts{
+	Namespace: build.ProjectName,
+	Name:      "check_coalesced_request_count",
+	Help:      "The total number of Check requests that were answered with the result of an identical Check in flight.",
+})
+
+// coalescedCheckResult is the result of a Check that is shared by the identical Checks coalesced with it.
+type coalescedCheckResult struct {
+	resp     *graph.ResolveCheckResponse
+	metadata *graph.ResolveCheckRequestMetadata
+	err      error
+}
+
+// followerResult returns the result of the Check in flight for a Check coalesced with it. The response is a
+// copy, so that the Checks don't share it, without the datastore query count and the duration of the resolution,
+// and the request metadata is left out: the resolution is observed once, by the Check that resolved it.
+func (r coalescedCheckResult) followerResult() coalescedCheckResult {
+	if r.resp == nil {
+		return coalescedCheckResult{err: r.err}
+	}
+
+	metadata := r.resp.GetResolutionMetadata()
+	return coalescedCheckResult{
+		resp: &graph.ResolveCheckResponse{
+			Allowed: r.resp.GetAllowed(),
+			Score:   r.resp.GetScore(),
+			ResolutionMetadata: graph.ResolveCheckResponseMetadata{
+				CycleDetected: metadata.CycleDetected,
+				Degraded:      metadata.Degraded,
+			},
+		},
+		err: r.err,
+	}
+}
+
+type coalescedCheck struct {
+	started time.Time
+	done    chan struct{}
+	result  coalescedCheckResult
+}
+
+// checkCoalescer merges identical Checks that arrive while one of them is in flight. A Check joins the
+// identical Check in flight if it arrives within the coalescing window of its start, and is then answered
+// with its result. Past the window, the result in flight is deemed too old to answer a new Check, which is
+// then resolved on its own.
+type checkCoalescer struct {
+	window time.Duration
+
+	mu       sync.Mutex
+	inflight map[uint64]*coalescedCheck
+}
+
+func newCheckCoalescer(window time.Duration) *checkCoalescer {
+	return &checkCoalescer{
+		window:   window,
+		inflight: make(map[uint64]*coalescedCheck),
+	}
+}
+
+// do resolves the Check with the key with fn, unless an identical Check is in flight and started within
+// the window, in which case it waits for its result. It reports whether the result was shared.
+func (c *checkCoalescer) do(
+	ctx context.Context,
+	key uint64,
+	fn func() (*graph.ResolveCheckResponse, *graph.ResolveCheckRequestMetadata, error),
+) (coalescedCheckResult, bool) {
+	c.mu.Lock()
+	if call, ok := c.inflight[key]; ok && time.Since(call.started) <= c.window {
+		c.mu.Unlock()
+
+		select {
+		case <-ctx.Done():
+			return coalescedCheckResult{err: ctx.Err()}, false
+		case <-call.done:
+		}
+
+		// the Check in flight was cancelled by its own caller, not by the caller of this one
+		if !errors.Is(call.result.err, context.Canceled) && !errors.Is(call.result.err, context.DeadlineExceeded) {
+			checkCoalescedRequestCounter.Inc()
+			return call.result.followerResult(), true
+		}
+
+		resp, metadata, err := fn()
+		return coalescedCheckResult{resp: resp, metadata: metadata, err: err}, false
+	}
+
+	call := &coalescedCheck{started: time.Now(), done: make(chan struct{})}
+	c.inflight[key] = call
+	c.mu.Unlock()
+
+	defer func() {
+		c.mu.Lock()
+		if c.inflight[key] == call {
+			delete(c.inflight, key)
+		}
+		c.mu.Unlock()
+		close(call.done)
+	}()
+
+	resp, metadata, err := fn()
+	call.result = coalescedCheckResult{resp: resp, metadata: metadata, err: err}
+	return call.result, false
+}
+
+// checkCoalescingKey returns the key identifying the Checks that can be coalesced, i.e. the Checks of the
+// same tuple in the same store and model, with the same contextual tuples, context and consistency. As for the
+// keys of the Check cache, it is a hash, so that the size of the contextual tuples and of the context doesn't
+// add up in the Checks in flight.
+func checkCoalescingKey(
+	storeID, modelID string,
+	tupleKey *openfgav1.CheckRequestTupleKey,
+	req *openfgav1.CheckRequest,
+	wildcardPolicy tuple.WildcardPolicy,
+	maxContextualTuples uint32,
+) (uint64, error) {
+	hasher := xxhash.New()
+	err := storage.WriteCheckCacheKey(hasher, &storage.CheckCacheKeyParams{
+		StoreID:              storeID,
+		AuthorizationModelID: modelID,
+		TupleKey: &openfgav1.TupleKey{
+			Object:   tupleKey.GetObject(),
+			Relation: tupleKey.GetRelation(),
+			User:     tupleKey.GetUser(),
+		},
+		ContextualTuples:    req.GetContextualTuples().GetTupleKeys(),
+		Context:             req.GetContext(),
+		WildcardPolicy:      wildcardPolicy.String(),
+		MaxContextualTuples: int(maxContextualTuples),
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	if _, err := hasher.WriteString(" " + req.GetConsistency().String()); err != nil {
+		return 0, err
+	}
+	return hasher.Sum64(), nil
+}
@@ -0,0 +1,242 @@
+package server
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/goleak"
+
+	openfgav1 "github.com/openfga/api/proto/openfga/v1"
+	parser "github.com/openfga/language/pkg/go/transformer"
+
+	"github.com/openfga/openfga/internal/graph"
+	"github.com/openfga/openfga/pkg/storage"
+	"github.com/openfga/openfga/pkg/storage/memory"
+	"github.com/openfga/openfga/pkg/tuple"
+)
+
+// blockingDatastore blocks the reads of single tuples until it's released or their context is done.
+type blockingDatastore struct {
+	storage.OpenFGADatastore
+	started  chan struct{}
+	release  chan struct{}
+	reads    atomic.Int32
+	startOne sync.Once
+}
+
+func (d *blockingDatastore) ReadUserTuple(ctx context.Context, store string, tupleKey *openfgav1.TupleKey, options storage.ReadUserTupleOptions) (*openfgav1.Tuple, error) {
+	d.reads.Add(1)
+	d.startOne.Do(func() { close(d.started) })
+	select {
+	case <-d.release:
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+	return d.OpenFGADatastore.ReadUserTuple(ctx, store, tupleKey, options)
+}
+
+func TestCheckCoalescing(t *testing.T) {
+	t.Cleanup(func() {
+		goleak.VerifyNone(t)
+	})
+
+	ctx := context.Background()
+	ds := &blockingDatastore{
+		OpenFGADatastore: memory.New(),
+		started:          make(chan struct{}),
+		release:          make(chan struct{}),
+	}
+	s := MustNewServerWithOpts(
+		WithDatastore(ds),
+		WithCheckCoalescing(time.Minute),
+	)
+	t.Cleanup(s.Close)
+
+	createStoreResp, err := s.CreateStore(ctx, &openfgav1.CreateStoreRequest{Name: "check-coalescing"})
+	require.NoError(t, err)
+	storeID := createStoreResp.GetId()
+
+	model := parser.MustTransformDSLToProto(`
+		model
+			schema 1.1
+		type user
+		type document
+			relations
+				define viewer: [user]`)
+	_, err = s.WriteAuthorizationModel(ctx, &openfgav1.WriteAuthorizationModelRequest{
+		StoreId:         storeID,
+		TypeDefinitions: model.GetTypeDefinitions(),
+		SchemaVersion:   model.GetSchemaVersion(),
+	})
+	require.NoError(t, err)
+
+	_, err = s.Write(ctx, &openfgav1.WriteRequest{
+		StoreId: storeID,
+		Writes: &openfgav1.WriteRequestWrites{
+			TupleKeys: []*openfgav1.TupleKey{tuple.NewTupleKey("document:1", "viewer", "user:anne")},
+		},
+	})
+	require.NoError(t, err)
+
+	coalesced := testutil.ToFloat64(checkCoalescedRequestCounter)
+
+	var wg sync.WaitGroup
+	check := func(consistency openfgav1.ConsistencyPreference) {
+		defer wg.Done()
+		resp, err := s.Check(ctx, &openfgav1.CheckRequest{
+			StoreId:     storeID,
+			TupleKey:    tuple.NewCheckRequestTupleKey("document:1", "viewer", "user:anne"),
+			Consistency: consistency,
+		})
+		require.NoError(t, err)
+		require.True(t, resp.GetAllowed())
+	}
+
+	wg.Add(1)
+	go check(openfgav1.ConsistencyPreference_UNSPECIFIED)
+	<-ds.started
+
+	// the second Check arrives while the first one waits for the datastore, and so does the third one, which
+	// asks for HIGHER_CONSISTENCY and so reads the datastore itself
+	wg.Add(2)
+	go check(openfgav1.ConsistencyPreference_UNSPECIFIED)
+	go check(openfgav1.ConsistencyPreference_HIGHER_CONSISTENCY)
+	time.Sleep(50 * time.Millisecond)
+
+	close(ds.release)
+	wg.Wait()
+
+	require.Equal(t, int32(2), ds.reads.Load())
+	require.InDelta(t, coalesced+1, testutil.ToFloat64(checkCoalescedRequestCounter), 0)
+}
+
+func TestCheckCoalescer(t *testing.T) {
+	t.Cleanup(func() {
+		goleak.VerifyNone(t)
+	})
+
+	resolved := func(allowed bool) func() (*graph.ResolveCheckResponse, *graph.ResolveCheckRequestMetadata, error) {
+		return func() (*graph.ResolveCheckResponse, *graph.ResolveCheckRequestMetadata, error) {
+			return &graph.ResolveCheckResponse{Allowed: allowed}, nil, nil
+		}
+	}
+
+	// inFlight starts a Check with the key that resolves to the result once it's released
+	inFlight := func(c *checkCoalescer, key uint64, err error) (release func()) {
+		started := make(chan struct{})
+		releaseCh := make(chan struct{})
+		done := make(chan struct{})
+		go func() {
+			defer close(done)
+			c.do(context.Background(), key, func() (*graph.ResolveCheckResponse, *graph.ResolveCheckRequestMetadata, error) {
+				close(started)
+				<-releaseCh
+				return &graph.ResolveCheckResponse{Allowed: true}, nil, err
+			})
+		}()
+		<-started
+		return func() {
+			close(releaseCh)
+			<-done
+		}
+	}
+
+	t.Run("followers_get_their_own_response", func(t *testing.T) {
+		c := newCheckCoalescer(time.Minute)
+		leaderResp := &graph.ResolveCheckResponse{
+			Allowed: true,
+			ResolutionMetadata: graph.ResolveCheckResponseMetadata{
+				DatastoreQueryCount: 3,
+				Duration:            time.Second,
+				CycleDetected:       true,
+			},
+		}
+		leaderMetadata := graph.NewCheckRequestMetadata()
+
+		started := make(chan struct{})
+		releaseCh := make(chan struct{})
+		done := make(chan struct{})
+		go func() {
+			defer close(done)
+			result, coalesced := c.do(context.Background(), 1, func() (*graph.ResolveCheckResponse, *graph.ResolveCheckRequestMetadata, error) {
+				close(started)
+				<-releaseCh
+				return leaderResp, leaderMetadata, nil
+			})
+			require.False(t, coalesced)
+			require.Same(t, leaderResp, result.resp)
+			require.Same(t, leaderMetadata, result.metadata)
+		}()
+		<-started
+
+		go func() {
+			time.Sleep(10 * time.Millisecond)
+			close(releaseCh)
+		}()
+
+		result, coalesced := c.do(context.Background(), 1, resolved(false))
+		<-done
+		require.True(t, coalesced)
+		require.NotSame(t, leaderResp, result.resp)
+		require.True(t, result.resp.GetAllowed())
+		require.True(t, result.resp.GetResolutionMetadata().CycleDetected)
+		require.Zero(t, result.resp.GetResolutionMetadata().DatastoreQueryCount)
+		require.Zero(t, result.resp.GetResolutionMetadata().Duration)
+		require.Nil(t, result.metadata)
+	})
+
+	t.Run("different_keys_are_not_coalesced", func(t *testing.T) {
+		c := newCheckCoalescer(time.Minute)
+		release := inFlight(c, 1, nil)
+		defer release()
+
+		result, coalesced := c.do(context.Background(), 2, resolved(false))
+		require.False(t, coalesced)
+		require.False(t, result.resp.GetAllowed())
+	})
+
+	t.Run("past_the_window", func(t *testing.T) {
+		c := newCheckCoalescer(time.Millisecond)
+		release := inFlight(c, 1, nil)
+		defer release()
+
+		time.Sleep(5 * time.Millisecond)
+		result, coalesced := c.do(context.Background(), 1, resolved(false))
+		require.False(t, coalesced)
+		require.False(t, result.resp.GetAllowed())
+	})
+
+	t.Run("check_in_flight_cancelled", func(t *testing.T) {
+		c := newCheckCoalescer(time.Minute)
+		release := inFlight(c, 1, context.Canceled)
+
+		go func() {
+			time.Sleep(10 * time.Millisecond)
+			release()
+		}()
+
+		// the Check is resolved again rather than failing with the cancellation of another caller
+		result, coalesced := c.do(context.Background(), 1, resolved(false))
+		require.False(t, coalesced)
+		require.NoError(t, result.err)
+		require.False(t, result.resp.GetAllowed())
+	})
+
+	t.Run("caller_cancelled_while_waiting", func(t *testing.T) {
+		c := newCheckCoalescer(time.Minute)
+		release := inFlight(c, 1, nil)
+		defer release()
+
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+
+		result, coalesced := c.do(ctx, 1, resolved(false))
+		require.False(t, coalesced)
+		require.ErrorIs(t, result.err, context.Canceled)
+	})
+}
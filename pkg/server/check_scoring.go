@@ -0,0 +1,35 @@
+package server
+
+import (
+	"github.com/openfga/openfga/internal/graph"
+)
+
+// CheckScoring is the rubric used to score the strength of the relationship between a user and an object,
+// see CheckWithScore. A grant through a direct relationship tuple scores DirectScore and a grant through a
+// typed wildcard scores WildcardScore. Every hop through a userset or a tuple to userset rewrite multiplies
+// the score of the path by HopDecay. When several paths grant access, the highest scoring one wins.
+type CheckScoring struct {
+	// DirectScore is the score of a direct grant, it must be positive.
+	DirectScore float64
+	// WildcardScore is the score of a grant through a typed wildcard, it must not be negative.
+	WildcardScore float64
+	// HopDecay is the factor applied to the score for every hop, it must be in the range (0, 1].
+	HopDecay float64
+}
+
+// DefaultCheckScoring derives the score from the path depth: a direct grant scores 1, every hop halves the
+// score, and a wildcard grant scores lower than any direct grant up to three hops away.
+var DefaultCheckScoring = CheckScoring{
+	DirectScore:   graph.DefaultCheckScoring.DirectScore,
+	WildcardScore: graph.DefaultCheckScoring.WildcardScore,
+	HopDecay:      graph.DefaultCheckScoring.HopDecay,
+}
+
+// graphCheckScoring returns the rubric of the resolution of a Check scored with the scoring.
+func (s CheckScoring) graphCheckScoring() *graph.CheckScoring {
+	return &graph.CheckScoring{
+		DirectScore:   s.DirectScore,
+		WildcardScore: s.WildcardScore,
+		HopDecay:      s.HopDecay,
+	}
+}
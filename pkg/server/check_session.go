@@ -0,0 +1,228 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/oklog/ulid/v2"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	openfgav1 "github.com/openfga/api/proto/openfga/v1"
+
+	"github.com/openfga/openfga/internal/graph"
+	"github.com/openfga/openfga/internal/utils/apimethod"
+	"github.com/openfga/openfga/pkg/middleware/validator"
+	"github.com/openfga/openfga/pkg/server/commands"
+	serverErrors "github.com/openfga/openfga/pkg/server/errors"
+	"github.com/openfga/openfga/pkg/storage"
+	"github.com/openfga/openfga/pkg/telemetry"
+)
+
+// checkSession is a Check session that is open for a store.
+type checkSession struct {
+	storeID   string
+	session   *graph.CheckSession
+	expiresAt time.Time
+}
+
+// OpenCheckSession opens a Check session for the store and returns its ID. The Checks that are resolved
+// with the session (see CheckInSession) share the outcomes of the subproblems they resolve, e.g. the group
+// memberships of a user, without caching them for any other request. The session keeps at most the
+// configured number of outcomes (see WithCheckSessionMaxEntries) until it's closed with CloseCheckSession,
+// or until it expires (see WithCheckSessionTTL). Since the outcomes are kept for the lifetime of the
+// session, sessions are meant to be short-lived.
+func (s *Server) OpenCheckSession(ctx context.Context, storeID string) (string, error) {
+	ctx, span := tracer.Start(ctx, "OpenCheckSession", trace.WithAttributes(
+		attribute.String("store_id", storeID),
+	))
+	defer span.End()
+
+	err := s.checkAuthz(ctx, storeID, apimethod.Check)
+	if err != nil {
+		return "", err
+	}
+
+	s.checkSessionsMu.Lock()
+	defer s.checkSessionsMu.Unlock()
+
+	// the expired sessions don't count against the limits
+	now := s.checkSessionClock.Now()
+	for sessionID, session := range s.checkSessions {
+		if !now.Before(session.expiresAt) {
+			s.removeCheckSession(sessionID, session)
+		}
+	}
+
+	if len(s.checkSessions) >= s.maxCheckSessions || s.checkSessionsPerStore[storeID] >= s.maxCheckSessionsPerStore {
+		return "", serverErrors.ErrTooManyCheckSessions
+	}
+
+	sessionID := ulid.Make().String()
+	s.checkSessions[sessionID] = &checkSession{
+		storeID:   storeID,
+		session:   graph.NewCheckSession(s.checkSessionMaxEntries),
+		expiresAt: now.Add(s.checkSessionTTL),
+	}
+	s.checkSessionsPerStore[storeID]++
+	return sessionID, nil
+}
+
+// CloseCheckSession closes a Check session of the store and frees the outcomes that it keeps.
+func (s *Server) CloseCheckSession(ctx context.Context, storeID string, sessionID string) error {
+	ctx, span := tracer.Start(ctx, "CloseCheckSession", trace.WithAttributes(
+		attribute.String("store_id", storeID),
+	))
+	defer span.End()
+
+	err := s.checkAuthz(ctx, storeID, apimethod.Check)
+	if err != nil {
+		return err
+	}
+
+	s.checkSessionsMu.Lock()
+	defer s.checkSessionsMu.Unlock()
+
+	session, err := s.lookupCheckSession(sessionID, storeID)
+	if err != nil {
+		return err
+	}
+
+	s.removeCheckSession(sessionID, session)
+	return nil
+}
+
+// lookupCheckSession returns the open Check session of the store. An expired session is closed and isn't
+// found. The caller must hold checkSessionsMu.
+func (s *Server) lookupCheckSession(sessionID string, storeID string) (*checkSession, error) {
+	session, ok := s.checkSessions[sessionID]
+	if !ok {
+		return nil, serverErrors.ErrCheckSessionNotFound
+	}
+
+	if !s.checkSessionClock.Now().Before(session.expiresAt) {
+		s.removeCheckSession(sessionID, session)
+		return nil, serverErrors.ErrCheckSessionNotFound
+	}
+
+	if session.storeID != storeID {
+		return nil, serverErrors.ValidationError(fmt.Errorf("the Check session was opened for store '%s'", session.storeID))
+	}
+
+	return session, nil
+}
+
+// removeCheckSession closes the Check session and removes it from the open ones. The caller must hold
+// checkSessionsMu.
+func (s *Server) removeCheckSession(sessionID string, session *checkSession) {
+	session.session.Close()
+	delete(s.checkSessions, sessionID)
+
+	s.checkSessionsPerStore[session.storeID]--
+	if s.checkSessionsPerStore[session.storeID] <= 0 {
+		delete(s.checkSessionsPerStore, session.storeID)
+	}
+}
+
+// CheckInSession resolves a Check like Check, sharing the outcomes of its subproblems with the other
+// Checks of the session. The Check must be for the store that the session was opened for.
+func (s *Server) CheckInSession(ctx context.Context, sessionID string, req *openfgav1.CheckRequest) (*openfgav1.CheckResponse, error) {
+	const methodName = "check"
+
+	tk := req.GetTupleKey()
+	ctx, span := tracer.Start(ctx, "CheckInSession", trace.WithAttributes(
+		attribute.KeyValue{Key: "store_id", Value: attribute.StringValue(req.GetStoreId())},
+		attribute.KeyValue{Key: "object", Value: attribute.StringValue(tk.GetObject())},
+		attribute.KeyValue{Key: "relation", Value: attribute.StringValue(tk.GetRelation())},
+		attribute.KeyValue{Key: "user", Value: attribute.StringValue(tk.GetUser())},
+		attribute.KeyValue{Key: "consistency", Value: attribute.StringValue(req.GetConsistency().String())},
+	))
+	defer span.End()
+
+	if !validator.RequestIsValidatedFromContext(ctx) {
+		if err := req.Validate(); err != nil {
+			return nil, status.Error(codes.InvalidArgument, err.Error())
+		}
+	}
+
+	if err := s.validateContextualTuples(req.GetContextualTuples().GetTupleKeys()); err != nil {
+		return nil, err
+	}
+
+	ctx = telemetry.ContextWithRPCInfo(ctx, telemetry.RPCInfo{
+		Service: s.serviceName,
+		Method:  apimethod.Check.String(),
+	})
+
+	storeID := req.GetStoreId()
+	err := s.checkAuthz(ctx, storeID, apimethod.Check)
+	if err != nil {
+		return nil, err
+	}
+
+	s.checkSessionsMu.Lock()
+	session, err := s.lookupCheckSession(sessionID, storeID)
+	s.checkSessionsMu.Unlock()
+	if err != nil {
+		return nil, err
+	}
+
+	consistency, err := s.resolveConsistency(ctx, storeID, req.GetConsistency())
+	if err != nil {
+		return nil, err
+	}
+
+	ctx = storage.ContextWithConsistency(ctx, consistency)
+	typesys, err := s.resolveTypesystem(ctx, storeID, req.GetAuthorizationModelId())
+	if err != nil {
+		return nil, err
+	}
+
+	checkQuery := commands.NewCheckCommand(
+		s.datastore,
+		s.checkResolver,
+		typesys,
+		commands.WithCheckCommandLogger(s.logger),
+		commands.WithCheckCommandMaxConcurrentReads(s.maxConcurrentReadsForCheck),
+		commands.WithCheckCommandCache(s.sharedDatastoreResources, s.cacheSettings),
+		commands.WithCheckDatastoreThrottler(s.checkDatastoreThrottleThreshold, s.checkDatastoreThrottleDuration),
+		commands.WithCheckDispatchThrottlingFrequency(s.checkDispatchThrottlingFrequency),
+		commands.WithCheckCommandWildcardPolicy(s.wildcardPolicy(storeID)),
+	)
+
+	resp, _, err := checkQuery.Execute(ctx, &commands.CheckCommandParams{
+		StoreID:          storeID,
+		TupleKey:         req.GetTupleKey(),
+		ContextualTuples: req.GetContextualTuples(),
+		Context:          s.conditionContext(typesys, req.GetContext()),
+		Consistency:      consistency,
+		CacheMode:        checkCacheModeFromContext(ctx),
+		Session:          session.session,
+	})
+	if err != nil {
+		telemetry.TraceError(span, err)
+		finalErr := commands.CheckCommandErrorToServerError(err)
+		s.observeRetryableError(ctx, methodName, finalErr)
+		s.observeDatastoreError(storeID, finalErr)
+		return nil, finalErr
+	}
+
+	span.SetAttributes(attribute.Bool("allowed", resp.GetAllowed()))
+
+	return &openfgav1.CheckResponse{
+		Allowed: resp.GetAllowed(),
+	}, nil
+}
+
+// closeCheckSessions closes the Check sessions that are still open.
+func (s *Server) closeCheckSessions() {
+	s.checkSessionsMu.Lock()
+	defer s.checkSessionsMu.Unlock()
+
+	for sessionID, session := range s.checkSessions {
+		s.removeCheckSession(sessionID, session)
+	}
+}
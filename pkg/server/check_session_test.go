@@ -0,0 +1,221 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/oklog/ulid/v2"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/goleak"
+
+	openfgav1 "github.com/openfga/api/proto/openfga/v1"
+	parser "github.com/openfga/language/pkg/go/transformer"
+
+	"github.com/openfga/openfga/internal/clock"
+	serverconfig "github.com/openfga/openfga/pkg/server/config"
+	serverErrors "github.com/openfga/openfga/pkg/server/errors"
+	"github.com/openfga/openfga/pkg/storage"
+	"github.com/openfga/openfga/pkg/storage/memory"
+	"github.com/openfga/openfga/pkg/tuple"
+)
+
+// memberReadCountingDatastore counts the reads of the tuples of the 'member' relation.
+type memberReadCountingDatastore struct {
+	storage.OpenFGADatastore
+	reads atomic.Int32
+}
+
+func (d *memberReadCountingDatastore) ReadUserTuple(ctx context.Context, store string, tupleKey *openfgav1.TupleKey, options storage.ReadUserTupleOptions) (*openfgav1.Tuple, error) {
+	if tupleKey.GetRelation() == "member" {
+		d.reads.Add(1)
+	}
+	return d.OpenFGADatastore.ReadUserTuple(ctx, store, tupleKey, options)
+}
+
+func TestCheckSession(t *testing.T) {
+	t.Cleanup(func() {
+		goleak.VerifyNone(t)
+	})
+
+	ctx := context.Background()
+	ds := &memberReadCountingDatastore{OpenFGADatastore: memory.New()}
+	s := MustNewServerWithOpts(
+		WithDatastore(ds),
+		WithMaxCheckSessions(2),
+	)
+	t.Cleanup(s.Close)
+
+	createStoreResp, err := s.CreateStore(ctx, &openfgav1.CreateStoreRequest{Name: "check-session"})
+	require.NoError(t, err)
+	storeID := createStoreResp.GetId()
+
+	model := parser.MustTransformDSLToProto(`
+		model
+			schema 1.1
+		type user
+		type group
+			relations
+				define member: [user]
+		type document
+			relations
+				define viewer: [group#member]`)
+	writeModelResp, err := s.WriteAuthorizationModel(ctx, &openfgav1.WriteAuthorizationModelRequest{
+		StoreId:         storeID,
+		TypeDefinitions: model.GetTypeDefinitions(),
+		SchemaVersion:   model.GetSchemaVersion(),
+	})
+	require.NoError(t, err)
+
+	_, err = s.Write(ctx, &openfgav1.WriteRequest{
+		StoreId: storeID,
+		Writes: &openfgav1.WriteRequestWrites{
+			TupleKeys: []*openfgav1.TupleKey{
+				tuple.NewTupleKey("group:eng", "member", "user:bob"),
+				tuple.NewTupleKey("document:1", "viewer", "group:eng#member"),
+				tuple.NewTupleKey("document:2", "viewer", "group:eng#member"),
+			},
+		},
+	})
+	require.NoError(t, err)
+
+	checkRequest := func(object string) *openfgav1.CheckRequest {
+		return &openfgav1.CheckRequest{
+			StoreId:              storeID,
+			AuthorizationModelId: writeModelResp.GetAuthorizationModelId(),
+			TupleKey:             tuple.NewCheckRequestTupleKey(object, "viewer", "user:bob"),
+		}
+	}
+
+	t.Run("checks_of_a_session_share_the_group_resolution", func(t *testing.T) {
+		sessionID, err := s.OpenCheckSession(ctx, storeID)
+		require.NoError(t, err)
+		t.Cleanup(func() {
+			_ = s.CloseCheckSession(ctx, storeID, sessionID)
+		})
+
+		ds.reads.Store(0)
+		for _, object := range []string{"document:1", "document:2"} {
+			resp, err := s.CheckInSession(ctx, sessionID, checkRequest(object))
+			require.NoError(t, err)
+			require.True(t, resp.GetAllowed())
+		}
+		require.Equal(t, int32(1), ds.reads.Load())
+
+		// the resolution isn't shared with other sessions
+		otherSessionID, err := s.OpenCheckSession(ctx, storeID)
+		require.NoError(t, err)
+		t.Cleanup(func() {
+			_ = s.CloseCheckSession(ctx, storeID, otherSessionID)
+		})
+
+		ds.reads.Store(0)
+		resp, err := s.CheckInSession(ctx, otherSessionID, checkRequest("document:1"))
+		require.NoError(t, err)
+		require.True(t, resp.GetAllowed())
+		require.Equal(t, int32(1), ds.reads.Load())
+	})
+
+	t.Run("closed_session", func(t *testing.T) {
+		sessionID, err := s.OpenCheckSession(ctx, storeID)
+		require.NoError(t, err)
+		require.NoError(t, s.CloseCheckSession(ctx, storeID, sessionID))
+
+		_, err = s.CheckInSession(ctx, sessionID, checkRequest("document:1"))
+		require.ErrorIs(t, err, serverErrors.ErrCheckSessionNotFound)
+		require.ErrorIs(t, s.CloseCheckSession(ctx, storeID, sessionID), serverErrors.ErrCheckSessionNotFound)
+	})
+
+	t.Run("other_store", func(t *testing.T) {
+		otherStoreID := ulid.Make().String()
+		sessionID, err := s.OpenCheckSession(ctx, otherStoreID)
+		require.NoError(t, err)
+		t.Cleanup(func() {
+			_ = s.CloseCheckSession(ctx, otherStoreID, sessionID)
+		})
+
+		_, err = s.CheckInSession(ctx, sessionID, checkRequest("document:1"))
+		require.ErrorContains(t, err, "the Check session was opened for store")
+
+		// the session can only be closed for its store
+		require.ErrorContains(t, s.CloseCheckSession(ctx, storeID, sessionID), "the Check session was opened for store")
+	})
+
+	t.Run("expired_session", func(t *testing.T) {
+		fakeClock := clock.NewFake(time.Now())
+		s.checkSessionClock = fakeClock
+		t.Cleanup(func() {
+			s.checkSessionClock = clock.New()
+		})
+
+		sessionID, err := s.OpenCheckSession(ctx, storeID)
+		require.NoError(t, err)
+
+		fakeClock.Advance(s.checkSessionTTL)
+		_, err = s.CheckInSession(ctx, sessionID, checkRequest("document:1"))
+		require.ErrorIs(t, err, serverErrors.ErrCheckSessionNotFound)
+		require.Empty(t, s.checkSessions)
+		require.Empty(t, s.checkSessionsPerStore)
+	})
+
+	t.Run("contextual_tuples_limit", func(t *testing.T) {
+		s.maxContextualTuples = 1
+		t.Cleanup(func() {
+			s.maxContextualTuples = serverconfig.DefaultMaxContextualTuples
+		})
+
+		sessionID, err := s.OpenCheckSession(ctx, storeID)
+		require.NoError(t, err)
+		t.Cleanup(func() {
+			_ = s.CloseCheckSession(ctx, storeID, sessionID)
+		})
+
+		req := checkRequest("document:1")
+		req.ContextualTuples = &openfgav1.ContextualTupleKeys{}
+		for i := range s.maxContextualTuples + 1 {
+			req.ContextualTuples.TupleKeys = append(req.ContextualTuples.TupleKeys, tuple.NewTupleKey(fmt.Sprintf("group:%d", i), "member", "user:bob"))
+		}
+
+		_, err = s.CheckInSession(ctx, sessionID, req)
+		require.ErrorContains(t, err, "contextual tuples, the maximum allowed is")
+	})
+
+	t.Run("too_many_sessions", func(t *testing.T) {
+		var sessionIDs []string
+		for i := 0; i < 2; i++ {
+			sessionID, err := s.OpenCheckSession(ctx, storeID)
+			require.NoError(t, err)
+			sessionIDs = append(sessionIDs, sessionID)
+		}
+
+		_, err := s.OpenCheckSession(ctx, storeID)
+		require.ErrorIs(t, err, serverErrors.ErrTooManyCheckSessions)
+
+		for _, sessionID := range sessionIDs {
+			require.NoError(t, s.CloseCheckSession(ctx, storeID, sessionID))
+		}
+	})
+
+	t.Run("too_many_sessions_of_a_store", func(t *testing.T) {
+		s.maxCheckSessionsPerStore = 1
+		t.Cleanup(func() {
+			s.maxCheckSessionsPerStore = serverconfig.DefaultMaxCheckSessionsPerStore
+		})
+
+		sessionID, err := s.OpenCheckSession(ctx, storeID)
+		require.NoError(t, err)
+
+		_, err = s.OpenCheckSession(ctx, storeID)
+		require.ErrorIs(t, err, serverErrors.ErrTooManyCheckSessions)
+
+		// the other stores still have sessions
+		otherStoreID := ulid.Make().String()
+		otherSessionID, err := s.OpenCheckSession(ctx, otherStoreID)
+		require.NoError(t, err)
+
+		require.NoError(t, s.CloseCheckSession(ctx, storeID, sessionID))
+		require.NoError(t, s.CloseCheckSession(ctx, otherStoreID, otherSessionID))
+	})
+}
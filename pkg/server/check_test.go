@@ -1,13 +1,28 @@
 package server
 
 import (
+	"context"
+	"fmt"
 	"testing"
+	"time"
 
+	"github.com/oklog/ulid/v2"
+	"github.com/prometheus/client_golang/prometheus/testutil"
 	"github.com/stretchr/testify/require"
+	"go.uber.org/goleak"
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/status"
 
 	openfgav1 "github.com/openfga/api/proto/openfga/v1"
+	parser "github.com/openfga/language/pkg/go/transformer"
+
+	"github.com/openfga/openfga/internal/graph"
+	"github.com/openfga/openfga/pkg/gateway"
+	"github.com/openfga/openfga/pkg/server/commands"
+	serverErrors "github.com/openfga/openfga/pkg/server/errors"
+	"github.com/openfga/openfga/pkg/storage/memory"
+	"github.com/openfga/openfga/pkg/tuple"
+	"github.com/openfga/openfga/pkg/typesystem"
 )
 
 func TestCheck_Validation(t *testing.T) {
@@ -82,3 +97,1042 @@ func TestCheck_Validation(t *testing.T) {
 		})
 	}
 }
+
+func TestMalformedTupleKeys(t *testing.T) {
+	t.Cleanup(func() {
+		goleak.VerifyNone(t)
+	})
+
+	ctx := context.Background()
+	s := MustNewServerWithOpts(WithDatastore(memory.New()))
+	t.Cleanup(s.Close)
+
+	createStoreResp, err := s.CreateStore(ctx, &openfgav1.CreateStoreRequest{Name: "malformed-tuple-keys"})
+	require.NoError(t, err)
+	storeID := createStoreResp.GetId()
+
+	model := parser.MustTransformDSLToProto(`
+		model
+			schema 1.1
+		type user
+		type group
+			relations
+				define member: [user]
+		type repo
+			relations
+				define reader: [user, group#member]`)
+	_, err = s.WriteAuthorizationModel(ctx, &openfgav1.WriteAuthorizationModelRequest{
+		StoreId:         storeID,
+		TypeDefinitions: model.GetTypeDefinitions(),
+		SchemaVersion:   model.GetSchemaVersion(),
+	})
+	require.NoError(t, err)
+
+	tests := []struct {
+		name                 string
+		tupleKey             *openfgav1.TupleKey
+		expectedCheckError   string // empty if Check accepts the tuple key, which it validates loosely.
+		expectedWriteMessage string
+	}{
+		{
+			name:                 "object_without_id",
+			tupleKey:             tuple.NewTupleKey("repo", "reader", "user:anne"),
+			expectedCheckError:   "invalid 'object' field format",
+			expectedWriteMessage: "Invalid tuple 'repo#reader@user:anne'. Reason: invalid 'object' field format",
+		},
+		{
+			name:                 "object_without_type",
+			tupleKey:             tuple.NewTupleKey(":openfga", "reader", "user:anne"),
+			expectedCheckError:   "invalid 'object' field format",
+			expectedWriteMessage: "Invalid tuple ':openfga#reader@user:anne'. Reason: invalid 'object' field format",
+		},
+		{
+			name:                 "object_wildcard",
+			tupleKey:             tuple.NewTupleKey("repo:*", "reader", "user:anne"),
+			expectedCheckError:   "the 'object' field cannot reference a typed wildcard",
+			expectedWriteMessage: "Invalid tuple 'repo:*#reader@user:anne'. Reason: the 'object' field cannot reference a typed wildcard",
+		},
+		{
+			name:                 "undefined_relation",
+			tupleKey:             tuple.NewTupleKey("repo:openfga", "writer", "user:anne"),
+			expectedCheckError:   "relation 'repo#writer' not found",
+			expectedWriteMessage: "Invalid tuple 'repo:openfga#writer@user:anne'. Reason: relation 'repo#writer' not found",
+		},
+		{
+			name:                 "untyped_user",
+			tupleKey:             tuple.NewTupleKey("repo:openfga", "reader", "anne"),
+			expectedCheckError:   "the 'user' field must be an object (e.g. document:1) or an 'object#relation' or a typed wildcard (e.g. group:*)",
+			expectedWriteMessage: "Invalid tuple 'repo:openfga#reader@anne'. Reason: the 'user' field must be an object (e.g. document:1) or an 'object#relation' or a typed wildcard (e.g. group:*)",
+		},
+		{
+			name:                 "user_without_id",
+			tupleKey:             tuple.NewTupleKey("repo:openfga", "reader", "user:"),
+			expectedCheckError:   "the 'user' field is malformed",
+			expectedWriteMessage: "Invalid tuple 'repo:openfga#reader@user:'. Reason: the 'user' field is malformed",
+		},
+		{
+			name:                 "userset_without_relation",
+			tupleKey:             tuple.NewTupleKey("repo:openfga", "reader", "group:eng#"),
+			expectedCheckError:   "the 'user' field is malformed",
+			expectedWriteMessage: "Invalid tuple 'repo:openfga#reader@group:eng#'. Reason: the 'user' field is malformed",
+		},
+		{
+			name:                 "undefined_user_type",
+			tupleKey:             tuple.NewTupleKey("repo:openfga", "reader", "team:eng"),
+			expectedCheckError:   "type 'team' not found",
+			expectedWriteMessage: "Invalid tuple 'repo:openfga#reader@team:eng'. Reason: type 'team' not found",
+		},
+		{
+			name:                 "undefined_userset_relation",
+			tupleKey:             tuple.NewTupleKey("repo:openfga", "reader", "group:eng#admin"),
+			expectedCheckError:   "relation 'group#admin' not found",
+			expectedWriteMessage: "Invalid tuple 'repo:openfga#reader@group:eng#admin'. Reason: relation 'group#admin' not found",
+		},
+		{
+			name:                 "user_type_not_allowed",
+			tupleKey:             tuple.NewTupleKey("repo:openfga", "reader", "group:eng"),
+			expectedWriteMessage: "Invalid tuple 'repo:openfga#reader@group:eng'. Reason: type 'group' is not an allowed type restriction for 'repo#reader'",
+		},
+		{
+			name:                 "wildcard_not_allowed",
+			tupleKey:             tuple.NewTupleKey("repo:openfga", "reader", "user:*"),
+			expectedWriteMessage: "Invalid tuple 'repo:openfga#reader@user:*'. Reason: the typed wildcard 'user:*' is not an allowed type restriction for 'repo#reader'",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			_, err := s.Check(ctx, &openfgav1.CheckRequest{
+				StoreId: storeID,
+				TupleKey: tuple.NewCheckRequestTupleKey(
+					test.tupleKey.GetObject(), test.tupleKey.GetRelation(), test.tupleKey.GetUser(),
+				),
+			})
+			if test.expectedCheckError == "" {
+				require.NoError(t, err)
+			} else {
+				require.Equal(t, codes.Code(openfgav1.ErrorCode_validation_error), status.Code(err))
+				require.Equal(t, test.expectedCheckError, status.Convert(err).Message())
+			}
+
+			_, err = s.Write(ctx, &openfgav1.WriteRequest{
+				StoreId: storeID,
+				Writes:  &openfgav1.WriteRequestWrites{TupleKeys: []*openfgav1.TupleKey{test.tupleKey}},
+			})
+			require.Equal(t, codes.Code(openfgav1.ErrorCode_validation_error), status.Code(err))
+			require.Equal(t, test.expectedWriteMessage, status.Convert(err).Message())
+		})
+	}
+}
+
+func TestMaxContextualTuples(t *testing.T) {
+	t.Cleanup(func() {
+		goleak.VerifyNone(t)
+	})
+
+	ctx := context.Background()
+	s := MustNewServerWithOpts(
+		WithDatastore(memory.New()),
+		WithMaxContextualTuples(1),
+	)
+	t.Cleanup(s.Close)
+
+	createStoreResp, err := s.CreateStore(ctx, &openfgav1.CreateStoreRequest{Name: "max-contextual-tuples"})
+	require.NoError(t, err)
+	storeID := createStoreResp.GetId()
+
+	model := parser.MustTransformDSLToProto(`
+		model
+			schema 1.1
+		type user
+		type document
+			relations
+				define viewer: [user]`)
+	_, err = s.WriteAuthorizationModel(ctx, &openfgav1.WriteAuthorizationModelRequest{
+		StoreId:         storeID,
+		TypeDefinitions: model.GetTypeDefinitions(),
+		SchemaVersion:   model.GetSchemaVersion(),
+	})
+	require.NoError(t, err)
+
+	oneTuple := &openfgav1.ContextualTupleKeys{
+		TupleKeys: []*openfgav1.TupleKey{tuple.NewTupleKey("document:1", "viewer", "user:anne")},
+	}
+	twoTuples := &openfgav1.ContextualTupleKeys{
+		TupleKeys: []*openfgav1.TupleKey{
+			tuple.NewTupleKey("document:1", "viewer", "user:anne"),
+			tuple.NewTupleKey("document:2", "viewer", "user:anne"),
+		},
+	}
+	const expectedMessage = "the request has 2 contextual tuples, the maximum allowed is 1"
+
+	t.Run("check", func(t *testing.T) {
+		resp, err := s.Check(ctx, &openfgav1.CheckRequest{
+			StoreId:          storeID,
+			TupleKey:         tuple.NewCheckRequestTupleKey("document:1", "viewer", "user:anne"),
+			ContextualTuples: oneTuple,
+		})
+		require.NoError(t, err)
+		require.True(t, resp.GetAllowed())
+
+		_, err = s.Check(ctx, &openfgav1.CheckRequest{
+			StoreId:          storeID,
+			TupleKey:         tuple.NewCheckRequestTupleKey("document:1", "viewer", "user:anne"),
+			ContextualTuples: twoTuples,
+		})
+		require.Equal(t, codes.Code(openfgav1.ErrorCode_validation_error), status.Code(err))
+		require.ErrorContains(t, err, expectedMessage)
+	})
+
+	t.Run("batch_check", func(t *testing.T) {
+		_, err := s.BatchCheck(ctx, &openfgav1.BatchCheckRequest{
+			StoreId: storeID,
+			Checks: []*openfgav1.BatchCheckItem{
+				{
+					TupleKey:         tuple.NewCheckRequestTupleKey("document:1", "viewer", "user:anne"),
+					ContextualTuples: oneTuple,
+					CorrelationId:    "1",
+				},
+				{
+					TupleKey:         tuple.NewCheckRequestTupleKey("document:2", "viewer", "user:anne"),
+					ContextualTuples: twoTuples,
+					CorrelationId:    "2",
+				},
+			},
+		})
+		require.Equal(t, codes.Code(openfgav1.ErrorCode_validation_error), status.Code(err))
+		require.ErrorContains(t, err, expectedMessage)
+	})
+
+	t.Run("list_objects", func(t *testing.T) {
+		resp, err := s.ListObjects(ctx, &openfgav1.ListObjectsRequest{
+			StoreId:          storeID,
+			Type:             "document",
+			Relation:         "viewer",
+			User:             "user:anne",
+			ContextualTuples: oneTuple,
+		})
+		require.NoError(t, err)
+		require.Equal(t, []string{"document:1"}, resp.GetObjects())
+
+		_, err = s.ListObjects(ctx, &openfgav1.ListObjectsRequest{
+			StoreId:          storeID,
+			Type:             "document",
+			Relation:         "viewer",
+			User:             "user:anne",
+			ContextualTuples: twoTuples,
+		})
+		require.Equal(t, codes.Code(openfgav1.ErrorCode_validation_error), status.Code(err))
+		require.ErrorContains(t, err, expectedMessage)
+	})
+
+	t.Run("streamed_list_objects", func(t *testing.T) {
+		err := s.StreamedListObjects(&openfgav1.StreamedListObjectsRequest{
+			StoreId:          storeID,
+			Type:             "document",
+			Relation:         "viewer",
+			User:             "user:anne",
+			ContextualTuples: twoTuples,
+		}, NewMockStreamServer(ctx))
+		require.Equal(t, codes.Code(openfgav1.ErrorCode_validation_error), status.Code(err))
+		require.ErrorContains(t, err, expectedMessage)
+	})
+
+	checkRequest := &openfgav1.CheckRequest{
+		StoreId:          storeID,
+		TupleKey:         tuple.NewCheckRequestTupleKey("document:1", "viewer", "user:anne"),
+		ContextualTuples: twoTuples,
+	}
+
+	t.Run("check_with_trace", func(t *testing.T) {
+		_, _, err := s.CheckWithTrace(ctx, checkRequest)
+		require.Equal(t, codes.Code(openfgav1.ErrorCode_validation_error), status.Code(err))
+		require.ErrorContains(t, err, expectedMessage)
+	})
+
+	t.Run("check_with_model_delta", func(t *testing.T) {
+		_, err := s.CheckWithModelDelta(ctx, checkRequest, &typesystem.ModelDelta{})
+		require.Equal(t, codes.Code(openfgav1.ErrorCode_validation_error), status.Code(err))
+		require.ErrorContains(t, err, expectedMessage)
+	})
+
+	t.Run("count_objects", func(t *testing.T) {
+		_, err := s.CountObjects(ctx, &openfgav1.ListObjectsRequest{
+			StoreId:          storeID,
+			Type:             "document",
+			Relation:         "viewer",
+			User:             "user:anne",
+			ContextualTuples: twoTuples,
+		})
+		require.Equal(t, codes.Code(openfgav1.ErrorCode_validation_error), status.Code(err))
+		require.ErrorContains(t, err, expectedMessage)
+	})
+
+	listUsersRequest := &openfgav1.ListUsersRequest{
+		StoreId:          storeID,
+		Object:           &openfgav1.Object{Type: "document", Id: "1"},
+		Relation:         "viewer",
+		UserFilters:      []*openfgav1.UserTypeFilter{{Type: "user"}},
+		ContextualTuples: twoTuples.GetTupleKeys(),
+	}
+
+	t.Run("list_users", func(t *testing.T) {
+		_, err := s.ListUsers(ctx, listUsersRequest)
+		require.Equal(t, codes.Code(openfgav1.ErrorCode_validation_error), status.Code(err))
+		require.ErrorContains(t, err, expectedMessage)
+	})
+
+	t.Run("streamed_list_users", func(t *testing.T) {
+		err := s.StreamedListUsers(listUsersRequest, &streamedListUsersServer{ctx: ctx})
+		require.Equal(t, codes.Code(openfgav1.ErrorCode_validation_error), status.Code(err))
+		require.ErrorContains(t, err, expectedMessage)
+	})
+}
+
+func TestCheck_RelationCounter(t *testing.T) {
+	t.Cleanup(func() {
+		goleak.VerifyNone(t)
+	})
+
+	ctx := context.Background()
+	ds := memory.New()
+	s := MustNewServerWithOpts(WithDatastore(ds))
+	t.Cleanup(s.Close)
+
+	createStoreResp, err := s.CreateStore(ctx, &openfgav1.CreateStoreRequest{Name: "relation-counter"})
+	require.NoError(t, err)
+	storeID := createStoreResp.GetId()
+
+	model := parser.MustTransformDSLToProto(`
+		model
+			schema 1.1
+		type user
+		type folder
+			relations
+				define editor: [user]`)
+	writeModelResp, err := s.WriteAuthorizationModel(ctx, &openfgav1.WriteAuthorizationModelRequest{
+		StoreId:         storeID,
+		TypeDefinitions: model.GetTypeDefinitions(),
+		SchemaVersion:   model.GetSchemaVersion(),
+	})
+	require.NoError(t, err)
+
+	counter := checkRelationCounter.WithLabelValues("folder", "editor")
+	before := testutil.ToFloat64(counter)
+
+	_, err = s.Check(ctx, &openfgav1.CheckRequest{
+		StoreId:              storeID,
+		AuthorizationModelId: writeModelResp.GetAuthorizationModelId(),
+		TupleKey:             tuple.NewCheckRequestTupleKey("folder:1", "editor", "user:anne"),
+	})
+	require.NoError(t, err)
+	require.InDelta(t, before+1, testutil.ToFloat64(counter), 0)
+
+	t.Run("undefined_relation_is_not_counted", func(t *testing.T) {
+		_, err := s.Check(ctx, &openfgav1.CheckRequest{
+			StoreId:              storeID,
+			AuthorizationModelId: writeModelResp.GetAuthorizationModelId(),
+			TupleKey:             tuple.NewCheckRequestTupleKey("folder:1", "undefined", "user:anne"),
+		})
+		require.Error(t, err)
+		require.Zero(t, testutil.ToFloat64(checkRelationCounter.WithLabelValues("folder", "undefined")))
+	})
+}
+
+// registeredObjects is an ObjectRegistry of a fixed set of objects.
+type registeredObjects map[string]bool
+
+func (r registeredObjects) ObjectExists(_ context.Context, _, object string) (bool, error) {
+	return r[object], nil
+}
+
+// headerRecordingTransport records the headers set on the responses.
+type headerRecordingTransport struct {
+	headers map[string]string
+}
+
+func (h *headerRecordingTransport) SetHeader(_ context.Context, key, value string) {
+	h.headers[key] = value
+}
+
+// trailerRecordingTransport records the headers and the trailing metadata set on the responses.
+type trailerRecordingTransport struct {
+	headerRecordingTransport
+	trailers map[string]string
+}
+
+func (h *trailerRecordingTransport) SetTrailer(_ context.Context, key, value string) {
+	h.trailers[key] = value
+}
+
+func TestCheckWithObjectRegistry(t *testing.T) {
+	t.Cleanup(func() {
+		goleak.VerifyNone(t)
+	})
+
+	ctx := context.Background()
+	transport := &headerRecordingTransport{headers: map[string]string{}}
+	s := MustNewServerWithOpts(
+		WithDatastore(memory.New()),
+		WithTransport(transport),
+		WithCheckObjectRegistry(registeredObjects{"doc:1": true, "doc:2": true}),
+	)
+	t.Cleanup(s.Close)
+
+	createStoreResp, err := s.CreateStore(ctx, &openfgav1.CreateStoreRequest{Name: "object-registry"})
+	require.NoError(t, err)
+	storeID := createStoreResp.GetId()
+
+	model := parser.MustTransformDSLToProto(`
+		model
+			schema 1.1
+		type user
+		type doc
+			relations
+				define viewer: [user]`)
+	writeModelResp, err := s.WriteAuthorizationModel(ctx, &openfgav1.WriteAuthorizationModelRequest{
+		StoreId:         storeID,
+		TypeDefinitions: model.GetTypeDefinitions(),
+		SchemaVersion:   model.GetSchemaVersion(),
+	})
+	require.NoError(t, err)
+
+	_, err = s.Write(ctx, &openfgav1.WriteRequest{
+		StoreId: storeID,
+		Writes: &openfgav1.WriteRequestWrites{
+			TupleKeys: []*openfgav1.TupleKey{tuple.NewTupleKey("doc:1", "viewer", "user:anne")},
+		},
+	})
+	require.NoError(t, err)
+
+	check := func(object string) (*openfgav1.CheckResponse, error) {
+		transport.headers = map[string]string{}
+		return s.Check(ctx, &openfgav1.CheckRequest{
+			StoreId:              storeID,
+			AuthorizationModelId: writeModelResp.GetAuthorizationModelId(),
+			TupleKey:             tuple.NewCheckRequestTupleKey(object, "viewer", "user:anne"),
+		})
+	}
+
+	t.Run("granted", func(t *testing.T) {
+		resp, err := check("doc:1")
+		require.NoError(t, err)
+		require.True(t, resp.GetAllowed())
+		require.NotContains(t, transport.headers, ObjectExistsHeader)
+	})
+
+	t.Run("exists_but_denied", func(t *testing.T) {
+		resp, err := check("doc:2")
+		require.NoError(t, err)
+		require.False(t, resp.GetAllowed())
+		require.Equal(t, "true", transport.headers[ObjectExistsHeader])
+	})
+
+	t.Run("not_found", func(t *testing.T) {
+		_, err := check("doc:3")
+		require.Error(t, err)
+		require.Equal(t, codes.NotFound, status.Code(err))
+		require.NotContains(t, transport.headers, ObjectExistsHeader)
+	})
+}
+
+func TestCheckDatastoreQueryCountHeader(t *testing.T) {
+	t.Cleanup(func() {
+		goleak.VerifyNone(t)
+	})
+
+	ctx := context.Background()
+	transport := &headerRecordingTransport{headers: map[string]string{}}
+	s := MustNewServerWithOpts(
+		WithDatastore(memory.New()),
+		WithTransport(transport),
+		WithCheckQueryCacheEnabled(true),
+	)
+	t.Cleanup(s.Close)
+
+	createStoreResp, err := s.CreateStore(ctx, &openfgav1.CreateStoreRequest{Name: "datastore-query-count"})
+	require.NoError(t, err)
+	storeID := createStoreResp.GetId()
+
+	model := parser.MustTransformDSLToProto(`
+		model
+			schema 1.1
+		type user
+		type doc
+			relations
+				define viewer: [user]`)
+	writeModelResp, err := s.WriteAuthorizationModel(ctx, &openfgav1.WriteAuthorizationModelRequest{
+		StoreId:         storeID,
+		TypeDefinitions: model.GetTypeDefinitions(),
+		SchemaVersion:   model.GetSchemaVersion(),
+	})
+	require.NoError(t, err)
+
+	check := func() string {
+		transport.headers = map[string]string{}
+		_, err := s.Check(ctx, &openfgav1.CheckRequest{
+			StoreId:              storeID,
+			AuthorizationModelId: writeModelResp.GetAuthorizationModelId(),
+			TupleKey:             tuple.NewCheckRequestTupleKey("doc:1", "viewer", "user:anne"),
+		})
+		require.NoError(t, err)
+		require.Contains(t, transport.headers, DatastoreQueryCountHeader)
+		return transport.headers[DatastoreQueryCountHeader]
+	}
+
+	require.Equal(t, "1", check())
+
+	// the second Check is served from the cache
+	require.Equal(t, "0", check())
+}
+
+func TestWriteInvalidatesCachedChecks(t *testing.T) {
+	t.Cleanup(func() {
+		goleak.VerifyNone(t)
+	})
+
+	ctx := context.Background()
+	s := MustNewServerWithOpts(
+		WithDatastore(memory.New()),
+		WithCheckQueryCacheEnabled(true),
+		WithCheckQueryCacheTTL(time.Hour),
+	)
+	t.Cleanup(s.Close)
+
+	createStoreResp, err := s.CreateStore(ctx, &openfgav1.CreateStoreRequest{Name: "write-invalidation"})
+	require.NoError(t, err)
+	storeID := createStoreResp.GetId()
+
+	model := parser.MustTransformDSLToProto(`
+		model
+			schema 1.1
+		type user
+		type doc
+			relations
+				define viewer: [user]`)
+	_, err = s.WriteAuthorizationModel(ctx, &openfgav1.WriteAuthorizationModelRequest{
+		StoreId:         storeID,
+		TypeDefinitions: model.GetTypeDefinitions(),
+		SchemaVersion:   model.GetSchemaVersion(),
+	})
+	require.NoError(t, err)
+
+	tk := tuple.NewTupleKey("doc:1", "viewer", "user:anne")
+	check := func() bool {
+		resp, err := s.Check(ctx, &openfgav1.CheckRequest{
+			StoreId:  storeID,
+			TupleKey: tuple.NewCheckRequestTupleKey(tk.GetObject(), tk.GetRelation(), tk.GetUser()),
+		})
+		require.NoError(t, err)
+		return resp.GetAllowed()
+	}
+
+	_, err = s.Write(ctx, &openfgav1.WriteRequest{
+		StoreId: storeID,
+		Writes:  &openfgav1.WriteRequestWrites{TupleKeys: []*openfgav1.TupleKey{tk}},
+	})
+	require.NoError(t, err)
+	require.True(t, check())
+
+	// the cached response of the Check of the deleted tuple isn't served anymore
+	_, err = s.Write(ctx, &openfgav1.WriteRequest{
+		StoreId: storeID,
+		Deletes: &openfgav1.WriteRequestDeletes{TupleKeys: []*openfgav1.TupleKeyWithoutCondition{
+			tuple.TupleKeyToTupleKeyWithoutCondition(tk),
+		}},
+	})
+	require.NoError(t, err)
+	require.False(t, check())
+}
+
+func TestCheckWithRelationAliases(t *testing.T) {
+	t.Cleanup(func() {
+		goleak.VerifyNone(t)
+	})
+
+	ctx := context.Background()
+	ds := memory.New()
+	t.Cleanup(ds.Close)
+
+	storeID := ulid.Make().String()
+	_, err := ds.CreateStore(ctx, &openfgav1.Store{Id: storeID, Name: "relation-aliases"})
+	require.NoError(t, err)
+
+	s := MustNewServerWithOpts(
+		WithDatastore(ds),
+		WithCheckQueryCacheEnabled(true),
+		WithRelationAliases(storeID, map[string]string{"doc#reader": "viewer"}),
+	)
+	t.Cleanup(s.Close)
+
+	model := parser.MustTransformDSLToProto(`
+		model
+			schema 1.1
+		type user
+		type doc
+			relations
+				define viewer: [user]`)
+	writeModelResp, err := s.WriteAuthorizationModel(ctx, &openfgav1.WriteAuthorizationModelRequest{
+		StoreId:         storeID,
+		TypeDefinitions: model.GetTypeDefinitions(),
+		SchemaVersion:   model.GetSchemaVersion(),
+	})
+	require.NoError(t, err)
+
+	tk := tuple.NewTupleKey("doc:1", "viewer", "user:anne")
+	err = ds.Write(ctx, storeID, nil, []*openfgav1.TupleKey{tk})
+	require.NoError(t, err)
+
+	listObjectsResp, err := s.ListObjects(ctx, &openfgav1.ListObjectsRequest{
+		StoreId:              storeID,
+		AuthorizationModelId: writeModelResp.GetAuthorizationModelId(),
+		Type:                 "doc",
+		Relation:             "reader",
+		User:                 "user:anne",
+	})
+	require.NoError(t, err)
+	require.Equal(t, []string{"doc:1"}, listObjectsResp.GetObjects())
+
+	check := func(relation string) (*openfgav1.CheckResponse, error) {
+		return s.Check(ctx, &openfgav1.CheckRequest{
+			StoreId:              storeID,
+			AuthorizationModelId: writeModelResp.GetAuthorizationModelId(),
+			TupleKey:             tuple.NewCheckRequestTupleKey("doc:1", relation, "user:anne"),
+		})
+	}
+
+	resp, err := check("viewer")
+	require.NoError(t, err)
+	require.True(t, resp.GetAllowed())
+
+	// deleting the tuple behind the back of the server leaves the cached result of the current name in place,
+	// which the Check of the alias can only observe if it shares the cache entry
+	err = ds.Write(ctx, storeID, []*openfgav1.TupleKeyWithoutCondition{tuple.TupleKeyToTupleKeyWithoutCondition(tk)}, nil)
+	require.NoError(t, err)
+
+	resp, err = check("reader")
+	require.NoError(t, err)
+	require.True(t, resp.GetAllowed())
+
+	_, err = check("editor")
+	require.Error(t, err)
+	require.Equal(t, codes.Code(openfgav1.ErrorCode_validation_error), status.Code(err))
+}
+
+func TestRelationAliasesValidation(t *testing.T) {
+	aliases := make(map[string]string, MaxRelationAliasesPerStore+1)
+	for i := 0; i <= MaxRelationAliasesPerStore; i++ {
+		aliases[fmt.Sprintf("doc#reader%d", i)] = "viewer"
+	}
+
+	_, err := NewServerWithOpts(WithDatastore(memory.New()), WithRelationAliases("store", aliases))
+	require.ErrorContains(t, err, "more than the maximum")
+
+	_, err = NewServerWithOpts(WithDatastore(memory.New()), WithRelationAliases("store", map[string]string{"reader": "viewer"}))
+	require.ErrorContains(t, err, "must be an 'objectType#relation' pair")
+}
+
+func TestCheckWithModelDelta(t *testing.T) {
+	t.Cleanup(func() {
+		goleak.VerifyNone(t)
+	})
+
+	ctx := context.Background()
+	s := MustNewServerWithOpts(WithDatastore(memory.New()))
+	t.Cleanup(s.Close)
+
+	createStoreResp, err := s.CreateStore(ctx, &openfgav1.CreateStoreRequest{Name: "model-delta"})
+	require.NoError(t, err)
+	storeID := createStoreResp.GetId()
+
+	model := parser.MustTransformDSLToProto(`
+		model
+			schema 1.1
+		type user
+		type doc
+			relations
+				define editor: [user]
+				define viewer: [user]`)
+	writeModelResp, err := s.WriteAuthorizationModel(ctx, &openfgav1.WriteAuthorizationModelRequest{
+		StoreId:         storeID,
+		TypeDefinitions: model.GetTypeDefinitions(),
+		SchemaVersion:   model.GetSchemaVersion(),
+	})
+	require.NoError(t, err)
+
+	_, err = s.Write(ctx, &openfgav1.WriteRequest{
+		StoreId: storeID,
+		Writes: &openfgav1.WriteRequestWrites{
+			TupleKeys: []*openfgav1.TupleKey{tuple.NewTupleKey("doc:1", "editor", "user:anne")},
+		},
+	})
+	require.NoError(t, err)
+
+	checkReq := &openfgav1.CheckRequest{
+		StoreId:              storeID,
+		AuthorizationModelId: writeModelResp.GetAuthorizationModelId(),
+		TupleKey:             tuple.NewCheckRequestTupleKey("doc:1", "viewer", "user:anne"),
+	}
+
+	checkResp, err := s.Check(ctx, checkReq)
+	require.NoError(t, err)
+	require.False(t, checkResp.GetAllowed())
+
+	deltaModel := parser.MustTransformDSLToProto(`
+		model
+			schema 1.1
+		type user
+		type doc
+			relations
+				define editor: [user]
+				define viewer: [user] or editor`)
+	delta := &typesystem.ModelDelta{}
+	for _, td := range deltaModel.GetTypeDefinitions() {
+		if td.GetType() == "doc" {
+			delta.TypeDefinitions = append(delta.TypeDefinitions, &openfgav1.TypeDefinition{
+				Type:      td.GetType(),
+				Relations: map[string]*openfgav1.Userset{"viewer": td.GetRelations()["viewer"]},
+				Metadata: &openfgav1.Metadata{Relations: map[string]*openfgav1.RelationMetadata{
+					"viewer": td.GetMetadata().GetRelations()["viewer"],
+				}},
+			})
+		}
+	}
+
+	t.Run("delta_adding_a_relation_allows", func(t *testing.T) {
+		checkResp, err := s.CheckWithModelDelta(ctx, checkReq, delta)
+		require.NoError(t, err)
+		require.True(t, checkResp.GetAllowed())
+	})
+
+	t.Run("base_model_is_unchanged", func(t *testing.T) {
+		checkResp, err := s.Check(ctx, checkReq)
+		require.NoError(t, err)
+		require.False(t, checkResp.GetAllowed())
+
+		modelsResp, err := s.ReadAuthorizationModels(ctx, &openfgav1.ReadAuthorizationModelsRequest{StoreId: storeID})
+		require.NoError(t, err)
+		require.Len(t, modelsResp.GetAuthorizationModels(), 1)
+	})
+
+	t.Run("invalid_merged_model", func(t *testing.T) {
+		_, err := s.CheckWithModelDelta(ctx, checkReq, &typesystem.ModelDelta{
+			TypeDefinitions: []*openfgav1.TypeDefinition{{
+				Type: "doc",
+				Relations: map[string]*openfgav1.Userset{
+					"viewer": typesystem.ComputedUserset("undefined"),
+				},
+			}},
+		})
+		require.Error(t, err)
+		require.Equal(t, codes.Code(openfgav1.ErrorCode_invalid_authorization_model), status.Code(err))
+	})
+}
+
+func TestCheckWithTrace(t *testing.T) {
+	t.Cleanup(func() {
+		goleak.VerifyNone(t)
+	})
+
+	ctx := context.Background()
+	s := MustNewServerWithOpts(WithDatastore(memory.New()))
+	t.Cleanup(s.Close)
+
+	createStoreResp, err := s.CreateStore(ctx, &openfgav1.CreateStoreRequest{Name: "trace"})
+	require.NoError(t, err)
+	storeID := createStoreResp.GetId()
+
+	model := parser.MustTransformDSLToProto(`
+		model
+			schema 1.1
+		type user
+		type doc
+			relations
+				define editor: [user]
+				define viewer: [user] or editor`)
+	_, err = s.WriteAuthorizationModel(ctx, &openfgav1.WriteAuthorizationModelRequest{
+		StoreId:         storeID,
+		TypeDefinitions: model.GetTypeDefinitions(),
+		SchemaVersion:   model.GetSchemaVersion(),
+	})
+	require.NoError(t, err)
+
+	checkResp, trace, err := s.CheckWithTrace(ctx, &openfgav1.CheckRequest{
+		StoreId:  storeID,
+		TupleKey: tuple.NewCheckRequestTupleKey("doc:1", "viewer", "user:anne"),
+	})
+	require.NoError(t, err)
+	require.False(t, checkResp.GetAllowed())
+
+	require.Equal(t, "doc:1#viewer@user:anne", trace.TupleKey)
+	require.Equal(t, string(graph.CheckTraceUnion), trace.Operation)
+	require.False(t, trace.Allowed)
+	require.True(t, trace.Contributed)
+	require.Len(t, trace.Children, 2)
+
+	direct, computed := trace.Children[0], trace.Children[1]
+	require.Equal(t, string(graph.CheckTraceThis), direct.Operation)
+	require.Equal(t, []string{"ReadUserTuple doc:1#viewer@user:anne"}, direct.Reads)
+	require.Equal(t, string(graph.CheckTraceComputed), computed.Operation)
+	require.Len(t, computed.Children, 1)
+	require.Equal(t, "doc:1#editor@user:anne", computed.Children[0].TupleKey)
+	require.Equal(t, []string{"ReadUserTuple doc:1#editor@user:anne"}, computed.Children[0].Reads)
+}
+
+func TestCheckWithExcludedRelations(t *testing.T) {
+	t.Cleanup(func() {
+		goleak.VerifyNone(t)
+	})
+
+	ctx := context.Background()
+	s := MustNewServerWithOpts(WithDatastore(memory.New()), WithCheckQueryCacheEnabled(true))
+	t.Cleanup(s.Close)
+
+	createStoreResp, err := s.CreateStore(ctx, &openfgav1.CreateStoreRequest{Name: "excluded-relations"})
+	require.NoError(t, err)
+	storeID := createStoreResp.GetId()
+
+	model := parser.MustTransformDSLToProto(`
+		model
+			schema 1.1
+		type user
+		type doc
+			relations
+				define editor: [user]
+				define viewer: [user] or editor`)
+	_, err = s.WriteAuthorizationModel(ctx, &openfgav1.WriteAuthorizationModelRequest{
+		StoreId:         storeID,
+		TypeDefinitions: model.GetTypeDefinitions(),
+		SchemaVersion:   model.GetSchemaVersion(),
+	})
+	require.NoError(t, err)
+
+	_, err = s.Write(ctx, &openfgav1.WriteRequest{
+		StoreId: storeID,
+		Writes: &openfgav1.WriteRequestWrites{
+			TupleKeys: []*openfgav1.TupleKey{tuple.NewTupleKey("doc:1", "editor", "user:anne")},
+		},
+	})
+	require.NoError(t, err)
+
+	checkReq := &openfgav1.CheckRequest{
+		StoreId:  storeID,
+		TupleKey: tuple.NewCheckRequestTupleKey("doc:1", "viewer", "user:anne"),
+	}
+
+	t.Run("excluding_the_granting_relation_denies", func(t *testing.T) {
+		checkResp, err := s.CheckWithExcludedRelations(ctx, checkReq, []string{"doc#editor"})
+		require.NoError(t, err)
+		require.False(t, checkResp.GetAllowed())
+
+		// the simulated outcome isn't shared with the Checks without exclusions
+		checkResp, err = s.Check(ctx, checkReq)
+		require.NoError(t, err)
+		require.True(t, checkResp.GetAllowed())
+	})
+
+	t.Run("undefined_relation", func(t *testing.T) {
+		_, err := s.CheckWithExcludedRelations(ctx, checkReq, []string{"doc#owner"})
+		require.Equal(t, codes.Code(openfgav1.ErrorCode_validation_error), status.Code(err))
+	})
+
+	t.Run("too_many_relations", func(t *testing.T) {
+		excluded := make([]string, commands.MaxExcludedRelations+1)
+		for i := range excluded {
+			excluded[i] = "doc#editor"
+		}
+		_, err := s.CheckWithExcludedRelations(ctx, checkReq, excluded)
+		require.Equal(t, codes.Code(openfgav1.ErrorCode_validation_error), status.Code(err))
+	})
+}
+
+func TestCheckWithAssumedFacts(t *testing.T) {
+	t.Cleanup(func() {
+		goleak.VerifyNone(t)
+	})
+
+	ctx := context.Background()
+	s := MustNewServerWithOpts(WithDatastore(memory.New()), WithCheckQueryCacheEnabled(true))
+	t.Cleanup(s.Close)
+
+	createStoreResp, err := s.CreateStore(ctx, &openfgav1.CreateStoreRequest{Name: "assumed-facts"})
+	require.NoError(t, err)
+	storeID := createStoreResp.GetId()
+
+	model := parser.MustTransformDSLToProto(`
+		model
+			schema 1.1
+		type user
+		type group
+			relations
+				define member: [user]
+		type doc
+			relations
+				define viewer: [user, group#member]`)
+	_, err = s.WriteAuthorizationModel(ctx, &openfgav1.WriteAuthorizationModelRequest{
+		StoreId:         storeID,
+		TypeDefinitions: model.GetTypeDefinitions(),
+		SchemaVersion:   model.GetSchemaVersion(),
+	})
+	require.NoError(t, err)
+
+	_, err = s.Write(ctx, &openfgav1.WriteRequest{
+		StoreId: storeID,
+		Writes: &openfgav1.WriteRequestWrites{
+			TupleKeys: []*openfgav1.TupleKey{tuple.NewTupleKey("doc:1", "viewer", "group:eng#member")},
+		},
+	})
+	require.NoError(t, err)
+
+	checkReq := &openfgav1.CheckRequest{
+		StoreId:  storeID,
+		TupleKey: tuple.NewCheckRequestTupleKey("doc:1", "viewer", "user:anne"),
+	}
+	membership := tuple.NewTupleKey("group:eng", "member", "user:anne")
+
+	t.Run("assumed_fact_allows", func(t *testing.T) {
+		checkResp, err := s.Check(ctx, checkReq)
+		require.NoError(t, err)
+		require.False(t, checkResp.GetAllowed())
+
+		checkResp, err = s.CheckWithAssumedFacts(ctx, checkReq, []*openfgav1.TupleKey{membership})
+		require.NoError(t, err)
+		require.True(t, checkResp.GetAllowed())
+
+		// the simulated outcome isn't shared with the Checks without assumed facts
+		checkResp, err = s.Check(ctx, checkReq)
+		require.NoError(t, err)
+		require.False(t, checkResp.GetAllowed())
+	})
+
+	t.Run("conditioned_fact", func(t *testing.T) {
+		_, err := s.CheckWithAssumedFacts(ctx, checkReq, []*openfgav1.TupleKey{
+			tuple.NewTupleKeyWithCondition("group:eng", "member", "user:anne", "cond", nil),
+		})
+		require.Equal(t, codes.Code(openfgav1.ErrorCode_invalid_tuple), status.Code(err))
+	})
+
+	t.Run("too_many_facts", func(t *testing.T) {
+		facts := make([]*openfgav1.TupleKey, commands.MaxAssumedFacts+1)
+		for i := range facts {
+			facts[i] = membership
+		}
+		_, err := s.CheckWithAssumedFacts(ctx, checkReq, facts)
+		require.Equal(t, codes.Code(openfgav1.ErrorCode_invalid_tuple), status.Code(err))
+	})
+}
+
+func TestCheckWithScore(t *testing.T) {
+	t.Cleanup(func() {
+		goleak.VerifyNone(t)
+	})
+
+	ctx := context.Background()
+	s := MustNewServerWithOpts(WithDatastore(memory.New()), WithCheckQueryCacheEnabled(true))
+	t.Cleanup(s.Close)
+
+	createStoreResp, err := s.CreateStore(ctx, &openfgav1.CreateStoreRequest{Name: "score"})
+	require.NoError(t, err)
+	storeID := createStoreResp.GetId()
+
+	model := parser.MustTransformDSLToProto(`
+		model
+			schema 1.1
+		type user
+		type group
+			relations
+				define member: [user]
+		type doc
+			relations
+				define viewer: [user, user:*, group#member]`)
+	_, err = s.WriteAuthorizationModel(ctx, &openfgav1.WriteAuthorizationModelRequest{
+		StoreId:         storeID,
+		TypeDefinitions: model.GetTypeDefinitions(),
+		SchemaVersion:   model.GetSchemaVersion(),
+	})
+	require.NoError(t, err)
+
+	_, err = s.Write(ctx, &openfgav1.WriteRequest{
+		StoreId: storeID,
+		Writes: &openfgav1.WriteRequestWrites{
+			TupleKeys: []*openfgav1.TupleKey{
+				tuple.NewTupleKey("doc:1", "viewer", "user:anne"),
+				tuple.NewTupleKey("doc:1", "viewer", "group:eng#member"),
+				tuple.NewTupleKey("group:eng", "member", "user:bob"),
+				tuple.NewTupleKey("doc:2", "viewer", "user:*"),
+			},
+		},
+	})
+	require.NoError(t, err)
+
+	checkWithScore := func(t *testing.T, object, user string, scoring CheckScoring) float64 {
+		checkResp, score, err := s.CheckWithScore(ctx, &openfgav1.CheckRequest{
+			StoreId:  storeID,
+			TupleKey: tuple.NewCheckRequestTupleKey(object, "viewer", user),
+		}, scoring)
+		require.NoError(t, err)
+		require.Equal(t, score > 0, checkResp.GetAllowed())
+		return score
+	}
+
+	t.Run("direct_grant_scores_higher_than_wildcard_grant", func(t *testing.T) {
+		direct := checkWithScore(t, "doc:1", "user:anne", DefaultCheckScoring)
+		wildcard := checkWithScore(t, "doc:2", "user:anne", DefaultCheckScoring)
+		require.InDelta(t, DefaultCheckScoring.DirectScore, direct, 1e-9)
+		require.InDelta(t, DefaultCheckScoring.WildcardScore, wildcard, 1e-9)
+		require.Greater(t, direct, wildcard)
+	})
+
+	t.Run("hops_decay_the_score_with_the_rubric", func(t *testing.T) {
+		scoring := CheckScoring{DirectScore: 1, WildcardScore: 0.1, HopDecay: 0.8}
+		require.InDelta(t, 0.8, checkWithScore(t, "doc:1", "user:bob", scoring), 1e-9)
+	})
+
+	t.Run("denied_checks_score_zero", func(t *testing.T) {
+		require.Zero(t, checkWithScore(t, "doc:1", "user:carl", DefaultCheckScoring))
+	})
+
+	t.Run("invalid_rubric", func(t *testing.T) {
+		_, _, err := s.CheckWithScore(ctx, &openfgav1.CheckRequest{
+			StoreId:  storeID,
+			TupleKey: tuple.NewCheckRequestTupleKey("doc:1", "viewer", "user:anne"),
+		}, CheckScoring{DirectScore: 1, HopDecay: 2})
+		require.Equal(t, codes.Code(openfgav1.ErrorCode_validation_error), status.Code(err))
+	})
+}
+
+func TestObserveRetryableError(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("throttled", func(t *testing.T) {
+		transport := &trailerRecordingTransport{trailers: map[string]string{}}
+		s := &Server{transport: transport}
+
+		s.observeRetryableError(ctx, "check", &serverErrors.ThrottledError{Limit: commands.DatastoreThrottlingLimit, RetryAfter: 1500 * time.Millisecond})
+		require.Equal(t, map[string]string{serverErrors.RetryAfterKey: "2"}, transport.trailers)
+	})
+
+	t.Run("datastore_unavailable", func(t *testing.T) {
+		transport := &trailerRecordingTransport{trailers: map[string]string{}}
+		s := &Server{transport: transport}
+
+		s.observeRetryableError(ctx, "check", &serverErrors.DatastoreUnavailableError{RetryAfter: 3 * time.Second})
+		require.Equal(t, map[string]string{serverErrors.RetryAfterKey: "3"}, transport.trailers)
+	})
+
+	t.Run("not_retryable", func(t *testing.T) {
+		transport := &trailerRecordingTransport{trailers: map[string]string{}}
+		s := &Server{transport: transport}
+
+		s.observeRetryableError(ctx, "check", serverErrors.ErrRequestDeadlineExceeded)
+		require.Empty(t, transport.trailers)
+	})
+
+	t.Run("transport_without_trailers", func(t *testing.T) {
+		s := &Server{transport: gateway.NewNoopTransport()}
+
+		require.NotPanics(t, func() {
+			s.observeRetryableError(ctx, "check", &serverErrors.ThrottledError{RetryAfter: time.Second})
+		})
+	})
+}
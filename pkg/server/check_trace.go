@@ -0,0 +1,46 @@
+package server
+
+import (
+	"github.com/openfga/openfga/internal/graph"
+)
+
+// CheckTrace is a node of the resolution tree of a Check, see CheckWithTrace. Every rewrite that is evaluated
+// is a node, whose children are the operands of a set operation, the rewritten relation of a computed userset,
+// or the subproblems dispatched by a direct relation or a tuple to userset rewrite.
+type CheckTrace struct {
+	// TupleKey is the 'object#relation@user' subproblem that the node evaluates.
+	TupleKey string
+	// Operation is the rewrite operation evaluated, e.g. 'union', empty for a subproblem answered without
+	// evaluating a rewrite, e.g. from the check cache.
+	Operation string
+	// Reads are the datastore reads made to evaluate the node, e.g. 'ReadUserTuple document:1#viewer@user:anne'.
+	Reads []string
+	// Allowed is the outcome of the node.
+	Allowed bool
+	// Contributed reports whether the outcome of the node determined the outcome of the Check.
+	Contributed bool
+	// FromCache reports whether the outcome was served from a cache rather than resolved.
+	FromCache bool
+	// Children are the nodes evaluated to resolve this one.
+	Children []*CheckTrace
+}
+
+// newCheckTrace returns the CheckTrace of the trace of a resolution, or nil if the Check wasn't traced.
+func newCheckTrace(node *graph.CheckTrace) *CheckTrace {
+	if node == nil {
+		return nil
+	}
+
+	trace := &CheckTrace{
+		TupleKey:    node.TupleKey,
+		Operation:   string(node.Operation),
+		Reads:       node.Reads,
+		Allowed:     node.Allowed,
+		Contributed: node.Contributed,
+		FromCache:   node.FromCache,
+	}
+	for _, child := range node.Children {
+		trace.Children = append(trace.Children, newCheckTrace(child))
+	}
+	return trace
+}
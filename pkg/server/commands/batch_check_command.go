@@ -2,6 +2,8 @@ package commands
 
 import (
 	"context"
+	"errors"
+	"slices"
 	"strconv"
 	"sync"
 	"sync/atomic"
@@ -9,6 +11,7 @@ import (
 
 	"github.com/cespare/xxhash/v2"
 	"go.uber.org/zap"
+	"google.golang.org/protobuf/types/known/structpb"
 
 	openfgav1 "github.com/openfga/api/proto/openfga/v1"
 
@@ -19,6 +22,7 @@ import (
 	"github.com/openfga/openfga/pkg/logger"
 	"github.com/openfga/openfga/pkg/server/config"
 	"github.com/openfga/openfga/pkg/storage"
+	"github.com/openfga/openfga/pkg/tuple"
 	"github.com/openfga/openfga/pkg/typesystem"
 )
 
@@ -29,10 +33,13 @@ type BatchCheckQuery struct {
 	datastore                  storage.RelationshipTupleReader
 	logger                     logger.Logger
 	maxChecksAllowed           uint32
+	maxStreamedChecksAllowed   uint32
 	maxConcurrentChecks        uint32
 	typesys                    *typesystem.TypeSystem
 	datastoreThrottleThreshold int
 	datastoreThrottleDuration  time.Duration
+	dispatchThrottleFrequency  time.Duration
+	wildcardPolicy             tuple.WildcardPolicy
 }
 
 type BatchCheckCommandParams struct {
@@ -52,6 +59,9 @@ type BatchCheckMetadata struct {
 	DispatchCount       uint32
 	DatastoreQueryCount uint32
 	DuplicateCheckCount int
+	// RetryAfter is the longest delay suggested by the checks of the batch that failed with a ThrottledError,
+	// or zero if none did.
+	RetryAfter time.Duration
 }
 
 type BatchCheckValidationError struct {
@@ -97,6 +107,14 @@ func WithBatchCheckMaxChecksPerBatch(maxChecks uint32) BatchCheckQueryOption {
 	}
 }
 
+// WithBatchCheckMaxChecksPerStreamedBatch sets the maximum number of checks of a batch run by
+// ExecuteStreamed.
+func WithBatchCheckMaxChecksPerStreamedBatch(maxChecks uint32) BatchCheckQueryOption {
+	return func(bq *BatchCheckQuery) {
+		bq.maxStreamedChecksAllowed = maxChecks
+	}
+}
+
 func WithBatchCheckDatastoreThrottler(threshold int, duration time.Duration) BatchCheckQueryOption {
 	return func(bq *BatchCheckQuery) {
 		bq.datastoreThrottleThreshold = threshold
@@ -104,18 +122,34 @@ func WithBatchCheckDatastoreThrottler(threshold int, duration time.Duration) Bat
 	}
 }
 
+// WithBatchCheckDispatchThrottlingFrequency see WithCheckDispatchThrottlingFrequency.
+func WithBatchCheckDispatchThrottlingFrequency(frequency time.Duration) BatchCheckQueryOption {
+	return func(bq *BatchCheckQuery) {
+		bq.dispatchThrottleFrequency = frequency
+	}
+}
+
+// WithBatchCheckWildcardPolicy sets the policy that determines the users that typed wildcards apply to.
+func WithBatchCheckWildcardPolicy(policy tuple.WildcardPolicy) BatchCheckQueryOption {
+	return func(bq *BatchCheckQuery) {
+		bq.wildcardPolicy = policy
+	}
+}
+
 func NewBatchCheckCommand(datastore storage.RelationshipTupleReader, checkResolver graph.CheckResolver, typesys *typesystem.TypeSystem, opts ...BatchCheckQueryOption) *BatchCheckQuery {
 	cmd := &BatchCheckQuery{
-		logger:              logger.NewNoopLogger(),
-		datastore:           datastore,
-		checkResolver:       checkResolver,
-		typesys:             typesys,
-		maxChecksAllowed:    config.DefaultMaxChecksPerBatchCheck,
-		maxConcurrentChecks: config.DefaultMaxConcurrentChecksPerBatchCheck,
-		cacheSettings:       config.NewDefaultCacheSettings(),
+		logger:                   logger.NewNoopLogger(),
+		datastore:                datastore,
+		checkResolver:            checkResolver,
+		typesys:                  typesys,
+		maxChecksAllowed:         config.DefaultMaxChecksPerBatchCheck,
+		maxStreamedChecksAllowed: config.DefaultMaxChecksPerStreamedBatchCheck,
+		maxConcurrentChecks:      config.DefaultMaxConcurrentChecksPerBatchCheck,
+		cacheSettings:            config.NewDefaultCacheSettings(),
 		sharedCheckResources: &shared.SharedDatastoreResources{
 			CacheController: cachecontroller.NewNoopCacheController(),
 		},
+		dispatchThrottleFrequency: config.DefaultCheckDispatchThrottlingFrequency,
 	}
 
 	for _, opt := range opts {
@@ -125,30 +159,97 @@ func NewBatchCheckCommand(datastore storage.RelationshipTupleReader, checkResolv
 }
 
 func (bq *BatchCheckQuery) Execute(ctx context.Context, params *BatchCheckCommandParams) (map[CorrelationID]*BatchCheckOutcome, *BatchCheckMetadata, error) {
-	if len(params.Checks) > int(bq.maxChecksAllowed) {
-		return nil, nil, &BatchCheckValidationError{
-			Message: "batchCheck received " + strconv.Itoa(len(params.Checks)) + " checks, the maximum allowed is " + strconv.Itoa(int(bq.maxChecksAllowed)),
+	cacheKeyMap, err := bq.deduplicate(params, bq.maxChecksAllowed)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var resultMap = new(sync.Map)
+	metadata, err := bq.run(ctx, params, cacheKeyMap, func(key CacheKey, _ *checkAndCorrelationIDs, outcome *BatchCheckOutcome) error {
+		resultMap.Store(key, outcome)
+		return nil
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	results := map[CorrelationID]*BatchCheckOutcome{}
+
+	// Each cacheKey can have > 1 associated CorrelationID
+	for cacheKey, checkItem := range cacheKeyMap {
+		res, _ := resultMap.Load(cacheKey)
+		outcome := res.(*BatchCheckOutcome)
+
+		for _, id := range checkItem.CorrelationIDs {
+			// map all associated CorrelationIDs to this outcome
+			results[id] = outcome
+		}
+	}
+
+	return results, metadata, nil
+}
+
+// ExecuteStreamed runs the checks like Execute, but sends the outcome of each check as soon as it's resolved
+// instead of returning them all at once, so that the batches can be larger than for Execute, up to the
+// limit set by WithBatchCheckMaxChecksPerStreamedBatch. The outcome of a check that failed is sent like the
+// others, with its error. send is never called concurrently, and if it returns an error, the checks that
+// haven't been resolved yet are abandoned and the error is returned.
+func (bq *BatchCheckQuery) ExecuteStreamed(
+	ctx context.Context,
+	params *BatchCheckCommandParams,
+	send func(CorrelationID, *BatchCheckOutcome) error,
+) (*BatchCheckMetadata, error) {
+	cacheKeyMap, err := bq.deduplicate(params, bq.maxStreamedChecksAllowed)
+	if err != nil {
+		return nil, err
+	}
+
+	var (
+		mu      sync.Mutex
+		sendErr error // GUARDED_BY(mu). Once set, the abandoned checks aren't sent.
+	)
+	return bq.run(ctx, params, cacheKeyMap, func(_ CacheKey, item *checkAndCorrelationIDs, outcome *BatchCheckOutcome) error {
+		mu.Lock()
+		defer mu.Unlock()
+
+		if sendErr != nil {
+			return sendErr
+		}
+		for _, id := range item.CorrelationIDs {
+			if sendErr = send(id, outcome); sendErr != nil {
+				return sendErr
+			}
+		}
+		return nil
+	})
+}
+
+// deduplicate validates the checks and groups them by their cache key, so that each distinct check is
+// resolved once and its outcome is mapped to all the associated CorrelationIDs.
+func (bq *BatchCheckQuery) deduplicate(params *BatchCheckCommandParams, maxChecksAllowed uint32) (map[CacheKey]*checkAndCorrelationIDs, error) {
+	if len(params.Checks) > int(maxChecksAllowed) {
+		return nil, &BatchCheckValidationError{
+			Message: "batchCheck received " + strconv.Itoa(len(params.Checks)) + " checks, the maximum allowed is " + strconv.Itoa(int(maxChecksAllowed)),
 		}
 	}
 
 	if len(params.Checks) == 0 {
-		return nil, nil, &BatchCheckValidationError{
+		return nil, &BatchCheckValidationError{
 			Message: "batch check requires at least one check to evaluate, no checks were received",
 		}
 	}
 
 	if err := validateCorrelationIDs(params.Checks); err != nil {
-		return nil, nil, err
+		return nil, err
 	}
 
-	// Before processing the batch, deduplicate the checks based on their unique cache key
-	// After all routines have finished, we will map each individual check response to all associated CorrelationIDs
 	cacheKeyMap := make(map[CacheKey]*checkAndCorrelationIDs)
+	keyBuilder := &checkCacheKeyBuilder{storeID: params.StoreID, authModelID: bq.typesys.GetAuthorizationModelID()}
 	for _, check := range params.Checks {
-		key, err := generateCacheKeyFromCheck(check, params.StoreID, bq.typesys.GetAuthorizationModelID())
+		key, err := keyBuilder.build(check)
 		if err != nil {
 			bq.logger.Error("batch check cache key computation failed with error", zap.Error(err))
-			return nil, nil, err
+			return nil, err
 		}
 
 		if item, ok := cacheKeyMap[key]; ok {
@@ -161,10 +262,21 @@ func (bq *BatchCheckQuery) Execute(ctx context.Context, params *BatchCheckComman
 		}
 	}
 
-	var resultMap = new(sync.Map)
+	return cacheKeyMap, nil
+}
+
+// run resolves each distinct check with bounded concurrency and passes its outcome to onOutcome. If
+// onOutcome returns an error, the remaining checks are abandoned and the error is returned.
+func (bq *BatchCheckQuery) run(
+	ctx context.Context,
+	params *BatchCheckCommandParams,
+	cacheKeyMap map[CacheKey]*checkAndCorrelationIDs,
+	onOutcome func(CacheKey, *checkAndCorrelationIDs, *BatchCheckOutcome) error,
+) (*BatchCheckMetadata, error) {
 	var totalQueryCount atomic.Uint32
 	var totalDispatchCount atomic.Uint32
 	var totalThrottleCount atomic.Uint32
+	var maxRetryAfter atomic.Int64
 
 	pool := concurrency.NewPool(ctx, int(bq.maxConcurrentChecks))
 	for key, item := range cacheKeyMap {
@@ -172,10 +284,9 @@ func (bq *BatchCheckQuery) Execute(ctx context.Context, params *BatchCheckComman
 		pool.Go(func(ctx context.Context) error {
 			select {
 			case <-ctx.Done():
-				resultMap.Store(key, &BatchCheckOutcome{
+				return onOutcome(key, item, &BatchCheckOutcome{
 					Err: ctx.Err(),
 				})
-				return nil
 			default:
 			}
 
@@ -186,6 +297,8 @@ func (bq *BatchCheckQuery) Execute(ctx context.Context, params *BatchCheckComman
 				WithCheckCommandLogger(bq.logger),
 				WithCheckCommandCache(bq.sharedCheckResources, bq.cacheSettings),
 				WithCheckDatastoreThrottler(bq.datastoreThrottleThreshold, bq.datastoreThrottleDuration),
+				WithCheckDispatchThrottlingFrequency(bq.dispatchThrottleFrequency),
+				WithCheckCommandWildcardPolicy(bq.wildcardPolicy),
 			)
 
 			checkParams := &CheckCommandParams{
@@ -198,11 +311,6 @@ func (bq *BatchCheckQuery) Execute(ctx context.Context, params *BatchCheckComman
 
 			response, metadata, err := checkQuery.Execute(ctx, checkParams)
 
-			resultMap.Store(key, &BatchCheckOutcome{
-				CheckResponse: response,
-				Err:           err,
-			})
-
 			if metadata != nil {
 				if metadata.WasThrottled.Load() {
 					totalThrottleCount.Add(1)
@@ -212,33 +320,34 @@ func (bq *BatchCheckQuery) Execute(ctx context.Context, params *BatchCheckComman
 
 			totalQueryCount.Add(response.GetResolutionMetadata().DatastoreQueryCount)
 
-			return nil
+			var throttledErr *ThrottledError
+			if errors.As(err, &throttledErr) {
+				for retryAfter := maxRetryAfter.Load(); int64(throttledErr.RetryAfter) > retryAfter; retryAfter = maxRetryAfter.Load() {
+					if maxRetryAfter.CompareAndSwap(retryAfter, int64(throttledErr.RetryAfter)) {
+						break
+					}
+				}
+			}
+
+			return onOutcome(key, item, &BatchCheckOutcome{
+				CheckResponse: response,
+				Err:           err,
+			})
 		})
 	}
 
-	_ = pool.Wait()
-
-	results := map[CorrelationID]*BatchCheckOutcome{}
-
-	// Each cacheKey can have > 1 associated CorrelationID
-	for cacheKey, checkItem := range cacheKeyMap {
-		res, _ := resultMap.Load(cacheKey)
-		outcome := res.(*BatchCheckOutcome)
-
-		for _, id := range checkItem.CorrelationIDs {
-			// map all associated CorrelationIDs to this outcome
-			results[id] = outcome
-		}
+	if err := pool.Wait(); err != nil {
+		return nil, err
 	}
 
-	return results, &BatchCheckMetadata{
+	return &BatchCheckMetadata{
 		ThrottleCount:       totalThrottleCount.Load(),
 		DatastoreQueryCount: totalQueryCount.Load(),
 		DispatchCount:       totalDispatchCount.Load(),
 		DuplicateCheckCount: len(params.Checks) - len(cacheKeyMap),
+		RetryAfter:          time.Duration(maxRetryAfter.Load()),
 	}, nil
 }
-
 func validateCorrelationIDs(checks []*openfgav1.BatchCheckItem) error {
 	seen := map[string]struct{}{}
 
@@ -262,6 +371,108 @@ func validateCorrelationIDs(checks []*openfgav1.BatchCheckItem) error {
 	return nil
 }
 
+// checkCacheKeyBuilder builds the same cache keys as generateCacheKeyFromCheck. The invariant part of the key,
+// which covers the contextual tuples and context, is reused across consecutive checks that share them, so
+// that only the tuple key of each check is hashed on its own.
+type checkCacheKeyBuilder struct {
+	storeID     string
+	authModelID string
+
+	// the contextual tuples and context of the last built invariant
+	contextualTuples *openfgav1.ContextualTupleKeys
+	context          *structpb.Struct
+	invariant        storage.CheckCacheKeyInvariant
+	built            bool
+}
+
+func (b *checkCacheKeyBuilder) build(check *openfgav1.BatchCheckItem) (CacheKey, error) {
+	if !b.built || !contextualTuplesEqual(b.contextualTuples.GetTupleKeys(), check.GetContextualTuples().GetTupleKeys()) || !structsEqual(b.context, check.GetContext()) {
+		invariant, err := storage.NewCheckCacheKeyInvariant(&storage.CheckCacheKeyParams{
+			StoreID:              b.storeID,
+			AuthorizationModelID: b.authModelID,
+			ContextualTuples:     check.GetContextualTuples().GetTupleKeys(),
+			Context:              check.GetContext(),
+		})
+		if err != nil {
+			return "", err
+		}
+
+		b.contextualTuples = check.GetContextualTuples()
+		b.context = check.GetContext()
+		b.invariant = invariant
+		b.built = true
+	}
+
+	tupleKey := check.GetTupleKey()
+	hasher := xxhash.New()
+	err := storage.WriteCheckCacheKeyWithInvariant(hasher, &openfgav1.TupleKey{
+		User:     tupleKey.GetUser(),
+		Relation: tupleKey.GetRelation(),
+		Object:   tupleKey.GetObject(),
+	}, b.invariant)
+	if err != nil {
+		return "", err
+	}
+
+	return CacheKey(strconv.FormatUint(hasher.Sum64(), 10)), nil
+}
+
+// contextualTuplesEqual returns true if both lists hold the same tuples in the same order. It is cheaper
+// than proto.Equal, which matters as it runs for every check of a batch.
+func contextualTuplesEqual(a, b []*openfgav1.TupleKey) bool {
+	return slices.EqualFunc(a, b, func(x, y *openfgav1.TupleKey) bool {
+		return x.GetObject() == y.GetObject() &&
+			x.GetRelation() == y.GetRelation() &&
+			x.GetUser() == y.GetUser() &&
+			(x.GetCondition() == nil) == (y.GetCondition() == nil) &&
+			x.GetCondition().GetName() == y.GetCondition().GetName() &&
+			structsEqual(x.GetCondition().GetContext(), y.GetCondition().GetContext())
+	})
+}
+
+// structsEqual returns true if both structs hold the same fields. A nil struct only equals a nil struct,
+// as the cache key may tell a nil struct from an empty one.
+func structsEqual(a, b *structpb.Struct) bool {
+	if a == b {
+		return true
+	}
+	if a == nil || b == nil || len(a.GetFields()) != len(b.GetFields()) {
+		return false
+	}
+	for name, x := range a.GetFields() {
+		y, ok := b.GetFields()[name]
+		if !ok || !valuesEqual(x, y) {
+			return false
+		}
+	}
+	return true
+}
+
+func valuesEqual(a, b *structpb.Value) bool {
+	switch x := a.GetKind().(type) {
+	case *structpb.Value_NullValue:
+		_, ok := b.GetKind().(*structpb.Value_NullValue)
+		return ok
+	case *structpb.Value_NumberValue:
+		y, ok := b.GetKind().(*structpb.Value_NumberValue)
+		return ok && x.NumberValue == y.NumberValue
+	case *structpb.Value_StringValue:
+		y, ok := b.GetKind().(*structpb.Value_StringValue)
+		return ok && x.StringValue == y.StringValue
+	case *structpb.Value_BoolValue:
+		y, ok := b.GetKind().(*structpb.Value_BoolValue)
+		return ok && x.BoolValue == y.BoolValue
+	case *structpb.Value_StructValue:
+		y, ok := b.GetKind().(*structpb.Value_StructValue)
+		return ok && structsEqual(x.StructValue, y.StructValue)
+	case *structpb.Value_ListValue:
+		y, ok := b.GetKind().(*structpb.Value_ListValue)
+		return ok && slices.EqualFunc(x.ListValue.GetValues(), y.ListValue.GetValues(), valuesEqual)
+	default:
+		return false
+	}
+}
+
 func generateCacheKeyFromCheck(check *openfgav1.BatchCheckItem, storeID string, authModelID string) (CacheKey, error) {
 	tupleKey := check.GetTupleKey()
 	cacheKeyParams := &storage.CheckCacheKeyParams{
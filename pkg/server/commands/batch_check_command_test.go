@@ -9,6 +9,8 @@ import (
 	"github.com/stretchr/testify/require"
 	"go.uber.org/goleak"
 	"go.uber.org/mock/gomock"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/structpb"
 
 	openfgav1 "github.com/openfga/api/proto/openfga/v1"
 
@@ -17,6 +19,7 @@ import (
 	"github.com/openfga/openfga/pkg/server/config"
 	"github.com/openfga/openfga/pkg/storage/memory"
 	"github.com/openfga/openfga/pkg/testutils"
+	"github.com/openfga/openfga/pkg/tuple"
 	"github.com/openfga/openfga/pkg/typesystem"
 )
 
@@ -350,6 +353,195 @@ func TestBatchCheckCommand(t *testing.T) {
 		require.NoError(t, err)
 		require.Len(t, result, 1)
 	})
+
+	t.Run("streams_an_outcome_for_each_correlation_id", func(t *testing.T) {
+		mockCheckResolver := graph.NewMockCheckResolver(mockController)
+		cmd := NewBatchCheckCommand(ds, mockCheckResolver, ts, WithBatchCheckMaxChecksPerStreamedBatch(3))
+
+		justinTuple := &openfgav1.CheckRequestTupleKey{Object: "doc:doc1", Relation: "viewer", User: "user:justin"}
+		ewanTuple := &openfgav1.CheckRequestTupleKey{Object: "doc:doc1", Relation: "viewer", User: "user:ewan"}
+
+		mockCheckResolver.EXPECT().ResolveCheck(gomock.Any(), gomock.Any()).
+			DoAndReturn(func(_ context.Context, req *graph.ResolveCheckRequest) (*graph.ResolveCheckResponse, error) {
+				if req.GetTupleKey().GetUser() == "user:ewan" {
+					return nil, fmt.Errorf("some error")
+				}
+				return &graph.ResolveCheckResponse{Allowed: true}, nil
+			}).
+			Times(2)
+
+		params := &BatchCheckCommandParams{
+			AuthorizationModelID: ts.GetAuthorizationModelID(),
+			Checks: []*openfgav1.BatchCheckItem{
+				{TupleKey: justinTuple, CorrelationId: "qwe"},
+				{TupleKey: justinTuple, CorrelationId: "rty"},
+				{TupleKey: ewanTuple, CorrelationId: "asd"},
+			},
+			StoreID: ulid.Make().String(),
+		}
+
+		outcomes := map[CorrelationID]*BatchCheckOutcome{}
+		meta, err := cmd.ExecuteStreamed(context.Background(), params, func(id CorrelationID, outcome *BatchCheckOutcome) error {
+			outcomes[id] = outcome
+			return nil
+		})
+		require.NoError(t, err)
+		require.Equal(t, 1, meta.DuplicateCheckCount)
+
+		require.Len(t, outcomes, 3)
+		require.True(t, outcomes["qwe"].CheckResponse.GetAllowed())
+		require.True(t, outcomes["rty"].CheckResponse.GetAllowed())
+		require.Error(t, outcomes["asd"].Err)
+	})
+
+	t.Run("stops_streaming_once_send_fails", func(t *testing.T) {
+		mockCheckResolver := graph.NewMockCheckResolver(mockController)
+		cmd := NewBatchCheckCommand(ds, mockCheckResolver, ts, WithBatchCheckMaxConcurrentChecks(1))
+
+		mockCheckResolver.EXPECT().ResolveCheck(gomock.Any(), gomock.Any()).
+			DoAndReturn(func(_ any, _ any) (*graph.ResolveCheckResponse, error) {
+				return &graph.ResolveCheckResponse{}, nil
+			}).
+			AnyTimes()
+
+		numChecks := 10
+		checks := make([]*openfgav1.BatchCheckItem, numChecks)
+		for i := 0; i < numChecks; i++ {
+			checks[i] = &openfgav1.BatchCheckItem{
+				TupleKey:      &openfgav1.CheckRequestTupleKey{Object: fmt.Sprintf("doc:doc%d", i), Relation: "viewer", User: "user:justin"},
+				CorrelationId: fmt.Sprintf("fakeid%d", i),
+			}
+		}
+
+		sendErr := fmt.Errorf("stream closed")
+		var sent int
+		_, err := cmd.ExecuteStreamed(context.Background(), &BatchCheckCommandParams{
+			AuthorizationModelID: ts.GetAuthorizationModelID(),
+			Checks:               checks,
+			StoreID:              ulid.Make().String(),
+		}, func(CorrelationID, *BatchCheckOutcome) error {
+			sent++
+			return sendErr
+		})
+		require.ErrorIs(t, err, sendErr)
+		require.Equal(t, 1, sent)
+	})
+
+	t.Run("streamed_batch_size_is_limited", func(t *testing.T) {
+		cmd := NewBatchCheckCommand(ds, graph.NewMockCheckResolver(mockController), ts, WithBatchCheckMaxChecksPerStreamedBatch(1))
+
+		_, err := cmd.ExecuteStreamed(context.Background(), &BatchCheckCommandParams{
+			AuthorizationModelID: ts.GetAuthorizationModelID(),
+			Checks: []*openfgav1.BatchCheckItem{
+				{TupleKey: &openfgav1.CheckRequestTupleKey{Object: "doc:doc1", Relation: "viewer", User: "user:justin"}, CorrelationId: "qwe"},
+				{TupleKey: &openfgav1.CheckRequestTupleKey{Object: "doc:doc2", Relation: "viewer", User: "user:justin"}, CorrelationId: "rty"},
+			},
+			StoreID: ulid.Make().String(),
+		}, func(CorrelationID, *BatchCheckOutcome) error {
+			return nil
+		})
+		var validationErr *BatchCheckValidationError
+		require.ErrorAs(t, err, &validationErr)
+	})
+}
+
+func TestCheckCacheKeyBuilder(t *testing.T) {
+	storeID := ulid.Make().String()
+	modelID := ulid.Make().String()
+
+	sharedContext := testutils.MustNewStruct(t, map[string]interface{}{"ip": "10.0.0.1", "time": "2024-01-01T00:00:00Z"})
+	sharedTuples := &openfgav1.ContextualTupleKeys{
+		TupleKeys: []*openfgav1.TupleKey{
+			tuple.NewTupleKey("doc:1", "viewer", "user:anne"),
+			tuple.NewTupleKey("doc:2", "viewer", "user:bob"),
+		},
+	}
+	reorderedTuples := &openfgav1.ContextualTupleKeys{
+		TupleKeys: []*openfgav1.TupleKey{
+			tuple.NewTupleKey("doc:2", "viewer", "user:bob"),
+			tuple.NewTupleKey("doc:1", "viewer", "user:anne"),
+		},
+	}
+
+	newCheck := func(object string, contextualTuples *openfgav1.ContextualTupleKeys, context *structpb.Struct) *openfgav1.BatchCheckItem {
+		return &openfgav1.BatchCheckItem{
+			TupleKey:         &openfgav1.CheckRequestTupleKey{Object: object, Relation: "viewer", User: "user:anne"},
+			ContextualTuples: contextualTuples,
+			Context:          context,
+		}
+	}
+
+	checks := []*openfgav1.BatchCheckItem{
+		newCheck("doc:1", nil, nil),
+		newCheck("doc:2", nil, nil),
+		newCheck("doc:1", sharedTuples, sharedContext),
+		newCheck("doc:2", sharedTuples, sharedContext),
+		newCheck("doc:3", proto.Clone(sharedTuples).(*openfgav1.ContextualTupleKeys), proto.Clone(sharedContext).(*structpb.Struct)),
+		newCheck("doc:3", reorderedTuples, sharedContext),
+		newCheck("doc:3", sharedTuples, nil),
+		newCheck("doc:3", nil, sharedContext),
+		newCheck("doc:3", &openfgav1.ContextualTupleKeys{TupleKeys: []*openfgav1.TupleKey{
+			{Object: "doc:1", Relation: "viewer", User: "user:anne", Condition: &openfgav1.RelationshipCondition{}},
+			tuple.NewTupleKey("doc:2", "viewer", "user:bob"),
+		}}, nil),
+		newCheck("doc:1", nil, nil),
+	}
+
+	builder := &checkCacheKeyBuilder{storeID: storeID, authModelID: modelID}
+	keys := make(map[CacheKey]struct{})
+	for _, check := range checks {
+		expected, err := generateCacheKeyFromCheck(check, storeID, modelID)
+		require.NoError(t, err)
+
+		actual, err := builder.build(check)
+		require.NoError(t, err)
+		require.Equal(t, expected, actual)
+
+		keys[actual] = struct{}{}
+	}
+
+	// doc:1 without context is repeated, and so are doc:3 with shared and reordered contextual tuples
+	require.Len(t, keys, len(checks)-2)
+}
+
+func BenchmarkCheckCacheKeyWithSharedContext(b *testing.B) {
+	storeID := ulid.Make().String()
+	modelID := ulid.Make().String()
+
+	contextualTuples := &openfgav1.ContextualTupleKeys{}
+	for i := 0; i < 10; i++ {
+		contextualTuples.TupleKeys = append(contextualTuples.TupleKeys, tuple.NewTupleKey(fmt.Sprintf("doc:%d", i), "viewer", "user:anne"))
+	}
+	context := testutils.MustNewStruct(b, map[string]interface{}{"ip": "10.0.0.1", "time": "2024-01-01T00:00:00Z", "region": "eu"})
+
+	checks := make([]*openfgav1.BatchCheckItem, config.DefaultMaxChecksPerBatchCheck)
+	for i := range checks {
+		// each check of a request is unmarshalled on its own, so the contexts are equal but not shared
+		checks[i] = &openfgav1.BatchCheckItem{
+			TupleKey:         &openfgav1.CheckRequestTupleKey{Object: fmt.Sprintf("doc:%d", i), Relation: "viewer", User: "user:anne"},
+			ContextualTuples: proto.Clone(contextualTuples).(*openfgav1.ContextualTupleKeys),
+			Context:          proto.Clone(context).(*structpb.Struct),
+		}
+	}
+
+	b.Run("per_check", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			for _, check := range checks {
+				_, err := generateCacheKeyFromCheck(check, storeID, modelID)
+				require.NoError(b, err)
+			}
+		}
+	})
+
+	b.Run("shared_invariant", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			builder := &checkCacheKeyBuilder{storeID: storeID, authModelID: modelID}
+			for _, check := range checks {
+				_, err := builder.build(check)
+				require.NoError(b, err)
+			}
+		}
+	})
 }
 
 func BenchmarkBatchCheckCommand(b *testing.B) {
@@ -0,0 +1,276 @@
+package commands
+
+import (
+	"context"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/cespare/xxhash/v2"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+	"go.uber.org/zap"
+
+	openfgav1 "github.com/openfga/api/proto/openfga/v1"
+
+	"github.com/openfga/openfga/internal/build"
+	"github.com/openfga/openfga/internal/cachecontroller"
+	"github.com/openfga/openfga/pkg/logger"
+	serverconfig "github.com/openfga/openfga/pkg/server/config"
+	"github.com/openfga/openfga/pkg/storage"
+)
+
+const listObjectsCachePrefix = "lo."
+
+var (
+	listObjectsCacheTotalCounter = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: build.ProjectName,
+		Name:      "list_objects_cache_total_count",
+		Help:      "The total number of ListObjects calls that attempted to use the ListObjects result cache.",
+	})
+
+	listObjectsCacheHitCounter = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: build.ProjectName,
+		Name:      "list_objects_cache_hit_count",
+		Help:      "The total number of cache hits for ListObjects.",
+	})
+
+	listObjectsCacheInvalidHitCounter = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: build.ProjectName,
+		Name:      "list_objects_cache_invalid_hit_count",
+		Help:      "The total number of cache hits for ListObjects that were discarded because they were invalidated.",
+	})
+)
+
+var (
+	_ storage.CacheItem       = (*ListObjectsResponseCacheEntry)(nil)
+	_ storage.CostedCacheItem = (*ListObjectsResponseCacheEntry)(nil)
+)
+
+type ListObjectsResponseCacheEntry struct {
+	LastModified time.Time
+	Objects      []string
+	// TruncatedBy is the reason the cached objects may be incomplete.
+	TruncatedBy ListObjectsTruncation
+}
+
+func (c *ListObjectsResponseCacheEntry) CacheEntityType() string {
+	return "list_objects_response"
+}
+
+// CacheCost implements storage.CostedCacheItem.
+func (c *ListObjectsResponseCacheEntry) CacheCost() int64 {
+	return storage.StringsCacheCost(c.Objects)
+}
+
+// CachedListObjectsResolver attempts to resolve ListObjects requests via prior computations before
+// delegating the request to some underlying ListObjectsResolver.
+type CachedListObjectsResolver struct {
+	delegate        ListObjectsResolver
+	cache           storage.InMemoryCache[any]
+	cacheController cachecontroller.CacheController
+	cacheTTL        time.Duration
+	maxResults      uint32
+	deadline        time.Duration
+	logger          logger.Logger
+	// allocatedCache is used to denote whether the cache is allocated by this struct.
+	// If so, CachedListObjectsResolver is responsible for cleaning up.
+	allocatedCache bool
+}
+
+var _ ListObjectsResolver = (*CachedListObjectsResolver)(nil)
+
+// CachedListObjectsResolverOption defines an option that can be used to change the behavior of
+// CachedListObjectsResolver instance.
+type CachedListObjectsResolverOption func(*CachedListObjectsResolver)
+
+// WithCachedListObjectsTTL sets the TTL (as a duration) for any single ListObjects cache key value.
+func WithCachedListObjectsTTL(ttl time.Duration) CachedListObjectsResolverOption {
+	return func(c *CachedListObjectsResolver) {
+		c.cacheTTL = ttl
+	}
+}
+
+// WithCachedListObjectsMaxResults sets the maximum number of objects in a result that is cached.
+// Larger results are returned but not cached.
+func WithCachedListObjectsMaxResults(maxResults uint32) CachedListObjectsResolverOption {
+	return func(c *CachedListObjectsResolver) {
+		c.maxResults = maxResults
+	}
+}
+
+// WithCachedListObjectsExistingCache sets the cache to the specified cache.
+// Note that the original cache will not be stopped as it may still be used by others. It is up to the caller
+// to check whether the original cache should be stopped.
+func WithCachedListObjectsExistingCache(cache storage.InMemoryCache[any]) CachedListObjectsResolverOption {
+	return func(c *CachedListObjectsResolver) {
+		c.cache = cache
+	}
+}
+
+// WithCachedListObjectsCacheController sets the cache controller used to discard the cached results
+// of a store that were computed before its last write.
+func WithCachedListObjectsCacheController(cacheController cachecontroller.CacheController) CachedListObjectsResolverOption {
+	return func(c *CachedListObjectsResolver) {
+		c.cacheController = cacheController
+	}
+}
+
+// WithCachedListObjectsDeadline sets the deadline of the delegate. Results that took at least the
+// deadline to compute may be incomplete, so they are not cached.
+func WithCachedListObjectsDeadline(deadline time.Duration) CachedListObjectsResolverOption {
+	return func(c *CachedListObjectsResolver) {
+		c.deadline = deadline
+	}
+}
+
+// WithCachedListObjectsLogger sets the logger for the cached ListObjects resolver.
+func WithCachedListObjectsLogger(l logger.Logger) CachedListObjectsResolverOption {
+	return func(c *CachedListObjectsResolver) {
+		c.logger = l
+	}
+}
+
+// NewCachedListObjectsResolver constructs a ListObjectsResolver that delegates ListObjects resolution to the
+// provided delegate, but before delegating a cache-key lookup is made to see if the same request has already
+// recently been computed. If it has, the cached objects are returned immediately.
+// NOTE: the resolution metadata of a cached response is left empty as we actually did no database lookup.
+func NewCachedListObjectsResolver(delegate ListObjectsResolver, opts ...CachedListObjectsResolverOption) (*CachedListObjectsResolver, error) {
+	c := &CachedListObjectsResolver{
+		delegate:        delegate,
+		cacheController: cachecontroller.NewNoopCacheController(),
+		cacheTTL:        serverconfig.DefaultListObjectsQueryCacheTTL,
+		maxResults:      serverconfig.DefaultListObjectsQueryCacheMaxResults,
+		logger:          logger.NewNoopLogger(),
+	}
+
+	for _, opt := range opts {
+		opt(c)
+	}
+
+	if c.cache == nil {
+		c.allocatedCache = true
+
+		var err error
+		c.cache, err = storage.NewInMemoryLRUCache[any](
+			storage.WithMaxCacheSize[any](int64(serverconfig.DefaultCheckCacheLimit)),
+		)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return c, nil
+}
+
+// Close will deallocate resource allocated by the CachedListObjectsResolver.
+// It will not deallocate cache if it has been passed in from WithCachedListObjectsExistingCache.
+func (c *CachedListObjectsResolver) Close() {
+	if c.allocatedCache {
+		c.cache.Stop()
+	}
+}
+
+// Execute returns the cached objects of the request if they were computed after the last write to the store,
+// and otherwise delegates the request and caches the result. Requests with HIGHER_CONSISTENCY are always delegated.
+func (c *CachedListObjectsResolver) Execute(
+	ctx context.Context,
+	req *openfgav1.ListObjectsRequest,
+) (*ListObjectsResponse, error) {
+	if req.GetConsistency() == openfgav1.ConsistencyPreference_HIGHER_CONSISTENCY {
+		return c.delegate.Execute(ctx, req)
+	}
+
+	span := trace.SpanFromContext(ctx)
+
+	cacheKey, err := buildListObjectsCacheKey(req)
+	if err != nil {
+		return nil, err
+	}
+
+	listObjectsCacheTotalCounter.Inc()
+
+	invalidationTime := c.cacheController.DetermineInvalidationTime(ctx, req.GetStoreId())
+
+	if cached := c.cache.Get(cacheKey); cached != nil {
+		entry := cached.(*ListObjectsResponseCacheEntry)
+		isValid := entry.LastModified.After(invalidationTime)
+		c.logger.Debug("CachedListObjectsResolver found cache key",
+			zap.String("store_id", req.GetStoreId()),
+			zap.String("authorization_model_id", req.GetAuthorizationModelId()),
+			zap.Bool("isValid", isValid))
+
+		span.SetAttributes(attribute.Bool("cached", isValid))
+		if isValid {
+			listObjectsCacheHitCounter.Inc()
+			// return a copy to avoid races across goroutines
+			return &ListObjectsResponse{
+				Objects:     append([]string(nil), entry.Objects...),
+				Truncated:   entry.TruncatedBy != ListObjectsNotTruncated,
+				TruncatedBy: entry.TruncatedBy,
+			}, nil
+		}
+
+		listObjectsCacheInvalidHitCounter.Inc()
+	}
+
+	start := time.Now()
+
+	resp, err := c.delegate.Execute(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+
+	if uint32(len(resp.Objects)) > c.maxResults {
+		span.SetAttributes(attribute.Bool("cache_skipped_max_results", true))
+		return resp, nil
+	}
+
+	// the deadline may have cut the result short
+	if resp.TruncatedBy == ListObjectsTruncatedByDeadline || (c.deadline != 0 && time.Since(start) >= c.deadline) {
+		return resp, nil
+	}
+
+	c.cache.Set(cacheKey, &ListObjectsResponseCacheEntry{
+		LastModified: start,
+		Objects:      append([]string(nil), resp.Objects...),
+		TruncatedBy:  resp.TruncatedBy,
+	}, c.cacheTTL)
+
+	return resp, nil
+}
+
+// ExecuteStreamed always delegates the request, streamed results are not cached.
+func (c *CachedListObjectsResolver) ExecuteStreamed(
+	ctx context.Context,
+	req *openfgav1.StreamedListObjectsRequest,
+	srv openfgav1.OpenFGAService_StreamedListObjectsServer,
+) (*ListObjectsResolutionMetadata, error) {
+	return c.delegate.ExecuteStreamed(ctx, req, srv)
+}
+
+// buildListObjectsCacheKey returns the cache key of the result of a ListObjects request, which is
+// determined by its store, model, type, relation, user, contextual tuples and context.
+func buildListObjectsCacheKey(req *openfgav1.ListObjectsRequest) (string, error) {
+	var b strings.Builder
+	b.WriteString(req.GetType() + "#" + req.GetRelation() + "@" + req.GetUser())
+
+	err := storage.WriteInvariantCheckCacheKey(&b, &storage.CheckCacheKeyParams{
+		StoreID:              req.GetStoreId(),
+		AuthorizationModelID: req.GetAuthorizationModelId(),
+		ContextualTuples:     req.GetContextualTuples().GetTupleKeys(),
+		Context:              req.GetContext(),
+	})
+	if err != nil {
+		return "", err
+	}
+
+	hasher := xxhash.New()
+
+	// Digest.WriteString returns int and a nil error, ignoring
+	_, _ = hasher.WriteString(b.String())
+
+	return listObjectsCachePrefix + strconv.FormatUint(hasher.Sum64(), 10), nil
+}
@@ -0,0 +1,240 @@
+package commands
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"go.uber.org/goleak"
+	"go.uber.org/mock/gomock"
+	"google.golang.org/protobuf/types/known/structpb"
+
+	openfgav1 "github.com/openfga/api/proto/openfga/v1"
+
+	"github.com/openfga/openfga/internal/mocks"
+	"github.com/openfga/openfga/pkg/testutils"
+	"github.com/openfga/openfga/pkg/tuple"
+)
+
+func TestCachedListObjectsResolver(t *testing.T) {
+	t.Cleanup(func() {
+		goleak.VerifyNone(t)
+	})
+
+	storeID := "01JCYFTAPDJWKGCAQSEM6XTKZ5"
+
+	newRequest := func() *openfgav1.ListObjectsRequest {
+		return &openfgav1.ListObjectsRequest{
+			StoreId:              storeID,
+			AuthorizationModelId: "01JCYFTAPDJWKGCAQSEM6XTKZ6",
+			Type:                 "document",
+			Relation:             "viewer",
+			User:                 "user:anne",
+		}
+	}
+
+	newDelegate := func(objects ...string) (*mockListObjectsQuery, *int) {
+		calls := 0
+		return &mockListObjectsQuery{
+			executeFunc: func(_ context.Context, _ *openfgav1.ListObjectsRequest) (*ListObjectsResponse, error) {
+				calls++
+				return &ListObjectsResponse{Objects: append([]string(nil), objects...)}, nil
+			},
+		}, &calls
+	}
+
+	t.Run("second_request_is_served_from_cache", func(t *testing.T) {
+		delegate, calls := newDelegate("document:1", "document:2")
+		resolver, err := NewCachedListObjectsResolver(delegate)
+		require.NoError(t, err)
+		t.Cleanup(resolver.Close)
+
+		for range 2 {
+			resp, err := resolver.Execute(context.Background(), newRequest())
+			require.NoError(t, err)
+			require.ElementsMatch(t, []string{"document:1", "document:2"}, resp.Objects)
+		}
+		require.Equal(t, 1, *calls)
+	})
+
+	t.Run("different_requests_miss", func(t *testing.T) {
+		delegate, calls := newDelegate("document:1")
+		resolver, err := NewCachedListObjectsResolver(delegate)
+		require.NoError(t, err)
+		t.Cleanup(resolver.Close)
+
+		ctx := context.Background()
+		_, err = resolver.Execute(ctx, newRequest())
+		require.NoError(t, err)
+
+		req := newRequest()
+		req.User = "user:bob"
+		_, err = resolver.Execute(ctx, req)
+		require.NoError(t, err)
+
+		req = newRequest()
+		req.ContextualTuples = &openfgav1.ContextualTupleKeys{
+			TupleKeys: []*openfgav1.TupleKey{tuple.NewTupleKey("document:3", "viewer", "user:anne")},
+		}
+		_, err = resolver.Execute(ctx, req)
+		require.NoError(t, err)
+
+		req = newRequest()
+		req.Context = testutils.MustNewStruct(t, map[string]interface{}{"x": 1})
+		_, err = resolver.Execute(ctx, req)
+		require.NoError(t, err)
+
+		require.Equal(t, 4, *calls)
+	})
+
+	t.Run("higher_consistency_bypasses_cache", func(t *testing.T) {
+		delegate, calls := newDelegate("document:1")
+		resolver, err := NewCachedListObjectsResolver(delegate)
+		require.NoError(t, err)
+		t.Cleanup(resolver.Close)
+
+		req := newRequest()
+		req.Consistency = openfgav1.ConsistencyPreference_HIGHER_CONSISTENCY
+		for range 2 {
+			_, err = resolver.Execute(context.Background(), req)
+			require.NoError(t, err)
+		}
+		require.Equal(t, 2, *calls)
+	})
+
+	t.Run("results_above_max_results_are_not_cached", func(t *testing.T) {
+		delegate, calls := newDelegate("document:1", "document:2")
+		resolver, err := NewCachedListObjectsResolver(delegate, WithCachedListObjectsMaxResults(1))
+		require.NoError(t, err)
+		t.Cleanup(resolver.Close)
+
+		for range 2 {
+			resp, err := resolver.Execute(context.Background(), newRequest())
+			require.NoError(t, err)
+			require.Len(t, resp.Objects, 2)
+		}
+		require.Equal(t, 2, *calls)
+	})
+
+	t.Run("cached_response_is_a_copy", func(t *testing.T) {
+		delegate, _ := newDelegate("document:1")
+		resolver, err := NewCachedListObjectsResolver(delegate)
+		require.NoError(t, err)
+		t.Cleanup(resolver.Close)
+
+		resp, err := resolver.Execute(context.Background(), newRequest())
+		require.NoError(t, err)
+		resp.Objects[0] = "document:mutated"
+
+		resp, err = resolver.Execute(context.Background(), newRequest())
+		require.NoError(t, err)
+		require.Equal(t, []string{"document:1"}, resp.Objects)
+	})
+
+	t.Run("write_after_cached_result_invalidates_it", func(t *testing.T) {
+		mockController := gomock.NewController(t)
+		t.Cleanup(mockController.Finish)
+
+		cacheController := mocks.NewMockCacheController(mockController)
+		gomock.InOrder(
+			cacheController.EXPECT().DetermineInvalidationTime(gomock.Any(), storeID).Return(time.Time{}),
+			cacheController.EXPECT().DetermineInvalidationTime(gomock.Any(), storeID).DoAndReturn(func(context.Context, string) time.Time {
+				return time.Now()
+			}),
+			cacheController.EXPECT().DetermineInvalidationTime(gomock.Any(), storeID).Return(time.Time{}),
+		)
+
+		objects := []string{"document:1"}
+		calls := 0
+		delegate := &mockListObjectsQuery{
+			executeFunc: func(_ context.Context, _ *openfgav1.ListObjectsRequest) (*ListObjectsResponse, error) {
+				calls++
+				return &ListObjectsResponse{Objects: append([]string(nil), objects...)}, nil
+			},
+		}
+
+		resolver, err := NewCachedListObjectsResolver(delegate, WithCachedListObjectsCacheController(cacheController))
+		require.NoError(t, err)
+		t.Cleanup(resolver.Close)
+
+		resp, err := resolver.Execute(context.Background(), newRequest())
+		require.NoError(t, err)
+		require.Equal(t, []string{"document:1"}, resp.Objects)
+
+		// a newly granted object is written after the result was cached
+		objects = append(objects, "document:2")
+
+		resp, err = resolver.Execute(context.Background(), newRequest())
+		require.NoError(t, err)
+		require.Equal(t, []string{"document:1", "document:2"}, resp.Objects)
+
+		resp, err = resolver.Execute(context.Background(), newRequest())
+		require.NoError(t, err)
+		require.Equal(t, []string{"document:1", "document:2"}, resp.Objects)
+		require.Equal(t, 2, calls)
+	})
+
+	t.Run("expired_entries_miss", func(t *testing.T) {
+		delegate, calls := newDelegate("document:1")
+		resolver, err := NewCachedListObjectsResolver(delegate, WithCachedListObjectsTTL(time.Millisecond))
+		require.NoError(t, err)
+		t.Cleanup(resolver.Close)
+
+		_, err = resolver.Execute(context.Background(), newRequest())
+		require.NoError(t, err)
+
+		require.Eventually(t, func() bool {
+			_, err := resolver.Execute(context.Background(), newRequest())
+			require.NoError(t, err)
+			return *calls > 1
+		}, time.Second, 10*time.Millisecond)
+	})
+}
+
+func TestBuildListObjectsCacheKey(t *testing.T) {
+	req := &openfgav1.ListObjectsRequest{
+		StoreId:              "01JCYFTAPDJWKGCAQSEM6XTKZ5",
+		AuthorizationModelId: "01JCYFTAPDJWKGCAQSEM6XTKZ6",
+		Type:                 "document",
+		Relation:             "viewer",
+		User:                 "user:anne",
+		ContextualTuples: &openfgav1.ContextualTupleKeys{
+			TupleKeys: []*openfgav1.TupleKey{
+				tuple.NewTupleKey("document:1", "viewer", "user:anne"),
+				tuple.NewTupleKey("document:2", "viewer", "user:anne"),
+			},
+		},
+		Context: &structpb.Struct{Fields: map[string]*structpb.Value{
+			"a": structpb.NewNumberValue(1),
+			"b": structpb.NewStringValue("x"),
+		}},
+	}
+
+	key, err := buildListObjectsCacheKey(req)
+	require.NoError(t, err)
+	require.Contains(t, key, listObjectsCachePrefix)
+
+	reordered := &openfgav1.ListObjectsRequest{
+		StoreId:              req.GetStoreId(),
+		AuthorizationModelId: req.GetAuthorizationModelId(),
+		Type:                 req.GetType(),
+		Relation:             req.GetRelation(),
+		User:                 req.GetUser(),
+		ContextualTuples: &openfgav1.ContextualTupleKeys{
+			TupleKeys: []*openfgav1.TupleKey{
+				tuple.NewTupleKey("document:2", "viewer", "user:anne"),
+				tuple.NewTupleKey("document:1", "viewer", "user:anne"),
+			},
+		},
+		Context: req.GetContext(),
+	}
+	reorderedKey, err := buildListObjectsCacheKey(reordered)
+	require.NoError(t, err)
+	require.Equal(t, key, reorderedKey)
+
+	reordered.Relation = "editor"
+	otherKey, err := buildListObjectsCacheKey(reordered)
+	require.NoError(t, err)
+	require.NotEqual(t, key, otherKey)
+}
@@ -3,6 +3,7 @@ package commands
 import (
 	"context"
 	"errors"
+	"fmt"
 	"math"
 	"time"
 
@@ -25,6 +26,12 @@ import (
 
 const (
 	defaultMaxConcurrentReadsForCheck = math.MaxUint32
+
+	// MaxExcludedRelations is the maximum number of relations that can be excluded from a single Check.
+	MaxExcludedRelations = 20
+
+	// MaxAssumedFacts is the maximum number of facts that can be assumed in a single Check.
+	MaxAssumedFacts = 20
 )
 
 type CheckQuery struct {
@@ -38,6 +45,8 @@ type CheckQuery struct {
 	shouldCacheIterators       bool
 	datastoreThrottleThreshold int
 	datastoreThrottleDuration  time.Duration
+	dispatchThrottleFrequency  time.Duration
+	wildcardPolicy             tuple.WildcardPolicy
 }
 
 type CheckCommandParams struct {
@@ -46,6 +55,34 @@ type CheckCommandParams struct {
 	ContextualTuples *openfgav1.ContextualTupleKeys
 	Context          *structpb.Struct
 	Consistency      openfgav1.ConsistencyPreference
+	// ExcludedRelations are 'objectType#relation' pairs that are treated as having no members
+	// while resolving the Check, e.g. to simulate the access a user has without a given role.
+	ExcludedRelations []string
+	// AssumedFacts are 'object#relation@user' subproblems that are treated as allowed without being
+	// resolved, on top of the stored and contextual tuples, e.g. to simulate the access a user would
+	// have if they were granted a role. Unlike contextual tuples, they aren't subject to the model's
+	// type restrictions and conditions.
+	AssumedFacts []*openfgav1.TupleKey
+	// Scoring resolves the Check in scoring mode with the given rubric. The score of the winning
+	// path is reported in the Score of the response, in addition to the allowed decision.
+	Scoring *graph.CheckScoring
+	// Session memoizes the outcomes of subproblems across the Checks that are resolved with it.
+	Session *graph.CheckSession
+	// CacheMode determines whether the check cache is read and written while resolving the Check, e.g.
+	// CacheWriteOnly to warm the cache from a background job without serving cached results, or CacheOff
+	// to verify a fix without waiting for cached results to expire. Unlike HIGHER_CONSISTENCY, it doesn't
+	// change how the datastore is read.
+	CacheMode graph.CacheMode
+	// CacheNamespace separates the cache entries of the Check from those of the Checks of other namespaces,
+	// e.g. of the tenants that share a store.
+	CacheNamespace string
+	// ModelDeltaFingerprint must be set to the fingerprint of the delta when the typesystem of the
+	// command is the result of typesystem.ApplyModelDelta, so that its results are cached apart from
+	// the results of the base model.
+	ModelDeltaFingerprint string
+	// Trace resolves the Check in trace mode. The resolution tree is reported in the Trace of the response,
+	// e.g. to explain why the Check was allowed or denied.
+	Trace bool
 }
 
 type CheckQueryOption func(*CheckQuery)
@@ -76,6 +113,21 @@ func WithCheckDatastoreThrottler(threshold int, duration time.Duration) CheckQue
 	}
 }
 
+// WithCheckDispatchThrottlingFrequency sets the frequency of the dispatch throttler of the check resolver,
+// which is suggested as the delay before retrying a Check throttled by it.
+func WithCheckDispatchThrottlingFrequency(frequency time.Duration) CheckQueryOption {
+	return func(c *CheckQuery) {
+		c.dispatchThrottleFrequency = frequency
+	}
+}
+
+// WithCheckCommandWildcardPolicy sets the policy that determines the users that typed wildcards apply to.
+func WithCheckCommandWildcardPolicy(policy tuple.WildcardPolicy) CheckQueryOption {
+	return func(c *CheckQuery) {
+		c.wildcardPolicy = policy
+	}
+}
+
 // TODO accept CheckCommandParams so we can build the datastore object right away.
 func NewCheckCommand(datastore storage.RelationshipTupleReader, checkResolver graph.CheckResolver, typesys *typesystem.TypeSystem, opts ...CheckQueryOption) *CheckQuery {
 	cmd := &CheckQuery{
@@ -89,6 +141,7 @@ func NewCheckCommand(datastore storage.RelationshipTupleReader, checkResolver gr
 		sharedCheckResources: &shared.SharedDatastoreResources{
 			CacheController: cachecontroller.NewNoopCacheController(),
 		},
+		dispatchThrottleFrequency: config.DefaultCheckDispatchThrottlingFrequency,
 	}
 
 	for _, opt := range opts {
@@ -98,48 +151,12 @@ func NewCheckCommand(datastore storage.RelationshipTupleReader, checkResolver gr
 }
 
 func (c *CheckQuery) Execute(ctx context.Context, params *CheckCommandParams) (*graph.ResolveCheckResponse, *graph.ResolveCheckRequestMetadata, error) {
-	err := validateCheckRequest(c.typesys, params.TupleKey, params.ContextualTuples)
-	if err != nil {
-		return nil, nil, err
-	}
-
-	cacheInvalidationTime := time.Time{}
-
-	if params.Consistency != openfgav1.ConsistencyPreference_HIGHER_CONSISTENCY {
-		cacheInvalidationTime = c.sharedCheckResources.CacheController.DetermineInvalidationTime(ctx, params.StoreID)
-	}
-
-	resolveCheckRequest, err := graph.NewResolveCheckRequest(
-		graph.ResolveCheckRequestParams{
-			StoreID:                   params.StoreID,
-			TupleKey:                  tuple.ConvertCheckRequestTupleKeyToTupleKey(params.TupleKey),
-			Context:                   params.Context,
-			ContextualTuples:          params.ContextualTuples,
-			Consistency:               params.Consistency,
-			LastCacheInvalidationTime: cacheInvalidationTime,
-			AuthorizationModelID:      c.typesys.GetAuthorizationModelID(),
-		},
-	)
-
+	resolveCheckRequest, err := c.newResolveCheckRequest(ctx, params, params.TupleKey)
 	if err != nil {
 		return nil, nil, err
 	}
 
-	datastoreWithTupleCache := storagewrappers.NewRequestStorageWrapperWithCache(
-		c.datastore,
-		params.ContextualTuples.GetTupleKeys(),
-		&storagewrappers.Operation{
-			Method:            apimethod.Check,
-			Concurrency:       c.maxConcurrentReads,
-			ThrottleThreshold: c.datastoreThrottleThreshold,
-			ThrottleDuration:  c.datastoreThrottleDuration,
-		},
-		storagewrappers.DataResourceConfiguration{
-			Resources:      c.sharedCheckResources,
-			CacheSettings:  c.cacheSettings,
-			UseShadowCache: false,
-		},
-	)
+	datastoreWithTupleCache := c.requestDatastore(params.ContextualTuples)
 
 	ctx = typesystem.ContextWithTypesystem(ctx, c.typesys)
 	ctx = storage.ContextWithRelationshipTupleReader(ctx, datastoreWithTupleCache)
@@ -163,11 +180,21 @@ func (c *CheckQuery) Execute(ctx context.Context, params *CheckCommandParams) (*
 	dsMeta := datastoreWithTupleCache.GetMetadata()
 	resp.ResolutionMetadata.DatastoreQueryCount = dsMeta.DatastoreQueryCount
 	// Until dispatch throttling is deprecated, merge the results of both
+	dispatchThrottled := resolveCheckRequest.GetRequestMetadata().WasThrottled.Load()
 	resolveCheckRequest.GetRequestMetadata().WasThrottled.CompareAndSwap(false, dsMeta.WasThrottled)
 
 	if err != nil {
 		if errors.Is(err, context.DeadlineExceeded) && resolveCheckRequest.GetRequestMetadata().WasThrottled.Load() {
-			return resp, resolveCheckRequest.GetRequestMetadata(), &ThrottledError{Cause: err}
+			throttledErr := &ThrottledError{Cause: err, Limit: DatastoreThrottlingLimit, RetryAfter: c.datastoreThrottleDuration}
+			if dispatchThrottled {
+				throttledErr.Limit = DispatchThrottlingLimit
+				throttledErr.RetryAfter = c.dispatchThrottleFrequency
+			}
+			return resp, resolveCheckRequest.GetRequestMetadata(), throttledErr
+		}
+
+		if limit := resolveCheckRequest.GetRequestMetadata().ThrottledLimit(); errors.Is(err, context.DeadlineExceeded) && limit != "" {
+			return resp, resolveCheckRequest.GetRequestMetadata(), &ThrottledError{Cause: err, Limit: limit, RetryAfter: concurrencyLimitRetryAfter}
 		}
 
 		return resp, resolveCheckRequest.GetRequestMetadata(), err
@@ -176,9 +203,154 @@ func (c *CheckQuery) Execute(ctx context.Context, params *CheckCommandParams) (*
 	return resp, resolveCheckRequest.GetRequestMetadata(), nil
 }
 
-func validateCheckRequest(typesys *typesystem.TypeSystem, tupleKey *openfgav1.CheckRequestTupleKey, contextualTuples *openfgav1.ContextualTupleKeys) error {
+// CheckBatchMetadata is the metadata of the resolution of a batch of Checks, see ExecuteBatch.
+type CheckBatchMetadata struct {
+	// DatastoreQueryCount is the number of datastore queries made by the Checks of the batch.
+	DatastoreQueryCount uint32
+	// DispatchCount is the number of dispatches made by the Checks of the batch.
+	DispatchCount uint32
+	// WasThrottled reports whether any Check of the batch was throttled.
+	WasThrottled bool
+}
+
+// ExecuteBatch resolves the Checks of the tuple keys, which share the other parameters of params, as one batch
+// with graph.ResolveChecks, with at most concurrencyLimit in flight. The Checks go through the check resolver of
+// the command, identical Checks are resolved once, and the Checks share the tuples read from the datastore. The
+// response of each Check is returned at its position. If any Check fails, its response is nil and the responses
+// of the others are still returned, along with a *graph.ResolveChecksError that holds the error of each Check.
+func (c *CheckQuery) ExecuteBatch(ctx context.Context, params *CheckCommandParams, tupleKeys []*openfgav1.CheckRequestTupleKey, concurrencyLimit int) ([]*graph.ResolveCheckResponse, *CheckBatchMetadata, error) {
+	errs := make([]error, len(tupleKeys))
+	reqs := make([]*graph.ResolveCheckRequest, 0, len(tupleKeys))
+	positions := make([]int, 0, len(tupleKeys))
+	for i, tk := range tupleKeys {
+		req, err := c.newResolveCheckRequest(ctx, params, tk)
+		if err != nil {
+			errs[i] = err
+			continue
+		}
+		reqs = append(reqs, req)
+		positions = append(positions, i)
+	}
+
+	datastoreWithTupleCache := c.requestDatastore(params.ContextualTuples)
+
+	ctx = typesystem.ContextWithTypesystem(ctx, c.typesys)
+	ctx = storage.ContextWithRelationshipTupleReader(ctx, datastoreWithTupleCache)
+
+	resolved, err := graph.ResolveChecks(ctx, c.checkResolver, reqs, concurrencyLimit)
+	var resolveChecksErr *graph.ResolveChecksError
+	if err != nil && !errors.As(err, &resolveChecksErr) {
+		return nil, nil, err
+	}
+
+	dsMeta := datastoreWithTupleCache.GetMetadata()
+	metadata := &CheckBatchMetadata{
+		DatastoreQueryCount: dsMeta.DatastoreQueryCount,
+		WasThrottled:        dsMeta.WasThrottled,
+	}
+
+	resps := make([]*graph.ResolveCheckResponse, len(tupleKeys))
+	failed := false
+	for j, req := range reqs {
+		i := positions[j]
+		resps[i] = resolved[j]
+		if resolveChecksErr != nil {
+			errs[i] = resolveChecksErr.Errs[j]
+		}
+		metadata.DispatchCount += req.GetRequestMetadata().DispatchCounter.Load()
+		metadata.WasThrottled = metadata.WasThrottled || req.GetRequestMetadata().WasThrottled.Load()
+	}
+	for _, err := range errs {
+		failed = failed || err != nil
+	}
+
+	if failed {
+		return resps, metadata, &graph.ResolveChecksError{Errs: errs}
+	}
+	return resps, metadata, nil
+}
+
+// newResolveCheckRequest validates the Check of the tuple key with the other parameters of params, and returns
+// its request.
+func (c *CheckQuery) newResolveCheckRequest(ctx context.Context, params *CheckCommandParams, checkTupleKey *openfgav1.CheckRequestTupleKey) (*graph.ResolveCheckRequest, error) {
+	tupleKey := tuple.ConvertCheckRequestTupleKeyToTupleKey(checkTupleKey)
+
+	// the user is validated as sent, so that an untyped user, e.g. 'anne', is rejected in schema 1.1 as it is by
+	// Write and ListObjects rather than resolved as the only type of user it could be of
+	err := validateCheckRequest(c.typesys, tupleKey, params.ContextualTuples)
+	if err != nil {
+		return nil, err
+	}
+
+	// the request is resolved, and its cache key computed, with the canonical form of the user, see NormalizeUser
+	tupleKey = c.typesys.NormalizeTupleKey(tupleKey)
+
+	err = validateExcludedRelations(c.typesys, params.ExcludedRelations)
+	if err != nil {
+		return nil, err
+	}
+
+	err = validateAssumedFacts(c.typesys, params.AssumedFacts)
+	if err != nil {
+		return nil, err
+	}
+
+	if params.Scoring != nil {
+		if err := params.Scoring.Validate(); err != nil {
+			return nil, err
+		}
+	}
+
+	cacheInvalidationTime := time.Time{}
+
+	if params.Consistency != openfgav1.ConsistencyPreference_HIGHER_CONSISTENCY {
+		cacheInvalidationTime = c.sharedCheckResources.CacheController.DetermineInvalidationTime(ctx, params.StoreID)
+	}
+
+	return graph.NewResolveCheckRequest(
+		graph.ResolveCheckRequestParams{
+			StoreID:                   params.StoreID,
+			TupleKey:                  tupleKey,
+			Context:                   params.Context,
+			ContextualTuples:          params.ContextualTuples,
+			Consistency:               params.Consistency,
+			LastCacheInvalidationTime: cacheInvalidationTime,
+			AuthorizationModelID:      c.typesys.GetAuthorizationModelID(),
+			ExcludedRelations:         params.ExcludedRelations,
+			AssumedFacts:              params.AssumedFacts,
+			Scoring:                   params.Scoring,
+			Session:                   params.Session,
+			WildcardPolicy:            c.wildcardPolicy,
+			CacheMode:                 params.CacheMode,
+			CacheNamespace:            params.CacheNamespace,
+			ModelDeltaFingerprint:     params.ModelDeltaFingerprint,
+			Trace:                     params.Trace,
+		},
+	)
+}
+
+// requestDatastore returns the datastore that the Checks of the command read, which includes the contextual tuples.
+func (c *CheckQuery) requestDatastore(contextualTuples *openfgav1.ContextualTupleKeys) *storagewrappers.RequestStorageWrapper {
+	return storagewrappers.NewRequestStorageWrapperWithCache(
+		c.datastore,
+		contextualTuples.GetTupleKeys(),
+		&storagewrappers.Operation{
+			Method:            apimethod.Check,
+			Concurrency:       c.maxConcurrentReads,
+			ThrottleThreshold: c.datastoreThrottleThreshold,
+			ThrottleDuration:  c.datastoreThrottleDuration,
+		},
+		storagewrappers.DataResourceConfiguration{
+			Resources:      c.sharedCheckResources,
+			CacheSettings:  c.cacheSettings,
+			UseShadowCache: false,
+		},
+	)
+}
+
+func validateCheckRequest(typesys *typesystem.TypeSystem, tupleKey *openfgav1.TupleKey, contextualTuples *openfgav1.ContextualTupleKeys) error {
 	// The input tuple Key should be validated loosely.
-	if err := validation.ValidateUserObjectRelation(typesys, tuple.ConvertCheckRequestTupleKeyToTupleKey(tupleKey)); err != nil {
+	if err := validation.ValidateUserObjectRelation(typesys, tupleKey); err != nil {
 		return &InvalidRelationError{Cause: err}
 	}
 
@@ -190,3 +362,33 @@ func validateCheckRequest(typesys *typesystem.TypeSystem, tupleKey *openfgav1.Ch
 	}
 	return nil
 }
+
+func validateExcludedRelations(typesys *typesystem.TypeSystem, excludedRelations []string) error {
+	if len(excludedRelations) > MaxExcludedRelations {
+		return &InvalidRelationError{Cause: fmt.Errorf("the number of excluded relations exceeds the allowed limit of %d", MaxExcludedRelations)}
+	}
+
+	for _, excluded := range excludedRelations {
+		objectType, relation := tuple.SplitObjectRelation(excluded)
+		if _, err := typesys.GetRelation(objectType, relation); err != nil {
+			return &InvalidRelationError{Cause: fmt.Errorf("excluded relation '%s': %w", excluded, err)}
+		}
+	}
+	return nil
+}
+
+func validateAssumedFacts(typesys *typesystem.TypeSystem, assumedFacts []*openfgav1.TupleKey) error {
+	if len(assumedFacts) > MaxAssumedFacts {
+		return &InvalidTupleError{Cause: fmt.Errorf("the number of assumed facts exceeds the allowed limit of %d", MaxAssumedFacts)}
+	}
+
+	for _, fact := range assumedFacts {
+		if fact.GetCondition() != nil {
+			return &InvalidTupleError{Cause: fmt.Errorf("assumed fact '%s' must not have a condition", tuple.TupleKeyToString(fact))}
+		}
+		if err := validation.ValidateUserObjectRelation(typesys, fact); err != nil {
+			return &InvalidTupleError{Cause: fmt.Errorf("assumed fact '%s': %w", tuple.TupleKeyToString(fact), err)}
+		}
+	}
+	return nil
+}
@@ -9,6 +9,7 @@ import (
 	"github.com/oklog/ulid/v2"
 	"github.com/stretchr/testify/require"
 	"go.uber.org/mock/gomock"
+	"google.golang.org/grpc/status"
 
 	openfgav1 "github.com/openfga/api/proto/openfga/v1"
 
@@ -21,6 +22,7 @@ import (
 	"github.com/openfga/openfga/pkg/server/config"
 	serverErrors "github.com/openfga/openfga/pkg/server/errors"
 	"github.com/openfga/openfga/pkg/storage"
+	"github.com/openfga/openfga/pkg/storage/memory"
 	"github.com/openfga/openfga/pkg/testutils"
 	"github.com/openfga/openfga/pkg/tuple"
 	"github.com/openfga/openfga/pkg/typesystem"
@@ -233,13 +235,16 @@ type doc
 				return nil, context.DeadlineExceeded
 			})
 
-		cmd := NewCheckCommand(mockDatastore, mockCheckResolver, ts)
+		cmd := NewCheckCommand(mockDatastore, mockCheckResolver, ts, WithCheckDispatchThrottlingFrequency(2*time.Second))
 		checkResp, checkRequestMetadata, err := cmd.Execute(ctx, &CheckCommandParams{
 			StoreID:  ulid.Make().String(),
 			TupleKey: tuple.NewCheckRequestTupleKey("doc:1", "viewer", "user:1"),
 		})
 
-		require.Error(t, err)
+		var throttledError *ThrottledError
+		require.ErrorAs(t, err, &throttledError)
+		require.Equal(t, DispatchThrottlingLimit, throttledError.Limit)
+		require.Equal(t, 2*time.Second, throttledError.RetryAfter)
 		require.Equal(t, uint32(1), checkResp.GetResolutionMetadata().DatastoreQueryCount)
 		require.Equal(t, uint32(1), checkRequestMetadata.Depth)
 		require.Equal(t, uint32(1), checkRequestMetadata.DispatchCounter.Load())
@@ -247,6 +252,412 @@ type doc
 	})
 }
 
+func TestCheckQueryWithExcludedRelations(t *testing.T) {
+	ctx := context.Background()
+	ds := memory.New()
+	t.Cleanup(ds.Close)
+
+	storeID := ulid.Make().String()
+	model := testutils.MustTransformDSLToProtoWithID(`
+model
+	schema 1.1
+type user
+type group
+	relations
+		define member: [user]
+type doc
+	relations
+		define owner: [user]
+		define admin: [user, group#member]
+		define editor: [user] or admin
+		define viewer: [user] or editor`)
+	require.NoError(t, ds.WriteAuthorizationModel(ctx, storeID, model))
+	require.NoError(t, ds.Write(ctx, storeID, nil, []*openfgav1.TupleKey{
+		tuple.NewTupleKey("group:eng", "member", "user:bob"),
+		tuple.NewTupleKey("doc:1", "admin", "group:eng#member"),
+	}))
+
+	ts, err := typesystem.NewAndValidate(ctx, model)
+	require.NoError(t, err)
+
+	checkResolver, checkResolverCloser, err := graph.NewOrderedCheckResolvers(
+		graph.WithCachedCheckResolverOpts(true),
+	).Build()
+	require.NoError(t, err)
+	t.Cleanup(checkResolverCloser)
+
+	check := func(excludedRelations ...string) (*graph.ResolveCheckResponse, error) {
+		resp, _, err := NewCheckCommand(ds, checkResolver, ts).Execute(ctx, &CheckCommandParams{
+			StoreID:           storeID,
+			TupleKey:          tuple.NewCheckRequestTupleKey("doc:1", "viewer", "user:bob"),
+			ExcludedRelations: excludedRelations,
+		})
+		return resp, err
+	}
+
+	resp, err := check()
+	require.NoError(t, err)
+	require.True(t, resp.GetAllowed())
+
+	t.Run("excluding_the_granting_relation_denies", func(t *testing.T) {
+		resp, err := check("doc#admin")
+		require.NoError(t, err)
+		require.False(t, resp.GetAllowed())
+	})
+
+	t.Run("excluding_a_userset_relation_denies", func(t *testing.T) {
+		resp, err := check("group#member")
+		require.NoError(t, err)
+		require.False(t, resp.GetAllowed())
+	})
+
+	t.Run("excluding_an_unrelated_relation_allows", func(t *testing.T) {
+		resp, err := check("doc#owner")
+		require.NoError(t, err)
+		require.True(t, resp.GetAllowed())
+	})
+
+	t.Run("undefined_relation", func(t *testing.T) {
+		_, err := check("doc#undefined")
+		var invalidRelationError *InvalidRelationError
+		require.ErrorAs(t, err, &invalidRelationError)
+	})
+
+	t.Run("too_many_relations", func(t *testing.T) {
+		excluded := make([]string, MaxExcludedRelations+1)
+		for i := range excluded {
+			excluded[i] = "doc#admin"
+		}
+		_, err := check(excluded...)
+		require.ErrorContains(t, err, "exceeds the allowed limit")
+	})
+}
+
+func TestCheckQueryWithAssumedFacts(t *testing.T) {
+	ctx := context.Background()
+	ds := memory.New()
+	t.Cleanup(ds.Close)
+
+	storeID := ulid.Make().String()
+	model := testutils.MustTransformDSLToProtoWithID(`
+model
+	schema 1.1
+type user
+type group
+	relations
+		define member: [user]
+type doc
+	relations
+		define owner: [user]
+		define editor: [user, group#member] or owner
+		define viewer: [user] or editor`)
+	require.NoError(t, ds.WriteAuthorizationModel(ctx, storeID, model))
+	require.NoError(t, ds.Write(ctx, storeID, nil, []*openfgav1.TupleKey{
+		tuple.NewTupleKey("doc:1", "editor", "group:eng#member"),
+	}))
+
+	ts, err := typesystem.NewAndValidate(ctx, model)
+	require.NoError(t, err)
+
+	checkResolver, checkResolverCloser, err := graph.NewOrderedCheckResolvers(
+		graph.WithCachedCheckResolverOpts(true),
+	).Build()
+	require.NoError(t, err)
+	t.Cleanup(checkResolverCloser)
+
+	check := func(assumedFacts ...*openfgav1.TupleKey) (*graph.ResolveCheckResponse, error) {
+		resp, _, err := NewCheckCommand(ds, checkResolver, ts).Execute(ctx, &CheckCommandParams{
+			StoreID:      storeID,
+			TupleKey:     tuple.NewCheckRequestTupleKey("doc:1", "viewer", "user:bob"),
+			AssumedFacts: assumedFacts,
+		})
+		return resp, err
+	}
+
+	resp, err := check()
+	require.NoError(t, err)
+	require.False(t, resp.GetAllowed())
+
+	t.Run("assuming_a_userset_membership_allows", func(t *testing.T) {
+		resp, err := check(tuple.NewTupleKey("group:eng", "member", "user:bob"))
+		require.NoError(t, err)
+		require.True(t, resp.GetAllowed())
+	})
+
+	t.Run("assuming_a_computed_relation_allows", func(t *testing.T) {
+		resp, err := check(tuple.NewTupleKey("doc:1", "owner", "user:bob"))
+		require.NoError(t, err)
+		require.True(t, resp.GetAllowed())
+	})
+
+	t.Run("assuming_an_unrelated_fact_denies", func(t *testing.T) {
+		resp, err := check(tuple.NewTupleKey("group:eng", "member", "user:maria"))
+		require.NoError(t, err)
+		require.False(t, resp.GetAllowed())
+
+		// the outcomes of the previous checks were not cached for this one
+		resp, err = check()
+		require.NoError(t, err)
+		require.False(t, resp.GetAllowed())
+	})
+
+	t.Run("undefined_relation", func(t *testing.T) {
+		_, err := check(tuple.NewTupleKey("doc:1", "undefined", "user:bob"))
+		var invalidTupleError *InvalidTupleError
+		require.ErrorAs(t, err, &invalidTupleError)
+	})
+
+	t.Run("condition", func(t *testing.T) {
+		_, err := check(tuple.NewTupleKeyWithCondition("doc:1", "owner", "user:bob", "in_region", nil))
+		require.ErrorContains(t, err, "must not have a condition")
+	})
+
+	t.Run("too_many_facts", func(t *testing.T) {
+		facts := make([]*openfgav1.TupleKey, MaxAssumedFacts+1)
+		for i := range facts {
+			facts[i] = tuple.NewTupleKey("doc:1", "owner", "user:bob")
+		}
+		_, err := check(facts...)
+		require.ErrorContains(t, err, "exceeds the allowed limit")
+	})
+}
+
+// blockingTupleReader blocks the reads of user tuples until their context is done.
+type blockingTupleReader struct {
+	storage.RelationshipTupleReader
+}
+
+func (r *blockingTupleReader) ReadUserTuple(ctx context.Context, _ string, _ *openfgav1.TupleKey, _ storage.ReadUserTupleOptions) (*openfgav1.Tuple, error) {
+	<-ctx.Done()
+	return nil, ctx.Err()
+}
+
+func TestCheckQueryThrottledByConcurrencyLimit(t *testing.T) {
+	ds := memory.New()
+	t.Cleanup(ds.Close)
+
+	model := testutils.MustTransformDSLToProtoWithID(`
+model
+	schema 1.1
+type user
+type doc
+	relations
+		define editor: [user]
+		define viewer: [user] or editor`)
+	ts, err := typesystem.New(model)
+	require.NoError(t, err)
+
+	// the branches of the union wait for the only slot of the breadth limit
+	checkResolver, checkResolverCloser, err := graph.NewOrderedCheckResolvers(
+		graph.WithLocalCheckerOpts(graph.WithResolveNodeBreadthLimit(1)),
+	).Build()
+	require.NoError(t, err)
+	t.Cleanup(checkResolverCloser)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	_, _, err = NewCheckCommand(&blockingTupleReader{ds}, checkResolver, ts).Execute(ctx, &CheckCommandParams{
+		StoreID:  ulid.Make().String(),
+		TupleKey: tuple.NewCheckRequestTupleKey("doc:1", "viewer", "user:anne"),
+	})
+
+	var throttledError *ThrottledError
+	require.ErrorAs(t, err, &throttledError)
+	require.Equal(t, graph.ResolveNodeBreadthLimitName, throttledError.Limit)
+	require.Equal(t, concurrencyLimitRetryAfter, throttledError.RetryAfter)
+
+	serverErr := CheckCommandErrorToServerError(err)
+	require.ErrorIs(t, serverErr, serverErrors.ErrThrottledTimeout)
+	require.Contains(t, status.Convert(serverErr).Message(), graph.ResolveNodeBreadthLimitName)
+}
+
+func TestCheckQueryRejectsUntypedUsers(t *testing.T) {
+	mockController := gomock.NewController(t)
+	defer mockController.Finish()
+	mockDatastore := mockstorage.NewMockOpenFGADatastore(mockController)
+
+	// the untyped user is rejected before it is resolved, so it is never cached apart from the typed one
+	mockCheckResolver := graph.NewMockCheckResolver(mockController)
+	mockCheckResolver.EXPECT().ResolveCheck(gomock.Any(), gomock.Any()).Times(0)
+
+	model := testutils.MustTransformDSLToProtoWithID(`
+model
+	schema 1.1
+type user
+type doc
+	relations
+		define viewer: [user]`)
+	ts, err := typesystem.NewAndValidate(context.Background(), model)
+	require.NoError(t, err)
+
+	_, _, err = NewCheckCommand(mockDatastore, mockCheckResolver, ts).Execute(context.Background(), &CheckCommandParams{
+		StoreID:  ulid.Make().String(),
+		TupleKey: tuple.NewCheckRequestTupleKey("doc:1", "viewer", "anne"),
+	})
+	require.ErrorContains(t, err, "the 'user' field must be an object")
+}
+
+func TestCheckQueryExecuteBatch(t *testing.T) {
+	ctx := context.Background()
+	ds := memory.New()
+	t.Cleanup(ds.Close)
+
+	storeID := ulid.Make().String()
+	model := testutils.MustTransformDSLToProtoWithID(`
+model
+	schema 1.1
+type user
+type doc
+	relations
+		define viewer: [user]`)
+	require.NoError(t, ds.WriteAuthorizationModel(ctx, storeID, model))
+	require.NoError(t, ds.Write(ctx, storeID, nil, []*openfgav1.TupleKey{
+		tuple.NewTupleKey("doc:1", "viewer", "user:anne"),
+	}))
+
+	ts, err := typesystem.NewAndValidate(ctx, model)
+	require.NoError(t, err)
+
+	checkResolver, checkResolverCloser, err := graph.NewOrderedCheckResolvers().Build()
+	require.NoError(t, err)
+	t.Cleanup(checkResolverCloser)
+
+	resps, metadata, err := NewCheckCommand(ds, checkResolver, ts).ExecuteBatch(ctx, &CheckCommandParams{
+		StoreID: storeID,
+		ContextualTuples: &openfgav1.ContextualTupleKeys{
+			TupleKeys: []*openfgav1.TupleKey{tuple.NewTupleKey("doc:3", "viewer", "user:anne")},
+		},
+	}, []*openfgav1.CheckRequestTupleKey{
+		tuple.NewCheckRequestTupleKey("doc:1", "viewer", "user:anne"),
+		tuple.NewCheckRequestTupleKey("doc:2", "viewer", "user:anne"),
+		tuple.NewCheckRequestTupleKey("doc:1", "invalid", "user:anne"),
+		tuple.NewCheckRequestTupleKey("doc:3", "viewer", "user:anne"),
+	}, 2)
+
+	var resolveChecksErr *graph.ResolveChecksError
+	require.ErrorAs(t, err, &resolveChecksErr)
+	require.Len(t, resolveChecksErr.Errs, 4)
+	for i, err := range resolveChecksErr.Errs {
+		if i == 2 {
+			require.ErrorContains(t, err, "relation 'doc#invalid' not found")
+			continue
+		}
+		require.NoError(t, err)
+	}
+
+	require.Len(t, resps, 4)
+	require.True(t, resps[0].GetAllowed())
+	require.False(t, resps[1].GetAllowed())
+	require.Nil(t, resps[2])
+	require.True(t, resps[3].GetAllowed())
+
+	require.NotNil(t, metadata)
+	require.Positive(t, metadata.DatastoreQueryCount)
+	require.False(t, metadata.WasThrottled)
+}
+
+func TestCheckQueryWithScoring(t *testing.T) {
+	ctx := context.Background()
+	ds := memory.New()
+	t.Cleanup(ds.Close)
+
+	storeID := ulid.Make().String()
+	model := testutils.MustTransformDSLToProtoWithID(`
+model
+	schema 1.1
+type user
+type group
+	relations
+		define member: [user]
+type folder
+	relations
+		define viewer: [user]
+type doc
+	relations
+		define parent: [folder]
+		define restricted: [user]
+		define viewer: [user, user:*, group#member] or viewer from parent
+		define can_view: viewer but not restricted`)
+	require.NoError(t, ds.WriteAuthorizationModel(ctx, storeID, model))
+	require.NoError(t, ds.Write(ctx, storeID, nil, []*openfgav1.TupleKey{
+		tuple.NewTupleKey("doc:1", "viewer", "user:*"),
+		tuple.NewTupleKey("doc:1", "viewer", "user:anne"),
+		tuple.NewTupleKey("doc:1", "viewer", "group:eng#member"),
+		tuple.NewTupleKey("group:eng", "member", "user:bob"),
+		tuple.NewTupleKey("doc:1", "parent", "folder:x"),
+		tuple.NewTupleKey("folder:x", "viewer", "user:carl"),
+		tuple.NewTupleKey("group:eng", "member", "user:carl"),
+	}))
+
+	ts, err := typesystem.NewAndValidate(ctx, model)
+	require.NoError(t, err)
+
+	checkResolver, checkResolverCloser, err := graph.NewOrderedCheckResolvers(
+		graph.WithCachedCheckResolverOpts(true),
+	).Build()
+	require.NoError(t, err)
+	t.Cleanup(checkResolverCloser)
+
+	check := func(relation, user string, scoring *graph.CheckScoring) (*graph.ResolveCheckResponse, error) {
+		resp, _, err := NewCheckCommand(ds, checkResolver, ts).Execute(ctx, &CheckCommandParams{
+			StoreID:  storeID,
+			TupleKey: tuple.NewCheckRequestTupleKey("doc:1", relation, user),
+			Scoring:  scoring,
+		})
+		return resp, err
+	}
+
+	t.Run("unscored_checks_have_no_score", func(t *testing.T) {
+		resp, err := check("viewer", "user:anne", nil)
+		require.NoError(t, err)
+		require.True(t, resp.GetAllowed())
+		require.Zero(t, resp.GetScore())
+	})
+
+	t.Run("direct_grant_scores_higher_than_wildcard_grant", func(t *testing.T) {
+		direct, err := check("viewer", "user:anne", &graph.DefaultCheckScoring)
+		require.NoError(t, err)
+		require.True(t, direct.GetAllowed())
+		require.InDelta(t, graph.DefaultCheckScoring.DirectScore, direct.GetScore(), 1e-9)
+
+		wildcard, err := check("viewer", "user:dave", &graph.DefaultCheckScoring)
+		require.NoError(t, err)
+		require.True(t, wildcard.GetAllowed())
+		require.InDelta(t, graph.DefaultCheckScoring.WildcardScore, wildcard.GetScore(), 1e-9)
+
+		require.Greater(t, direct.GetScore(), wildcard.GetScore())
+	})
+
+	t.Run("each_hop_reduces_the_score", func(t *testing.T) {
+		resp, err := check("viewer", "user:bob", &graph.DefaultCheckScoring)
+		require.NoError(t, err)
+		require.True(t, resp.GetAllowed())
+		require.InDelta(t, 0.5, resp.GetScore(), 1e-9)
+	})
+
+	t.Run("highest_scoring_path_wins", func(t *testing.T) {
+		scoring := &graph.CheckScoring{DirectScore: 1, WildcardScore: 0.1, HopDecay: 0.8}
+		// carl is related through the wildcard, a group and the parent folder
+		resp, err := check("viewer", "user:carl", scoring)
+		require.NoError(t, err)
+		require.True(t, resp.GetAllowed())
+		require.InDelta(t, 0.8, resp.GetScore(), 1e-9)
+	})
+
+	t.Run("exclusion_keeps_the_base_score", func(t *testing.T) {
+		resp, err := check("can_view", "user:bob", &graph.DefaultCheckScoring)
+		require.NoError(t, err)
+		require.True(t, resp.GetAllowed())
+		require.InDelta(t, 0.5, resp.GetScore(), 1e-9)
+	})
+
+	t.Run("invalid_rubric", func(t *testing.T) {
+		_, err := check("viewer", "user:anne", &graph.CheckScoring{DirectScore: 1, HopDecay: 2})
+		require.ErrorIs(t, err, graph.ErrInvalidCheckScoring)
+	})
+}
+
 func TestCheckCommandErrorToServerError(t *testing.T) {
 	testcases := map[string]struct {
 		inputError    error
@@ -284,6 +695,10 @@ func TestCheckCommandErrorToServerError(t *testing.T) {
 			inputError:    ofga_errors.ErrUnknown,
 			expectedError: ofga_errors.ErrUnknown,
 		},
+		`8`: {
+			inputError:    graph.ErrRewriteDepthExceeded,
+			expectedError: serverErrors.ErrAuthorizationModelResolutionTooDeep,
+		},
 	}
 
 	for name, testCase := range testcases {
@@ -292,4 +707,14 @@ func TestCheckCommandErrorToServerError(t *testing.T) {
 			require.ErrorIs(t, actualError, testCase.expectedError)
 		})
 	}
+
+	t.Run("throttled", func(t *testing.T) {
+		actualError := CheckCommandErrorToServerError(&ThrottledError{Cause: context.DeadlineExceeded, Limit: DispatchThrottlingLimit, RetryAfter: 2 * time.Second})
+
+		var throttledError *serverErrors.ThrottledError
+		require.ErrorAs(t, actualError, &throttledError)
+		require.Equal(t, DispatchThrottlingLimit, throttledError.Limit)
+		require.Equal(t, 2*time.Second, throttledError.RetryAfter)
+		require.Equal(t, status.Code(serverErrors.ErrThrottledTimeout), status.Code(actualError))
+	})
 }
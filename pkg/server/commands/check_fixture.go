@@ -0,0 +1,287 @@
+package commands
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/oklog/ulid/v2"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/structpb"
+
+	openfgav1 "github.com/openfga/api/proto/openfga/v1"
+
+	"github.com/openfga/openfga/internal/graph"
+	"github.com/openfga/openfga/pkg/storage"
+	"github.com/openfga/openfga/pkg/storage/memory"
+	"github.com/openfga/openfga/pkg/typesystem"
+)
+
+// CheckFixtureVersion is the version of the serialized CheckFixture format.
+const CheckFixtureVersion = "1"
+
+// ErrCheckFixtureDrift is returned by ReplayCheckFixture when re-resolving a fixture yields
+// a decision different from the one that was captured.
+var ErrCheckFixtureDrift = errors.New("check decision drifted from captured fixture")
+
+// CheckFixture is a self-contained snapshot of a Check request and its resolved decision.
+// It holds everything needed to re-resolve the request deterministically: the authorization
+// model, every tuple stored in the store at capture time, and the request inputs.
+type CheckFixture struct {
+	StoreID          string
+	Model            *openfgav1.AuthorizationModel
+	Tuples           []*openfgav1.TupleKey
+	TupleKey         *openfgav1.CheckRequestTupleKey
+	ContextualTuples []*openfgav1.TupleKey
+	Context          *structpb.Struct
+	Allowed          bool
+}
+
+// checkFixtureJSON is the serialized form of a CheckFixture. Protobuf messages are encoded
+// with protojson so that the fixture is stable across versions.
+type checkFixtureJSON struct {
+	Version          string            `json:"version"`
+	StoreID          string            `json:"store_id"`
+	Model            json.RawMessage   `json:"model"`
+	Tuples           []json.RawMessage `json:"tuples,omitempty"`
+	TupleKey         json.RawMessage   `json:"tuple_key"`
+	ContextualTuples []json.RawMessage `json:"contextual_tuples,omitempty"`
+	Context          json.RawMessage   `json:"context,omitempty"`
+	Allowed          bool              `json:"allowed"`
+}
+
+// MarshalJSON implements json.Marshaler.
+func (f *CheckFixture) MarshalJSON() ([]byte, error) {
+	model, err := protojson.Marshal(f.Model)
+	if err != nil {
+		return nil, err
+	}
+
+	tk, err := protojson.Marshal(f.TupleKey)
+	if err != nil {
+		return nil, err
+	}
+
+	tuples, err := marshalTupleKeys(f.Tuples)
+	if err != nil {
+		return nil, err
+	}
+
+	contextualTuples, err := marshalTupleKeys(f.ContextualTuples)
+	if err != nil {
+		return nil, err
+	}
+
+	var reqContext json.RawMessage
+	if f.Context != nil {
+		reqContext, err = protojson.Marshal(f.Context)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return json.Marshal(checkFixtureJSON{
+		Version:          CheckFixtureVersion,
+		StoreID:          f.StoreID,
+		Model:            model,
+		Tuples:           tuples,
+		TupleKey:         tk,
+		ContextualTuples: contextualTuples,
+		Context:          reqContext,
+		Allowed:          f.Allowed,
+	})
+}
+
+// UnmarshalJSON implements json.Unmarshaler.
+func (f *CheckFixture) UnmarshalJSON(data []byte) error {
+	var raw checkFixtureJSON
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+
+	if raw.Version != CheckFixtureVersion {
+		return fmt.Errorf("unsupported check fixture version '%s'", raw.Version)
+	}
+
+	model := &openfgav1.AuthorizationModel{}
+	if err := protojson.Unmarshal(raw.Model, model); err != nil {
+		return err
+	}
+
+	tk := &openfgav1.CheckRequestTupleKey{}
+	if err := protojson.Unmarshal(raw.TupleKey, tk); err != nil {
+		return err
+	}
+
+	tuples, err := unmarshalTupleKeys(raw.Tuples)
+	if err != nil {
+		return err
+	}
+
+	contextualTuples, err := unmarshalTupleKeys(raw.ContextualTuples)
+	if err != nil {
+		return err
+	}
+
+	var reqContext *structpb.Struct
+	if len(raw.Context) > 0 {
+		reqContext = &structpb.Struct{}
+		if err := protojson.Unmarshal(raw.Context, reqContext); err != nil {
+			return err
+		}
+	}
+
+	*f = CheckFixture{
+		StoreID:          raw.StoreID,
+		Model:            model,
+		Tuples:           tuples,
+		TupleKey:         tk,
+		ContextualTuples: contextualTuples,
+		Context:          reqContext,
+		Allowed:          raw.Allowed,
+	}
+
+	return nil
+}
+
+func marshalTupleKeys(tks []*openfgav1.TupleKey) ([]json.RawMessage, error) {
+	res := make([]json.RawMessage, 0, len(tks))
+	for _, tk := range tks {
+		b, err := protojson.Marshal(tk)
+		if err != nil {
+			return nil, err
+		}
+		res = append(res, b)
+	}
+	return res, nil
+}
+
+func unmarshalTupleKeys(raw []json.RawMessage) ([]*openfgav1.TupleKey, error) {
+	res := make([]*openfgav1.TupleKey, 0, len(raw))
+	for _, b := range raw {
+		tk := &openfgav1.TupleKey{}
+		if err := protojson.Unmarshal(b, tk); err != nil {
+			return nil, err
+		}
+		res = append(res, tk)
+	}
+	return res, nil
+}
+
+// CaptureCheckFixture resolves the Check described by params against the given datastore and
+// records the inputs, a snapshot of every tuple in the store, and the resolved decision into a
+// CheckFixture. The Check always uses HIGHER_CONSISTENCY so that the captured decision matches
+// the captured tuples.
+func CaptureCheckFixture(
+	ctx context.Context,
+	ds storage.OpenFGADatastore,
+	checkResolver graph.CheckResolver,
+	typesys *typesystem.TypeSystem,
+	params *CheckCommandParams,
+) (*CheckFixture, error) {
+	model, err := ds.ReadAuthorizationModel(ctx, params.StoreID, typesys.GetAuthorizationModelID())
+	if err != nil {
+		return nil, err
+	}
+
+	iter, err := ds.Read(ctx, params.StoreID, nil, storage.ReadOptions{
+		Consistency: storage.ConsistencyOptions{Preference: openfgav1.ConsistencyPreference_HIGHER_CONSISTENCY},
+	})
+	if err != nil {
+		return nil, err
+	}
+	defer iter.Stop()
+
+	var tuples []*openfgav1.TupleKey
+	for {
+		t, err := iter.Next(ctx)
+		if err != nil {
+			if errors.Is(err, storage.ErrIteratorDone) {
+				break
+			}
+			return nil, err
+		}
+		tuples = append(tuples, t.GetKey())
+	}
+
+	resp, _, err := NewCheckCommand(ds, checkResolver, typesys).Execute(ctx, &CheckCommandParams{
+		StoreID:          params.StoreID,
+		TupleKey:         params.TupleKey,
+		ContextualTuples: params.ContextualTuples,
+		Context:          params.Context,
+		Consistency:      openfgav1.ConsistencyPreference_HIGHER_CONSISTENCY,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &CheckFixture{
+		StoreID:          params.StoreID,
+		Model:            model,
+		Tuples:           tuples,
+		TupleKey:         params.TupleKey,
+		ContextualTuples: params.ContextualTuples.GetTupleKeys(),
+		Context:          params.Context,
+		Allowed:          resp.GetAllowed(),
+	}, nil
+}
+
+// ReplayCheckFixture reconstructs an in-memory datastore from the fixture snapshot and
+// re-resolves the captured Check request. It returns the new response, and ErrCheckFixtureDrift
+// if the decision differs from the one recorded in the fixture.
+func ReplayCheckFixture(ctx context.Context, fixture *CheckFixture) (*graph.ResolveCheckResponse, error) {
+	ds := memory.New()
+	defer ds.Close()
+
+	storeID := fixture.StoreID
+	if storeID == "" {
+		storeID = ulid.Make().String()
+	}
+
+	model := proto.Clone(fixture.Model).(*openfgav1.AuthorizationModel)
+	if model.GetId() == "" {
+		model.Id = ulid.Make().String()
+	}
+
+	if err := ds.WriteAuthorizationModel(ctx, storeID, model); err != nil {
+		return nil, err
+	}
+
+	maxTuples := ds.MaxTuplesPerWrite()
+	for i := 0; i < len(fixture.Tuples); i += maxTuples {
+		end := min(i+maxTuples, len(fixture.Tuples))
+		if err := ds.Write(ctx, storeID, nil, fixture.Tuples[i:end]); err != nil {
+			return nil, err
+		}
+	}
+
+	typesys, err := typesystem.NewAndValidate(ctx, model)
+	if err != nil {
+		return nil, err
+	}
+
+	checkResolver, checkResolverCloser, err := graph.NewOrderedCheckResolvers().Build()
+	if err != nil {
+		return nil, err
+	}
+	defer checkResolverCloser()
+
+	resp, _, err := NewCheckCommand(ds, checkResolver, typesys).Execute(ctx, &CheckCommandParams{
+		StoreID:          storeID,
+		TupleKey:         fixture.TupleKey,
+		ContextualTuples: &openfgav1.ContextualTupleKeys{TupleKeys: fixture.ContextualTuples},
+		Context:          fixture.Context,
+		Consistency:      openfgav1.ConsistencyPreference_HIGHER_CONSISTENCY,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.GetAllowed() != fixture.Allowed {
+		return resp, fmt.Errorf("%w: expected allowed=%t, got allowed=%t", ErrCheckFixtureDrift, fixture.Allowed, resp.GetAllowed())
+	}
+
+	return resp, nil
+}
@@ -0,0 +1,83 @@
+package commands
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/oklog/ulid/v2"
+	"github.com/stretchr/testify/require"
+
+	openfgav1 "github.com/openfga/api/proto/openfga/v1"
+
+	"github.com/openfga/openfga/internal/graph"
+	"github.com/openfga/openfga/pkg/storage/memory"
+	"github.com/openfga/openfga/pkg/testutils"
+	"github.com/openfga/openfga/pkg/tuple"
+	"github.com/openfga/openfga/pkg/typesystem"
+)
+
+func TestCheckFixtureCaptureAndReplay(t *testing.T) {
+	ctx := context.Background()
+	ds := memory.New()
+	t.Cleanup(ds.Close)
+
+	storeID := ulid.Make().String()
+	model := testutils.MustTransformDSLToProtoWithID(`
+model
+	schema 1.1
+type user
+type group
+	relations
+		define member: [user]
+type doc
+	relations
+		define viewer: [user, group#member]`)
+	require.NoError(t, ds.WriteAuthorizationModel(ctx, storeID, model))
+	require.NoError(t, ds.Write(ctx, storeID, nil, []*openfgav1.TupleKey{
+		tuple.NewTupleKey("group:eng", "member", "user:anne"),
+		tuple.NewTupleKey("doc:1", "viewer", "group:eng#member"),
+	}))
+
+	ts, err := typesystem.NewAndValidate(ctx, model)
+	require.NoError(t, err)
+
+	checkResolver, checkResolverCloser, err := graph.NewOrderedCheckResolvers().Build()
+	require.NoError(t, err)
+	t.Cleanup(checkResolverCloser)
+
+	fixture, err := CaptureCheckFixture(ctx, ds, checkResolver, ts, &CheckCommandParams{
+		StoreID:  storeID,
+		TupleKey: tuple.NewCheckRequestTupleKey("doc:1", "viewer", "user:anne"),
+	})
+	require.NoError(t, err)
+	require.True(t, fixture.Allowed)
+	require.Len(t, fixture.Tuples, 2)
+
+	t.Run("replay_after_json_roundtrip", func(t *testing.T) {
+		b, err := json.Marshal(fixture)
+		require.NoError(t, err)
+
+		var decoded CheckFixture
+		require.NoError(t, json.Unmarshal(b, &decoded))
+
+		resp, err := ReplayCheckFixture(ctx, &decoded)
+		require.NoError(t, err)
+		require.True(t, resp.GetAllowed())
+	})
+
+	t.Run("replay_detects_drift", func(t *testing.T) {
+		drifted := *fixture
+		drifted.Allowed = false
+
+		resp, err := ReplayCheckFixture(ctx, &drifted)
+		require.ErrorIs(t, err, ErrCheckFixtureDrift)
+		require.True(t, resp.GetAllowed())
+	})
+
+	t.Run("rejects_unknown_version", func(t *testing.T) {
+		var decoded CheckFixture
+		err := json.Unmarshal([]byte(`{"version":"0"}`), &decoded)
+		require.ErrorContains(t, err, "unsupported check fixture version")
+	})
+}
@@ -3,6 +3,7 @@ package commands
 import (
 	"context"
 	"errors"
+	"time"
 
 	"github.com/openfga/openfga/internal/condition"
 	"github.com/openfga/openfga/internal/graph"
@@ -34,8 +35,23 @@ func (e *InvalidRelationError) Error() string {
 	return e.Unwrap().Error()
 }
 
+// The limits that can throttle a Check, see ThrottledError. A Check can also be throttled by the concurrency
+// limits of its resolution, see graph.ResolveCheckRequestMetadata.ThrottledLimit.
+const (
+	DispatchThrottlingLimit  = "dispatch_throttling"
+	DatastoreThrottlingLimit = "datastore_throttling"
+)
+
+// concurrencyLimitRetryAfter is the delay suggested to the client before retrying a Check throttled by the
+// concurrency limits of its resolution, which aren't released on a schedule.
+const concurrencyLimitRetryAfter = time.Second
+
 type ThrottledError struct {
 	Cause error
+	// Limit is the name of the limit that throttled the request.
+	Limit string
+	// RetryAfter is the delay of the throttler of the limit, which is suggested to the client before retrying.
+	RetryAfter time.Duration
 }
 
 func (e *ThrottledError) Unwrap() error {
@@ -64,13 +80,25 @@ func CheckCommandErrorToServerError(err error) error {
 		return serverErrors.ErrAuthorizationModelResolutionTooComplex
 	}
 
+	if errors.Is(err, graph.ErrRewriteDepthExceeded) {
+		return serverErrors.ErrAuthorizationModelResolutionTooDeep
+	}
+
+	if errors.Is(err, graph.ErrUsersetFanoutExceeded) {
+		return serverErrors.ErrUsersetFanoutExceeded
+	}
+
 	if errors.Is(err, condition.ErrEvaluationFailed) {
 		return serverErrors.ValidationError(err)
 	}
 
+	if errors.Is(err, graph.ErrInvalidCheckScoring) {
+		return serverErrors.ValidationError(err)
+	}
+
 	var throttledError *ThrottledError
 	if errors.As(err, &throttledError) {
-		return serverErrors.ErrThrottledTimeout
+		return &serverErrors.ThrottledError{Limit: throttledError.Limit, RetryAfter: throttledError.RetryAfter}
 	}
 
 	if errors.Is(err, context.DeadlineExceeded) {
@@ -5,6 +5,7 @@ import (
 	"errors"
 	"fmt"
 	"math"
+	"slices"
 	"sync/atomic"
 	"time"
 
@@ -49,6 +50,12 @@ var (
 		Name:      "list_objects_no_further_eval_required_count",
 		Help:      "Number of objects in a ListObjects call that needed to issue a Check call to determine a final result",
 	})
+
+	contextualTuplesOnlyCounter = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: build.ProjectName,
+		Name:      "list_objects_contextual_tuples_only_count",
+		Help:      "Number of ListObjects calls that were answered from the contextual tuples without reading the datastore",
+	})
 )
 
 type ListObjectsQuery struct {
@@ -60,6 +67,15 @@ type ListObjectsQuery struct {
 	resolveNodeBreadthLimit uint32
 	maxConcurrentReads      uint32
 
+	// the maximum number of objects streamed by ExecuteStreamed, 0 streams every object
+	streamedListObjectsMaxResults uint32
+
+	// closed to stop streaming the objects of ExecuteStreamed, e.g. when the server shuts down
+	interrupt <-chan struct{}
+
+	// the order of the objects returned by Execute and ExecuteStreamed
+	orderBy serverconfig.ListObjectsOrder
+
 	dispatchThrottlerConfig threshold.Config
 
 	datastoreThrottleThreshold int
@@ -79,8 +95,8 @@ type ListObjectsResolver interface {
 	Execute(ctx context.Context, req *openfgav1.ListObjectsRequest) (*ListObjectsResponse, error)
 
 	// ExecuteStreamed executes the ListObjectsQuery, returning a stream of object IDs.
-	// It ignores the value of q.listObjectsMaxResults and returns all available results
-	// until q.listObjectsDeadline is hit.
+	// It ignores the value of q.listObjectsMaxResults and returns results up to a maximum of
+	// q.streamedListObjectsMaxResults (if non-zero) or until q.listObjectsDeadline is hit.
 	ExecuteStreamed(ctx context.Context, req *openfgav1.StreamedListObjectsRequest, srv openfgav1.OpenFGAService_StreamedListObjectsServer) (*ListObjectsResolutionMetadata, error)
 }
 
@@ -104,10 +120,64 @@ type ListObjectsResolutionMetadata struct {
 	// For queries with Infinite weight, the weighted graph implementation falls back
 	// to the original code, making any comparison useless.
 	ShouldRunShadowQuery atomic.Bool
+
+	// DeadlineExceeded indicates whether the deadline was hit before every candidate object was evaluated
+	DeadlineExceeded atomic.Bool
+
+	// MaxResultsReached indicates whether the objects found reached the maximum number of results, after which
+	// the remaining candidate objects are not evaluated
+	MaxResultsReached atomic.Bool
+
+	// Interrupted indicates whether streaming the objects was stopped before every candidate object was evaluated
+	Interrupted atomic.Bool
+
+	// ObjectsScanned is the number of candidate objects that were evaluated, i.e. yielded by reverse_expand
+	// or the contextual tuples
+	ObjectsScanned atomic.Uint32
+
+	// ChecksIssued is the number of Checks issued to evaluate the candidate objects that required further eval
+	ChecksIssued atomic.Uint32
+
+	// ObjectsReturned is the number of objects returned, or streamed, to the client
+	ObjectsReturned atomic.Uint32
+}
+
+// ListObjectsTruncation is the reason the objects of a ListObjects request may be incomplete.
+type ListObjectsTruncation string
+
+const (
+	// ListObjectsNotTruncated means every candidate object was evaluated.
+	ListObjectsNotTruncated ListObjectsTruncation = ""
+	// ListObjectsTruncatedByMaxResults means the objects reached the maximum number of results, so there may be
+	// more objects than were returned.
+	ListObjectsTruncatedByMaxResults ListObjectsTruncation = "max_results"
+	// ListObjectsTruncatedByDeadline means the deadline was hit before every candidate object was evaluated.
+	ListObjectsTruncatedByDeadline ListObjectsTruncation = "deadline"
+	// ListObjectsTruncatedByInterrupt means the streaming of the objects was interrupted, e.g. because the
+	// server is shutting down, before every candidate object was evaluated.
+	ListObjectsTruncatedByInterrupt ListObjectsTruncation = "interrupted"
+)
+
+// Truncation returns the reason the objects of the request may be incomplete. Reaching the maximum number of
+// results takes precedence over the deadline.
+func (m *ListObjectsResolutionMetadata) Truncation() ListObjectsTruncation {
+	switch {
+	case m.MaxResultsReached.Load():
+		return ListObjectsTruncatedByMaxResults
+	case m.DeadlineExceeded.Load():
+		return ListObjectsTruncatedByDeadline
+	case m.Interrupted.Load():
+		return ListObjectsTruncatedByInterrupt
+	default:
+		return ListObjectsNotTruncated
+	}
 }
 
 type ListObjectsResponse struct {
-	Objects            []string
+	Objects []string
+	// Truncated is whether the objects may be incomplete, in which case TruncatedBy is the reason.
+	Truncated          bool
+	TruncatedBy        ListObjectsTruncation
 	ResolutionMetadata ListObjectsResolutionMetadata
 }
 
@@ -131,6 +201,28 @@ func WithListObjectsMaxResults(maxResults uint32) ListObjectsQueryOption {
 	}
 }
 
+// WithStreamedListObjectsMaxResults see server.WithStreamedListObjectsMaxResults.
+func WithStreamedListObjectsMaxResults(maxResults uint32) ListObjectsQueryOption {
+	return func(d *ListObjectsQuery) {
+		d.streamedListObjectsMaxResults = maxResults
+	}
+}
+
+// WithStreamedListObjectsInterrupt stops streaming the objects of ExecuteStreamed once the channel is closed.
+// The objects streamed so far are kept, and the response is truncated with ListObjectsTruncatedByInterrupt.
+func WithStreamedListObjectsInterrupt(interrupt <-chan struct{}) ListObjectsQueryOption {
+	return func(d *ListObjectsQuery) {
+		d.interrupt = interrupt
+	}
+}
+
+// WithListObjectsOrderBy see server.WithListObjectsOrderBy.
+func WithListObjectsOrderBy(order serverconfig.ListObjectsOrder) ListObjectsQueryOption {
+	return func(d *ListObjectsQuery) {
+		d.orderBy = order
+	}
+}
+
 // WithResolveNodeLimit see server.WithResolveNodeLimit.
 func WithResolveNodeLimit(limit uint32) ListObjectsQueryOption {
 	return func(d *ListObjectsQuery) {
@@ -292,10 +384,33 @@ func (q *ListObjectsQuery) evaluate(
 		return serverErrors.HandleError("", err)
 	}
 
+	if err := validation.ValidateUserIsTyped(typesys, req.GetUser(), targetObjectType, targetRelation); err != nil {
+		return serverErrors.ValidationError(fmt.Errorf("invalid 'user' value: %s", err))
+	}
+
 	if err := validation.ValidateUser(typesys, req.GetUser()); err != nil {
 		return serverErrors.ValidationError(fmt.Errorf("invalid 'user' value: %s", err))
 	}
 
+	// the contextual tuples of the request, e.g. hypothetical relationships, may suffice to determine its objects
+	if relations := directRelations(typesys, targetObjectType, targetRelation, req.GetUser()); relations != nil && len(req.GetContextualTuples().GetTupleKeys()) > 0 {
+		if objects := contextualTuplesObjects(req, relations, maxResults); objects != nil {
+			contextualTuplesOnlyCounter.Inc()
+			resolutionMetadata.ObjectsScanned.Add(uint32(len(objects)))
+			go func() {
+				objectsFound := atomic.Uint32{}
+				for _, object := range objects {
+					trySendObject(ctx, object, &objectsFound, maxResults, resultsChan)
+				}
+				close(resultsChan)
+			}()
+			return nil
+		}
+
+		go q.evaluateDirectRelations(ctx, typesys, req, relations, resultsChan, maxResults, resolutionMetadata)
+		return nil
+	}
+
 	handler := func() {
 		userObj, userRel := tuple.SplitObjectRelation(req.GetUser())
 		userObjType, userObjID := tuple.SplitObject(userObj)
@@ -324,21 +439,7 @@ func (q *ListObjectsQuery) evaluate(
 		reverseExpandResultsChan := make(chan *reverseexpand.ReverseExpandResult, 1)
 		objectsFound := atomic.Uint32{}
 
-		ds := storagewrappers.NewRequestStorageWrapperWithCache(
-			q.datastore,
-			req.GetContextualTuples().GetTupleKeys(),
-			&storagewrappers.Operation{
-				Method:            apimethod.ListObjects,
-				Concurrency:       q.maxConcurrentReads,
-				ThrottleThreshold: q.datastoreThrottleThreshold,
-				ThrottleDuration:  q.datastoreThrottleDuration,
-			},
-			storagewrappers.DataResourceConfiguration{
-				Resources:      q.sharedDatastoreResources,
-				CacheSettings:  q.cacheSettings,
-				UseShadowCache: q.useShadowCache,
-			},
-		)
+		ds := q.requestDatastore(req)
 
 		reverseExpandQuery := reverseexpand.NewReverseExpandQuery(
 			ds,
@@ -381,65 +482,79 @@ func (q *ListObjectsQuery) evaluate(
 			return nil
 		})
 
+		// the candidates that require further eval and are found together are checked as one batch, so that the
+		// identical subproblems of their Checks are resolved once
+		var candidates []string
+		checkCandidates := func() {
+			if len(candidates) == 0 {
+				return
+			}
+			objects := candidates
+			candidates = nil
+			pool.Go(func(ctx context.Context) error {
+				return q.checkCandidates(ctx, typesys, req, objects, &objectsFound, maxResults, resultsChan, resolutionMetadata)
+			})
+		}
+
 	ConsumerReadLoop:
 		for {
-			select {
-			case <-reverseExpandDoneWithError:
-				cancel() // cancel any inflight work if e.g. model too complex
-				break ConsumerReadLoop
-			case <-ctx.Done():
-				cancel() // cancel any inflight work if e.g. deadline exceeded
-				break ConsumerReadLoop
-			case res, channelOpen := <-reverseExpandResultsChan:
-				if !channelOpen {
-					// don't cancel here. Reverse Expand has finished finding candidate object IDs
-					// but since we haven't collected "maxResults",
-					// we need to wait until all the inflight Checks finish in the hopes that
-					// we collect a few more object IDs.
-					// if we send a cancellation now, we might miss those.
-					break ConsumerReadLoop
+			if len(candidates) >= int(q.resolveNodeBreadthLimit) {
+				checkCandidates()
+			}
+
+			var (
+				res         *reverseexpand.ReverseExpandResult
+				channelOpen bool
+				received    bool
+			)
+			// a batch takes the candidates that are already found, and is checked once no other one is
+			if len(candidates) > 0 {
+				select {
+				case res, channelOpen = <-reverseExpandResultsChan:
+					received = true
+				default:
+					checkCandidates()
 				}
+			}
 
-				if (maxResults != 0) && objectsFound.Load() >= maxResults {
-					cancel() // cancel any inflight work if we already found enough results
+			if !received {
+				select {
+				case <-reverseExpandDoneWithError:
+					cancel() // cancel any inflight work if e.g. model too complex
+					break ConsumerReadLoop
+				case <-ctx.Done():
+					cancel() // cancel any inflight work if e.g. deadline exceeded
 					break ConsumerReadLoop
+				case res, channelOpen = <-reverseExpandResultsChan:
 				}
+			}
 
-				if res.ResultStatus == reverseexpand.NoFurtherEvalStatus {
-					noFurtherEvalRequiredCounter.Inc()
-					trySendObject(ctx, res.Object, &objectsFound, maxResults, resultsChan)
-					continue
-				}
+			if !channelOpen {
+				// don't cancel here. Reverse Expand has finished finding candidate object IDs
+				// but since we haven't collected "maxResults",
+				// we need to wait until all the inflight Checks finish in the hopes that
+				// we collect a few more object IDs.
+				// if we send a cancellation now, we might miss those.
+				checkCandidates()
+				break ConsumerReadLoop
+			}
+
+			if (maxResults != 0) && objectsFound.Load() >= maxResults {
+				cancel() // cancel any inflight work if we already found enough results
+				break ConsumerReadLoop
+			}
 
-				furtherEvalRequiredCounter.Inc()
-
-				pool.Go(func(ctx context.Context) error {
-					resp, checkRequestMetadata, err := NewCheckCommand(q.datastore, q.checkResolver, typesys,
-						WithCheckCommandLogger(q.logger),
-						WithCheckCommandMaxConcurrentReads(q.maxConcurrentReads),
-						WithCheckDatastoreThrottler(q.datastoreThrottleThreshold, q.datastoreThrottleDuration),
-					).
-						Execute(ctx, &CheckCommandParams{
-							StoreID:          req.GetStoreId(),
-							TupleKey:         tuple.NewCheckRequestTupleKey(res.Object, req.GetRelation(), req.GetUser()),
-							ContextualTuples: req.GetContextualTuples(),
-							Context:          req.GetContext(),
-							Consistency:      req.GetConsistency(),
-						})
-					if err != nil {
-						return err
-					}
-					resolutionMetadata.DatastoreQueryCount.Add(resp.GetResolutionMetadata().DatastoreQueryCount)
-					resolutionMetadata.DispatchCounter.Add(checkRequestMetadata.DispatchCounter.Load())
-					if !resolutionMetadata.WasThrottled.Load() && checkRequestMetadata.WasThrottled.Load() {
-						resolutionMetadata.WasThrottled.Store(true)
-					}
-					if resp.Allowed {
-						trySendObject(ctx, res.Object, &objectsFound, maxResults, resultsChan)
-					}
-					return nil
-				})
+			resolutionMetadata.ObjectsScanned.Add(1)
+
+			if res.ResultStatus == reverseexpand.NoFurtherEvalStatus {
+				noFurtherEvalRequiredCounter.Inc()
+				trySendObject(ctx, res.Object, &objectsFound, maxResults, resultsChan)
+				continue
 			}
+
+			furtherEvalRequiredCounter.Inc()
+			resolutionMetadata.ChecksIssued.Add(1)
+			candidates = append(candidates, res.Object)
 		}
 
 		err := pool.Wait()
@@ -447,7 +562,9 @@ func (q *ListObjectsQuery) evaluate(
 			if !errors.Is(err, context.DeadlineExceeded) && !errors.Is(err, context.Canceled) {
 				resultsChan <- ListObjectsResult{Err: err}
 			}
-			// TODO set header to indicate "deadline exceeded"
+		}
+		if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			resolutionMetadata.DeadlineExceeded.Store(true)
 		}
 		close(resultsChan)
 		dsMeta := ds.GetMetadata()
@@ -460,6 +577,252 @@ func (q *ListObjectsQuery) evaluate(
 	return nil
 }
 
+// checkCandidates checks whether the user of the request is related to the candidate objects with one batch of
+// Checks, see CheckQuery.ExecuteBatch, and sends the objects it is related to. The Checks go through the check
+// resolver of the query, with at most resolveNodeBreadthLimit in flight.
+func (q *ListObjectsQuery) checkCandidates(
+	ctx context.Context,
+	typesys *typesystem.TypeSystem,
+	req listObjectsRequest,
+	objects []string,
+	objectsFound *atomic.Uint32,
+	maxResults uint32,
+	resultsChan chan<- ListObjectsResult,
+	resolutionMetadata *ListObjectsResolutionMetadata,
+) error {
+	tupleKeys := make([]*openfgav1.CheckRequestTupleKey, 0, len(objects))
+	for _, object := range objects {
+		tupleKeys = append(tupleKeys, tuple.NewCheckRequestTupleKey(object, req.GetRelation(), req.GetUser()))
+	}
+
+	resps, checkBatchMetadata, err := NewCheckCommand(q.datastore, q.checkResolver, typesys,
+		WithCheckCommandLogger(q.logger),
+		WithCheckCommandMaxConcurrentReads(q.maxConcurrentReads),
+		WithCheckDatastoreThrottler(q.datastoreThrottleThreshold, q.datastoreThrottleDuration),
+	).
+		ExecuteBatch(ctx, &CheckCommandParams{
+			StoreID:          req.GetStoreId(),
+			ContextualTuples: req.GetContextualTuples(),
+			Context:          req.GetContext(),
+			Consistency:      req.GetConsistency(),
+		}, tupleKeys, int(q.resolveNodeBreadthLimit))
+
+	var resolveChecksErr *graph.ResolveChecksError
+	if err != nil && !errors.As(err, &resolveChecksErr) {
+		return err
+	}
+
+	resolutionMetadata.DatastoreQueryCount.Add(checkBatchMetadata.DatastoreQueryCount)
+	resolutionMetadata.DispatchCounter.Add(checkBatchMetadata.DispatchCount)
+	if checkBatchMetadata.WasThrottled {
+		resolutionMetadata.WasThrottled.Store(true)
+	}
+
+	for i, resp := range resps {
+		if resp.GetAllowed() {
+			trySendObject(ctx, objects[i], objectsFound, maxResults, resultsChan)
+		}
+	}
+
+	// the objects of the Checks that were resolved are sent before the first error ends the query
+	if resolveChecksErr != nil {
+		for _, err := range resolveChecksErr.Errs {
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// requestDatastore returns the datastore that the request reads, which includes its contextual tuples.
+func (q *ListObjectsQuery) requestDatastore(req listObjectsRequest) *storagewrappers.RequestStorageWrapper {
+	return storagewrappers.NewRequestStorageWrapperWithCache(
+		q.datastore,
+		req.GetContextualTuples().GetTupleKeys(),
+		&storagewrappers.Operation{
+			Method:            apimethod.ListObjects,
+			Concurrency:       q.maxConcurrentReads,
+			ThrottleThreshold: q.datastoreThrottleThreshold,
+			ThrottleDuration:  q.datastoreThrottleDuration,
+		},
+		storagewrappers.DataResourceConfiguration{
+			Resources:      q.sharedDatastoreResources,
+			CacheSettings:  q.cacheSettings,
+			UseShadowCache: q.useShadowCache,
+		},
+	)
+}
+
+// directRelations returns the relations of objectType that relate the user to relation, if the typesystem shows
+// that the user can only be related to relation by a direct relationship on one of them. That's the case when
+// relation is a union of relations of objectType that are directly assignable, unconditionally and to no usersets.
+// Otherwise, e.g. if the user may be related through a userset, a tuple to userset, an intersection or an
+// exclusion, nil is returned.
+func directRelations(typesys *typesystem.TypeSystem, objectType, relation, user string) []string {
+	if tuple.IsObjectRelation(user) {
+		return nil
+	}
+
+	var relations []string
+	visited := map[string]struct{}{relation: {}}
+
+	var reachable func(relation string, rewrite *openfgav1.Userset) bool
+	reachable = func(relation string, rewrite *openfgav1.Userset) bool {
+		switch rw := rewrite.GetUserset().(type) {
+		case *openfgav1.Userset_This:
+			directlyRelatedTypes, err := typesys.GetDirectlyRelatedUserTypes(objectType, relation)
+			if err != nil {
+				return false
+			}
+			for _, ref := range directlyRelatedTypes {
+				if ref.GetRelation() != "" || ref.GetCondition() != "" {
+					return false
+				}
+			}
+			relations = append(relations, relation)
+			return true
+		case *openfgav1.Userset_ComputedUserset:
+			computedRelation := rw.ComputedUserset.GetRelation()
+			if _, ok := visited[computedRelation]; ok {
+				return true
+			}
+			visited[computedRelation] = struct{}{}
+
+			rel, err := typesys.GetRelation(objectType, computedRelation)
+			if err != nil {
+				return false
+			}
+			return reachable(computedRelation, rel.GetRewrite())
+		case *openfgav1.Userset_Union:
+			for _, child := range rw.Union.GetChild() {
+				if !reachable(relation, child) {
+					return false
+				}
+			}
+			return true
+		default:
+			return false
+		}
+	}
+
+	rel, err := typesys.GetRelation(objectType, relation)
+	if err != nil || !reachable(relation, rel.GetRewrite()) {
+		return nil
+	}
+	return relations
+}
+
+// contextualTuplesObjects returns the objects of the request if the contextual tuples alone determine them,
+// so that they can be answered without reading the datastore. That's the case when the contextual tuples
+// relate at least maxResults objects directly to the user through the relations, see directRelations.
+// Otherwise, the objects of the stored tuples may be part of the answer, and nil is returned.
+func contextualTuplesObjects(req listObjectsRequest, relations []string, maxResults uint32) []string {
+	if maxResults == 0 || uint32(len(req.GetContextualTuples().GetTupleKeys())) < maxResults {
+		return nil
+	}
+
+	wildcard := tuple.TypedPublicWildcard(tuple.GetType(req.GetUser()))
+	seen := make(map[string]struct{}, maxResults)
+	objects := make([]string, 0, maxResults)
+	for _, t := range req.GetContextualTuples().GetTupleKeys() {
+		if (t.GetUser() != req.GetUser() && t.GetUser() != wildcard) || !slices.Contains(relations, t.GetRelation()) ||
+			tuple.GetType(t.GetObject()) != req.GetType() {
+			continue
+		}
+		if _, ok := seen[t.GetObject()]; ok {
+			continue
+		}
+		seen[t.GetObject()] = struct{}{}
+		objects = append(objects, t.GetObject())
+		if uint32(len(objects)) == maxResults {
+			return objects
+		}
+	}
+	return nil
+}
+
+// evaluateDirectRelations yields the objects that the stored and contextual tuples relate directly to the user
+// through the relations, see directRelations. As no other relationship can relate the user to the objects, it
+// reads the relationships of the user only, instead of reverse expanding the relation and checking the objects.
+// The resultsChan is always closed when it is done.
+func (q *ListObjectsQuery) evaluateDirectRelations(
+	ctx context.Context,
+	typesys *typesystem.TypeSystem,
+	req listObjectsRequest,
+	relations []string,
+	resultsChan chan<- ListObjectsResult,
+	maxResults uint32,
+	resolutionMetadata *ListObjectsResolutionMetadata,
+) {
+	ds := q.requestDatastore(req)
+	defer func() {
+		dsMeta := ds.GetMetadata()
+		resolutionMetadata.DatastoreQueryCount.Add(dsMeta.DatastoreQueryCount)
+		resolutionMetadata.WasThrottled.CompareAndSwap(false, dsMeta.WasThrottled)
+		if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			resolutionMetadata.DeadlineExceeded.Store(true)
+		}
+		close(resultsChan)
+	}()
+
+	userFilter := []*openfgav1.ObjectRelation{
+		{Object: tuple.TypedPublicWildcard(tuple.GetType(req.GetUser()))},
+		{Object: req.GetUser()},
+	}
+
+	objectsFound := atomic.Uint32{}
+	seen := make(map[string]struct{})
+	for _, relation := range relations {
+		iter, err := ds.ReadStartingWithUser(ctx, req.GetStoreId(), storage.ReadStartingWithUserFilter{
+			ObjectType: req.GetType(),
+			Relation:   relation,
+			UserFilter: userFilter,
+		}, storage.ReadStartingWithUserOptions{
+			Consistency: storage.ConsistencyOptions{
+				Preference: req.GetConsistency(),
+			},
+		})
+		if err != nil {
+			if !errors.Is(err, context.DeadlineExceeded) && !errors.Is(err, context.Canceled) {
+				concurrency.TrySendThroughChannel(ctx, ListObjectsResult{Err: err}, resultsChan)
+			}
+			return
+		}
+
+		// filter out invalid tuples yielded by the database iterator
+		filteredIter := storage.NewFilteredTupleKeyIterator(
+			storage.NewTupleKeyIteratorFromTupleIterator(iter),
+			validation.FilterInvalidTuples(typesys),
+		)
+		for {
+			tk, err := filteredIter.Next(ctx)
+			if err != nil {
+				filteredIter.Stop()
+				if errors.Is(err, storage.ErrIteratorDone) {
+					break
+				}
+				if !errors.Is(err, context.DeadlineExceeded) && !errors.Is(err, context.Canceled) {
+					concurrency.TrySendThroughChannel(ctx, ListObjectsResult{Err: err}, resultsChan)
+				}
+				return
+			}
+
+			if _, ok := seen[tk.GetObject()]; ok {
+				continue
+			}
+			seen[tk.GetObject()] = struct{}{}
+
+			resolutionMetadata.ObjectsScanned.Add(1)
+			trySendObject(ctx, tk.GetObject(), &objectsFound, maxResults, resultsChan)
+			if maxResults != 0 && objectsFound.Load() >= maxResults {
+				filteredIter.Stop()
+				return
+			}
+		}
+	}
+}
+
 func trySendObject(ctx context.Context, object string, objectsFound *atomic.Uint32, maxResults uint32, resultsChan chan<- ListObjectsResult) {
 	if maxResults != 0 {
 		if objectsFound.Add(1) > maxResults {
@@ -530,14 +893,97 @@ func (q *ListObjectsQuery) Execute(
 		return nil, errs
 	}
 
+	if q.orderBy == serverconfig.ListObjectsOrderByObjectID {
+		slices.Sort(listObjectsResponse.Objects)
+	}
+
+	listObjectsResponse.ResolutionMetadata.ObjectsReturned.Store(uint32(len(listObjectsResponse.Objects)))
+	if maxResults != 0 && len(listObjectsResponse.Objects) >= int(maxResults) {
+		listObjectsResponse.ResolutionMetadata.MaxResultsReached.Store(true)
+	}
+	listObjectsResponse.TruncatedBy = listObjectsResponse.ResolutionMetadata.Truncation()
+	listObjectsResponse.Truncated = listObjectsResponse.TruncatedBy != ListObjectsNotTruncated
+
 	return &listObjectsResponse, nil
 }
 
+// CountObjectsResponse is the number of objects that ListObjects would return. If Capped is true,
+// the count was stopped at the cap, so the actual number of objects is at least Count.
+type CountObjectsResponse struct {
+	Count              uint32
+	Capped             bool
+	ResolutionMetadata ListObjectsResolutionMetadata
+}
+
+// CountObjects counts the objects that the ListObjectsQuery would return without returning them. It ignores the
+// value of q.listObjectsMaxResults, the count is instead stopped at maxCount (if non-zero), and it
+// returns the count of the objects that were found until q.listObjectsDeadline is hit.
+func (q *ListObjectsQuery) CountObjects(
+	ctx context.Context,
+	req *openfgav1.ListObjectsRequest,
+	maxCount uint32,
+) (*CountObjectsResponse, error) {
+	resultsChan := make(chan ListObjectsResult, streamedBufferSize)
+
+	timeoutCtx := ctx
+	if q.listObjectsDeadline != 0 {
+		var cancel context.CancelFunc
+		timeoutCtx, cancel = context.WithTimeout(ctx, q.listObjectsDeadline)
+		defer cancel()
+	}
+
+	var countObjectsResponse CountObjectsResponse
+
+	err := q.evaluate(timeoutCtx, req, resultsChan, maxCount, &countObjectsResponse.ResolutionMetadata)
+	if err != nil {
+		return nil, err
+	}
+
+	var errs error
+
+	for result := range resultsChan {
+		if result.Err != nil {
+			if errors.Is(result.Err, graph.ErrResolutionDepthExceeded) {
+				return nil, serverErrors.ErrAuthorizationModelResolutionTooComplex
+			}
+
+			if errors.Is(result.Err, condition.ErrEvaluationFailed) {
+				errs = errors.Join(errs, result.Err)
+				continue
+			}
+
+			return nil, serverErrors.HandleError("", result.Err)
+		}
+
+		// the object itself is discarded, evaluate already dedupes the objects it yields
+		countObjectsResponse.Count++
+	}
+
+	countObjectsResponse.Capped = maxCount != 0 && countObjectsResponse.Count >= maxCount
+
+	if !countObjectsResponse.Capped && errs != nil {
+		return nil, errs
+	}
+
+	return &countObjectsResponse, nil
+}
+
 // ExecuteStreamed executes the ListObjectsQuery, returning a stream of object IDs.
-// It ignores the value of q.listObjectsMaxResults and returns all available results
-// until q.listObjectsDeadline is hit.
+// It ignores the value of q.listObjectsMaxResults and returns results up to a maximum of
+// q.streamedListObjectsMaxResults (if non-zero) or until q.listObjectsDeadline is hit.
+// The Truncation of the returned metadata tells whether the stream was cut off.
+// If the objects are ordered, they are buffered until the evaluation is done and then streamed in order,
+// and q.listObjectsMaxResults (if non-zero) bounds the buffer as well.
 func (q *ListObjectsQuery) ExecuteStreamed(ctx context.Context, req *openfgav1.StreamedListObjectsRequest, srv openfgav1.OpenFGAService_StreamedListObjectsServer) (*ListObjectsResolutionMetadata, error) {
+	ordered := q.orderBy == serverconfig.ListObjectsOrderByObjectID
+
 	maxResults := uint32(math.MaxUint32)
+	if q.streamedListObjectsMaxResults != 0 {
+		maxResults = q.streamedListObjectsMaxResults
+	}
+	if ordered && q.listObjectsMaxResults != 0 {
+		maxResults = min(maxResults, q.listObjectsMaxResults)
+	}
 	// make a buffered channel so that writer goroutines aren't blocked when attempting to send a result
 	resultsChan := make(chan ListObjectsResult, streamedBufferSize)
 
@@ -547,6 +993,8 @@ func (q *ListObjectsQuery) ExecuteStreamed(ctx context.Context, req *openfgav1.S
 		timeoutCtx, cancel = context.WithTimeout(ctx, q.listObjectsDeadline)
 		defer cancel()
 	}
+	timeoutCtx, cancel := context.WithCancel(timeoutCtx)
+	defer cancel()
 
 	var resolutionMetadata ListObjectsResolutionMetadata
 
@@ -555,7 +1003,59 @@ func (q *ListObjectsQuery) ExecuteStreamed(ctx context.Context, req *openfgav1.S
 		return nil, err
 	}
 
-	for result := range resultsChan {
+	var (
+		sent uint32
+		// the objects found so far, if they are ordered
+		buffered []string
+	)
+	send := func(object string) error {
+		if err := srv.Send(&openfgav1.StreamedListObjectsResponse{
+			Object: object,
+		}); err != nil {
+			return serverErrors.HandleError("", err)
+		}
+		sent++
+		return nil
+	}
+	// flush streams the buffered objects in order
+	flush := func() error {
+		slices.Sort(buffered)
+		for _, object := range buffered {
+			if err := send(object); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	for {
+		var result ListObjectsResult
+		select {
+		case <-q.interrupt:
+			cancel()
+			// wait for the evaluation to stop, the objects it still yields are discarded
+			for range resultsChan { //nolint:revive
+			}
+			if err := flush(); err != nil {
+				return nil, err
+			}
+			resolutionMetadata.Interrupted.Store(true)
+			resolutionMetadata.ObjectsReturned.Store(sent)
+			return &resolutionMetadata, nil
+		case r, ok := <-resultsChan:
+			if !ok {
+				if err := flush(); err != nil {
+					return nil, err
+				}
+				if maxResults != math.MaxUint32 && sent >= maxResults {
+					resolutionMetadata.MaxResultsReached.Store(true)
+				}
+				resolutionMetadata.ObjectsReturned.Store(sent)
+				return &resolutionMetadata, nil
+			}
+			result = r
+		}
+
 		if result.Err != nil {
 			if errors.Is(result.Err, graph.ErrResolutionDepthExceeded) {
 				return nil, serverErrors.ErrAuthorizationModelResolutionTooComplex
@@ -568,12 +1068,12 @@ func (q *ListObjectsQuery) ExecuteStreamed(ctx context.Context, req *openfgav1.S
 			return nil, serverErrors.HandleError("", result.Err)
 		}
 
-		if err := srv.Send(&openfgav1.StreamedListObjectsResponse{
-			Object: result.ObjectID,
-		}); err != nil {
-			return nil, serverErrors.HandleError("", err)
+		if ordered {
+			buffered = append(buffered, result.ObjectID)
+			continue
+		}
+		if err := send(result.ObjectID); err != nil {
+			return nil, err
 		}
 	}
-
-	return &resolutionMetadata, nil
 }
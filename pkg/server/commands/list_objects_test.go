@@ -13,6 +13,7 @@ import (
 	"go.uber.org/goleak"
 	"go.uber.org/mock/gomock"
 	"golang.org/x/sync/singleflight"
+	"google.golang.org/grpc"
 
 	openfgav1 "github.com/openfga/api/proto/openfga/v1"
 	parser "github.com/openfga/language/pkg/go/transformer"
@@ -834,3 +835,476 @@ func createRecursiveRelations(b *testing.B, ctx context.Context, datastore stora
 		require.NoError(b, err)
 	}
 }
+
+func TestListObjectsUntypedUser(t *testing.T) {
+	ds := memory.New()
+	t.Cleanup(ds.Close)
+
+	model := parser.MustTransformDSLToProto(`
+		model
+			schema 1.1
+		type user
+		type document
+			relations
+				define viewer: [user]`)
+	ts, err := typesystem.New(model)
+	require.NoError(t, err)
+
+	ctx := typesystem.ContextWithTypesystem(context.Background(), ts)
+
+	q, err := NewListObjectsQuery(ds, graph.NewLocalChecker())
+	require.NoError(t, err)
+
+	_, err = q.Execute(ctx, &openfgav1.ListObjectsRequest{
+		StoreId:  ulid.Make().String(),
+		Type:     "document",
+		Relation: "viewer",
+		User:     "bob",
+	})
+	require.Error(t, err)
+	require.ErrorContains(t, err, "'document#viewer' expects users of type user (e.g. 'user:bob')")
+}
+
+func TestListObjectsContextualTuplesOnly(t *testing.T) {
+	t.Cleanup(func() {
+		goleak.VerifyNone(t)
+	})
+
+	model := parser.MustTransformDSLToProto(`
+		model
+			schema 1.1
+		type user
+		type group
+			relations
+				define member: [user]
+		type document
+			relations
+				define editor: [user]
+				define viewer: [user, user:*] or editor
+				define commenter: [user, group#member]`)
+	ts, err := typesystem.New(model)
+	require.NoError(t, err)
+
+	ctx := typesystem.ContextWithTypesystem(context.Background(), ts)
+
+	contextualTuples := &openfgav1.ContextualTupleKeys{
+		TupleKeys: []*openfgav1.TupleKey{
+			tuple.NewTupleKey("document:1", "viewer", "user:anne"),
+			tuple.NewTupleKey("document:2", "viewer", "user:bob"),
+			tuple.NewTupleKey("document:2", "editor", "user:anne"),
+			tuple.NewTupleKey("document:3", "viewer", "user:*"),
+		},
+	}
+
+	t.Run("answered_without_reading_the_datastore", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		t.Cleanup(ctrl.Finish)
+
+		// no reads are expected
+		mockDatastore := mocks.NewMockRelationshipTupleReader(ctrl)
+
+		q, err := NewListObjectsQuery(mockDatastore, graph.NewLocalChecker(), WithListObjectsMaxResults(2))
+		require.NoError(t, err)
+
+		resp, err := q.Execute(ctx, &openfgav1.ListObjectsRequest{
+			StoreId:          ulid.Make().String(),
+			Type:             "document",
+			Relation:         "viewer",
+			User:             "user:anne",
+			ContextualTuples: contextualTuples,
+		})
+		require.NoError(t, err)
+		require.Equal(t, []string{"document:1", "document:2"}, resp.Objects)
+		require.True(t, resp.Truncated)
+	})
+
+	t.Run("answered_from_the_relationships_of_the_user", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		t.Cleanup(ctrl.Finish)
+
+		storeID := ulid.Make().String()
+
+		// only the direct relationships of the user are read, there are no stored ones
+		mockDatastore := mocks.NewMockRelationshipTupleReader(ctrl)
+		for _, relation := range []string{"viewer", "editor"} {
+			mockDatastore.EXPECT().ReadStartingWithUser(gomock.Any(), storeID, storage.ReadStartingWithUserFilter{
+				ObjectType: "document",
+				Relation:   relation,
+				UserFilter: []*openfgav1.ObjectRelation{{Object: "user:*"}, {Object: "user:anne"}},
+			}, gomock.Any()).Return(storage.NewStaticTupleIterator(nil), nil)
+		}
+
+		q, err := NewListObjectsQuery(mockDatastore, graph.NewLocalChecker())
+		require.NoError(t, err)
+
+		resp, err := q.Execute(ctx, &openfgav1.ListObjectsRequest{
+			StoreId:          storeID,
+			Type:             "document",
+			Relation:         "viewer",
+			User:             "user:anne",
+			ContextualTuples: contextualTuples,
+		})
+		require.NoError(t, err)
+		require.ElementsMatch(t, []string{"document:1", "document:2", "document:3"}, resp.Objects)
+		require.Zero(t, resp.ResolutionMetadata.DispatchCounter.Load())
+	})
+
+	t.Run("datastore_errors_are_returned", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		t.Cleanup(ctrl.Finish)
+
+		mockDatastore := mocks.NewMockRelationshipTupleReader(ctrl)
+		mockDatastore.EXPECT().ReadStartingWithUser(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).
+			Return(nil, errors.ErrUnknown)
+
+		q, err := NewListObjectsQuery(mockDatastore, graph.NewLocalChecker())
+		require.NoError(t, err)
+
+		_, err = q.Execute(ctx, &openfgav1.ListObjectsRequest{
+			StoreId:          ulid.Make().String(),
+			Type:             "document",
+			Relation:         "viewer",
+			User:             "user:anne",
+			ContextualTuples: contextualTuples,
+		})
+		require.Error(t, err)
+	})
+
+	t.Run("relations_reachable_through_usersets_are_reverse_expanded", func(t *testing.T) {
+		ds := memory.New()
+		t.Cleanup(ds.Close)
+
+		storeID := ulid.Make().String()
+		err := ds.Write(context.Background(), storeID, nil, []*openfgav1.TupleKey{
+			tuple.NewTupleKey("document:stored", "commenter", "group:eng#member"),
+		})
+		require.NoError(t, err)
+
+		q, err := NewListObjectsQuery(ds, graph.NewLocalChecker())
+		require.NoError(t, err)
+
+		resp, err := q.Execute(ctx, &openfgav1.ListObjectsRequest{
+			StoreId:  storeID,
+			Type:     "document",
+			Relation: "commenter",
+			User:     "user:anne",
+			ContextualTuples: &openfgav1.ContextualTupleKeys{
+				TupleKeys: []*openfgav1.TupleKey{
+					tuple.NewTupleKey("group:eng", "member", "user:anne"),
+				},
+			},
+		})
+		require.NoError(t, err)
+		require.Equal(t, []string{"document:stored"}, resp.Objects)
+	})
+
+	t.Run("stored_tuples_may_be_part_of_the_answer", func(t *testing.T) {
+		ds := memory.New()
+		t.Cleanup(ds.Close)
+
+		storeID := ulid.Make().String()
+		err := ds.Write(context.Background(), storeID, nil, []*openfgav1.TupleKey{
+			tuple.NewTupleKey("document:stored", "viewer", "user:anne"),
+		})
+		require.NoError(t, err)
+
+		q, err := NewListObjectsQuery(ds, graph.NewLocalChecker(), WithListObjectsMaxResults(10))
+		require.NoError(t, err)
+
+		resp, err := q.Execute(ctx, &openfgav1.ListObjectsRequest{
+			StoreId:          storeID,
+			Type:             "document",
+			Relation:         "viewer",
+			User:             "user:anne",
+			ContextualTuples: contextualTuples,
+		})
+		require.NoError(t, err)
+		require.ElementsMatch(t, []string{"document:1", "document:2", "document:3", "document:stored"}, resp.Objects)
+	})
+}
+
+func TestListObjectsCountObjects(t *testing.T) {
+	t.Cleanup(func() {
+		goleak.VerifyNone(t)
+	})
+
+	ds := memory.New()
+	t.Cleanup(ds.Close)
+
+	var tuples []string
+	for i := 0; i < 250; i++ {
+		object := "document:" + strconv.Itoa(i)
+		// the same object is reachable through several paths
+		tuples = append(tuples,
+			object+"#viewer@user:jon",
+			object+"#editor@user:jon",
+			object+"#viewer@group:eng#member",
+		)
+		if i%2 == 0 {
+			tuples = append(tuples, object+"#blocked@user:jon")
+		}
+	}
+	tuples = append(tuples,
+		"group:eng#member@user:jon",
+		"document:small#viewer@user:maria",
+		"document:small#editor@user:maria",
+		"document:other#editor@user:maria",
+	)
+
+	storeID, model := storagetest.BootstrapFGAStore(t, ds, `
+		model
+			schema 1.1
+		type user
+		type group
+			relations
+				define member: [user]
+		type document
+			relations
+				define blocked: [user]
+				define editor: [user]
+				define viewer: [user, group#member] or editor
+				define visible: viewer but not blocked`, tuples)
+	ts, err := typesystem.NewAndValidate(context.Background(), model)
+	require.NoError(t, err)
+
+	ctx := storage.ContextWithRelationshipTupleReader(context.Background(), ds)
+	ctx = typesystem.ContextWithTypesystem(ctx, ts)
+
+	checkResolver, checkResolverCloser, err := graph.NewOrderedCheckResolvers().Build()
+	require.NoError(t, err)
+	t.Cleanup(checkResolverCloser)
+
+	q, err := NewListObjectsQuery(ds, checkResolver, WithListObjectsMaxResults(0))
+	require.NoError(t, err)
+
+	t.Run("matches_list_objects", func(t *testing.T) {
+		for _, req := range []*openfgav1.ListObjectsRequest{
+			{StoreId: storeID, Type: "document", Relation: "viewer", User: "user:maria"},
+			{StoreId: storeID, Type: "document", Relation: "editor", User: "user:maria"},
+			{StoreId: storeID, Type: "document", Relation: "viewer", User: "user:jon"},
+			{StoreId: storeID, Type: "document", Relation: "visible", User: "user:jon"},
+			{StoreId: storeID, Type: "document", Relation: "viewer", User: "user:unknown"},
+		} {
+			listResp, err := q.Execute(ctx, req)
+			require.NoError(t, err)
+
+			countResp, err := q.CountObjects(ctx, req, 0)
+			require.NoError(t, err)
+			require.Equal(t, uint32(len(listResp.Objects)), countResp.Count, "%s#%s@%s", req.GetType(), req.GetRelation(), req.GetUser())
+			require.False(t, countResp.Capped)
+		}
+	})
+
+	t.Run("caps_the_count", func(t *testing.T) {
+		req := &openfgav1.ListObjectsRequest{StoreId: storeID, Type: "document", Relation: "viewer", User: "user:jon"}
+
+		resp, err := q.CountObjects(ctx, req, 100)
+		require.NoError(t, err)
+		require.Equal(t, uint32(100), resp.Count)
+		require.True(t, resp.Capped)
+
+		resp, err = q.CountObjects(ctx, req, 1000)
+		require.NoError(t, err)
+		require.Equal(t, uint32(250), resp.Count)
+		require.False(t, resp.Capped)
+	})
+
+	t.Run("caps_the_count_of_objects_requiring_check", func(t *testing.T) {
+		req := &openfgav1.ListObjectsRequest{StoreId: storeID, Type: "document", Relation: "visible", User: "user:jon"}
+
+		resp, err := q.CountObjects(ctx, req, 10)
+		require.NoError(t, err)
+		require.Equal(t, uint32(10), resp.Count)
+		require.True(t, resp.Capped)
+	})
+}
+
+// objectsStreamServer collects the objects streamed by ExecuteStreamed.
+type objectsStreamServer struct {
+	grpc.ServerStream
+	objects []string
+}
+
+func (s *objectsStreamServer) Send(resp *openfgav1.StreamedListObjectsResponse) error {
+	s.objects = append(s.objects, resp.GetObject())
+	return nil
+}
+
+func TestListObjectsTruncation(t *testing.T) {
+	t.Cleanup(func() {
+		goleak.VerifyNone(t)
+	})
+
+	ds := memory.New()
+	t.Cleanup(ds.Close)
+
+	var tuples []string
+	for i := 0; i < 10; i++ {
+		tuples = append(tuples, "document:"+strconv.Itoa(i)+"#viewer@user:jon")
+	}
+	storeID, model := storagetest.BootstrapFGAStore(t, ds, `
+		model
+			schema 1.1
+		type user
+		type document
+			relations
+				define viewer: [user]`, tuples)
+	ts, err := typesystem.NewAndValidate(context.Background(), model)
+	require.NoError(t, err)
+
+	ctx := storage.ContextWithRelationshipTupleReader(context.Background(), ds)
+	ctx = typesystem.ContextWithTypesystem(ctx, ts)
+
+	checkResolver, checkResolverCloser, err := graph.NewOrderedCheckResolvers().Build()
+	require.NoError(t, err)
+	t.Cleanup(checkResolverCloser)
+
+	req := &openfgav1.ListObjectsRequest{StoreId: storeID, Type: "document", Relation: "viewer", User: "user:jon"}
+
+	t.Run("not_truncated", func(t *testing.T) {
+		q, err := NewListObjectsQuery(ds, checkResolver, WithListObjectsMaxResults(100))
+		require.NoError(t, err)
+
+		resp, err := q.Execute(ctx, req)
+		require.NoError(t, err)
+		require.Len(t, resp.Objects, 10)
+		require.False(t, resp.Truncated)
+		require.Equal(t, ListObjectsNotTruncated, resp.TruncatedBy)
+	})
+
+	t.Run("truncated_by_max_results", func(t *testing.T) {
+		q, err := NewListObjectsQuery(ds, checkResolver, WithListObjectsMaxResults(5))
+		require.NoError(t, err)
+
+		resp, err := q.Execute(ctx, req)
+		require.NoError(t, err)
+		require.Len(t, resp.Objects, 5)
+		require.True(t, resp.Truncated)
+		require.Equal(t, ListObjectsTruncatedByMaxResults, resp.TruncatedBy)
+	})
+
+	t.Run("truncated_by_deadline", func(t *testing.T) {
+		q, err := NewListObjectsQuery(ds, checkResolver, WithListObjectsMaxResults(100), WithListObjectsDeadline(time.Nanosecond))
+		require.NoError(t, err)
+
+		resp, err := q.Execute(ctx, req)
+		require.NoError(t, err)
+		require.True(t, resp.Truncated)
+		require.Equal(t, ListObjectsTruncatedByDeadline, resp.TruncatedBy)
+	})
+
+	streamedReq := &openfgav1.StreamedListObjectsRequest{StoreId: storeID, Type: "document", Relation: "viewer", User: "user:jon"}
+
+	t.Run("streamed_not_truncated", func(t *testing.T) {
+		q, err := NewListObjectsQuery(ds, checkResolver)
+		require.NoError(t, err)
+
+		srv := &objectsStreamServer{}
+		metadata, err := q.ExecuteStreamed(ctx, streamedReq, srv)
+		require.NoError(t, err)
+		require.Len(t, srv.objects, 10)
+		require.Equal(t, ListObjectsNotTruncated, metadata.Truncation())
+	})
+
+	t.Run("streamed_truncated_by_max_results", func(t *testing.T) {
+		q, err := NewListObjectsQuery(ds, checkResolver, WithStreamedListObjectsMaxResults(3))
+		require.NoError(t, err)
+
+		srv := &objectsStreamServer{}
+		metadata, err := q.ExecuteStreamed(ctx, streamedReq, srv)
+		require.NoError(t, err)
+		require.Len(t, srv.objects, 3)
+		require.Equal(t, ListObjectsTruncatedByMaxResults, metadata.Truncation())
+	})
+
+	t.Run("streamed_truncated_by_deadline", func(t *testing.T) {
+		q, err := NewListObjectsQuery(ds, checkResolver, WithListObjectsDeadline(time.Nanosecond))
+		require.NoError(t, err)
+
+		metadata, err := q.ExecuteStreamed(ctx, streamedReq, &objectsStreamServer{})
+		require.NoError(t, err)
+		require.Equal(t, ListObjectsTruncatedByDeadline, metadata.Truncation())
+	})
+}
+
+func TestListObjectsOrderBy(t *testing.T) {
+	t.Cleanup(func() {
+		goleak.VerifyNone(t)
+	})
+
+	ds := memory.New()
+	t.Cleanup(ds.Close)
+
+	// written out of order, so that the objects aren't found in order
+	var tuples, expected []string
+	for _, i := range []int{7, 2, 9, 0, 5, 3, 8, 1, 6, 4} {
+		tuples = append(tuples, "document:"+strconv.Itoa(i)+"#viewer@user:jon")
+	}
+	for i := 0; i < 10; i++ {
+		expected = append(expected, "document:"+strconv.Itoa(i))
+	}
+	storeID, model := storagetest.BootstrapFGAStore(t, ds, `
+		model
+			schema 1.1
+		type user
+		type document
+			relations
+				define viewer: [user]`, tuples)
+	ts, err := typesystem.NewAndValidate(context.Background(), model)
+	require.NoError(t, err)
+
+	ctx := storage.ContextWithRelationshipTupleReader(context.Background(), ds)
+	ctx = typesystem.ContextWithTypesystem(ctx, ts)
+
+	checkResolver, checkResolverCloser, err := graph.NewOrderedCheckResolvers().Build()
+	require.NoError(t, err)
+	t.Cleanup(checkResolverCloser)
+
+	req := &openfgav1.ListObjectsRequest{StoreId: storeID, Type: "document", Relation: "viewer", User: "user:jon"}
+	streamedReq := &openfgav1.StreamedListObjectsRequest{StoreId: storeID, Type: "document", Relation: "viewer", User: "user:jon"}
+
+	t.Run("sorts_the_objects", func(t *testing.T) {
+		q, err := NewListObjectsQuery(ds, checkResolver, WithListObjectsOrderBy(serverconfig.ListObjectsOrderByObjectID))
+		require.NoError(t, err)
+
+		resp, err := q.Execute(ctx, req)
+		require.NoError(t, err)
+		require.Equal(t, expected, resp.Objects)
+	})
+
+	t.Run("streams_the_objects_in_order", func(t *testing.T) {
+		q, err := NewListObjectsQuery(ds, checkResolver, WithListObjectsOrderBy(serverconfig.ListObjectsOrderByObjectID))
+		require.NoError(t, err)
+
+		srv := &objectsStreamServer{}
+		metadata, err := q.ExecuteStreamed(ctx, streamedReq, srv)
+		require.NoError(t, err)
+		require.Equal(t, expected, srv.objects)
+		require.Equal(t, ListObjectsNotTruncated, metadata.Truncation())
+	})
+
+	t.Run("the_streamed_buffer_is_bounded_by_the_max_results", func(t *testing.T) {
+		q, err := NewListObjectsQuery(ds, checkResolver,
+			WithListObjectsOrderBy(serverconfig.ListObjectsOrderByObjectID),
+			WithListObjectsMaxResults(4),
+		)
+		require.NoError(t, err)
+
+		srv := &objectsStreamServer{}
+		metadata, err := q.ExecuteStreamed(ctx, streamedReq, srv)
+		require.NoError(t, err)
+		require.Len(t, srv.objects, 4)
+		require.True(t, sort.StringsAreSorted(srv.objects))
+		require.Equal(t, ListObjectsTruncatedByMaxResults, metadata.Truncation())
+	})
+
+	t.Run("the_max_results_of_unordered_streams_are_unchanged", func(t *testing.T) {
+		q, err := NewListObjectsQuery(ds, checkResolver, WithListObjectsMaxResults(4))
+		require.NoError(t, err)
+
+		srv := &objectsStreamServer{}
+		_, err = q.ExecuteStreamed(ctx, streamedReq, srv)
+		require.NoError(t, err)
+		require.Len(t, srv.objects, 10)
+	})
+}
@@ -15,6 +15,7 @@ type ListStoresQuery struct {
 	storesBackend storage.StoresBackend
 	logger        logger.Logger
 	encoder       encoder.Encoder
+	nameContains  string
 }
 
 type ListStoresQueryOption func(*ListStoresQuery)
@@ -31,6 +32,13 @@ func WithListStoresQueryEncoder(e encoder.Encoder) ListStoresQueryOption {
 	}
 }
 
+// WithListStoresQueryNameContains filters the stores to those whose name contains the substring, case-sensitively.
+func WithListStoresQueryNameContains(substring string) ListStoresQueryOption {
+	return func(q *ListStoresQuery) {
+		q.nameContains = substring
+	}
+}
+
 func NewListStoresQuery(storesBackend storage.StoresBackend, opts ...ListStoresQueryOption) *ListStoresQuery {
 	q := &ListStoresQuery{
 		storesBackend: storesBackend,
@@ -51,9 +59,10 @@ func (q *ListStoresQuery) Execute(ctx context.Context, req *openfgav1.ListStores
 	}
 
 	opts := storage.ListStoresOptions{
-		IDs:        storeIDs,
-		Name:       req.GetName(),
-		Pagination: storage.NewPaginationOptions(req.GetPageSize().GetValue(), string(decodedContToken)),
+		IDs:          storeIDs,
+		Name:         req.GetName(),
+		NameContains: q.nameContains,
+		Pagination:   storage.NewPaginationOptions(req.GetPageSize().GetValue(), string(decodedContToken)),
 	}
 	stores, continuationToken, err := q.storesBackend.ListStores(ctx, opts)
 	if err != nil {
@@ -198,6 +198,37 @@ func (l *listUsersQuery) ListUsers(
 	))
 	defer span.End()
 
+	return l.listUsers(ctx, req, nil)
+}
+
+// StreamedListUsers is the streaming variant of ListUsers: the users are sent as they are found, rather than
+// returned once the expansion completes, so the Users of the response are empty.
+//
+// If the relation involves an exclusion, a user found may be excluded by a subtracted relation expanded
+// later on, so the users are only sent once the expansion completes, as ListUsers returns them. If sending
+// a user fails, the expansion is cancelled and the error is returned.
+func (l *listUsersQuery) StreamedListUsers(
+	ctx context.Context,
+	req *openfgav1.ListUsersRequest,
+	send func(user *openfgav1.User) error,
+) (*listUsersResponse, error) {
+	ctx, span := tracer.Start(ctx, "StreamedListUsers", trace.WithAttributes(
+		attribute.String("store_id", req.GetStoreId()),
+	))
+	defer span.End()
+
+	return l.listUsers(ctx, req, send)
+}
+
+// listUsers expands the users of the request. If send is nil, the users are returned in the response,
+// otherwise they are sent as described by StreamedListUsers.
+func (l *listUsersQuery) listUsers(
+	ctx context.Context,
+	req *openfgav1.ListUsersRequest,
+	send func(user *openfgav1.User) error,
+) (*listUsersResponse, error) {
+	span := trace.SpanFromContext(ctx)
+
 	cancellableCtx, cancelCtx := context.WithCancel(ctx)
 	if l.deadline != 0 {
 		cancellableCtx, cancelCtx = context.WithTimeout(cancellableCtx, l.deadline)
@@ -230,6 +261,15 @@ func (l *listUsersQuery) ListUsers(
 		}
 	}
 
+	streamFoundUsers := false
+	if send != nil {
+		involvesExclusion, err := typesys.RelationInvolvesExclusion(req.GetObject().GetType(), req.GetRelation())
+		if err != nil {
+			return nil, err
+		}
+		streamFoundUsers = !involvesExclusion
+	}
+
 	dispatchCount := atomic.Uint32{}
 
 	foundUsersCh := l.buildResultsChannel()
@@ -237,10 +277,20 @@ func (l *listUsersQuery) ListUsers(
 
 	foundUsersUnique := make(map[tuple.UserString]foundUser, 1000)
 
+	var sendErr error
 	doneWithFoundUsersCh := make(chan struct{}, 1)
 	go func() {
 		for foundUser := range foundUsersCh {
-			foundUsersUnique[tuple.UserProtoToString(foundUser.user)] = foundUser
+			key := tuple.UserProtoToString(foundUser.user)
+			_, alreadyFound := foundUsersUnique[key]
+			foundUsersUnique[key] = foundUser
+
+			if streamFoundUsers && !alreadyFound && foundUser.relationshipStatus != NoRelationship {
+				if sendErr = send(tuple.StringToUserProto(key)); sendErr != nil {
+					cancelCtx()
+					break
+				}
+			}
 
 			if l.maxResults > 0 {
 				if uint32(len(foundUsersUnique)) >= l.maxResults {
@@ -274,6 +324,11 @@ func (l *listUsersQuery) ListUsers(
 		break
 	}
 
+	if sendErr != nil {
+		telemetry.TraceError(span, sendErr)
+		return nil, sendErr
+	}
+
 	select {
 	case err := <-expandErrCh:
 		if deadlineExceeded || errors.Is(err, context.DeadlineExceeded) {
@@ -299,6 +354,18 @@ func (l *listUsersQuery) ListUsers(
 
 	span.SetAttributes(attribute.Int("result_count", len(foundUsers)))
 
+	if send != nil {
+		if !streamFoundUsers {
+			for _, user := range foundUsers {
+				if err := send(user); err != nil {
+					telemetry.TraceError(span, err)
+					return nil, err
+				}
+			}
+		}
+		foundUsers = nil
+	}
+
 	dsMeta := l.datastore.GetMetadata()
 	l.wasThrottled.CompareAndSwap(false, dsMeta.WasThrottled)
 	return &listUsersResponse{
@@ -4131,3 +4131,87 @@ func NewListUsersQueryPanicExpandDirect(ds storage.RelationshipTupleReader, cont
 
 	return l
 }
+
+func TestStreamedListUsers(t *testing.T) {
+	t.Cleanup(func() {
+		goleak.VerifyNone(t)
+	})
+
+	ds := memory.New()
+	t.Cleanup(ds.Close)
+
+	model := testutils.MustTransformDSLToProtoWithID(`
+		model
+			schema 1.1
+
+		type user
+
+		type document
+			relations
+				define owner: [user]
+				define editor: [user] or owner
+				define blocked: [user]
+				define viewer: editor but not blocked`)
+
+	storeID := ulid.Make().String()
+	require.NoError(t, ds.Write(context.Background(), storeID, nil, []*openfgav1.TupleKey{
+		tuple.NewTupleKey("document:1", "owner", "user:anne"),
+		tuple.NewTupleKey("document:1", "editor", "user:anne"),
+		tuple.NewTupleKey("document:1", "editor", "user:bob"),
+		tuple.NewTupleKey("document:1", "editor", "user:charlie"),
+		tuple.NewTupleKey("document:1", "blocked", "user:bob"),
+	}))
+
+	typesys, err := typesystem.NewAndValidate(context.Background(), model)
+	require.NoError(t, err)
+	ctx := typesystem.ContextWithTypesystem(context.Background(), typesys)
+
+	newRequest := func(relation string) *openfgav1.ListUsersRequest {
+		return &openfgav1.ListUsersRequest{
+			StoreId:     storeID,
+			Object:      &openfgav1.Object{Type: "document", Id: "1"},
+			Relation:    relation,
+			UserFilters: []*openfgav1.UserTypeFilter{{Type: "user"}},
+		}
+	}
+
+	t.Run("users_found_through_several_paths_are_sent_once", func(t *testing.T) {
+		var sent []string
+		resp, err := NewListUsersQuery(ds, nil).StreamedListUsers(ctx, newRequest("editor"), func(user *openfgav1.User) error {
+			sent = append(sent, tuple.UserProtoToString(user))
+			return nil
+		})
+		require.NoError(t, err)
+		require.Empty(t, resp.GetUsers())
+		require.ElementsMatch(t, []string{"user:anne", "user:bob", "user:charlie"}, sent)
+	})
+
+	t.Run("excluded_users_are_not_sent", func(t *testing.T) {
+		var sent []string
+		_, err := NewListUsersQuery(ds, nil).StreamedListUsers(ctx, newRequest("viewer"), func(user *openfgav1.User) error {
+			sent = append(sent, tuple.UserProtoToString(user))
+			return nil
+		})
+		require.NoError(t, err)
+		require.ElementsMatch(t, []string{"user:anne", "user:charlie"}, sent)
+	})
+
+	t.Run("max_results", func(t *testing.T) {
+		var sent []string
+		_, err := NewListUsersQuery(ds, nil, WithListUsersMaxResults(2)).StreamedListUsers(ctx, newRequest("editor"), func(user *openfgav1.User) error {
+			sent = append(sent, tuple.UserProtoToString(user))
+			return nil
+		})
+		require.NoError(t, err)
+		require.Len(t, sent, 2)
+		require.Subset(t, []string{"user:anne", "user:bob", "user:charlie"}, sent)
+	})
+
+	t.Run("send_error", func(t *testing.T) {
+		sendErr := fmt.Errorf("stream closed")
+		_, err := NewListUsersQuery(ds, nil).StreamedListUsers(ctx, newRequest("editor"), func(user *openfgav1.User) error {
+			return sendErr
+		})
+		require.ErrorIs(t, err, sendErr)
+	})
+}
@@ -0,0 +1,482 @@
+package commands
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"sort"
+
+	"github.com/oklog/ulid/v2"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+
+	openfgav1 "github.com/openfga/api/proto/openfga/v1"
+
+	"github.com/openfga/openfga/pkg/logger"
+	"github.com/openfga/openfga/pkg/server/config"
+	serverErrors "github.com/openfga/openfga/pkg/server/errors"
+	"github.com/openfga/openfga/pkg/storage"
+	"github.com/openfga/openfga/pkg/tuple"
+)
+
+// StoreArchiveVersion is the version of the format of the store archives written by StoreExportCommand.
+const StoreArchiveVersion = 1
+
+// The kinds of the records of a store archive. An archive is a stream of JSON records, one per line, that
+// starts with a header record and ends with an end record.
+const (
+	storeArchiveHeader   = "header"
+	storeArchiveMetadata = "metadata"
+	storeArchiveModel    = "model"
+	storeArchiveTuple    = "tuple"
+	storeArchiveEnd      = "end"
+)
+
+type storeArchiveRecord struct {
+	Kind string          `json:"kind"`
+	Data json.RawMessage `json:"data"`
+}
+
+type storeArchiveHeaderData struct {
+	Version int             `json:"version"`
+	Store   json.RawMessage `json:"store"`
+}
+
+// storeArchiveEndData allows detecting truncated archives.
+type storeArchiveEndData struct {
+	Models int `json:"models"`
+	Tuples int `json:"tuples"`
+}
+
+type StoreExportCommand struct {
+	datastore storage.OpenFGADatastore
+	logger    logger.Logger
+	pageSize  int
+}
+
+type StoreExportCmdOption func(*StoreExportCommand)
+
+func WithStoreExportCmdLogger(l logger.Logger) StoreExportCmdOption {
+	return func(c *StoreExportCommand) {
+		c.logger = l
+	}
+}
+
+// WithStoreExportCmdPageSize specifies the number of tuples and models read from the datastore at once.
+func WithStoreExportCmdPageSize(pageSize int) StoreExportCmdOption {
+	return func(c *StoreExportCommand) {
+		c.pageSize = pageSize
+	}
+}
+
+func NewStoreExportCommand(datastore storage.OpenFGADatastore, opts ...StoreExportCmdOption) *StoreExportCommand {
+	cmd := &StoreExportCommand{
+		datastore: datastore,
+		logger:    logger.NewNoopLogger(),
+		pageSize:  storage.DefaultPageSize,
+	}
+
+	for _, opt := range opts {
+		opt(cmd)
+	}
+	return cmd
+}
+
+// Execute writes an archive of the store to w, consisting of the store, its metadata, all of its authorization
+// models and all of its tuples. The models and tuples are read and written a page at a time, so the memory used
+// doesn't depend on the size of the store. The timestamps of the tuples and the changelog of the store are not
+// part of the archive. Only the IDs of the models are held in memory, to write the models from oldest to newest. The tuples are read with the default consistency, so tuples written while the export is in
+// progress may or may not be part of the archive. An interrupted export must be restarted from the beginning.
+func (c *StoreExportCommand) Execute(ctx context.Context, storeID string, w io.Writer) error {
+	store, err := c.datastore.GetStore(ctx, storeID)
+	if err != nil {
+		if errors.Is(err, storage.ErrNotFound) {
+			return serverErrors.ErrStoreIDNotFound
+		}
+		return serverErrors.HandleError("", err)
+	}
+
+	metadata, err := c.datastore.ReadStoreMetadata(ctx, storeID)
+	if err != nil {
+		return serverErrors.HandleError("", err)
+	}
+
+	enc := json.NewEncoder(w)
+	write := func(kind string, data any) error {
+		var raw []byte
+		var err error
+		if m, ok := data.(proto.Message); ok {
+			raw, err = protojson.Marshal(m)
+		} else {
+			raw, err = json.Marshal(data)
+		}
+		if err != nil {
+			return serverErrors.HandleError("", err)
+		}
+		if err := enc.Encode(storeArchiveRecord{Kind: kind, Data: raw}); err != nil {
+			return fmt.Errorf("writing the store archive: %w", err)
+		}
+		return nil
+	}
+
+	rawStore, err := protojson.Marshal(store)
+	if err != nil {
+		return serverErrors.HandleError("", err)
+	}
+	if err := write(storeArchiveHeader, storeArchiveHeaderData{Version: StoreArchiveVersion, Store: rawStore}); err != nil {
+		return err
+	}
+
+	if err := write(storeArchiveMetadata, metadata); err != nil {
+		return err
+	}
+
+	var end storeArchiveEndData
+
+	// the datastores return the models from newest to oldest, but they are written oldest first so
+	// that the imported models are written in the order in which they were originally written
+	var modelIDs []string
+	modelsToken := ""
+	for {
+		models, token, err := c.datastore.ReadAuthorizationModels(ctx, storeID, storage.ReadAuthorizationModelsOptions{
+			Pagination: storage.NewPaginationOptions(int32(c.pageSize), modelsToken),
+		})
+		if err != nil {
+			return serverErrors.HandleError("", err)
+		}
+
+		for _, model := range models {
+			modelIDs = append(modelIDs, model.GetId())
+		}
+
+		if token == "" {
+			break
+		}
+		modelsToken = token
+	}
+	sort.Strings(modelIDs)
+
+	for _, modelID := range modelIDs {
+		model, err := c.datastore.ReadAuthorizationModel(ctx, storeID, modelID)
+		if err != nil {
+			return serverErrors.HandleError("", err)
+		}
+		if err := write(storeArchiveModel, model); err != nil {
+			return err
+		}
+		end.Models++
+	}
+
+	tuplesToken := ""
+	for {
+		tuples, token, err := c.datastore.ReadPage(ctx, storeID, &openfgav1.TupleKey{}, storage.ReadPageOptions{
+			Pagination: storage.NewPaginationOptions(int32(c.pageSize), tuplesToken),
+		})
+		if err != nil {
+			return serverErrors.HandleError("", err)
+		}
+
+		for _, t := range tuples {
+			if err := write(storeArchiveTuple, t.GetKey()); err != nil {
+				return err
+			}
+			end.Tuples++
+		}
+
+		if token == "" {
+			break
+		}
+		tuplesToken = token
+	}
+
+	return write(storeArchiveEnd, end)
+}
+
+// StoreImportRequest configures the import of a store archive.
+type StoreImportRequest struct {
+	// StoreID is the ID of an existing store to import the archive into. If empty, a new store with the
+	// name of the archived store is created. Importing an archive again into the store that the archive
+	// was previously imported into completes an import that was interrupted.
+	StoreID string
+	// RemapModelIDs assigns new IDs to the imported authorization models instead of preserving their IDs.
+	// The new IDs preserve the order of the models and are derived from the store and the original IDs,
+	// so importing the same archive into the same store again assigns the same IDs.
+	RemapModelIDs bool
+}
+
+// StoreImportResult reports the outcome of the import of a store archive.
+type StoreImportResult struct {
+	StoreID string
+	// ModelIDs maps the IDs of the authorization models of the archive to their IDs in the store.
+	ModelIDs map[string]string
+	Tuples   int
+}
+
+type StoreImportCommand struct {
+	datastore          storage.OpenFGADatastore
+	logger             logger.Logger
+	writeAuthModelOpts []WriteAuthModelOption
+	modelWriter        *WriteAuthorizationModelCommand
+	tupleWriter        *WriteCommand
+}
+
+type StoreImportCmdOption func(*StoreImportCommand)
+
+func WithStoreImportCmdLogger(l logger.Logger) StoreImportCmdOption {
+	return func(c *StoreImportCommand) {
+		c.logger = l
+	}
+}
+
+// WithStoreImportWriteAuthModelOptions sets the options of the WriteAuthorizationModelCommand that validates the
+// imported models, e.g. their maximum size, which should be those of the WriteAuthorizationModel API.
+func WithStoreImportWriteAuthModelOptions(opts ...WriteAuthModelOption) StoreImportCmdOption {
+	return func(c *StoreImportCommand) {
+		c.writeAuthModelOpts = append(c.writeAuthModelOpts, opts...)
+	}
+}
+
+func NewStoreImportCommand(datastore storage.OpenFGADatastore, opts ...StoreImportCmdOption) *StoreImportCommand {
+	cmd := &StoreImportCommand{
+		datastore: datastore,
+		logger:    logger.NewNoopLogger(),
+	}
+
+	for _, opt := range opts {
+		opt(cmd)
+	}
+
+	cmd.modelWriter = NewWriteAuthorizationModelCommand(datastore, append([]WriteAuthModelOption{WithWriteAuthModelLogger(cmd.logger)}, cmd.writeAuthModelOpts...)...)
+	cmd.tupleWriter = NewWriteCommand(datastore, WithWriteCmdLogger(cmd.logger))
+	return cmd
+}
+
+func invalidStoreArchiveError(format string, a ...any) error {
+	return serverErrors.ValidationError(fmt.Errorf("invalid store archive: "+format, a...))
+}
+
+// Execute imports a store archive written by StoreExportCommand. The records of the archive are read and
+// written one at a time, with the tuples written in batches of the maximum number of tuples per write of
+// the datastore, so the memory used doesn't depend on the size of the archive. Models and tuples that
+// already exist in the store are skipped, which makes the import safe to restart. An archive without an
+// end record, e.g. one that was truncated, fails the import after the records it contains were imported.
+//
+// The archive is validated as the same changes made through the API would be: the metadata as by
+// ValidateStoreMetadata, the models as by WriteAuthorizationModelCommand and the tuples as by WriteCommand,
+// against the latest model of the store once the models of the archive are imported. So the tuples are subject
+// to the object type allowlist and the cycle guarded relations of the store.
+func (c *StoreImportCommand) Execute(ctx context.Context, r io.Reader, req *StoreImportRequest) (*StoreImportResult, error) {
+	dec := json.NewDecoder(r)
+
+	var header storeArchiveHeaderData
+	record, err := c.next(dec, &header)
+	if err != nil {
+		return nil, err
+	}
+	if record == nil || record.Kind != storeArchiveHeader {
+		return nil, invalidStoreArchiveError("missing header")
+	}
+	if header.Version != StoreArchiveVersion {
+		return nil, invalidStoreArchiveError("unsupported version %d", header.Version)
+	}
+
+	var archivedStore openfgav1.Store
+	if err := protojson.Unmarshal(header.Store, &archivedStore); err != nil {
+		return nil, invalidStoreArchiveError("%v", err)
+	}
+
+	storeID, err := c.targetStore(ctx, req.StoreID, archivedStore.GetName())
+	if err != nil {
+		return nil, err
+	}
+
+	result := &StoreImportResult{
+		StoreID:  storeID,
+		ModelIDs: map[string]string{},
+	}
+
+	// the tuples are validated against the latest model of the store, as those of a Write that doesn't specify
+	// a model, which is looked up once the models of the archive, which precede the tuples, are imported
+	var latestModelID string
+	batch := make([]*openfgav1.TupleKey, 0, c.datastore.MaxTuplesPerWrite())
+	flush := func() error {
+		if len(batch) == 0 {
+			return nil
+		}
+		if latestModelID == "" {
+			model, err := c.datastore.FindLatestAuthorizationModel(ctx, storeID)
+			if err != nil {
+				if errors.Is(err, storage.ErrNotFound) {
+					return serverErrors.LatestAuthorizationModelNotFound(storeID)
+				}
+				return serverErrors.HandleError("", err)
+			}
+			latestModelID = model.GetId()
+		}
+
+		_, err := c.tupleWriter.Execute(ctx, &openfgav1.WriteRequest{
+			StoreId:              storeID,
+			AuthorizationModelId: latestModelID,
+			Writes: &openfgav1.WriteRequestWrites{
+				TupleKeys:   batch,
+				OnDuplicate: string(config.DuplicateWriteIgnore),
+			},
+		})
+		if err != nil {
+			return err
+		}
+		result.Tuples += len(batch)
+		batch = batch[:0]
+		return nil
+	}
+
+	for {
+		record, err := c.next(dec, nil)
+		if err != nil {
+			return nil, err
+		}
+		if record == nil {
+			return nil, invalidStoreArchiveError("missing end, the archive may be truncated")
+		}
+
+		switch record.Kind {
+		case storeArchiveMetadata:
+			var metadata storage.StoreMetadata
+			if err := json.Unmarshal(record.Data, &metadata); err != nil {
+				return nil, invalidStoreArchiveError("%v", err)
+			}
+			if err := ValidateStoreMetadata(&metadata); err != nil {
+				return nil, err
+			}
+			if err := c.datastore.WriteStoreMetadata(ctx, storeID, &metadata); err != nil {
+				return nil, serverErrors.HandleError("", err)
+			}
+		case storeArchiveModel:
+			var model openfgav1.AuthorizationModel
+			if err := protojson.Unmarshal(record.Data, &model); err != nil {
+				return nil, invalidStoreArchiveError("%v", err)
+			}
+			archivedModelID := model.GetId()
+			modelID, err := c.importModel(ctx, storeID, &model, req.RemapModelIDs)
+			if err != nil {
+				return nil, err
+			}
+			result.ModelIDs[archivedModelID] = modelID
+		case storeArchiveTuple:
+			var tk openfgav1.TupleKey
+			if err := protojson.Unmarshal(record.Data, &tk); err != nil {
+				return nil, invalidStoreArchiveError("%v", err)
+			}
+			if !tuple.IsValidObject(tk.GetObject()) || !tuple.IsValidRelation(tk.GetRelation()) || !tuple.IsValidUser(tk.GetUser()) {
+				return nil, invalidStoreArchiveError("malformed tuple '%s'", tuple.TupleKeyToString(&tk))
+			}
+			batch = append(batch, &tk)
+			if len(batch) >= c.datastore.MaxTuplesPerWrite() {
+				if err := flush(); err != nil {
+					return nil, err
+				}
+			}
+		case storeArchiveEnd:
+			var end storeArchiveEndData
+			if err := json.Unmarshal(record.Data, &end); err != nil {
+				return nil, invalidStoreArchiveError("%v", err)
+			}
+			if err := flush(); err != nil {
+				return nil, err
+			}
+			if end.Models != len(result.ModelIDs) || end.Tuples != result.Tuples {
+				return nil, invalidStoreArchiveError("expected %d models and %d tuples, found %d models and %d tuples",
+					end.Models, end.Tuples, len(result.ModelIDs), result.Tuples)
+			}
+			return result, nil
+		default:
+			return nil, invalidStoreArchiveError("unknown record kind '%s'", record.Kind)
+		}
+	}
+}
+
+// next decodes the next record of the archive, and its data into data if not nil. It returns nil at the end of the archive.
+func (c *StoreImportCommand) next(dec *json.Decoder, data any) (*storeArchiveRecord, error) {
+	var record storeArchiveRecord
+	if err := dec.Decode(&record); err != nil {
+		if errors.Is(err, io.EOF) {
+			return nil, nil
+		}
+		return nil, invalidStoreArchiveError("%v", err)
+	}
+
+	if data != nil {
+		if err := json.Unmarshal(record.Data, data); err != nil {
+			return nil, invalidStoreArchiveError("%v", err)
+		}
+	}
+	return &record, nil
+}
+
+// targetStore returns the ID of the store to import into, creating a new store if storeID is empty.
+func (c *StoreImportCommand) targetStore(ctx context.Context, storeID, name string) (string, error) {
+	if storeID != "" {
+		_, err := c.datastore.GetStore(ctx, storeID)
+		if err != nil {
+			if errors.Is(err, storage.ErrNotFound) {
+				return "", serverErrors.ErrStoreIDNotFound
+			}
+			return "", serverErrors.HandleError("", err)
+		}
+		return storeID, nil
+	}
+
+	store, err := c.datastore.CreateStore(ctx, &openfgav1.Store{
+		Id:   ulid.Make().String(),
+		Name: name,
+	})
+	if err != nil {
+		return "", serverErrors.HandleError("", err)
+	}
+	return store.GetId(), nil
+}
+
+// importModel writes the model to the store unless it already exists, and returns its ID in the store.
+func (c *StoreImportCommand) importModel(ctx context.Context, storeID string, model *openfgav1.AuthorizationModel, remap bool) (string, error) {
+	id, err := ulid.Parse(model.GetId())
+	if err != nil {
+		return "", invalidStoreArchiveError("malformed authorization model id '%s'", model.GetId())
+	}
+
+	if remap {
+		// offset the ID by an amount derived from the store, rather than generating a new ID, so that the
+		// order of the models, and hence the latest model, is preserved
+		sum := sha256.Sum256([]byte(storeID))
+		offset := new(big.Int).SetBytes(sum[:10])
+		offset.Add(offset, big.NewInt(1))
+
+		remapped := new(big.Int).SetBytes(id[:])
+		remapped.Add(remapped, offset)
+		if remapped.BitLen() > len(id)*8 {
+			return "", invalidStoreArchiveError("authorization model id '%s' can't be remapped", model.GetId())
+		}
+		remapped.FillBytes(id[:])
+		model.Id = id.String()
+	}
+
+	_, err = c.datastore.ReadAuthorizationModel(ctx, storeID, model.GetId())
+	if err == nil {
+		return model.GetId(), nil
+	}
+	if !errors.Is(err, storage.ErrNotFound) {
+		return "", serverErrors.HandleError("", err)
+	}
+
+	if err := c.modelWriter.validateModel(ctx, model); err != nil {
+		return "", err
+	}
+
+	if err := c.datastore.WriteAuthorizationModel(ctx, storeID, model); err != nil {
+		return "", serverErrors.HandleError("", err)
+	}
+	return model.GetId(), nil
+}
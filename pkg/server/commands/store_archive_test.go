@@ -0,0 +1,247 @@
+package commands
+
+import (
+	"bytes"
+	"context"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/oklog/ulid/v2"
+	"github.com/stretchr/testify/require"
+
+	openfgav1 "github.com/openfga/api/proto/openfga/v1"
+
+	"github.com/openfga/openfga/internal/graph"
+	serverErrors "github.com/openfga/openfga/pkg/server/errors"
+	"github.com/openfga/openfga/pkg/storage"
+	"github.com/openfga/openfga/pkg/storage/memory"
+	"github.com/openfga/openfga/pkg/testutils"
+	"github.com/openfga/openfga/pkg/tuple"
+	"github.com/openfga/openfga/pkg/typesystem"
+)
+
+func TestStoreExportImport(t *testing.T) {
+	ctx := context.Background()
+
+	source := memory.New()
+	t.Cleanup(source.Close)
+
+	store, err := source.CreateStore(ctx, &openfgav1.Store{Id: ulid.Make().String(), Name: "staging"})
+	require.NoError(t, err)
+	storeID := store.GetId()
+
+	oldModel := testutils.MustTransformDSLToProtoWithID(`
+model
+	schema 1.1
+type user
+type doc
+	relations
+		define viewer: [user]`)
+	require.NoError(t, source.WriteAuthorizationModel(ctx, storeID, oldModel))
+
+	latestModel := testutils.MustTransformDSLToProtoWithID(`
+model
+	schema 1.1
+type user
+type group
+	relations
+		define member: [user]
+type doc
+	relations
+		define viewer: [user, group#member, user with in_region]
+condition in_region(region: string) {
+	region == "eu"
+}`)
+	require.NoError(t, source.WriteAuthorizationModel(ctx, storeID, latestModel))
+
+	require.NoError(t, source.WriteStoreMetadata(ctx, storeID, &storage.StoreMetadata{
+		ObjectTypeAllowlist: []string{"doc", "group"},
+	}))
+
+	require.NoError(t, source.Write(ctx, storeID, nil, []*openfgav1.TupleKey{
+		tuple.NewTupleKey("group:eng", "member", "user:anne"),
+		tuple.NewTupleKey("doc:1", "viewer", "group:eng#member"),
+		tuple.NewTupleKey("doc:2", "viewer", "user:bob"),
+		tuple.NewTupleKeyWithCondition("doc:3", "viewer", "user:carl", "in_region", nil),
+	}))
+
+	var archive bytes.Buffer
+	err = NewStoreExportCommand(source, WithStoreExportCmdPageSize(1)).Execute(ctx, storeID, &archive)
+	require.NoError(t, err)
+
+	checkResolver, checkResolverCloser, err := graph.NewOrderedCheckResolvers().Build()
+	require.NoError(t, err)
+	t.Cleanup(checkResolverCloser)
+
+	checks := []*openfgav1.CheckRequestTupleKey{
+		tuple.NewCheckRequestTupleKey("doc:1", "viewer", "user:anne"),
+		tuple.NewCheckRequestTupleKey("doc:1", "viewer", "user:bob"),
+		tuple.NewCheckRequestTupleKey("doc:2", "viewer", "user:bob"),
+		tuple.NewCheckRequestTupleKey("doc:3", "viewer", "user:carl"),
+	}
+	regionContext := testutils.MustNewStruct(t, map[string]any{"region": "eu"})
+
+	decisions := func(ds storage.OpenFGADatastore, storeID string) []bool {
+		model, err := ds.FindLatestAuthorizationModel(ctx, storeID)
+		require.NoError(t, err)
+		ts, err := typesystem.NewAndValidate(ctx, model)
+		require.NoError(t, err)
+
+		var allowed []bool
+		for _, check := range checks {
+			resp, _, err := NewCheckCommand(ds, checkResolver, ts).Execute(ctx, &CheckCommandParams{
+				StoreID:  storeID,
+				TupleKey: check,
+				Context:  regionContext,
+			})
+			require.NoError(t, err)
+			allowed = append(allowed, resp.GetAllowed())
+		}
+		return allowed
+	}
+
+	expected := decisions(source, storeID)
+	require.Equal(t, []bool{true, false, true, true}, expected)
+
+	t.Run("round_trip_preserves_check_decisions", func(t *testing.T) {
+		target := memory.New()
+		t.Cleanup(target.Close)
+
+		result, err := NewStoreImportCommand(target).Execute(ctx, bytes.NewReader(archive.Bytes()), &StoreImportRequest{})
+		require.NoError(t, err)
+		require.NotEqual(t, storeID, result.StoreID)
+		require.Equal(t, 4, result.Tuples)
+		require.Equal(t, map[string]string{
+			oldModel.GetId():    oldModel.GetId(),
+			latestModel.GetId(): latestModel.GetId(),
+		}, result.ModelIDs)
+
+		importedStore, err := target.GetStore(ctx, result.StoreID)
+		require.NoError(t, err)
+		require.Equal(t, "staging", importedStore.GetName())
+
+		metadata, err := target.ReadStoreMetadata(ctx, result.StoreID)
+		require.NoError(t, err)
+		require.Equal(t, []string{"doc", "group"}, metadata.ObjectTypeAllowlist)
+
+		require.Equal(t, expected, decisions(target, result.StoreID))
+	})
+
+	t.Run("remapped_model_ids_preserve_the_latest_model", func(t *testing.T) {
+		target := memory.New()
+		t.Cleanup(target.Close)
+
+		result, err := NewStoreImportCommand(target).Execute(ctx, bytes.NewReader(archive.Bytes()), &StoreImportRequest{RemapModelIDs: true})
+		require.NoError(t, err)
+		require.Len(t, result.ModelIDs, 2)
+		require.NotEqual(t, latestModel.GetId(), result.ModelIDs[latestModel.GetId()])
+
+		latest, err := target.FindLatestAuthorizationModel(ctx, result.StoreID)
+		require.NoError(t, err)
+		require.Equal(t, result.ModelIDs[latestModel.GetId()], latest.GetId())
+
+		require.Equal(t, expected, decisions(target, result.StoreID))
+
+		// importing again into the same store assigns the same IDs
+		again, err := NewStoreImportCommand(target).Execute(ctx, bytes.NewReader(archive.Bytes()), &StoreImportRequest{
+			StoreID:       result.StoreID,
+			RemapModelIDs: true,
+		})
+		require.NoError(t, err)
+		require.Equal(t, result.ModelIDs, again.ModelIDs)
+	})
+
+	t.Run("truncated_archive_can_be_restarted", func(t *testing.T) {
+		target := memory.New()
+		t.Cleanup(target.Close)
+
+		lines := strings.SplitAfter(strings.TrimSuffix(archive.String(), "\n"), "\n")
+		truncated := strings.Join(lines[:len(lines)-2], "")
+
+		_, err := NewStoreImportCommand(target).Execute(ctx, strings.NewReader(truncated), &StoreImportRequest{})
+		require.ErrorContains(t, err, "missing end")
+
+		stores, _, err := target.ListStores(ctx, storage.ListStoresOptions{Pagination: storage.NewPaginationOptions(0, "")})
+		require.NoError(t, err)
+		require.Len(t, stores, 1)
+
+		result, err := NewStoreImportCommand(target).Execute(ctx, bytes.NewReader(archive.Bytes()), &StoreImportRequest{
+			StoreID: stores[0].GetId(),
+		})
+		require.NoError(t, err)
+		require.Equal(t, expected, decisions(target, result.StoreID))
+	})
+
+	t.Run("rejects_invalid_archives", func(t *testing.T) {
+		target := memory.New()
+		t.Cleanup(target.Close)
+
+		for _, archive := range []string{
+			``,
+			`{"kind":"tuple","data":{}}`,
+			`{"kind":"header","data":{"version":2,"store":{}}}`,
+			`{"kind":"header","data":{"version":1,"store":{}}}` + "\n" + `{"kind":"unknown","data":{}}`,
+			`{"kind":"header","data":{"version":1,"store":{}}}` + "\n" + `{"kind":"end","data":{"models":0,"tuples":1}}`,
+		} {
+			_, err := NewStoreImportCommand(target).Execute(ctx, strings.NewReader(archive), &StoreImportRequest{})
+			require.ErrorContains(t, err, "invalid store archive")
+		}
+	})
+
+	t.Run("validates_like_the_write_apis", func(t *testing.T) {
+		lines := strings.SplitAfter(archive.String(), "\n")
+		require.Contains(t, lines[1], `"kind":"metadata"`)
+
+		t.Run("model_limits", func(t *testing.T) {
+			target := memory.New()
+			t.Cleanup(target.Close)
+
+			_, err := NewStoreImportCommand(target,
+				WithStoreImportWriteAuthModelOptions(WithWriteAuthModelMaxSizeInBytes(10)),
+			).Execute(ctx, bytes.NewReader(archive.Bytes()), &StoreImportRequest{})
+			require.ErrorContains(t, err, "model exceeds size limit")
+		})
+
+		t.Run("object_type_allowlist", func(t *testing.T) {
+			target := memory.New()
+			t.Cleanup(target.Close)
+
+			// the group tuples aren't in the allowlist of the store
+			restricted := slices.Clone(lines)
+			restricted[1] = `{"kind":"metadata","data":{"object_type_allowlist":["doc"]}}` + "\n"
+
+			_, err := NewStoreImportCommand(target).Execute(ctx, strings.NewReader(strings.Join(restricted, "")), &StoreImportRequest{})
+			require.ErrorContains(t, err, "object type 'group' is not in the object type allowlist of the store")
+		})
+
+		t.Run("tuples_of_the_model", func(t *testing.T) {
+			target := memory.New()
+			t.Cleanup(target.Close)
+
+			// a tuple of a relation that the latest model doesn't define
+			invalid := slices.Clone(lines)
+			end := len(invalid) - 2
+			invalid = slices.Insert(invalid, end, `{"kind":"tuple","data":{"object":"doc:4","relation":"editor","user":"user:anne"}}`+"\n")
+
+			_, err := NewStoreImportCommand(target).Execute(ctx, strings.NewReader(strings.Join(invalid, "")), &StoreImportRequest{})
+			require.ErrorContains(t, err, "relation 'doc#editor' not found")
+		})
+
+		t.Run("metadata", func(t *testing.T) {
+			target := memory.New()
+			t.Cleanup(target.Close)
+
+			invalid := slices.Clone(lines)
+			invalid[1] = `{"kind":"metadata","data":{"cycle_guarded_relations":["group"]}}` + "\n"
+
+			_, err := NewStoreImportCommand(target).Execute(ctx, strings.NewReader(strings.Join(invalid, "")), &StoreImportRequest{})
+			require.ErrorContains(t, err, "invalid relation 'group' in the cycle guarded relations")
+		})
+	})
+
+	t.Run("export_of_non-existent_store", func(t *testing.T) {
+		err := NewStoreExportCommand(source).Execute(ctx, ulid.Make().String(), &bytes.Buffer{})
+		require.ErrorIs(t, err, serverErrors.ErrStoreIDNotFound)
+	})
+}
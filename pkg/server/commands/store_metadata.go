@@ -0,0 +1,35 @@
+package commands
+
+import (
+	"fmt"
+	"strings"
+
+	openfgav1 "github.com/openfga/api/proto/openfga/v1"
+
+	serverErrors "github.com/openfga/openfga/pkg/server/errors"
+	"github.com/openfga/openfga/pkg/storage"
+	"github.com/openfga/openfga/pkg/tuple"
+)
+
+// ValidateStoreMetadata returns a validation error if the metadata of a store is malformed, e.g. if its object
+// type allowlist has an object type that isn't a valid type name.
+func ValidateStoreMetadata(metadata *storage.StoreMetadata) error {
+	for _, objectType := range metadata.GetObjectTypeAllowlist() {
+		if objectType == "" || strings.ContainsAny(objectType, ":#@ ") {
+			return serverErrors.ValidationError(fmt.Errorf("invalid object type '%s' in the object type allowlist", objectType))
+		}
+	}
+
+	for _, guarded := range metadata.GetCycleGuardedRelations() {
+		objectType, relation := tuple.SplitObjectRelation(guarded)
+		if objectType == "" || relation == "" || strings.ContainsAny(guarded, ":@ ") {
+			return serverErrors.ValidationError(fmt.Errorf("invalid relation '%s' in the cycle guarded relations, expected 'objectType#relation'", guarded))
+		}
+	}
+
+	if _, ok := openfgav1.ConsistencyPreference_name[int32(metadata.GetDefaultConsistency())]; !ok {
+		return serverErrors.ValidationError(fmt.Errorf("invalid default consistency '%d'", metadata.GetDefaultConsistency()))
+	}
+
+	return nil
+}
@@ -0,0 +1,302 @@
+package commands
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"sort"
+	"time"
+
+	"github.com/oklog/ulid/v2"
+
+	openfgav1 "github.com/openfga/api/proto/openfga/v1"
+
+	"github.com/openfga/openfga/pkg/encoder"
+	"github.com/openfga/openfga/pkg/logger"
+	serverconfig "github.com/openfga/openfga/pkg/server/config"
+	serverErrors "github.com/openfga/openfga/pkg/server/errors"
+	"github.com/openfga/openfga/pkg/storage"
+	"github.com/openfga/openfga/pkg/tuple"
+)
+
+// TupleDiffRequest selects the window of the changelog of a store whose net changes are computed.
+// The start of the window is either FromToken, a continuation token returned by ReadChanges, or
+// FromTime. The end of the window is either ToToken or ToTime. A window bounded by tokens includes
+// the changes after FromToken up to and including the last change returned before ToToken. A window
+// bounded by times includes the changes at or after FromTime and before ToTime. A missing start
+// bound starts the window at the beginning of the changelog, a missing end bound ends it at the
+// latest change.
+type TupleDiffRequest struct {
+	StoreID   string
+	Type      string
+	FromToken string
+	FromTime  time.Time
+	ToToken   string
+	ToTime    time.Time
+	// ContinuationToken resumes a previous diff after the last change that was streamed. The window
+	// is taken from the token, so the bounds of the request are ignored.
+	ContinuationToken string
+}
+
+// TupleDiff is the net change of a single tuple within the window. The timestamp of the change is
+// the timestamp of the last change of the tuple within the window. Passing ContinuationToken to a
+// subsequent request resumes the diff after this change.
+type TupleDiff struct {
+	Change            *openfgav1.TupleChange
+	ContinuationToken string
+}
+
+// tupleDiffToken is the state required to resume a diff.
+type tupleDiffToken struct {
+	From  string `json:"from,omitempty"`
+	To    string `json:"to,omitempty"`
+	Type  string `json:"type,omitempty"`
+	After string `json:"after"`
+}
+
+type TupleDiffQuery struct {
+	backend         storage.ChangelogBackend
+	logger          logger.Logger
+	encoder         encoder.Encoder
+	tokenSerializer encoder.ContinuationTokenSerializer
+	horizonOffset   time.Duration
+	pageSize        int
+}
+
+type TupleDiffQueryOption func(*TupleDiffQuery)
+
+func WithTupleDiffQueryLogger(l logger.Logger) TupleDiffQueryOption {
+	return func(q *TupleDiffQuery) {
+		q.logger = l
+	}
+}
+
+func WithTupleDiffQueryEncoder(e encoder.Encoder) TupleDiffQueryOption {
+	return func(q *TupleDiffQuery) {
+		q.encoder = e
+	}
+}
+
+// WithTupleDiffQueryTokenSerializer specifies the serializer of the ReadChanges continuation tokens
+// that bound the window.
+func WithTupleDiffQueryTokenSerializer(tokenSerializer encoder.ContinuationTokenSerializer) TupleDiffQueryOption {
+	return func(q *TupleDiffQuery) {
+		q.tokenSerializer = tokenSerializer
+	}
+}
+
+// WithTupleDiffQueryHorizonOffset specifies duration in minutes.
+func WithTupleDiffQueryHorizonOffset(horizonOffset int) TupleDiffQueryOption {
+	return func(q *TupleDiffQuery) {
+		q.horizonOffset = time.Duration(horizonOffset) * time.Minute
+	}
+}
+
+// WithTupleDiffQueryPageSize specifies the number of changes read from the datastore at once.
+func WithTupleDiffQueryPageSize(pageSize int) TupleDiffQueryOption {
+	return func(q *TupleDiffQuery) {
+		q.pageSize = pageSize
+	}
+}
+
+// NewTupleDiffQuery creates a TupleDiffQuery with specified `ChangelogBackend`.
+func NewTupleDiffQuery(backend storage.ChangelogBackend, opts ...TupleDiffQueryOption) *TupleDiffQuery {
+	q := &TupleDiffQuery{
+		backend:         backend,
+		logger:          logger.NewNoopLogger(),
+		encoder:         encoder.NewBase64Encoder(),
+		tokenSerializer: encoder.NewStringContinuationTokenSerializer(),
+		horizonOffset:   time.Duration(serverconfig.DefaultChangelogHorizonOffset) * time.Minute,
+		pageSize:        storage.DefaultPageSize,
+	}
+
+	for _, opt := range opts {
+		opt(q)
+	}
+	return q
+}
+
+// Execute computes the net changes of the tuples within the window and streams them to send. A tuple
+// that is written and then deleted within the window is omitted, a tuple whose last change within the
+// window is a write is streamed as a write with its latest condition, and a tuple that is only deleted
+// within the window is streamed as a delete. The changes are streamed ordered by tuple key, which keeps
+// the order stable across resumed requests. If send returns an error, Execute stops and returns it.
+func (q *TupleDiffQuery) Execute(ctx context.Context, req *TupleDiffRequest, send func(*TupleDiff) error) error {
+	window, err := q.resolveWindow(req)
+	if err != nil {
+		return err
+	}
+
+	changes, err := q.readWindow(ctx, req.StoreID, window)
+	if err != nil {
+		return err
+	}
+
+	for _, key := range sortedKeys(changes) {
+		if window.After != "" && key <= window.After {
+			continue
+		}
+
+		change := changes[key].net()
+		if change == nil {
+			continue
+		}
+
+		window.After = key
+		contToken, err := q.encodeToken(window)
+		if err != nil {
+			return serverErrors.HandleError("", err)
+		}
+
+		if err := send(&TupleDiff{Change: change, ContinuationToken: contToken}); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// resolveWindow returns the window of the request, expressed as changelog ULIDs.
+func (q *TupleDiffQuery) resolveWindow(req *TupleDiffRequest) (*tupleDiffToken, error) {
+	if req.ContinuationToken != "" {
+		decoded, err := q.encoder.Decode(req.ContinuationToken)
+		if err != nil {
+			return nil, serverErrors.ErrInvalidContinuationToken
+		}
+
+		var token tupleDiffToken
+		if err := json.Unmarshal(decoded, &token); err != nil {
+			return nil, serverErrors.ErrInvalidContinuationToken
+		}
+		if token.Type != req.Type {
+			return nil, serverErrors.ErrMismatchObjectType
+		}
+		return &token, nil
+	}
+
+	from, err := q.resolveBound(req.FromToken, req.FromTime, req.Type)
+	if err != nil {
+		return nil, err
+	}
+
+	to, err := q.resolveBound(req.ToToken, req.ToTime, req.Type)
+	if err != nil {
+		return nil, err
+	}
+
+	return &tupleDiffToken{From: from, To: to, Type: req.Type}, nil
+}
+
+// resolveBound returns the ULID of a bound of the window given as either a ReadChanges continuation
+// token or a time. ULIDs created for a time sort before every change made at that time.
+func (q *TupleDiffQuery) resolveBound(encodedToken string, t time.Time, objectType string) (string, error) {
+	if encodedToken != "" {
+		decoded, err := q.encoder.Decode(encodedToken)
+		if err != nil {
+			return "", serverErrors.ErrInvalidContinuationToken
+		}
+
+		boundUlid, tokenType, err := q.tokenSerializer.Deserialize(string(decoded))
+		if err != nil {
+			return "", serverErrors.ErrInvalidContinuationToken
+		}
+		if tokenType != objectType {
+			return "", serverErrors.ErrMismatchObjectType
+		}
+		return boundUlid, nil
+	}
+
+	if t.IsZero() {
+		return "", nil
+	}
+
+	boundUlid, err := ulid.New(ulid.Timestamp(t), nil)
+	if err != nil {
+		return "", serverErrors.HandleError(err.Error(), storage.ErrInvalidStartTime)
+	}
+	return boundUlid.String(), nil
+}
+
+// readWindow reads the changes within the window and groups them by tuple key.
+func (q *TupleDiffQuery) readWindow(ctx context.Context, storeID string, window *tupleDiffToken) (map[string]*tupleChanges, error) {
+	filter := storage.ReadChangesFilter{
+		ObjectType:    window.Type,
+		HorizonOffset: q.horizonOffset,
+	}
+
+	changes := make(map[string]*tupleChanges)
+	add := func(page []*openfgav1.TupleChange) {
+		for _, change := range page {
+			key := tuple.TupleKeyToString(change.GetTupleKey())
+			if _, ok := changes[key]; !ok {
+				changes[key] = &tupleChanges{first: change}
+			}
+			changes[key].last = change
+		}
+	}
+
+	from := window.From
+	pageSize := q.pageSize
+	for {
+		page, contUlid, err := q.backend.ReadChanges(ctx, storeID, filter, storage.ReadChangesOptions{
+			Pagination: storage.NewPaginationOptions(int32(pageSize), from),
+		})
+		if err != nil {
+			if errors.Is(err, storage.ErrNotFound) {
+				return changes, nil
+			}
+			return nil, serverErrors.HandleError("", err)
+		}
+
+		if window.To == "" || contUlid <= window.To {
+			add(page)
+			from = contUlid
+			continue
+		}
+
+		// the page ends past the end of the window, only the continuation ULID of the whole page is
+		// known, so the rest of the window is read one change at a time
+		if pageSize == 1 {
+			return changes, nil
+		}
+		pageSize = 1
+	}
+}
+
+// tupleChanges are the first and the last change of a tuple within the window.
+type tupleChanges struct {
+	first *openfgav1.TupleChange
+	last  *openfgav1.TupleChange
+}
+
+// net returns the change that leads from the state of the tuple at the start of the window to
+// its state at the end of the window, or nil if the states are the same.
+func (c *tupleChanges) net() *openfgav1.TupleChange {
+	if c.last.GetOperation() == openfgav1.TupleOperation_TUPLE_OPERATION_WRITE {
+		return c.last
+	}
+
+	if c.first.GetOperation() == openfgav1.TupleOperation_TUPLE_OPERATION_WRITE {
+		// the tuple didn't exist at the start of the window and doesn't exist at the end
+		return nil
+	}
+
+	return c.last
+}
+
+func sortedKeys(changes map[string]*tupleChanges) []string {
+	keys := make([]string, 0, len(changes))
+	for key := range changes {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func (q *TupleDiffQuery) encodeToken(token *tupleDiffToken) (string, error) {
+	b, err := json.Marshal(token)
+	if err != nil {
+		return "", err
+	}
+	return q.encoder.Encode(b)
+}
@@ -0,0 +1,194 @@
+package commands
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/oklog/ulid/v2"
+	"github.com/stretchr/testify/require"
+
+	openfgav1 "github.com/openfga/api/proto/openfga/v1"
+
+	serverErrors "github.com/openfga/openfga/pkg/server/errors"
+	"github.com/openfga/openfga/pkg/storage"
+	"github.com/openfga/openfga/pkg/storage/memory"
+	"github.com/openfga/openfga/pkg/tuple"
+)
+
+func TestTupleDiffQuery(t *testing.T) {
+	ctx := context.Background()
+	ds := memory.New()
+	t.Cleanup(ds.Close)
+
+	storeID := ulid.Make().String()
+
+	// the state at the start of the window
+	require.NoError(t, ds.Write(ctx, storeID, nil, []*openfgav1.TupleKey{
+		tuple.NewTupleKey("doc:removed", "viewer", "user:jon"),
+		tuple.NewTupleKey("doc:rewritten", "viewer", "user:jon"),
+	}))
+	fromToken := readChangesToken(t, ds, storeID)
+
+	// the changes within the window
+	require.NoError(t, ds.Write(ctx, storeID, nil, []*openfgav1.TupleKey{
+		tuple.NewTupleKey("doc:added", "viewer", "user:jon"),
+		tuple.NewTupleKey("doc:transient", "viewer", "user:jon"),
+		tuple.NewTupleKey("doc:readded", "viewer", "user:jon"),
+	}))
+	require.NoError(t, ds.Write(ctx, storeID, []*openfgav1.TupleKeyWithoutCondition{
+		{Object: "doc:transient", Relation: "viewer", User: "user:jon"},
+		{Object: "doc:readded", Relation: "viewer", User: "user:jon"},
+		{Object: "doc:removed", Relation: "viewer", User: "user:jon"},
+		{Object: "doc:rewritten", Relation: "viewer", User: "user:jon"},
+	}, nil))
+	require.NoError(t, ds.Write(ctx, storeID, nil, []*openfgav1.TupleKey{
+		tuple.NewTupleKey("doc:readded", "viewer", "user:jon"),
+		tuple.NewTupleKeyWithCondition("doc:rewritten", "viewer", "user:jon", "in_region", nil),
+	}))
+	toToken := readChangesToken(t, ds, storeID)
+
+	// the changes after the window
+	require.NoError(t, ds.Write(ctx, storeID, nil, []*openfgav1.TupleKey{
+		tuple.NewTupleKey("doc:later", "viewer", "user:jon"),
+	}))
+
+	expected := []string{
+		"WRITE doc:added#viewer@user:jon",
+		"WRITE doc:readded#viewer@user:jon",
+		"DELETE doc:removed#viewer@user:jon",
+		"WRITE doc:rewritten#viewer@user:jon (in_region)",
+	}
+
+	execute := func(t *testing.T, q *TupleDiffQuery, req *TupleDiffRequest, limit int) ([]string, string) {
+		var got []string
+		var contToken string
+		errLimit := errors.New("limit reached")
+		err := q.Execute(ctx, req, func(diff *TupleDiff) error {
+			change := diff.Change
+			line := change.GetOperation().String()[len("TUPLE_OPERATION_"):] + " " + tuple.TupleKeyToString(change.GetTupleKey())
+			if name := change.GetTupleKey().GetCondition().GetName(); name != "" {
+				line += " (" + name + ")"
+			}
+			got = append(got, line)
+			contToken = diff.ContinuationToken
+			if len(got) == limit {
+				return errLimit
+			}
+			return nil
+		})
+		if limit > 0 && len(got) == limit {
+			require.ErrorIs(t, err, errLimit)
+		} else {
+			require.NoError(t, err)
+		}
+		return got, contToken
+	}
+
+	t.Run("collapses_changes_within_the_window", func(t *testing.T) {
+		for _, pageSize := range []int{1, 2, 100} {
+			q := NewTupleDiffQuery(ds, WithTupleDiffQueryPageSize(pageSize))
+			got, _ := execute(t, q, &TupleDiffRequest{
+				StoreID:   storeID,
+				FromToken: fromToken,
+				ToToken:   toToken,
+			}, 0)
+			require.Equal(t, expected, got, "page size %d", pageSize)
+		}
+	})
+
+	t.Run("open_window_includes_latest_changes", func(t *testing.T) {
+		q := NewTupleDiffQuery(ds)
+		got, _ := execute(t, q, &TupleDiffRequest{
+			StoreID:   storeID,
+			FromToken: fromToken,
+		}, 0)
+		require.Equal(t, []string{
+			"WRITE doc:added#viewer@user:jon",
+			"WRITE doc:later#viewer@user:jon",
+			"WRITE doc:readded#viewer@user:jon",
+			"DELETE doc:removed#viewer@user:jon",
+			"WRITE doc:rewritten#viewer@user:jon (in_region)",
+		}, got)
+	})
+
+	t.Run("window_bounded_by_times", func(t *testing.T) {
+		q := NewTupleDiffQuery(ds)
+		got, _ := execute(t, q, &TupleDiffRequest{
+			StoreID: storeID,
+			ToTime:  time.Now().Add(time.Hour),
+		}, 0)
+		require.Equal(t, []string{
+			"WRITE doc:added#viewer@user:jon",
+			"WRITE doc:later#viewer@user:jon",
+			"WRITE doc:readded#viewer@user:jon",
+			"WRITE doc:rewritten#viewer@user:jon (in_region)",
+		}, got)
+
+		got, _ = execute(t, q, &TupleDiffRequest{
+			StoreID:  storeID,
+			FromTime: time.Now().Add(time.Hour),
+		}, 0)
+		require.Empty(t, got)
+	})
+
+	t.Run("resumes_after_the_last_streamed_change", func(t *testing.T) {
+		q := NewTupleDiffQuery(ds, WithTupleDiffQueryPageSize(2))
+		req := &TupleDiffRequest{
+			StoreID:   storeID,
+			FromToken: fromToken,
+			ToToken:   toToken,
+		}
+
+		var got []string
+		for {
+			page, contToken := execute(t, q, req, 1)
+			if len(page) == 0 {
+				break
+			}
+			got = append(got, page...)
+			// the bounds are taken from the continuation token
+			req = &TupleDiffRequest{StoreID: storeID, ContinuationToken: contToken}
+		}
+		require.Equal(t, expected, got)
+	})
+
+	t.Run("invalid_continuation_token", func(t *testing.T) {
+		q := NewTupleDiffQuery(ds)
+		err := q.Execute(ctx, &TupleDiffRequest{
+			StoreID:           storeID,
+			ContinuationToken: "invalid",
+		}, func(*TupleDiff) error { return nil })
+		require.ErrorIs(t, err, serverErrors.ErrInvalidContinuationToken)
+	})
+
+	t.Run("mismatched_object_type", func(t *testing.T) {
+		q := NewTupleDiffQuery(ds)
+		err := q.Execute(ctx, &TupleDiffRequest{
+			StoreID:   storeID,
+			Type:      "folder",
+			FromToken: fromToken,
+		}, func(*TupleDiff) error { return nil })
+		require.ErrorIs(t, err, serverErrors.ErrMismatchObjectType)
+	})
+}
+
+// readChangesToken returns the ReadChanges continuation token that points at the latest change.
+func readChangesToken(t *testing.T, ds storage.ChangelogBackend, storeID string) string {
+	t.Helper()
+
+	q := NewReadChangesQuery(ds)
+	var token string
+	for {
+		resp, err := q.Execute(context.Background(), &openfgav1.ReadChangesRequest{
+			StoreId:           storeID,
+			ContinuationToken: token,
+		})
+		require.NoError(t, err)
+		if len(resp.GetChanges()) == 0 {
+			return token
+		}
+		token = resp.GetContinuationToken()
+	}
+}
@@ -25,8 +25,13 @@ type WriteCommand struct {
 	logger                    logger.Logger
 	datastore                 storage.OpenFGADatastore
 	conditionContextByteLimit int
+	duplicateWriteBehavior    config.DuplicateWriteBehavior
+	readStoreMetadata         StoreMetadataReader
 }
 
+// StoreMetadataReader reads the metadata of a store, see storage.OpenFGADatastore.ReadStoreMetadata.
+type StoreMetadataReader func(ctx context.Context, storeID string) (*storage.StoreMetadata, error)
+
 type WriteCommandOption func(*WriteCommand)
 
 func WithWriteCmdLogger(l logger.Logger) WriteCommandOption {
@@ -41,12 +46,29 @@ func WithConditionContextByteLimit(limit int) WriteCommandOption {
 	}
 }
 
+// WithDuplicateWriteBehavior sets how a tuple that already exists is handled when the request doesn't specify it.
+func WithDuplicateWriteBehavior(behavior config.DuplicateWriteBehavior) WriteCommandOption {
+	return func(wc *WriteCommand) {
+		wc.duplicateWriteBehavior = behavior
+	}
+}
+
+// WithStoreMetadataReader sets how the metadata of the store is read, e.g. from a cache, instead of from the
+// datastore on every Write.
+func WithStoreMetadataReader(reader StoreMetadataReader) WriteCommandOption {
+	return func(wc *WriteCommand) {
+		wc.readStoreMetadata = reader
+	}
+}
+
 // NewWriteCommand creates a WriteCommand with specified storage.OpenFGADatastore to use for storage.
 func NewWriteCommand(datastore storage.OpenFGADatastore, opts ...WriteCommandOption) *WriteCommand {
 	cmd := &WriteCommand{
 		datastore:                 datastore,
 		logger:                    logger.NewNoopLogger(),
 		conditionContextByteLimit: config.DefaultWriteContextByteLimit,
+		duplicateWriteBehavior:    config.DefaultDuplicateWriteBehavior,
+		readStoreMetadata:         datastore.ReadStoreMetadata,
 	}
 
 	for _, opt := range opts {
@@ -55,12 +77,19 @@ func NewWriteCommand(datastore storage.OpenFGADatastore, opts ...WriteCommandOpt
 	return cmd
 }
 
-func parseOptionOnDuplicate(wr *openfgav1.WriteRequestWrites) (storage.OnDuplicateInsert, error) {
-	switch wr.GetOnDuplicate() {
-	case "", "error":
+func parseOptionOnDuplicate(wr *openfgav1.WriteRequestWrites, defaultBehavior config.DuplicateWriteBehavior) (storage.OnDuplicateInsert, error) {
+	onDuplicate := wr.GetOnDuplicate()
+	if onDuplicate == "" {
+		onDuplicate = string(defaultBehavior)
+	}
+
+	switch config.DuplicateWriteBehavior(onDuplicate) {
+	case "", config.DuplicateWriteError:
 		return storage.OnDuplicateInsertError, nil
-	case "ignore":
+	case config.DuplicateWriteIgnore:
 		return storage.OnDuplicateInsertIgnore, nil
+	case config.DuplicateWriteUpsert:
+		return storage.OnDuplicateInsertUpsert, nil
 	default:
 		return storage.OnDuplicateInsertError, serverErrors.ValidationError(fmt.Errorf("invalid on_duplicate option: %s", wr.GetOnDuplicate()))
 	}
@@ -83,7 +112,7 @@ func (c *WriteCommand) Execute(ctx context.Context, req *openfgav1.WriteRequest)
 		return nil, err
 	}
 
-	onDuplicateInsert, err := parseOptionOnDuplicate(req.GetWrites())
+	onDuplicateInsert, err := parseOptionOnDuplicate(req.GetWrites(), c.duplicateWriteBehavior)
 	if err != nil {
 		return nil, err
 	}
@@ -114,6 +143,33 @@ func (c *WriteCommand) Execute(ctx context.Context, req *openfgav1.WriteRequest)
 	return &openfgav1.WriteResponse{}, nil
 }
 
+// ExecuteBestEffort writes the specified tuples without a transaction spanning all of them, so that the tuples
+// that can't be written, e.g. because they already exist, don't prevent the others from being written. It
+// returns the outcome of each tuple in the order of the request. Unlike Execute, the request can't have deletes,
+// and its on_duplicate option doesn't apply, as the tuples that already exist are reported as such.
+//
+// The request is validated as a whole beforehand, e.g. it's rejected if it exceeds the maximum number of tuples
+// per write. It fails if the datastore doesn't support best effort writes.
+func (c *WriteCommand) ExecuteBestEffort(ctx context.Context, req *openfgav1.WriteRequest) ([]storage.TupleWriteResult, error) {
+	if len(req.GetDeletes().GetTupleKeys()) > 0 {
+		return nil, serverErrors.ValidationError(errors.New("deletes are not supported by best effort writes"))
+	}
+
+	if err := c.validateWriteRequest(ctx, req); err != nil {
+		return nil, err
+	}
+
+	results, err := storage.WriteBestEffort(ctx, c.datastore, req.GetStoreId(), req.GetWrites().GetTupleKeys())
+	if err != nil {
+		if errors.Is(err, storage.ErrBestEffortWriteNotSupported) {
+			return nil, status.Error(codes.Unimplemented, err.Error())
+		}
+		return nil, serverErrors.HandleError("", err)
+	}
+
+	return results, nil
+}
+
 func (c *WriteCommand) validateWriteRequest(ctx context.Context, req *openfgav1.WriteRequest) error {
 	ctx, span := tracer.Start(ctx, "validateWriteRequest")
 	defer span.End()
@@ -127,6 +183,11 @@ func (c *WriteCommand) validateWriteRequest(ctx context.Context, req *openfgav1.
 		return serverErrors.ErrInvalidWriteInput
 	}
 
+	// conflicting tuples are rejected before anything is read from the datastore
+	if err := c.validateNoDuplicatesAndCorrectSize(deletes, writes); err != nil {
+		return err
+	}
+
 	if len(writes) > 0 {
 		authModel, err := c.datastore.ReadAuthorizationModel(ctx, store, modelID)
 		if err != nil {
@@ -178,37 +239,69 @@ func (c *WriteCommand) validateWriteRequest(ctx context.Context, req *openfgav1.
 		}
 	}
 
-	if err := c.validateNoDuplicatesAndCorrectSize(deletes, writes); err != nil {
-		return err
+	if len(writes) > 0 {
+		metadata, err := c.readStoreMetadata(ctx, store)
+		if err != nil && !errors.Is(err, storage.ErrNotFound) {
+			return serverErrors.HandleError("", err)
+		}
+
+		if err := c.validateObjectTypesAllowed(metadata, writes); err != nil {
+			return err
+		}
+
+		if err := c.validateNoCycles(ctx, store, metadata, deletes, writes); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// validateObjectTypesAllowed ensures the object types of the writes are in the object type allowlist of the store.
+// Deletes are not restricted, so that tuples of object types that were removed from the allowlist can be cleaned up.
+func (c *WriteCommand) validateObjectTypesAllowed(metadata *storage.StoreMetadata, writes []*openfgav1.TupleKey) error {
+	for _, tk := range writes {
+		objectType := tupleUtils.GetType(tk.GetObject())
+		if !metadata.IsObjectTypeAllowed(objectType) {
+			return serverErrors.ValidationError(&tupleUtils.InvalidTupleError{
+				Cause:    fmt.Errorf("object type '%s' is not in the object type allowlist of the store", objectType),
+				TupleKey: tk,
+			})
+		}
 	}
 
 	return nil
 }
 
 // validateNoDuplicatesAndCorrectSize ensures the deletes and writes contain no duplicates and length fits.
+// A tuple that is both written and deleted is rejected as a conflict, as the outcome would depend on the
+// order in which the datastore applies them.
 func (c *WriteCommand) validateNoDuplicatesAndCorrectSize(
 	deletes []*openfgav1.TupleKeyWithoutCondition,
 	writes []*openfgav1.TupleKey,
 ) error {
-	tuples := map[string]struct{}{}
-
+	deleted := make(map[string]struct{}, len(deletes))
 	for _, tk := range deletes {
 		key := tupleUtils.TupleKeyToString(tk)
-		if _, ok := tuples[key]; ok {
+		if _, ok := deleted[key]; ok {
 			return serverErrors.DuplicateTupleInWrite(tk)
 		}
-		tuples[key] = struct{}{}
+		deleted[key] = struct{}{}
 	}
 
+	written := make(map[string]struct{}, len(writes))
 	for _, tk := range writes {
 		key := tupleUtils.TupleKeyToString(tk)
-		if _, ok := tuples[key]; ok {
+		if _, ok := deleted[key]; ok {
+			return serverErrors.ConflictingTupleInWrite(tk)
+		}
+		if _, ok := written[key]; ok {
 			return serverErrors.DuplicateTupleInWrite(tk)
 		}
-		tuples[key] = struct{}{}
+		written[key] = struct{}{}
 	}
 
-	if len(tuples) > c.datastore.MaxTuplesPerWrite() {
+	if len(deleted)+len(written) > c.datastore.MaxTuplesPerWrite() {
 		return serverErrors.ExceededEntityLimit("write operations", c.datastore.MaxTuplesPerWrite())
 	}
 	return nil
@@ -227,3 +320,162 @@ func (c *WriteCommand) validateNotImplicit(
 	}
 	return nil
 }
+
+// cycleGuardMaxDepth and cycleGuardMaxReads bound the search of a cycle by validateNoCycles, so that the guard adds
+// at most cycleGuardMaxReads reads to a Write. The writes whose search exceeds them are rejected, as the Checks of
+// a relation that deep would exhaust the resolution depth anyway.
+const (
+	cycleGuardMaxDepth = 25
+	cycleGuardMaxReads = 1000
+)
+
+// cycleGuardEdge returns the object that the tuple relates its object to within a cycle guarded relation, e.g.
+// 'group:b' for 'group:a#member@group:b#member'. It returns false if the user of the tuple is not a userset of the
+// same relation, e.g. 'group:a#member@group:b', which can't be part of a cycle.
+func cycleGuardEdge(tk tupleUtils.TupleWithoutCondition) (string, bool) {
+	userObject, userRelation := tupleUtils.SplitObjectRelation(tk.GetUser())
+	if userRelation != tk.GetRelation() || tupleUtils.GetType(userObject) != tupleUtils.GetType(tk.GetObject()) {
+		return "", false
+	}
+	return userObject, true
+}
+
+// validateNoCycles ensures the writes of the relations that are cycle guarded by the store don't introduce a cycle
+// among the objects of the relation, e.g. group:a being a member of group:b while group:b is a member of group:a,
+// considering the tuples of the store as they would be after the deletes and writes of the request. Cycles make
+// Checks of the relation exhaust the resolution depth. Since the stored tuples are read for every guarded write, the
+// guard adds to the cost of the writes. It doesn't prevent cycles introduced by concurrent writes.
+func (c *WriteCommand) validateNoCycles(
+	ctx context.Context,
+	store string,
+	metadata *storage.StoreMetadata,
+	deletes []*openfgav1.TupleKeyWithoutCondition,
+	writes []*openfgav1.TupleKey,
+) error {
+	if len(metadata.GetCycleGuardedRelations()) == 0 {
+		return nil
+	}
+
+	deleted := make(map[string]struct{}, len(deletes))
+	for _, tk := range deletes {
+		deleted[tupleUtils.TupleKeyToString(tk)] = struct{}{}
+	}
+
+	// map: 'object#relation' => objects that the object is related to by the writes of the request
+	written := map[string][]string{}
+	for _, tk := range writes {
+		if !metadata.IsCycleGuarded(tupleUtils.GetType(tk.GetObject()), tk.GetRelation()) {
+			continue
+		}
+		if userObject, ok := cycleGuardEdge(tk); ok {
+			key := tupleUtils.ToObjectRelationString(tk.GetObject(), tk.GetRelation())
+			written[key] = append(written[key], userObject)
+		}
+	}
+
+	// the reads are bounded for the request as a whole
+	reads := 0
+	for _, tk := range writes {
+		if !metadata.IsCycleGuarded(tupleUtils.GetType(tk.GetObject()), tk.GetRelation()) {
+			continue
+		}
+
+		userObject, ok := cycleGuardEdge(tk)
+		if !ok {
+			continue
+		}
+
+		reachable, err := c.isReachable(ctx, store, tk.GetRelation(), userObject, tk.GetObject(), deleted, written, &reads)
+		if errors.Is(err, errCycleGuardLimitExceeded) {
+			return serverErrors.ValidationError(&tupleUtils.InvalidTupleError{
+				Cause:    fmt.Errorf("the relation '%s' is too deep to be checked for cycles", tupleUtils.ToObjectRelationString(tupleUtils.GetType(tk.GetObject()), tk.GetRelation())),
+				TupleKey: tk,
+			})
+		}
+		if err != nil {
+			return err
+		}
+		if reachable {
+			return serverErrors.ValidationError(&tupleUtils.InvalidTupleError{
+				Cause:    fmt.Errorf("the tuple would introduce a cycle in the relation '%s'", tupleUtils.ToObjectRelationString(tupleUtils.GetType(tk.GetObject()), tk.GetRelation())),
+				TupleKey: tk,
+			})
+		}
+	}
+
+	return nil
+}
+
+// errCycleGuardLimitExceeded is returned by isReachable when the search exceeds cycleGuardMaxDepth or
+// cycleGuardMaxReads.
+var errCycleGuardLimitExceeded = errors.New("cycle guard limit exceeded")
+
+// isReachable returns true if the target object is reachable from the source object by following the tuples of
+// the relation, including the tuples written by the request and excluding the tuples deleted by it. The reads
+// of the search are added to reads.
+func (c *WriteCommand) isReachable(
+	ctx context.Context,
+	store, relation, source, target string,
+	deleted map[string]struct{},
+	written map[string][]string,
+	reads *int,
+) (bool, error) {
+	type queued struct {
+		object string
+		depth  int
+	}
+
+	visited := map[string]struct{}{source: {}}
+	queue := []queued{{object: source}}
+
+	for len(queue) > 0 {
+		object, depth := queue[0].object, queue[0].depth
+		queue = queue[1:]
+
+		if object == target {
+			return true, nil
+		}
+
+		if depth >= cycleGuardMaxDepth || *reads >= cycleGuardMaxReads {
+			return false, errCycleGuardLimitExceeded
+		}
+		*reads++
+
+		next := written[tupleUtils.ToObjectRelationString(object, relation)]
+
+		iter, err := c.datastore.Read(ctx, store, tupleUtils.NewTupleKey(object, relation, ""), storage.ReadOptions{
+			Consistency: storage.ConsistencyOptions{Preference: openfgav1.ConsistencyPreference_HIGHER_CONSISTENCY},
+		})
+		if err != nil {
+			return false, serverErrors.HandleError("", err)
+		}
+
+		for {
+			t, err := iter.Next(ctx)
+			if err != nil {
+				iter.Stop()
+				if errors.Is(err, storage.ErrIteratorDone) {
+					break
+				}
+				return false, serverErrors.HandleError("", err)
+			}
+
+			tk := t.GetKey()
+			if _, ok := deleted[tupleUtils.TupleKeyToString(tk)]; ok {
+				continue
+			}
+			if userObject, ok := cycleGuardEdge(tk); ok {
+				next = append(next, userObject)
+			}
+		}
+
+		for _, userObject := range next {
+			if _, ok := visited[userObject]; !ok {
+				visited[userObject] = struct{}{}
+				queue = append(queue, queued{object: userObject, depth: depth + 1})
+			}
+		}
+	}
+
+	return false, nil
+}
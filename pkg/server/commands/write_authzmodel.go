@@ -3,6 +3,7 @@ package commands
 import (
 	"context"
 	"fmt"
+	"sort"
 
 	"github.com/oklog/ulid/v2"
 	"google.golang.org/grpc/codes"
@@ -23,6 +24,7 @@ type WriteAuthorizationModelCommand struct {
 	backend                          storage.TypeDefinitionWriteBackend
 	logger                           logger.Logger
 	maxAuthorizationModelSizeInBytes int
+	maxRelationBranches              int
 }
 
 type WriteAuthModelOption func(*WriteAuthorizationModelCommand)
@@ -39,11 +41,20 @@ func WithWriteAuthModelMaxSizeInBytes(size int) WriteAuthModelOption {
 	}
 }
 
+// WithWriteAuthModelMaxRelationBranches sets the maximum number of branches allowed in the rewrite
+// of a single relation, see typesystem.RewriteBranchCount.
+func WithWriteAuthModelMaxRelationBranches(limit int) WriteAuthModelOption {
+	return func(m *WriteAuthorizationModelCommand) {
+		m.maxRelationBranches = limit
+	}
+}
+
 func NewWriteAuthorizationModelCommand(backend storage.TypeDefinitionWriteBackend, opts ...WriteAuthModelOption) *WriteAuthorizationModelCommand {
 	model := &WriteAuthorizationModelCommand{
 		backend:                          backend,
 		logger:                           logger.NewNoopLogger(),
 		maxAuthorizationModelSizeInBytes: serverconfig.DefaultMaxAuthorizationModelSizeInBytes,
+		maxRelationBranches:              serverconfig.DefaultMaxRelationBranches,
 	}
 
 	for _, opt := range opts {
@@ -54,11 +65,31 @@ func NewWriteAuthorizationModelCommand(backend storage.TypeDefinitionWriteBacken
 
 // Execute the command using the supplied request.
 func (w *WriteAuthorizationModelCommand) Execute(ctx context.Context, req *openfgav1.WriteAuthorizationModelRequest) (*openfgav1.WriteAuthorizationModelResponse, error) {
-	// Until this is solved: https://github.com/envoyproxy/protoc-gen-validate/issues/74
-	if len(req.GetTypeDefinitions()) > w.backend.MaxTypesPerAuthorizationModel() {
-		return nil, serverErrors.ExceededEntityLimit("type definitions in an authorization model", w.backend.MaxTypesPerAuthorizationModel())
+	model, err := w.validatedModel(ctx, req)
+	if err != nil {
+		return nil, err
 	}
 
+	err = w.backend.WriteAuthorizationModel(ctx, req.GetStoreId(), model)
+	if err != nil {
+		return nil, serverErrors.
+			HandleError("Error writing authorization model configuration", err)
+	}
+
+	return &openfgav1.WriteAuthorizationModelResponse{
+		AuthorizationModelId: model.GetId(),
+	}, nil
+}
+
+// Validate validates the authorization model of the request as Execute does, and returns the same errors,
+// without writing it.
+func (w *WriteAuthorizationModelCommand) Validate(ctx context.Context, req *openfgav1.WriteAuthorizationModelRequest) error {
+	_, err := w.validatedModel(ctx, req)
+	return err
+}
+
+// validatedModel builds the authorization model of the request, with a new ID, and validates it.
+func (w *WriteAuthorizationModelCommand) validatedModel(ctx context.Context, req *openfgav1.WriteAuthorizationModelRequest) (*openfgav1.AuthorizationModel, error) {
 	// Fill in the schema version for old requests, which don't contain it, while we migrate to the new schema version.
 	if req.GetSchemaVersion() == "" {
 		req.SchemaVersion = typesystem.SchemaVersion1_1
@@ -71,28 +102,62 @@ func (w *WriteAuthorizationModelCommand) Execute(ctx context.Context, req *openf
 		Conditions:      req.GetConditions(),
 	}
 
+	if err := w.validateModel(ctx, model); err != nil {
+		return nil, err
+	}
+
+	return model, nil
+}
+
+// validateModel validates the model against the limits of the command and the rules of the typesystem.
+func (w *WriteAuthorizationModelCommand) validateModel(ctx context.Context, model *openfgav1.AuthorizationModel) error {
+	// Until this is solved: https://github.com/envoyproxy/protoc-gen-validate/issues/74
+	if len(model.GetTypeDefinitions()) > w.backend.MaxTypesPerAuthorizationModel() {
+		return serverErrors.ExceededEntityLimit("type definitions in an authorization model", w.backend.MaxTypesPerAuthorizationModel())
+	}
+
 	// Validate the size in bytes of the wire-format encoding of the authorization model.
 	modelSize := proto.Size(model)
 	if modelSize > w.maxAuthorizationModelSizeInBytes {
 		// Consider using serverErrors.ExceededEntityLimit.
-		return nil, status.Error(
+		return status.Error(
 			codes.Code(openfgav1.ErrorCode_exceeded_entity_limit),
 			fmt.Sprintf("model exceeds size limit: %d bytes vs %d bytes", modelSize, w.maxAuthorizationModelSizeInBytes),
 		)
 	}
 
-	_, err := typesystem.NewAndValidate(ctx, model)
-	if err != nil {
-		return nil, serverErrors.InvalidAuthorizationModelInput(err)
+	// Bound the breadth of every relation before the model is validated, as a relation with many
+	// branches fans out to as many subproblems when it is resolved.
+	if err := w.validateRelationBranches(model); err != nil {
+		return err
 	}
 
-	err = w.backend.WriteAuthorizationModel(ctx, req.GetStoreId(), model)
-	if err != nil {
-		return nil, serverErrors.
-			HandleError("Error writing authorization model configuration", err)
+	if _, err := typesystem.NewAndValidate(ctx, model); err != nil {
+		return serverErrors.InvalidAuthorizationModelInput(err)
 	}
 
-	return &openfgav1.WriteAuthorizationModelResponse{
-		AuthorizationModelId: model.GetId(),
-	}, nil
+	return nil
+}
+
+// validateRelationBranches returns an error if the rewrite of any relation of the model has more
+// branches than allowed.
+func (w *WriteAuthorizationModelCommand) validateRelationBranches(model *openfgav1.AuthorizationModel) error {
+	for _, td := range model.GetTypeDefinitions() {
+		relations := make([]string, 0, len(td.GetRelations()))
+		for relation := range td.GetRelations() {
+			relations = append(relations, relation)
+		}
+		sort.Strings(relations)
+
+		for _, relation := range relations {
+			if typesystem.RewriteBranchCount(td.GetRelations()[relation]) > w.maxRelationBranches {
+				return serverErrors.ExceededEntityLimit(
+					fmt.Sprintf("branches in the definition of relation '%s' in object type '%s'", relation, td.GetType()),
+					w.maxRelationBranches,
+				)
+			}
+		}
+	}
+
+	return nil
 }
@@ -783,7 +783,7 @@ func TestWriteAuthorizationModel(t *testing.T) {
 							define z: [user] or x`).GetTypeDefinitions(),
 			},
 			errCode:    codes.Code(openfgav1.ErrorCode_invalid_authorization_model),
-			errMessage: "the definition of relation 'x' in object type 'other' is invalid: an authorization model cannot contain a cycle",
+			errMessage: "the definition of relation 'x' in object type 'other' is invalid: an authorization model cannot contain a cycle: other#x -> other#y -> other#z -> other#x",
 		},
 		`fail_model_size`: {
 			setMock: func(mockDatastore *mockstorage.MockOpenFGADatastore) {},
@@ -1287,3 +1287,166 @@ func buildModelWithManyTypes(maxTypesPerAuthorizationModel int) []*openfgav1.Typ
 	}
 	return items
 }
+
+func TestWriteAuthorizationModelWithMaxRelationBranches(t *testing.T) {
+	t.Cleanup(func() {
+		goleak.VerifyNone(t)
+	})
+
+	ctx := context.Background()
+	storeID := ulid.Make().String()
+	const maxRelationBranches = 4
+
+	tests := map[string]struct {
+		model      string
+		errMessage string
+	}{
+		`allow_relation_at_the_limit`: {
+			model: `
+				model
+					schema 1.1
+				type user
+				type folder
+					relations
+						define viewer: [user]
+				type document
+					relations
+						define blocked: [user]
+						define owner: [user]
+						define parent: [folder]
+						define viewer: [user] or owner or (viewer from parent but not blocked)`,
+		},
+		`reject_union_over_the_limit`: {
+			model: `
+				model
+					schema 1.1
+				type user
+				type document
+					relations
+						define admin: [user]
+						define editor: [user]
+						define owner: [user]
+						define viewer: [user] or admin or editor or owner or viewer`,
+			errMessage: "The number of branches in the definition of relation 'viewer' in object type 'document' exceeds the allowed limit of 4",
+		},
+		`reject_nested_rewrites_over_the_limit`: {
+			model: `
+				model
+					schema 1.1
+				type user
+				type document
+					relations
+						define admin: [user]
+						define blocked: [user]
+						define editor: [user]
+						define owner: [user]
+						define viewer: [user] or admin or (editor but not blocked) or owner`,
+			errMessage: "The number of branches in the definition of relation 'viewer' in object type 'document' exceeds the allowed limit of 4",
+		},
+	}
+
+	for testName, test := range tests {
+		t.Run(testName, func(t *testing.T) {
+			mockController := gomock.NewController(t)
+			defer mockController.Finish()
+
+			mockDatastore := mockstorage.NewMockOpenFGADatastore(mockController)
+			mockDatastore.EXPECT().MaxTypesPerAuthorizationModel().AnyTimes().Return(serverconfig.DefaultMaxTypesPerAuthorizationModel)
+			if test.errMessage == "" {
+				mockDatastore.EXPECT().WriteAuthorizationModel(gomock.Any(), storeID, gomock.Any()).Return(nil)
+			}
+
+			cmd := NewWriteAuthorizationModelCommand(mockDatastore, WithWriteAuthModelMaxRelationBranches(maxRelationBranches))
+			resp, err := cmd.Execute(ctx, &openfgav1.WriteAuthorizationModelRequest{
+				StoreId:         storeID,
+				SchemaVersion:   typesystem.SchemaVersion1_1,
+				TypeDefinitions: parser.MustTransformDSLToProto(test.model).GetTypeDefinitions(),
+			})
+			if test.errMessage != "" {
+				st, ok := status.FromError(err)
+				require.True(t, ok)
+				require.Equal(t, codes.Code(openfgav1.ErrorCode_exceeded_entity_limit), st.Code())
+				require.Equal(t, test.errMessage, st.Message())
+				return
+			}
+
+			require.NoError(t, err)
+			require.NotEmpty(t, resp.GetAuthorizationModelId())
+		})
+	}
+}
+
+func TestValidateAuthorizationModel(t *testing.T) {
+	t.Cleanup(func() {
+		goleak.VerifyNone(t)
+	})
+
+	ctx := context.Background()
+	storeID := ulid.Make().String()
+
+	tests := map[string]struct {
+		model   string
+		errCode codes.Code
+	}{
+		`valid_model`: {
+			model: `
+				model
+					schema 1.1
+				type user
+				type document
+					relations
+						define viewer: [user]`,
+		},
+		`cyclic_model`: {
+			model: `
+				model
+					schema 1.1
+				type user
+				type document
+					relations
+						define editor: viewer
+						define viewer: editor`,
+			errCode: codes.Code(openfgav1.ErrorCode_invalid_authorization_model),
+		},
+		`undefined_relation`: {
+			model: `
+				model
+					schema 1.1
+				type user
+				type document
+					relations
+						define viewer: [user] or owner`,
+			errCode: codes.Code(openfgav1.ErrorCode_invalid_authorization_model),
+		},
+	}
+
+	for testName, test := range tests {
+		t.Run(testName, func(t *testing.T) {
+			mockController := gomock.NewController(t)
+			defer mockController.Finish()
+
+			// the model is never written
+			mockDatastore := mockstorage.NewMockOpenFGADatastore(mockController)
+			mockDatastore.EXPECT().MaxTypesPerAuthorizationModel().AnyTimes().Return(serverconfig.DefaultMaxTypesPerAuthorizationModel)
+
+			req := &openfgav1.WriteAuthorizationModelRequest{
+				StoreId:         storeID,
+				SchemaVersion:   typesystem.SchemaVersion1_1,
+				TypeDefinitions: parser.MustTransformDSLToProto(test.model).GetTypeDefinitions(),
+			}
+			err := NewWriteAuthorizationModelCommand(mockDatastore).Validate(ctx, req)
+			if test.errCode == codes.OK {
+				require.NoError(t, err)
+				return
+			}
+
+			st, ok := status.FromError(err)
+			require.True(t, ok)
+			require.Equal(t, test.errCode, st.Code())
+
+			// the write fails with the same error
+			_, writeErr := NewWriteAuthorizationModelCommand(mockDatastore).Execute(ctx, req)
+			require.Equal(t, err.Error(), writeErr.Error())
+		})
+	}
+}
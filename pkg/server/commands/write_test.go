@@ -8,8 +8,11 @@ import (
 
 	"github.com/google/go-cmp/cmp"
 	"github.com/google/go-cmp/cmp/cmpopts"
+	"github.com/oklog/ulid/v2"
 	"github.com/stretchr/testify/require"
 	"go.uber.org/mock/gomock"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 	"google.golang.org/protobuf/testing/protocmp"
 
 	openfgav1 "github.com/openfga/api/proto/openfga/v1"
@@ -17,7 +20,9 @@ import (
 
 	mockstorage "github.com/openfga/openfga/internal/mocks"
 	"github.com/openfga/openfga/pkg/server/config"
+	serverErrors "github.com/openfga/openfga/pkg/server/errors"
 	"github.com/openfga/openfga/pkg/storage"
+	"github.com/openfga/openfga/pkg/storage/memory"
 	"github.com/openfga/openfga/pkg/testutils"
 	"github.com/openfga/openfga/pkg/tuple"
 )
@@ -128,10 +133,8 @@ func TestWriteCommand(t *testing.T) {
 			expectedError: "rpc error: code = Code(2004) desc = duplicate tuple in write: user: 'user:0', relation: 'viewer', object: 'document:0'",
 		},
 		{
-			name: "duplicate_writes",
-			setMock: func(mockDatastore *mockstorage.MockOpenFGADatastore) {
-				mockDatastore.EXPECT().ReadAuthorizationModel(gomock.Any(), storeID, modelID).Times(1).Return(model, nil)
-			},
+			name:    "duplicate_writes",
+			setMock: func(mockDatastore *mockstorage.MockOpenFGADatastore) {},
 			writes: &openfgav1.WriteRequestWrites{
 				TupleKeys: []*openfgav1.TupleKey{
 					tuple.TupleKeyWithoutConditionToTupleKey(items[0]),
@@ -142,10 +145,8 @@ func TestWriteCommand(t *testing.T) {
 			expectedError: "rpc error: code = Code(2004) desc = duplicate tuple in write: user: 'user:0', relation: 'viewer', object: 'document:0'",
 		},
 		{
-			name: "same_item_appeared_in_writes_and_deletes",
-			setMock: func(mockDatastore *mockstorage.MockOpenFGADatastore) {
-				mockDatastore.EXPECT().ReadAuthorizationModel(gomock.Any(), storeID, modelID).Times(1).Return(model, nil)
-			},
+			name:    "same_item_appeared_in_writes_and_deletes",
+			setMock: func(mockDatastore *mockstorage.MockOpenFGADatastore) {},
 			deletes: &openfgav1.WriteRequestDeletes{
 				TupleKeys: []*openfgav1.TupleKeyWithoutCondition{items[2], items[1]},
 			},
@@ -155,13 +156,28 @@ func TestWriteCommand(t *testing.T) {
 					tuple.TupleKeyWithoutConditionToTupleKey(items[1]),
 				},
 			},
-			expectedError: "rpc error: code = Code(2004) desc = duplicate tuple in write: user: 'user:1', relation: 'viewer', object: 'document:1'",
+			expectedError: "rpc error: code = Code(2004) desc = tuple is both written and deleted in write: user: 'user:1', relation: 'viewer', object: 'document:1'",
 		},
 		{
-			name: "too_many_items_writes_and_deletes",
+			name: "writes_and_deletes_of_different_tuples",
 			setMock: func(mockDatastore *mockstorage.MockOpenFGADatastore) {
-				mockDatastore.EXPECT().ReadAuthorizationModel(gomock.Any(), storeID, modelID).Times(1).Return(model, nil)
+				mockDatastore.EXPECT().ReadAuthorizationModel(gomock.Any(), storeID, modelID).Return(model, nil)
+				mockDatastore.EXPECT().Write(gomock.Any(), storeID, gomock.Any(), gomock.Any(), gomock.Any()).Return(nil)
 			},
+			deletes: &openfgav1.WriteRequestDeletes{
+				TupleKeys: []*openfgav1.TupleKeyWithoutCondition{items[2]},
+			},
+			writes: &openfgav1.WriteRequestWrites{
+				TupleKeys: []*openfgav1.TupleKey{
+					tuple.TupleKeyWithoutConditionToTupleKey(items[0]),
+					tuple.TupleKeyWithoutConditionToTupleKey(items[1]),
+				},
+			},
+			expectedResponse: &openfgav1.WriteResponse{},
+		},
+		{
+			name:    "too_many_items_writes_and_deletes",
+			setMock: func(mockDatastore *mockstorage.MockOpenFGADatastore) {},
 			deletes: &openfgav1.WriteRequestDeletes{
 				TupleKeys: items[:5],
 			},
@@ -931,6 +947,65 @@ func TestWriteCommand(t *testing.T) {
 			},
 			expectedResponse: &openfgav1.WriteResponse{},
 		},
+		{
+			name: "writes_with_upsert_option",
+			setMock: func(mockDatastore *mockstorage.MockOpenFGADatastore) {
+				mockDatastore.EXPECT().ReadAuthorizationModel(gomock.Any(), storeID, modelID).Return(model, nil)
+				mockDatastore.EXPECT().Write(gomock.Any(), storeID, gomock.Any(), gomock.Any(),
+					NewWriteOptsMatcher(storage.OnDuplicateInsertUpsert, storage.OnMissingDeleteError)).Return(nil)
+			},
+			writes: &openfgav1.WriteRequestWrites{
+				TupleKeys: []*openfgav1.TupleKey{{
+					Object:   "document:1",
+					Relation: "viewer",
+					User:     "user:maria",
+				}},
+				OnDuplicate: "upsert",
+			},
+			expectedResponse: &openfgav1.WriteResponse{},
+		},
+		{
+			name: "writes_object_type_in_allowlist",
+			setMock: func(mockDatastore *mockstorage.MockOpenFGADatastore) {
+				mockDatastore.EXPECT().ReadAuthorizationModel(gomock.Any(), storeID, modelID).Return(model, nil)
+				mockDatastore.EXPECT().ReadStoreMetadata(gomock.Any(), storeID).Return(&storage.StoreMetadata{
+					ObjectTypeAllowlist: []string{"document"},
+				}, nil)
+				mockDatastore.EXPECT().Write(gomock.Any(), storeID, gomock.Any(), gomock.Any(), gomock.Any()).Return(nil)
+			},
+			writes: &openfgav1.WriteRequestWrites{
+				TupleKeys: []*openfgav1.TupleKey{{
+					Object:   "document:1",
+					Relation: "viewer",
+					User:     "user:maria",
+				}},
+			},
+			expectedResponse: &openfgav1.WriteResponse{},
+		},
+		{
+			name: "rejects_object_type_not_in_allowlist",
+			setMock: func(mockDatastore *mockstorage.MockOpenFGADatastore) {
+				mockDatastore.EXPECT().ReadAuthorizationModel(gomock.Any(), storeID, modelID).Return(model, nil)
+				mockDatastore.EXPECT().ReadStoreMetadata(gomock.Any(), storeID).Return(&storage.StoreMetadata{
+					ObjectTypeAllowlist: []string{"document"},
+				}, nil)
+			},
+			writes: &openfgav1.WriteRequestWrites{
+				TupleKeys: []*openfgav1.TupleKey{
+					{
+						Object:   "document:1",
+						Relation: "viewer",
+						User:     "user:maria",
+					},
+					{
+						Object:   "org:1",
+						Relation: "viewer",
+						User:     "user:maria",
+					},
+				},
+			},
+			expectedError: "rpc error: code = Code(2000) desc = Invalid tuple 'org:1#viewer@user:maria'. Reason: object type 'org' is not in the object type allowlist of the store",
+		},
 	}
 
 	for _, test := range tests {
@@ -940,6 +1015,7 @@ func TestWriteCommand(t *testing.T) {
 			mockDatastore := mockstorage.NewMockOpenFGADatastore(mockController)
 			mockDatastore.EXPECT().MaxTuplesPerWrite().AnyTimes().Return(maxTuplesInWriteOperation)
 			test.setMock(mockDatastore)
+			mockDatastore.EXPECT().ReadStoreMetadata(gomock.Any(), storeID).AnyTimes().Return(&storage.StoreMetadata{}, nil)
 
 			resp, err := NewWriteCommand(mockDatastore).Execute(context.Background(), &openfgav1.WriteRequest{
 				StoreId:              storeID,
@@ -965,3 +1041,150 @@ func TestWriteCommand(t *testing.T) {
 		})
 	}
 }
+
+func TestWriteCommandDuplicateWriteBehavior(t *testing.T) {
+	const (
+		storeID = "01JCC8Z5S039R3X661KQGTNAFG"
+		modelID = "01JCC8ZD4X84K2W0H0ZA5AQ947"
+	)
+
+	model := parser.MustTransformDSLToProto(`
+	model
+		schema 1.1
+	type user
+	type document
+		relations
+			define viewer: [user]`)
+
+	tests := []struct {
+		name        string
+		behavior    config.DuplicateWriteBehavior
+		onDuplicate string
+		expected    storage.OnDuplicateInsert
+	}{
+		{
+			name:     "default_ignore",
+			behavior: config.DuplicateWriteIgnore,
+			expected: storage.OnDuplicateInsertIgnore,
+		},
+		{
+			name:     "default_upsert",
+			behavior: config.DuplicateWriteUpsert,
+			expected: storage.OnDuplicateInsertUpsert,
+		},
+		{
+			name:        "request_option_overrides_default",
+			behavior:    config.DuplicateWriteUpsert,
+			onDuplicate: "error",
+			expected:    storage.OnDuplicateInsertError,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			mockController := gomock.NewController(t)
+			defer mockController.Finish()
+			mockDatastore := mockstorage.NewMockOpenFGADatastore(mockController)
+			mockDatastore.EXPECT().MaxTuplesPerWrite().AnyTimes().Return(10)
+			mockDatastore.EXPECT().ReadAuthorizationModel(gomock.Any(), storeID, modelID).Return(model, nil)
+			mockDatastore.EXPECT().ReadStoreMetadata(gomock.Any(), storeID).AnyTimes().Return(&storage.StoreMetadata{}, nil)
+			mockDatastore.EXPECT().Write(gomock.Any(), storeID, gomock.Any(), gomock.Any(),
+				NewWriteOptsMatcher(test.expected, storage.OnMissingDeleteError)).Return(nil)
+
+			_, err := NewWriteCommand(mockDatastore, WithDuplicateWriteBehavior(test.behavior)).Execute(context.Background(), &openfgav1.WriteRequest{
+				StoreId:              storeID,
+				AuthorizationModelId: modelID,
+				Writes: &openfgav1.WriteRequestWrites{
+					TupleKeys:   []*openfgav1.TupleKey{tuple.NewTupleKey("document:1", "viewer", "user:maria")},
+					OnDuplicate: test.onDuplicate,
+				},
+			})
+			require.NoError(t, err)
+		})
+	}
+}
+
+func TestWriteCommandBestEffort(t *testing.T) {
+	model := testutils.MustTransformDSLToProtoWithID(`
+	model
+		schema 1.1
+	type user
+	type document
+		relations
+			define viewer: [user]`)
+
+	t.Run("reports_the_outcome_of_each_tuple", func(t *testing.T) {
+		ds := memory.New()
+		t.Cleanup(ds.Close)
+
+		storeID := ulid.Make().String()
+		require.NoError(t, ds.WriteAuthorizationModel(context.Background(), storeID, model))
+		require.NoError(t, ds.Write(context.Background(), storeID, nil, []*openfgav1.TupleKey{
+			tuple.NewTupleKey("document:1", "viewer", "user:maria"),
+		}))
+
+		results, err := NewWriteCommand(ds).ExecuteBestEffort(context.Background(), &openfgav1.WriteRequest{
+			StoreId:              storeID,
+			AuthorizationModelId: model.GetId(),
+			Writes: &openfgav1.WriteRequestWrites{
+				TupleKeys: []*openfgav1.TupleKey{
+					tuple.NewTupleKey("document:1", "viewer", "user:maria"),
+					tuple.NewTupleKey("document:1", "viewer", "user:jon"),
+				},
+			},
+		})
+		require.NoError(t, err)
+		require.Len(t, results, 2)
+		require.Equal(t, storage.TupleWriteStatusAlreadyExists, results[0].Status)
+		require.Equal(t, storage.TupleWriteStatusWritten, results[1].Status)
+	})
+
+	t.Run("rejects_requests_exceeding_the_max_tuples_per_write", func(t *testing.T) {
+		ds := memory.New(memory.WithMaxTuplesPerWrite(1))
+		t.Cleanup(ds.Close)
+
+		_, err := NewWriteCommand(ds).ExecuteBestEffort(context.Background(), &openfgav1.WriteRequest{
+			StoreId:              ulid.Make().String(),
+			AuthorizationModelId: model.GetId(),
+			Writes: &openfgav1.WriteRequestWrites{
+				TupleKeys: []*openfgav1.TupleKey{
+					tuple.NewTupleKey("document:1", "viewer", "user:maria"),
+					tuple.NewTupleKey("document:1", "viewer", "user:jon"),
+				},
+			},
+		})
+		require.ErrorIs(t, err, serverErrors.ExceededEntityLimit("write operations", 1))
+	})
+
+	t.Run("rejects_deletes", func(t *testing.T) {
+		mockController := gomock.NewController(t)
+		mockDatastore := mockstorage.NewMockOpenFGADatastore(mockController)
+
+		_, err := NewWriteCommand(mockDatastore).ExecuteBestEffort(context.Background(), &openfgav1.WriteRequest{
+			StoreId: ulid.Make().String(),
+			Deletes: &openfgav1.WriteRequestDeletes{
+				TupleKeys: []*openfgav1.TupleKeyWithoutCondition{
+					{Object: "document:1", Relation: "viewer", User: "user:maria"},
+				},
+			},
+		})
+		require.ErrorContains(t, err, "deletes are not supported by best effort writes")
+	})
+
+	t.Run("datastore_without_support", func(t *testing.T) {
+		mockController := gomock.NewController(t)
+		mockDatastore := mockstorage.NewMockOpenFGADatastore(mockController)
+		mockDatastore.EXPECT().MaxTuplesPerWrite().AnyTimes().Return(10)
+		mockDatastore.EXPECT().ReadAuthorizationModel(gomock.Any(), gomock.Any(), model.GetId()).Return(model, nil)
+		mockDatastore.EXPECT().ReadStoreMetadata(gomock.Any(), gomock.Any()).AnyTimes().Return(&storage.StoreMetadata{}, nil)
+
+		_, err := NewWriteCommand(mockDatastore).ExecuteBestEffort(context.Background(), &openfgav1.WriteRequest{
+			StoreId:              ulid.Make().String(),
+			AuthorizationModelId: model.GetId(),
+			Writes: &openfgav1.WriteRequestWrites{
+				TupleKeys: []*openfgav1.TupleKey{tuple.NewTupleKey("document:1", "viewer", "user:maria")},
+			},
+		})
+		require.Equal(t, codes.Unimplemented, status.Code(err))
+	})
+}
@@ -0,0 +1,81 @@
+package server
+
+import (
+	"maps"
+	"time"
+
+	"google.golang.org/protobuf/types/known/structpb"
+
+	openfgav1 "github.com/openfga/api/proto/openfga/v1"
+
+	"github.com/openfga/openfga/internal/clock"
+	"github.com/openfga/openfga/pkg/typesystem"
+)
+
+// CurrentTimeConditionParameter is the condition parameter that the server sets to the current time, if a
+// condition of the model declares it as a timestamp and the request doesn't provide it. It allows
+// conditions such as `current_time < grant_expiry` without the clients sending the time.
+const CurrentTimeConditionParameter = "current_time"
+
+// WithConditionCurrentTimeGranularity sets the granularity of the current time provided to the conditions,
+// see CurrentTimeConditionParameter. The current time is rounded up to a multiple of it, so that the
+// requests made within the same interval share their cache entries. Rounding up makes the grants that
+// expire at the current time, e.g. `current_time < grant_expiry`, expire early rather than late, but it
+// makes the grants that start at a time, e.g. `current_time > grant_start`, apply up to one granularity
+// early. Models with such grants should use a small granularity, or have their clients send the current
+// time. 0 disables providing the current time.
+func WithConditionCurrentTimeGranularity(granularity time.Duration) OpenFGAServiceV1Option {
+	return func(s *Server) {
+		s.conditionCurrentTimeGranularity = granularity
+	}
+}
+
+// WithConditionClock sets the clock that the current time provided to the conditions is read from. It
+// defaults to the system clock.
+func WithConditionClock(c clock.Clock) OpenFGAServiceV1Option {
+	return func(s *Server) {
+		s.conditionClock = c
+	}
+}
+
+// conditionContext returns the context of a request with the current time added, if a condition of the
+// model declares the CurrentTimeConditionParameter and the context doesn't already provide it. The context
+// of the request isn't modified.
+func (s *Server) conditionContext(typesys *typesystem.TypeSystem, reqContext *structpb.Struct) *structpb.Struct {
+	if s.conditionCurrentTimeGranularity <= 0 {
+		return reqContext
+	}
+
+	if _, ok := reqContext.GetFields()[CurrentTimeConditionParameter]; ok {
+		return reqContext
+	}
+
+	if !declaresCurrentTime(typesys) {
+		return reqContext
+	}
+
+	// the time is rounded towards the expiry of the grants, a grant never outlives it, which in turn starts the
+	// grants that aren't valid before a time up to one granularity early
+	now := s.conditionClock.Now().UTC()
+	if rounded := now.Truncate(s.conditionCurrentTimeGranularity); !rounded.Equal(now) {
+		now = rounded.Add(s.conditionCurrentTimeGranularity)
+	}
+
+	fields := make(map[string]*structpb.Value, len(reqContext.GetFields())+1)
+	maps.Copy(fields, reqContext.GetFields())
+	fields[CurrentTimeConditionParameter] = structpb.NewStringValue(now.Format(time.RFC3339Nano))
+
+	return &structpb.Struct{Fields: fields}
+}
+
+// declaresCurrentTime returns true if a condition of the model declares the CurrentTimeConditionParameter
+// as a timestamp.
+func declaresCurrentTime(typesys *typesystem.TypeSystem) bool {
+	for _, cond := range typesys.GetConditions() {
+		param, ok := cond.GetParameters()[CurrentTimeConditionParameter]
+		if ok && param.GetTypeName() == openfgav1.ConditionParamTypeRef_TYPE_NAME_TIMESTAMP {
+			return true
+		}
+	}
+	return false
+}
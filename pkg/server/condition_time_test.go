@@ -0,0 +1,215 @@
+package server
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"go.uber.org/goleak"
+	"google.golang.org/protobuf/types/known/structpb"
+
+	openfgav1 "github.com/openfga/api/proto/openfga/v1"
+	parser "github.com/openfga/language/pkg/go/transformer"
+
+	"github.com/openfga/openfga/internal/clock"
+	"github.com/openfga/openfga/pkg/storage/memory"
+	"github.com/openfga/openfga/pkg/tuple"
+	"github.com/openfga/openfga/pkg/typesystem"
+)
+
+func TestConditionCurrentTime(t *testing.T) {
+	t.Cleanup(func() {
+		goleak.VerifyNone(t)
+	})
+
+	ctx := context.Background()
+	start := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+
+	model := parser.MustTransformDSLToProto(`
+		model
+			schema 1.1
+		type user
+		type document
+			relations
+				define viewer: [user with non_expired]
+		condition non_expired(current_time: timestamp, expiry: timestamp) {
+			current_time < expiry
+		}`)
+
+	setup := func(t *testing.T, opts ...OpenFGAServiceV1Option) (*Server, *clock.Fake, string) {
+		fakeClock := clock.NewFake(start)
+		s := MustNewServerWithOpts(append([]OpenFGAServiceV1Option{
+			WithDatastore(memory.New()),
+			WithConditionClock(fakeClock),
+		}, opts...)...)
+		t.Cleanup(s.Close)
+
+		createStoreResp, err := s.CreateStore(ctx, &openfgav1.CreateStoreRequest{Name: "condition-current-time"})
+		require.NoError(t, err)
+		storeID := createStoreResp.GetId()
+
+		_, err = s.WriteAuthorizationModel(ctx, &openfgav1.WriteAuthorizationModelRequest{
+			StoreId:         storeID,
+			TypeDefinitions: model.GetTypeDefinitions(),
+			SchemaVersion:   model.GetSchemaVersion(),
+			Conditions:      model.GetConditions(),
+		})
+		require.NoError(t, err)
+
+		expiry, err := structpb.NewStruct(map[string]interface{}{"expiry": start.Add(time.Hour).Format(time.RFC3339)})
+		require.NoError(t, err)
+		_, err = s.Write(ctx, &openfgav1.WriteRequest{
+			StoreId: storeID,
+			Writes: &openfgav1.WriteRequestWrites{
+				TupleKeys: []*openfgav1.TupleKey{
+					tuple.NewTupleKeyWithCondition("document:1", "viewer", "user:anne", "non_expired", expiry),
+				},
+			},
+		})
+		require.NoError(t, err)
+
+		return s, fakeClock, storeID
+	}
+
+	check := func(t *testing.T, s *Server, storeID string, reqContext *structpb.Struct) *openfgav1.CheckResponse {
+		resp, err := s.Check(ctx, &openfgav1.CheckRequest{
+			StoreId:  storeID,
+			TupleKey: tuple.NewCheckRequestTupleKey("document:1", "viewer", "user:anne"),
+			Context:  reqContext,
+		})
+		require.NoError(t, err)
+		return resp
+	}
+
+	listObjects := func(t *testing.T, s *Server, storeID string) []string {
+		resp, err := s.ListObjects(ctx, &openfgav1.ListObjectsRequest{
+			StoreId:  storeID,
+			Type:     "document",
+			Relation: "viewer",
+			User:     "user:anne",
+		})
+		require.NoError(t, err)
+		return resp.GetObjects()
+	}
+
+	t.Run("grants_expire_with_the_server_clock", func(t *testing.T) {
+		s, fakeClock, storeID := setup(t)
+
+		require.True(t, check(t, s, storeID, nil).GetAllowed())
+		require.Equal(t, []string{"document:1"}, listObjects(t, s, storeID))
+
+		fakeClock.Advance(2 * time.Hour)
+		require.False(t, check(t, s, storeID, nil).GetAllowed())
+		require.Empty(t, listObjects(t, s, storeID))
+	})
+
+	t.Run("grants_expire_no_later_than_their_expiry", func(t *testing.T) {
+		s, fakeClock, storeID := setup(t, WithConditionCurrentTimeGranularity(time.Minute))
+
+		// within the last interval before the expiry, the grant has expired already
+		fakeClock.Advance(time.Hour - time.Second)
+		require.False(t, check(t, s, storeID, nil).GetAllowed())
+	})
+
+	t.Run("applies_to_the_other_check_apis", func(t *testing.T) {
+		s, fakeClock, storeID := setup(t)
+		fakeClock.Advance(2 * time.Hour)
+
+		req := &openfgav1.CheckRequest{
+			StoreId:  storeID,
+			TupleKey: tuple.NewCheckRequestTupleKey("document:1", "viewer", "user:anne"),
+		}
+
+		resp, _, err := s.CheckWithTrace(ctx, req)
+		require.NoError(t, err)
+		require.False(t, resp.GetAllowed())
+
+		resp, err = s.CheckWithModelDelta(ctx, req, &typesystem.ModelDelta{})
+		require.NoError(t, err)
+		require.False(t, resp.GetAllowed())
+	})
+
+	t.Run("the_current_time_of_the_request_wins", func(t *testing.T) {
+		s, fakeClock, storeID := setup(t)
+		fakeClock.Advance(2 * time.Hour)
+
+		reqContext, err := structpb.NewStruct(map[string]interface{}{"current_time": start.Format(time.RFC3339)})
+		require.NoError(t, err)
+		require.True(t, check(t, s, storeID, reqContext).GetAllowed())
+	})
+
+	t.Run("disabled", func(t *testing.T) {
+		s, _, storeID := setup(t, WithConditionCurrentTimeGranularity(0))
+
+		_, err := s.Check(ctx, &openfgav1.CheckRequest{
+			StoreId:  storeID,
+			TupleKey: tuple.NewCheckRequestTupleKey("document:1", "viewer", "user:anne"),
+		})
+		require.ErrorContains(t, err, "missing")
+	})
+}
+
+func TestConditionContext(t *testing.T) {
+	now := time.Date(2024, 5, 1, 12, 34, 56, 789, time.UTC)
+	s := &Server{
+		conditionCurrentTimeGranularity: time.Minute,
+		conditionClock:                  clock.NewFake(now),
+	}
+
+	withCurrentTime, err := typesystem.New(parser.MustTransformDSLToProto(`
+		model
+			schema 1.1
+		type user
+		type document
+			relations
+				define viewer: [user with non_expired]
+		condition non_expired(current_time: timestamp, expiry: timestamp) {
+			current_time < expiry
+		}`))
+	require.NoError(t, err)
+	withoutCurrentTime, err := typesystem.New(parser.MustTransformDSLToProto(`
+		model
+			schema 1.1
+		type user
+		type document
+			relations
+				define viewer: [user with in_office]
+		condition in_office(current_time: string) {
+			current_time == "office_hours"
+		}`))
+	require.NoError(t, err)
+
+	reqContext, err := structpb.NewStruct(map[string]interface{}{"ip": "10.0.0.1"})
+	require.NoError(t, err)
+
+	t.Run("adds_the_current_time_rounded_up", func(t *testing.T) {
+		got := s.conditionContext(withCurrentTime, reqContext)
+		require.Equal(t, map[string]interface{}{
+			"ip":           "10.0.0.1",
+			"current_time": "2024-05-01T12:35:00Z",
+		}, got.AsMap())
+
+		// the context of the request isn't modified
+		require.Equal(t, map[string]interface{}{"ip": "10.0.0.1"}, reqContext.AsMap())
+	})
+
+	t.Run("keeps_a_current_time_that_is_a_multiple_of_the_granularity", func(t *testing.T) {
+		s := &Server{
+			conditionCurrentTimeGranularity: time.Minute,
+			conditionClock:                  clock.NewFake(now.Truncate(time.Minute)),
+		}
+		got := s.conditionContext(withCurrentTime, nil)
+		require.Equal(t, map[string]interface{}{"current_time": "2024-05-01T12:34:00Z"}, got.AsMap())
+	})
+
+	t.Run("keeps_the_context_of_requests_that_set_the_current_time", func(t *testing.T) {
+		withTime, err := structpb.NewStruct(map[string]interface{}{"current_time": "2024-01-01T00:00:00Z"})
+		require.NoError(t, err)
+		require.Same(t, withTime, s.conditionContext(withCurrentTime, withTime))
+	})
+
+	t.Run("keeps_the_context_if_no_condition_declares_a_current_timestamp", func(t *testing.T) {
+		require.Same(t, reqContext, s.conditionContext(withoutCurrentTime, reqContext))
+	})
+}
@@ -5,17 +5,38 @@ import (
 )
 
 type CacheSettings struct {
-	CheckCacheLimit                    uint32
-	CacheControllerEnabled             bool
-	CacheControllerTTL                 time.Duration
-	CheckQueryCacheEnabled             bool
-	CheckQueryCacheTTL                 time.Duration
+	CheckCacheLimit uint32
+	// CheckCacheCostBudget bounds the estimated total size in bytes of the entries of the check cache rather
+	// than their number, see storage.CacheEntryCost. Zero bounds their number by CheckCacheLimit instead.
+	CheckCacheCostBudget   int64
+	CacheControllerEnabled bool
+	CacheControllerTTL     time.Duration
+	CheckQueryCacheEnabled bool
+	CheckQueryCacheTTL     time.Duration
+	// CheckQueryCacheMaxStaleness is the maximum age of a cached Check result that may be served
+	// when the datastore fails. Zero disables the stale fallback.
+	CheckQueryCacheMaxStaleness time.Duration
+	// CheckQueryCacheStaleGrace is how long before the last cache invalidation a cached Check result may
+	// have been cached and still be served to requests with MINIMIZE_LATENCY. Zero disables it.
+	CheckQueryCacheStaleGrace time.Duration
+	// CheckQueryCacheStoreMinEntries, CheckQueryCacheStoreMaxEntries and CheckQueryCacheStoreBudget partition
+	// the cached Check results by store, see graph.StoreCacheLimits. They aren't partitioned if all are zero.
+	CheckQueryCacheStoreMinEntries uint32
+	CheckQueryCacheStoreMaxEntries uint32
+	CheckQueryCacheStoreBudget     uint32
+	// CheckQueryCacheUncachedObjectTypes are the object types whose Check subproblems are never cached.
+	CheckQueryCacheUncachedObjectTypes []string
+	// CheckQueryCacheUsersetsEnabled caches the memberships of usersets as Check subproblems of their own.
+	CheckQueryCacheUsersetsEnabled     bool
 	CheckIteratorCacheEnabled          bool
 	CheckIteratorCacheMaxResults       uint32
 	CheckIteratorCacheTTL              time.Duration
 	ListObjectsIteratorCacheEnabled    bool
 	ListObjectsIteratorCacheMaxResults uint32
 	ListObjectsIteratorCacheTTL        time.Duration
+	ListObjectsQueryCacheEnabled       bool
+	ListObjectsQueryCacheMaxResults    uint32
+	ListObjectsQueryCacheTTL           time.Duration
 	SharedIteratorEnabled              bool
 	SharedIteratorLimit                uint32
 	SharedIteratorTTL                  time.Duration
@@ -35,6 +56,9 @@ func NewDefaultCacheSettings() CacheSettings {
 		ListObjectsIteratorCacheEnabled:    DefaultListObjectsIteratorCacheEnabled,
 		ListObjectsIteratorCacheMaxResults: DefaultListObjectsIteratorCacheMaxResults,
 		ListObjectsIteratorCacheTTL:        DefaultListObjectsIteratorCacheTTL,
+		ListObjectsQueryCacheEnabled:       DefaultListObjectsQueryCacheEnabled,
+		ListObjectsQueryCacheMaxResults:    DefaultListObjectsQueryCacheMaxResults,
+		ListObjectsQueryCacheTTL:           DefaultListObjectsQueryCacheTTL,
 		SharedIteratorEnabled:              DefaultSharedIteratorEnabled,
 		SharedIteratorLimit:                DefaultSharedIteratorLimit,
 		SharedIteratorTTL:                  DefaultSharedIteratorTTL,
@@ -43,7 +67,7 @@ func NewDefaultCacheSettings() CacheSettings {
 }
 
 func (c CacheSettings) ShouldCreateNewCache() bool {
-	return c.ShouldCacheCheckQueries() || c.ShouldCacheCheckIterators() || c.ShouldCacheListObjectsIterators()
+	return c.ShouldCacheCheckQueries() || c.ShouldCacheCheckIterators() || c.ShouldCacheListObjectsIterators() || c.ShouldCacheListObjectsQueries()
 }
 
 func (c CacheSettings) ShouldCreateCacheController() bool {
@@ -54,6 +78,16 @@ func (c CacheSettings) ShouldCacheCheckQueries() bool {
 	return c.CheckCacheLimit > 0 && c.CheckQueryCacheEnabled
 }
 
+// ShouldPartitionCheckQueryCacheByStore returns true if the cached Check results are partitioned by store.
+func (c CacheSettings) ShouldPartitionCheckQueryCacheByStore() bool {
+	return c.CheckQueryCacheStoreMaxEntries > 0 || c.CheckQueryCacheStoreBudget > 0
+}
+
+// ShouldCacheCheckQueryUsersets returns true if the memberships of usersets should be cached as Check subproblems.
+func (c CacheSettings) ShouldCacheCheckQueryUsersets() bool {
+	return c.ShouldCacheCheckQueries() && c.CheckQueryCacheUsersetsEnabled
+}
+
 func (c CacheSettings) ShouldCacheCheckIterators() bool {
 	return c.CheckCacheLimit > 0 && c.CheckIteratorCacheEnabled
 }
@@ -62,6 +96,11 @@ func (c CacheSettings) ShouldCacheListObjectsIterators() bool {
 	return c.ListObjectsIteratorCacheEnabled && c.ListObjectsIteratorCacheMaxResults > 0
 }
 
+// ShouldCacheListObjectsQueries returns true if the results of ListObjects requests should be cached.
+func (c CacheSettings) ShouldCacheListObjectsQueries() bool {
+	return c.CheckCacheLimit > 0 && c.ListObjectsQueryCacheEnabled && c.ListObjectsQueryCacheMaxResults > 0
+}
+
 func (c CacheSettings) ShouldCreateShadowNewCache() bool {
 	return c.ShadowCheckCacheEnabled && c.ShouldCreateNewCache()
 }
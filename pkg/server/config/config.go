@@ -12,17 +12,86 @@ import (
 	"github.com/spf13/viper"
 )
 
+// ResolutionStrategy selects how the subproblems of a query are scheduled.
+type ResolutionStrategy string
+
+const (
+	// BreadthFirstResolution evaluates the subproblems of a node concurrently, up to the
+	// ResolveNodeBreadthLimit.
+	BreadthFirstResolution ResolutionStrategy = "breadth-first"
+	// DepthFirstResolution fully evaluates a subproblem of a node before it evaluates the next one,
+	// which issues fewer concurrent datastore reads at the cost of latency.
+	DepthFirstResolution ResolutionStrategy = "depth-first"
+)
+
+// DuplicateWriteBehavior selects how a Write handles a tuple that already exists, unless the request
+// specifies it.
+type DuplicateWriteBehavior string
+
+const (
+	// DuplicateWriteError rejects the Write.
+	DuplicateWriteError DuplicateWriteBehavior = "error"
+	// DuplicateWriteIgnore makes the write of the tuple a no-op. The Write is rejected if the condition
+	// of the tuple differs from the existing one.
+	DuplicateWriteIgnore DuplicateWriteBehavior = "ignore"
+	// DuplicateWriteUpsert replaces the condition of the existing tuple with the condition of the
+	// written one.
+	DuplicateWriteUpsert DuplicateWriteBehavior = "upsert"
+)
+
+// UsersetFanoutBehavior selects what happens when the usersets related to an object through a relation
+// exceed the MaxUsersetFanout.
+type UsersetFanoutBehavior string
+
+const (
+	// UsersetFanoutError fails the query.
+	UsersetFanoutError UsersetFanoutBehavior = "error"
+	// UsersetFanoutDeny doesn't expand the usersets beyond the cap, so the users that are only related
+	// through them are denied.
+	UsersetFanoutDeny UsersetFanoutBehavior = "deny"
+)
+
+// ListObjectsOrder selects the order of the objects returned by ListObjects and StreamedListObjects.
+type ListObjectsOrder string
+
+const (
+	// ListObjectsUnordered returns the objects in the order in which they are found, which depends on the
+	// datastore and on the concurrency of the evaluation.
+	ListObjectsUnordered ListObjectsOrder = "none"
+	// ListObjectsOrderByObjectID returns the objects sorted by ascending object ID.
+	ListObjectsOrderByObjectID ListObjectsOrder = "object-id"
+)
+
 const (
 	DefaultMaxRPCMessageSizeInBytes         = 512 * 1_204 // 512 KB
 	DefaultMaxTuplesPerWrite                = 100
 	DefaultMaxTypesPerAuthorizationModel    = 100
 	DefaultMaxAuthorizationModelSizeInBytes = 256 * 1_024
+	DefaultMaxRelationBranches              = 100
+	DefaultDatastoreErrorMetricMaxStores    = 100
 	DefaultMaxAuthorizationModelCacheSize   = 100000
 	DefaultChangelogHorizonOffset           = 0
+	DefaultChangelogCompactionInterval      = 0
+	DefaultChangelogRetention               = 30 * 24 * time.Hour
 	DefaultResolveNodeLimit                 = 25
+	DefaultResolveDepthLimit                = 0
+	DefaultMaxContextualTuples              = 100
+	// DefaultConditionCurrentTimeGranularity matches DefaultCheckQueryCacheTTL, so that a condition
+	// on the current time isn't stale for longer than a cached Check result already is.
+	DefaultConditionCurrentTimeGranularity  = 10 * time.Second
 	DefaultResolveNodeBreadthLimit          = 10
+	DefaultResolutionStrategy               = BreadthFirstResolution
+	DefaultDuplicateWriteBehavior           = DuplicateWriteError
+	DefaultMaxUsersetFanout                 = 10000
+	DefaultUsersetFanoutBehavior            = UsersetFanoutError
 	DefaultListObjectsDeadline              = 3 * time.Second
 	DefaultListObjectsMaxResults            = 1000
+	DefaultListObjectsOrderBy               = ListObjectsUnordered
+	DefaultCountObjectsMaxCount             = 100000
+	DefaultCheckSessionMaxEntries           = 10000
+	DefaultMaxCheckSessions                 = 1000
+	DefaultMaxCheckSessionsPerStore         = 100
+	DefaultCheckSessionTTL                  = time.Minute
 	DefaultMaxConcurrentReadsForCheck       = math.MaxUint32
 	DefaultMaxConcurrentReadsForListObjects = math.MaxUint32
 	DefaultListUsersDeadline                = 3 * time.Second
@@ -49,6 +118,10 @@ const (
 	DefaultListObjectsIteratorCacheMaxResults = 10000
 	DefaultListObjectsIteratorCacheTTL        = 10 * time.Second
 
+	DefaultListObjectsQueryCacheEnabled    = false
+	DefaultListObjectsQueryCacheMaxResults = 1000
+	DefaultListObjectsQueryCacheTTL        = 10 * time.Second
+
 	DefaultListObjectsOptimizationsEnabled = false
 
 	DefaultCacheControllerConfigEnabled = false
@@ -71,6 +144,19 @@ const (
 	DefaultMaxConditionEvaluationCost = 100
 	DefaultInterruptCheckFrequency    = 100
 
+	DefaultDatastoreCircuitBreakerEnabled              = false
+	DefaultDatastoreCircuitBreakerFailureRateThreshold = 0.5
+	DefaultDatastoreCircuitBreakerMinRequests          = 20
+	DefaultDatastoreCircuitBreakerWindow               = 10 * time.Second
+	DefaultDatastoreCircuitBreakerCooldown             = 5 * time.Second
+	DefaultDatastoreCircuitBreakerHalfOpenProbes       = 3
+
+	DefaultDatastoreRetryEnabled        = false
+	DefaultDatastoreRetryMaxAttempts    = 3
+	DefaultDatastoreRetryInitialBackoff = 10 * time.Millisecond
+	DefaultDatastoreRetryMaxBackoff     = 200 * time.Millisecond
+	DefaultDatastoreRetryWrites         = false
+
 	DefaultCheckDispatchThrottlingEnabled          = false
 	DefaultCheckDispatchThrottlingFrequency        = 10 * time.Microsecond
 	DefaultCheckDispatchThrottlingDefaultThreshold = 100
@@ -78,6 +164,7 @@ const (
 
 	// Batch Check.
 	DefaultMaxChecksPerBatchCheck           = 50
+	DefaultMaxChecksPerStreamedBatchCheck   = 1000
 	DefaultMaxConcurrentChecksPerBatchCheck = 50
 
 	DefaultListObjectsDispatchThrottlingEnabled          = false
@@ -135,8 +222,62 @@ type DatastoreConfig struct {
 	// ConnMaxLifetime is the maximum amount of time a connection to the datastore may be reused.
 	ConnMaxLifetime time.Duration
 
+	// ReadTimeout is the maximum amount of time a single tuple read from the datastore may take.
+	// Zero means no timeout.
+	ReadTimeout time.Duration
+
+	// WriteTimeout is the maximum amount of time a single tuple write to the datastore may take.
+	// Zero means no timeout.
+	WriteTimeout time.Duration
+
 	// Metrics is configuration for the Datastore metrics.
 	Metrics DatastoreMetricsConfig
+
+	// CircuitBreaker is configuration for the circuit breakers of the datastore.
+	CircuitBreaker DatastoreCircuitBreakerConfig
+
+	// Retry is configuration for the retries of the datastore operations that fail with a transient error.
+	Retry DatastoreRetryConfig
+}
+
+// DatastoreCircuitBreakerConfig defines the circuit breakers that fail the reads and the writes of the datastore
+// fast while it is failing. See storagewrappers.CircuitBreakerPolicy.
+type DatastoreCircuitBreakerConfig struct {
+	Enabled bool
+
+	// FailureRateThreshold is the rate of failed operations, between 0 and 1, over a window at which a
+	// breaker opens.
+	FailureRateThreshold float64
+
+	// MinRequests is the minimum number of operations in a window for a breaker to open.
+	MinRequests int
+
+	// Window is the duration over which the failure rate is computed.
+	Window time.Duration
+
+	// Cooldown is the time for which an open breaker fails the operations fast before it half-opens.
+	Cooldown time.Duration
+
+	// HalfOpenProbes is the number of operations that a half-open breaker lets through.
+	HalfOpenProbes int
+}
+
+// DatastoreRetryConfig defines the retries of the datastore operations that fail with a transient error.
+// See storagewrappers.RetryPolicy.
+type DatastoreRetryConfig struct {
+	Enabled bool
+
+	// MaxAttempts is the maximum number of attempts of an operation, including the first one.
+	MaxAttempts int
+
+	// InitialBackoff is the time waited before the first retry. It grows exponentially for the next ones.
+	InitialBackoff time.Duration
+
+	// MaxBackoff is the maximum time waited before a retry.
+	MaxBackoff time.Duration
+
+	// Writes enables retrying the writes, which may have been committed if they failed because of the connection.
+	Writes bool
 }
 
 // GRPCConfig defines OpenFGA server configurations for grpc server specific settings.
@@ -209,6 +350,9 @@ type TraceConfig struct {
 	OTLP        OTLPTraceConfig `mapstructure:"otlp"`
 	SampleRatio float64
 	ServiceName string
+	// HighCardinalityAttributes adds the tuple keys of the Check subproblems, i.e. their objects and users, to
+	// their spans. They are left out by default since they may hold personal data.
+	HighCardinalityAttributes bool
 }
 
 type OTLPTraceConfig struct {
@@ -237,17 +381,34 @@ type MetricConfig struct {
 	Enabled             bool
 	Addr                string
 	EnableRPCHistograms bool
+	// EnableCheckRewriteHistograms records the time that Checks spend evaluating each rewrite operation,
+	// e.g. union or tupleToUserset, excluding the time spent in its operands and dispatched subproblems.
+	EnableCheckRewriteHistograms bool
+	// DatastoreErrorMaxStores is the maximum number of stores whose datastore errors are counted under
+	// their own store label. The errors of any other store are counted under the label 'other'.
+	DatastoreErrorMaxStores int
 }
 
 // CheckQueryCache defines configuration for caching when resolving check.
 type CheckQueryCache struct {
 	Enabled bool
 	TTL     time.Duration
+	// StoreMinEntries, StoreMaxEntries and StoreBudget partition the cached results by store, so that the
+	// Checks of a busy store can't evict the results of the others. Zero StoreMaxEntries and StoreBudget
+	// don't bound them.
+	StoreMinEntries uint32
+	StoreMaxEntries uint32
+	StoreBudget     uint32
+	// UncachedObjectTypes are the object types whose Check subproblems are never cached.
+	UncachedObjectTypes []string
 }
 
 // CheckCacheConfig defines configuration for a cache that is shared across Check requests.
 type CheckCacheConfig struct {
 	Limit uint32
+	// CostBudget bounds the estimated total size in bytes of the entries of the cache instead of their number.
+	// Zero bounds their number by Limit.
+	CostBudget int64
 }
 
 // IteratorCacheConfig defines configuration to cache storage iterator results.
@@ -257,6 +418,14 @@ type IteratorCacheConfig struct {
 	TTL        time.Duration
 }
 
+// ListObjectsQueryCacheConfig defines configuration to cache the results of ListObjects requests.
+type ListObjectsQueryCacheConfig struct {
+	Enabled bool
+	// MaxResults is the maximum number of objects in a result that is cached. Larger results are not cached.
+	MaxResults uint32
+	TTL        time.Duration
+}
+
 // SharedIteratorConfig defines configuration to share storage iterator.
 type SharedIteratorConfig struct {
 	Enabled bool
@@ -311,6 +480,11 @@ type Config struct {
 	// This is to protect the server from misuse of the ListObjects endpoints.
 	ListObjectsMaxResults uint32
 
+	// ListObjectsOrderBy defines the order of the objects returned by the ListObjects endpoints, either
+	// 'none' or 'object-id'. Ordering StreamedListObjects buffers the objects until all are found, see
+	// server.WithListObjectsOrderBy.
+	ListObjectsOrderBy ListObjectsOrder
+
 	// ListUsersDeadline defines the maximum amount of time to accumulate ListUsers results
 	// before the server will respond. This is to protect the server from misuse of the
 	// ListUsers endpoints. It cannot be larger than the configured server's request timeout (RequestTimeout or HTTPConfig.UpstreamTimeout).
@@ -324,10 +498,35 @@ type Config struct {
 	// MaxTuplesPerWrite defines the maximum number of tuples per Write endpoint.
 	MaxTuplesPerWrite int
 
+	// DuplicateWriteBehavior defines how a Write handles a tuple that already exists when the request
+	// doesn't specify it, either 'error', 'ignore' or 'upsert'. It is applied the same way by all datastores.
+	DuplicateWriteBehavior DuplicateWriteBehavior
+
+	// MaxContextualTuples defines the maximum number of contextual tuples of a Check, BatchCheck item or
+	// ListObjects request. The API already rejects requests with more than 100 contextual tuples, so the
+	// limit can only make this stricter.
+	MaxContextualTuples uint32
+
+	// ConditionCurrentTimeGranularity is the granularity of the 'current_time' parameter that the server
+	// provides to the conditions that declare it, if the request doesn't. The current time is rounded up
+	// to a multiple of it, so that the Checks made within the same interval share their cache entries and
+	// the grants expire early rather than late. The grants that start at a time, e.g. `current_time > start`,
+	// apply up to one granularity early as a result.
+	// 0 disables providing the current time.
+	ConditionCurrentTimeGranularity time.Duration
+
+	// ModelIndexFiles are the files of the precomputed indexes of authorization models that are loaded at
+	// startup, see typesystem.BuildIndex.
+	ModelIndexFiles []string
+
 	// MaxChecksPerBatchCheck defines the maximum number of tuples
 	// that can be passed in each BatchCheck request.
 	MaxChecksPerBatchCheck uint32
 
+	// MaxChecksPerStreamedBatchCheck defines the maximum number of tuples
+	// that can be passed in each streamed BatchCheck, see Server.StreamedBatchCheck.
+	MaxChecksPerStreamedBatchCheck uint32
+
 	// MaxConcurrentChecksPerBatchCheck defines the maximum number of checks
 	// that can be run in simultaneously
 	MaxConcurrentChecksPerBatchCheck uint32
@@ -340,6 +539,11 @@ type Config struct {
 	// persisting an Authorization Model.
 	MaxAuthorizationModelSizeInBytes int
 
+	// MaxRelationBranches defines the maximum number of branches (the operands of unions,
+	// intersections and exclusions) allowed in the definition of a single relation of an
+	// Authorization Model.
+	MaxRelationBranches int
+
 	// MaxConcurrentReadsForListObjects defines the maximum number of concurrent database reads
 	// allowed in ListObjects queries
 	MaxConcurrentReadsForListObjects uint32
@@ -359,6 +563,14 @@ type Config struct {
 	// after this offset will not be included in the response of ReadChanges.
 	ChangelogHorizonOffset int
 
+	// ChangelogCompactionInterval is how often the changelog of every store is compacted, removing the
+	// changes older than ChangelogRetention. ReadChanges continuation tokens older than the retention
+	// become invalid. 0 disables the compaction.
+	ChangelogCompactionInterval time.Duration
+
+	// ChangelogRetention is how long the changes are kept in the changelog when it's compacted.
+	ChangelogRetention time.Duration
+
 	// Experimentals is a list of the experimental features to enable in the OpenFGA server.
 	Experimentals []string
 
@@ -369,10 +581,35 @@ type Config struct {
 	// errors out.
 	ResolveNodeLimit uint32
 
+	// ResolveDepthLimit indicates how many rewrites a Check can expand in any one path before
+	// it errors out. Unlike ResolveNodeLimit, it also counts the rewrites that are resolved without
+	// a dispatch, such as chains of computed relations. 0 means no limit.
+	ResolveDepthLimit uint32
+
 	// ResolveNodeBreadthLimit indicates how many nodes on a given level can be evaluated
 	// concurrently in a query
 	ResolveNodeBreadthLimit uint32
 
+	// ResolutionStrategy selects how the subproblems of a Check are scheduled, either
+	// 'breadth-first' or 'depth-first'. The strategy doesn't affect the outcome of a Check.
+	ResolutionStrategy ResolutionStrategy
+
+	// CheckConcurrencyBudget bounds the number of subproblems that all the Checks evaluate concurrently,
+	// on top of ResolveNodeBreadthLimit. 0 means no limit.
+	CheckConcurrencyBudget uint32
+
+	// CheckPerRequestConcurrencyLimit bounds the number of subproblems that a single Check evaluates
+	// concurrently, so that one Check can't spend the whole CheckConcurrencyBudget. 0 means no limit.
+	CheckPerRequestConcurrencyLimit uint32
+
+	// MaxUsersetFanout caps the number of userset tuples that are expanded for a single object and
+	// relation of a query, e.g. the groups a document is shared with. 0 doesn't cap the fan-out.
+	MaxUsersetFanout uint32
+
+	// UsersetFanoutBehavior defines what happens when the MaxUsersetFanout is exceeded, either 'error'
+	// or 'deny'.
+	UsersetFanoutBehavior UsersetFanoutBehavior
+
 	// RequestTimeout configures request timeout.  If both HTTP upstream timeout and request timeout are specified,
 	// request timeout will be prioritized
 	RequestTimeout time.Duration
@@ -401,6 +638,7 @@ type Config struct {
 	ListObjectsDatabaseThrottle   DatabaseThrottleConfig
 	ListUsersDatabaseThrottle     DatabaseThrottleConfig
 	ListObjectsIteratorCache      IteratorCacheConfig
+	ListObjectsQueryCache         ListObjectsQueryCacheConfig
 	SharedIterator                SharedIteratorConfig
 	Planner                       PlannerConfig
 
@@ -420,6 +658,30 @@ func (cfg *Config) VerifyServerSettings() error {
 		return err
 	}
 
+	if cfg.MaxRelationBranches <= 0 {
+		return errors.New("config 'maxRelationBranches' must be greater than 0")
+	}
+
+	if cfg.Metrics.DatastoreErrorMaxStores < 0 {
+		return errors.New("config 'metrics.datastoreErrorMaxStores' cannot be negative")
+	}
+
+	if cfg.MaxContextualTuples == 0 {
+		return errors.New("config 'maxContextualTuples' must be greater than 0")
+	}
+
+	if cfg.ConditionCurrentTimeGranularity < 0 {
+		return errors.New("config 'conditionCurrentTimeGranularity' cannot be negative")
+	}
+
+	if cfg.ChangelogCompactionInterval < 0 {
+		return errors.New("config 'changelogCompactionInterval' cannot be negative")
+	}
+
+	if cfg.ChangelogCompactionInterval > 0 && cfg.ChangelogRetention <= 0 {
+		return errors.New("config 'changelogRetention' must be greater than 0 if the changelog compaction is enabled")
+	}
+
 	if cfg.MaxConcurrentReadsForListUsers == 0 {
 		return fmt.Errorf("config 'maxConcurrentReadsForListUsers' cannot be 0")
 	}
@@ -454,6 +716,45 @@ func (cfg *Config) VerifyServerSettings() error {
 		return err
 	}
 
+	if cfg.Datastore.CircuitBreaker.Enabled {
+		if cfg.Datastore.CircuitBreaker.FailureRateThreshold <= 0 || cfg.Datastore.CircuitBreaker.FailureRateThreshold > 1 {
+			return errors.New("'datastore.circuitBreaker.failureRateThreshold' must be greater than zero and at most one")
+		}
+		if cfg.Datastore.CircuitBreaker.Window <= 0 {
+			return errors.New("'datastore.circuitBreaker.window' must be greater than zero")
+		}
+		if cfg.Datastore.CircuitBreaker.Cooldown <= 0 {
+			return errors.New("'datastore.circuitBreaker.cooldown' must be greater than zero")
+		}
+	}
+
+	if cfg.Datastore.Retry.Enabled {
+		if cfg.Datastore.Retry.MaxAttempts < 1 {
+			return errors.New("'datastore.retry.maxAttempts' must be at least one")
+		}
+		if cfg.Datastore.Retry.MaxBackoff < cfg.Datastore.Retry.InitialBackoff {
+			return errors.New("'datastore.retry.maxBackoff' must be at least 'datastore.retry.initialBackoff'")
+		}
+	}
+
+	if cfg.ResolutionStrategy != BreadthFirstResolution && cfg.ResolutionStrategy != DepthFirstResolution {
+		return fmt.Errorf("config 'resolutionStrategy' must be one of ['%s', '%s']", BreadthFirstResolution, DepthFirstResolution)
+	}
+
+	switch cfg.DuplicateWriteBehavior {
+	case DuplicateWriteError, DuplicateWriteIgnore, DuplicateWriteUpsert:
+	default:
+		return fmt.Errorf("config 'duplicateWriteBehavior' must be one of ['%s', '%s', '%s']", DuplicateWriteError, DuplicateWriteIgnore, DuplicateWriteUpsert)
+	}
+
+	if cfg.UsersetFanoutBehavior != UsersetFanoutError && cfg.UsersetFanoutBehavior != UsersetFanoutDeny {
+		return fmt.Errorf("config 'usersetFanoutBehavior' must be one of ['%s', '%s']", UsersetFanoutError, UsersetFanoutDeny)
+	}
+
+	if cfg.ListObjectsOrderBy != ListObjectsUnordered && cfg.ListObjectsOrderBy != ListObjectsOrderByObjectID {
+		return fmt.Errorf("config 'listObjectsOrderBy' must be one of ['%s', '%s']", ListObjectsUnordered, ListObjectsOrderByObjectID)
+	}
+
 	if cfg.ListObjectsDeadline < 0 {
 		return errors.New("listObjectsDeadline must be non-negative time duration")
 	}
@@ -669,6 +970,14 @@ func (cfg *Config) verifyCacheConfig() error {
 			return errors.New("'listObjectsIteratorCache.maxResults' must be greater than zero")
 		}
 	}
+	if cfg.ListObjectsQueryCache.Enabled {
+		if cfg.ListObjectsQueryCache.TTL <= 0 {
+			return errors.New("'listObjectsQueryCache.ttl' must be greater than zero")
+		}
+		if cfg.ListObjectsQueryCache.MaxResults <= 0 {
+			return errors.New("'listObjectsQueryCache.maxResults' must be greater than zero")
+		}
+	}
 	if cfg.CacheController.Enabled && cfg.CacheController.TTL <= 0 {
 		return errors.New("'cacheController.ttl' must be greater than zero")
 	}
@@ -684,21 +993,34 @@ func MaxConditionEvaluationCost() uint64 {
 func DefaultConfig() *Config {
 	return &Config{
 		MaxTuplesPerWrite:                         DefaultMaxTuplesPerWrite,
+		DuplicateWriteBehavior:                    DefaultDuplicateWriteBehavior,
 		MaxTypesPerAuthorizationModel:             DefaultMaxTypesPerAuthorizationModel,
 		MaxAuthorizationModelSizeInBytes:          DefaultMaxAuthorizationModelSizeInBytes,
+		MaxRelationBranches:                       DefaultMaxRelationBranches,
 		MaxChecksPerBatchCheck:                    DefaultMaxChecksPerBatchCheck,
+		MaxChecksPerStreamedBatchCheck:            DefaultMaxChecksPerStreamedBatchCheck,
 		MaxConcurrentChecksPerBatchCheck:          DefaultMaxConcurrentChecksPerBatchCheck,
 		MaxConcurrentReadsForCheck:                DefaultMaxConcurrentReadsForCheck,
 		MaxConcurrentReadsForListObjects:          DefaultMaxConcurrentReadsForListObjects,
 		MaxConcurrentReadsForListUsers:            DefaultMaxConcurrentReadsForListUsers,
 		MaxConditionEvaluationCost:                DefaultMaxConditionEvaluationCost,
 		ChangelogHorizonOffset:                    DefaultChangelogHorizonOffset,
+		ChangelogCompactionInterval:               DefaultChangelogCompactionInterval,
+		ChangelogRetention:                        DefaultChangelogRetention,
 		ResolveNodeLimit:                          DefaultResolveNodeLimit,
+		ResolveDepthLimit:                         DefaultResolveDepthLimit,
+		MaxContextualTuples:                       DefaultMaxContextualTuples,
+		ConditionCurrentTimeGranularity:           DefaultConditionCurrentTimeGranularity,
+		ModelIndexFiles:                           []string{},
 		ResolveNodeBreadthLimit:                   DefaultResolveNodeBreadthLimit,
+		ResolutionStrategy:                        DefaultResolutionStrategy,
+		MaxUsersetFanout:                          DefaultMaxUsersetFanout,
+		UsersetFanoutBehavior:                     DefaultUsersetFanoutBehavior,
 		Experimentals:                             []string{},
 		AccessControl:                             AccessControlConfig{Enabled: false, StoreID: "", ModelID: ""},
 		ListObjectsDeadline:                       DefaultListObjectsDeadline,
 		ListObjectsMaxResults:                     DefaultListObjectsMaxResults,
+		ListObjectsOrderBy:                        DefaultListObjectsOrderBy,
 		ListUsersMaxResults:                       DefaultListUsersMaxResults,
 		ListUsersDeadline:                         DefaultListUsersDeadline,
 		RequestDurationDatastoreQueryCountBuckets: []string{"50", "200"},
@@ -708,6 +1030,21 @@ func DefaultConfig() *Config {
 			MaxCacheSize: DefaultMaxAuthorizationModelCacheSize,
 			MaxIdleConns: 10,
 			MaxOpenConns: 30,
+			CircuitBreaker: DatastoreCircuitBreakerConfig{
+				Enabled:              DefaultDatastoreCircuitBreakerEnabled,
+				FailureRateThreshold: DefaultDatastoreCircuitBreakerFailureRateThreshold,
+				MinRequests:          DefaultDatastoreCircuitBreakerMinRequests,
+				Window:               DefaultDatastoreCircuitBreakerWindow,
+				Cooldown:             DefaultDatastoreCircuitBreakerCooldown,
+				HalfOpenProbes:       DefaultDatastoreCircuitBreakerHalfOpenProbes,
+			},
+			Retry: DatastoreRetryConfig{
+				Enabled:        DefaultDatastoreRetryEnabled,
+				MaxAttempts:    DefaultDatastoreRetryMaxAttempts,
+				InitialBackoff: DefaultDatastoreRetryInitialBackoff,
+				MaxBackoff:     DefaultDatastoreRetryMaxBackoff,
+				Writes:         DefaultDatastoreRetryWrites,
+			},
 		},
 		GRPC: GRPCConfig{
 			Addr: "0.0.0.0:8081",
@@ -739,8 +1076,9 @@ func DefaultConfig() *Config {
 					Enabled: false,
 				},
 			},
-			SampleRatio: 0.2,
-			ServiceName: "openfga",
+			SampleRatio:               0.2,
+			ServiceName:               "openfga",
+			HighCardinalityAttributes: false,
 		},
 		Playground: PlaygroundConfig{
 			Enabled: true,
@@ -751,9 +1089,11 @@ func DefaultConfig() *Config {
 			Addr:    ":3001",
 		},
 		Metrics: MetricConfig{
-			Enabled:             true,
-			Addr:                "0.0.0.0:2112",
-			EnableRPCHistograms: false,
+			Enabled:                      true,
+			Addr:                         "0.0.0.0:2112",
+			EnableRPCHistograms:          false,
+			EnableCheckRewriteHistograms: false,
+			DatastoreErrorMaxStores:      DefaultDatastoreErrorMetricMaxStores,
 		},
 		CheckIteratorCache: IteratorCacheConfig{
 			Enabled:    DefaultCheckIteratorCacheEnabled,
@@ -761,8 +1101,9 @@ func DefaultConfig() *Config {
 			TTL:        DefaultCheckIteratorCacheTTL,
 		},
 		CheckQueryCache: CheckQueryCache{
-			Enabled: DefaultCheckQueryCacheEnabled,
-			TTL:     DefaultCheckQueryCacheTTL,
+			Enabled:             DefaultCheckQueryCacheEnabled,
+			TTL:                 DefaultCheckQueryCacheTTL,
+			UncachedObjectTypes: []string{},
 		},
 		CheckCache: CheckCacheConfig{
 			Limit: DefaultCheckCacheLimit,
@@ -798,6 +1139,11 @@ func DefaultConfig() *Config {
 			MaxResults: DefaultListObjectsIteratorCacheMaxResults,
 			TTL:        DefaultListObjectsIteratorCacheTTL,
 		},
+		ListObjectsQueryCache: ListObjectsQueryCacheConfig{
+			Enabled:    DefaultListObjectsQueryCacheEnabled,
+			MaxResults: DefaultListObjectsQueryCacheMaxResults,
+			TTL:        DefaultListObjectsQueryCacheTTL,
+		},
 		CheckDatabaseThrottle: DatabaseThrottleConfig{
 			Enabled:   false,
 			Threshold: 0,
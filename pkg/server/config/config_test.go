@@ -323,6 +323,60 @@ func TestVerifyConfig(t *testing.T) {
 		require.Error(t, err)
 	})
 
+	t.Run("datastore_circuit_breaker", func(t *testing.T) {
+		t.Run("enable_but_failure_rate_threshold_above_one", func(t *testing.T) {
+			cfg := DefaultConfig()
+			cfg.Datastore.CircuitBreaker.Enabled = true
+			cfg.Datastore.CircuitBreaker.FailureRateThreshold = 1.5
+
+			err := cfg.Verify()
+			require.EqualError(t, err, "'datastore.circuitBreaker.failureRateThreshold' must be greater than zero and at most one")
+		})
+
+		t.Run("enable_but_cooldown_zero", func(t *testing.T) {
+			cfg := DefaultConfig()
+			cfg.Datastore.CircuitBreaker.Enabled = true
+			cfg.Datastore.CircuitBreaker.Cooldown = 0
+
+			err := cfg.Verify()
+			require.EqualError(t, err, "'datastore.circuitBreaker.cooldown' must be greater than zero")
+		})
+
+		t.Run("enable_with_the_defaults", func(t *testing.T) {
+			cfg := DefaultConfig()
+			cfg.Datastore.CircuitBreaker.Enabled = true
+
+			require.NoError(t, cfg.Verify())
+		})
+	})
+
+	t.Run("datastore_retry", func(t *testing.T) {
+		t.Run("enable_but_max_attempts_zero", func(t *testing.T) {
+			cfg := DefaultConfig()
+			cfg.Datastore.Retry.Enabled = true
+			cfg.Datastore.Retry.MaxAttempts = 0
+
+			err := cfg.Verify()
+			require.EqualError(t, err, "'datastore.retry.maxAttempts' must be at least one")
+		})
+
+		t.Run("enable_but_max_backoff_below_initial_backoff", func(t *testing.T) {
+			cfg := DefaultConfig()
+			cfg.Datastore.Retry.Enabled = true
+			cfg.Datastore.Retry.MaxBackoff = cfg.Datastore.Retry.InitialBackoff / 2
+
+			err := cfg.Verify()
+			require.EqualError(t, err, "'datastore.retry.maxBackoff' must be at least 'datastore.retry.initialBackoff'")
+		})
+
+		t.Run("enable_with_the_defaults", func(t *testing.T) {
+			cfg := DefaultConfig()
+			cfg.Datastore.Retry.Enabled = true
+
+			require.NoError(t, cfg.Verify())
+		})
+	})
+
 	t.Run("cache_query_cache", func(t *testing.T) {
 		t.Run("enable_but_ttl_zero", func(t *testing.T) {
 			cfg := DefaultConfig()
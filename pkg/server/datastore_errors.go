@@ -0,0 +1,66 @@
+package server
+
+import (
+	"errors"
+	"sync"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+
+	"github.com/openfga/openfga/internal/build"
+	serverErrors "github.com/openfga/openfga/pkg/server/errors"
+)
+
+// otherStoresLabel is the store label of the datastore errors of the stores that aren't tracked individually.
+const otherStoresLabel = "other"
+
+var (
+	datastoreErrorCounterName = "datastore_error_count"
+	datastoreErrorCounter     = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: build.ProjectName,
+		Name:      datastoreErrorCounterName,
+		Help:      "The total number of datastore errors handled by the server, labeled by store. The errors of the stores beyond the tracked limit are labeled as 'other'.",
+	}, []string{"store_id"})
+)
+
+// storeLabeler assigns the store label of a metric. It tracks the first maxStores distinct stores under their
+// own label and buckets the rest under otherStoresLabel, to bound the cardinality of the metric.
+type storeLabeler struct {
+	mu        sync.Mutex
+	maxStores int
+	stores    map[string]struct{}
+}
+
+func newStoreLabeler(maxStores int) *storeLabeler {
+	return &storeLabeler{
+		maxStores: maxStores,
+		stores:    make(map[string]struct{}),
+	}
+}
+
+func (l *storeLabeler) label(storeID string) string {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if _, ok := l.stores[storeID]; ok {
+		return storeID
+	}
+
+	if len(l.stores) >= l.maxStores {
+		return otherStoresLabel
+	}
+
+	l.stores[storeID] = struct{}{}
+	return storeID
+}
+
+// observeDatastoreError counts err against the store if it's a datastore error. Datastore errors are the
+// errors that the server reports as internal errors, as opposed to e.g. validation errors or cancellations.
+func (s *Server) observeDatastoreError(storeID string, err error) {
+	var internalError serverErrors.InternalError
+	if !errors.As(err, &internalError) {
+		return
+	}
+
+	datastoreErrorCounter.WithLabelValues(s.datastoreErrorStoreLabeler.label(storeID)).Inc()
+}
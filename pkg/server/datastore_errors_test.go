@@ -0,0 +1,182 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/oklog/ulid/v2"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/goleak"
+	"go.uber.org/mock/gomock"
+
+	openfgav1 "github.com/openfga/api/proto/openfga/v1"
+
+	mockstorage "github.com/openfga/openfga/internal/mocks"
+	serverErrors "github.com/openfga/openfga/pkg/server/errors"
+	"github.com/openfga/openfga/pkg/storage"
+	"github.com/openfga/openfga/pkg/storage/storagewrappers"
+)
+
+func TestDatastoreErrorCounter(t *testing.T) {
+	t.Cleanup(func() {
+		goleak.VerifyNone(t)
+	})
+
+	ctx := context.Background()
+	mockController := gomock.NewController(t)
+	t.Cleanup(mockController.Finish)
+
+	mockDatastore := mockstorage.NewMockOpenFGADatastore(mockController)
+	mockDatastore.EXPECT().ReadPage(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).
+		AnyTimes().
+		Return(nil, "", errors.New("error reading from storage"))
+
+	s := MustNewServerWithOpts(
+		WithDatastore(mockDatastore),
+		WithDatastoreErrorMetricMaxStores(1),
+	)
+	t.Cleanup(func() {
+		mockDatastore.EXPECT().Close().Times(1)
+		s.Close()
+	})
+
+	read := func(storeID string) error {
+		_, err := s.Read(ctx, &openfgav1.ReadRequest{StoreId: storeID})
+		return err
+	}
+
+	trackedStoreID := ulid.Make().String()
+	overflowStoreID := ulid.Make().String()
+	trackedCounter := datastoreErrorCounter.WithLabelValues(trackedStoreID)
+	otherCounter := datastoreErrorCounter.WithLabelValues(otherStoresLabel)
+	otherBefore := testutil.ToFloat64(otherCounter)
+
+	t.Run("errors_of_a_tracked_store_are_counted_under_its_label", func(t *testing.T) {
+		for i := 0; i < 2; i++ {
+			err := read(trackedStoreID)
+			require.EqualError(t, err, serverErrors.NewInternalError("", errors.New("error reading from storage")).Error())
+		}
+		require.InDelta(t, 2, testutil.ToFloat64(trackedCounter), 0)
+		require.InDelta(t, otherBefore, testutil.ToFloat64(otherCounter), 0)
+	})
+
+	t.Run("errors_of_stores_beyond_the_limit_are_counted_as_other", func(t *testing.T) {
+		require.Error(t, read(overflowStoreID))
+		require.InDelta(t, otherBefore+1, testutil.ToFloat64(otherCounter), 0)
+		require.Zero(t, testutil.ToFloat64(datastoreErrorCounter.WithLabelValues(overflowStoreID)))
+
+		// the tracked store keeps its own label
+		require.Error(t, read(trackedStoreID))
+		require.InDelta(t, 3, testutil.ToFloat64(trackedCounter), 0)
+	})
+
+	t.Run("errors_that_are_not_datastore_errors_are_not_counted", func(t *testing.T) {
+		_, err := s.Read(ctx, &openfgav1.ReadRequest{StoreId: trackedStoreID, ContinuationToken: "invalid"})
+		require.ErrorIs(t, err, serverErrors.ErrInvalidContinuationToken)
+		require.InDelta(t, 3, testutil.ToFloat64(trackedCounter), 0)
+	})
+}
+
+func TestDatastoreCircuitBreaker(t *testing.T) {
+	t.Cleanup(func() {
+		goleak.VerifyNone(t)
+	})
+
+	ctx := context.Background()
+	mockController := gomock.NewController(t)
+	t.Cleanup(mockController.Finish)
+
+	// the breaker opens after the two reads, the next ones aren't sent to the datastore
+	mockDatastore := mockstorage.NewMockOpenFGADatastore(mockController)
+	mockDatastore.EXPECT().ReadPage(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).
+		Times(2).
+		Return(nil, "", errors.New("error reading from storage"))
+
+	s := MustNewServerWithOpts(
+		WithDatastore(mockDatastore),
+		WithDatastoreCircuitBreaker(true, storagewrappers.CircuitBreakerPolicy{
+			FailureRateThreshold: 0.5,
+			MinRequests:          2,
+			Window:               time.Minute,
+			Cooldown:             time.Minute,
+			HalfOpenProbes:       1,
+		}),
+	)
+	t.Cleanup(func() {
+		mockDatastore.EXPECT().Close().Times(1)
+		s.Close()
+	})
+
+	storeID := ulid.Make().String()
+	for range 2 {
+		_, err := s.Read(ctx, &openfgav1.ReadRequest{StoreId: storeID})
+		require.Error(t, err)
+	}
+
+	_, err := s.Read(ctx, &openfgav1.ReadRequest{StoreId: storeID})
+	var unavailableErr *serverErrors.DatastoreUnavailableError
+	require.ErrorAs(t, err, &unavailableErr)
+	require.Positive(t, unavailableErr.RetryAfter)
+}
+
+// transientErrorDatastore is a datastore whose errors are all transient.
+type transientErrorDatastore struct {
+	*mockstorage.MockOpenFGADatastore
+}
+
+func (transientErrorDatastore) IsTransientError(error) bool {
+	return true
+}
+
+func TestDatastoreRetries(t *testing.T) {
+	t.Cleanup(func() {
+		goleak.VerifyNone(t)
+	})
+
+	ctx := context.Background()
+	mockController := gomock.NewController(t)
+	t.Cleanup(mockController.Finish)
+
+	// every read fails twice before it succeeds, so the breaker, which counts a read and its retries as
+	// one, never sees a failure
+	mockDatastore := mockstorage.NewMockOpenFGADatastore(mockController)
+	failures := 0
+	mockDatastore.EXPECT().ReadPage(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).
+		Times(9).
+		DoAndReturn(func(context.Context, string, *openfgav1.TupleKey, storage.ReadPageOptions) ([]*openfgav1.Tuple, string, error) {
+			failures++
+			if failures%3 != 0 {
+				return nil, "", errors.New("connection reset by peer")
+			}
+			return nil, "", nil
+		})
+
+	s := MustNewServerWithOpts(
+		WithDatastore(transientErrorDatastore{mockDatastore}),
+		WithDatastoreRetries(true, storagewrappers.RetryPolicy{
+			MaxAttempts:    3,
+			InitialBackoff: time.Millisecond,
+			MaxBackoff:     time.Millisecond,
+		}),
+		WithDatastoreCircuitBreaker(true, storagewrappers.CircuitBreakerPolicy{
+			FailureRateThreshold: 0.5,
+			MinRequests:          2,
+			Window:               time.Minute,
+			Cooldown:             time.Minute,
+			HalfOpenProbes:       1,
+		}),
+	)
+	t.Cleanup(func() {
+		mockDatastore.EXPECT().Close().Times(1)
+		s.Close()
+	})
+
+	storeID := ulid.Make().String()
+	for range 3 {
+		_, err := s.Read(ctx, &openfgav1.ReadRequest{StoreId: storeID})
+		require.NoError(t, err)
+	}
+}
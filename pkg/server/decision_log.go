@@ -0,0 +1,55 @@
+package server
+
+import (
+	"context"
+
+	openfgav1 "github.com/openfga/api/proto/openfga/v1"
+
+	"github.com/openfga/openfga/internal/graph"
+	"github.com/openfga/openfga/pkg/decisionlog"
+)
+
+// logDecision reports the decision of the Check to the decision logger, if one is set, see WithDecisionLogger.
+func (s *Server) logDecision(
+	ctx context.Context,
+	storeID, modelID string,
+	tupleKey *openfgav1.CheckRequestTupleKey,
+	contextualTuples []*openfgav1.TupleKey,
+	resp *graph.ResolveCheckResponse,
+) {
+	if s.decisionLogger == nil {
+		return
+	}
+
+	outcome := decisionlog.Outcome{
+		Allowed:       resp.GetAllowed(),
+		CycleDetected: resp.GetCycleDetected(),
+		Degraded:      resp.GetDegraded(),
+	}
+	// the Checks with a trace, see CheckWithTrace, are traced regardless of WithDecisionLogger
+	if s.decisionLogPath {
+		outcome.Path = decisionPath(resp.GetTrace())
+	}
+	s.decisionLogger(ctx, decisionlog.NewDecision(storeID, modelID, tupleKey, contextualTuples, outcome))
+}
+
+// decisionPath returns the path of a decision from the trace of its resolution, i.e. the nodes of the trace
+// that contributed to its outcome, or nil if the Check wasn't traced.
+func decisionPath(node *graph.CheckTrace) *decisionlog.Step {
+	if node == nil {
+		return nil
+	}
+
+	step := &decisionlog.Step{
+		TupleKey:  node.TupleKey,
+		Operation: string(node.Operation),
+		Allowed:   node.Allowed,
+		FromCache: node.FromCache,
+	}
+	for _, child := range node.Children {
+		if child.Contributed {
+			step.Children = append(step.Children, decisionPath(child))
+		}
+	}
+	return step
+}
@@ -0,0 +1,97 @@
+package server
+
+import (
+	"context"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"go.uber.org/goleak"
+
+	openfgav1 "github.com/openfga/api/proto/openfga/v1"
+	parser "github.com/openfga/language/pkg/go/transformer"
+
+	"github.com/openfga/openfga/pkg/decisionlog"
+	"github.com/openfga/openfga/pkg/storage/memory"
+	"github.com/openfga/openfga/pkg/tuple"
+)
+
+func TestCheckDecisionLogger(t *testing.T) {
+	t.Cleanup(func() {
+		goleak.VerifyNone(t)
+	})
+
+	ctx := context.Background()
+
+	for _, recordPath := range []bool{false, true} {
+		var (
+			mu        sync.Mutex
+			decisions []*decisionlog.Decision
+		)
+		s := MustNewServerWithOpts(
+			WithDatastore(memory.New()),
+			WithDecisionLogger(func(_ context.Context, decision *decisionlog.Decision) {
+				mu.Lock()
+				defer mu.Unlock()
+				decisions = append(decisions, decision)
+			}, recordPath),
+		)
+		t.Cleanup(s.Close)
+
+		createStoreResp, err := s.CreateStore(ctx, &openfgav1.CreateStoreRequest{Name: "decision-logger"})
+		require.NoError(t, err)
+		storeID := createStoreResp.GetId()
+
+		model := parser.MustTransformDSLToProto(`
+			model
+				schema 1.1
+			type user
+			type document
+				relations
+					define viewer: [user]
+					define can_view: viewer`)
+		writeModelResp, err := s.WriteAuthorizationModel(ctx, &openfgav1.WriteAuthorizationModelRequest{
+			StoreId:         storeID,
+			TypeDefinitions: model.GetTypeDefinitions(),
+			SchemaVersion:   model.GetSchemaVersion(),
+		})
+		require.NoError(t, err)
+		modelID := writeModelResp.GetAuthorizationModelId()
+
+		_, err = s.Write(ctx, &openfgav1.WriteRequest{
+			StoreId: storeID,
+			Writes: &openfgav1.WriteRequestWrites{
+				TupleKeys: []*openfgav1.TupleKey{tuple.NewTupleKey("document:1", "viewer", "user:anne")},
+			},
+		})
+		require.NoError(t, err)
+
+		_, err = s.Check(ctx, &openfgav1.CheckRequest{
+			StoreId:  storeID,
+			TupleKey: tuple.NewCheckRequestTupleKey("document:1", "can_view", "user:anne"),
+		})
+		require.NoError(t, err)
+
+		require.Len(t, decisions, 1)
+		decision := decisions[0]
+		require.Equal(t, storeID, decision.StoreID)
+		require.Equal(t, modelID, decision.AuthorizationModelID)
+		require.Equal(t, decisionlog.TupleKey{Object: "document:1", Relation: "can_view", User: "user:anne"}, decision.TupleKey)
+		require.Equal(t, decisionlog.DecisionAllow, decision.Decision)
+
+		if !recordPath {
+			require.Nil(t, decision.Path)
+			continue
+		}
+		require.Equal(t, &decisionlog.Step{
+			TupleKey:  "document:1#can_view@user:anne",
+			Operation: "computed",
+			Allowed:   true,
+			Children: []*decisionlog.Step{{
+				TupleKey:  "document:1#viewer@user:anne",
+				Operation: "this",
+				Allowed:   true,
+			}},
+		}, decision.Path)
+	}
+}
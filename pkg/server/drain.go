@@ -0,0 +1,134 @@
+package server
+
+import (
+	"context"
+	"sync"
+
+	"go.uber.org/zap"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// ErrServerDraining is returned for the requests received once the server started draining, see Server.Drain.
+var ErrServerDraining = status.Error(codes.Unavailable, "the server is shutting down")
+
+// requestDrainer tracks the requests in flight, so that the server can wait for them to finish before it
+// shuts down.
+type requestDrainer struct {
+	// draining is closed once the server starts draining.
+	draining chan struct{}
+
+	mu       sync.Mutex
+	started  bool          // GUARDED_BY(mu).
+	inflight int           // GUARDED_BY(mu).
+	idle     chan struct{} // GUARDED_BY(mu). Closed once draining and no request is in flight.
+}
+
+func newRequestDrainer() *requestDrainer {
+	return &requestDrainer{
+		draining: make(chan struct{}),
+		idle:     make(chan struct{}),
+	}
+}
+
+// begin records a new request in flight, unless the server is draining, in which case it returns
+// ErrServerDraining. The request must call the returned function once it's done.
+func (d *requestDrainer) begin() (func(), error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if d.started {
+		return nil, ErrServerDraining
+	}
+	d.inflight++
+
+	var once sync.Once
+	return func() {
+		once.Do(d.end)
+	}, nil
+}
+
+func (d *requestDrainer) end() {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	d.inflight--
+	if d.started && d.inflight == 0 {
+		close(d.idle)
+	}
+}
+
+// drain rejects the new requests and waits for the requests in flight to finish, or for the context to be
+// done. It returns the number of requests still in flight.
+func (d *requestDrainer) drain(ctx context.Context) int {
+	d.mu.Lock()
+	if !d.started {
+		d.started = true
+		close(d.draining)
+		if d.inflight == 0 {
+			close(d.idle)
+		}
+	}
+	d.mu.Unlock()
+
+	select {
+	case <-d.idle:
+		return 0
+	case <-ctx.Done():
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.inflight
+}
+
+// DrainUnaryInterceptor returns an interceptor that rejects the unary requests once the server started
+// draining, and tracks the others until they finish, see Drain.
+func (s *Server) DrainUnaryInterceptor() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, _ *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		done, err := s.drainer.begin()
+		if err != nil {
+			return nil, err
+		}
+		defer done()
+
+		return handler(ctx, req)
+	}
+}
+
+// DrainStreamInterceptor returns an interceptor that rejects the streaming requests once the server started
+// draining, and tracks the others until they finish, see Drain.
+func (s *Server) DrainStreamInterceptor() grpc.StreamServerInterceptor {
+	return func(srv interface{}, stream grpc.ServerStream, _ *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		done, err := s.drainer.begin()
+		if err != nil {
+			return err
+		}
+		defer done()
+
+		return handler(srv, stream)
+	}
+}
+
+// Drain shuts the server down gracefully. It stops accepting new requests, which fail with ErrServerDraining,
+// and lets the requests in flight finish until the context is done. StreamedListObjects requests stop
+// streaming once their datastore queries in flight return, and end with the objects streamed so far and a
+// trailer marking them as truncated.
+// The check cache, if configured, is then flushed. Drain doesn't close the server: the gRPC server must be
+// stopped first, and the server closed afterwards, which closes the datastore.
+//
+// Only the requests that went through DrainUnaryInterceptor or DrainStreamInterceptor are tracked. Drain
+// returns the number of those that didn't finish before the context was done.
+func (s *Server) Drain(ctx context.Context) int {
+	unfinished := s.drainer.drain(ctx)
+	if unfinished > 0 {
+		s.logger.Warn("requests did not finish before the server was drained", zap.Int("requests", unfinished))
+	}
+
+	if checkCache := s.sharedDatastoreResources.CheckCache; checkCache != nil {
+		checkCache.Clear()
+	}
+
+	return unfinished
+}
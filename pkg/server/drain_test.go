@@ -0,0 +1,162 @@
+package server
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"go.uber.org/goleak"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	openfgav1 "github.com/openfga/api/proto/openfga/v1"
+	parser "github.com/openfga/language/pkg/go/transformer"
+
+	"github.com/openfga/openfga/pkg/server/commands"
+	"github.com/openfga/openfga/pkg/storage/memory"
+	"github.com/openfga/openfga/pkg/tuple"
+)
+
+func TestDrain(t *testing.T) {
+	t.Cleanup(func() {
+		goleak.VerifyNone(t)
+	})
+
+	ctx := context.Background()
+
+	// setup returns a server whose reads of single tuples block until they are released
+	setup := func(t *testing.T) (*Server, *blockingDatastore, string) {
+		ds := &blockingDatastore{
+			OpenFGADatastore: memory.New(),
+			started:          make(chan struct{}),
+			release:          make(chan struct{}),
+		}
+		s := MustNewServerWithOpts(WithDatastore(ds))
+		t.Cleanup(s.Close)
+
+		createStoreResp, err := s.CreateStore(ctx, &openfgav1.CreateStoreRequest{Name: "drain"})
+		require.NoError(t, err)
+		storeID := createStoreResp.GetId()
+
+		model := parser.MustTransformDSLToProto(`
+			model
+				schema 1.1
+			type user
+			type document
+				relations
+					define blocked: [user]
+					define viewer: [user] but not blocked`)
+		_, err = s.WriteAuthorizationModel(ctx, &openfgav1.WriteAuthorizationModelRequest{
+			StoreId:         storeID,
+			TypeDefinitions: model.GetTypeDefinitions(),
+			SchemaVersion:   model.GetSchemaVersion(),
+		})
+		require.NoError(t, err)
+
+		_, err = s.Write(ctx, &openfgav1.WriteRequest{
+			StoreId: storeID,
+			Writes: &openfgav1.WriteRequestWrites{
+				TupleKeys: []*openfgav1.TupleKey{tuple.NewTupleKey("document:1", "viewer", "user:anne")},
+			},
+		})
+		require.NoError(t, err)
+
+		return s, ds, storeID
+	}
+
+	check := func(s *Server, storeID string) (interface{}, error) {
+		return s.DrainUnaryInterceptor()(ctx, &openfgav1.CheckRequest{
+			StoreId:  storeID,
+			TupleKey: tuple.NewCheckRequestTupleKey("document:1", "viewer", "user:anne"),
+		}, &grpc.UnaryServerInfo{}, func(ctx context.Context, req interface{}) (interface{}, error) {
+			return s.Check(ctx, req.(*openfgav1.CheckRequest))
+		})
+	}
+
+	t.Run("waits_for_the_requests_in_flight", func(t *testing.T) {
+		s, ds, storeID := setup(t)
+
+		checkDone := make(chan error, 1)
+		go func() {
+			resp, err := check(s, storeID)
+			if err == nil && !resp.(*openfgav1.CheckResponse).GetAllowed() {
+				t.Error("expected the Check to be allowed")
+			}
+			checkDone <- err
+		}()
+		<-ds.started
+
+		drained := make(chan int, 1)
+		go func() {
+			drained <- s.Drain(ctx)
+		}()
+
+		// new requests are rejected while the Check in flight is drained
+		<-s.drainer.draining
+		_, err := check(s, storeID)
+		require.Equal(t, codes.Unavailable, status.Code(err))
+
+		select {
+		case <-drained:
+			require.FailNow(t, "the server was drained before the Check in flight finished")
+		case <-time.After(50 * time.Millisecond):
+		}
+
+		close(ds.release)
+		require.NoError(t, <-checkDone)
+		require.Zero(t, <-drained)
+	})
+
+	t.Run("returns_the_requests_that_did_not_finish", func(t *testing.T) {
+		s, ds, storeID := setup(t)
+
+		checkDone := make(chan struct{})
+		go func() {
+			defer close(checkDone)
+			_, _ = check(s, storeID)
+		}()
+		<-ds.started
+
+		drainCtx, cancel := context.WithTimeout(ctx, 50*time.Millisecond)
+		defer cancel()
+		require.Equal(t, 1, s.Drain(drainCtx))
+
+		close(ds.release)
+		<-checkDone
+	})
+
+	t.Run("interrupts_streamed_list_objects", func(t *testing.T) {
+		s, ds, storeID := setup(t)
+
+		stream := NewMockStreamServer(ctx)
+		streamDone := make(chan error, 1)
+		go func() {
+			streamDone <- s.DrainStreamInterceptor()(s, stream, &grpc.StreamServerInfo{}, func(srv interface{}, stream grpc.ServerStream) error {
+				return srv.(*Server).StreamedListObjects(&openfgav1.StreamedListObjectsRequest{
+					StoreId:  storeID,
+					Type:     "document",
+					Relation: "viewer",
+					User:     "user:anne",
+				}, stream.(*mockStreamServer))
+			})
+		}()
+		// the object is being checked against the blocked relation
+		<-ds.started
+
+		drained := make(chan int, 1)
+		go func() {
+			drained <- s.Drain(ctx)
+		}()
+
+		// the stream stops once the read in flight returns
+		<-s.drainer.draining
+		time.Sleep(50 * time.Millisecond)
+		close(ds.release)
+
+		require.Zero(t, <-drained)
+		require.NoError(t, <-streamDone)
+		require.Equal(t, []string{string(commands.ListObjectsTruncatedByInterrupt)}, stream.trailer.Get(ListObjectsTruncatedHeader))
+	})
+}
@@ -3,6 +3,7 @@ package errors
 import (
 	"net/http"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/require"
 	"google.golang.org/grpc/codes"
@@ -349,6 +350,11 @@ func TestConvertToEncodedErrorCode(t *testing.T) {
 			status:            status.New(codes.InvalidArgument, "invalid WriteTokenIssuersRequest.Params: embedded message failed validation | caused by: invalid WriteTokenIssuersRequestParams.TypeDefinitions: value must contain at least 1 item"),
 			expectedErrorCode: int32(openfgav1.ErrorCode_type_definitions_too_few_items),
 		},
+		{
+			_name:             "throttled",
+			status:            status.Convert(&ThrottledError{Limit: "dispatch_throttling", RetryAfter: time.Second}),
+			expectedErrorCode: int32(openfgav1.UnprocessableContentErrorCode_throttled_timeout_error),
+		},
 	}
 
 	for _, test := range tests {
@@ -5,6 +5,9 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"math"
+	"strconv"
+	"time"
 
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/status"
@@ -17,24 +20,90 @@ import (
 
 const InternalServerErrorMsg = "Internal Server Error"
 
+// RetryAfterKey is the key of the trailing metadata of a throttled response, whose value is the number of
+// seconds the client should wait before retrying the request.
+const RetryAfterKey = "retry-after"
+
 var (
 	// ErrAuthorizationModelResolutionTooComplex is used to avoid stack overflows.
 	ErrAuthorizationModelResolutionTooComplex = status.Error(codes.Code(openfgav1.ErrorCode_authorization_model_resolution_too_complex), "Authorization Model resolution required too many rewrite rules to be resolved. Check your authorization model for infinite recursion or too much nesting")
-	ErrInvalidWriteInput                      = status.Error(codes.Code(openfgav1.ErrorCode_invalid_write_input), "Invalid input. Make sure you provide at least one write, or at least one delete")
-	ErrInvalidContinuationToken               = status.Error(codes.Code(openfgav1.ErrorCode_invalid_continuation_token), "Invalid continuation token")
-	ErrInvalidStartTime                       = status.Error(codes.Code(openfgav1.ErrorCode_invalid_start_time), "Invalid start time")
-	ErrInvalidExpandInput                     = status.Error(codes.Code(openfgav1.ErrorCode_invalid_expand_input), "Invalid input. Make sure you provide an object and a relation")
-	ErrUnsupportedUserSet                     = status.Error(codes.Code(openfgav1.ErrorCode_unsupported_user_set), "Userset is not supported (right now)")
-	ErrStoreIDNotFound                        = status.Error(codes.Code(openfgav1.NotFoundErrorCode_store_id_not_found), "Store ID not found")
-	ErrMismatchObjectType                     = status.Error(codes.Code(openfgav1.ErrorCode_query_string_type_continuation_token_mismatch), "The type in the querystring and the continuation token don't match")
-	ErrRequestCancelled                       = status.Error(codes.Code(openfgav1.ErrorCode_cancelled), "Request Cancelled")
-	ErrRequestDeadlineExceeded                = status.Error(codes.Code(openfgav1.InternalErrorCode_deadline_exceeded), "Request Deadline Exceeded")
-	ErrThrottledTimeout                       = status.Error(codes.Code(openfgav1.UnprocessableContentErrorCode_throttled_timeout_error), "timeout due to throttling on complex request")
+	// ErrAuthorizationModelResolutionTooDeep is returned when a path of a Check expands more rewrites than allowed.
+	ErrAuthorizationModelResolutionTooDeep = status.Error(codes.Code(openfgav1.ErrorCode_authorization_model_resolution_too_complex), "Authorization Model resolution expanded too many nested rewrite rules. Check your authorization model for long chains of relations")
+	ErrInvalidWriteInput                   = status.Error(codes.Code(openfgav1.ErrorCode_invalid_write_input), "Invalid input. Make sure you provide at least one write, or at least one delete")
+	ErrInvalidContinuationToken            = status.Error(codes.Code(openfgav1.ErrorCode_invalid_continuation_token), "Invalid continuation token")
+	ErrInvalidStartTime                    = status.Error(codes.Code(openfgav1.ErrorCode_invalid_start_time), "Invalid start time")
+	ErrChangelogCompacted                  = status.Error(codes.Code(openfgav1.ErrorCode_invalid_continuation_token), "Changes before the changelog compaction horizon are no longer available")
+	ErrInvalidExpandInput                  = status.Error(codes.Code(openfgav1.ErrorCode_invalid_expand_input), "Invalid input. Make sure you provide an object and a relation")
+	ErrUnsupportedUserSet                  = status.Error(codes.Code(openfgav1.ErrorCode_unsupported_user_set), "Userset is not supported (right now)")
+	ErrStoreIDNotFound                     = status.Error(codes.Code(openfgav1.NotFoundErrorCode_store_id_not_found), "Store ID not found")
+	ErrMismatchObjectType                  = status.Error(codes.Code(openfgav1.ErrorCode_query_string_type_continuation_token_mismatch), "The type in the querystring and the continuation token don't match")
+	ErrRequestCancelled                    = status.Error(codes.Code(openfgav1.ErrorCode_cancelled), "Request Cancelled")
+	ErrRequestDeadlineExceeded             = status.Error(codes.Code(openfgav1.InternalErrorCode_deadline_exceeded), "Request Deadline Exceeded")
+	ErrThrottledTimeout                    = status.Error(codes.Code(openfgav1.UnprocessableContentErrorCode_throttled_timeout_error), "timeout due to throttling on complex request")
+	ErrCheckSessionNotFound                = status.Error(codes.NotFound, "Check session not found")
+	ErrTooManyCheckSessions                = status.Error(codes.ResourceExhausted, "Too many open Check sessions")
+	ErrUsersetFanoutExceeded               = status.Error(codes.Code(openfgav1.ErrorCode_exceeded_entity_limit), "The number of usersets related to an object through a relation exceeds the allowed userset fan-out")
 
 	// ErrTransactionThrottled can apply when a limit is hit at the database level.
 	ErrTransactionThrottled = status.Error(codes.ResourceExhausted, "transaction was throttled by the datastore")
 )
 
+// RetryAfterSeconds returns the delay in whole seconds, rounded up, as sent in the RetryAfterKey trailing metadata.
+func RetryAfterSeconds(d time.Duration) string {
+	return strconv.FormatInt(int64(math.Ceil(d.Seconds())), 10)
+}
+
+// ThrottledError is returned when a request couldn't get past a limit within its deadline. It's sent to the
+// client as ErrThrottledTimeout, whose message is suffixed with the name of the limit, while the suggested
+// delay before retrying the request is sent in the RetryAfterKey trailing metadata.
+type ThrottledError struct {
+	// Limit is the name of the limit that throttled the request.
+	Limit string
+	// RetryAfter is the suggested delay before retrying the request.
+	RetryAfter time.Duration
+}
+
+func (e *ThrottledError) Error() string {
+	return fmt.Sprintf("%s (limit: %s)", status.Convert(ErrThrottledTimeout).Message(), e.Limit)
+}
+
+// Is reports the error as ErrThrottledTimeout, which it's sent to the client as.
+func (e *ThrottledError) Is(target error) bool {
+	return target == ErrThrottledTimeout
+}
+
+// RetryAfterSeconds returns the suggested delay in whole seconds, rounded up, as sent in the RetryAfterKey
+// trailing metadata.
+func (e *ThrottledError) RetryAfterSeconds() string {
+	return RetryAfterSeconds(e.RetryAfter)
+}
+
+func (e *ThrottledError) GRPCStatus() *status.Status {
+	return status.New(status.Code(ErrThrottledTimeout), e.Error())
+}
+
+// DatastoreUnavailableError is returned when a request fails fast because the circuit breaker of the datastore
+// is open. It's sent to the client as an UNAVAILABLE error, while the suggested delay before retrying the request
+// is sent in the RetryAfterKey trailing metadata.
+type DatastoreUnavailableError struct {
+	// RetryAfter is the suggested delay before retrying the request.
+	RetryAfter time.Duration
+}
+
+func (e *DatastoreUnavailableError) Error() string {
+	return fmt.Sprintf("datastore unavailable, retry after %s", e.RetryAfter)
+}
+
+// RetryAfterSeconds returns the suggested delay in whole seconds, rounded up, as sent in the RetryAfterKey
+// trailing metadata.
+func (e *DatastoreUnavailableError) RetryAfterSeconds() string {
+	return RetryAfterSeconds(e.RetryAfter)
+}
+
+func (e *DatastoreUnavailableError) GRPCStatus() *status.Status {
+	return status.New(codes.Unavailable, e.Error())
+}
+
 type InternalError struct {
 	public   error
 	internal error
@@ -91,6 +160,11 @@ func TypeNotFound(objectType string) error {
 	return status.Error(codes.Code(openfgav1.ErrorCode_type_not_found), fmt.Sprintf("type '%s' not found", objectType))
 }
 
+// ObjectNotFound is returned when the object of a Check isn't registered, see server.WithCheckObjectRegistry.
+func ObjectNotFound(object string) error {
+	return status.Error(codes.NotFound, fmt.Sprintf("object '%s' not found", object))
+}
+
 func RelationNotFound(relation string, objectType string, tk *openfgav1.TupleKey) error {
 	msg := fmt.Sprintf("relation '%s#%s' not found", objectType, relation)
 	if tk != nil {
@@ -109,6 +183,12 @@ func DuplicateTupleInWrite(tk tuple.TupleWithoutCondition) error {
 	return status.Error(codes.Code(openfgav1.ErrorCode_cannot_allow_duplicate_tuples_in_one_request), fmt.Sprintf("duplicate tuple in write: user: '%s', relation: '%s', object: '%s'", tk.GetUser(), tk.GetRelation(), tk.GetObject()))
 }
 
+// ConflictingTupleInWrite is returned when a Write request both writes and deletes the same tuple, for which
+// the outcome would depend on the order in which the datastore applies them.
+func ConflictingTupleInWrite(tk tuple.TupleWithoutCondition) error {
+	return status.Error(codes.Code(openfgav1.ErrorCode_cannot_allow_duplicate_tuples_in_one_request), fmt.Sprintf("tuple is both written and deleted in write: user: '%s', relation: '%s', object: '%s'", tk.GetUser(), tk.GetRelation(), tk.GetObject()))
+}
+
 func WriteFailedDueToInvalidInput(err error) error {
 	return status.Error(codes.Code(openfgav1.ErrorCode_write_failed_due_to_invalid_input), err.Error())
 }
@@ -120,7 +200,10 @@ func InvalidAuthorizationModelInput(err error) error {
 // HandleError is used to surface some errors, and hide others.
 // Use `public` if you want to return a useful error message to the user.
 func HandleError(public string, err error) error {
+	var circuitOpenErr *storage.CircuitOpenError
 	switch {
+	case errors.As(err, &circuitOpenErr):
+		return &DatastoreUnavailableError{RetryAfter: circuitOpenErr.RetryAfter}
 	case errors.Is(err, storage.ErrTransactionThrottled):
 		return ErrTransactionThrottled
 	case errors.Is(err, context.Canceled):
@@ -132,6 +215,8 @@ func HandleError(public string, err error) error {
 		return ErrInvalidStartTime
 	case errors.Is(err, storage.ErrInvalidContinuationToken):
 		return ErrInvalidContinuationToken
+	case errors.Is(err, storage.ErrChangelogCompacted):
+		return ErrChangelogCompacted
 	default:
 		return NewInternalError(public, err)
 	}
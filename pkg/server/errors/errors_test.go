@@ -5,6 +5,7 @@ import (
 	"errors"
 	"fmt"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/require"
 	"google.golang.org/grpc/codes"
@@ -51,6 +52,31 @@ func TestInternalError(t *testing.T) {
 	})
 }
 
+func TestThrottledError(t *testing.T) {
+	err := &ThrottledError{Limit: "dispatch_throttling", RetryAfter: 1500 * time.Millisecond}
+	require.Equal(t, "2", err.RetryAfterSeconds())
+
+	// it's sent to the client as ErrThrottledTimeout
+	require.ErrorIs(t, err, ErrThrottledTimeout)
+
+	st := status.Convert(err)
+	require.Equal(t, status.Code(ErrThrottledTimeout), st.Code())
+	require.Equal(t, "timeout due to throttling on complex request (limit: dispatch_throttling)", st.Message())
+	require.Empty(t, st.Details())
+}
+
+func TestDatastoreUnavailableError(t *testing.T) {
+	err := HandleError("", fmt.Errorf("reading tuples: %w", &storage.CircuitOpenError{Breaker: "read", RetryAfter: 2500 * time.Millisecond}))
+
+	var unavailableErr *DatastoreUnavailableError
+	require.ErrorAs(t, err, &unavailableErr)
+	require.Equal(t, "3", unavailableErr.RetryAfterSeconds())
+
+	st := status.Convert(err)
+	require.Equal(t, codes.Unavailable, st.Code())
+	require.Equal(t, "datastore unavailable, retry after 2.5s", st.Message())
+}
+
 func TestHandleErrors(t *testing.T) {
 	tests := map[string]struct {
 		storageErr              error
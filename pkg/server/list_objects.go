@@ -9,7 +9,9 @@ import (
 	"go.opentelemetry.io/otel/attribute"
 	"go.opentelemetry.io/otel/trace"
 	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
 	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
 
 	openfgav1 "github.com/openfga/api/proto/openfga/v1"
 
@@ -20,6 +22,7 @@ import (
 	"github.com/openfga/openfga/pkg/middleware/validator"
 	"github.com/openfga/openfga/pkg/server/commands"
 	serverErrors "github.com/openfga/openfga/pkg/server/errors"
+	"github.com/openfga/openfga/pkg/storage"
 	"github.com/openfga/openfga/pkg/telemetry"
 	"github.com/openfga/openfga/pkg/typesystem"
 )
@@ -44,6 +47,10 @@ func (s *Server) ListObjects(ctx context.Context, req *openfgav1.ListObjectsRequ
 		}
 	}
 
+	if err := s.validateContextualTuples(req.GetContextualTuples().GetTupleKeys()); err != nil {
+		return nil, err
+	}
+
 	// TODO: This should be apimethod.ListObjects, but is it considered a breaking change to move?
 	const methodName = "listobjects"
 
@@ -59,10 +66,22 @@ func (s *Server) ListObjects(ctx context.Context, req *openfgav1.ListObjectsRequ
 
 	storeID := req.GetStoreId()
 
+	consistency, err := s.resolveConsistency(ctx, storeID, req.GetConsistency())
+	if err != nil {
+		return nil, err
+	}
+	if consistency != req.GetConsistency() {
+		req = proto.CloneOf(req)
+		req.Consistency = consistency
+		span.SetAttributes(attribute.String("consistency", consistency.String()))
+	}
+
+	ctx = storage.ContextWithConsistency(ctx, consistency)
 	typesys, err := s.resolveTypesystem(ctx, storeID, req.GetAuthorizationModelId())
 	if err != nil {
 		return nil, err
 	}
+	resolvedReq := s.resolvedListObjectsRequest(ctx, typesys, req)
 
 	q, err := commands.NewListObjectsQueryWithShadowConfig(
 		s.datastore,
@@ -74,38 +93,29 @@ func (s *Server) ListObjects(ctx context.Context, req *openfgav1.ListObjectsRequ
 			commands.WithShadowListObjectsQueryMaxDeltaItems(s.shadowListObjectsQueryMaxDeltaItems),
 			commands.WithShadowListObjectsQueryLogger(s.logger),
 		),
-		commands.WithLogger(s.logger),
-		commands.WithListObjectsDeadline(s.listObjectsDeadline),
-		commands.WithListObjectsMaxResults(s.listObjectsMaxResults),
-		commands.WithDispatchThrottlerConfig(threshold.Config{
-			Throttler:    s.listObjectsDispatchThrottler,
-			Enabled:      s.listObjectsDispatchThrottlingEnabled,
-			Threshold:    s.listObjectsDispatchDefaultThreshold,
-			MaxThreshold: s.listObjectsDispatchThrottlingMaxThreshold,
-		}),
-		commands.WithResolveNodeLimit(s.resolveNodeLimit),
-		commands.WithResolveNodeBreadthLimit(s.resolveNodeBreadthLimit),
-		commands.WithMaxConcurrentReads(s.maxConcurrentReadsForListObjects),
-		commands.WithListObjectsCache(s.sharedDatastoreResources, s.cacheSettings),
-		commands.WithListObjectsDatastoreThrottler(s.listObjectsDatastoreThrottleThreshold, s.listObjectsDatastoreThrottleDuration),
-		commands.WithListObjectsOptimizationsEnabled(s.IsExperimentallyEnabled(ExperimentalListObjectsOptimizations)),
+		s.listObjectsQueryOptions()...,
 	)
 	if err != nil {
 		return nil, serverErrors.NewInternalError("", err)
 	}
 
+	if s.cacheSettings.ShouldCacheListObjectsQueries() {
+		q, err = commands.NewCachedListObjectsResolver(q,
+			commands.WithCachedListObjectsExistingCache(s.sharedDatastoreResources.CheckCache),
+			commands.WithCachedListObjectsCacheController(s.sharedDatastoreResources.CacheController),
+			commands.WithCachedListObjectsTTL(s.cacheSettings.ListObjectsQueryCacheTTL),
+			commands.WithCachedListObjectsMaxResults(s.cacheSettings.ListObjectsQueryCacheMaxResults),
+			commands.WithCachedListObjectsDeadline(s.listObjectsDeadline),
+			commands.WithCachedListObjectsLogger(s.logger),
+		)
+		if err != nil {
+			return nil, serverErrors.NewInternalError("", err)
+		}
+	}
+
 	result, err := q.Execute(
 		typesystem.ContextWithTypesystem(ctx, typesys),
-		&openfgav1.ListObjectsRequest{
-			StoreId:              storeID,
-			ContextualTuples:     req.GetContextualTuples(),
-			AuthorizationModelId: typesys.GetAuthorizationModelID(), // the resolved model id
-			Type:                 targetObjectType,
-			Relation:             req.GetRelation(),
-			User:                 req.GetUser(),
-			Context:              req.GetContext(),
-			Consistency:          req.GetConsistency(),
-		},
+		resolvedReq,
 	)
 	if err != nil {
 		telemetry.TraceError(span, err)
@@ -113,6 +123,7 @@ func (s *Server) ListObjects(ctx context.Context, req *openfgav1.ListObjectsRequ
 			return nil, serverErrors.ValidationError(err)
 		}
 
+		s.observeDatastoreError(storeID, err)
 		return nil, err
 	}
 	datastoreQueryCount := float64(result.ResolutionMetadata.DatastoreQueryCount.Load())
@@ -155,11 +166,139 @@ func (s *Server) ListObjects(ctx context.Context, req *openfgav1.ListObjectsRequ
 	checkCounter := float64(result.ResolutionMetadata.CheckCounter.Load())
 	grpc_ctxtags.Extract(ctx).Set(listObjectsCheckCountName, checkCounter)
 
+	observeListObjectsEfficiency(methodName, targetObjectType, resolvedReq.GetRelation(), &result.ResolutionMetadata, uint32(len(result.Objects)), time.Since(start))
+
+	if result.Truncated {
+		span.SetAttributes(attribute.String("truncated_by", string(result.TruncatedBy)))
+		s.transport.SetHeader(ctx, ListObjectsTruncatedHeader, string(result.TruncatedBy))
+	}
+
 	return &openfgav1.ListObjectsResponse{
 		Objects: result.Objects,
 	}, nil
 }
 
+// CountObjects returns the number of objects that ListObjects would return for the request, without
+// returning the objects. The count stops at the configured cap (see WithCountObjectsMaxCount), in which
+// case the response is marked as capped and the actual number of objects is at least the count.
+func (s *Server) CountObjects(ctx context.Context, req *openfgav1.ListObjectsRequest) (*commands.CountObjectsResponse, error) {
+	ctx, span := tracer.Start(ctx, apimethod.CountObjects.String(), trace.WithAttributes(
+		attribute.String("store_id", req.GetStoreId()),
+		attribute.String("object_type", req.GetType()),
+		attribute.String("relation", req.GetRelation()),
+		attribute.String("user", req.GetUser()),
+		attribute.String("consistency", req.GetConsistency().String()),
+	))
+	defer span.End()
+
+	if !validator.RequestIsValidatedFromContext(ctx) {
+		if err := req.Validate(); err != nil {
+			return nil, status.Error(codes.InvalidArgument, err.Error())
+		}
+	}
+
+	if err := s.validateContextualTuples(req.GetContextualTuples().GetTupleKeys()); err != nil {
+		return nil, err
+	}
+
+	ctx = telemetry.ContextWithRPCInfo(ctx, telemetry.RPCInfo{
+		Service: s.serviceName,
+		Method:  apimethod.CountObjects.String(),
+	})
+
+	// counting the objects discloses as much as listing them
+	err := s.checkAuthz(ctx, req.GetStoreId(), apimethod.ListObjects)
+	if err != nil {
+		return nil, err
+	}
+
+	storeID := req.GetStoreId()
+
+	consistency, err := s.resolveConsistency(ctx, storeID, req.GetConsistency())
+	if err != nil {
+		return nil, err
+	}
+	if consistency != req.GetConsistency() {
+		req = proto.CloneOf(req)
+		req.Consistency = consistency
+		span.SetAttributes(attribute.String("consistency", consistency.String()))
+	}
+
+	ctx = storage.ContextWithConsistency(ctx, consistency)
+	typesys, err := s.resolveTypesystem(ctx, storeID, req.GetAuthorizationModelId())
+	if err != nil {
+		return nil, err
+	}
+
+	q, err := commands.NewListObjectsQuery(
+		s.datastore,
+		s.listObjectsCheckResolver,
+		s.listObjectsQueryOptions()...,
+	)
+	if err != nil {
+		return nil, serverErrors.NewInternalError("", err)
+	}
+
+	result, err := q.CountObjects(
+		typesystem.ContextWithTypesystem(ctx, typesys),
+		s.resolvedListObjectsRequest(ctx, typesys, req),
+		s.countObjectsMaxCount,
+	)
+	if err != nil {
+		telemetry.TraceError(span, err)
+		if errors.Is(err, condition.ErrEvaluationFailed) {
+			return nil, serverErrors.ValidationError(err)
+		}
+
+		s.observeDatastoreError(storeID, err)
+		return nil, err
+	}
+
+	span.SetAttributes(
+		attribute.Int64("count", int64(result.Count)),
+		attribute.Bool("capped", result.Capped),
+	)
+
+	return result, nil
+}
+
+// listObjectsQueryOptions returns the options of the ListObjectsQuery that ListObjects and CountObjects evaluate.
+func (s *Server) listObjectsQueryOptions() []commands.ListObjectsQueryOption {
+	return []commands.ListObjectsQueryOption{
+		commands.WithLogger(s.logger),
+		commands.WithListObjectsDeadline(s.listObjectsDeadline),
+		commands.WithListObjectsMaxResults(s.listObjectsMaxResults),
+		commands.WithListObjectsOrderBy(s.listObjectsOrderBy),
+		commands.WithDispatchThrottlerConfig(threshold.Config{
+			Throttler:    s.listObjectsDispatchThrottler,
+			Enabled:      s.listObjectsDispatchThrottlingEnabled,
+			Threshold:    s.listObjectsDispatchDefaultThreshold,
+			MaxThreshold: s.listObjectsDispatchThrottlingMaxThreshold,
+		}),
+		commands.WithResolveNodeLimit(s.resolveNodeLimit),
+		commands.WithResolveNodeBreadthLimit(s.resolveNodeBreadthLimit),
+		commands.WithMaxConcurrentReads(s.maxConcurrentReadsForListObjects),
+		commands.WithListObjectsCache(s.sharedDatastoreResources, s.cacheSettings),
+		commands.WithListObjectsDatastoreThrottler(s.listObjectsDatastoreThrottleThreshold, s.listObjectsDatastoreThrottleDuration),
+		commands.WithListObjectsOptimizationsEnabled(s.IsExperimentallyEnabled(ExperimentalListObjectsOptimizations)),
+	}
+}
+
+// resolvedListObjectsRequest returns the request that the ListObjectsQuery evaluates for req, i.e. with the
+// resolved model id, relation alias and condition context.
+func (s *Server) resolvedListObjectsRequest(ctx context.Context, typesys *typesystem.TypeSystem, req *openfgav1.ListObjectsRequest) *openfgav1.ListObjectsRequest {
+	return &openfgav1.ListObjectsRequest{
+		StoreId:              req.GetStoreId(),
+		ContextualTuples:     req.GetContextualTuples(),
+		AuthorizationModelId: typesys.GetAuthorizationModelID(), // the resolved model id
+		Type:                 req.GetType(),
+		Relation:             s.resolveRelationAlias(ctx, req.GetStoreId(), req.GetType(), req.GetRelation()),
+		User:                 req.GetUser(),
+		Context:              s.conditionContext(typesys, req.GetContext()),
+		Consistency:          req.GetConsistency(),
+	}
+}
+
 func (s *Server) StreamedListObjects(req *openfgav1.StreamedListObjectsRequest, srv openfgav1.OpenFGAService_StreamedListObjectsServer) error {
 	start := time.Now()
 
@@ -179,6 +318,10 @@ func (s *Server) StreamedListObjects(req *openfgav1.StreamedListObjectsRequest,
 		}
 	}
 
+	if err := s.validateContextualTuples(req.GetContextualTuples().GetTupleKeys()); err != nil {
+		return err
+	}
+
 	// TODO: This should be apimethod.StreamedListObjects, but is it considered a breaking change to move?
 	const methodName = "streamedlistobjects"
 
@@ -194,6 +337,13 @@ func (s *Server) StreamedListObjects(req *openfgav1.StreamedListObjectsRequest,
 
 	storeID := req.GetStoreId()
 
+	consistency, err := s.resolveConsistency(ctx, storeID, req.GetConsistency())
+	if err != nil {
+		return err
+	}
+	req.Consistency = consistency
+
+	ctx = storage.ContextWithConsistency(ctx, consistency)
 	typesys, err := s.resolveTypesystem(ctx, storeID, req.GetAuthorizationModelId())
 	if err != nil {
 		return err
@@ -218,6 +368,9 @@ func (s *Server) StreamedListObjects(req *openfgav1.StreamedListObjectsRequest,
 			MaxThreshold: s.listObjectsDispatchThrottlingMaxThreshold,
 		}),
 		commands.WithListObjectsMaxResults(s.listObjectsMaxResults),
+		commands.WithStreamedListObjectsMaxResults(s.streamedListObjectsMaxResults),
+		commands.WithListObjectsOrderBy(s.listObjectsOrderBy),
+		commands.WithStreamedListObjectsInterrupt(s.drainer.draining),
 		commands.WithResolveNodeLimit(s.resolveNodeLimit),
 		commands.WithResolveNodeBreadthLimit(s.resolveNodeBreadthLimit),
 		commands.WithMaxConcurrentReads(s.maxConcurrentReadsForListObjects),
@@ -227,6 +380,8 @@ func (s *Server) StreamedListObjects(req *openfgav1.StreamedListObjectsRequest,
 	}
 
 	req.AuthorizationModelId = typesys.GetAuthorizationModelID() // the resolved model id
+	req.Relation = s.resolveRelationAlias(ctx, storeID, req.GetType(), req.GetRelation())
+	req.Context = s.conditionContext(typesys, req.GetContext())
 
 	resolutionMetadata, err := q.ExecuteStreamed(
 		typesystem.ContextWithTypesystem(ctx, typesys),
@@ -235,6 +390,7 @@ func (s *Server) StreamedListObjects(req *openfgav1.StreamedListObjectsRequest,
 	)
 	if err != nil {
 		telemetry.TraceError(span, err)
+		s.observeDatastoreError(storeID, err)
 		return err
 	}
 	datastoreQueryCount := float64(resolutionMetadata.DatastoreQueryCount.Load())
@@ -268,5 +424,27 @@ func (s *Server) StreamedListObjects(req *openfgav1.StreamedListObjectsRequest,
 		throttledRequestCounter.WithLabelValues(s.serviceName, methodName).Inc()
 	}
 
+	observeListObjectsEfficiency(methodName, req.GetType(), req.GetRelation(), resolutionMetadata, resolutionMetadata.ObjectsReturned.Load(), time.Since(start))
+
+	// the objects have already been streamed, so the reason they were cut off is sent in the trailer
+	if truncatedBy := resolutionMetadata.Truncation(); truncatedBy != commands.ListObjectsNotTruncated {
+		span.SetAttributes(attribute.String("truncated_by", string(truncatedBy)))
+		srv.SetTrailer(metadata.Pairs(ListObjectsTruncatedHeader, string(truncatedBy)))
+	}
+
 	return nil
 }
+
+// observeListObjectsEfficiency records the work done by a ListObjects request relative to the objects it returned.
+func observeListObjectsEfficiency(
+	methodName, objectType, relation string,
+	metadata *commands.ListObjectsResolutionMetadata,
+	objectsReturned uint32,
+	duration time.Duration,
+) {
+	listObjectsObjectsScannedHistogram.WithLabelValues(methodName, objectType, relation).Observe(float64(metadata.ObjectsScanned.Load()))
+	listObjectsObjectsReturnedHistogram.WithLabelValues(methodName, objectType, relation).Observe(float64(objectsReturned))
+	checksIssued := metadata.ChecksIssued.Load() + metadata.CheckCounter.Load()
+	listObjectsChecksIssuedHistogram.WithLabelValues(methodName, objectType, relation).Observe(float64(checksIssued))
+	listObjectsDurationHistogram.WithLabelValues(methodName, objectType, relation).Observe(float64(duration.Milliseconds()))
+}
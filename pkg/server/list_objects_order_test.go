@@ -0,0 +1,88 @@
+package server
+
+import (
+	"context"
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"go.uber.org/goleak"
+
+	openfgav1 "github.com/openfga/api/proto/openfga/v1"
+	parser "github.com/openfga/language/pkg/go/transformer"
+
+	"github.com/openfga/openfga/cmd/util"
+	serverconfig "github.com/openfga/openfga/pkg/server/config"
+	"github.com/openfga/openfga/pkg/tuple"
+)
+
+func TestListObjectsOrderAcrossDatastores(t *testing.T) {
+	t.Cleanup(func() {
+		goleak.VerifyNone(t)
+	})
+
+	ctx := context.Background()
+	model := parser.MustTransformDSLToProto(`
+		model
+			schema 1.1
+		type user
+		type group
+			relations
+				define member: [user]
+		type document
+			relations
+				define viewer: [user, group#member]`)
+
+	// the same fixture, written out of order, for every datastore
+	var tuples []*openfgav1.TupleKey
+	for _, i := range []int{12, 3, 25, 0, 7, 18, 1, 30, 9, 21} {
+		tuples = append(tuples, tuple.NewTupleKey("document:"+strconv.Itoa(i), "viewer", "user:anne"))
+		tuples = append(tuples, tuple.NewTupleKey("document:"+strconv.Itoa(i+100), "viewer", "group:eng#member"))
+	}
+	tuples = append(tuples, tuple.NewTupleKey("group:eng", "member", "user:anne"))
+
+	listObjects := func(t *testing.T, engine string) []string {
+		_, ds, _ := util.MustBootstrapDatastore(t, engine)
+		s := MustNewServerWithOpts(
+			WithDatastore(ds),
+			WithListObjectsOrderBy(serverconfig.ListObjectsOrderByObjectID),
+		)
+		t.Cleanup(s.Close)
+
+		createStoreResp, err := s.CreateStore(ctx, &openfgav1.CreateStoreRequest{Name: "list-objects-order"})
+		require.NoError(t, err)
+		storeID := createStoreResp.GetId()
+
+		_, err = s.WriteAuthorizationModel(ctx, &openfgav1.WriteAuthorizationModelRequest{
+			StoreId:         storeID,
+			TypeDefinitions: model.GetTypeDefinitions(),
+			SchemaVersion:   model.GetSchemaVersion(),
+		})
+		require.NoError(t, err)
+
+		_, err = s.Write(ctx, &openfgav1.WriteRequest{
+			StoreId: storeID,
+			Writes:  &openfgav1.WriteRequestWrites{TupleKeys: tuples},
+		})
+		require.NoError(t, err)
+
+		resp, err := s.ListObjects(ctx, &openfgav1.ListObjectsRequest{
+			StoreId:  storeID,
+			Type:     "document",
+			Relation: "viewer",
+			User:     "user:anne",
+		})
+		require.NoError(t, err)
+		return resp.GetObjects()
+	}
+
+	expected := listObjects(t, "memory")
+	require.Len(t, expected, 20)
+	require.IsIncreasing(t, expected)
+
+	for _, engine := range []string{"postgres", "sqlite"} {
+		t.Run(engine, func(t *testing.T) {
+			require.Equal(t, expected, listObjects(t, engine))
+		})
+	}
+}
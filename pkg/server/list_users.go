@@ -11,6 +11,7 @@ import (
 	"go.opentelemetry.io/otel/trace"
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
 
 	openfgav1 "github.com/openfga/api/proto/openfga/v1"
 
@@ -22,6 +23,7 @@ import (
 	"github.com/openfga/openfga/pkg/middleware/validator"
 	"github.com/openfga/openfga/pkg/server/commands/listusers"
 	serverErrors "github.com/openfga/openfga/pkg/server/errors"
+	"github.com/openfga/openfga/pkg/storage"
 	"github.com/openfga/openfga/pkg/telemetry"
 	"github.com/openfga/openfga/pkg/tuple"
 	"github.com/openfga/openfga/pkg/typesystem"
@@ -49,6 +51,10 @@ func (s *Server) ListUsers(
 		}
 	}
 
+	if err := s.validateContextualTuples(req.GetContextualTuples()); err != nil {
+		return nil, err
+	}
+
 	// TODO: This should be apimethod.ListUsers, but is it considered a breaking change to move?
 	const methodName = "listusers"
 
@@ -62,6 +68,7 @@ func (s *Server) ListUsers(
 		return nil, err
 	}
 
+	ctx = storage.ContextWithConsistency(ctx, req.GetConsistency())
 	typesys, err := s.resolveTypesystem(ctx, req.GetStoreId(), req.GetAuthorizationModelId())
 	if err != nil {
 		return nil, err
@@ -72,6 +79,11 @@ func (s *Server) ListUsers(
 		return nil, err
 	}
 
+	if reqContext := s.conditionContext(typesys, req.GetContext()); reqContext != req.GetContext() {
+		req = proto.CloneOf(req)
+		req.Context = reqContext
+	}
+
 	ctx = typesystem.ContextWithTypesystem(ctx, typesys)
 
 	listUsersQuery := listusers.NewListUsersQuery(s.datastore,
@@ -101,7 +113,9 @@ func (s *Server) ListUsers(
 		case errors.Is(err, condition.ErrEvaluationFailed):
 			return nil, serverErrors.ValidationError(err)
 		default:
-			return nil, serverErrors.HandleError("", err)
+			err = serverErrors.HandleError("", err)
+			s.observeDatastoreError(req.GetStoreId(), err)
+			return nil, err
 		}
 	}
 
@@ -140,6 +154,133 @@ func (s *Server) ListUsers(
 	}, nil
 }
 
+// StreamedListUsersServer is the server stream of StreamedListUsers.
+type StreamedListUsersServer interface {
+	Context() context.Context
+	Send(user *openfgav1.User) error
+}
+
+// StreamedListUsers is the streaming variant of ListUsers, which sends the users to the stream as they are found
+// rather than once they all are. It's subject to the same limits as ListUsers.
+func (s *Server) StreamedListUsers(req *openfgav1.ListUsersRequest, srv StreamedListUsersServer) error {
+	start := time.Now()
+
+	ctx := srv.Context()
+	ctx, span := tracer.Start(ctx, apimethod.StreamedListUsers.String(), trace.WithAttributes(
+		attribute.String("store_id", req.GetStoreId()),
+		attribute.String("object", tuple.BuildObject(req.GetObject().GetType(), req.GetObject().GetId())),
+		attribute.String("relation", req.GetRelation()),
+		attribute.String("user_filters", userFiltersToString(req.GetUserFilters())),
+		attribute.String("consistency", req.GetConsistency().String()),
+	))
+	defer span.End()
+
+	if !validator.RequestIsValidatedFromContext(ctx) {
+		if err := req.Validate(); err != nil {
+			return status.Error(codes.InvalidArgument, err.Error())
+		}
+	}
+
+	if err := s.validateContextualTuples(req.GetContextualTuples()); err != nil {
+		return err
+	}
+
+	const methodName = "streamedlistusers"
+
+	ctx = telemetry.ContextWithRPCInfo(ctx, telemetry.RPCInfo{
+		Service: s.serviceName,
+		Method:  methodName,
+	})
+
+	err := s.checkAuthz(ctx, req.GetStoreId(), apimethod.StreamedListUsers)
+	if err != nil {
+		return err
+	}
+
+	ctx = storage.ContextWithConsistency(ctx, req.GetConsistency())
+	typesys, err := s.resolveTypesystem(ctx, req.GetStoreId(), req.GetAuthorizationModelId())
+	if err != nil {
+		return err
+	}
+
+	err = listusers.ValidateListUsersRequest(ctx, req, typesys)
+	if err != nil {
+		return err
+	}
+
+	if reqContext := s.conditionContext(typesys, req.GetContext()); reqContext != req.GetContext() {
+		req = proto.CloneOf(req)
+		req.Context = reqContext
+	}
+
+	ctx = typesystem.ContextWithTypesystem(ctx, typesys)
+
+	listUsersQuery := listusers.NewListUsersQuery(s.datastore,
+		req.GetContextualTuples(),
+		listusers.WithResolveNodeLimit(s.resolveNodeLimit),
+		listusers.WithResolveNodeBreadthLimit(s.resolveNodeBreadthLimit),
+		listusers.WithListUsersQueryLogger(s.logger),
+		listusers.WithListUsersMaxResults(s.listUsersMaxResults),
+		listusers.WithListUsersDeadline(s.listUsersDeadline),
+		listusers.WithListUsersMaxConcurrentReads(s.maxConcurrentReadsForListUsers),
+		listusers.WithDispatchThrottlerConfig(threshold.Config{
+			Throttler:    s.listUsersDispatchThrottler,
+			Enabled:      s.listUsersDispatchThrottlingEnabled,
+			Threshold:    s.listUsersDispatchDefaultThreshold,
+			MaxThreshold: s.listUsersDispatchThrottlingMaxThreshold,
+		}),
+		listusers.WithListUsersDatastoreThrottler(s.listUsersDatastoreThrottleThreshold, s.listUsersDatastoreThrottleDuration),
+	)
+
+	resp, err := listUsersQuery.StreamedListUsers(ctx, req, srv.Send)
+	if err != nil {
+		telemetry.TraceError(span, err)
+
+		switch {
+		case errors.Is(err, graph.ErrResolutionDepthExceeded):
+			return serverErrors.ErrAuthorizationModelResolutionTooComplex
+		case errors.Is(err, condition.ErrEvaluationFailed):
+			return serverErrors.ValidationError(err)
+		default:
+			err = serverErrors.HandleError("", err)
+			s.observeDatastoreError(req.GetStoreId(), err)
+			return err
+		}
+	}
+
+	datastoreQueryCount := float64(resp.Metadata.DatastoreQueryCount)
+
+	grpc_ctxtags.Extract(ctx).Set(datastoreQueryCountHistogramName, datastoreQueryCount)
+	span.SetAttributes(attribute.Float64(datastoreQueryCountHistogramName, datastoreQueryCount))
+	datastoreQueryCountHistogram.WithLabelValues(
+		s.serviceName,
+		methodName,
+	).Observe(datastoreQueryCount)
+
+	dispatchCount := float64(resp.Metadata.DispatchCounter.Load())
+	grpc_ctxtags.Extract(ctx).Set(dispatchCountHistogramName, dispatchCount)
+	span.SetAttributes(attribute.Float64(dispatchCountHistogramName, dispatchCount))
+	dispatchCountHistogram.WithLabelValues(
+		s.serviceName,
+		methodName,
+	).Observe(dispatchCount)
+
+	requestDurationHistogram.WithLabelValues(
+		s.serviceName,
+		methodName,
+		utils.Bucketize(uint(datastoreQueryCount), s.requestDurationByQueryHistogramBuckets),
+		utils.Bucketize(uint(dispatchCount), s.requestDurationByDispatchCountHistogramBuckets),
+		req.GetConsistency().String(),
+	).Observe(float64(time.Since(start).Milliseconds()))
+
+	wasRequestThrottled := resp.GetMetadata().WasThrottled.Load()
+	if wasRequestThrottled {
+		throttledRequestCounter.WithLabelValues(s.serviceName, methodName).Inc()
+	}
+
+	return nil
+}
+
 func userFiltersToString(filter []*openfgav1.UserTypeFilter) string {
 	var s strings.Builder
 	for _, f := range filter {
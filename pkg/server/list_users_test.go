@@ -343,6 +343,104 @@ func TestListUsers_ErrorCases(t *testing.T) {
 	})
 }
 
+// streamedListUsersServer is a StreamedListUsersServer collecting the users sent.
+type streamedListUsersServer struct {
+	ctx   context.Context
+	users []string
+}
+
+func (s *streamedListUsersServer) Context() context.Context {
+	return s.ctx
+}
+
+func (s *streamedListUsersServer) Send(user *openfgav1.User) error {
+	s.users = append(s.users, tuple.UserProtoToString(user))
+	return nil
+}
+
+func TestStreamedListUsers(t *testing.T) {
+	t.Cleanup(func() {
+		goleak.VerifyNone(t)
+	})
+
+	ctx := context.Background()
+	store := ulid.Make().String()
+
+	s := MustNewServerWithOpts(
+		WithDatastore(memory.New()),
+	)
+	t.Cleanup(s.Close)
+
+	writeModelResp, err := s.WriteAuthorizationModel(ctx, &openfgav1.WriteAuthorizationModelRequest{
+		StoreId:       store,
+		SchemaVersion: typesystem.SchemaVersion1_1,
+		TypeDefinitions: parser.MustTransformDSLToProto(`
+			model
+				schema 1.1
+			type user
+
+			type group
+				relations
+					define member: [user, user:*]
+
+			type document
+				relations
+					define viewer: [user, group#member]`).GetTypeDefinitions(),
+	})
+	require.NoError(t, err)
+
+	_, err = s.Write(ctx, &openfgav1.WriteRequest{
+		StoreId: store,
+		Writes: &openfgav1.WriteRequestWrites{
+			TupleKeys: []*openfgav1.TupleKey{
+				tuple.NewTupleKey("document:1", "viewer", "user:anne"),
+				tuple.NewTupleKey("document:1", "viewer", "group:1#member"),
+				tuple.NewTupleKey("group:1", "member", "user:anne"),
+				tuple.NewTupleKey("group:1", "member", "user:*"),
+			},
+		},
+	})
+	require.NoError(t, err)
+
+	newRequest := func() *openfgav1.ListUsersRequest {
+		return &openfgav1.ListUsersRequest{
+			StoreId:              store,
+			AuthorizationModelId: writeModelResp.GetAuthorizationModelId(),
+			Relation:             "viewer",
+			Object: &openfgav1.Object{
+				Type: "document",
+				Id:   "1",
+			},
+			UserFilters: []*openfgav1.UserTypeFilter{{Type: "user"}},
+		}
+	}
+
+	t.Run("sends_the_users_of_list_users", func(t *testing.T) {
+		res, err := s.ListUsers(ctx, newRequest())
+		require.NoError(t, err)
+
+		expected := make([]string, 0, len(res.GetUsers()))
+		for _, user := range res.GetUsers() {
+			expected = append(expected, tuple.UserProtoToString(user))
+		}
+		require.ElementsMatch(t, []string{"user:anne", "user:*"}, expected)
+
+		srv := &streamedListUsersServer{ctx: ctx}
+		require.NoError(t, s.StreamedListUsers(newRequest(), srv))
+		require.ElementsMatch(t, expected, srv.users)
+	})
+
+	t.Run("invalid_relation", func(t *testing.T) {
+		req := newRequest()
+		req.Relation = "editor"
+
+		srv := &streamedListUsersServer{ctx: ctx}
+		err := s.StreamedListUsers(req, srv)
+		require.ErrorContains(t, err, "relation 'document#editor' not found")
+		require.Empty(t, srv.users)
+	})
+}
+
 func TestListUsers_Deadline(t *testing.T) {
 	t.Cleanup(func() {
 		goleak.VerifyNone(t)
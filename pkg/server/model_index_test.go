@@ -0,0 +1,128 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"go.uber.org/goleak"
+
+	openfgav1 "github.com/openfga/api/proto/openfga/v1"
+	parser "github.com/openfga/language/pkg/go/transformer"
+
+	"github.com/openfga/openfga/pkg/storage/memory"
+	"github.com/openfga/openfga/pkg/tuple"
+	"github.com/openfga/openfga/pkg/typesystem"
+)
+
+func TestCheckWithModelIndexes(t *testing.T) {
+	t.Cleanup(func() {
+		goleak.VerifyNone(t)
+	})
+
+	ctx := context.Background()
+
+	// the github sample model
+	model := parser.MustTransformDSLToProto(`
+		model
+			schema 1.1
+		type user
+		type team
+			relations
+				define member: [user, team#member]
+		type organization
+			relations
+				define owner: [user]
+				define member: [user] or owner
+				define repo_admin: [user, organization#member]
+				define repo_reader: [user, organization#member]
+				define repo_writer: [user, organization#member]
+		type repo
+			relations
+				define owner: [organization]
+				define admin: [user, team#member] or repo_admin from owner
+				define maintainer: [user, team#member] or admin
+				define writer: [user, team#member] or maintainer or repo_writer from owner
+				define triager: [user, team#member] or writer
+				define reader: [user, team#member] or triager or repo_reader from owner`)
+
+	ds := memory.New()
+	t.Cleanup(ds.Close)
+
+	s := MustNewServerWithOpts(WithDatastore(ds))
+	t.Cleanup(s.Close)
+
+	createStoreResp, err := s.CreateStore(ctx, &openfgav1.CreateStoreRequest{Name: "github"})
+	require.NoError(t, err)
+	storeID := createStoreResp.GetId()
+
+	writeModelResp, err := s.WriteAuthorizationModel(ctx, &openfgav1.WriteAuthorizationModelRequest{
+		StoreId:         storeID,
+		TypeDefinitions: model.GetTypeDefinitions(),
+		SchemaVersion:   model.GetSchemaVersion(),
+	})
+	require.NoError(t, err)
+	modelID := writeModelResp.GetAuthorizationModelId()
+
+	_, err = s.Write(ctx, &openfgav1.WriteRequest{
+		StoreId: storeID,
+		Writes: &openfgav1.WriteRequestWrites{TupleKeys: []*openfgav1.TupleKey{
+			tuple.NewTupleKey("organization:openfga", "owner", "user:anne"),
+			tuple.NewTupleKey("organization:openfga", "repo_reader", "organization:openfga#member"),
+			tuple.NewTupleKey("organization:openfga", "member", "user:beth"),
+			tuple.NewTupleKey("repo:openfga/openfga", "owner", "organization:openfga"),
+			tuple.NewTupleKey("team:core", "member", "user:charles"),
+			tuple.NewTupleKey("repo:openfga/openfga", "writer", "team:core#member"),
+		}},
+	})
+	require.NoError(t, err)
+
+	// the index is built offline from the model as it is stored
+	storedModel, err := ds.ReadAuthorizationModel(ctx, storeID, modelID)
+	require.NoError(t, err)
+	idx, err := typesystem.BuildIndex(storedModel)
+	require.NoError(t, err)
+	data, err := idx.Marshal()
+	require.NoError(t, err)
+	loaded, err := typesystem.UnmarshalIndex(data)
+	require.NoError(t, err)
+
+	check := func(t *testing.T, s *Server, object, relation, user string) bool {
+		resp, err := s.Check(ctx, &openfgav1.CheckRequest{
+			StoreId:              storeID,
+			AuthorizationModelId: modelID,
+			TupleKey:             tuple.NewCheckRequestTupleKey(object, relation, user),
+		})
+		require.NoError(t, err)
+		return resp.GetAllowed()
+	}
+
+	t.Run("matches_the_checks_without_index", func(t *testing.T) {
+		indexed := MustNewServerWithOpts(WithDatastore(ds), WithModelIndexes(loaded))
+		t.Cleanup(indexed.Close)
+
+		users := []string{"user:anne", "user:beth", "user:charles", "user:dan", "team:core#member", "organization:openfga#member"}
+		relations := []string{"admin", "maintainer", "writer", "triager", "reader"}
+		for _, user := range users {
+			for _, relation := range relations {
+				expected := check(t, s, "repo:openfga/openfga", relation, user)
+				require.Equal(t, expected, check(t, indexed, "repo:openfga/openfga", relation, user), "%s %s", user, relation)
+			}
+		}
+	})
+
+	t.Run("consults_the_index", func(t *testing.T) {
+		// an index according to which no user can be related to anything
+		unreachable, err := typesystem.UnmarshalIndex([]byte(fmt.Sprintf(
+			`{"version":"1","authorization_model_id":"%s","reachability":{"user organization#owner":false}}`, modelID,
+		)))
+		require.NoError(t, err)
+
+		indexed := MustNewServerWithOpts(WithDatastore(ds), WithModelIndexes(unreachable))
+		t.Cleanup(indexed.Close)
+
+		require.True(t, check(t, s, "organization:openfga", "owner", "user:anne"))
+		require.False(t, check(t, indexed, "organization:openfga", "owner", "user:anne"))
+	})
+}
@@ -48,11 +48,13 @@ func (s *Server) Read(ctx context.Context, req *openfgav1.ReadRequest) (*openfga
 		commands.WithReadQueryEncoder(s.encoder),
 		commands.WithReadQueryTokenSerializer(s.tokenSerializer),
 	)
-	return q.Execute(ctx, &openfgav1.ReadRequest{
+	resp, err := q.Execute(ctx, &openfgav1.ReadRequest{
 		StoreId:           req.GetStoreId(),
 		TupleKey:          tk,
 		PageSize:          req.GetPageSize(),
 		ContinuationToken: req.GetContinuationToken(),
 		Consistency:       req.GetConsistency(),
 	})
+	s.observeDatastoreError(req.GetStoreId(), err)
+	return resp, err
 }
@@ -2,6 +2,7 @@ package server
 
 import (
 	"context"
+	"time"
 
 	"go.opentelemetry.io/otel/attribute"
 	"go.opentelemetry.io/otel/trace"
@@ -13,6 +14,7 @@ import (
 	"github.com/openfga/openfga/internal/utils/apimethod"
 	"github.com/openfga/openfga/pkg/middleware/validator"
 	"github.com/openfga/openfga/pkg/server/commands"
+	serverErrors "github.com/openfga/openfga/pkg/server/errors"
 	"github.com/openfga/openfga/pkg/telemetry"
 )
 
@@ -45,5 +47,64 @@ func (s *Server) ReadChanges(ctx context.Context, req *openfgav1.ReadChangesRequ
 		commands.WithContinuationTokenSerializer(s.tokenSerializer),
 		commands.WithReadChangeQueryHorizonOffset(s.changelogHorizonOffset),
 	)
-	return q.Execute(ctx, req)
+	resp, err := q.Execute(ctx, req)
+	s.observeDatastoreError(req.GetStoreId(), err)
+	return resp, err
+}
+
+// CompactChangeLog removes the changelog entries of a store that are older than olderThan. The
+// current tuples of the store are not affected. ReadChanges requests that resume from a point
+// before the compaction horizon fail with serverErrors.ErrChangelogCompacted. Since compaction
+// irreversibly discards history, it requires the same authorization as deleting the store.
+func (s *Server) CompactChangeLog(ctx context.Context, storeID string, olderThan time.Time) error {
+	ctx, span := tracer.Start(ctx, "CompactChangeLog", trace.WithAttributes(
+		attribute.String("store_id", storeID),
+		attribute.String("older_than", olderThan.UTC().Format(time.RFC3339Nano)),
+	))
+	defer span.End()
+
+	ctx = telemetry.ContextWithRPCInfo(ctx, telemetry.RPCInfo{
+		Service: s.serviceName,
+		Method:  apimethod.DeleteStore.String(),
+	})
+
+	err := s.checkAuthz(ctx, storeID, apimethod.DeleteStore)
+	if err != nil {
+		return err
+	}
+
+	err = s.datastore.CompactChangeLog(ctx, storeID, olderThan)
+	if err != nil {
+		return serverErrors.HandleError("", err)
+	}
+
+	return nil
+}
+
+// StreamTupleDiff streams the net changes of the tuples of a store within a window of its changelog,
+// see [commands.TupleDiffQuery]. It requires the same authorization as ReadChanges.
+func (s *Server) StreamTupleDiff(ctx context.Context, req *commands.TupleDiffRequest, send func(*commands.TupleDiff) error) error {
+	ctx, span := tracer.Start(ctx, "StreamTupleDiff", trace.WithAttributes(
+		attribute.String("store_id", req.StoreID),
+		attribute.KeyValue{Key: "type", Value: attribute.StringValue(req.Type)},
+	))
+	defer span.End()
+
+	ctx = telemetry.ContextWithRPCInfo(ctx, telemetry.RPCInfo{
+		Service: s.serviceName,
+		Method:  apimethod.ReadChanges.String(),
+	})
+
+	err := s.checkAuthz(ctx, req.StoreID, apimethod.ReadChanges)
+	if err != nil {
+		return err
+	}
+
+	q := commands.NewTupleDiffQuery(s.datastore,
+		commands.WithTupleDiffQueryLogger(s.logger),
+		commands.WithTupleDiffQueryEncoder(s.encoder),
+		commands.WithTupleDiffQueryTokenSerializer(s.tokenSerializer),
+		commands.WithTupleDiffQueryHorizonOffset(s.changelogHorizonOffset),
+	)
+	return q.Execute(ctx, req, send)
 }
@@ -5,8 +5,10 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"maps"
 	"slices"
 	"sort"
+	"sync"
 	"time"
 
 	grpc_ctxtags "github.com/grpc-ecosystem/go-grpc-middleware/tags"
@@ -23,6 +25,7 @@ import (
 
 	"github.com/openfga/openfga/internal/authz"
 	"github.com/openfga/openfga/internal/build"
+	"github.com/openfga/openfga/internal/clock"
 	"github.com/openfga/openfga/internal/graph"
 	"github.com/openfga/openfga/internal/planner"
 	"github.com/openfga/openfga/internal/shared"
@@ -30,6 +33,7 @@ import (
 	"github.com/openfga/openfga/internal/utils"
 	"github.com/openfga/openfga/internal/utils/apimethod"
 	"github.com/openfga/openfga/pkg/authclaims"
+	"github.com/openfga/openfga/pkg/decisionlog"
 	"github.com/openfga/openfga/pkg/encoder"
 	"github.com/openfga/openfga/pkg/gateway"
 	"github.com/openfga/openfga/pkg/logger"
@@ -38,6 +42,7 @@ import (
 	"github.com/openfga/openfga/pkg/storage"
 	"github.com/openfga/openfga/pkg/storage/storagewrappers"
 	"github.com/openfga/openfga/pkg/telemetry"
+	"github.com/openfga/openfga/pkg/tuple"
 	"github.com/openfga/openfga/pkg/typesystem"
 )
 
@@ -47,6 +52,20 @@ const (
 	AuthorizationModelIDHeader = "Openfga-Authorization-Model-Id"
 	authorizationModelIDKey    = "authorization_model_id"
 
+	// ObjectExistsHeader is set on the denied Checks of registered objects, see WithCheckObjectRegistry.
+	ObjectExistsHeader = "Openfga-Object-Exists"
+
+	// DatastoreQueryCountHeader is set on Check responses to the number of datastore queries that resolving
+	// the Check performed, which is 0 if it was served from the cache.
+	DatastoreQueryCountHeader = "Openfga-Datastore-Query-Count"
+
+	// ListObjectsTruncatedHeader is set on ListObjects responses, and on the trailer of StreamedListObjects,
+	// whose objects may be incomplete, to the reason they were cut off: 'max_results', 'deadline' or 'interrupted'.
+	ListObjectsTruncatedHeader = "Openfga-List-Objects-Truncated"
+
+	// MaxRelationAliasesPerStore is the maximum number of relation aliases of a store, see WithRelationAliases.
+	MaxRelationAliasesPerStore = 1000
+
 	ExperimentalCheckOptimizations       ExperimentalFeatureFlag = "enable-check-optimizations"
 	ExperimentalListObjectsOptimizations ExperimentalFeatureFlag = "enable-list-objects-optimizations"
 	ExperimentalAccessControlParams      ExperimentalFeatureFlag = "enable-access-control"
@@ -100,6 +119,46 @@ var (
 
 	listObjectsCheckCountName = "check_count"
 
+	listObjectsObjectsScannedHistogram = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace:                       build.ProjectName,
+		Name:                            "list_objects_objects_scanned",
+		Help:                            "The number of candidate objects evaluated by a ListObjects request, labeled by object type and relation. Compared to the objects returned, it shows how selective the reads of the candidate objects are.",
+		Buckets:                         []float64{1, 10, 50, 100, 250, 500, 1000, 2500, 5000, 10000},
+		NativeHistogramBucketFactor:     1.1,
+		NativeHistogramMaxBucketNumber:  100,
+		NativeHistogramMinResetDuration: time.Hour,
+	}, []string{"grpc_method", "object_type", "relation"})
+
+	listObjectsObjectsReturnedHistogram = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace:                       build.ProjectName,
+		Name:                            "list_objects_objects_returned",
+		Help:                            "The number of objects returned by a ListObjects request, labeled by object type and relation.",
+		Buckets:                         []float64{1, 10, 50, 100, 250, 500, 1000, 2500, 5000, 10000},
+		NativeHistogramBucketFactor:     1.1,
+		NativeHistogramMaxBucketNumber:  100,
+		NativeHistogramMinResetDuration: time.Hour,
+	}, []string{"grpc_method", "object_type", "relation"})
+
+	listObjectsChecksIssuedHistogram = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace:                       build.ProjectName,
+		Name:                            "list_objects_checks_issued",
+		Help:                            "The number of Checks issued to evaluate the candidate objects of a ListObjects request, labeled by object type and relation.",
+		Buckets:                         []float64{1, 10, 50, 100, 250, 500, 1000, 2500, 5000, 10000},
+		NativeHistogramBucketFactor:     1.1,
+		NativeHistogramMaxBucketNumber:  100,
+		NativeHistogramMinResetDuration: time.Hour,
+	}, []string{"grpc_method", "object_type", "relation"})
+
+	listObjectsDurationHistogram = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace:                       build.ProjectName,
+		Name:                            "list_objects_duration_ms",
+		Help:                            "The duration (in ms) of a ListObjects request, labeled by object type and relation.",
+		Buckets:                         []float64{1, 5, 10, 25, 50, 80, 100, 150, 200, 300, 1000, 2000, 5000},
+		NativeHistogramBucketFactor:     1.1,
+		NativeHistogramMaxBucketNumber:  100,
+		NativeHistogramMinResetDuration: time.Hour,
+	}, []string{"grpc_method", "object_type", "relation"})
+
 	throttledRequestCounter = promauto.NewCounterVec(prometheus.CounterOpts{
 		Namespace: build.ProjectName,
 		Name:      "throttled_requests_count",
@@ -113,6 +172,13 @@ var (
 		Help:      "The total number of check requests by response result",
 	}, []string{allowedLabel})
 
+	checkRelationCounterName = "check_relation_count"
+	checkRelationCounter     = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: build.ProjectName,
+		Name:      checkRelationCounterName,
+		Help:      "The total number of check requests by object type and relation of the requested tuple key.",
+	}, []string{"object_type", "relation"})
+
 	accessControlStoreCheckDurationHistogramName = "access_control_store_check_request_duration_ms"
 
 	accessControlStoreCheckDurationHistogram = promauto.NewHistogramVec(prometheus.HistogramOpts{
@@ -159,19 +225,64 @@ type Server struct {
 	encoder                          encoder.Encoder
 	transport                        gateway.Transport
 	resolveNodeLimit                 uint32
+	resolveDepthLimit                uint32
+	checkUnionBranchTimeout          time.Duration
+	checkMinRemainingDeadline        time.Duration
+	checkEarlyDeny                   bool
+	checkRewriteLatencyMetrics       bool
+	checkTraceHighCardinality        bool
+	checkQueryCache                  storage.CheckCache
+	checkUsersetOracles              []graph.LocalCheckerOption
+	checkPrimaryReadRelations        map[string][]string
+	checkObjectRegistry              ObjectRegistry
 	resolveNodeBreadthLimit          uint32
+	resolutionStrategy               serverconfig.ResolutionStrategy
+	checkConcurrencyBudget           uint32
+	checkPerRequestConcurrencyLimit  uint32
+	duplicateWriteBehavior           serverconfig.DuplicateWriteBehavior
+	maxUsersetFanout                 uint32
+	usersetFanoutBehavior            serverconfig.UsersetFanoutBehavior
 	changelogHorizonOffset           int
 	listObjectsDeadline              time.Duration
 	listObjectsMaxResults            uint32
+	streamedListObjectsMaxResults    uint32
+	listObjectsOrderBy               serverconfig.ListObjectsOrder
+	countObjectsMaxCount             uint32
+	datastoreErrorMetricMaxStores    int
+	checkSessionMaxEntries           int
+	maxCheckSessions                 int
+	maxCheckSessionsPerStore         int
+	checkSessionTTL                  time.Duration
+	checkSessionClock                clock.Clock
+	checkCoalescer                   *checkCoalescer
+	decisionLogger                   DecisionLogger
+	decisionLogPath                  bool
+	checkSessionsMu                  sync.Mutex
+	checkSessions                    map[string]*checkSession
+	checkSessionsPerStore            map[string]int
+	wildcardPolicies                 map[string]tuple.WildcardPolicy
+	relationAliases                  map[string]map[string]string
+	modelIndexes                     map[string]*typesystem.Index
+	storeMetadataCache               *storage.InMemoryLRUCache[*storage.StoreMetadata]
+	changelogCompactionInterval      time.Duration
+	changelogRetention               time.Duration
+	changelogCompactor               *changelogCompactor
+	datastoreErrorStoreLabeler       *storeLabeler
 	listUsersDeadline                time.Duration
 	listUsersMaxResults              uint32
 	maxChecksPerBatchCheck           uint32
+	maxChecksPerStreamedBatchCheck   uint32
+	maxContextualTuples              uint32
+	conditionCurrentTimeGranularity  time.Duration
+	conditionClock                   clock.Clock
 	maxConcurrentChecksPerBatch      uint32
 	maxConcurrentReadsForListObjects uint32
 	maxConcurrentReadsForCheck       uint32
 	maxConcurrentReadsForListUsers   uint32
 	maxAuthorizationModelCacheSize   int
+	modelCache                       storage.ModelCache
 	maxAuthorizationModelSizeInBytes int
+	maxRelationBranches              int
 	experimentals                    []ExperimentalFeatureFlag
 	AccessControl                    serverconfig.AccessControlConfig
 	AuthnMethod                      string
@@ -192,6 +303,10 @@ type Server struct {
 	listObjectsCheckResolver       graph.CheckResolver
 	listObjectsCheckResolverCloser func()
 
+	// cachedCheckResolvers are the CachedCheckResolvers of checkResolver and listObjectsCheckResolver, whose
+	// cached responses are invalidated by Write and DeleteStore.
+	cachedCheckResolvers []*graph.CachedCheckResolver
+
 	shadowCheckResolverEnabled          bool
 	shadowCheckResolverSamplePercentage int
 	shadowCheckResolverTimeout          time.Duration
@@ -238,12 +353,22 @@ type Server struct {
 	ctx                           context.Context
 	contextPropagationToDatastore bool
 
+	datastoreCircuitBreakerEnabled bool
+	datastoreCircuitBreakerPolicy  storagewrappers.CircuitBreakerPolicy
+	datastoreCircuitBreakerName    string
+
+	datastoreRetriesEnabled bool
+	datastoreRetryPolicy    storagewrappers.RetryPolicy
+
 	// singleflightGroup can be shared across caches, deduplicators, etc.
 	singleflightGroup *singleflight.Group
 
 	planner *planner.Planner
 
 	requestTimeout time.Duration
+
+	drainer   *requestDrainer
+	closeOnce sync.Once
 }
 
 type OpenFGAServiceV1Option func(s *Server)
@@ -276,6 +401,16 @@ func WithAuthorizationModelCacheSize(maxAuthorizationModelCacheSize int) OpenFGA
 	}
 }
 
+// WithModelCache sets the cache of the authorization models, instead of an in-memory cache of the size set
+// by WithAuthorizationModelCacheSize. Models are immutable once written, so one cache, e.g. created with
+// storage.NewInMemoryModelCache, can be shared by the servers of a process. The cache is not stopped when
+// the server is closed.
+func WithModelCache(cache storage.ModelCache) OpenFGAServiceV1Option {
+	return func(s *Server) {
+		s.modelCache = cache
+	}
+}
+
 func WithLogger(l logger.Logger) OpenFGAServiceV1Option {
 	return func(s *Server) {
 		s.logger = l
@@ -304,6 +439,111 @@ func WithResolveNodeLimit(limit uint32) OpenFGAServiceV1Option {
 	}
 }
 
+// WithResolveDepthLimit sets a limit on the number of rewrites that one Check will expand in any one path,
+// including the rewrites resolved without a recursive call, such as a chain of computed relations.
+// A limit of 0 doesn't bound the rewrites.
+func WithResolveDepthLimit(limit uint32) OpenFGAServiceV1Option {
+	return func(s *Server) {
+		s.resolveDepthLimit = limit
+	}
+}
+
+// WithCheckUnionBranchTimeout bounds the time a Check spends resolving each branch of a union relation.
+// A branch that doesn't resolve in time is abandoned while the other branches continue. 0 means no bound.
+func WithCheckUnionBranchTimeout(timeout time.Duration) OpenFGAServiceV1Option {
+	return func(s *Server) {
+		s.checkUnionBranchTimeout = timeout
+	}
+}
+
+// WithCheckMinRemainingDeadline fails a Check with a deadline exceeded error instead of resolving a subproblem
+// when less than the given duration remains before the deadline of the request. 0 means no minimum.
+// See graph.WithMinRemainingDeadline.
+func WithCheckMinRemainingDeadline(d time.Duration) OpenFGAServiceV1Option {
+	return func(s *Server) {
+		s.checkMinRemainingDeadline = d
+	}
+}
+
+// WithCheckRewriteLatencyMetrics records the time that Checks spend evaluating each rewrite operation in a
+// histogram. See graph.WithRewriteLatencyMetrics.
+func WithCheckRewriteLatencyMetrics(enabled bool) OpenFGAServiceV1Option {
+	return func(s *Server) {
+		s.checkRewriteLatencyMetrics = enabled
+	}
+}
+
+// WithCheckTraceHighCardinalityAttributes adds the tuple keys of the Check subproblems, i.e. their objects and
+// users, to the spans of the check resolvers, e.g. for debugging. By default the spans only describe the
+// subproblems by store, model, object type and relation. See graph.WithHighCardinalityTraceAttributes.
+func WithCheckTraceHighCardinalityAttributes(enabled bool) OpenFGAServiceV1Option {
+	return func(s *Server) {
+		s.checkTraceHighCardinality = enabled
+	}
+}
+
+// WithModelIndexes loads the precomputed indexes of authorization models, see typesystem.BuildIndex. The Checks
+// against the model with the ID of an index look up whether a user can be related to a relation in the index,
+// rather than analyze the model the first time each pair is checked. The models without an index are analyzed. The indexes must be built from the
+// models as they are stored, an index of a model that differs in content from the stored model with its ID
+// yields wrong results.
+func WithModelIndexes(indexes ...*typesystem.Index) OpenFGAServiceV1Option {
+	return func(s *Server) {
+		if s.modelIndexes == nil {
+			s.modelIndexes = make(map[string]*typesystem.Index, len(indexes))
+		}
+		for _, idx := range indexes {
+			s.modelIndexes[idx.AuthorizationModelID()] = idx
+		}
+	}
+}
+
+// WithCheckEarlyDeny resolves the subtracted operand of a difference relation before its base, so that a Check
+// is denied without resolving the base when the subtracted operand is allowed. See graph.WithEarlyDeny.
+func WithCheckEarlyDeny(enabled bool) OpenFGAServiceV1Option {
+	return func(s *Server) {
+		s.checkEarlyDeny = enabled
+	}
+}
+
+// WithCheckUsersetOracle designates an oracle that Check consults to resolve the membership of users in the
+// usersets of 'objectType#relation', instead of reading the tuples of the relation. See graph.WithUsersetOracle.
+func WithCheckUsersetOracle(objectType, relation string, oracle graph.UsersetOracle) OpenFGAServiceV1Option {
+	return func(s *Server) {
+		s.checkUsersetOracles = append(s.checkUsersetOracles, graph.WithUsersetOracle(objectType, relation, oracle))
+	}
+}
+
+// WithCheckPrimaryReadRelations reads the tuples of the 'objectType#relation' pairs of a store, e.g.
+// 'document#viewer', from the primary of the datastore when resolving Check, even if the Check doesn't request
+// HIGHER_CONSISTENCY. The tuples of the other relations are read from the secondary, if one is configured.
+// The Check subproblems that may read these relations are not cached. See graph.WithPrimaryReadRelations.
+func WithCheckPrimaryReadRelations(storeID string, relations ...string) OpenFGAServiceV1Option {
+	return func(s *Server) {
+		if s.checkPrimaryReadRelations == nil {
+			s.checkPrimaryReadRelations = map[string][]string{}
+		}
+		s.checkPrimaryReadRelations[storeID] = append(s.checkPrimaryReadRelations[storeID], relations...)
+	}
+}
+
+// ObjectRegistry tells whether an object has been registered in a system external to OpenFGA, e.g. the
+// database of the documents of an application, regardless of the tuples of the object.
+type ObjectRegistry interface {
+	// ObjectExists returns true if the object, e.g. 'document:1', is registered in the store.
+	ObjectExists(ctx context.Context, storeID, object string) (bool, error)
+}
+
+// WithCheckObjectRegistry distinguishes the denied Checks of objects that exist from the Checks of objects
+// that don't. When a Check is denied, the registry is consulted: the response of a registered object carries
+// the ObjectExistsHeader, and the Check of an unregistered object fails with a not found error.
+// Allowed Checks don't consult the registry.
+func WithCheckObjectRegistry(registry ObjectRegistry) OpenFGAServiceV1Option {
+	return func(s *Server) {
+		s.checkObjectRegistry = registry
+	}
+}
+
 // WithResolveNodeBreadthLimit sets a limit on the number of goroutines that can be created
 // when evaluating a subtree of a Check, ListObjects or ListUsers call.
 // Thinking of a Check request as a tree of evaluations, this option controls,
@@ -317,6 +557,61 @@ func WithResolveNodeBreadthLimit(limit uint32) OpenFGAServiceV1Option {
 	}
 }
 
+// WithResolutionStrategy selects how the subproblems of a Check are scheduled. The default,
+// serverconfig.BreadthFirstResolution, evaluates the nodes on a given level of the tree concurrently
+// (see WithResolveNodeBreadthLimit). serverconfig.DepthFirstResolution fully evaluates a node before
+// it evaluates the next one, which issues fewer concurrent datastore reads at the cost of latency.
+// The strategy doesn't affect the outcome of a Check.
+func WithResolutionStrategy(strategy serverconfig.ResolutionStrategy) OpenFGAServiceV1Option {
+	return func(s *Server) {
+		s.resolutionStrategy = strategy
+	}
+}
+
+// WithCheckConcurrencyBudget bounds the number of subproblems that all the Checks evaluate concurrently, on top
+// of the breadth limit of each node (see WithResolveNodeBreadthLimit). Once the budget is spent, the subproblems
+// are evaluated sequentially until a slot is released. 0, the default, doesn't bound them.
+// See graph.WithConcurrencyBudget.
+func WithCheckConcurrencyBudget(budget uint32) OpenFGAServiceV1Option {
+	return func(s *Server) {
+		s.checkConcurrencyBudget = budget
+	}
+}
+
+// WithCheckPerRequestConcurrencyLimit bounds the number of subproblems that a single Check evaluates
+// concurrently, so that one Check can't spend the whole budget set by WithCheckConcurrencyBudget.
+// 0, the default, doesn't bound them. See graph.WithPerRequestConcurrencyLimit.
+func WithCheckPerRequestConcurrencyLimit(limit uint32) OpenFGAServiceV1Option {
+	return func(s *Server) {
+		s.checkPerRequestConcurrencyLimit = limit
+	}
+}
+
+// WithMaxUsersetFanout caps the number of userset tuples that are expanded for a single object and relation
+// of a query, e.g. the groups a document is shared with. 0 doesn't cap the fan-out. See graph.WithMaxUsersetFanout.
+func WithMaxUsersetFanout(limit uint32) OpenFGAServiceV1Option {
+	return func(s *Server) {
+		s.maxUsersetFanout = limit
+	}
+}
+
+// WithUsersetFanoutBehavior sets what happens when the usersets of an object and relation exceed the max
+// userset fan-out. See graph.WithUsersetFanoutBehavior.
+func WithUsersetFanoutBehavior(behavior serverconfig.UsersetFanoutBehavior) OpenFGAServiceV1Option {
+	return func(s *Server) {
+		s.usersetFanoutBehavior = behavior
+	}
+}
+
+// WithDuplicateWriteBehavior sets how Write handles a tuple that already exists when the request doesn't
+// specify it (see openfgav1.WriteRequestWrites.OnDuplicate). The default, serverconfig.DuplicateWriteError,
+// rejects the Write.
+func WithDuplicateWriteBehavior(behavior serverconfig.DuplicateWriteBehavior) OpenFGAServiceV1Option {
+	return func(s *Server) {
+		s.duplicateWriteBehavior = behavior
+	}
+}
+
 // WithChangelogHorizonOffset sets an offset (in minutes) from the current time.
 // Changes that occur after this offset will not be included in the response of ReadChanges API.
 // If your datastore is eventually consistent or if you have a database with replication delay, we recommend setting this (e.g. 1 minute).
@@ -342,6 +637,137 @@ func WithListObjectsMaxResults(limit uint32) OpenFGAServiceV1Option {
 	}
 }
 
+// WithListObjectsOrderBy sets the order of the objects returned by the ListObjects APIs. With
+// serverconfig.ListObjectsOrderByObjectID, ListObjects sorts the objects it collects, which is cheap, whereas
+// StreamedListObjects can't stream the objects as they are found anymore: it buffers them until every object
+// is found, or until the deadline, and then streams them in order. Its memory then grows with the number of
+// objects, so the objects streamed are also limited by WithListObjectsMaxResults, if it is non-zero, and the
+// first object is only streamed once the evaluation is done. The objects returned when a response is
+// truncated are still the first ones found, so ordering doesn't make truncated responses deterministic.
+func WithListObjectsOrderBy(order serverconfig.ListObjectsOrder) OpenFGAServiceV1Option {
+	return func(s *Server) {
+		s.listObjectsOrderBy = order
+	}
+}
+
+// WithStreamedListObjectsMaxResults affects the StreamedListObjects API only.
+// It sets the maximum number of results that this API will stream, 0 streams every result.
+// A stream that reaches it has the ListObjectsTruncatedHeader trailer set.
+func WithStreamedListObjectsMaxResults(limit uint32) OpenFGAServiceV1Option {
+	return func(s *Server) {
+		s.streamedListObjectsMaxResults = limit
+	}
+}
+
+// WithCountObjectsMaxCount affects the CountObjects API only.
+// It sets the count at which counting stops, in which case the count is reported as capped.
+// A value of 0 counts all objects that are found within the ListObjects deadline.
+func WithCountObjectsMaxCount(limit uint32) OpenFGAServiceV1Option {
+	return func(s *Server) {
+		s.countObjectsMaxCount = limit
+	}
+}
+
+// WithDatastoreErrorMetricMaxStores sets the maximum number of stores whose datastore errors are counted
+// under their own store label. The errors of any other store are counted under the label 'other'.
+func WithDatastoreErrorMetricMaxStores(maxStores int) OpenFGAServiceV1Option {
+	return func(s *Server) {
+		s.datastoreErrorMetricMaxStores = maxStores
+	}
+}
+
+// WithCheckSessionMaxEntries sets the maximum number of subproblem outcomes that a Check session memoizes,
+// see OpenCheckSession.
+func WithCheckSessionMaxEntries(maxEntries int) OpenFGAServiceV1Option {
+	return func(s *Server) {
+		s.checkSessionMaxEntries = maxEntries
+	}
+}
+
+// WithMaxCheckSessions sets the maximum number of Check sessions that can be open at the same time,
+// see OpenCheckSession.
+func WithMaxCheckSessions(maxSessions int) OpenFGAServiceV1Option {
+	return func(s *Server) {
+		s.maxCheckSessions = maxSessions
+	}
+}
+
+// WithMaxCheckSessionsPerStore sets the maximum number of Check sessions that can be open at the same time
+// for a store, so that a store can't take all the sessions of WithMaxCheckSessions, see OpenCheckSession.
+func WithMaxCheckSessionsPerStore(maxSessions int) OpenFGAServiceV1Option {
+	return func(s *Server) {
+		s.maxCheckSessionsPerStore = maxSessions
+	}
+}
+
+// WithCheckSessionTTL sets how long a Check session stays open after it's opened. An expired session is
+// closed as if with CloseCheckSession, see OpenCheckSession.
+func WithCheckSessionTTL(ttl time.Duration) OpenFGAServiceV1Option {
+	return func(s *Server) {
+		s.checkSessionTTL = ttl
+	}
+}
+
+// WithCheckCoalescing merges the identical Check requests that arrive within the window of an identical
+// Check in flight: they are answered with its result instead of being resolved again. Checks are identical
+// if they have the same store, model, tuple, contextual tuples, context and consistency. Unlike the
+// deduplication of subproblems in the Check resolvers, it applies to the whole request, so it also benefits
+// requests with many contextual tuples, which aren't cached. Checks with HIGHER_CONSISTENCY are never
+// coalesced. A window of 0 disables the coalescing.
+func WithCheckCoalescing(window time.Duration) OpenFGAServiceV1Option {
+	return func(s *Server) {
+		s.checkCoalescer = nil
+		if window > 0 {
+			s.checkCoalescer = newCheckCoalescer(window)
+		}
+	}
+}
+
+// DecisionLogger receives the decision of each Check that is resolved, see WithDecisionLogger. It's called
+// synchronously by the Check, so it should return quickly.
+type DecisionLogger func(ctx context.Context, decision *decisionlog.Decision)
+
+// WithDecisionLogger sets the logger that receives the decision of each Check, e.g. to export it to an
+// audit system. If recordPath is true, the decisions also record the path evaluated by the Check, which
+// requires resolving the Check in trace mode: the Check is then neither coalesced nor deduplicated with
+// other Checks, and its resolution is slower.
+func WithDecisionLogger(logger DecisionLogger, recordPath bool) OpenFGAServiceV1Option {
+	return func(s *Server) {
+		s.decisionLogger = logger
+		s.decisionLogPath = recordPath
+	}
+}
+
+// WithWildcardPolicies sets the wildcard policies of the stores, keyed by store ID, that determine whether
+// typed wildcards apply to the anonymous principal, see tuple.WildcardPolicy. Stores without a policy use
+// tuple.WildcardPolicyIncludeAnonymous, under which typed wildcards apply to every user of their type.
+//
+// The policies only apply to the Check APIs: Check, BatchCheck, StreamedBatchCheck, CheckInSession,
+// CheckWithModelDelta and CheckWithTrace. ListObjects, ListUsers and Expand ignore them, so a store that
+// excludes the anonymous principal may still list it as a user of a relation granted to a typed wildcard.
+func WithWildcardPolicies(policies map[string]tuple.WildcardPolicy) OpenFGAServiceV1Option {
+	return func(s *Server) {
+		s.wildcardPolicies = maps.Clone(policies)
+	}
+}
+
+// WithRelationAliases translates the deprecated relations of a store to their current names in Check and
+// ListObjects, e.g. to keep serving the clients that still use the old name of a renamed relation. The aliases
+// are keyed by 'objectType#relation' pairs, e.g. 'document#reader', and map to the current name of the
+// relation, e.g. 'viewer'. Each use of an alias is logged as deprecated. A store has at most
+// MaxRelationAliasesPerStore aliases.
+func WithRelationAliases(storeID string, aliases map[string]string) OpenFGAServiceV1Option {
+	return func(s *Server) {
+		if s.relationAliases == nil {
+			s.relationAliases = map[string]map[string]string{}
+		}
+		if s.relationAliases[storeID] == nil {
+			s.relationAliases[storeID] = map[string]string{}
+		}
+		maps.Copy(s.relationAliases[storeID], aliases)
+	}
+}
+
 // WithListUsersDeadline affect the ListUsers API only.
 // It sets the maximum amount of time that the server will spend gathering results.
 func WithListUsersDeadline(deadline time.Duration) OpenFGAServiceV1Option {
@@ -432,6 +858,23 @@ func WithCheckCacheLimit(limit uint32) OpenFGAServiceV1Option {
 	}
 }
 
+// WithCheckCacheCostBudget bounds the estimated total size in bytes of the entries of the check cache, see
+// storage.CacheEntryCost, instead of their number. Zero, the default, bounds their number by WithCheckCacheLimit.
+func WithCheckCacheCostBudget(budget int64) OpenFGAServiceV1Option {
+	return func(s *Server) {
+		s.cacheSettings.CheckCacheCostBudget = budget
+	}
+}
+
+// WithCheckQueryCache makes the check query cache, if enabled, keep the Check sub-problems in the cache rather
+// than in the in-memory cache of the server, e.g. in a cache backed by Redis and shared by the servers of a
+// deployment, see storage.CheckCache. The cache is not stopped when the server is closed.
+func WithCheckQueryCache(cache storage.CheckCache) OpenFGAServiceV1Option {
+	return func(s *Server) {
+		s.checkQueryCache = cache
+	}
+}
+
 // WithCacheControllerEnabled enables cache invalidation of different cache entities.
 func WithCacheControllerEnabled(enabled bool) OpenFGAServiceV1Option {
 	return func(s *Server) {
@@ -454,6 +897,56 @@ func WithCheckQueryCacheTTL(ttl time.Duration) OpenFGAServiceV1Option {
 	}
 }
 
+// WithCheckQueryCacheMaxStaleness enables serving cached Check results of up to the given age
+// when resolving a Check fails with a datastore error. Requests with HIGHER_CONSISTENCY never use it.
+// Needs WithCheckQueryCacheEnabled set to true.
+func WithCheckQueryCacheMaxStaleness(maxStaleness time.Duration) OpenFGAServiceV1Option {
+	return func(s *Server) {
+		s.cacheSettings.CheckQueryCacheMaxStaleness = maxStaleness
+	}
+}
+
+// WithCheckQueryCacheStaleGrace lets the Check requests with MINIMIZE_LATENCY be served cached results that
+// were invalidated, as long as they were cached less than the grace before the invalidation. The results are
+// then refreshed in the background. Needs WithCheckQueryCacheEnabled set to true.
+func WithCheckQueryCacheStaleGrace(grace time.Duration) OpenFGAServiceV1Option {
+	return func(s *Server) {
+		s.cacheSettings.CheckQueryCacheStaleGrace = grace
+	}
+}
+
+// WithCheckQueryCacheStoreLimits partitions the cached Check results by store, so that the Checks of a busy
+// store can't evict the results of the others: each store holds at most maxEntries results, and once the
+// budget shared by the stores is spent, the results of the stores holding the most are evicted first, down to
+// minEntries. A maxEntries or budget of 0 doesn't bound them. Needs WithCheckQueryCacheEnabled set to true.
+func WithCheckQueryCacheStoreLimits(minEntries, maxEntries, budget uint32) OpenFGAServiceV1Option {
+	return func(s *Server) {
+		s.cacheSettings.CheckQueryCacheStoreMinEntries = minEntries
+		s.cacheSettings.CheckQueryCacheStoreMaxEntries = maxEntries
+		s.cacheSettings.CheckQueryCacheStoreBudget = budget
+	}
+}
+
+// WithCheckQueryCacheUncachedObjectTypes disables the check query cache for the Check subproblems of the
+// given object types, e.g. the types whose tuples change too often for their results to be worth caching.
+// The subproblems of other types whose result depends on them are still cached. See
+// graph.WithUncachedObjectTypes. Needs WithCheckQueryCacheEnabled set to true.
+func WithCheckQueryCacheUncachedObjectTypes(objectTypes ...string) OpenFGAServiceV1Option {
+	return func(s *Server) {
+		s.cacheSettings.CheckQueryCacheUncachedObjectTypes = objectTypes
+	}
+}
+
+// WithCheckQueryCacheUsersetsEnabled caches the memberships of the usersets that Check resolves, e.g.
+// 'group:eng#member@user:bob', as entries of their own, so the Checks of different objects related to the
+// same userset share them. A cached membership is discarded once the cache controller observes a change
+// to the tuples of its userset. Needs WithCheckQueryCacheEnabled set to true.
+func WithCheckQueryCacheUsersetsEnabled(enabled bool) OpenFGAServiceV1Option {
+	return func(s *Server) {
+		s.cacheSettings.CheckQueryCacheUsersetsEnabled = enabled
+	}
+}
+
 // WithCheckIteratorCacheEnabled enables caching of iterators produced within Check for subsequent requests.
 func WithCheckIteratorCacheEnabled(enabled bool) OpenFGAServiceV1Option {
 	return func(s *Server) {
@@ -500,6 +993,32 @@ func WithListObjectsIteratorCacheTTL(ttl time.Duration) OpenFGAServiceV1Option {
 	}
 }
 
+// WithListObjectsQueryCacheEnabled enables caching of the results of ListObjects requests. Cached results
+// are discarded once the cache controller observes a write to the store, so enabling the cache controller
+// (see WithCacheControllerEnabled) is recommended. Requests with HIGHER_CONSISTENCY never use the cache.
+// See also WithCheckCacheLimit, WithListObjectsQueryCacheMaxResults and WithListObjectsQueryCacheTTL.
+func WithListObjectsQueryCacheEnabled(enabled bool) OpenFGAServiceV1Option {
+	return func(s *Server) {
+		s.cacheSettings.ListObjectsQueryCacheEnabled = enabled
+	}
+}
+
+// WithListObjectsQueryCacheMaxResults sets the maximum number of objects in a ListObjects result that is cached.
+// Needs WithListObjectsQueryCacheEnabled set to true.
+func WithListObjectsQueryCacheMaxResults(limit uint32) OpenFGAServiceV1Option {
+	return func(s *Server) {
+		s.cacheSettings.ListObjectsQueryCacheMaxResults = limit
+	}
+}
+
+// WithListObjectsQueryCacheTTL sets the TTL of cached ListObjects results.
+// Needs WithListObjectsQueryCacheEnabled set to true.
+func WithListObjectsQueryCacheTTL(ttl time.Duration) OpenFGAServiceV1Option {
+	return func(s *Server) {
+		s.cacheSettings.ListObjectsQueryCacheTTL = ttl
+	}
+}
+
 // WithRequestDurationByQueryHistogramBuckets sets the buckets used in labelling the requestDurationByQueryAndDispatchHistogram.
 func WithRequestDurationByQueryHistogramBuckets(buckets []uint) OpenFGAServiceV1Option {
 	return func(s *Server) {
@@ -522,6 +1041,15 @@ func WithMaxAuthorizationModelSizeInBytes(size int) OpenFGAServiceV1Option {
 	}
 }
 
+// WithMaxRelationBranches sets the maximum number of branches (the operands of unions, intersections
+// and exclusions) allowed in the definition of a single relation. Models that define a relation with
+// more branches are rejected when written, which bounds the work that a single relation can fan out to.
+func WithMaxRelationBranches(limit int) OpenFGAServiceV1Option {
+	return func(s *Server) {
+		s.maxRelationBranches = limit
+	}
+}
+
 // WithDispatchThrottlingCheckResolverEnabled sets whether dispatch throttling is enabled for Check requests.
 // Enabling this feature will prioritize dispatched requests requiring less than the configured dispatch
 // threshold over requests whose dispatch count exceeds the configured threshold.
@@ -571,6 +1099,37 @@ func WithContextPropagationToDatastore(enable bool) OpenFGAServiceV1Option {
 	}
 }
 
+// WithDatastoreCircuitBreaker guards the reads and the writes of the datastore with circuit breakers that fail
+// them fast with the policy while the datastore is failing, see storagewrappers.CircuitBreakerDatastore. The
+// breakers wrap the datastore below the caches of the server, so that the cache hits aren't counted as
+// successful operations. The retries of WithDatastoreRetries are wrapped by the breakers, so an operation and
+// its retries count as one and a failing operation has exhausted its retries.
+func WithDatastoreCircuitBreaker(enabled bool, policy storagewrappers.CircuitBreakerPolicy) OpenFGAServiceV1Option {
+	return func(s *Server) {
+		s.datastoreCircuitBreakerEnabled = enabled
+		s.datastoreCircuitBreakerPolicy = policy
+	}
+}
+
+// WithDatastoreCircuitBreakerName sets the name of the datastore that labels the state of the breakers of
+// WithDatastoreCircuitBreaker in the datastore_circuit_breaker_state gauge, so that the breakers of the servers
+// of a process are told apart. See storagewrappers.WithCircuitBreakerDatastoreName.
+func WithDatastoreCircuitBreakerName(name string) OpenFGAServiceV1Option {
+	return func(s *Server) {
+		s.datastoreCircuitBreakerName = name
+	}
+}
+
+// WithDatastoreRetries retries the datastore operations that fail with a transient error with the policy, see
+// storagewrappers.RetryingDatastore. The retries wrap the datastore below the circuit breakers of
+// WithDatastoreCircuitBreaker.
+func WithDatastoreRetries(enabled bool, policy storagewrappers.RetryPolicy) OpenFGAServiceV1Option {
+	return func(s *Server) {
+		s.datastoreRetriesEnabled = enabled
+		s.datastoreRetryPolicy = policy
+	}
+}
+
 func WithPlanner(planner *planner.Planner) OpenFGAServiceV1Option {
 	return func(s *Server) {
 		s.planner = planner
@@ -694,6 +1253,22 @@ func WithMaxChecksPerBatchCheck(maxChecks uint32) OpenFGAServiceV1Option {
 	}
 }
 
+// WithMaxChecksPerStreamedBatchCheck defines the maximum number of checks allowed to be sent
+// in a single streamed BatchCheck, see StreamedBatchCheck.
+func WithMaxChecksPerStreamedBatchCheck(maxChecks uint32) OpenFGAServiceV1Option {
+	return func(s *Server) {
+		s.maxChecksPerStreamedBatchCheck = maxChecks
+	}
+}
+
+// WithMaxContextualTuples defines the maximum number of contextual tuples of a Check, of each item
+// of a BatchCheck, or of a ListObjects request. Requests with more are rejected before they are resolved.
+func WithMaxContextualTuples(maxTuples uint32) OpenFGAServiceV1Option {
+	return func(s *Server) {
+		s.maxContextualTuples = maxTuples
+	}
+}
+
 func WithCheckDatabaseThrottle(threshold int, duration time.Duration) OpenFGAServiceV1Option {
 	return func(s *Server) {
 		s.checkDatastoreThrottleThreshold = threshold
@@ -823,17 +1398,38 @@ func NewServerWithOpts(opts ...OpenFGAServiceV1Option) (*Server, error) {
 		transport:                        gateway.NewNoopTransport(),
 		changelogHorizonOffset:           serverconfig.DefaultChangelogHorizonOffset,
 		resolveNodeLimit:                 serverconfig.DefaultResolveNodeLimit,
+		resolveDepthLimit:                serverconfig.DefaultResolveDepthLimit,
 		resolveNodeBreadthLimit:          serverconfig.DefaultResolveNodeBreadthLimit,
+		resolutionStrategy:               serverconfig.DefaultResolutionStrategy,
+		duplicateWriteBehavior:           serverconfig.DefaultDuplicateWriteBehavior,
+		maxUsersetFanout:                 serverconfig.DefaultMaxUsersetFanout,
+		usersetFanoutBehavior:            serverconfig.DefaultUsersetFanoutBehavior,
 		listObjectsDeadline:              serverconfig.DefaultListObjectsDeadline,
 		listObjectsMaxResults:            serverconfig.DefaultListObjectsMaxResults,
+		listObjectsOrderBy:               serverconfig.DefaultListObjectsOrderBy,
+		countObjectsMaxCount:             serverconfig.DefaultCountObjectsMaxCount,
+		datastoreErrorMetricMaxStores:    serverconfig.DefaultDatastoreErrorMetricMaxStores,
+		checkSessionMaxEntries:           serverconfig.DefaultCheckSessionMaxEntries,
+		maxCheckSessions:                 serverconfig.DefaultMaxCheckSessions,
+		maxCheckSessionsPerStore:         serverconfig.DefaultMaxCheckSessionsPerStore,
+		checkSessionTTL:                  serverconfig.DefaultCheckSessionTTL,
+		checkSessionClock:                clock.New(),
+		checkSessions:                    make(map[string]*checkSession),
+		checkSessionsPerStore:            make(map[string]int),
+		drainer:                          newRequestDrainer(),
 		listUsersDeadline:                serverconfig.DefaultListUsersDeadline,
 		listUsersMaxResults:              serverconfig.DefaultListUsersMaxResults,
 		maxChecksPerBatchCheck:           serverconfig.DefaultMaxChecksPerBatchCheck,
+		maxChecksPerStreamedBatchCheck:   serverconfig.DefaultMaxChecksPerStreamedBatchCheck,
+		maxContextualTuples:              serverconfig.DefaultMaxContextualTuples,
+		conditionCurrentTimeGranularity:  serverconfig.DefaultConditionCurrentTimeGranularity,
+		conditionClock:                   clock.New(),
 		maxConcurrentChecksPerBatch:      serverconfig.DefaultMaxConcurrentChecksPerBatchCheck,
 		maxConcurrentReadsForCheck:       serverconfig.DefaultMaxConcurrentReadsForCheck,
 		maxConcurrentReadsForListObjects: serverconfig.DefaultMaxConcurrentReadsForListObjects,
 		maxConcurrentReadsForListUsers:   serverconfig.DefaultMaxConcurrentReadsForListUsers,
 		maxAuthorizationModelSizeInBytes: serverconfig.DefaultMaxAuthorizationModelSizeInBytes,
+		maxRelationBranches:              serverconfig.DefaultMaxRelationBranches,
 		maxAuthorizationModelCacheSize:   serverconfig.DefaultMaxAuthorizationModelCacheSize,
 		experimentals:                    make([]ExperimentalFeatureFlag, 0, 10),
 		AccessControl:                    serverconfig.AccessControlConfig{Enabled: false, StoreID: "", ModelID: ""},
@@ -915,6 +1511,17 @@ func NewServerWithOpts(opts ...OpenFGAServiceV1Option) (*Server, error) {
 		return nil, fmt.Errorf("ListUsers default dispatch throttling threshold must be equal or smaller than max dispatch threshold for ListUsers")
 	}
 
+	for storeID, aliases := range s.relationAliases {
+		if len(aliases) > MaxRelationAliasesPerStore {
+			return nil, fmt.Errorf("store %s has %d relation aliases, more than the maximum of %d", storeID, len(aliases), MaxRelationAliasesPerStore)
+		}
+		for alias := range aliases {
+			if _, relation := tuple.SplitObjectRelation(alias); relation == "" {
+				return nil, fmt.Errorf("relation alias '%s' of store %s must be an 'objectType#relation' pair", alias, storeID)
+			}
+		}
+	}
+
 	err := s.validateAccessControlEnabled()
 	if err != nil {
 		return nil, err
@@ -926,6 +1533,8 @@ func NewServerWithOpts(opts ...OpenFGAServiceV1Option) (*Server, error) {
 
 	// below this point, don't throw errors or we may leak resources in tests
 
+	s.datastoreErrorStoreLabeler = newStoreLabeler(s.datastoreErrorMetricMaxStores)
+
 	checkDispatchThrottlingOptions := []graph.DispatchThrottlingCheckResolverOpt{}
 	if s.checkDispatchThrottlingEnabled {
 		checkDispatchThrottlingOptions = []graph.DispatchThrottlingCheckResolverOpt{
@@ -945,9 +1554,27 @@ func NewServerWithOpts(opts ...OpenFGAServiceV1Option) (*Server, error) {
 		s.datastore = storagewrappers.NewContextWrapper(s.datastore)
 	}
 
-	s.datastore, err = storagewrappers.NewCachedOpenFGADatastore(s.datastore, s.maxAuthorizationModelCacheSize)
-	if err != nil {
-		return nil, err
+	if s.datastoreRetriesEnabled {
+		// above the context wrapper, so that the retries honor the deadline and the cancellation of the caller
+		s.datastore = storagewrappers.NewRetryingDatastore(s.datastore, s.datastoreRetryPolicy)
+	}
+
+	if s.datastoreCircuitBreakerEnabled {
+		// above the context wrapper, so that the operations cancelled by the caller aren't counted as failures
+		var opts []storagewrappers.CircuitBreakerDatastoreOpt
+		if s.datastoreCircuitBreakerName != "" {
+			opts = append(opts, storagewrappers.WithCircuitBreakerDatastoreName(s.datastoreCircuitBreakerName))
+		}
+		s.datastore = storagewrappers.NewCircuitBreakerDatastore(s.datastore, s.datastoreCircuitBreakerPolicy, opts...)
+	}
+
+	if s.modelCache != nil {
+		s.datastore = storagewrappers.NewCachedOpenFGADatastoreWithModelCache(s.datastore, s.modelCache)
+	} else {
+		s.datastore, err = storagewrappers.NewCachedOpenFGADatastore(s.datastore, s.maxAuthorizationModelCacheSize)
+		if err != nil {
+			return nil, err
+		}
 	}
 
 	if s.shadowListObjectsQueryEnabled {
@@ -961,28 +1588,64 @@ func NewServerWithOpts(opts ...OpenFGAServiceV1Option) (*Server, error) {
 
 	var checkCacheOptions []graph.CachedCheckResolverOpt
 	if s.cacheSettings.ShouldCacheCheckQueries() {
+		checkCacheOption := graph.WithExistingCache(s.sharedDatastoreResources.CheckCache)
+		if s.checkQueryCache != nil {
+			checkCacheOption = graph.WithCheckCache(s.checkQueryCache)
+		}
 		checkCacheOptions = append(checkCacheOptions,
-			graph.WithExistingCache(s.sharedDatastoreResources.CheckCache),
+			checkCacheOption,
+			// the cache controller writes its invalidations to the in-memory cache of the server
+			graph.WithInvalidationCache(s.sharedDatastoreResources.CheckCache),
 			graph.WithLogger(s.logger),
 			graph.WithCacheTTL(s.cacheSettings.CheckQueryCacheTTL),
+			graph.WithStaleFallback(s.cacheSettings.CheckQueryCacheMaxStaleness),
+			graph.WithStaleGrace(s.cacheSettings.CheckQueryCacheStaleGrace),
+			graph.WithUsersetInvalidation(s.cacheSettings.ShouldCacheCheckQueryUsersets()),
+			graph.WithCacheHighCardinalityTraceAttributes(s.checkTraceHighCardinality),
+			graph.WithCachePrimaryReadRelations(s.checkPrimaryReadRelations),
+			graph.WithUncachedObjectTypes(s.cacheSettings.CheckQueryCacheUncachedObjectTypes...),
 		)
+		if s.cacheSettings.ShouldPartitionCheckQueryCacheByStore() {
+			checkCacheOptions = append(checkCacheOptions, graph.WithStoreCacheLimits(graph.StoreCacheLimits{
+				MinEntries: int(s.cacheSettings.CheckQueryCacheStoreMinEntries),
+				MaxEntries: int(s.cacheSettings.CheckQueryCacheStoreMaxEntries),
+				Budget:     int(s.cacheSettings.CheckQueryCacheStoreBudget),
+			}))
+		}
 	}
 
 	s.checkResolver, s.checkResolverCloser, err = graph.NewOrderedCheckResolvers([]graph.CheckResolverOrderedBuilderOpt{
-		graph.WithLocalCheckerOpts([]graph.LocalCheckerOption{
+		graph.WithLocalCheckerOpts(append([]graph.LocalCheckerOption{
 			graph.WithResolveNodeBreadthLimit(s.resolveNodeBreadthLimit),
+			graph.WithResolutionStrategy(s.resolutionStrategy),
 			graph.WithOptimizations(s.IsExperimentallyEnabled(ExperimentalCheckOptimizations)),
 			graph.WithMaxResolutionDepth(s.resolveNodeLimit),
+			graph.WithMaxRewriteDepth(s.resolveDepthLimit),
 			graph.WithPlanner(s.planner),
 			graph.WithUpstreamTimeout(s.requestTimeout),
 			graph.WithLocalCheckerLogger(s.logger),
-		}...),
-		graph.WithLocalShadowCheckerOpts([]graph.LocalCheckerOption{
+			graph.WithUnionBranchTimeout(s.checkUnionBranchTimeout),
+			graph.WithMinRemainingDeadline(s.checkMinRemainingDeadline),
+			graph.WithEarlyDeny(s.checkEarlyDeny),
+			graph.WithRewriteLatencyMetrics(s.checkRewriteLatencyMetrics),
+			graph.WithHighCardinalityTraceAttributes(s.checkTraceHighCardinality),
+			graph.WithModelIndexes(s.modelIndexes),
+			graph.WithUsersetCaching(s.cacheSettings.ShouldCacheCheckQueryUsersets()),
+			graph.WithPrimaryReadRelations(s.checkPrimaryReadRelations),
+			graph.WithMaxUsersetFanout(s.maxUsersetFanout),
+			graph.WithUsersetFanoutBehavior(s.usersetFanoutBehavior),
+			graph.WithConcurrencyBudget(int(s.checkConcurrencyBudget)),
+			graph.WithPerRequestConcurrencyLimit(int(s.checkPerRequestConcurrencyLimit)),
+		}, s.checkUsersetOracles...)...),
+		// the shadow checker must consult the same oracles for its results to be comparable
+		graph.WithLocalShadowCheckerOpts(append([]graph.LocalCheckerOption{
 			graph.WithResolveNodeBreadthLimit(s.resolveNodeBreadthLimit),
+			graph.WithResolutionStrategy(s.resolutionStrategy),
 			graph.WithOptimizations(true),
 			graph.WithMaxResolutionDepth(s.resolveNodeLimit),
+			graph.WithMaxRewriteDepth(s.resolveDepthLimit),
 			graph.WithPlanner(s.planner),
-		}...),
+		}, s.checkUsersetOracles...)...),
 		graph.WithShadowResolverEnabled(s.shadowCheckResolverEnabled),
 		graph.WithShadowResolverOpts([]graph.ShadowResolverOpt{
 			graph.ShadowResolverWithLogger(s.logger),
@@ -999,11 +1662,17 @@ func NewServerWithOpts(opts ...OpenFGAServiceV1Option) (*Server, error) {
 	s.listObjectsCheckResolver, s.listObjectsCheckResolverCloser, err = graph.NewOrderedCheckResolvers([]graph.CheckResolverOrderedBuilderOpt{
 		graph.WithLocalCheckerOpts([]graph.LocalCheckerOption{
 			graph.WithResolveNodeBreadthLimit(s.resolveNodeBreadthLimit),
+			graph.WithResolutionStrategy(s.resolutionStrategy),
 			graph.WithOptimizations(s.IsExperimentallyEnabled(ExperimentalCheckOptimizations)),
 			graph.WithMaxResolutionDepth(s.resolveNodeLimit),
+			graph.WithUsersetCaching(s.cacheSettings.ShouldCacheCheckQueryUsersets()),
+			graph.WithModelIndexes(s.modelIndexes),
+			graph.WithMaxUsersetFanout(s.maxUsersetFanout),
+			graph.WithUsersetFanoutBehavior(s.usersetFanoutBehavior),
 		}...),
 		graph.WithLocalShadowCheckerOpts([]graph.LocalCheckerOption{
 			graph.WithResolveNodeBreadthLimit(s.resolveNodeBreadthLimit),
+			graph.WithResolutionStrategy(s.resolutionStrategy),
 			graph.WithOptimizations(true),
 			graph.WithMaxResolutionDepth(s.resolveNodeLimit),
 		}...),
@@ -1021,6 +1690,13 @@ func NewServerWithOpts(opts ...OpenFGAServiceV1Option) (*Server, error) {
 		return nil, err
 	}
 
+	for _, resolver := range []graph.CheckResolver{s.checkResolver, s.listObjectsCheckResolver} {
+		if cachedCheckResolver, ok := resolver.(*graph.CachedCheckResolver); ok {
+			s.sharedDatastoreResources.OnCheckCacheEviction(cachedCheckResolver.OnCacheEviction)
+			s.cachedCheckResolvers = append(s.cachedCheckResolvers, cachedCheckResolver)
+		}
+	}
+
 	if s.listObjectsDispatchThrottlingEnabled {
 		s.listObjectsDispatchThrottler = throttler.NewConstantRateThrottler(s.listObjectsDispatchThrottlingFrequency, "list_objects_dispatch_throttle")
 	}
@@ -1034,6 +1710,13 @@ func NewServerWithOpts(opts ...OpenFGAServiceV1Option) (*Server, error) {
 		return nil, err
 	}
 
+	s.storeMetadataCache, err = storage.NewInMemoryLRUCache[*storage.StoreMetadata]()
+	if err != nil {
+		return nil, err
+	}
+
+	s.startChangelogCompaction()
+
 	if s.IsAccessControlEnabled() {
 		s.authorizer = authz.NewAuthorizer(&authz.Config{StoreID: s.AccessControl.StoreID, ModelID: s.AccessControl.ModelID}, s, s.logger)
 	}
@@ -1043,12 +1726,18 @@ func NewServerWithOpts(opts ...OpenFGAServiceV1Option) (*Server, error) {
 
 // Close releases the server resources.
 func (s *Server) Close() {
+	s.closeOnce.Do(s.close)
+}
+
+func (s *Server) close() {
+	s.stopChangelogCompaction()
 	s.checkResolverCloser()
 	if s.planner != nil {
 		s.planner.StopCleanup()
 	}
 	s.listObjectsCheckResolverCloser()
 	s.typesystemResolverStop()
+	s.storeMetadataCache.Stop()
 
 	if s.listObjectsDispatchThrottler != nil {
 		s.listObjectsDispatchThrottler.Close()
@@ -1057,17 +1746,22 @@ func (s *Server) Close() {
 		s.listUsersDispatchThrottler.Close()
 	}
 
+	s.closeCheckSessions()
 	s.sharedDatastoreResources.Close()
 	s.datastore.Close()
 }
 
-// IsReady reports whether the datastore is ready. Please see the implementation of [[storage.OpenFGADatastore.IsReady]]
-// for your datastore.
+// IsReady reports whether the datastore is ready and the caches of the server are operational. Please see the
+// implementation of [[storage.OpenFGADatastore.IsReady]] for your datastore.
 func (s *Server) IsReady(ctx context.Context) (bool, error) {
-	// for now we only depend on the datastore being ready, but in the future
-	// server readiness may also depend on other criteria in addition to the
-	// datastore being ready.
+	if checkCache := s.sharedDatastoreResources.CheckCache; checkCache != nil {
+		if err := storage.ProbeInMemoryCache[any](checkCache, struct{}{}); err != nil {
+			s.logger.WarnWithContext(ctx, "check cache is not ready", zap.Error(err))
+			return false, nil
+		}
+	}
 
+	// the datastore is wrapped by the cache of the models, which is probed too
 	status, err := s.datastore.IsReady(ctx)
 	if err != nil {
 		return false, err
@@ -1077,7 +1771,10 @@ func (s *Server) IsReady(ctx context.Context) (bool, error) {
 		return true, nil
 	}
 
-	s.logger.WarnWithContext(ctx, "datastore is not ready", zap.Any("status", status.Message))
+	s.logger.WarnWithContext(ctx, "datastore is not ready",
+		zap.Any("status", status.Message),
+		zap.Duration("latency", status.Latency),
+	)
 	return false, nil
 }
 
@@ -1101,6 +1798,7 @@ func (s *Server) resolveTypesystem(ctx context.Context, storeID, modelID string)
 
 		telemetry.TraceError(parentSpan, err)
 		err = serverErrors.HandleError("", err)
+		s.observeDatastoreError(storeID, err)
 		return nil, err
 	}
 
@@ -1147,6 +1845,17 @@ func (s *Server) validateShadowListObjectsQueryEnabled() error {
 	return nil
 }
 
+// validateContextualTuples returns a validation error if a request has more contextual tuples than allowed,
+// see WithMaxContextualTuples.
+func (s *Server) validateContextualTuples(tupleKeys []*openfgav1.TupleKey) error {
+	if s.maxContextualTuples > 0 && len(tupleKeys) > int(s.maxContextualTuples) {
+		return serverErrors.ValidationError(fmt.Errorf(
+			"the request has %d contextual tuples, the maximum allowed is %d", len(tupleKeys), s.maxContextualTuples,
+		))
+	}
+	return nil
+}
+
 // checkAuthz checks the authorization for calling an API method.
 func (s *Server) checkAuthz(ctx context.Context, storeID string, apiMethod apimethod.APIMethod, modules ...string) error {
 	if authclaims.SkipAuthzCheckFromContext(ctx) {
@@ -1220,3 +1929,24 @@ func (s *Server) emitCheckDurationMetric(checkMetadata graph.ResolveCheckRespons
 		caller,
 	).Observe(float64(checkMetadata.Duration.Milliseconds()))
 }
+
+// wildcardPolicy returns the wildcard policy of the store.
+func (s *Server) wildcardPolicy(storeID string) tuple.WildcardPolicy {
+	return s.wildcardPolicies[storeID]
+}
+
+// resolveRelationAlias returns the current name of the relation of the object type if the relation is a
+// deprecated alias in the store, and otherwise the relation itself.
+func (s *Server) resolveRelationAlias(ctx context.Context, storeID, objectType, relation string) string {
+	current, ok := s.relationAliases[storeID][tuple.ToObjectRelationString(objectType, relation)]
+	if !ok {
+		return relation
+	}
+
+	s.logger.WarnWithContext(ctx, "relation is deprecated, use its current name instead",
+		zap.String("store_id", storeID),
+		zap.String("object_type", objectType),
+		zap.String("relation", relation),
+		zap.String("current_relation", current))
+	return current
+}
@@ -10,16 +10,19 @@ import (
 	"runtime"
 	"strconv"
 	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 
 	sq "github.com/Masterminds/squirrel"
 	"github.com/oklog/ulid/v2"
+	"github.com/prometheus/client_golang/prometheus"
 	"github.com/stretchr/testify/require"
 	"go.uber.org/goleak"
 	"go.uber.org/mock/gomock"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
 	"google.golang.org/grpc/status"
 	"google.golang.org/protobuf/types/known/timestamppb"
 
@@ -28,7 +31,6 @@ import (
 
 	"github.com/openfga/openfga/cmd/migrate"
 	"github.com/openfga/openfga/cmd/util"
-	"github.com/openfga/openfga/internal/build"
 	"github.com/openfga/openfga/internal/cachecontroller"
 	"github.com/openfga/openfga/internal/graph"
 	mockstorage "github.com/openfga/openfga/internal/mocks"
@@ -282,14 +284,17 @@ func TestServerPanicIfValidationsFail(t *testing.T) {
 }
 
 func TestServerNotReadyDueToDatastoreRevision(t *testing.T) {
-	// skipping sqlite here because the lowest supported schema revision is 4
-	engines := []string{"postgres", "mysql"}
+	minimumRevisions := map[string]int64{
+		"postgres": postgres.MinimumSupportedSchemaRevision,
+		"mysql":    mysql.MinimumSupportedSchemaRevision,
+		"sqlite":   sqlite.MinimumSupportedSchemaRevision,
+	}
 
-	for _, engine := range engines {
+	for engine, minimumRevision := range minimumRevisions {
 		t.Run(engine, func(t *testing.T) {
 			_, ds, uri := util.MustBootstrapDatastore(t, engine)
 
-			targetVersion := build.MinimumSupportedDatastoreSchemaRevision - 1
+			targetVersion := minimumRevision - 1
 
 			migrateCommand := migrate.NewMigrateCommand()
 
@@ -299,7 +304,7 @@ func TestServerNotReadyDueToDatastoreRevision(t *testing.T) {
 			require.NoError(t, err)
 
 			status, _ := ds.IsReady(context.Background())
-			require.Contains(t, status.Message, fmt.Sprintf("datastore requires migrations: at revision '%d', but requires '%d'.", targetVersion, build.MinimumSupportedDatastoreSchemaRevision))
+			require.Contains(t, status.Message, fmt.Sprintf("datastore requires migrations: at revision '%d', but requires '%d'.", targetVersion, minimumRevision))
 			require.False(t, status.IsReady)
 		})
 	}
@@ -654,6 +659,11 @@ func TestRequestContextPropagation(t *testing.T) {
 
 			mockDatastore := mockstorage.NewMockOpenFGADatastore(mockController)
 
+			mockDatastore.EXPECT().
+				ReadStoreMetadata(gomock.Any(), storeID).
+				AnyTimes().
+				Return(&storage.StoreMetadata{}, nil)
+
 			model := testutils.MustTransformDSLToProtoWithID(`
 			model
 				schema 1.1
@@ -871,6 +881,11 @@ func TestCheckDoesNotThrowBecauseDirectTupleWasFound(t *testing.T) {
 
 	mockDatastore := mockstorage.NewMockOpenFGADatastore(mockController)
 
+	mockDatastore.EXPECT().
+		ReadStoreMetadata(gomock.Any(), storeID).
+		AnyTimes().
+		Return(&storage.StoreMetadata{}, nil)
+
 	mockDatastore.EXPECT().
 		ReadAuthorizationModel(gomock.Any(), storeID, modelID).
 		AnyTimes().
@@ -1058,6 +1073,11 @@ func TestOperationsWithInvalidModel(t *testing.T) {
 
 	mockDatastore := mockstorage.NewMockOpenFGADatastore(mockController)
 
+	mockDatastore.EXPECT().
+		ReadStoreMetadata(gomock.Any(), gomock.Any()).
+		AnyTimes().
+		Return(&storage.StoreMetadata{}, nil)
+
 	mockDatastore.EXPECT().
 		ReadAuthorizationModel(gomock.Any(), storeID, modelID).
 		AnyTimes().
@@ -1162,6 +1182,11 @@ func TestShortestPathToSolutionWins(t *testing.T) {
 
 	mockDatastore := mockstorage.NewMockOpenFGADatastore(mockController)
 
+	mockDatastore.EXPECT().
+		ReadStoreMetadata(gomock.Any(), storeID).
+		AnyTimes().
+		Return(&storage.StoreMetadata{}, nil)
+
 	mockDatastore.EXPECT().
 		ReadAuthorizationModel(gomock.Any(), storeID, modelID).
 		AnyTimes().
@@ -1244,6 +1269,11 @@ func TestCheckWithCachedResolution(t *testing.T) {
 
 	mockDatastore := mockstorage.NewMockOpenFGADatastore(mockController)
 
+	mockDatastore.EXPECT().
+		ReadStoreMetadata(gomock.Any(), storeID).
+		AnyTimes().
+		Return(&storage.StoreMetadata{}, nil)
+
 	mockDatastore.EXPECT().
 		ReadAuthorizationModel(gomock.Any(), storeID, modelID).
 		AnyTimes().
@@ -1289,6 +1319,64 @@ func TestCheckWithCachedResolution(t *testing.T) {
 	require.True(t, checkResponse.GetAllowed())
 }
 
+func TestCheckQueryCacheOptions(t *testing.T) {
+	t.Cleanup(func() {
+		goleak.VerifyNone(t)
+	})
+
+	ctx := context.Background()
+
+	// checkDocs resolves a Check of each doc on a new server and returns the stats of its check query cache
+	checkDocs := func(t *testing.T, opts ...OpenFGAServiceV1Option) (string, graph.CacheStats) {
+		s := MustNewServerWithOpts(append([]OpenFGAServiceV1Option{
+			WithDatastore(memory.New()),
+			WithCheckQueryCacheEnabled(true),
+			WithCheckQueryCacheTTL(time.Hour),
+		}, opts...)...)
+		t.Cleanup(s.Close)
+
+		createStoreResp, err := s.CreateStore(ctx, &openfgav1.CreateStoreRequest{Name: "cache-options"})
+		require.NoError(t, err)
+		storeID := createStoreResp.GetId()
+
+		model := parser.MustTransformDSLToProto(`
+			model
+				schema 1.1
+			type user
+			type doc
+				relations
+					define viewer: [user]`)
+		_, err = s.WriteAuthorizationModel(ctx, &openfgav1.WriteAuthorizationModelRequest{
+			StoreId:         storeID,
+			TypeDefinitions: model.GetTypeDefinitions(),
+			SchemaVersion:   model.GetSchemaVersion(),
+		})
+		require.NoError(t, err)
+
+		for _, object := range []string{"doc:1", "doc:2"} {
+			_, err := s.Check(ctx, &openfgav1.CheckRequest{
+				StoreId:  storeID,
+				TupleKey: tuple.NewCheckRequestTupleKey(object, "viewer", "user:jon"),
+			})
+			require.NoError(t, err)
+		}
+
+		cachedCheckResolver, ok := s.checkResolver.(*graph.CachedCheckResolver)
+		require.True(t, ok)
+		return storeID, cachedCheckResolver.Stats()
+	}
+
+	t.Run("store_limits", func(t *testing.T) {
+		storeID, stats := checkDocs(t, WithCheckQueryCacheStoreLimits(0, 1, 0))
+		require.Equal(t, map[string]int{storeID: 1}, stats.StoreEntries)
+	})
+
+	t.Run("uncached_object_types", func(t *testing.T) {
+		_, stats := checkDocs(t, WithCheckQueryCacheUncachedObjectTypes("doc"))
+		require.Zero(t, stats.Lookups)
+	})
+}
+
 func TestResolveAuthorizationModel(t *testing.T) {
 	t.Cleanup(func() {
 		goleak.VerifyNone(t)
@@ -1374,7 +1462,8 @@ func TestResolveAuthorizationModel(t *testing.T) {
 type mockStreamServer struct {
 	grpc.ServerStream
 
-	ctx context.Context
+	ctx     context.Context
+	trailer metadata.MD
 }
 
 func NewMockStreamServer(ctx context.Context) *mockStreamServer {
@@ -1391,6 +1480,95 @@ func (m *mockStreamServer) Send(*openfgav1.StreamedListObjectsResponse) error {
 	return nil
 }
 
+func (m *mockStreamServer) SetTrailer(md metadata.MD) {
+	m.trailer = metadata.Join(m.trailer, md)
+}
+
+func TestListObjectsTruncatedHeader(t *testing.T) {
+	t.Cleanup(func() {
+		goleak.VerifyNone(t)
+	})
+
+	ctx := context.Background()
+	transport := &headerRecordingTransport{headers: map[string]string{}}
+	s := MustNewServerWithOpts(
+		WithDatastore(memory.New()),
+		WithTransport(transport),
+		WithListObjectsMaxResults(2),
+		WithStreamedListObjectsMaxResults(2),
+	)
+	t.Cleanup(s.Close)
+
+	createStoreResp, err := s.CreateStore(ctx, &openfgav1.CreateStoreRequest{Name: "list-objects-truncated"})
+	require.NoError(t, err)
+	storeID := createStoreResp.GetId()
+
+	model := parser.MustTransformDSLToProto(`
+		model
+			schema 1.1
+		type user
+		type doc
+			relations
+				define viewer: [user]`)
+	writeModelResp, err := s.WriteAuthorizationModel(ctx, &openfgav1.WriteAuthorizationModelRequest{
+		StoreId:         storeID,
+		TypeDefinitions: model.GetTypeDefinitions(),
+		SchemaVersion:   model.GetSchemaVersion(),
+	})
+	require.NoError(t, err)
+	modelID := writeModelResp.GetAuthorizationModelId()
+
+	_, err = s.Write(ctx, &openfgav1.WriteRequest{
+		StoreId: storeID,
+		Writes: &openfgav1.WriteRequestWrites{TupleKeys: []*openfgav1.TupleKey{
+			tuple.NewTupleKey("doc:1", "viewer", "user:anne"),
+			tuple.NewTupleKey("doc:2", "viewer", "user:anne"),
+			tuple.NewTupleKey("doc:3", "viewer", "user:anne"),
+			tuple.NewTupleKey("doc:1", "viewer", "user:bob"),
+		}},
+	})
+	require.NoError(t, err)
+
+	t.Run("list_objects", func(t *testing.T) {
+		listObjects := func(user string) *openfgav1.ListObjectsResponse {
+			transport.headers = map[string]string{}
+			resp, err := s.ListObjects(ctx, &openfgav1.ListObjectsRequest{
+				StoreId:              storeID,
+				AuthorizationModelId: modelID,
+				Type:                 "doc",
+				Relation:             "viewer",
+				User:                 user,
+			})
+			require.NoError(t, err)
+			return resp
+		}
+
+		require.Len(t, listObjects("user:anne").GetObjects(), 2)
+		require.Equal(t, "max_results", transport.headers[ListObjectsTruncatedHeader])
+
+		require.Len(t, listObjects("user:bob").GetObjects(), 1)
+		require.NotContains(t, transport.headers, ListObjectsTruncatedHeader)
+	})
+
+	t.Run("streamed_list_objects", func(t *testing.T) {
+		streamedListObjects := func(user string) metadata.MD {
+			srv := NewMockStreamServer(ctx)
+			err := s.StreamedListObjects(&openfgav1.StreamedListObjectsRequest{
+				StoreId:              storeID,
+				AuthorizationModelId: modelID,
+				Type:                 "doc",
+				Relation:             "viewer",
+				User:                 user,
+			}, srv)
+			require.NoError(t, err)
+			return srv.trailer
+		}
+
+		require.Equal(t, []string{"max_results"}, streamedListObjects("user:anne").Get(ListObjectsTruncatedHeader))
+		require.Empty(t, streamedListObjects("user:bob").Get(ListObjectsTruncatedHeader))
+	})
+}
+
 // This runs ListObjects and StreamedListObjects many times over to ensure no race conditions (see https://github.com/openfga/openfga/pull/762)
 func BenchmarkListObjectsNoRaceCondition(b *testing.B) {
 	b.Cleanup(func() {
@@ -1473,6 +1651,11 @@ func TestListObjects_ErrorCases(t *testing.T) {
 	t.Run("database_errors", func(t *testing.T) {
 		mockDatastore := mockstorage.NewMockOpenFGADatastore(mockController)
 
+		mockDatastore.EXPECT().
+			ReadStoreMetadata(gomock.Any(), gomock.Any()).
+			AnyTimes().
+			Return(&storage.StoreMetadata{}, nil)
+
 		s := MustNewServerWithOpts(
 			WithDatastore(mockDatastore),
 		)
@@ -1610,6 +1793,11 @@ func TestAuthorizationModelInvalidSchemaVersion(t *testing.T) {
 
 	mockDatastore := mockstorage.NewMockOpenFGADatastore(mockController)
 
+	mockDatastore.EXPECT().
+		ReadStoreMetadata(gomock.Any(), gomock.Any()).
+		AnyTimes().
+		Return(&storage.StoreMetadata{}, nil)
+
 	mockDatastore.EXPECT().ReadAuthorizationModel(gomock.Any(), store, modelID).AnyTimes().Return(&openfgav1.AuthorizationModel{
 		SchemaVersion: typesystem.SchemaVersion1_0,
 		TypeDefinitions: []*openfgav1.TypeDefinition{
@@ -2297,6 +2485,11 @@ func TestCheckWithCachedIterator(t *testing.T) {
 
 	mockDatastore := mockstorage.NewMockOpenFGADatastore(mockController)
 
+	mockDatastore.EXPECT().
+		ReadStoreMetadata(gomock.Any(), storeID).
+		AnyTimes().
+		Return(&storage.StoreMetadata{}, nil)
+
 	mockDatastore.EXPECT().
 		ReadAuthorizationModel(gomock.Any(), storeID, modelID).
 		Times(1).
@@ -2414,6 +2607,11 @@ func TestBatchCheckWithCachedIterator(t *testing.T) {
 
 	mockDatastore := mockstorage.NewMockOpenFGADatastore(mockController)
 
+	mockDatastore.EXPECT().
+		ReadStoreMetadata(gomock.Any(), gomock.Any()).
+		AnyTimes().
+		Return(&storage.StoreMetadata{}, nil)
+
 	mockDatastore.EXPECT().
 		ReadAuthorizationModel(gomock.Any(), storeID, modelID).
 		Times(1).
@@ -2523,3 +2721,813 @@ func TestBatchCheckWithCachedIterator(t *testing.T) {
 	require.NoError(t, err)
 	require.True(t, batchCheckResponse.GetResult()[fakeID].GetAllowed())
 }
+
+func TestGetRelationGraph(t *testing.T) {
+	t.Cleanup(func() {
+		goleak.VerifyNone(t)
+	})
+
+	ctx := context.Background()
+	s := MustNewServerWithOpts(WithDatastore(memory.New()))
+	t.Cleanup(s.Close)
+
+	createStoreResp, err := s.CreateStore(ctx, &openfgav1.CreateStoreRequest{Name: "relation-graph"})
+	require.NoError(t, err)
+	storeID := createStoreResp.GetId()
+
+	t.Run("no_model", func(t *testing.T) {
+		_, err := s.GetRelationGraph(ctx, storeID, "")
+		require.ErrorIs(t, err, serverErrors.LatestAuthorizationModelNotFound(storeID))
+	})
+
+	model := parser.MustTransformDSLToProto(`
+		model
+			schema 1.1
+		type user
+		type doc
+			relations
+				define editor: [user]
+				define viewer: [user] or editor`)
+	_, err = s.WriteAuthorizationModel(ctx, &openfgav1.WriteAuthorizationModelRequest{
+		StoreId:         storeID,
+		TypeDefinitions: model.GetTypeDefinitions(),
+		SchemaVersion:   model.GetSchemaVersion(),
+	})
+	require.NoError(t, err)
+
+	graph, err := s.GetRelationGraph(ctx, storeID, "")
+	require.NoError(t, err)
+	require.Equal(t, []typesystem.RelationGraphNode{
+		{Type: "doc", Relation: "editor"},
+		{Type: "doc", Relation: "viewer"},
+	}, graph.Nodes)
+	require.Equal(t, []typesystem.RelationGraphEdge{
+		{
+			From:     typesystem.RelationGraphNode{Type: "doc", Relation: "viewer"},
+			To:       typesystem.RelationGraphNode{Type: "doc", Relation: "editor"},
+			Type:     typesystem.RelationGraphEdgeComputed,
+			Operator: typesystem.RelationGraphOperatorUnion,
+		},
+	}, graph.Edges)
+}
+
+func TestValidateAuthorizationModel(t *testing.T) {
+	t.Cleanup(func() {
+		goleak.VerifyNone(t)
+	})
+
+	ctx := context.Background()
+	s := MustNewServerWithOpts(WithDatastore(memory.New()))
+	t.Cleanup(s.Close)
+
+	createStoreResp, err := s.CreateStore(ctx, &openfgav1.CreateStoreRequest{Name: "validate-model"})
+	require.NoError(t, err)
+	storeID := createStoreResp.GetId()
+
+	newRequest := func(dsl string) *openfgav1.WriteAuthorizationModelRequest {
+		model := parser.MustTransformDSLToProto(dsl)
+		return &openfgav1.WriteAuthorizationModelRequest{
+			StoreId:         storeID,
+			TypeDefinitions: model.GetTypeDefinitions(),
+			SchemaVersion:   model.GetSchemaVersion(),
+		}
+	}
+
+	t.Run("valid_model_is_not_written", func(t *testing.T) {
+		resp, err := s.ValidateAuthorizationModel(ctx, newRequest(`
+			model
+				schema 1.1
+			type user
+			type doc
+				relations
+					define viewer: [user]`))
+		require.NoError(t, err)
+		require.Empty(t, resp.GetAuthorizationModelId())
+
+		models, err := s.ReadAuthorizationModels(ctx, &openfgav1.ReadAuthorizationModelsRequest{StoreId: storeID})
+		require.NoError(t, err)
+		require.Empty(t, models.GetAuthorizationModels())
+	})
+
+	t.Run("invalid_model_fails_like_the_write", func(t *testing.T) {
+		req := newRequest(`
+			model
+				schema 1.1
+			type user
+			type doc
+				relations
+					define editor: viewer
+					define viewer: editor`)
+
+		_, err := s.ValidateAuthorizationModel(ctx, req)
+		require.Error(t, err)
+
+		_, writeErr := s.WriteAuthorizationModel(ctx, req)
+		require.Equal(t, writeErr, err)
+	})
+}
+
+func TestCompactChangeLog(t *testing.T) {
+	t.Cleanup(func() {
+		goleak.VerifyNone(t)
+	})
+
+	ctx := context.Background()
+	s := MustNewServerWithOpts(WithDatastore(memory.New()))
+	t.Cleanup(s.Close)
+
+	createStoreResp, err := s.CreateStore(ctx, &openfgav1.CreateStoreRequest{Name: "compact-changelog"})
+	require.NoError(t, err)
+	storeID := createStoreResp.GetId()
+
+	model := parser.MustTransformDSLToProto(`
+		model
+			schema 1.1
+		type user
+		type doc
+			relations
+				define viewer: [user]`)
+	_, err = s.WriteAuthorizationModel(ctx, &openfgav1.WriteAuthorizationModelRequest{
+		StoreId:         storeID,
+		TypeDefinitions: model.GetTypeDefinitions(),
+		SchemaVersion:   model.GetSchemaVersion(),
+	})
+	require.NoError(t, err)
+
+	_, err = s.Write(ctx, &openfgav1.WriteRequest{
+		StoreId: storeID,
+		Writes: &openfgav1.WriteRequestWrites{
+			TupleKeys: []*openfgav1.TupleKey{tuple.NewTupleKey("doc:1", "viewer", "user:jon")},
+		},
+	})
+	require.NoError(t, err)
+
+	changes, err := s.ReadChanges(ctx, &openfgav1.ReadChangesRequest{StoreId: storeID})
+	require.NoError(t, err)
+	require.Len(t, changes.GetChanges(), 1)
+
+	// ulids have millisecond precision
+	time.Sleep(2 * time.Millisecond)
+	err = s.CompactChangeLog(ctx, storeID, time.Now())
+	require.NoError(t, err)
+
+	changesAfterCompaction, err := s.ReadChanges(ctx, &openfgav1.ReadChangesRequest{StoreId: storeID})
+	require.NoError(t, err)
+	require.Empty(t, changesAfterCompaction.GetChanges())
+
+	_, err = s.ReadChanges(ctx, &openfgav1.ReadChangesRequest{
+		StoreId:           storeID,
+		ContinuationToken: changes.GetContinuationToken(),
+	})
+	require.ErrorIs(t, err, serverErrors.ErrChangelogCompacted)
+
+	checkResp, err := s.Check(ctx, &openfgav1.CheckRequest{
+		StoreId:  storeID,
+		TupleKey: tuple.NewCheckRequestTupleKey("doc:1", "viewer", "user:jon"),
+	})
+	require.NoError(t, err)
+	require.True(t, checkResp.GetAllowed())
+}
+
+// storeMetadataCountingDatastore counts the reads of the metadata of the stores.
+type storeMetadataCountingDatastore struct {
+	storage.OpenFGADatastore
+	metadataReads atomic.Int32
+}
+
+func (d *storeMetadataCountingDatastore) ReadStoreMetadata(ctx context.Context, storeID string) (*storage.StoreMetadata, error) {
+	d.metadataReads.Add(1)
+	return d.OpenFGADatastore.ReadStoreMetadata(ctx, storeID)
+}
+
+func TestWriteWithObjectTypeAllowlist(t *testing.T) {
+	t.Cleanup(func() {
+		goleak.VerifyNone(t)
+	})
+
+	ctx := context.Background()
+	ds := &storeMetadataCountingDatastore{OpenFGADatastore: memory.New()}
+	s := MustNewServerWithOpts(WithDatastore(ds))
+	t.Cleanup(s.Close)
+
+	createStoreResp, err := s.CreateStore(ctx, &openfgav1.CreateStoreRequest{Name: "object-type-allowlist"})
+	require.NoError(t, err)
+	storeID := createStoreResp.GetId()
+
+	model := parser.MustTransformDSLToProto(`
+		model
+			schema 1.1
+		type user
+		type doc
+			relations
+				define viewer: [user]
+		type folder
+			relations
+				define viewer: [user]`)
+	_, err = s.WriteAuthorizationModel(ctx, &openfgav1.WriteAuthorizationModelRequest{
+		StoreId:         storeID,
+		TypeDefinitions: model.GetTypeDefinitions(),
+		SchemaVersion:   model.GetSchemaVersion(),
+	})
+	require.NoError(t, err)
+
+	err = s.WriteStoreMetadata(ctx, storeID, &storage.StoreMetadata{ObjectTypeAllowlist: []string{"doc"}})
+	require.NoError(t, err)
+
+	metadata, err := s.ReadStoreMetadata(ctx, storeID)
+	require.NoError(t, err)
+	require.Equal(t, []string{"doc"}, metadata.ObjectTypeAllowlist)
+
+	_, err = s.Write(ctx, &openfgav1.WriteRequest{
+		StoreId: storeID,
+		Writes: &openfgav1.WriteRequestWrites{
+			TupleKeys: []*openfgav1.TupleKey{tuple.NewTupleKey("doc:1", "viewer", "user:jon")},
+		},
+	})
+	require.NoError(t, err)
+
+	_, err = s.Write(ctx, &openfgav1.WriteRequest{
+		StoreId: storeID,
+		Writes: &openfgav1.WriteRequestWrites{
+			TupleKeys: []*openfgav1.TupleKey{tuple.NewTupleKey("folder:1", "viewer", "user:jon")},
+		},
+	})
+	require.ErrorContains(t, err, "object type 'folder' is not in the object type allowlist of the store")
+
+	// the metadata read by the first Write is cached for the second one
+	require.Equal(t, int32(2), ds.metadataReads.Load())
+
+	err = s.WriteStoreMetadata(ctx, storeID, &storage.StoreMetadata{ObjectTypeAllowlist: []string{"folder:1"}})
+	require.ErrorContains(t, err, "invalid object type 'folder:1'")
+
+	// an empty allowlist allows any object type, and writing the metadata invalidates the cached one
+	err = s.WriteStoreMetadata(ctx, storeID, &storage.StoreMetadata{})
+	require.NoError(t, err)
+
+	_, err = s.Write(ctx, &openfgav1.WriteRequest{
+		StoreId: storeID,
+		Writes: &openfgav1.WriteRequestWrites{
+			TupleKeys: []*openfgav1.TupleKey{tuple.NewTupleKey("folder:1", "viewer", "user:jon")},
+		},
+	})
+	require.NoError(t, err)
+}
+
+func TestCheckWithStoreDefaultConsistency(t *testing.T) {
+	t.Cleanup(func() {
+		goleak.VerifyNone(t)
+	})
+
+	ctx := context.Background()
+	s := MustNewServerWithOpts(
+		WithDatastore(memory.New()),
+		WithCheckQueryCacheEnabled(true),
+		WithCheckQueryCacheTTL(time.Hour),
+	)
+	t.Cleanup(s.Close)
+
+	createStoreResp, err := s.CreateStore(ctx, &openfgav1.CreateStoreRequest{Name: "default-consistency"})
+	require.NoError(t, err)
+	storeID := createStoreResp.GetId()
+
+	model := parser.MustTransformDSLToProto(`
+		model
+			schema 1.1
+		type user
+		type doc
+			relations
+				define viewer: [user]
+				define can_view: viewer`)
+	_, err = s.WriteAuthorizationModel(ctx, &openfgav1.WriteAuthorizationModelRequest{
+		StoreId:         storeID,
+		TypeDefinitions: model.GetTypeDefinitions(),
+		SchemaVersion:   model.GetSchemaVersion(),
+	})
+	require.NoError(t, err)
+
+	tk := tuple.NewTupleKey("doc:1", "viewer", "user:jon")
+	_, err = s.Write(ctx, &openfgav1.WriteRequest{
+		StoreId: storeID,
+		Writes:  &openfgav1.WriteRequestWrites{TupleKeys: []*openfgav1.TupleKey{tk}},
+	})
+	require.NoError(t, err)
+
+	check := func(consistency openfgav1.ConsistencyPreference) bool {
+		resp, err := s.Check(ctx, &openfgav1.CheckRequest{
+			StoreId:     storeID,
+			TupleKey:    tuple.NewCheckRequestTupleKey("doc:1", "can_view", "user:jon"),
+			Consistency: consistency,
+		})
+		require.NoError(t, err)
+		return resp.GetAllowed()
+	}
+
+	// the result is cached, so that it outlives the tuple, whose Write only invalidates the Check of the tuple itself
+	require.True(t, check(openfgav1.ConsistencyPreference_UNSPECIFIED))
+	_, err = s.Write(ctx, &openfgav1.WriteRequest{
+		StoreId: storeID,
+		Deletes: &openfgav1.WriteRequestDeletes{TupleKeys: []*openfgav1.TupleKeyWithoutCondition{
+			tuple.TupleKeyToTupleKeyWithoutCondition(tk),
+		}},
+	})
+	require.NoError(t, err)
+	require.True(t, check(openfgav1.ConsistencyPreference_UNSPECIFIED))
+
+	err = s.WriteStoreMetadata(ctx, storeID, &storage.StoreMetadata{DefaultConsistency: openfgav1.ConsistencyPreference(42)})
+	require.ErrorContains(t, err, "invalid default consistency '42'")
+
+	err = s.WriteStoreMetadata(ctx, storeID, &storage.StoreMetadata{DefaultConsistency: openfgav1.ConsistencyPreference_HIGHER_CONSISTENCY})
+	require.NoError(t, err)
+
+	// the consistency of the request wins
+	require.True(t, check(openfgav1.ConsistencyPreference_MINIMIZE_LATENCY))
+
+	// the default consistency of the store bypasses the cache
+	require.False(t, check(openfgav1.ConsistencyPreference_UNSPECIFIED))
+
+	// of the other Check APIs as well
+	batchResp, err := s.BatchCheck(ctx, &openfgav1.BatchCheckRequest{
+		StoreId: storeID,
+		Checks: []*openfgav1.BatchCheckItem{{
+			TupleKey:      &openfgav1.CheckRequestTupleKey{Object: "doc:1", Relation: "can_view", User: "user:jon"},
+			CorrelationId: "1",
+		}},
+	})
+	require.NoError(t, err)
+	require.False(t, batchResp.GetResult()["1"].GetAllowed())
+
+	traceResp, _, err := s.CheckWithTrace(ctx, &openfgav1.CheckRequest{
+		StoreId:  storeID,
+		TupleKey: tuple.NewCheckRequestTupleKey("doc:1", "can_view", "user:jon"),
+	})
+	require.NoError(t, err)
+	require.False(t, traceResp.GetAllowed())
+}
+
+// countingCheckCache is a storage.CheckCache that counts the entries written to it.
+type countingCheckCache struct {
+	storage.CheckCache
+	sets atomic.Int32
+}
+
+func (c *countingCheckCache) Set(ctx context.Context, key string, value any, ttl time.Duration) error {
+	c.sets.Add(1)
+	return c.CheckCache.Set(ctx, key, value, ttl)
+}
+
+func TestCheckWithCheckQueryCache(t *testing.T) {
+	t.Cleanup(func() {
+		goleak.VerifyNone(t)
+	})
+
+	inMemory, err := storage.NewInMemoryLRUCache[any]()
+	require.NoError(t, err)
+	t.Cleanup(inMemory.Stop)
+	cache := &countingCheckCache{CheckCache: storage.NewInMemoryCheckCache(inMemory)}
+
+	ctx := context.Background()
+	s := MustNewServerWithOpts(
+		WithDatastore(memory.New()),
+		WithCheckQueryCacheEnabled(true),
+		WithCheckQueryCache(cache),
+	)
+	t.Cleanup(s.Close)
+
+	createStoreResp, err := s.CreateStore(ctx, &openfgav1.CreateStoreRequest{Name: "check-query-cache"})
+	require.NoError(t, err)
+	storeID := createStoreResp.GetId()
+
+	model := parser.MustTransformDSLToProto(`
+		model
+			schema 1.1
+		type user
+		type doc
+			relations
+				define viewer: [user]`)
+	_, err = s.WriteAuthorizationModel(ctx, &openfgav1.WriteAuthorizationModelRequest{
+		StoreId:         storeID,
+		TypeDefinitions: model.GetTypeDefinitions(),
+		SchemaVersion:   model.GetSchemaVersion(),
+	})
+	require.NoError(t, err)
+
+	resp, err := s.Check(ctx, &openfgav1.CheckRequest{
+		StoreId:  storeID,
+		TupleKey: tuple.NewCheckRequestTupleKey("doc:1", "viewer", "user:jon"),
+	})
+	require.NoError(t, err)
+	require.False(t, resp.GetAllowed())
+	require.Positive(t, cache.sets.Load())
+}
+
+func TestWriteWithCycleGuardedRelations(t *testing.T) {
+	t.Cleanup(func() {
+		goleak.VerifyNone(t)
+	})
+
+	ctx := context.Background()
+	s := MustNewServerWithOpts(WithDatastore(memory.New()))
+	t.Cleanup(s.Close)
+
+	createStoreResp, err := s.CreateStore(ctx, &openfgav1.CreateStoreRequest{Name: "cycle-guarded-relations"})
+	require.NoError(t, err)
+	storeID := createStoreResp.GetId()
+
+	model := parser.MustTransformDSLToProto(`
+		model
+			schema 1.1
+		type user
+		type group
+			relations
+				define member: [user, group#member]
+		type folder
+			relations
+				define parent: [folder]
+				define viewer: [user] or viewer from parent`)
+	_, err = s.WriteAuthorizationModel(ctx, &openfgav1.WriteAuthorizationModelRequest{
+		StoreId:         storeID,
+		TypeDefinitions: model.GetTypeDefinitions(),
+		SchemaVersion:   model.GetSchemaVersion(),
+	})
+	require.NoError(t, err)
+
+	err = s.WriteStoreMetadata(ctx, storeID, &storage.StoreMetadata{CycleGuardedRelations: []string{"group#member"}})
+	require.NoError(t, err)
+
+	write := func(tuples ...*openfgav1.TupleKey) error {
+		_, err := s.Write(ctx, &openfgav1.WriteRequest{
+			StoreId: storeID,
+			Writes:  &openfgav1.WriteRequestWrites{TupleKeys: tuples},
+		})
+		return err
+	}
+
+	require.NoError(t, write(
+		tuple.NewTupleKey("group:a", "member", "group:b#member"),
+		tuple.NewTupleKey("group:b", "member", "group:c#member"),
+		tuple.NewTupleKey("group:c", "member", "user:jon"),
+	))
+
+	// a valid membership that doesn't close a loop
+	require.NoError(t, write(tuple.NewTupleKey("group:a", "member", "group:c#member")))
+
+	err = write(tuple.NewTupleKey("group:c", "member", "group:a#member"))
+	require.ErrorContains(t, err, "the tuple would introduce a cycle in the relation 'group#member'")
+
+	// the writes of the same request are considered
+	err = write(
+		tuple.NewTupleKey("group:x", "member", "group:y#member"),
+		tuple.NewTupleKey("group:y", "member", "group:x#member"),
+	)
+	require.ErrorContains(t, err, "the tuple would introduce a cycle in the relation 'group#member'")
+
+	// the loop is allowed once the edge closing it is deleted in the same request
+	_, err = s.Write(ctx, &openfgav1.WriteRequest{
+		StoreId: storeID,
+		Writes: &openfgav1.WriteRequestWrites{
+			TupleKeys: []*openfgav1.TupleKey{tuple.NewTupleKey("group:c", "member", "group:a#member")},
+		},
+		Deletes: &openfgav1.WriteRequestDeletes{
+			TupleKeys: []*openfgav1.TupleKeyWithoutCondition{
+				tuple.TupleKeyToTupleKeyWithoutCondition(tuple.NewTupleKey("group:a", "member", "group:b#member")),
+				tuple.TupleKeyToTupleKeyWithoutCondition(tuple.NewTupleKey("group:a", "member", "group:c#member")),
+			},
+		},
+	})
+	require.NoError(t, err)
+
+	// relations that aren't guarded may still form cycles
+	require.NoError(t, write(
+		tuple.NewTupleKey("folder:1", "parent", "folder:2"),
+		tuple.NewTupleKey("folder:2", "parent", "folder:1"),
+	))
+
+	// only the usersets of the relation are followed, plain objects don't form cycles of the relation
+	err = s.WriteStoreMetadata(ctx, storeID, &storage.StoreMetadata{CycleGuardedRelations: []string{"group#member", "folder#parent"}})
+	require.NoError(t, err)
+	require.NoError(t, write(
+		tuple.NewTupleKey("folder:3", "parent", "folder:4"),
+		tuple.NewTupleKey("folder:4", "parent", "folder:3"),
+	))
+
+	// the search of a cycle is bounded
+	var chain []*openfgav1.TupleKey
+	for i := range 30 {
+		chain = append(chain, tuple.NewTupleKey(fmt.Sprintf("group:chain-%d", i), "member", fmt.Sprintf("group:chain-%d#member", i+1)))
+	}
+	err = write(chain...)
+	require.ErrorContains(t, err, "the relation 'group#member' is too deep to be checked for cycles")
+
+	err = s.WriteStoreMetadata(ctx, storeID, &storage.StoreMetadata{CycleGuardedRelations: []string{"group"}})
+	require.ErrorContains(t, err, "invalid relation 'group' in the cycle guarded relations")
+}
+
+func TestCheckWithWildcardPolicy(t *testing.T) {
+	t.Cleanup(func() {
+		goleak.VerifyNone(t)
+	})
+
+	ctx := context.Background()
+	ds := memory.New()
+	t.Cleanup(ds.Close)
+
+	s := MustNewServerWithOpts(WithDatastore(ds))
+	t.Cleanup(s.Close)
+
+	createStoreResp, err := s.CreateStore(ctx, &openfgav1.CreateStoreRequest{Name: "wildcard-policy"})
+	require.NoError(t, err)
+	storeID := createStoreResp.GetId()
+
+	model := parser.MustTransformDSLToProto(`
+		model
+			schema 1.1
+		type user
+		type group
+			relations
+				define member: [user, user:*]
+		type document
+			relations
+				define viewer: [user, user:*]
+				define editor: [group#member]`)
+	_, err = s.WriteAuthorizationModel(ctx, &openfgav1.WriteAuthorizationModelRequest{
+		StoreId:         storeID,
+		TypeDefinitions: model.GetTypeDefinitions(),
+		SchemaVersion:   model.GetSchemaVersion(),
+	})
+	require.NoError(t, err)
+
+	_, err = s.Write(ctx, &openfgav1.WriteRequest{
+		StoreId: storeID,
+		Writes: &openfgav1.WriteRequestWrites{
+			TupleKeys: []*openfgav1.TupleKey{
+				tuple.NewTupleKey("document:1", "viewer", "user:*"),
+				tuple.NewTupleKey("group:everyone", "member", "user:*"),
+				tuple.NewTupleKey("document:1", "editor", "group:everyone#member"),
+			},
+		},
+	})
+	require.NoError(t, err)
+
+	authenticated := MustNewServerWithOpts(
+		WithDatastore(ds),
+		WithWildcardPolicies(map[string]tuple.WildcardPolicy{storeID: tuple.WildcardPolicyAuthenticated}),
+	)
+	t.Cleanup(authenticated.Close)
+
+	anonymous := "user:" + tuple.AnonymousUserID
+
+	for _, relation := range []string{"viewer", "editor"} {
+		t.Run(relation, func(t *testing.T) {
+			check := func(s *Server, user string) bool {
+				resp, err := s.Check(ctx, &openfgav1.CheckRequest{
+					StoreId:  storeID,
+					TupleKey: tuple.NewCheckRequestTupleKey("document:1", relation, user),
+				})
+				require.NoError(t, err)
+				return resp.GetAllowed()
+			}
+
+			// by default, the wildcard applies to all the users of the type, as it always did
+			require.True(t, check(s, "user:jon"))
+			require.True(t, check(s, anonymous))
+
+			require.True(t, check(authenticated, "user:jon"))
+			require.False(t, check(authenticated, anonymous))
+		})
+	}
+}
+
+func TestServerIsReady(t *testing.T) {
+	t.Cleanup(func() {
+		goleak.VerifyNone(t)
+	})
+
+	t.Run("memory_with_check_cache", func(t *testing.T) {
+		s := MustNewServerWithOpts(
+			WithDatastore(memory.New()),
+			WithCheckQueryCacheEnabled(true),
+		)
+		t.Cleanup(s.Close)
+
+		ready, err := s.IsReady(context.Background())
+		require.NoError(t, err)
+		require.True(t, ready)
+	})
+
+	t.Run("datastore_not_ready", func(t *testing.T) {
+		mockController := gomock.NewController(t)
+		mockDatastore := mockstorage.NewMockOpenFGADatastore(mockController)
+		mockDatastore.EXPECT().IsReady(gomock.Any()).Return(storage.ReadinessStatus{Message: "requires migrations"}, nil)
+		mockDatastore.EXPECT().Close()
+
+		s := MustNewServerWithOpts(WithDatastore(mockDatastore))
+		t.Cleanup(s.Close)
+
+		ready, err := s.IsReady(context.Background())
+		require.NoError(t, err)
+		require.False(t, ready)
+	})
+}
+
+func TestPreloadModels(t *testing.T) {
+	t.Cleanup(func() {
+		goleak.VerifyNone(t)
+	})
+
+	storeID := ulid.Make().String()
+	model := testutils.MustTransformDSLToProtoWithID(`
+		model
+			schema 1.1
+		type user`)
+	missingModelID := ulid.Make().String()
+
+	mockController := gomock.NewController(t)
+	mockDatastore := mockstorage.NewMockOpenFGADatastore(mockController)
+	mockDatastore.EXPECT().Close()
+
+	s := MustNewServerWithOpts(WithDatastore(mockDatastore))
+	t.Cleanup(s.Close)
+
+	t.Run("preloaded_models_are_cached", func(t *testing.T) {
+		mockDatastore.EXPECT().ReadAuthorizationModel(gomock.Any(), storeID, model.GetId()).Times(1).Return(model, nil)
+		mockDatastore.EXPECT().ReadAuthorizationModel(gomock.Any(), storeID, missingModelID).Return(nil, storage.ErrNotFound)
+
+		results := s.PreloadModels(context.Background(), storeID, model.GetId(), missingModelID)
+		require.Len(t, results, 2)
+		require.Equal(t, ModelPreloadResult{ModelID: model.GetId()}, results[0])
+		require.Equal(t, missingModelID, results[1].ModelID)
+		require.ErrorIs(t, results[1].Err, typesystem.ErrModelNotFound)
+
+		// the model isn't read again
+		typesys, err := s.resolveTypesystem(context.Background(), storeID, model.GetId())
+		require.NoError(t, err)
+		require.Equal(t, model.GetId(), typesys.GetAuthorizationModelID())
+	})
+
+	t.Run("latest_model", func(t *testing.T) {
+		mockDatastore.EXPECT().FindLatestAuthorizationModel(gomock.Any(), storeID).Return(model, nil)
+
+		results := s.PreloadModels(context.Background(), storeID)
+		require.Equal(t, []ModelPreloadResult{{ModelID: model.GetId()}}, results)
+	})
+
+	t.Run("store_without_models", func(t *testing.T) {
+		otherStoreID := ulid.Make().String()
+		mockDatastore.EXPECT().FindLatestAuthorizationModel(gomock.Any(), otherStoreID).Return(nil, storage.ErrNotFound)
+
+		results := s.PreloadModels(context.Background(), otherStoreID)
+		require.Len(t, results, 1)
+		require.Empty(t, results[0].ModelID)
+		require.ErrorIs(t, results[0].Err, typesystem.ErrModelNotFound)
+	})
+}
+
+func TestSharedModelCache(t *testing.T) {
+	t.Cleanup(func() {
+		goleak.VerifyNone(t)
+	})
+
+	storeID := ulid.Make().String()
+	model := testutils.MustTransformDSLToProtoWithID(`
+		model
+			schema 1.1
+		type user`)
+
+	modelCache, err := storage.NewInMemoryModelCache(10)
+	require.NoError(t, err)
+	t.Cleanup(modelCache.Stop)
+
+	mockController := gomock.NewController(t)
+	mockDatastore := mockstorage.NewMockOpenFGADatastore(mockController)
+	mockDatastore.EXPECT().Close().Times(2)
+
+	// the model is read once, for both servers
+	mockDatastore.EXPECT().ReadAuthorizationModel(gomock.Any(), storeID, model.GetId()).Times(1).Return(model, nil)
+
+	for range 2 {
+		s := MustNewServerWithOpts(WithDatastore(mockDatastore), WithModelCache(modelCache))
+		t.Cleanup(s.Close)
+
+		resp, err := s.ReadAuthorizationModel(context.Background(), &openfgav1.ReadAuthorizationModelRequest{
+			StoreId: storeID,
+			Id:      model.GetId(),
+		})
+		require.NoError(t, err)
+		require.Equal(t, model.GetId(), resp.GetAuthorizationModel().GetId())
+	}
+
+	cached, ok := modelCache.Get(storeID, model.GetId())
+	require.True(t, ok)
+	require.Equal(t, model.GetId(), cached.GetId())
+}
+
+// listObjectsHistogram returns the number of samples and their sum recorded by the ListObjects histogram for
+// the method, object type and relation.
+func listObjectsHistogram(t *testing.T, name, method, objectType, relation string) (uint64, float64) {
+	t.Helper()
+
+	families, err := prometheus.DefaultGatherer.Gather()
+	require.NoError(t, err)
+
+	want := map[string]string{"grpc_method": method, "object_type": objectType, "relation": relation}
+	for _, family := range families {
+		if family.GetName() != name {
+			continue
+		}
+	metrics:
+		for _, metric := range family.GetMetric() {
+			for _, label := range metric.GetLabel() {
+				if want[label.GetName()] != label.GetValue() {
+					continue metrics
+				}
+			}
+			return metric.GetHistogram().GetSampleCount(), metric.GetHistogram().GetSampleSum()
+		}
+	}
+	return 0, 0
+}
+
+func TestListObjectsEfficiencyMetrics(t *testing.T) {
+	t.Cleanup(func() {
+		goleak.VerifyNone(t)
+	})
+
+	ctx := context.Background()
+	s := MustNewServerWithOpts(WithDatastore(memory.New()))
+	t.Cleanup(s.Close)
+
+	createStoreResp, err := s.CreateStore(ctx, &openfgav1.CreateStoreRequest{Name: "list-objects-efficiency"})
+	require.NoError(t, err)
+	storeID := createStoreResp.GetId()
+
+	model := parser.MustTransformDSLToProto(`
+		model
+			schema 1.1
+		type user
+		type efficiency_doc
+			relations
+				define blocked: [user]
+				define viewer: [user] but not blocked`)
+	_, err = s.WriteAuthorizationModel(ctx, &openfgav1.WriteAuthorizationModelRequest{
+		StoreId:         storeID,
+		TypeDefinitions: model.GetTypeDefinitions(),
+		SchemaVersion:   model.GetSchemaVersion(),
+	})
+	require.NoError(t, err)
+
+	_, err = s.Write(ctx, &openfgav1.WriteRequest{
+		StoreId: storeID,
+		Writes: &openfgav1.WriteRequestWrites{
+			TupleKeys: []*openfgav1.TupleKey{
+				tuple.NewTupleKey("efficiency_doc:1", "viewer", "user:anne"),
+				tuple.NewTupleKey("efficiency_doc:2", "viewer", "user:anne"),
+				tuple.NewTupleKey("efficiency_doc:3", "viewer", "user:anne"),
+				tuple.NewTupleKey("efficiency_doc:3", "blocked", "user:anne"),
+			},
+		},
+	})
+	require.NoError(t, err)
+
+	// each of the 3 candidate objects is checked against the exclusion, and 2 of them are returned
+	assertRecorded := func(t *testing.T, method string) {
+		count, sum := listObjectsHistogram(t, "openfga_list_objects_objects_scanned", method, "efficiency_doc", "viewer")
+		require.Equal(t, uint64(1), count)
+		require.InDelta(t, 3, sum, 0)
+
+		count, sum = listObjectsHistogram(t, "openfga_list_objects_objects_returned", method, "efficiency_doc", "viewer")
+		require.Equal(t, uint64(1), count)
+		require.InDelta(t, 2, sum, 0)
+
+		count, sum = listObjectsHistogram(t, "openfga_list_objects_checks_issued", method, "efficiency_doc", "viewer")
+		require.Equal(t, uint64(1), count)
+		require.InDelta(t, 3, sum, 0)
+
+		count, _ = listObjectsHistogram(t, "openfga_list_objects_duration_ms", method, "efficiency_doc", "viewer")
+		require.Equal(t, uint64(1), count)
+	}
+
+	t.Run("list_objects", func(t *testing.T) {
+		resp, err := s.ListObjects(ctx, &openfgav1.ListObjectsRequest{
+			StoreId:  storeID,
+			Type:     "efficiency_doc",
+			Relation: "viewer",
+			User:     "user:anne",
+		})
+		require.NoError(t, err)
+		require.Len(t, resp.GetObjects(), 2)
+
+		assertRecorded(t, "listobjects")
+	})
+
+	t.Run("streamed_list_objects", func(t *testing.T) {
+		err := s.StreamedListObjects(&openfgav1.StreamedListObjectsRequest{
+			StoreId:  storeID,
+			Type:     "efficiency_doc",
+			Relation: "viewer",
+			User:     "user:anne",
+		}, NewMockStreamServer(ctx))
+		require.NoError(t, err)
+
+		assertRecorded(t, "streamedlistobjects")
+	})
+}
@@ -0,0 +1,91 @@
+package server
+
+import (
+	"context"
+	"io"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/openfga/openfga/internal/utils/apimethod"
+	"github.com/openfga/openfga/pkg/server/commands"
+	"github.com/openfga/openfga/pkg/telemetry"
+)
+
+// ExportStore writes an archive of the store to w, consisting of the store, its metadata, all of its
+// authorization models and all of its tuples, see [commands.StoreExportCommand]. It requires the same
+// authorization as reading the models and the tuples of the store.
+func (s *Server) ExportStore(ctx context.Context, storeID string, w io.Writer) error {
+	ctx, span := tracer.Start(ctx, "ExportStore", trace.WithAttributes(
+		attribute.String("store_id", storeID),
+	))
+	defer span.End()
+
+	ctx = telemetry.ContextWithRPCInfo(ctx, telemetry.RPCInfo{
+		Service: s.serviceName,
+		Method:  apimethod.Read.String(),
+	})
+
+	for _, method := range []apimethod.APIMethod{apimethod.ReadAuthorizationModels, apimethod.Read} {
+		if err := s.checkAuthz(ctx, storeID, method); err != nil {
+			return err
+		}
+	}
+
+	cmd := commands.NewStoreExportCommand(s.datastore, commands.WithStoreExportCmdLogger(s.logger))
+	err := cmd.Execute(ctx, storeID, w)
+	if err != nil {
+		telemetry.TraceError(span, err)
+		return err
+	}
+
+	return nil
+}
+
+// ImportStore imports an archive written by ExportStore, either into a new store or into the existing store
+// of the request, see [commands.StoreImportCommand]. Importing into a new store requires the same authorization
+// as creating a store, importing into an existing store the same authorization as writing its models and tuples.
+func (s *Server) ImportStore(ctx context.Context, r io.Reader, req *commands.StoreImportRequest) (*commands.StoreImportResult, error) {
+	ctx, span := tracer.Start(ctx, "ImportStore", trace.WithAttributes(
+		attribute.String("store_id", req.StoreID),
+	))
+	defer span.End()
+
+	ctx = telemetry.ContextWithRPCInfo(ctx, telemetry.RPCInfo{
+		Service: s.serviceName,
+		Method:  apimethod.Write.String(),
+	})
+
+	if req.StoreID == "" {
+		if err := s.checkCreateStoreAuthz(ctx); err != nil {
+			return nil, err
+		}
+	} else {
+		for _, method := range []apimethod.APIMethod{apimethod.WriteAuthorizationModel, apimethod.Write} {
+			if err := s.checkAuthz(ctx, req.StoreID, method); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	cmd := commands.NewStoreImportCommand(s.datastore,
+		commands.WithStoreImportCmdLogger(s.logger),
+		commands.WithStoreImportWriteAuthModelOptions(
+			commands.WithWriteAuthModelMaxSizeInBytes(s.maxAuthorizationModelSizeInBytes),
+			commands.WithWriteAuthModelMaxRelationBranches(s.maxRelationBranches),
+		),
+	)
+	result, err := cmd.Execute(ctx, r, req)
+	if req.StoreID != "" {
+		// the archive may have replaced the metadata of the store
+		s.storeMetadataCache.Delete(req.StoreID)
+	}
+	if err != nil {
+		telemetry.TraceError(span, err)
+		return nil, err
+	}
+
+	span.SetAttributes(attribute.String("imported_store_id", result.StoreID))
+
+	return result, nil
+}
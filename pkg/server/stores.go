@@ -2,8 +2,10 @@ package server
 
 import (
 	"context"
+	"errors"
 	"net/http"
 	"strconv"
+	"time"
 
 	"go.opentelemetry.io/otel/attribute"
 	"go.opentelemetry.io/otel/trace"
@@ -16,6 +18,8 @@ import (
 	httpmiddleware "github.com/openfga/openfga/pkg/middleware/http"
 	"github.com/openfga/openfga/pkg/middleware/validator"
 	"github.com/openfga/openfga/pkg/server/commands"
+	serverErrors "github.com/openfga/openfga/pkg/server/errors"
+	"github.com/openfga/openfga/pkg/storage"
 	"github.com/openfga/openfga/pkg/telemetry"
 )
 
@@ -78,6 +82,10 @@ func (s *Server) DeleteStore(ctx context.Context, req *openfgav1.DeleteStoreRequ
 		return nil, err
 	}
 
+	for _, cachedCheckResolver := range s.cachedCheckResolvers {
+		cachedCheckResolver.InvalidateStore(ctx, req.GetStoreId())
+	}
+
 	s.transport.SetHeader(ctx, httpmiddleware.XHttpCode, strconv.Itoa(http.StatusNoContent))
 
 	return res, nil
@@ -110,6 +118,18 @@ func (s *Server) GetStore(ctx context.Context, req *openfgav1.GetStoreRequest) (
 }
 
 func (s *Server) ListStores(ctx context.Context, req *openfgav1.ListStoresRequest) (*openfgav1.ListStoresResponse, error) {
+	return s.listStores(ctx, req)
+}
+
+// ListStoresWithNameContaining lists the stores like ListStores, filtered to those whose name contains the
+// substring, case-sensitively. The stores are listed by ID, i.e. from oldest to newest, and the continuation
+// token is the ID of the first store of the next page, so the stores created while paginating never shift
+// the pages nor repeat the stores already listed.
+func (s *Server) ListStoresWithNameContaining(ctx context.Context, req *openfgav1.ListStoresRequest, substring string) (*openfgav1.ListStoresResponse, error) {
+	return s.listStores(ctx, req, commands.WithListStoresQueryNameContains(substring))
+}
+
+func (s *Server) listStores(ctx context.Context, req *openfgav1.ListStoresRequest, opts ...commands.ListStoresQueryOption) (*openfgav1.ListStoresResponse, error) {
 	method := "ListStores"
 	ctx, span := tracer.Start(ctx, method)
 	defer span.End()
@@ -131,9 +151,105 @@ func (s *Server) ListStores(ctx context.Context, req *openfgav1.ListStoresReques
 	}
 
 	// even though we have the list of store IDs, we need to call ListStoresQuery to fetch the entire metadata of the store.
-	q := commands.NewListStoresQuery(s.datastore,
+	q := commands.NewListStoresQuery(s.datastore, append([]commands.ListStoresQueryOption{
 		commands.WithListStoresQueryLogger(s.logger),
 		commands.WithListStoresQueryEncoder(s.encoder),
-	)
+	}, opts...)...)
 	return q.Execute(ctx, req, storeIDs)
 }
+
+// WriteStoreMetadata replaces the metadata of a store, e.g. the allowlist of the object types of the tuples
+// that may be written to the store. Since the metadata governs how the store may be used, it requires the
+// same authorization as deleting the store.
+func (s *Server) WriteStoreMetadata(ctx context.Context, storeID string, metadata *storage.StoreMetadata) error {
+	ctx, span := tracer.Start(ctx, "WriteStoreMetadata", trace.WithAttributes(
+		attribute.String("store_id", storeID),
+	))
+	defer span.End()
+
+	ctx = telemetry.ContextWithRPCInfo(ctx, telemetry.RPCInfo{
+		Service: s.serviceName,
+		Method:  apimethod.DeleteStore.String(),
+	})
+
+	err := s.checkAuthz(ctx, storeID, apimethod.DeleteStore)
+	if err != nil {
+		return err
+	}
+
+	if err := commands.ValidateStoreMetadata(metadata); err != nil {
+		return err
+	}
+
+	err = s.datastore.WriteStoreMetadata(ctx, storeID, metadata)
+	if err != nil {
+		return serverErrors.HandleError("", err)
+	}
+
+	s.storeMetadataCache.Delete(storeID)
+
+	return nil
+}
+
+// ReadStoreMetadata returns the metadata of a store. It requires the same authorization as GetStore.
+func (s *Server) ReadStoreMetadata(ctx context.Context, storeID string) (*storage.StoreMetadata, error) {
+	ctx, span := tracer.Start(ctx, "ReadStoreMetadata", trace.WithAttributes(
+		attribute.String("store_id", storeID),
+	))
+	defer span.End()
+
+	ctx = telemetry.ContextWithRPCInfo(ctx, telemetry.RPCInfo{
+		Service: s.serviceName,
+		Method:  apimethod.GetStore.String(),
+	})
+
+	err := s.checkAuthz(ctx, storeID, apimethod.GetStore)
+	if err != nil {
+		return nil, err
+	}
+
+	metadata, err := s.datastore.ReadStoreMetadata(ctx, storeID)
+	if err != nil {
+		return nil, serverErrors.HandleError("", err)
+	}
+
+	return metadata, nil
+}
+
+// storeMetadataCacheTTL is how long the metadata of a store read by the requests is cached. It bounds how long the
+// metadata written through another server takes to apply.
+const storeMetadataCacheTTL = 10 * time.Second
+
+// resolveConsistency returns the consistency preference of a request of the store, which is the consistency of the
+// request if it's specified, and otherwise the default consistency of the store.
+func (s *Server) resolveConsistency(ctx context.Context, storeID string, consistency openfgav1.ConsistencyPreference) (openfgav1.ConsistencyPreference, error) {
+	if consistency != openfgav1.ConsistencyPreference_UNSPECIFIED {
+		return consistency, nil
+	}
+
+	metadata, err := s.readCachedStoreMetadata(ctx, storeID)
+	if err != nil {
+		if errors.Is(err, storage.ErrNotFound) {
+			// the store has no default consistency if it was deleted concurrently
+			return consistency, nil
+		}
+		return consistency, serverErrors.HandleError("", err)
+	}
+
+	return metadata.GetDefaultConsistency(), nil
+}
+
+// readCachedStoreMetadata returns the metadata of the store, which is cached for storeMetadataCacheTTL, or
+// until it's written through this server.
+func (s *Server) readCachedStoreMetadata(ctx context.Context, storeID string) (*storage.StoreMetadata, error) {
+	if metadata := s.storeMetadataCache.Get(storeID); metadata != nil {
+		return metadata, nil
+	}
+
+	metadata, err := s.datastore.ReadStoreMetadata(ctx, storeID)
+	if err != nil {
+		return nil, err
+	}
+	s.storeMetadataCache.Set(storeID, metadata, storeMetadataCacheTTL)
+	return metadata, nil
+}
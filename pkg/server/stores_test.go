@@ -0,0 +1,114 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"go.uber.org/goleak"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+
+	openfgav1 "github.com/openfga/api/proto/openfga/v1"
+
+	"github.com/openfga/openfga/pkg/storage/memory"
+)
+
+func TestListStoresWithNameContaining(t *testing.T) {
+	t.Cleanup(func() {
+		goleak.VerifyNone(t)
+	})
+
+	ctx := context.Background()
+
+	s := MustNewServerWithOpts(WithDatastore(memory.New()))
+	t.Cleanup(s.Close)
+
+	// 50 stores of which the even ones are named 'team-*'
+	var teamStoreIDs []string
+	for i := range 50 {
+		name := fmt.Sprintf("project-%02d", i)
+		if i%2 == 0 {
+			name = fmt.Sprintf("team-%02d", i)
+		}
+		resp, err := s.CreateStore(ctx, &openfgav1.CreateStoreRequest{Name: name})
+		require.NoError(t, err)
+		if i%2 == 0 {
+			teamStoreIDs = append(teamStoreIDs, resp.GetId())
+		}
+	}
+
+	// listStores pages through the stores whose name contains the substring and returns the pages
+	listStores := func(t *testing.T, substring string, onPage func()) [][]*openfgav1.Store {
+		var (
+			pages [][]*openfgav1.Store
+			token string
+		)
+		for {
+			resp, err := s.ListStoresWithNameContaining(ctx, &openfgav1.ListStoresRequest{
+				PageSize:          wrapperspb.Int32(10),
+				ContinuationToken: token,
+			}, substring)
+			require.NoError(t, err)
+			pages = append(pages, resp.GetStores())
+
+			token = resp.GetContinuationToken()
+			if token == "" {
+				return pages
+			}
+			onPage()
+			require.Less(t, len(pages), 10, "the pagination must end")
+		}
+	}
+
+	t.Run("pages_through_all_the_stores", func(t *testing.T) {
+		pages := listStores(t, "", func() {})
+		require.Len(t, pages, 5)
+		for _, page := range pages {
+			require.Len(t, page, 10)
+			for _, store := range page {
+				require.NotEmpty(t, store.GetId())
+				require.NotEmpty(t, store.GetName())
+				require.NotNil(t, store.GetCreatedAt())
+				require.NotNil(t, store.GetUpdatedAt())
+			}
+		}
+	})
+
+	t.Run("filters_by_name", func(t *testing.T) {
+		pages := listStores(t, "team-", func() {})
+		require.Len(t, pages, 3)
+
+		var ids []string
+		for _, page := range pages {
+			for _, store := range page {
+				require.Contains(t, store.GetName(), "team-")
+				ids = append(ids, store.GetId())
+			}
+		}
+		require.Equal(t, teamStoreIDs, ids)
+
+		pages = listStores(t, "TEAM-", func() {})
+		require.Len(t, pages, 1)
+		require.Empty(t, pages[0])
+	})
+
+	t.Run("stable_under_concurrent_store_creation", func(t *testing.T) {
+		var created []string
+		pages := listStores(t, "", func() {
+			resp, err := s.CreateStore(ctx, &openfgav1.CreateStoreRequest{Name: "late-store"})
+			require.NoError(t, err)
+			created = append(created, resp.GetId())
+		})
+
+		seen := make(map[string]struct{})
+		for _, page := range pages {
+			for _, store := range page {
+				require.NotContains(t, seen, store.GetId())
+				seen[store.GetId()] = struct{}{}
+			}
+		}
+		// every store that existed before or was created during the pagination is listed once
+		require.Len(t, seen, 50+len(created))
+	})
+}
@@ -12,11 +12,14 @@ import (
 
 	openfgav1 "github.com/openfga/api/proto/openfga/v1"
 
+	"github.com/openfga/openfga/internal/graph"
 	"github.com/openfga/openfga/internal/utils/apimethod"
 	"github.com/openfga/openfga/pkg/authclaims"
 	"github.com/openfga/openfga/pkg/middleware/validator"
 	"github.com/openfga/openfga/pkg/server/commands"
+	"github.com/openfga/openfga/pkg/storage"
 	"github.com/openfga/openfga/pkg/telemetry"
+	"github.com/openfga/openfga/pkg/tuple"
 )
 
 func (s *Server) Write(ctx context.Context, req *openfgav1.WriteRequest) (*openfgav1.WriteResponse, error) {
@@ -53,6 +56,8 @@ func (s *Server) Write(ctx context.Context, req *openfgav1.WriteRequest) (*openf
 	cmd := commands.NewWriteCommand(
 		s.datastore,
 		commands.WithWriteCmdLogger(s.logger),
+		commands.WithDuplicateWriteBehavior(s.duplicateWriteBehavior),
+		commands.WithStoreMetadataReader(s.readCachedStoreMetadata),
 	)
 	resp, err := cmd.Execute(ctx, &openfgav1.WriteRequest{
 		StoreId:              storeID,
@@ -69,5 +74,100 @@ func (s *Server) Write(ctx context.Context, req *openfgav1.WriteRequest) (*openf
 		req.GetDeletes().GetOnMissing(),
 	).Observe(float64(time.Since(start).Milliseconds()))
 
+	s.observeDatastoreError(storeID, err)
+	if err == nil {
+		s.invalidateCachedChecks(ctx, storeID, typesys.GetAuthorizationModelID(), req)
+	}
 	return resp, err
 }
+
+// WriteBestEffort writes the tuples of the request without a transaction spanning all of them, so that the
+// tuples that can't be written, e.g. because they already exist, don't prevent the others from being written.
+// It returns the outcome of each tuple in the order of the request, see commands.WriteCommand.ExecuteBestEffort.
+// The request can't have deletes. It fails with codes.Unimplemented if the datastore doesn't support best
+// effort writes.
+func (s *Server) WriteBestEffort(ctx context.Context, req *openfgav1.WriteRequest) ([]storage.TupleWriteResult, error) {
+	ctx, span := tracer.Start(ctx, "WriteBestEffort", trace.WithAttributes(
+		attribute.String("store_id", req.GetStoreId()),
+	))
+	defer span.End()
+
+	if !validator.RequestIsValidatedFromContext(ctx) {
+		if err := req.Validate(); err != nil {
+			return nil, status.Error(codes.InvalidArgument, err.Error())
+		}
+	}
+
+	ctx = telemetry.ContextWithRPCInfo(ctx, telemetry.RPCInfo{
+		Service: s.serviceName,
+		Method:  apimethod.Write.String(),
+	})
+
+	storeID := req.GetStoreId()
+
+	typesys, err := s.resolveTypesystem(ctx, storeID, req.GetAuthorizationModelId())
+	if err != nil {
+		return nil, err
+	}
+
+	err = s.checkWriteAuthz(ctx, req, typesys)
+	if err != nil {
+		return nil, err
+	}
+
+	cmd := commands.NewWriteCommand(
+		s.datastore,
+		commands.WithWriteCmdLogger(s.logger),
+		commands.WithStoreMetadataReader(s.readCachedStoreMetadata),
+	)
+	results, err := cmd.ExecuteBestEffort(ctx, &openfgav1.WriteRequest{
+		StoreId:              storeID,
+		AuthorizationModelId: typesys.GetAuthorizationModelID(), // the resolved model id
+		Writes:               req.GetWrites(),
+		Deletes:              req.GetDeletes(),
+	})
+	s.observeDatastoreError(storeID, err)
+	if err != nil {
+		return nil, err
+	}
+
+	written := &openfgav1.WriteRequestWrites{}
+	for _, result := range results {
+		if result.Status == storage.TupleWriteStatusWritten {
+			written.TupleKeys = append(written.TupleKeys, result.TupleKey)
+		}
+	}
+	s.invalidateCachedChecks(ctx, storeID, typesys.GetAuthorizationModelID(), &openfgav1.WriteRequest{Writes: written})
+
+	return results, nil
+}
+
+// invalidateCachedChecks removes the cached responses of the Checks of the tuples written or deleted by the
+// request, in the model of the request, without context or contextual tuples. The responses of the Checks
+// that depend on the tuples otherwise still expire with the TTL of the cache.
+func (s *Server) invalidateCachedChecks(ctx context.Context, storeID, modelID string, req *openfgav1.WriteRequest) {
+	if len(s.cachedCheckResolvers) == 0 {
+		return
+	}
+
+	invalidate := func(object, relation, user string) {
+		checkReq, err := graph.NewResolveCheckRequest(graph.ResolveCheckRequestParams{
+			StoreID:              storeID,
+			AuthorizationModelID: modelID,
+			TupleKey:             tuple.NewTupleKey(object, relation, user),
+		})
+		if err != nil {
+			return
+		}
+		for _, cachedCheckResolver := range s.cachedCheckResolvers {
+			cachedCheckResolver.Invalidate(ctx, checkReq)
+		}
+	}
+
+	for _, tk := range req.GetWrites().GetTupleKeys() {
+		invalidate(tk.GetObject(), tk.GetRelation(), tk.GetUser())
+	}
+	for _, tk := range req.GetDeletes().GetTupleKeys() {
+		invalidate(tk.GetObject(), tk.GetRelation(), tk.GetUser())
+	}
+}
@@ -0,0 +1,92 @@
+package server
+
+import (
+	"context"
+	"testing"
+
+	parser "github.com/openfga/language/pkg/go/transformer"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/goleak"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	openfgav1 "github.com/openfga/api/proto/openfga/v1"
+
+	"github.com/openfga/openfga/pkg/storage"
+	"github.com/openfga/openfga/pkg/storage/memory"
+	"github.com/openfga/openfga/pkg/tuple"
+)
+
+func TestWriteBestEffort(t *testing.T) {
+	t.Cleanup(func() {
+		goleak.VerifyNone(t)
+	})
+
+	ctx := context.Background()
+	s := MustNewServerWithOpts(WithDatastore(memory.New()))
+	t.Cleanup(s.Close)
+
+	createStoreResp, err := s.CreateStore(ctx, &openfgav1.CreateStoreRequest{Name: "write-best-effort"})
+	require.NoError(t, err)
+	storeID := createStoreResp.GetId()
+
+	model := parser.MustTransformDSLToProto(`
+		model
+			schema 1.1
+		type user
+		type doc
+			relations
+				define viewer: [user]`)
+	_, err = s.WriteAuthorizationModel(ctx, &openfgav1.WriteAuthorizationModelRequest{
+		StoreId:         storeID,
+		TypeDefinitions: model.GetTypeDefinitions(),
+		SchemaVersion:   model.GetSchemaVersion(),
+	})
+	require.NoError(t, err)
+
+	existing := tuple.NewTupleKey("doc:1", "viewer", "user:anne")
+	_, err = s.Write(ctx, &openfgav1.WriteRequest{
+		StoreId: storeID,
+		Writes:  &openfgav1.WriteRequestWrites{TupleKeys: []*openfgav1.TupleKey{existing}},
+	})
+	require.NoError(t, err)
+
+	t.Run("writes_the_tuples_that_dont_exist", func(t *testing.T) {
+		added := tuple.NewTupleKey("doc:1", "viewer", "user:bob")
+		results, err := s.WriteBestEffort(ctx, &openfgav1.WriteRequest{
+			StoreId: storeID,
+			Writes:  &openfgav1.WriteRequestWrites{TupleKeys: []*openfgav1.TupleKey{existing, added}},
+		})
+		require.NoError(t, err)
+		require.Len(t, results, 2)
+		require.Equal(t, storage.TupleWriteStatusAlreadyExists, results[0].Status)
+		require.Equal(t, storage.TupleWriteStatusWritten, results[1].Status)
+
+		resp, err := s.Check(ctx, &openfgav1.CheckRequest{
+			StoreId:  storeID,
+			TupleKey: tuple.NewCheckRequestTupleKey(added.GetObject(), added.GetRelation(), added.GetUser()),
+		})
+		require.NoError(t, err)
+		require.True(t, resp.GetAllowed())
+	})
+
+	t.Run("rejects_deletes", func(t *testing.T) {
+		_, err := s.WriteBestEffort(ctx, &openfgav1.WriteRequest{
+			StoreId: storeID,
+			Deletes: &openfgav1.WriteRequestDeletes{TupleKeys: []*openfgav1.TupleKeyWithoutCondition{
+				tuple.TupleKeyToTupleKeyWithoutCondition(existing),
+			}},
+		})
+		require.Equal(t, codes.Code(openfgav1.ErrorCode_validation_error), status.Code(err))
+	})
+
+	t.Run("rejects_tuples_invalid_in_the_model", func(t *testing.T) {
+		_, err := s.WriteBestEffort(ctx, &openfgav1.WriteRequest{
+			StoreId: storeID,
+			Writes: &openfgav1.WriteRequestWrites{TupleKeys: []*openfgav1.TupleKey{
+				tuple.NewTupleKey("doc:1", "editor", "user:anne"),
+			}},
+		})
+		require.Error(t, err)
+	})
+}
@@ -4,11 +4,12 @@ package storage
 
 import (
 	"errors"
-	"fmt"
 	"io"
 	"sort"
 	"strconv"
+	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/Yiling-J/theine-go"
@@ -60,7 +61,10 @@ type InMemoryCache[T any] interface {
 	Get(key string) T
 	Set(key string, value T, ttl time.Duration)
 
+	// Delete removes the key, if it exists.
 	Delete(key string)
+	// Clear removes all the keys.
+	Clear()
 
 	// Stop cleans resources.
 	Stop()
@@ -72,22 +76,61 @@ type InMemoryLRUCache[T any] struct {
 	client      *theine.Cache[string, T]
 	maxElements int64
 	stopOnce    *sync.Once
+	// evictions is the number of entries that were evicted to make room for others.
+	evictions *atomic.Uint64
+	onEvict   func(key string, value T, reason EvictReason)
+	costFunc  func(value T) int64
 }
 
+// EvictReason is the reason why an entry left an [InMemoryLRUCache].
+type EvictReason int
+
+const (
+	// EvictLRU is the reason of the entries evicted to make room for others.
+	EvictLRU EvictReason = iota
+	// EvictTTL is the reason of the entries removed because their TTL expired.
+	EvictTTL
+	// EvictManual is the reason of the entries removed with Delete.
+	EvictManual
+)
+
 type InMemoryLRUCacheOpt[T any] func(i *InMemoryLRUCache[T])
 
+// WithMaxCacheSize sets the maximum number of entries of the cache, or the maximum total cost of its entries if
+// a cost function is set, see WithCostFunc.
 func WithMaxCacheSize[T any](maxElements int64) InMemoryLRUCacheOpt[T] {
 	return func(i *InMemoryLRUCache[T]) {
 		i.maxElements = maxElements
 	}
 }
 
+// WithCostFunc sets the function that estimates the cost of an entry, e.g. its size in bytes, so that the cache
+// evicts entries once their total cost exceeds the max cache size rather than once their number does. The cost of
+// an entry is at least 1, and an entry that costs more than the max cache size is not stored. Without a cost
+// function, every entry costs 1.
+func WithCostFunc[T any](costFunc func(value T) int64) InMemoryLRUCacheOpt[T] {
+	return func(i *InMemoryLRUCache[T]) {
+		i.costFunc = costFunc
+	}
+}
+
+// WithEvictionCallback sets the function called when an entry leaves the cache, with the reason why it left.
+// It isn't called when the value of an entry is replaced by a Set of the same key. It is called by the
+// goroutine that maintains the cache, after the entry was removed and outside the locks that guard the
+// entries of the cache, one entry at a time, so it should return quickly.
+func WithEvictionCallback[T any](onEvict func(key string, value T, reason EvictReason)) InMemoryLRUCacheOpt[T] {
+	return func(i *InMemoryLRUCache[T]) {
+		i.onEvict = onEvict
+	}
+}
+
 var _ InMemoryCache[any] = (*InMemoryLRUCache[any])(nil)
 
 func NewInMemoryLRUCache[T any](opts ...InMemoryLRUCacheOpt[T]) (*InMemoryLRUCache[T], error) {
 	t := &InMemoryLRUCache[T]{
 		maxElements: defaultMaxCacheSize,
 		stopOnce:    &sync.Once{},
+		evictions:   &atomic.Uint64{},
 	}
 
 	for _, opt := range opts {
@@ -99,16 +142,22 @@ func NewInMemoryLRUCache[T any](opts ...InMemoryLRUCacheOpt[T]) (*InMemoryLRUCac
 		var (
 			reasonLabel string
 			entityLabel string
+			evictReason EvictReason
 		)
 		switch reason {
 		case theine.EVICTED:
 			reasonLabel = evictedLabel
+			evictReason = EvictLRU
+			t.evictions.Add(1)
 		case theine.EXPIRED:
 			reasonLabel = expiredLabel
+			evictReason = EvictTTL
 		case theine.REMOVED:
 			reasonLabel = removedLabel
+			evictReason = EvictManual
 		default:
 			reasonLabel = unspecifiedLabel
+			evictReason = EvictManual
 		}
 
 		if item, ok := any(value).(CacheItem); ok {
@@ -119,6 +168,10 @@ func NewInMemoryLRUCache[T any](opts ...InMemoryLRUCacheOpt[T]) (*InMemoryLRUCac
 
 		cacheItemCount.WithLabelValues(entityLabel).Dec()
 		cacheItemRemovedCount.WithLabelValues(entityLabel, reasonLabel).Inc()
+
+		if t.onEvict != nil {
+			t.onEvict(key, value, evictReason)
+		}
 	})
 
 	var err error
@@ -144,28 +197,82 @@ func (i InMemoryLRUCache[T]) Get(key string) T {
 // Note that ttl is truncated to one year to avoid misinterpreted as negative value.
 // Negative ttl are noop.
 func (i InMemoryLRUCache[T]) Set(key string, value T, ttl time.Duration) {
+	i.set(key, value, ttl)
+}
+
+// set stores the value like Set, and reports whether it was stored, i.e. whether its cost doesn't exceed the
+// max cache size.
+func (i InMemoryLRUCache[T]) set(key string, value T, ttl time.Duration) bool {
 	if ttl >= oneYear {
 		ttl = oneYear
 	}
-	i.client.SetWithTTL(key, value, 1, ttl)
+	cost := int64(1)
+	if i.costFunc != nil {
+		cost = max(i.costFunc(value), 1)
+	}
+	stored := i.client.SetWithTTL(key, value, cost, ttl)
 
 	if item, ok := any(value).(CacheItem); ok {
 		cacheItemCount.WithLabelValues(item.CacheEntityType()).Inc()
 	} else {
 		cacheItemCount.WithLabelValues(unspecifiedLabel).Inc()
 	}
+	return stored
 }
 
 func (i InMemoryLRUCache[T]) Delete(key string) {
 	i.client.Delete(key)
 }
 
+// Clear removes all the entries. The cache has no bulk removal, so the entries are deleted one at a time,
+// and the entries that are set concurrently may be kept.
+func (i InMemoryLRUCache[T]) Clear() {
+	var keys []string
+	i.client.Range(func(key string, _ T) bool {
+		keys = append(keys, key)
+		return true
+	})
+
+	for _, key := range keys {
+		i.client.Delete(key)
+	}
+}
+
+// Len returns the number of entries in the cache.
+func (i InMemoryLRUCache[T]) Len() int {
+	return i.client.Len()
+}
+
+// Evictions returns the number of entries that were evicted to make room for others since the cache was created.
+func (i InMemoryLRUCache[T]) Evictions() uint64 {
+	return i.evictions.Load()
+}
+
 func (i InMemoryLRUCache[T]) Stop() {
 	i.stopOnce.Do(func() {
 		i.client.Close()
 	})
 }
 
+// ErrCacheNotOperational is returned by ProbeInMemoryCache when the cache doesn't return what was stored in it.
+var ErrCacheNotOperational = errors.New("cache is not operational")
+
+// readinessProbes numbers the probes, so that concurrent probes of a cache don't use the same key.
+var readinessProbes atomic.Uint64
+
+// ProbeInMemoryCache stores the value in the cache under a key of its own and reads it back, and returns
+// ErrCacheNotOperational if it can't. The key is removed afterward. It is meant for readiness checks.
+func ProbeInMemoryCache[T comparable](cache InMemoryCache[T], value T) error {
+	key := "readiness_probe/" + strconv.FormatUint(readinessProbes.Add(1), 10)
+	cache.Set(key, value, time.Minute)
+	defer cache.Delete(key)
+
+	if cache.Get(key) != value {
+		return ErrCacheNotOperational
+	}
+	return nil
+}
+
 var (
 	_ CacheItem = (*ChangelogCacheEntry)(nil)
 	_ CacheItem = (*InvalidEntityCacheEntry)(nil)
@@ -235,9 +342,19 @@ func GetReadCacheKey(store, tuple string) string {
 // an unexpected structpb.Value kind was encountered.
 var ErrUnexpectedStructValue = errors.New("unexpected structpb value encountered")
 
+// ErrTooManyContextualTuples is returned when building the cache key of a Check with more contextual
+// tuples than CheckCacheKeyParams.MaxContextualTuples.
+var ErrTooManyContextualTuples = errors.New("too many contextual tuples")
+
 // writeValue writes value v to the writer w. An error
 // is returned only when the underlying writer returns
 // an error or an unexpected value kind is encountered.
+//
+// The encoding is canonical, so that two values are written the same way if and only if they are equal:
+// strings are quoted, lists are enclosed in brackets and structs in braces, and struct fields are sorted
+// at every level of nesting (see writeStruct). The order of list elements is kept, even for lists of
+// scalars, because it is meaningful to the conditions that index the list, so reordered lists are
+// written differently.
 func writeValue(w io.StringWriter, v *structpb.Value) (err error) {
 	switch val := v.GetKind().(type) {
 	case *structpb.Value_BoolValue:
@@ -245,12 +362,20 @@ func writeValue(w io.StringWriter, v *structpb.Value) (err error) {
 	case *structpb.Value_NullValue:
 		_, err = w.WriteString("null")
 	case *structpb.Value_StringValue:
-		_, err = w.WriteString(val.StringValue)
+		_, err = w.WriteString(strconv.Quote(val.StringValue))
 	case *structpb.Value_NumberValue:
-		_, err = w.WriteString(strconv.FormatFloat(val.NumberValue, 'f', -1, 64)) // -1 precision ensures we represent the 64-bit value with the maximum precision needed to represent it, see strconv#FormatFloat for more info.
+		number := val.NumberValue
+		if number == 0 {
+			number = 0 // -0 equals 0
+		}
+		_, err = w.WriteString(strconv.FormatFloat(number, 'f', -1, 64)) // -1 precision ensures we represent the 64-bit value with the maximum precision needed to represent it, see strconv#FormatFloat for more info.
 	case *structpb.Value_ListValue:
 		values := val.ListValue.GetValues()
 
+		if _, err = w.WriteString("["); err != nil {
+			return
+		}
+
 		for n, vv := range values {
 			if err = writeValue(w, vv); err != nil {
 				return
@@ -262,8 +387,18 @@ func writeValue(w io.StringWriter, v *structpb.Value) (err error) {
 				}
 			}
 		}
+
+		_, err = w.WriteString("]")
 	case *structpb.Value_StructValue:
-		err = writeStruct(w, val.StructValue)
+		if _, err = w.WriteString("{"); err != nil {
+			return
+		}
+
+		if err = writeStruct(w, val.StructValue); err != nil {
+			return
+		}
+
+		_, err = w.WriteString("}")
 	default:
 		err = ErrUnexpectedStructValue
 	}
@@ -297,7 +432,7 @@ func writeStruct(w io.StringWriter, s *structpb.Struct) (err error) {
 	sort.Strings(keys)
 
 	for _, key := range keys {
-		if _, err = w.WriteString(fmt.Sprintf("'%s:'", key)); err != nil {
+		if _, err = w.WriteString(strconv.Quote(key) + ":"); err != nil {
 			return
 		}
 
@@ -312,6 +447,13 @@ func writeStruct(w io.StringWriter, s *structpb.Struct) (err error) {
 	return
 }
 
+// WriteStructCacheKey writes the canonical encoding of s to w, the one of the Context of the Check cache
+// keys, so that two structs are written the same way if and only if they are equal. An error is returned
+// only when the underlying writer returns an error or an unexpected value kind is encountered.
+func WriteStructCacheKey(w io.StringWriter, s *structpb.Struct) error {
+	return writeStruct(w, s)
+}
+
 // writeTuples writes the set of tuples to writer w in ascending sorted order.
 // The intention of this function is to write the tuples as a unique string.
 // Tuples are separated by commas, and when present, conditions are included
@@ -383,6 +525,19 @@ type CheckCacheKeyParams struct {
 	TupleKey             *openfgav1.TupleKey
 	ContextualTuples     []*openfgav1.TupleKey
 	Context              *structpb.Struct
+	// ExcludedRelations are the 'objectType#relation' pairs treated as empty during resolution.
+	ExcludedRelations []string
+	// AssumedFacts are the subproblems treated as allowed during resolution.
+	AssumedFacts []*openfgav1.TupleKey
+	// Scoring identifies the rubric of a Check resolved in scoring mode, if any.
+	Scoring string
+	// WildcardPolicy identifies the wildcard policy of the Check, if it isn't the default.
+	WildcardPolicy string
+	// ModelDeltaFingerprint identifies the changes layered over the authorization model, if any.
+	ModelDeltaFingerprint string
+	// MaxContextualTuples is the maximum number of contextual tuples written to the cache key. 0 means
+	// no limit.
+	MaxContextualTuples int
 }
 
 // WriteCheckCacheKey converts the elements of a Check into a canonical cache key that can be
@@ -390,11 +545,10 @@ type CheckCacheKeyParams struct {
 //
 // For one store and model ID, the same tuple provided with the same contextual tuples and context
 // should produce the same cache key. Contextual tuple order and context parameter order is ignored,
-// only the contents are compared.
+// at every level of nesting of the context, only the contents are compared. The order of the
+// elements of the lists of the context is not ignored, see writeValue.
 func WriteCheckCacheKey(w io.StringWriter, params *CheckCacheKeyParams) error {
-	t := tuple.From(params.TupleKey)
-
-	_, err := w.WriteString(t.String())
+	_, err := w.WriteString(tuple.From(params.TupleKey).String())
 	if err != nil {
 		return err
 	}
@@ -407,6 +561,33 @@ func WriteCheckCacheKey(w io.StringWriter, params *CheckCacheKeyParams) error {
 	return nil
 }
 
+// CheckCacheKeyInvariant is the part of the cache key of a Check that doesn't depend on the tuple key of
+// the Check, i.e. everything written by WriteInvariantCheckCacheKey. Checks that share their store, model,
+// contextual tuples and context share it, so it can be built once for all of them.
+type CheckCacheKeyInvariant string
+
+// NewCheckCacheKeyInvariant builds the part of the cache key of a Check that doesn't depend on the
+// tuple key of the Check. The TupleKey of the params is ignored.
+func NewCheckCacheKeyInvariant(params *CheckCacheKeyParams) (CheckCacheKeyInvariant, error) {
+	var b strings.Builder
+	if err := WriteInvariantCheckCacheKey(&b, params); err != nil {
+		return "", err
+	}
+	return CheckCacheKeyInvariant(b.String()), nil
+}
+
+// WriteCheckCacheKeyWithInvariant writes the same cache key as WriteCheckCacheKey for the tuple key, given
+// the invariant part of the key that was built by NewCheckCacheKeyInvariant.
+func WriteCheckCacheKeyWithInvariant(w io.StringWriter, tupleKey *openfgav1.TupleKey, invariant CheckCacheKeyInvariant) error {
+	_, err := w.WriteString(tuple.From(tupleKey).String())
+	if err != nil {
+		return err
+	}
+
+	_, err = w.WriteString(string(invariant))
+	return err
+}
+
 func WriteInvariantCheckCacheKey(w io.StringWriter, params *CheckCacheKeyParams) error {
 	_, err := w.WriteString(
 		" " + // space to separate from user in the TupleCacheKey, where spaces cannot be present
@@ -419,6 +600,11 @@ func WriteInvariantCheckCacheKey(w io.StringWriter, params *CheckCacheKeyParams)
 		return err
 	}
 
+	// bound the sorting and hashing of the contextual tuples before doing any of it
+	if params.MaxContextualTuples > 0 && len(params.ContextualTuples) > params.MaxContextualTuples {
+		return ErrTooManyContextualTuples
+	}
+
 	// here, and for context below, avoid hashing if we don't need to
 	if len(params.ContextualTuples) > 0 {
 		if err = writeTuples(w, params.ContextualTuples...); err != nil {
@@ -432,5 +618,42 @@ func WriteInvariantCheckCacheKey(w io.StringWriter, params *CheckCacheKeyParams)
 		}
 	}
 
+	if len(params.ExcludedRelations) > 0 {
+		excluded := append([]string(nil), params.ExcludedRelations...)
+		sort.Strings(excluded)
+		if _, err = w.WriteString("/excluded:" + strings.Join(excluded, ",")); err != nil {
+			return err
+		}
+	}
+
+	if len(params.AssumedFacts) > 0 {
+		assumed := make([]string, 0, len(params.AssumedFacts))
+		for _, fact := range params.AssumedFacts {
+			assumed = append(assumed, tuple.TupleKeyToString(fact))
+		}
+		sort.Strings(assumed)
+		if _, err = w.WriteString("/assumed:" + strings.Join(assumed, ",")); err != nil {
+			return err
+		}
+	}
+
+	if params.Scoring != "" {
+		if _, err = w.WriteString("/scoring:" + params.Scoring); err != nil {
+			return err
+		}
+	}
+
+	if params.WildcardPolicy != "" {
+		if _, err = w.WriteString("/wildcard:" + params.WildcardPolicy); err != nil {
+			return err
+		}
+	}
+
+	if params.ModelDeltaFingerprint != "" {
+		if _, err = w.WriteString("/delta:" + params.ModelDeltaFingerprint); err != nil {
+			return err
+		}
+	}
+
 	return nil
 }
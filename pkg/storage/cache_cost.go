@@ -0,0 +1,57 @@
+package storage
+
+import (
+	"google.golang.org/protobuf/proto"
+)
+
+const (
+	// cacheEntryBaseCost is the estimated size in bytes of an entry of the cache besides its variable-size
+	// contents, including its key and the bookkeeping of the cache.
+	cacheEntryBaseCost = 256
+	// tupleRecordCost is the estimated size in bytes of a TupleRecord and of its pointer, besides its strings.
+	tupleRecordCost = 224
+	// stringHeaderCost is the size in bytes of the header of a string.
+	stringHeaderCost = 16
+)
+
+// CostedCacheItem is a value of the cache with variable-size contents, which it estimates the size of.
+type CostedCacheItem interface {
+	// CacheCost returns the estimated size in bytes of the variable-size contents of the value.
+	CacheCost() int64
+}
+
+var _ CostedCacheItem = (*TupleIteratorCacheEntry)(nil)
+
+// CacheEntryCost estimates the size in bytes of a value of the cache, to be used as its cost with WithCostFunc.
+// Every value costs a fixed base, and the values that implement CostedCacheItem additionally cost the estimated
+// size of their contents.
+func CacheEntryCost(value any) int64 {
+	if item, ok := value.(CostedCacheItem); ok {
+		return cacheEntryBaseCost + item.CacheCost()
+	}
+	return cacheEntryBaseCost
+}
+
+// CacheCost implements CostedCacheItem.
+func (t *TupleIteratorCacheEntry) CacheCost() int64 {
+	var cost int64
+	for _, record := range t.Tuples {
+		cost += tupleRecordCost + int64(
+			len(record.Store)+len(record.ObjectType)+len(record.ObjectID)+len(record.Relation)+len(record.User)+
+				len(record.UserObjectType)+len(record.UserObjectID)+len(record.UserRelation)+len(record.ConditionName)+
+				len(record.Ulid))
+		if record.ConditionContext != nil {
+			cost += int64(proto.Size(record.ConditionContext))
+		}
+	}
+	return cost
+}
+
+// StringsCacheCost estimates the size in bytes of the strings, for the CostedCacheItem values that hold them.
+func StringsCacheCost(values []string) int64 {
+	var cost int64
+	for _, value := range values {
+		cost += stringHeaderCost + int64(len(value))
+	}
+	return cost
+}
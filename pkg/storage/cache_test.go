@@ -6,13 +6,18 @@ import (
 	"fmt"
 	"io"
 	"math"
+	"slices"
+	"strconv"
 	"strings"
+	"sync"
 	"testing"
 	"time"
 
 	"github.com/oklog/ulid/v2"
 	"github.com/stretchr/testify/require"
 	"go.uber.org/goleak"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
 	"google.golang.org/protobuf/types/known/structpb"
 
 	openfgav1 "github.com/openfga/api/proto/openfga/v1"
@@ -98,7 +103,7 @@ func TestWriteValue(t *testing.T) {
 					})),
 				},
 			}),
-			output: "A,null,true,1111111111,'key:'value,",
+			output: `["A",null,true,1111111111,{"key":"value",}]`,
 		},
 		"list_write_value_error": {
 			writer: &ErrorStringWriter{},
@@ -117,7 +122,7 @@ func TestWriteValue(t *testing.T) {
 		},
 		"list_write_comma_error": {
 			writer: &ErrorStringWriter{
-				TriggerAt: 2,
+				TriggerAt: 3,
 			},
 			value: structpb.NewListValue(&structpb.ListValue{
 				Values: []*structpb.Value{
@@ -161,7 +166,7 @@ func TestWriteStruct(t *testing.T) {
 				"keyA": "valueA",
 				"keyB": "valueB",
 			}),
-			output: "'keyA:'valueA,'keyB:'valueB,",
+			output: `"keyA":"valueA","keyB":"valueB",`,
 		},
 		"fields_write_key_error": {
 			writer: &ErrorStringWriter{},
@@ -290,7 +295,7 @@ func TestWriteTuples(t *testing.T) {
 					}),
 				),
 			},
-			output: "/document:A#relationA with A 'key:'value,@user:A,document:A#relationA with B 'key:'value,@user:A",
+			output: `/document:A#relationA with A "key":"value",@user:A,document:A#relationA with B "key":"value",@user:A`,
 		},
 		"with_condition_write_with_error": {
 			writer: &ErrorStringWriter{
@@ -421,6 +426,147 @@ func TestInMemoryCache(t *testing.T) {
 		require.NotEqual(t, "value", result)
 	})
 
+	t.Run("len_and_evictions", func(t *testing.T) {
+		cache, err := NewInMemoryLRUCache[string](WithMaxCacheSize[string](10))
+		require.NoError(t, err)
+		t.Cleanup(func() {
+			goleak.VerifyNone(t)
+		})
+		defer cache.Stop()
+
+		require.Zero(t, cache.Len())
+		require.Zero(t, cache.Evictions())
+
+		for i := 0; i < 100; i++ {
+			cache.Set(strconv.Itoa(i), "value", time.Minute)
+		}
+
+		require.Eventually(t, func() bool {
+			return cache.Len() <= 10 && cache.Evictions() >= 90
+		}, time.Second, 10*time.Millisecond)
+	})
+
+	t.Run("cost_func", func(t *testing.T) {
+		cache, err := NewInMemoryLRUCache[string](
+			WithMaxCacheSize[string](100),
+			WithCostFunc(func(value string) int64 { return int64(len(value)) }),
+		)
+		require.NoError(t, err)
+		t.Cleanup(func() {
+			goleak.VerifyNone(t)
+		})
+		defer cache.Stop()
+
+		// the entries are evicted once their total cost, rather than their number, exceeds the max size
+		for i := 0; i < 10; i++ {
+			cache.Set(strconv.Itoa(i), strings.Repeat("v", 20), time.Minute)
+		}
+		require.Eventually(t, func() bool {
+			return cache.Len() <= 5 && cache.Evictions() >= 5
+		}, time.Second, 10*time.Millisecond)
+
+		// an entry that costs more than the max size isn't stored
+		cache.Set("large", strings.Repeat("v", 101), time.Minute)
+		require.Empty(t, cache.Get("large"))
+	})
+
+	t.Run("delete_and_clear", func(t *testing.T) {
+		cache, err := NewInMemoryLRUCache[string]()
+		require.NoError(t, err)
+		t.Cleanup(func() {
+			goleak.VerifyNone(t)
+		})
+		defer cache.Stop()
+
+		for i := 0; i < 10; i++ {
+			cache.Set(strconv.Itoa(i), "value", time.Minute)
+		}
+
+		cache.Delete("0")
+		require.Empty(t, cache.Get("0"))
+		require.Equal(t, "value", cache.Get("1"))
+
+		cache.Clear()
+		for i := 0; i < 10; i++ {
+			require.Empty(t, cache.Get(strconv.Itoa(i)))
+		}
+	})
+
+	t.Run("eviction_callback", func(t *testing.T) {
+		type eviction struct {
+			key    string
+			value  string
+			reason EvictReason
+		}
+
+		newCache := func(t *testing.T, maxSize int64) (*InMemoryLRUCache[string], func() []eviction) {
+			var (
+				mu        sync.Mutex
+				evictions []eviction
+			)
+			cache, err := NewInMemoryLRUCache[string](
+				WithMaxCacheSize[string](maxSize),
+				WithEvictionCallback(func(key string, value string, reason EvictReason) {
+					mu.Lock()
+					defer mu.Unlock()
+					evictions = append(evictions, eviction{key, value, reason})
+				}),
+			)
+			require.NoError(t, err)
+			t.Cleanup(cache.Stop)
+
+			return cache, func() []eviction {
+				mu.Lock()
+				defer mu.Unlock()
+				return slices.Clone(evictions)
+			}
+		}
+
+		t.Cleanup(func() {
+			goleak.VerifyNone(t)
+		})
+
+		t.Run("manual", func(t *testing.T) {
+			cache, evictions := newCache(t, 10)
+
+			// overwriting the entry doesn't call the callback
+			cache.Set("key", "value1", time.Minute)
+			cache.Set("key", "value2", time.Minute)
+			cache.Delete("key")
+
+			require.Eventually(t, func() bool {
+				return len(evictions()) > 0
+			}, time.Second, time.Millisecond)
+			require.Equal(t, []eviction{{"key", "value2", EvictManual}}, evictions())
+		})
+
+		t.Run("ttl", func(t *testing.T) {
+			cache, evictions := newCache(t, 10)
+
+			cache.Set("key", "value", time.Millisecond)
+
+			require.Eventually(t, func() bool {
+				return len(evictions()) > 0
+			}, 3*time.Second, time.Millisecond)
+			require.Equal(t, []eviction{{"key", "value", EvictTTL}}, evictions())
+		})
+
+		t.Run("lru", func(t *testing.T) {
+			cache, evictions := newCache(t, 10)
+
+			for i := 0; i < 100; i++ {
+				cache.Set(strconv.Itoa(i), "value", time.Minute)
+			}
+
+			require.Eventually(t, func() bool {
+				return uint64(len(evictions())) == cache.Evictions() && len(evictions()) >= 90
+			}, time.Second, 10*time.Millisecond)
+			for _, e := range evictions() {
+				require.Equal(t, EvictLRU, e.reason)
+			}
+		})
+	})
+
 	t.Run("stop_multiple_times", func(t *testing.T) {
 		cache, err := NewInMemoryLRUCache[string]()
 		require.NoError(t, err)
@@ -453,6 +599,47 @@ func TestInMemoryCache(t *testing.T) {
 	})
 }
 
+// discardingCache is an InMemoryCache that doesn't store anything.
+type discardingCache[T any] struct {
+	InMemoryCache[T]
+}
+
+func (discardingCache[T]) Set(string, T, time.Duration) {}
+
+func (discardingCache[T]) Get(string) T {
+	var zero T
+	return zero
+}
+
+func (discardingCache[T]) Delete(string) {}
+
+func TestProbeInMemoryCache(t *testing.T) {
+	t.Run("operational", func(t *testing.T) {
+		cache, err := NewInMemoryLRUCache[any]()
+		require.NoError(t, err)
+		t.Cleanup(func() {
+			goleak.VerifyNone(t)
+		})
+		defer cache.Stop()
+
+		cache.Set("key", "value", time.Minute)
+		require.NoError(t, ProbeInMemoryCache[any](cache, struct{}{}))
+
+		// the probe removes its own key only
+		require.Equal(t, "value", cache.Get("key"))
+		var keys []string
+		cache.client.Range(func(key string, _ any) bool {
+			keys = append(keys, key)
+			return true
+		})
+		require.Equal(t, []string{"key"}, keys)
+	})
+
+	t.Run("not_operational", func(t *testing.T) {
+		require.ErrorIs(t, ProbeInMemoryCache[any](discardingCache[any]{}, struct{}{}), ErrCacheNotOperational)
+	})
+}
+
 func MustGetCheckCacheKey(params *CheckCacheKeyParams) string {
 	w := &strings.Builder{}
 
@@ -503,6 +690,70 @@ func TestCheckCacheKeyDoNotOverlap(t *testing.T) {
 	require.NotEqual(t, key1, key3)
 }
 
+func TestCheckCacheKeyConsidersExcludedRelations(t *testing.T) {
+	storeID := ulid.Make().String()
+	modelID := ulid.Make().String()
+	tupleKey := tuple.NewTupleKey("document:x", "viewer", "user:jon")
+
+	keyWithout := MustGetCheckCacheKey(&CheckCacheKeyParams{
+		StoreID:              storeID,
+		AuthorizationModelID: modelID,
+		TupleKey:             tupleKey,
+	})
+
+	keyWith := MustGetCheckCacheKey(&CheckCacheKeyParams{
+		StoreID:              storeID,
+		AuthorizationModelID: modelID,
+		TupleKey:             tupleKey,
+		ExcludedRelations:    []string{"document#editor", "group#member"},
+	})
+
+	keyWithReordered := MustGetCheckCacheKey(&CheckCacheKeyParams{
+		StoreID:              storeID,
+		AuthorizationModelID: modelID,
+		TupleKey:             tupleKey,
+		ExcludedRelations:    []string{"group#member", "document#editor"},
+	})
+
+	require.NotEqual(t, keyWithout, keyWith)
+	require.Equal(t, keyWith, keyWithReordered)
+}
+
+func TestCheckCacheKeyConsidersAssumedFacts(t *testing.T) {
+	storeID := ulid.Make().String()
+	modelID := ulid.Make().String()
+	tupleKey := tuple.NewTupleKey("document:x", "viewer", "user:jon")
+
+	keyWithout := MustGetCheckCacheKey(&CheckCacheKeyParams{
+		StoreID:              storeID,
+		AuthorizationModelID: modelID,
+		TupleKey:             tupleKey,
+	})
+
+	keyWith := MustGetCheckCacheKey(&CheckCacheKeyParams{
+		StoreID:              storeID,
+		AuthorizationModelID: modelID,
+		TupleKey:             tupleKey,
+		AssumedFacts: []*openfgav1.TupleKey{
+			tuple.NewTupleKey("document:x", "editor", "user:jon"),
+			tuple.NewTupleKey("group:eng", "member", "user:jon"),
+		},
+	})
+
+	keyWithReordered := MustGetCheckCacheKey(&CheckCacheKeyParams{
+		StoreID:              storeID,
+		AuthorizationModelID: modelID,
+		TupleKey:             tupleKey,
+		AssumedFacts: []*openfgav1.TupleKey{
+			tuple.NewTupleKey("group:eng", "member", "user:jon"),
+			tuple.NewTupleKey("document:x", "editor", "user:jon"),
+		},
+	})
+
+	require.NotEqual(t, keyWithout, keyWith)
+	require.Equal(t, keyWith, keyWithReordered)
+}
+
 func TestCheckCacheKeyConsidersContextualTuples(t *testing.T) {
 	storeID := ulid.Make().String()
 	modelID := ulid.Make().String()
@@ -697,6 +948,93 @@ func TestCheckCacheKeyConditionContextOrderAgnostic(t *testing.T) {
 	require.Equal(t, key1, key2)
 }
 
+func TestCheckCacheKeyContextCanonicalization(t *testing.T) {
+	storeID := ulid.Make().String()
+	modelID := ulid.Make().String()
+	tupleKey := tuple.NewTupleKey("document:x", "viewer", "user:jon")
+
+	cacheKey := func(t *testing.T, context string) string {
+		s := &structpb.Struct{}
+		require.NoError(t, protojson.Unmarshal([]byte(context), s))
+
+		return MustGetCheckCacheKey(&CheckCacheKeyParams{
+			StoreID:              storeID,
+			AuthorizationModelID: modelID,
+			TupleKey:             tupleKey,
+			Context:              s,
+		})
+	}
+
+	var cases = map[string]struct {
+		context1 string
+		context2 string
+		equal    bool
+	}{
+		"reordered_keys": {
+			context1: `{"a": 1, "b": "x", "c": true}`,
+			context2: `{"c": true, "b": "x", "a": 1}`,
+			equal:    true,
+		},
+		"reordered_nested_maps": {
+			context1: `{"outer": {"a": {"x": 1, "y": [1, 2]}, "b": null}}`,
+			context2: `{"outer": {"b": null, "a": {"y": [1, 2], "x": 1}}}`,
+			equal:    true,
+		},
+		"reordered_keys_of_maps_in_lists": {
+			context1: `{"list": [{"a": 1, "b": 2}, {"c": ["x", "y"], "d": {}}]}`,
+			context2: `{"list": [{"b": 2, "a": 1}, {"d": {}, "c": ["x", "y"]}]}`,
+			equal:    true,
+		},
+		"negative_zero": {
+			context1: `{"a": 0}`,
+			context2: `{"a": -0}`,
+			equal:    true,
+		},
+		"reordered_lists_of_scalars": {
+			context1: `{"list": ["x", "y"]}`,
+			context2: `{"list": ["y", "x"]}`,
+			equal:    false,
+		},
+		"reordered_nested_lists": {
+			context1: `{"outer": {"list": [[1, 2], [3]]}}`,
+			context2: `{"outer": {"list": [[3], [1, 2]]}}`,
+			equal:    false,
+		},
+		"list_and_string_with_separator": {
+			context1: `{"a": ["x", "y"]}`,
+			context2: `{"a": "x,y"}`,
+			equal:    false,
+		},
+		"nested_lists_and_flat_list": {
+			context1: `{"a": [["x"], "y"]}`,
+			context2: `{"a": ["x", "y"]}`,
+			equal:    false,
+		},
+		"string_and_number": {
+			context1: `{"a": "1"}`,
+			context2: `{"a": 1}`,
+			equal:    false,
+		},
+		"map_and_key_with_separators": {
+			context1: `{"a": {"b": 1}}`,
+			context2: `{"a": "b:1"}`,
+			equal:    false,
+		},
+	}
+
+	for name, test := range cases {
+		t.Run(name, func(t *testing.T) {
+			key1 := cacheKey(t, test.context1)
+			key2 := cacheKey(t, test.context2)
+			if test.equal {
+				require.Equal(t, key1, key2)
+			} else {
+				require.NotEqual(t, key1, key2)
+			}
+		})
+	}
+}
+
 func TestCheckCacheKeyContextualTuplesConditionsOrderDoesNotMatter(t *testing.T) {
 	storeID := ulid.Make().String()
 	modelID := ulid.Make().String()
@@ -814,7 +1152,7 @@ func TestWriteInvariantCheckCacheKey(t *testing.T) {
 				},
 				Context: contextStruct,
 			},
-			output: " sp.fake_store_id/fake_model_id/document:1#viewer with condition_name 'key1:'true,@user:anne'key1:'true,",
+			output: ` sp.fake_store_id/fake_model_id/document:1#viewer with condition_name "key1":true,@user:anne"key1":true,`,
 			error:  false,
 		},
 		"writer_error": {
@@ -873,7 +1211,19 @@ func TestWriteCheckCacheKey(t *testing.T) {
 				},
 				Context: contextStruct,
 			},
-			output: "document:1#can_view@user:anne sp.fake_store_id/fake_model_id/document:1#viewer with condition_name 'key1:'true,@user:anne'key1:'true,",
+			output: `document:1#can_view@user:anne sp.fake_store_id/fake_model_id/document:1#viewer with condition_name "key1":true,@user:anne"key1":true,`,
+		},
+		"errors_if_too_many_contextual_tuples": {
+			writer: &strings.Builder{},
+			params: &CheckCacheKeyParams{
+				TupleKey: tuple.NewTupleKey("document:1", "can_view", "user:anne"),
+				ContextualTuples: []*openfgav1.TupleKey{
+					tuple.NewTupleKey("document:1", "viewer", "user:anne"),
+					tuple.NewTupleKey("document:2", "viewer", "user:anne"),
+				},
+				MaxContextualTuples: 1,
+			},
+			error: true,
 		},
 	}
 	for name, test := range cases {
@@ -889,6 +1239,42 @@ func TestWriteCheckCacheKey(t *testing.T) {
 	}
 }
 
+func TestWriteCheckCacheKeyWithInvariant(t *testing.T) {
+	contextStruct, err := structpb.NewStruct(map[string]interface{}{"key1": true})
+	require.NoError(t, err)
+
+	params := &CheckCacheKeyParams{
+		AuthorizationModelID: "fake_model_id",
+		StoreID:              "fake_store_id",
+		ContextualTuples: []*openfgav1.TupleKey{
+			tuple.NewTupleKeyWithCondition("document:1", "viewer", "user:anne", "condition_name", contextStruct),
+		},
+		Context: contextStruct,
+	}
+
+	invariant, err := NewCheckCacheKeyInvariant(params)
+	require.NoError(t, err)
+
+	for _, tk := range []*openfgav1.TupleKey{
+		tuple.NewTupleKey("document:1", "can_view", "user:anne"),
+		tuple.NewTupleKey("document:2", "viewer", "group:eng#member"),
+	} {
+		params.TupleKey = tk
+
+		var expected strings.Builder
+		require.NoError(t, WriteCheckCacheKey(&expected, params))
+
+		var actual strings.Builder
+		require.NoError(t, WriteCheckCacheKeyWithInvariant(&actual, tk, invariant))
+		require.Equal(t, expected.String(), actual.String())
+	}
+
+	t.Run("errors_if_write_fails", func(t *testing.T) {
+		err := WriteCheckCacheKeyWithInvariant(&ErrorStringWriter{TriggerAt: 1}, params.TupleKey, invariant)
+		require.Error(t, err)
+	})
+}
+
 func BenchmarkWriteCheckCacheKey(b *testing.B) {
 	var err error
 	writer := &strings.Builder{}
@@ -998,3 +1384,28 @@ func BenchmarkGetInvalidIteratorByUserObjectTypeCacheKeys(b *testing.B) {
 		_ = GetInvalidIteratorByUserObjectTypeCacheKeys(storeID, users, objectType)
 	}
 }
+
+func TestCacheEntryCost(t *testing.T) {
+	require.Equal(t, int64(cacheEntryBaseCost), CacheEntryCost(&ChangelogCacheEntry{}))
+	require.Equal(t, int64(cacheEntryBaseCost), CacheEntryCost(&TupleIteratorCacheEntry{}))
+
+	contextStruct, err := structpb.NewStruct(map[string]any{"ip": "127.0.0.1"})
+	require.NoError(t, err)
+
+	record := &TupleRecord{
+		ObjectType:       "document",
+		ObjectID:         "1",
+		Relation:         "viewer",
+		UserObjectType:   "user",
+		UserObjectID:     "anne",
+		ConditionName:    "in_network",
+		ConditionContext: contextStruct,
+	}
+	entry := &TupleIteratorCacheEntry{Tuples: []*TupleRecord{record, record}}
+
+	// the tuples are costed by their size
+	recordCost := int64(tupleRecordCost + len("document"+"1"+"viewer"+"user"+"anne"+"in_network") + proto.Size(contextStruct))
+	require.Equal(t, cacheEntryBaseCost+2*recordCost, CacheEntryCost(entry))
+
+	require.Equal(t, int64(2*stringHeaderCost+len("document:1document:2")), StringsCacheCost([]string{"document:1", "document:2"}))
+}
@@ -0,0 +1,90 @@
+package storage
+
+import (
+	"context"
+	"time"
+)
+
+// CheckCache caches the responses of the Check sub-problems, keyed by the cache keys that the check resolver
+// builds for them. It is the interface through which the check resolver reads and writes its cache, so that
+// the cache may live outside the process, e.g. in Redis or memcached, and be shared by the servers of a
+// deployment. InMemoryCheckCache is the in-memory implementation.
+//
+// The errors of an implementation are never returned to the callers of Check: a failed Get is treated as a
+// cache miss and a failed Set or Delete only loses the entry, and all of them are logged. Implementations
+// should therefore bound the time spent on each call, e.g. with a timeout of their own, since a slow cache
+// slows down every Check sub-problem.
+//
+// The values are the pointers to the cache entries of the check resolver, e.g. *graph.CheckResponseCacheEntry,
+// which are registered with encoding/gob. An implementation backed by an external store may encode them with
+// gob as an interface value and decode them back into an interface value:
+//
+//	func (c *RedisCheckCache) Set(ctx context.Context, key string, value any, ttl time.Duration) error {
+//		var buf bytes.Buffer
+//		if err := gob.NewEncoder(&buf).Encode(&value); err != nil {
+//			return err
+//		}
+//		return c.client.Set(ctx, c.prefix+key, buf.Bytes(), ttl).Err()
+//	}
+//
+//	func (c *RedisCheckCache) Get(ctx context.Context, key string) (any, error) {
+//		data, err := c.client.Get(ctx, c.prefix+key).Bytes()
+//		if errors.Is(err, redis.Nil) {
+//			return nil, nil
+//		}
+//		if err != nil {
+//			return nil, err
+//		}
+//		var value any
+//		if err := gob.NewDecoder(bytes.NewReader(data)).Decode(&value); err != nil {
+//			return nil, err
+//		}
+//		return value, nil
+//	}
+//
+// The entries carry the time at which they were cached and are compared against the invalidation time of
+// the store on every read, so the entries cached by another server are never served past a Write that
+// invalidated them. Implementations must be safe for concurrent use.
+type CheckCache interface {
+	// Get returns the value of the key, or nil if the key doesn't exist or has expired.
+	Get(ctx context.Context, key string) (any, error)
+	// Set stores the value under the key for the ttl.
+	Set(ctx context.Context, key string, value any, ttl time.Duration) error
+	// Delete removes the key, if it exists.
+	Delete(ctx context.Context, key string) error
+}
+
+var _ CheckCache = (*InMemoryCheckCache)(nil)
+
+// InMemoryCheckCache is a CheckCache that keeps the entries in an InMemoryCache, e.g. the cache shared by the
+// check resolvers and the iterator cache of a server. It never fails.
+type InMemoryCheckCache struct {
+	cache InMemoryCache[any]
+}
+
+// NewInMemoryCheckCache returns a CheckCache that keeps the entries in the cache.
+func NewInMemoryCheckCache(cache InMemoryCache[any]) *InMemoryCheckCache {
+	return &InMemoryCheckCache{cache: cache}
+}
+
+// Get see [CheckCache].Get.
+func (c *InMemoryCheckCache) Get(_ context.Context, key string) (any, error) {
+	return c.cache.Get(key), nil
+}
+
+// Set see [CheckCache].Set.
+func (c *InMemoryCheckCache) Set(_ context.Context, key string, value any, ttl time.Duration) error {
+	c.cache.Set(key, value, ttl)
+	return nil
+}
+
+// Delete see [CheckCache].Delete.
+func (c *InMemoryCheckCache) Delete(_ context.Context, key string) error {
+	c.cache.Delete(key)
+	return nil
+}
+
+// Unwrap returns the InMemoryCache that holds the entries.
+func (c *InMemoryCheckCache) Unwrap() InMemoryCache[any] {
+	return c.cache
+}
@@ -3,6 +3,7 @@ package storage
 import (
 	"errors"
 	"fmt"
+	"time"
 
 	openfgav1 "github.com/openfga/api/proto/openfga/v1"
 
@@ -37,8 +38,38 @@ var (
 
 	// ErrNotFound is returned when the object does not exist.
 	ErrNotFound = errors.New("not found")
+
+	// ErrBestEffortWriteNotSupported is returned when writing tuples without a transaction with a datastore
+	// that doesn't support it.
+	ErrBestEffortWriteNotSupported = errors.New("best effort writes are not supported by the datastore")
+
+	// ErrChangelogCompacted is returned when reading changes from a point in the changelog
+	// that has been removed by a compaction.
+	ErrChangelogCompacted = errors.New("changes before the changelog compaction horizon are no longer available")
+
+	// ErrCircuitOpen is returned when an operation isn't sent to the datastore because its circuit breaker is
+	// open, see CircuitOpenError.
+	ErrCircuitOpen = errors.New("datastore circuit breaker is open")
 )
 
+// CircuitOpenError is returned when an operation fails fast because the circuit breaker of the datastore is
+// open after too many failures. It matches ErrCircuitOpen with errors.Is.
+type CircuitOpenError struct {
+	// Breaker is the name of the breaker that is open, e.g. "read" or "write".
+	Breaker string
+	// RetryAfter is the time left before the breaker lets an operation through again.
+	RetryAfter time.Duration
+}
+
+func (e *CircuitOpenError) Error() string {
+	return fmt.Sprintf("%s: %s operations fail fast for %s", ErrCircuitOpen, e.Breaker, e.RetryAfter)
+}
+
+// Is reports whether the target is ErrCircuitOpen.
+func (e *CircuitOpenError) Is(target error) bool {
+	return target == ErrCircuitOpen
+}
+
 // InvalidWriteInputError generates an error for invalid operations in a tuple store.
 // This function is invoked when an attempt is made to write or delete a tuple with invalid conditions.
 // Specifically, it addresses two scenarios:
@@ -130,6 +130,8 @@ type MemoryBackend struct {
 	// ChangelogBackend
 	// map: store => set of changes
 	changes map[string][]*tupleChangeRec // GUARDED_BY(mutexTuples).
+	// map: store => ulid before which changes have been compacted
+	changelogHorizons map[string]ulid.ULID // GUARDED_BY(mutexTuples).
 
 	// AuthorizationModelBackend
 	// map: store = > map: type definition id => type definition
@@ -137,8 +139,10 @@ type MemoryBackend struct {
 	mutexModels         sync.RWMutex
 
 	// map: store id => store data
-	stores      map[string]*openfgav1.Store // GUARDED_BY(mutexStores).
-	mutexStores sync.RWMutex
+	stores map[string]*openfgav1.Store // GUARDED_BY(mutexStores).
+	// map: store id => store metadata
+	storeMetadata map[string]*storage.StoreMetadata // GUARDED_BY(mutexStores).
+	mutexStores   sync.RWMutex
 
 	// map: store id | authz model id => assertions
 	assertions      map[string][]*openfgav1.Assertion // GUARDED_BY(mutexAssertions).
@@ -147,6 +151,7 @@ type MemoryBackend struct {
 
 // Ensures that [MemoryBackend] implements the [storage.OpenFGADatastore] interface.
 var _ storage.OpenFGADatastore = (*MemoryBackend)(nil)
+var _ storage.BestEffortTupleWriter = (*MemoryBackend)(nil)
 
 // AuthorizationModelEntry represents an entry in a storage system
 // that holds information about an authorization model.
@@ -162,8 +167,10 @@ func New(opts ...StorageOption) storage.OpenFGADatastore {
 		maxTypesPerAuthorizationModel: defaultMaxTypesPerAuthorizationModel,
 		tuples:                        make(map[string][]*storage.TupleRecord, 0),
 		changes:                       make(map[string][]*tupleChangeRec, 0),
+		changelogHorizons:             make(map[string]ulid.ULID, 0),
 		authorizationModels:           make(map[string]map[string]*AuthorizationModelEntry),
 		stores:                        make(map[string]*openfgav1.Store, 0),
+		storeMetadata:                 make(map[string]*storage.StoreMetadata, 0),
 		assertions:                    make(map[string][]*openfgav1.Assertion, 0),
 	}
 
@@ -227,6 +234,10 @@ func (s *MemoryBackend) ReadChanges(ctx context.Context, store string, filter st
 			return nil, "", storage.ErrInvalidContinuationToken
 		}
 		from = &parsed
+
+		if horizon, ok := s.changelogHorizons[store]; ok && parsed.Compare(horizon) < 0 {
+			return nil, "", storage.ErrChangelogCompacted
+		}
 	}
 
 	objectType := filter.ObjectType
@@ -280,6 +291,30 @@ func (s *MemoryBackend) ReadChanges(ctx context.Context, store string, filter st
 	return res, last.String(), nil
 }
 
+// CompactChangeLog see [storage.ChangelogBackend].CompactChangeLog.
+func (s *MemoryBackend) CompactChangeLog(ctx context.Context, store string, olderThan time.Time) error {
+	_, span := tracer.Start(ctx, "memory.CompactChangeLog")
+	defer span.End()
+
+	horizon, err := ulid.New(ulid.Timestamp(olderThan), nil)
+	if err != nil {
+		return storage.ErrInvalidStartTime
+	}
+
+	s.mutexTuples.Lock()
+	defer s.mutexTuples.Unlock()
+
+	s.changes[store] = slices.DeleteFunc(s.changes[store], func(rec *tupleChangeRec) bool {
+		return rec.Ulid.Compare(horizon) < 0
+	})
+
+	if current, ok := s.changelogHorizons[store]; !ok || current.Compare(horizon) < 0 {
+		s.changelogHorizons[store] = horizon
+	}
+
+	return nil
+}
+
 // read returns an iterator of a store's tuples with a given tuple as filter.
 // A nil paginationOptions input means the returned iterator will iterate through all values.
 func (s *MemoryBackend) read(ctx context.Context, store string, tk *openfgav1.TupleKey, options *storage.ReadPageOptions) (*staticIterator, error) {
@@ -341,7 +376,8 @@ func (s *MemoryBackend) Write(ctx context.Context, store string, deletes storage
 
 	now := timestamppb.Now()
 
-	duplicateDeletes, _, err := sanitizeTuplesWriteDelete(s.tuples[store], deletes, writes, storage.NewTupleWriteOptions(opts...))
+	writeOpts := storage.NewTupleWriteOptions(opts...)
+	duplicateDeletes, _, err := sanitizeTuplesWriteDelete(s.tuples[store], deletes, writes, writeOpts)
 	if err != nil {
 		return err
 	}
@@ -377,8 +413,13 @@ Delete:
 
 Write:
 	for _, t := range writes {
-		for _, et := range records {
+		for i, et := range records {
 			if match(et, t) {
+				if writeOpts.OnDuplicateInsert == storage.OnDuplicateInsertUpsert && !sameCondition(et, t) {
+					// replace the existing tuple
+					records = slices.Delete(records, i, i+1)
+					break
+				}
 				// notice we don't need to assert for duplicateWrites because the fact that we match,
 				// and it satisfies sanitizeTuplesWriteDelete means that it is a valid duplicate write.
 				continue Write
@@ -427,6 +468,23 @@ Write:
 	return nil
 }
 
+// WriteBestEffort see [storage.BestEffortTupleWriter].WriteBestEffort.
+func (s *MemoryBackend) WriteBestEffort(ctx context.Context, store string, writes storage.Writes) ([]storage.TupleWriteResult, error) {
+	ctx, span := tracer.Start(ctx, "memory.WriteBestEffort")
+	defer span.End()
+
+	results := make([]storage.TupleWriteResult, 0, len(writes))
+	for _, tk := range writes {
+		if err := ctx.Err(); err != nil {
+			return results, err
+		}
+
+		err := s.Write(ctx, store, nil, storage.Writes{tk})
+		results = append(results, storage.NewTupleWriteResult(tk, err))
+	}
+	return results, nil
+}
+
 func sanitizeTuplesWriteDelete(
 	records []*storage.TupleRecord,
 	deletes []*openfgav1.TupleKeyWithoutCondition,
@@ -447,13 +505,19 @@ func sanitizeTuplesWriteDelete(
 	for i, tk := range writes {
 		record := find(records, tk)
 		if record != nil {
-			if opts.OnDuplicateInsert == storage.OnDuplicateInsertIgnore {
+			switch opts.OnDuplicateInsert {
+			case storage.OnDuplicateInsertIgnore:
 				// need to validate against condition and context
-				if record.ConditionName == tk.GetCondition().GetName() && record.ConditionContext.String() == tk.GetCondition().GetContext().String() {
+				if sameCondition(record, tk) {
 					duplicateWrites = append(duplicateWrites, i)
 					continue
 				}
 				return nil, nil, storage.TupleConditionConflictError(tk)
+			case storage.OnDuplicateInsertUpsert:
+				if sameCondition(record, tk) {
+					duplicateWrites = append(duplicateWrites, i)
+				}
+				continue
 			}
 			return nil, nil, storage.InvalidWriteInputError(tk, openfgav1.TupleOperation_TUPLE_OPERATION_WRITE)
 		}
@@ -461,6 +525,11 @@ func sanitizeTuplesWriteDelete(
 	return duplicateDeletes, duplicateWrites, nil
 }
 
+// sameCondition returns true if the record and the tuple key have the same condition and condition context.
+func sameCondition(record *storage.TupleRecord, tk *openfgav1.TupleKey) bool {
+	return record.ConditionName == tk.GetCondition().GetName() && record.ConditionContext.String() == tk.GetCondition().GetContext().String()
+}
+
 // find returns tuple if *storage.TupleRecord [*storage.TupleRecord] returns true.
 // Return nil otherwise.
 func find(records []*storage.TupleRecord, tupleKey *openfgav1.TupleKey) *storage.TupleRecord {
@@ -518,9 +587,10 @@ func (s *MemoryBackend) ReadUsersetTuples(
 			userType := tupleUtils.GetType(t.User)
 			_, userRelation := tupleUtils.SplitObjectRelation(t.User)
 			for _, allowedType := range filter.AllowedUserTypeRestrictions {
+				// the references may differ by their condition only, the tuple is returned once
 				if allowedType.GetType() == userType && allowedType.GetRelation() == userRelation {
 					matches = append(matches, t)
-					continue
+					break
 				}
 			}
 		}
@@ -530,50 +600,26 @@ func (s *MemoryBackend) ReadUsersetTuples(
 }
 
 // ReadStartingWithUser see [storage.RelationshipTupleReader].ReadStartingWithUser.
+// The tuples are read in pages of [storage.DefaultPageSize] as the iterator is consumed, so
+// a scan observes the writes that happen while it is in progress as described in [ReadStartingWithUserPage].
 func (s *MemoryBackend) ReadStartingWithUser(
 	ctx context.Context,
 	store string,
 	filter storage.ReadStartingWithUserFilter,
-	options storage.ReadStartingWithUserOptions,
+	_ storage.ReadStartingWithUserOptions,
 ) (storage.TupleIterator, error) {
 	_, span := tracer.Start(ctx, "memory.ReadStartingWithUser")
 	defer span.End()
 
-	s.mutexTuples.RLock()
-	defer s.mutexTuples.RUnlock()
-
-	var matches []*storage.TupleRecord
-	for _, t := range s.tuples[store] {
-		if t.ObjectType != filter.ObjectType {
-			continue
-		}
-
-		if t.Relation != filter.Relation {
-			continue
-		}
-
-		if filter.ObjectIDs != nil && !filter.ObjectIDs.Exists(t.ObjectID) {
-			continue
-		}
-
-		for _, userFilter := range filter.UserFilter {
-			targetUser := userFilter.GetObject()
-			if userFilter.GetRelation() != "" {
-				targetUser = tupleUtils.GetObjectRelationAsString(userFilter)
-			}
-
-			if targetUser != t.User {
-				continue
-			}
-
-			matches = append(matches, t)
-		}
-	}
-	sort.Slice(matches, func(i, j int) bool {
-		return matches[i].ObjectID < matches[j].ObjectID
-	})
-
-	return &staticIterator{records: matches}, nil
+	matcher := newStartingWithUserMatcher(filter)
+	records, next := s.readStartingWithUser(store, matcher, nil, storage.DefaultPageSize)
+	return &startingWithUserIterator{
+		backend: s,
+		store:   store,
+		matcher: matcher,
+		records: records,
+		next:    next,
+	}, nil
 }
 
 func findAuthorizationModelByID(
@@ -748,13 +794,62 @@ func (s *MemoryBackend) DeleteStore(ctx context.Context, id string) error {
 	_, span := tracer.Start(ctx, "memory.DeleteStore")
 	defer span.End()
 
+	s.mutexStores.Lock()
+	delete(s.stores, id)
+	delete(s.storeMetadata, id)
+	s.mutexStores.Unlock()
+
+	// the changelog compaction horizon of the store isn't kept once it's deleted
+	s.mutexTuples.Lock()
+	delete(s.changelogHorizons, id)
+	s.mutexTuples.Unlock()
+	return nil
+}
+
+// WriteStoreMetadata see [storage.StoresBackend].WriteStoreMetadata.
+func (s *MemoryBackend) WriteStoreMetadata(ctx context.Context, store string, metadata *storage.StoreMetadata) error {
+	_, span := tracer.Start(ctx, "memory.WriteStoreMetadata")
+	defer span.End()
+
 	s.mutexStores.Lock()
 	defer s.mutexStores.Unlock()
 
-	delete(s.stores, id)
+	if s.stores[store] == nil {
+		return storage.ErrNotFound
+	}
+
+	s.storeMetadata[store] = &storage.StoreMetadata{
+		ObjectTypeAllowlist:   slices.Clone(metadata.GetObjectTypeAllowlist()),
+		CycleGuardedRelations: slices.Clone(metadata.GetCycleGuardedRelations()),
+		DefaultConsistency:    metadata.GetDefaultConsistency(),
+	}
 	return nil
 }
 
+// ReadStoreMetadata see [storage.StoresBackend].ReadStoreMetadata.
+func (s *MemoryBackend) ReadStoreMetadata(ctx context.Context, store string) (*storage.StoreMetadata, error) {
+	_, span := tracer.Start(ctx, "memory.ReadStoreMetadata")
+	defer span.End()
+
+	s.mutexStores.RLock()
+	defer s.mutexStores.RUnlock()
+
+	if s.stores[store] == nil {
+		return nil, storage.ErrNotFound
+	}
+
+	metadata, ok := s.storeMetadata[store]
+	if !ok {
+		return &storage.StoreMetadata{}, nil
+	}
+
+	return &storage.StoreMetadata{
+		ObjectTypeAllowlist:   slices.Clone(metadata.ObjectTypeAllowlist),
+		CycleGuardedRelations: slices.Clone(metadata.CycleGuardedRelations),
+		DefaultConsistency:    metadata.DefaultConsistency,
+	}, nil
+}
+
 // WriteAssertions see [storage.AssertionsBackend].WriteAssertions.
 func (s *MemoryBackend) WriteAssertions(ctx context.Context, store, modelID string, assertions []*openfgav1.Assertion) error {
 	_, span := tracer.Start(ctx, "memory.WriteAssertions")
@@ -845,27 +940,34 @@ func (s *MemoryBackend) ListStores(ctx context.Context, options storage.ListStor
 		stores = filteredStores
 	}
 
+	if options.NameContains != "" {
+		filteredStores := make([]*openfgav1.Store, 0, len(stores))
+		for _, store := range stores {
+			if strings.Contains(store.GetName(), options.NameContains) {
+				filteredStores = append(filteredStores, store)
+			}
+		}
+		stores = filteredStores
+	}
+
 	// From oldest to newest.
 	sort.SliceStable(stores, func(i, j int) bool {
 		return stores[i].GetId() < stores[j].GetId()
 	})
 
-	var err error
-	var from int64
+	// the continuation token is the ID of the first store of the next page, like in the SQL datastores, so that
+	// the stores created while paginating don't shift the pages
+	from := 0
 	if options.Pagination.From != "" {
-		from, err = strconv.ParseInt(options.Pagination.From, 10, 32)
-		if err != nil {
-			return nil, "", err
-		}
+		from = sort.Search(len(stores), func(i int) bool {
+			return stores[i].GetId() >= options.Pagination.From
+		})
 	}
 	pageSize := storage.DefaultPageSize
 	if options.Pagination.PageSize > 0 {
 		pageSize = options.Pagination.PageSize
 	}
-	to := int(from) + pageSize
-	if len(stores) < to {
-		to = len(stores)
-	}
+	to := min(from+pageSize, len(stores))
 	res := stores[from:to]
 	if len(res) == 0 {
 		return nil, "", nil
@@ -873,7 +975,7 @@ func (s *MemoryBackend) ListStores(ctx context.Context, options storage.ListStor
 
 	continuationToken := ""
 	if to != len(stores) {
-		continuationToken = strconv.Itoa(to)
+		continuationToken = stores[to].GetId()
 	}
 
 	return res, continuationToken, nil
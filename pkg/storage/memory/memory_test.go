@@ -2,6 +2,7 @@ package memory
 
 import (
 	"context"
+	"errors"
 	"strconv"
 	"testing"
 	"time"
@@ -349,3 +350,149 @@ func TestFindTupleKey(t *testing.T) {
 		})
 	}
 }
+
+func TestReadStartingWithUserPage(t *testing.T) {
+	ctx := context.Background()
+	store := ulid.Make().String()
+
+	ds := New()
+	t.Cleanup(ds.Close)
+	backend := ds.(*MemoryBackend)
+
+	err := ds.Write(ctx, store, nil, []*openfgav1.TupleKey{
+		tuple.NewTupleKey("document:3", "viewer", "user:anne"),
+		tuple.NewTupleKey("document:1", "viewer", "group:eng#member"),
+		tuple.NewTupleKey("document:1", "viewer", "user:anne"),
+		tuple.NewTupleKey("document:4", "viewer", "user:anne"),
+		tuple.NewTupleKey("document:2", "editor", "user:anne"),
+		tuple.NewTupleKey("document:2", "viewer", "user:bob"),
+	})
+	require.NoError(t, err)
+
+	filter := storage.ReadStartingWithUserFilter{
+		ObjectType: "document",
+		Relation:   "viewer",
+		UserFilter: []*openfgav1.ObjectRelation{
+			{Object: "user:anne"},
+			{Object: "group:eng", Relation: "member"},
+		},
+	}
+	readPage := func(t *testing.T, from string) ([]string, string) {
+		tuples, contToken, err := backend.ReadStartingWithUserPage(ctx, store, filter, storage.ReadStartingWithUserPageOptions{
+			Pagination: storage.NewPaginationOptions(2, from),
+		})
+		require.NoError(t, err)
+
+		var keys []string
+		for _, tp := range tuples {
+			keys = append(keys, tuple.TupleKeyToString(tp.GetKey()))
+		}
+		return keys, contToken
+	}
+
+	t.Run("pages", func(t *testing.T) {
+		keys, contToken := readPage(t, "")
+		require.Equal(t, []string{"document:1#viewer@group:eng#member", "document:1#viewer@user:anne"}, keys)
+		require.NotEmpty(t, contToken)
+
+		keys, contToken = readPage(t, contToken)
+		require.Equal(t, []string{"document:3#viewer@user:anne", "document:4#viewer@user:anne"}, keys)
+		require.Empty(t, contToken)
+	})
+
+	t.Run("stable_under_concurrent_writes", func(t *testing.T) {
+		keys, contToken := readPage(t, "")
+		require.Equal(t, []string{"document:1#viewer@group:eng#member", "document:1#viewer@user:anne"}, keys)
+
+		// a tuple before the position of the scan is not returned, one after it is, and deleting
+		// a tuple that was read doesn't shift the remaining ones
+		err := ds.Write(ctx, store,
+			[]*openfgav1.TupleKeyWithoutCondition{tuple.TupleKeyToTupleKeyWithoutCondition(tuple.NewTupleKey("document:1", "viewer", "user:anne"))},
+			[]*openfgav1.TupleKey{
+				tuple.NewTupleKey("document:0", "viewer", "user:anne"),
+				tuple.NewTupleKey("document:2", "viewer", "user:anne"),
+			})
+		require.NoError(t, err)
+
+		keys, contToken = readPage(t, contToken)
+		require.Equal(t, []string{"document:2#viewer@user:anne", "document:3#viewer@user:anne"}, keys)
+		require.NotEmpty(t, contToken)
+
+		keys, contToken = readPage(t, contToken)
+		require.Equal(t, []string{"document:4#viewer@user:anne"}, keys)
+		require.Empty(t, contToken)
+	})
+
+	t.Run("invalid_continuation_token", func(t *testing.T) {
+		_, _, err := backend.ReadStartingWithUserPage(ctx, store, filter, storage.ReadStartingWithUserPageOptions{
+			Pagination: storage.NewPaginationOptions(2, "invalid"),
+		})
+		require.ErrorIs(t, err, storage.ErrInvalidContinuationToken)
+	})
+}
+
+func TestReadStartingWithUserIterator(t *testing.T) {
+	ctx := context.Background()
+	store := ulid.Make().String()
+
+	ds := New()
+	t.Cleanup(ds.Close)
+
+	// enough tuples for the iterator to read several pages
+	numTuples := 2*storage.DefaultPageSize + 1
+	for i := 0; i < numTuples; i++ {
+		err := ds.Write(ctx, store, nil, []*openfgav1.TupleKey{
+			tuple.NewTupleKey("document:"+strconv.Itoa(i), "viewer", "user:anne"),
+		})
+		require.NoError(t, err)
+	}
+
+	filter := storage.ReadStartingWithUserFilter{
+		ObjectType: "document",
+		Relation:   "viewer",
+		UserFilter: []*openfgav1.ObjectRelation{{Object: "user:anne"}},
+	}
+
+	t.Run("reads_every_page", func(t *testing.T) {
+		iter, err := ds.ReadStartingWithUser(ctx, store, filter, storage.ReadStartingWithUserOptions{})
+		require.NoError(t, err)
+		t.Cleanup(iter.Stop)
+
+		seen := map[string]struct{}{}
+		var previous string
+		for {
+			head, err := iter.Head(ctx)
+			if errors.Is(err, storage.ErrIteratorDone) {
+				break
+			}
+			require.NoError(t, err)
+
+			tp, err := iter.Next(ctx)
+			require.NoError(t, err)
+			require.Equal(t, head.GetKey(), tp.GetKey())
+
+			_, objectID := tuple.SplitObject(tp.GetKey().GetObject())
+			require.Greater(t, objectID, previous)
+			previous = objectID
+			seen[objectID] = struct{}{}
+		}
+		require.Len(t, seen, numTuples)
+
+		_, err = iter.Next(ctx)
+		require.ErrorIs(t, err, storage.ErrIteratorDone)
+	})
+
+	t.Run("stop", func(t *testing.T) {
+		iter, err := ds.ReadStartingWithUser(ctx, store, filter, storage.ReadStartingWithUserOptions{})
+		require.NoError(t, err)
+
+		_, err = iter.Next(ctx)
+		require.NoError(t, err)
+
+		iter.Stop()
+		_, err = iter.Next(ctx)
+		require.ErrorIs(t, err, storage.ErrIteratorDone)
+		_, err = iter.Head(ctx)
+		require.ErrorIs(t, err, storage.ErrIteratorDone)
+	})
+}
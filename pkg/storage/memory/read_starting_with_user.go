@@ -0,0 +1,256 @@
+package memory
+
+import (
+	"cmp"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"slices"
+	"sync"
+
+	openfgav1 "github.com/openfga/api/proto/openfga/v1"
+
+	"github.com/openfga/openfga/pkg/storage"
+	"github.com/openfga/openfga/pkg/telemetry"
+	tupleUtils "github.com/openfga/openfga/pkg/tuple"
+)
+
+// startingWithUserCursor is the position of a ReadStartingWithUser scan: the key of the last tuple read.
+// The tuples are ordered by object ID and user, which identify a tuple among the ones of the object type
+// and relation of the scan, so a scan resumed from a cursor neither repeats nor skips a tuple, regardless
+// of the tuples written or deleted since.
+type startingWithUserCursor struct {
+	ObjectID string `json:"object_id"`
+	User     string `json:"user"`
+}
+
+func newStartingWithUserCursor(t *storage.TupleRecord) *startingWithUserCursor {
+	return &startingWithUserCursor{ObjectID: t.ObjectID, User: t.User}
+}
+
+// compare orders the tuple relative to the cursor.
+func (c *startingWithUserCursor) compare(t *storage.TupleRecord) int {
+	return cmp.Or(
+		cmp.Compare(t.ObjectID, c.ObjectID),
+		cmp.Compare(t.User, c.User),
+	)
+}
+
+func (c *startingWithUserCursor) encode() (string, error) {
+	b, err := json.Marshal(c)
+	if err != nil {
+		return "", err
+	}
+	return base64.URLEncoding.EncodeToString(b), nil
+}
+
+func decodeStartingWithUserCursor(token string) (*startingWithUserCursor, error) {
+	b, err := base64.URLEncoding.DecodeString(token)
+	if err != nil {
+		return nil, storage.ErrInvalidContinuationToken
+	}
+
+	var c startingWithUserCursor
+	if err := json.Unmarshal(b, &c); err != nil {
+		return nil, storage.ErrInvalidContinuationToken
+	}
+	return &c, nil
+}
+
+// ReadStartingWithUserPage is the paginated variant of [MemoryBackend.ReadStartingWithUser]. It returns a page of
+// at most options.Pagination.PageSize tuples, sorted by object ID and user, and the continuation token of the next
+// page, which is empty on the last page.
+//
+// The continuation token is the position of the scan rather than an offset, so resuming a scan neither repeats
+// nor skips a tuple because of the writes since the previous page: a tuple written in the meantime is returned
+// if it sorts after the position of the scan.
+func (s *MemoryBackend) ReadStartingWithUserPage(
+	ctx context.Context,
+	store string,
+	filter storage.ReadStartingWithUserFilter,
+	options storage.ReadStartingWithUserPageOptions,
+) ([]*openfgav1.Tuple, string, error) {
+	_, span := tracer.Start(ctx, "memory.ReadStartingWithUserPage")
+	defer span.End()
+
+	var after *startingWithUserCursor
+	if options.Pagination.From != "" {
+		var err error
+		after, err = decodeStartingWithUserCursor(options.Pagination.From)
+		if err != nil {
+			telemetry.TraceError(span, err)
+			return nil, "", err
+		}
+	}
+
+	records, next := s.readStartingWithUser(store, newStartingWithUserMatcher(filter), after, options.Pagination.PageSize)
+
+	tuples := make([]*openfgav1.Tuple, 0, len(records))
+	for _, t := range records {
+		tuples = append(tuples, t.AsTuple())
+	}
+
+	if next == nil {
+		return tuples, "", nil
+	}
+
+	contToken, err := next.encode()
+	if err != nil {
+		telemetry.TraceError(span, err)
+		return nil, "", err
+	}
+	return tuples, contToken, nil
+}
+
+// startingWithUserMatcher matches the tuples of a ReadStartingWithUserFilter. The object IDs and users of the
+// filter are indexed once per scan, so that matching a tuple takes constant time.
+type startingWithUserMatcher struct {
+	objectType string
+	relation   string
+	objectIDs  map[string]struct{} // nil matches any object ID.
+	users      map[string]struct{}
+}
+
+func newStartingWithUserMatcher(filter storage.ReadStartingWithUserFilter) *startingWithUserMatcher {
+	m := &startingWithUserMatcher{
+		objectType: filter.ObjectType,
+		relation:   filter.Relation,
+		users:      make(map[string]struct{}, len(filter.UserFilter)),
+	}
+
+	if filter.ObjectIDs != nil {
+		m.objectIDs = make(map[string]struct{}, filter.ObjectIDs.Size())
+		for _, objectID := range filter.ObjectIDs.Values() {
+			m.objectIDs[objectID] = struct{}{}
+		}
+	}
+
+	for _, userFilter := range filter.UserFilter {
+		targetUser := userFilter.GetObject()
+		if userFilter.GetRelation() != "" {
+			targetUser = tupleUtils.GetObjectRelationAsString(userFilter)
+		}
+		m.users[targetUser] = struct{}{}
+	}
+	return m
+}
+
+func (m *startingWithUserMatcher) matches(t *storage.TupleRecord) bool {
+	if t.ObjectType != m.objectType || t.Relation != m.relation {
+		return false
+	}
+
+	if m.objectIDs != nil {
+		if _, ok := m.objectIDs[t.ObjectID]; !ok {
+			return false
+		}
+	}
+
+	_, ok := m.users[t.User]
+	return ok
+}
+
+// readStartingWithUser returns the page of at most pageSize tuples matched by the matcher that sort after
+// the cursor, or all of them if pageSize is 0, and the cursor of the next page, which is nil on the last page.
+func (s *MemoryBackend) readStartingWithUser(
+	store string,
+	matcher *startingWithUserMatcher,
+	after *startingWithUserCursor,
+	pageSize int,
+) ([]*storage.TupleRecord, *startingWithUserCursor) {
+	s.mutexTuples.RLock()
+	defer s.mutexTuples.RUnlock()
+
+	var matches []*storage.TupleRecord
+	for _, t := range s.tuples[store] {
+		if !matcher.matches(t) {
+			continue
+		}
+
+		if after != nil && after.compare(t) <= 0 {
+			continue
+		}
+
+		matches = append(matches, t)
+	}
+	slices.SortFunc(matches, func(a, b *storage.TupleRecord) int {
+		return cmp.Or(
+			cmp.Compare(a.ObjectID, b.ObjectID),
+			cmp.Compare(a.User, b.User),
+		)
+	})
+
+	if pageSize == 0 || len(matches) <= pageSize {
+		return matches, nil
+	}
+
+	matches = matches[:pageSize]
+	return matches, newStartingWithUserCursor(matches[pageSize-1])
+}
+
+// startingWithUserIterator is the iterator of [MemoryBackend.ReadStartingWithUser], which reads the next page
+// of tuples when the current one is consumed.
+type startingWithUserIterator struct {
+	backend *MemoryBackend
+	store   string
+	matcher *startingWithUserMatcher
+
+	mu      sync.Mutex
+	records []*storage.TupleRecord
+	next    *startingWithUserCursor // nil on the last page.
+	stopped bool
+}
+
+var _ storage.TupleIterator = (*startingWithUserIterator)(nil)
+
+// fetch makes sure that the current page holds a tuple, unless the scan is over.
+func (it *startingWithUserIterator) fetch() {
+	for len(it.records) == 0 && it.next != nil && !it.stopped {
+		it.records, it.next = it.backend.readStartingWithUser(it.store, it.matcher, it.next, storage.DefaultPageSize)
+	}
+}
+
+// Next see [storage.Iterator].Next.
+func (it *startingWithUserIterator) Next(ctx context.Context) (*openfgav1.Tuple, error) {
+	if ctx.Err() != nil {
+		return nil, ctx.Err()
+	}
+
+	it.mu.Lock()
+	defer it.mu.Unlock()
+
+	it.fetch()
+	if it.stopped || len(it.records) == 0 {
+		return nil, storage.ErrIteratorDone
+	}
+
+	next := it.records[0]
+	it.records = it.records[1:]
+	return next.AsTuple(), nil
+}
+
+// Head see [storage.Iterator].Head.
+func (it *startingWithUserIterator) Head(ctx context.Context) (*openfgav1.Tuple, error) {
+	if ctx.Err() != nil {
+		return nil, ctx.Err()
+	}
+
+	it.mu.Lock()
+	defer it.mu.Unlock()
+
+	it.fetch()
+	if it.stopped || len(it.records) == 0 {
+		return nil, storage.ErrIteratorDone
+	}
+
+	return it.records[0].AsTuple(), nil
+}
+
+// Stop see [storage.Iterator].Stop.
+func (it *startingWithUserIterator) Stop() {
+	it.mu.Lock()
+	defer it.mu.Unlock()
+
+	it.stopped = true
+	it.records = nil
+}
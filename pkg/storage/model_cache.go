@@ -0,0 +1,77 @@
+package storage
+
+import (
+	"time"
+
+	openfgav1 "github.com/openfga/api/proto/openfga/v1"
+)
+
+// ModelCacheTTL is the TTL of the models cached by an InMemoryModelCache. Models are never modified once
+// written, so they don't go stale, and the TTL only releases the models that are no longer used.
+const ModelCacheTTL = 168 * time.Hour
+
+// ModelCache caches the authorization models by store and model ID. Since the models are immutable once
+// written, the cached models never need to be invalidated. Implementations must be safe for concurrent use.
+type ModelCache interface {
+	// Get returns the model of the store with the ID and true, or false if the model isn't cached.
+	Get(storeID, modelID string) (*openfgav1.AuthorizationModel, bool)
+	// Set caches the model of the store with the ID.
+	Set(storeID, modelID string, model *openfgav1.AuthorizationModel)
+}
+
+var (
+	_ ModelCache = (*InMemoryModelCache)(nil)
+	_ CacheItem  = (*AuthorizationModelCacheEntry)(nil)
+)
+
+// AuthorizationModelCacheEntry is an authorization model cached by an InMemoryModelCache.
+type AuthorizationModelCacheEntry struct {
+	*openfgav1.AuthorizationModel
+}
+
+func (c *AuthorizationModelCacheEntry) CacheEntityType() string {
+	return "authz_model"
+}
+
+// InMemoryModelCache is a ModelCache that keeps up to a maximum number of models in memory, evicting the
+// least recently used ones.
+type InMemoryModelCache struct {
+	cache *InMemoryLRUCache[*AuthorizationModelCacheEntry]
+}
+
+// NewInMemoryModelCache returns a ModelCache that keeps up to maxSize models in memory.
+func NewInMemoryModelCache(maxSize int) (*InMemoryModelCache, error) {
+	cache, err := NewInMemoryLRUCache[*AuthorizationModelCacheEntry](WithMaxCacheSize[*AuthorizationModelCacheEntry](int64(maxSize)))
+	if err != nil {
+		return nil, err
+	}
+	return &InMemoryModelCache{cache: cache}, nil
+}
+
+func modelCacheKey(storeID, modelID string) string {
+	return storeID + ":" + modelID
+}
+
+// Get see [ModelCache].Get.
+func (c *InMemoryModelCache) Get(storeID, modelID string) (*openfgav1.AuthorizationModel, bool) {
+	entry := c.cache.Get(modelCacheKey(storeID, modelID))
+	if entry == nil {
+		return nil, false
+	}
+	return entry.AuthorizationModel, true
+}
+
+// Set see [ModelCache].Set.
+func (c *InMemoryModelCache) Set(storeID, modelID string, model *openfgav1.AuthorizationModel) {
+	c.cache.Set(modelCacheKey(storeID, modelID), &AuthorizationModelCacheEntry{model}, ModelCacheTTL)
+}
+
+// IsReady returns ErrCacheNotOperational if the cache doesn't keep the models it caches.
+func (c *InMemoryModelCache) IsReady() error {
+	return ProbeInMemoryCache[*AuthorizationModelCacheEntry](c.cache, &AuthorizationModelCacheEntry{})
+}
+
+// Stop cleans the resources of the cache.
+func (c *InMemoryModelCache) Stop() {
+	c.cache.Stop()
+}
@@ -6,6 +6,8 @@ import (
 	"errors"
 	"fmt"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	sq "github.com/Masterminds/squirrel"
@@ -28,6 +30,10 @@ import (
 	tupleUtils "github.com/openfga/openfga/pkg/tuple"
 )
 
+// MinimumSupportedSchemaRevision is the schema revision that is required to run this build of OpenFGA with
+// a MySQL datastore, i.e. the latest of the `assets/migrations/mysql` migrations, which the queries depend on.
+const MinimumSupportedSchemaRevision int64 = 9
+
 var tracer = otel.Tracer("openfga/pkg/storage/mysql")
 
 func startTrace(ctx context.Context, name string) (context.Context, trace.Span) {
@@ -43,11 +49,24 @@ type Datastore struct {
 	dbStatsCollector       prometheus.Collector
 	maxTuplesPerWriteField int
 	maxTypesPerModelField  int
+	readTimeout            time.Duration
+	writeTimeout           time.Duration
 	versionReady           bool
+	// unregisterPoolMetrics stops exporting the pool metrics of the database, see sqlcommon.RegisterPoolMetrics.
+	unregisterPoolMetrics func()
+
+	// replicas serve the reads that don't require HIGHER_CONSISTENCY, see getReplica.
+	replicas                 []*replica
+	nextReplica              atomic.Uint64
+	replicaFallbackToPrimary bool
+	stopMonitoringReplicas   chan struct{}
+	replicasMonitored        sync.WaitGroup
 }
 
 // Ensures that Datastore implements the OpenFGADatastore interface.
 var _ storage.OpenFGADatastore = (*Datastore)(nil)
+var _ storage.BestEffortTupleWriter = (*Datastore)(nil)
+var _ storage.TransientErrorClassifier = (*Datastore)(nil)
 
 // New creates a new [Datastore] storage.
 func New(uri string, cfg *sqlcommon.Config) (*Datastore, error) {
@@ -71,11 +90,33 @@ func New(uri string, cfg *sqlcommon.Config) (*Datastore, error) {
 	if err != nil {
 		return nil, fmt.Errorf("initialize mysql connection: %w", err)
 	}
-	return NewWithDB(db, cfg)
+
+	replicaDBs := make([]*sql.DB, 0, len(cfg.ReplicaURIs))
+	closeDBs := func() {
+		db.Close()
+		for _, replicaDB := range replicaDBs {
+			replicaDB.Close()
+		}
+	}
+	for _, replicaURI := range cfg.ReplicaURIs {
+		replicaDB, err := sql.Open("mysql", replicaURI)
+		if err != nil {
+			closeDBs()
+			return nil, fmt.Errorf("initialize mysql replica connection: %w", err)
+		}
+		replicaDBs = append(replicaDBs, replicaDB)
+	}
+
+	s, err := NewWithReplicas(db, replicaDBs, cfg)
+	if err != nil {
+		closeDBs()
+		return nil, err
+	}
+	return s, nil
 }
 
-// NewWithDB creates a new [Datastore] storage with the provided database connection.
-func NewWithDB(db *sql.DB, cfg *sqlcommon.Config) (*Datastore, error) {
+// configurePool applies the connection pool settings of the config to the database connection.
+func configurePool(db *sql.DB, cfg *sqlcommon.Config) {
 	if cfg.MaxIdleConns != 0 {
 		db.SetMaxIdleConns(cfg.MaxIdleConns) // default is 2, not retaining connections(0) would be detrimental for performance
 	}
@@ -83,6 +124,17 @@ func NewWithDB(db *sql.DB, cfg *sqlcommon.Config) (*Datastore, error) {
 	db.SetMaxOpenConns(cfg.MaxOpenConns)
 	db.SetConnMaxIdleTime(cfg.ConnMaxIdleTime)
 	db.SetConnMaxLifetime(cfg.ConnMaxLifetime)
+}
+
+// NewWithDB creates a new [Datastore] storage with the provided database connection.
+func NewWithDB(db *sql.DB, cfg *sqlcommon.Config) (*Datastore, error) {
+	return NewWithReplicas(db, nil, cfg)
+}
+
+// NewWithReplicas creates a new [Datastore] storage with the provided database connection, whose reads
+// are served by the provided read replica connections, see [sqlcommon.Config].ReplicaURIs.
+func NewWithReplicas(db *sql.DB, replicaDBs []*sql.DB, cfg *sqlcommon.Config) (*Datastore, error) {
+	configurePool(db, cfg)
 
 	policy := backoff.NewExponentialBackOff()
 	policy.MaxElapsedTime = 1 * time.Minute
@@ -100,27 +152,63 @@ func NewWithDB(db *sql.DB, cfg *sqlcommon.Config) (*Datastore, error) {
 		return nil, fmt.Errorf("ping db: %w", err)
 	}
 
-	var collector prometheus.Collector
+	var (
+		collector             prometheus.Collector
+		unregisterPoolMetrics func()
+	)
 	if cfg.ExportMetrics {
 		collector = collectors.NewDBStatsCollector(db, "openfga")
 		if err := prometheus.Register(collector); err != nil {
 			return nil, fmt.Errorf("initialize metrics: %w", err)
 		}
+		unregisterPoolMetrics = sqlcommon.RegisterPoolMetrics(db, "mysql", "primary")
 	}
 
 	stbl := sq.StatementBuilder.RunWith(db)
 	dbInfo := sqlcommon.NewDBInfo(db, stbl, HandleSQLError, "mysql")
 
-	return &Datastore{
+	replicas := make([]*replica, 0, len(replicaDBs))
+	for i, replicaDB := range replicaDBs {
+		r, err := newReplica(replicaDB, cfg, i)
+		if err != nil {
+			if collector != nil {
+				prometheus.Unregister(collector)
+			}
+			if unregisterPoolMetrics != nil {
+				unregisterPoolMetrics()
+			}
+			for _, r := range replicas {
+				r.unregisterMetrics()
+			}
+			return nil, fmt.Errorf("configure replica %d: %w", i, err)
+		}
+		replicas = append(replicas, r)
+	}
+
+	s := &Datastore{
 		stbl:                   stbl,
 		db:                     db,
 		dbInfo:                 dbInfo,
 		logger:                 cfg.Logger,
 		dbStatsCollector:       collector,
+		unregisterPoolMetrics:  unregisterPoolMetrics,
 		maxTuplesPerWriteField: cfg.MaxTuplesPerWriteField,
 		maxTypesPerModelField:  cfg.MaxTypesPerModelField,
+		readTimeout:            cfg.ReadTimeout,
+		writeTimeout:           cfg.WriteTimeout,
 		versionReady:           false,
-	}, nil
+
+		replicas:                 replicas,
+		replicaFallbackToPrimary: cfg.ReplicaFallbackToPrimary,
+	}
+
+	if len(replicas) > 0 {
+		s.stopMonitoringReplicas = make(chan struct{})
+		s.replicasMonitored.Add(1)
+		go s.monitorReplicas()
+	}
+
+	return s, nil
 }
 
 // Close see [storage.OpenFGADatastore].Close.
@@ -128,7 +216,18 @@ func (s *Datastore) Close() {
 	if s.dbStatsCollector != nil {
 		prometheus.Unregister(s.dbStatsCollector)
 	}
+	if s.unregisterPoolMetrics != nil {
+		s.unregisterPoolMetrics()
+	}
 	s.db.Close()
+
+	if s.stopMonitoringReplicas != nil {
+		close(s.stopMonitoringReplicas)
+		s.replicasMonitored.Wait()
+	}
+	for _, r := range s.replicas {
+		r.close()
+	}
 }
 
 // Read see [storage.RelationshipTupleReader].Read.
@@ -136,12 +235,12 @@ func (s *Datastore) Read(
 	ctx context.Context,
 	store string,
 	tupleKey *openfgav1.TupleKey,
-	_ storage.ReadOptions,
+	options storage.ReadOptions,
 ) (storage.TupleIterator, error) {
 	ctx, span := startTrace(ctx, "Read")
 	defer span.End()
 
-	return s.read(ctx, store, tupleKey, nil)
+	return s.read(ctx, store, tupleKey, nil, s.getReadStbl(options.Consistency.Preference))
 }
 
 // ReadPage see [storage.RelationshipTupleReader].ReadPage.
@@ -149,7 +248,7 @@ func (s *Datastore) ReadPage(ctx context.Context, store string, tupleKey *openfg
 	ctx, span := startTrace(ctx, "ReadPage")
 	defer span.End()
 
-	iter, err := s.read(ctx, store, tupleKey, &options)
+	iter, err := s.read(ctx, store, tupleKey, &options, s.getReadStbl(options.Consistency.Preference))
 	if err != nil {
 		return nil, "", err
 	}
@@ -158,11 +257,11 @@ func (s *Datastore) ReadPage(ctx context.Context, store string, tupleKey *openfg
 	return iter.ToArray(ctx, options.Pagination)
 }
 
-func (s *Datastore) read(ctx context.Context, store string, tupleKey *openfgav1.TupleKey, options *storage.ReadPageOptions) (*sqlcommon.SQLTupleIterator, error) {
+func (s *Datastore) read(ctx context.Context, store string, tupleKey *openfgav1.TupleKey, options *storage.ReadPageOptions, readStbl sq.StatementBuilderType) (*sqlcommon.SQLTupleIterator, error) {
 	_, span := startTrace(ctx, "read")
 	defer span.End()
 
-	sb := s.stbl.
+	sb := readStbl.
 		Select(
 			"store", "object_type", "object_id", "relation",
 			"_user",
@@ -209,14 +308,40 @@ func (s *Datastore) Write(
 	ctx, span := startTrace(ctx, "Write")
 	defer span.End()
 
-	return sqlcommon.Write(ctx, s.dbInfo, store, deletes, writes, storage.NewTupleWriteOptions(opts...), time.Now().UTC())
+	timeout := sqlcommon.NewWriteTimeout("Write", s.writeTimeout)
+	ctx, cancel := timeout.WithContext(ctx)
+	defer cancel()
+
+	err := sqlcommon.Write(ctx, s.dbInfo, store, deletes, writes, storage.NewTupleWriteOptions(opts...), time.Now().UTC())
+	return timeout.HandleError(ctx, err)
+}
+
+// WriteBestEffort see [storage.BestEffortTupleWriter].WriteBestEffort.
+func (s *Datastore) WriteBestEffort(
+	ctx context.Context,
+	store string,
+	writes storage.Writes,
+) ([]storage.TupleWriteResult, error) {
+	ctx, span := startTrace(ctx, "WriteBestEffort")
+	defer span.End()
+
+	timeout := sqlcommon.NewWriteTimeout("WriteBestEffort", s.writeTimeout)
+	ctx, cancel := timeout.WithContext(ctx)
+	defer cancel()
+
+	results, err := sqlcommon.WriteBestEffort(ctx, s.dbInfo, store, writes, time.Now().UTC())
+	return results, timeout.HandleError(ctx, err)
 }
 
 // ReadUserTuple see [storage.RelationshipTupleReader].ReadUserTuple.
-func (s *Datastore) ReadUserTuple(ctx context.Context, store string, tupleKey *openfgav1.TupleKey, _ storage.ReadUserTupleOptions) (*openfgav1.Tuple, error) {
+func (s *Datastore) ReadUserTuple(ctx context.Context, store string, tupleKey *openfgav1.TupleKey, options storage.ReadUserTupleOptions) (*openfgav1.Tuple, error) {
 	ctx, span := startTrace(ctx, "ReadUserTuple")
 	defer span.End()
 
+	timeout := sqlcommon.NewReadTimeout("ReadUserTuple", s.readTimeout)
+	ctx, cancel := timeout.WithContext(ctx)
+	defer cancel()
+
 	objectType, objectID := tupleUtils.SplitObject(tupleKey.GetObject())
 	userType := tupleUtils.GetUserTypeFromUser(tupleKey.GetUser())
 
@@ -224,7 +349,7 @@ func (s *Datastore) ReadUserTuple(ctx context.Context, store string, tupleKey *o
 	var conditionContext []byte
 	var record storage.TupleRecord
 
-	err := s.stbl.
+	err := s.getReadStbl(options.Consistency.Preference).
 		Select(
 			"object_type", "object_id", "relation",
 			"_user",
@@ -249,7 +374,7 @@ func (s *Datastore) ReadUserTuple(ctx context.Context, store string, tupleKey *o
 			&conditionContext,
 		)
 	if err != nil {
-		return nil, HandleSQLError(err)
+		return nil, timeout.HandleError(ctx, HandleSQLError(err))
 	}
 
 	if conditionName.String != "" {
@@ -272,12 +397,12 @@ func (s *Datastore) ReadUsersetTuples(
 	ctx context.Context,
 	store string,
 	filter storage.ReadUsersetTuplesFilter,
-	_ storage.ReadUsersetTuplesOptions,
+	options storage.ReadUsersetTuplesOptions,
 ) (storage.TupleIterator, error) {
 	_, span := startTrace(ctx, "ReadUsersetTuples")
 	defer span.End()
 
-	sb := s.stbl.
+	sb := s.getReadStbl(options.Consistency.Preference).
 		Select(
 			"store", "object_type", "object_id", "relation",
 			"_user",
@@ -314,7 +439,9 @@ func (s *Datastore) ReadUsersetTuples(
 		sb = sb.Where(orConditions)
 	}
 
-	return sqlcommon.NewSQLTupleIterator(sb, HandleSQLError), nil
+	return sqlcommon.NewSQLTupleIterator(sb, HandleSQLError,
+		sqlcommon.WithSQLTupleIteratorTimeout(sqlcommon.NewReadTimeout("ReadUsersetTuples", s.readTimeout)),
+	), nil
 }
 
 // ReadStartingWithUser see [storage.RelationshipTupleReader].ReadStartingWithUser.
@@ -322,7 +449,7 @@ func (s *Datastore) ReadStartingWithUser(
 	ctx context.Context,
 	store string,
 	filter storage.ReadStartingWithUserFilter,
-	_ storage.ReadStartingWithUserOptions,
+	options storage.ReadStartingWithUserOptions,
 ) (storage.TupleIterator, error) {
 	_, span := startTrace(ctx, "ReadStartingWithUser")
 	defer span.End()
@@ -336,7 +463,7 @@ func (s *Datastore) ReadStartingWithUser(
 		targetUsersArg = append(targetUsersArg, targetUser)
 	}
 
-	builder := s.stbl.
+	builder := s.getReadStbl(options.Consistency.Preference).
 		Select(
 			"store", "object_type", "object_id", "relation",
 			"_user",
@@ -354,7 +481,9 @@ func (s *Datastore) ReadStartingWithUser(
 		builder = builder.Where(sq.Eq{"object_id": filter.ObjectIDs.Values()})
 	}
 
-	return sqlcommon.NewSQLTupleIterator(builder, HandleSQLError), nil
+	return sqlcommon.NewSQLTupleIterator(builder, HandleSQLError,
+		sqlcommon.WithSQLTupleIteratorTimeout(sqlcommon.NewReadTimeout("ReadStartingWithUser", s.readTimeout)),
+	), nil
 }
 
 // MaxTuplesPerWrite see [storage.RelationshipTupleWriter].MaxTuplesPerWrite.
@@ -367,7 +496,7 @@ func (s *Datastore) ReadAuthorizationModel(ctx context.Context, store string, mo
 	ctx, span := startTrace(ctx, "ReadAuthorizationModel")
 	defer span.End()
 
-	return sqlcommon.ReadAuthorizationModel(ctx, s.dbInfo, store, modelID)
+	return sqlcommon.ReadAuthorizationModel(ctx, s.getReadDBInfo(ctx), store, modelID)
 }
 
 // ReadAuthorizationModels see [storage.AuthorizationModelReadBackend].ReadAuthorizationModels.
@@ -439,7 +568,7 @@ func (s *Datastore) FindLatestAuthorizationModel(ctx context.Context, store stri
 	ctx, span := startTrace(ctx, "FindLatestAuthorizationModel")
 	defer span.End()
 
-	return sqlcommon.FindLatestAuthorizationModel(ctx, s.dbInfo, store)
+	return sqlcommon.FindLatestAuthorizationModel(ctx, s.getReadDBInfo(ctx), store)
 }
 
 // MaxTypesPerAuthorizationModel see [storage.TypeDefinitionWriteBackend].MaxTypesPerAuthorizationModel.
@@ -554,6 +683,10 @@ func (s *Datastore) ListStores(ctx context.Context, options storage.ListStoresOp
 		whereClause = append(whereClause, sq.Eq{"name": options.Name})
 	}
 
+	if options.NameContains != "" {
+		whereClause = append(whereClause, sq.Expr("INSTR(CAST(name AS BINARY), CAST(? AS BINARY)) > 0", options.NameContains))
+	}
+
 	if options.Pagination.From != "" {
 		whereClause = append(whereClause, sq.GtOrEq{"id": options.Pagination.From})
 	}
@@ -617,9 +750,34 @@ func (s *Datastore) DeleteStore(ctx context.Context, id string) error {
 		return HandleSQLError(err)
 	}
 
+	// the changelog compaction horizon of the store isn't kept once it's deleted
+	_, err = s.stbl.
+		Delete("changelog_horizon").
+		Where(sq.Eq{"store": id}).
+		ExecContext(ctx)
+	if err != nil {
+		return HandleSQLError(err)
+	}
+
 	return nil
 }
 
+// WriteStoreMetadata see [storage.StoresBackend].WriteStoreMetadata.
+func (s *Datastore) WriteStoreMetadata(ctx context.Context, store string, metadata *storage.StoreMetadata) error {
+	ctx, span := startTrace(ctx, "WriteStoreMetadata")
+	defer span.End()
+
+	return sqlcommon.WriteStoreMetadata(ctx, s.dbInfo, store, metadata)
+}
+
+// ReadStoreMetadata see [storage.StoresBackend].ReadStoreMetadata.
+func (s *Datastore) ReadStoreMetadata(ctx context.Context, store string) (*storage.StoreMetadata, error) {
+	ctx, span := startTrace(ctx, "ReadStoreMetadata")
+	defer span.End()
+
+	return sqlcommon.ReadStoreMetadata(ctx, s.stbl, store, HandleSQLError)
+}
+
 // WriteAssertions see [storage.AssertionsBackend].WriteAssertions.
 func (s *Datastore) WriteAssertions(ctx context.Context, store, modelID string, assertions []*openfgav1.Assertion) error {
 	ctx, span := startTrace(ctx, "WriteAssertions")
@@ -704,6 +862,7 @@ func (s *Datastore) ReadChanges(ctx context.Context, store string, filter storag
 	}
 	if options.Pagination.From != "" {
 		sb = sqlcommon.AddFromUlid(sb, options.Pagination.From, options.SortDesc)
+		sb = sqlcommon.AddChangelogHorizonCheck(sb, store, options.Pagination.From)
 	}
 	if options.Pagination.PageSize > 0 {
 		sb = sb.Limit(uint64(options.Pagination.PageSize)) // + 1 is NOT used here as we always return a continuation token.
@@ -764,15 +923,29 @@ func (s *Datastore) ReadChanges(ctx context.Context, store string, filter storag
 	}
 
 	if len(changes) == 0 {
+		if options.Pagination.From != "" {
+			err := sqlcommon.CheckChangelogHorizon(ctx, s.stbl, store, options.Pagination.From, HandleSQLError)
+			if err != nil {
+				return nil, "", err
+			}
+		}
 		return nil, "", storage.ErrNotFound
 	}
 
 	return changes, ulid, nil
 }
 
+// CompactChangeLog see [storage.ChangelogBackend].CompactChangeLog.
+func (s *Datastore) CompactChangeLog(ctx context.Context, store string, olderThan time.Time) error {
+	ctx, span := startTrace(ctx, "CompactChangeLog")
+	defer span.End()
+
+	return sqlcommon.CompactChangeLog(ctx, s.dbInfo, store, olderThan, "ON DUPLICATE KEY UPDATE ulid = GREATEST(ulid, VALUES(ulid))")
+}
+
 // IsReady see [sqlcommon.IsReady].
 func (s *Datastore) IsReady(ctx context.Context) (storage.ReadinessStatus, error) {
-	versionReady, err := sqlcommon.IsReady(ctx, s.versionReady, s.db)
+	versionReady, err := sqlcommon.IsReady(ctx, s.versionReady, s.db, MinimumSupportedSchemaRevision)
 	if err != nil {
 		return versionReady, err
 	}
@@ -780,6 +953,17 @@ func (s *Datastore) IsReady(ctx context.Context) (storage.ReadinessStatus, error
 	return versionReady, nil
 }
 
+// IsTransientError see [storage.TransientErrorClassifier].IsTransientError. Deadlocks (1213) and lock wait
+// timeouts (1205) are transient, as well as the errors of the connection.
+func (s *Datastore) IsTransientError(err error) bool {
+	var me *mysql.MySQLError
+	if errors.As(err, &me) {
+		return me.Number == 1213 || me.Number == 1205
+	}
+
+	return errors.Is(err, mysql.ErrInvalidConn) || sqlcommon.IsTransientConnError(err)
+}
+
 // HandleSQLError processes an SQL error and converts it into a more
 // specific error type based on the nature of the SQL error.
 func HandleSQLError(err error, args ...interface{}) error {
@@ -3,6 +3,7 @@ package mysql
 import (
 	"context"
 	"database/sql"
+	"database/sql/driver"
 	"testing"
 	"time"
 
@@ -635,3 +636,16 @@ func TestNew(t *testing.T) {
 		})
 	}
 }
+
+func TestIsTransientError(t *testing.T) {
+	ds := &Datastore{}
+
+	require.True(t, ds.IsTransientError(HandleSQLError(&mysqldriver.MySQLError{Number: 1213, Message: "Deadlock found"})))
+	require.True(t, ds.IsTransientError(&mysqldriver.MySQLError{Number: 1205, Message: "Lock wait timeout exceeded"}))
+	require.True(t, ds.IsTransientError(HandleSQLError(mysqldriver.ErrInvalidConn)))
+	require.True(t, ds.IsTransientError(HandleSQLError(driver.ErrBadConn)))
+
+	require.False(t, ds.IsTransientError(&mysqldriver.MySQLError{Number: 1062, Message: "Duplicate entry"}))
+	require.False(t, ds.IsTransientError(HandleSQLError(sql.ErrNoRows)))
+	require.False(t, ds.IsTransientError(context.DeadlineExceeded))
+}
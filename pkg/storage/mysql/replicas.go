@@ -0,0 +1,146 @@
+package mysql
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"sync/atomic"
+	"time"
+
+	sq "github.com/Masterminds/squirrel"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"go.uber.org/zap"
+
+	openfgav1 "github.com/openfga/api/proto/openfga/v1"
+
+	"github.com/openfga/openfga/pkg/storage"
+	"github.com/openfga/openfga/pkg/storage/sqlcommon"
+)
+
+// replicaHealthCheckInterval is the interval at which the health of the read replicas is checked.
+const replicaHealthCheckInterval = 5 * time.Second
+
+// replica is a read replica of the primary database.
+type replica struct {
+	db        *sql.DB
+	stbl      sq.StatementBuilderType
+	dbInfo    *sqlcommon.DBInfo
+	collector prometheus.Collector
+	// unregisterPoolMetrics stops exporting the pool metrics of the replica, see sqlcommon.RegisterPoolMetrics.
+	unregisterPoolMetrics func()
+	// healthy is whether the last health check reached the replica.
+	healthy atomic.Bool
+}
+
+func newReplica(db *sql.DB, cfg *sqlcommon.Config, index int) (*replica, error) {
+	configurePool(db, cfg)
+
+	stbl := sq.StatementBuilder.RunWith(db)
+	r := &replica{
+		db:     db,
+		stbl:   stbl,
+		dbInfo: sqlcommon.NewDBInfo(db, stbl, HandleSQLError, "mysql"),
+	}
+
+	if cfg.ExportMetrics {
+		r.collector = collectors.NewDBStatsCollector(db, fmt.Sprintf("openfga_replica_%d", index))
+		if err := prometheus.Register(r.collector); err != nil {
+			return nil, fmt.Errorf("initialize metrics: %w", err)
+		}
+		r.unregisterPoolMetrics = sqlcommon.RegisterPoolMetrics(db, "mysql", fmt.Sprintf("replica_%d", index))
+	}
+
+	// unlike the primary, a replica that can't be reached doesn't fail the start, it is unhealthy
+	// until a health check reaches it
+	r.checkHealth()
+
+	return r, nil
+}
+
+// checkHealth pings the replica and reports whether its health changed.
+func (r *replica) checkHealth() bool {
+	ctx, cancel := context.WithTimeout(context.Background(), replicaHealthCheckInterval)
+	defer cancel()
+
+	healthy := r.db.PingContext(ctx) == nil
+	return r.healthy.Swap(healthy) != healthy
+}
+
+// close unregisters the metrics of the replica and closes its connection.
+func (r *replica) close() {
+	r.unregisterMetrics()
+	r.db.Close()
+}
+
+// unregisterMetrics stops exporting the metrics of the replica.
+func (r *replica) unregisterMetrics() {
+	if r.collector != nil {
+		prometheus.Unregister(r.collector)
+	}
+	if r.unregisterPoolMetrics != nil {
+		r.unregisterPoolMetrics()
+	}
+}
+
+// monitorReplicas checks the health of the replicas every replicaHealthCheckInterval until the datastore is closed.
+func (s *Datastore) monitorReplicas() {
+	defer s.replicasMonitored.Done()
+
+	ticker := time.NewTicker(replicaHealthCheckInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-s.stopMonitoringReplicas:
+			return
+		case <-ticker.C:
+			for i, r := range s.replicas {
+				if r.checkHealth() {
+					s.logger.Warn("mysql replica health changed", zap.Int("replica", i), zap.Bool("healthy", r.healthy.Load()))
+				}
+			}
+		}
+	}
+}
+
+// getReplica returns the replica that serves the next read, in round-robin order among the healthy replicas.
+// It returns nil if the read is served by the primary, which is the case for reads with HIGHER_CONSISTENCY,
+// without replicas, and while every replica is unhealthy if the fallback to the primary is enabled.
+func (s *Datastore) getReplica(consistency openfgav1.ConsistencyPreference) *replica {
+	if len(s.replicas) == 0 || consistency == openfgav1.ConsistencyPreference_HIGHER_CONSISTENCY {
+		return nil
+	}
+
+	next := s.nextReplica.Add(1)
+	for i := range s.replicas {
+		r := s.replicas[(next+uint64(i))%uint64(len(s.replicas))]
+		if r.healthy.Load() {
+			return r
+		}
+	}
+
+	if s.replicaFallbackToPrimary {
+		return nil
+	}
+
+	// without the fallback, the reads keep being spread across the replicas, and fail until they recover
+	return s.replicas[next%uint64(len(s.replicas))]
+}
+
+// getReadStbl returns the statement builder of the database that serves a read with the given consistency.
+func (s *Datastore) getReadStbl(consistency openfgav1.ConsistencyPreference) sq.StatementBuilderType {
+	if r := s.getReplica(consistency); r != nil {
+		return r.stbl
+	}
+	return s.stbl
+}
+
+// getReadDBInfo returns the database info of the database that serves a read without consistency options, e.g.
+// of the authorization models, according to the consistency of the context, see storage.ConsistencyFromContext.
+func (s *Datastore) getReadDBInfo(ctx context.Context) *sqlcommon.DBInfo {
+	if r := s.getReplica(storage.ConsistencyFromContext(ctx)); r != nil {
+		return r.dbInfo
+	}
+	return s.dbInfo
+}
@@ -0,0 +1,115 @@
+package mysql
+
+import (
+	"context"
+	"testing"
+
+	"github.com/oklog/ulid/v2"
+	"github.com/stretchr/testify/require"
+
+	openfgav1 "github.com/openfga/api/proto/openfga/v1"
+
+	"github.com/openfga/openfga/pkg/storage"
+	"github.com/openfga/openfga/pkg/storage/sqlcommon"
+	"github.com/openfga/openfga/pkg/storage/test"
+	storagefixtures "github.com/openfga/openfga/pkg/testfixtures/storage"
+	"github.com/openfga/openfga/pkg/tuple"
+)
+
+func TestGetReplica(t *testing.T) {
+	newDatastore := func(fallbackToPrimary bool, healthy ...bool) *Datastore {
+		s := &Datastore{replicaFallbackToPrimary: fallbackToPrimary}
+		for _, h := range healthy {
+			r := &replica{}
+			r.healthy.Store(h)
+			s.replicas = append(s.replicas, r)
+		}
+		return s
+	}
+
+	t.Run("without_replicas", func(t *testing.T) {
+		s := newDatastore(false)
+		require.Nil(t, s.getReplica(openfgav1.ConsistencyPreference_MINIMIZE_LATENCY))
+	})
+
+	t.Run("higher_consistency_reads_the_primary", func(t *testing.T) {
+		s := newDatastore(false, true, true)
+		require.Nil(t, s.getReplica(openfgav1.ConsistencyPreference_HIGHER_CONSISTENCY))
+	})
+
+	t.Run("round_robin", func(t *testing.T) {
+		s := newDatastore(false, true, true)
+
+		reads := map[*replica]int{}
+		for i := 0; i < 10; i++ {
+			reads[s.getReplica(openfgav1.ConsistencyPreference_UNSPECIFIED)]++
+		}
+		require.Equal(t, map[*replica]int{s.replicas[0]: 5, s.replicas[1]: 5}, reads)
+	})
+
+	t.Run("skips_unhealthy_replicas", func(t *testing.T) {
+		s := newDatastore(false, true, false, true)
+
+		reads := map[*replica]int{}
+		for i := 0; i < 10; i++ {
+			reads[s.getReplica(openfgav1.ConsistencyPreference_UNSPECIFIED)]++
+		}
+		require.Len(t, reads, 2)
+		require.NotContains(t, reads, s.replicas[1])
+	})
+
+	t.Run("unhealthy_replicas_fall_back_to_the_primary", func(t *testing.T) {
+		s := newDatastore(true, false, false)
+		require.Nil(t, s.getReplica(openfgav1.ConsistencyPreference_UNSPECIFIED))
+	})
+
+	t.Run("unhealthy_replicas_without_fallback", func(t *testing.T) {
+		s := newDatastore(false, false, false)
+		require.NotNil(t, s.getReplica(openfgav1.ConsistencyPreference_UNSPECIFIED))
+	})
+
+	t.Run("model_reads_follow_the_consistency_of_the_context", func(t *testing.T) {
+		s := newDatastore(false, true)
+		s.dbInfo = &sqlcommon.DBInfo{}
+		s.replicas[0].dbInfo = &sqlcommon.DBInfo{}
+
+		require.Same(t, s.replicas[0].dbInfo, s.getReadDBInfo(context.Background()))
+
+		ctx := storage.ContextWithConsistency(context.Background(), openfgav1.ConsistencyPreference_HIGHER_CONSISTENCY)
+		require.Same(t, s.dbInfo, s.getReadDBInfo(ctx))
+	})
+}
+
+func TestMySQLDatastoreWithReplicas(t *testing.T) {
+	testDatastore := storagefixtures.RunDatastoreTestContainer(t, "mysql")
+	uri := testDatastore.GetConnectionURI(true)
+
+	t.Run("all_tests", func(t *testing.T) {
+		// the primary serves as its own replica, without replication lag
+		ds, err := New(uri, sqlcommon.NewConfig(sqlcommon.WithReplicaURIs(uri)))
+		require.NoError(t, err)
+		defer ds.Close()
+
+		require.True(t, ds.replicas[0].healthy.Load())
+		test.RunAllTests(t, ds)
+	})
+
+	t.Run("unreachable_replica_falls_back_to_the_primary", func(t *testing.T) {
+		ds, err := New(uri, sqlcommon.NewConfig(
+			sqlcommon.WithReplicaURIs("root:secret@tcp(127.0.0.1:1)/openfga?timeout=1s"),
+			sqlcommon.WithReplicaFallbackToPrimary(true),
+		))
+		require.NoError(t, err)
+		defer ds.Close()
+
+		require.False(t, ds.replicas[0].healthy.Load())
+
+		ctx := context.Background()
+		store := ulid.Make().String()
+		tk := tuple.NewTupleKey("doc:1", "viewer", "user:anne")
+		require.NoError(t, ds.Write(ctx, store, nil, []*openfgav1.TupleKey{tk}))
+
+		_, err = ds.ReadUserTuple(ctx, store, tk, storage.ReadUserTupleOptions{})
+		require.NoError(t, err)
+	})
+}
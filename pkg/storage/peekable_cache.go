@@ -0,0 +1,124 @@
+package storage
+
+import (
+	"sync"
+	"time"
+)
+
+// PeekableCache is implemented by the caches that can look a key up without affecting their eviction order,
+// e.g. for diagnostics or to skip the keys that a cache-warming job already cached.
+type PeekableCache[T any] interface {
+	// Peek returns the value of the key and true if the key exists and hasn't expired, without
+	// affecting the eviction order of the cache. Otherwise, it returns the zero value and false.
+	Peek(key string) (T, bool)
+	// Contains reports whether the key exists and hasn't expired, without affecting the eviction order of the cache.
+	Contains(key string) bool
+}
+
+// PeekableInMemoryLRUCache is an InMemoryLRUCache that implements PeekableCache. A lookup in the underlying
+// cache always counts as an access to the entry, so it keeps an index of its entries next to the cache, at
+// the cost of the memory of the index.
+type PeekableInMemoryLRUCache[T any] struct {
+	*InMemoryLRUCache[T]
+	// entries maps the keys to their *peekableEntry.
+	entries *sync.Map
+}
+
+type peekableEntry[T any] struct {
+	value T
+	// expiresAt is the zero time if the entry never expires.
+	expiresAt time.Time
+}
+
+func (e *peekableEntry[T]) expired(now time.Time) bool {
+	return !e.expiresAt.IsZero() && !now.Before(e.expiresAt)
+}
+
+var (
+	_ InMemoryCache[any] = (*PeekableInMemoryLRUCache[any])(nil)
+	_ PeekableCache[any] = (*PeekableInMemoryLRUCache[any])(nil)
+)
+
+// NewPeekableInMemoryLRUCache returns a PeekableInMemoryLRUCache, with the options of an InMemoryLRUCache.
+func NewPeekableInMemoryLRUCache[T any](opts ...InMemoryLRUCacheOpt[T]) (*PeekableInMemoryLRUCache[T], error) {
+	p := &PeekableInMemoryLRUCache[T]{entries: &sync.Map{}}
+
+	// the index forgets the entries that leave the cache before calling the eviction callback of the options
+	opts = append(opts[:len(opts):len(opts)], func(i *InMemoryLRUCache[T]) {
+		onEvict := i.onEvict
+		i.onEvict = func(key string, value T, reason EvictReason) {
+			p.forget(key, reason)
+			if onEvict != nil {
+				onEvict(key, value, reason)
+			}
+		}
+	})
+
+	cache, err := NewInMemoryLRUCache(opts...)
+	if err != nil {
+		return nil, err
+	}
+	p.InMemoryLRUCache = cache
+
+	return p, nil
+}
+
+// Set will store the value during the ttl, see InMemoryLRUCache.Set.
+func (p *PeekableInMemoryLRUCache[T]) Set(key string, value T, ttl time.Duration) {
+	entry := &peekableEntry[T]{value: value}
+	if ttl != 0 {
+		entry.expiresAt = time.Now().Add(min(ttl, oneYear))
+	}
+
+	// the entry is indexed first, so that the index forgets it if the cache evicts it right away
+	p.entries.Store(key, entry)
+	if !p.InMemoryLRUCache.set(key, value, ttl) {
+		p.entries.CompareAndDelete(key, entry)
+	}
+}
+
+func (p *PeekableInMemoryLRUCache[T]) Peek(key string) (T, bool) {
+	var zero T
+	v, ok := p.entries.Load(key)
+	if !ok {
+		return zero, false
+	}
+
+	entry := v.(*peekableEntry[T])
+	if entry.expired(time.Now()) {
+		return zero, false
+	}
+	return entry.value, true
+}
+
+func (p *PeekableInMemoryLRUCache[T]) Contains(key string) bool {
+	_, ok := p.Peek(key)
+	return ok
+}
+
+func (p *PeekableInMemoryLRUCache[T]) Delete(key string) {
+	p.entries.Delete(key)
+	p.InMemoryLRUCache.Delete(key)
+}
+
+// Clear removes all the entries, see InMemoryLRUCache.Clear.
+func (p *PeekableInMemoryLRUCache[T]) Clear() {
+	p.entries.Clear()
+	p.InMemoryLRUCache.Clear()
+}
+
+// forget removes the key from the index once its entry left the cache. The entries removed with Delete or
+// Clear were already removed, and an expired entry is only removed if it wasn't set again since.
+func (p *PeekableInMemoryLRUCache[T]) forget(key string, reason EvictReason) {
+	switch reason {
+	case EvictManual:
+		return
+	case EvictTTL:
+		v, ok := p.entries.Load(key)
+		if ok && v.(*peekableEntry[T]).expired(time.Now()) {
+			p.entries.CompareAndDelete(key, v)
+		}
+	default:
+		p.entries.Delete(key)
+	}
+}
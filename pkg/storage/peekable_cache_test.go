@@ -0,0 +1,136 @@
+package storage
+
+import (
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"go.uber.org/goleak"
+)
+
+func TestPeekableInMemoryLRUCache(t *testing.T) {
+	t.Run("peek_and_contains", func(t *testing.T) {
+		cache, err := NewPeekableInMemoryLRUCache[string]()
+		require.NoError(t, err)
+		t.Cleanup(func() {
+			goleak.VerifyNone(t)
+		})
+		defer cache.Stop()
+
+		cache.Set("key", "value", time.Minute)
+		cache.Set("forever", "value", 0)
+		cache.Set("expiring", "value", time.Millisecond)
+
+		value, ok := cache.Peek("key")
+		require.True(t, ok)
+		require.Equal(t, "value", value)
+		require.True(t, cache.Contains("key"))
+		require.True(t, cache.Contains("forever"))
+
+		value, ok = cache.Peek("missing")
+		require.False(t, ok)
+		require.Empty(t, value)
+		require.False(t, cache.Contains("missing"))
+
+		require.Eventually(t, func() bool {
+			return !cache.Contains("expiring")
+		}, time.Second, time.Millisecond)
+
+		// peeking doesn't count as an access to the entries
+		require.Zero(t, cache.client.Stats().Hits())
+		require.Zero(t, cache.client.Stats().Misses())
+	})
+
+	t.Run("forgets_evicted_entries", func(t *testing.T) {
+		cache, err := NewPeekableInMemoryLRUCache[string](WithMaxCacheSize[string](10))
+		require.NoError(t, err)
+		t.Cleanup(func() {
+			goleak.VerifyNone(t)
+		})
+		defer cache.Stop()
+
+		for i := 0; i < 100; i++ {
+			cache.Set(strconv.Itoa(i), "value", time.Minute)
+		}
+
+		require.Eventually(t, func() bool {
+			return cache.Len() <= 10 && cache.Evictions() >= 90
+		}, time.Second, 10*time.Millisecond)
+
+		peekable := 0
+		for i := 0; i < 100; i++ {
+			if cache.Contains(strconv.Itoa(i)) {
+				peekable++
+			}
+		}
+		require.Equal(t, cache.Len(), peekable)
+	})
+
+	t.Run("entry_larger_than_the_cache", func(t *testing.T) {
+		cache, err := NewPeekableInMemoryLRUCache[string](
+			WithMaxCacheSize[string](10),
+			WithCostFunc(func(value string) int64 { return int64(len(value)) }),
+		)
+		require.NoError(t, err)
+		t.Cleanup(func() {
+			goleak.VerifyNone(t)
+		})
+		defer cache.Stop()
+
+		cache.Set("large", "larger than ten", time.Minute)
+		require.False(t, cache.Contains("large"))
+	})
+
+	t.Run("delete_and_clear", func(t *testing.T) {
+		cache, err := NewPeekableInMemoryLRUCache[string]()
+		require.NoError(t, err)
+		t.Cleanup(func() {
+			goleak.VerifyNone(t)
+		})
+		defer cache.Stop()
+
+		for i := 0; i < 10; i++ {
+			cache.Set(strconv.Itoa(i), "value", time.Minute)
+		}
+
+		cache.Delete("0")
+		require.False(t, cache.Contains("0"))
+		require.True(t, cache.Contains("1"))
+
+		cache.Clear()
+		for i := 0; i < 10; i++ {
+			require.False(t, cache.Contains(strconv.Itoa(i)))
+		}
+	})
+
+	t.Run("keeps_the_eviction_callback", func(t *testing.T) {
+		var (
+			mu      sync.Mutex
+			removed []string
+		)
+		cache, err := NewPeekableInMemoryLRUCache[string](WithEvictionCallback(func(key string, _ string, reason EvictReason) {
+			mu.Lock()
+			defer mu.Unlock()
+			if reason == EvictManual {
+				removed = append(removed, key)
+			}
+		}))
+		require.NoError(t, err)
+		t.Cleanup(func() {
+			goleak.VerifyNone(t)
+		})
+		defer cache.Stop()
+
+		cache.Set("key", "value", time.Minute)
+		cache.Delete("key")
+
+		require.Eventually(t, func() bool {
+			mu.Lock()
+			defer mu.Unlock()
+			return len(removed) == 1
+		}, time.Second, time.Millisecond)
+		require.False(t, cache.Contains("key"))
+	})
+}
@@ -11,6 +11,7 @@ import (
 
 	sq "github.com/Masterminds/squirrel"
 	"github.com/cenkalti/backoff/v4"
+	"github.com/jackc/pgx/v5/pgconn"
 	_ "github.com/jackc/pgx/v5/stdlib" // PostgreSQL driver.
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/collectors"
@@ -29,6 +30,10 @@ import (
 	tupleUtils "github.com/openfga/openfga/pkg/tuple"
 )
 
+// MinimumSupportedSchemaRevision is the schema revision that is required to run this build of OpenFGA with
+// a Postgres datastore, i.e. the latest of the `assets/migrations/postgres` migrations, which the queries depend on.
+const MinimumSupportedSchemaRevision int64 = 8
+
 var tracer = otel.Tracer("openfga/pkg/storage/postgres")
 
 func startTrace(ctx context.Context, name string) (context.Context, trace.Span) {
@@ -48,11 +53,17 @@ type Datastore struct {
 	secondaryDBStatsCollector prometheus.Collector
 	maxTuplesPerWriteField    int
 	maxTypesPerModelField     int
+	readTimeout               time.Duration
+	writeTimeout              time.Duration
 	versionReady              bool
+	// unregisterPoolMetrics stops exporting the pool metrics of the databases, see sqlcommon.RegisterPoolMetrics.
+	unregisterPoolMetrics []func()
 }
 
 // Ensures that Datastore implements the OpenFGADatastore interface.
 var _ storage.OpenFGADatastore = (*Datastore)(nil)
+var _ storage.BestEffortTupleWriter = (*Datastore)(nil)
+var _ storage.TransientErrorClassifier = (*Datastore)(nil)
 
 // initDB initializes a new postgres database connection.
 func initDB(uri string, username string, password string, cfg *sqlcommon.Config) (*sql.DB, error) {
@@ -168,6 +179,14 @@ func NewWithDB(primaryDB, secondaryDB *sql.DB, cfg *sqlcommon.Config) (*Datastor
 		}
 	}
 
+	var unregisterPoolMetrics []func()
+	if cfg.ExportMetrics {
+		unregisterPoolMetrics = append(unregisterPoolMetrics, sqlcommon.RegisterPoolMetrics(primaryDB, "postgres", "primary"))
+		if secondaryDB != nil {
+			unregisterPoolMetrics = append(unregisterPoolMetrics, sqlcommon.RegisterPoolMetrics(secondaryDB, "postgres", "secondary"))
+		}
+	}
+
 	return &Datastore{
 		primaryStbl:               primaryStbl,
 		secondaryStbl:             secondaryStbl,
@@ -180,7 +199,10 @@ func NewWithDB(primaryDB, secondaryDB *sql.DB, cfg *sqlcommon.Config) (*Datastor
 		secondaryDBStatsCollector: secondaryCollector,
 		maxTuplesPerWriteField:    cfg.MaxTuplesPerWriteField,
 		maxTypesPerModelField:     cfg.MaxTypesPerModelField,
+		readTimeout:               cfg.ReadTimeout,
+		writeTimeout:              cfg.WriteTimeout,
 		versionReady:              false,
+		unregisterPoolMetrics:     unregisterPoolMetrics,
 	}, nil
 }
 
@@ -190,6 +212,9 @@ func (s *Datastore) isSecondaryConfigured() bool {
 
 // Close see [storage.OpenFGADatastore].Close.
 func (s *Datastore) Close() {
+	for _, unregister := range s.unregisterPoolMetrics {
+		unregister()
+	}
 	if s.primaryDBStatsCollector != nil {
 		prometheus.Unregister(s.primaryDBStatsCollector)
 	}
@@ -304,7 +329,29 @@ func (s *Datastore) Write(
 	ctx, span := startTrace(ctx, "Write")
 	defer span.End()
 
-	return sqlcommon.Write(ctx, s.primaryDBInfo, store, deletes, writes, storage.NewTupleWriteOptions(opts...), time.Now().UTC())
+	timeout := sqlcommon.NewWriteTimeout("Write", s.writeTimeout)
+	ctx, cancel := timeout.WithContext(ctx)
+	defer cancel()
+
+	err := sqlcommon.Write(ctx, s.primaryDBInfo, store, deletes, writes, storage.NewTupleWriteOptions(opts...), time.Now().UTC())
+	return timeout.HandleError(ctx, err)
+}
+
+// WriteBestEffort see [storage.BestEffortTupleWriter].WriteBestEffort.
+func (s *Datastore) WriteBestEffort(
+	ctx context.Context,
+	store string,
+	writes storage.Writes,
+) ([]storage.TupleWriteResult, error) {
+	ctx, span := startTrace(ctx, "WriteBestEffort")
+	defer span.End()
+
+	timeout := sqlcommon.NewWriteTimeout("WriteBestEffort", s.writeTimeout)
+	ctx, cancel := timeout.WithContext(ctx)
+	defer cancel()
+
+	results, err := sqlcommon.WriteBestEffort(ctx, s.primaryDBInfo, store, writes, time.Now().UTC())
+	return results, timeout.HandleError(ctx, err)
 }
 
 // ReadUserTuple see [storage.RelationshipTupleReader].ReadUserTuple.
@@ -312,6 +359,10 @@ func (s *Datastore) ReadUserTuple(ctx context.Context, store string, tupleKey *o
 	ctx, span := startTrace(ctx, "ReadUserTuple")
 	defer span.End()
 
+	timeout := sqlcommon.NewReadTimeout("ReadUserTuple", s.readTimeout)
+	ctx, cancel := timeout.WithContext(ctx)
+	defer cancel()
+
 	readStbl := s.getReadStbl(&options.Consistency.Preference)
 	objectType, objectID := tupleUtils.SplitObject(tupleKey.GetObject())
 	userType := tupleUtils.GetUserTypeFromUser(tupleKey.GetUser())
@@ -345,7 +396,7 @@ func (s *Datastore) ReadUserTuple(ctx context.Context, store string, tupleKey *o
 			&conditionContext,
 		)
 	if err != nil {
-		return nil, HandleSQLError(err)
+		return nil, timeout.HandleError(ctx, HandleSQLError(err))
 	}
 
 	if conditionName.String != "" {
@@ -411,7 +462,9 @@ func (s *Datastore) ReadUsersetTuples(
 		sb = sb.Where(orConditions)
 	}
 
-	return sqlcommon.NewSQLTupleIterator(sb, HandleSQLError), nil
+	return sqlcommon.NewSQLTupleIterator(sb, HandleSQLError,
+		sqlcommon.WithSQLTupleIteratorTimeout(sqlcommon.NewReadTimeout("ReadUsersetTuples", s.readTimeout)),
+	), nil
 }
 
 // ReadStartingWithUser see [storage.RelationshipTupleReader].ReadStartingWithUser.
@@ -452,7 +505,9 @@ func (s *Datastore) ReadStartingWithUser(
 		builder = builder.Where(sq.Eq{"object_id": filter.ObjectIDs.Values()})
 	}
 
-	return sqlcommon.NewSQLTupleIterator(builder, HandleSQLError), nil
+	return sqlcommon.NewSQLTupleIterator(builder, HandleSQLError,
+		sqlcommon.WithSQLTupleIteratorTimeout(sqlcommon.NewReadTimeout("ReadStartingWithUser", s.readTimeout)),
+	), nil
 }
 
 // MaxTuplesPerWrite see [storage.RelationshipTupleWriter].MaxTuplesPerWrite.
@@ -628,6 +683,10 @@ func (s *Datastore) ListStores(ctx context.Context, options storage.ListStoresOp
 		whereClause = append(whereClause, sq.Eq{"name": options.Name})
 	}
 
+	if options.NameContains != "" {
+		whereClause = append(whereClause, sq.Expr("strpos(name, ?) > 0", options.NameContains))
+	}
+
 	if options.Pagination.From != "" {
 		whereClause = append(whereClause, sq.GtOrEq{"id": options.Pagination.From})
 	}
@@ -691,9 +750,34 @@ func (s *Datastore) DeleteStore(ctx context.Context, id string) error {
 		return HandleSQLError(err)
 	}
 
+	// the changelog compaction horizon of the store isn't kept once it's deleted
+	_, err = s.primaryStbl.
+		Delete("changelog_horizon").
+		Where(sq.Eq{"store": id}).
+		ExecContext(ctx)
+	if err != nil {
+		return HandleSQLError(err)
+	}
+
 	return nil
 }
 
+// WriteStoreMetadata see [storage.StoresBackend].WriteStoreMetadata.
+func (s *Datastore) WriteStoreMetadata(ctx context.Context, store string, metadata *storage.StoreMetadata) error {
+	ctx, span := startTrace(ctx, "WriteStoreMetadata")
+	defer span.End()
+
+	return sqlcommon.WriteStoreMetadata(ctx, s.primaryDBInfo, store, metadata)
+}
+
+// ReadStoreMetadata see [storage.StoresBackend].ReadStoreMetadata.
+func (s *Datastore) ReadStoreMetadata(ctx context.Context, store string) (*storage.StoreMetadata, error) {
+	ctx, span := startTrace(ctx, "ReadStoreMetadata")
+	defer span.End()
+
+	return sqlcommon.ReadStoreMetadata(ctx, s.getReadStbl(nil), store, HandleSQLError)
+}
+
 // WriteAssertions see [storage.AssertionsBackend].WriteAssertions.
 func (s *Datastore) WriteAssertions(ctx context.Context, store, modelID string, assertions []*openfgav1.Assertion) error {
 	ctx, span := startTrace(ctx, "WriteAssertions")
@@ -778,6 +862,7 @@ func (s *Datastore) ReadChanges(ctx context.Context, store string, filter storag
 	}
 	if options.Pagination.From != "" {
 		sb = sqlcommon.AddFromUlid(sb, options.Pagination.From, options.SortDesc)
+		sb = sqlcommon.AddChangelogHorizonCheck(sb, store, options.Pagination.From)
 	}
 	if options.Pagination.PageSize > 0 {
 		sb = sb.Limit(uint64(options.Pagination.PageSize)) // + 1 is NOT used here as we always return a continuation token.
@@ -838,15 +923,29 @@ func (s *Datastore) ReadChanges(ctx context.Context, store string, filter storag
 	}
 
 	if len(changes) == 0 {
+		if options.Pagination.From != "" {
+			err := sqlcommon.CheckChangelogHorizon(ctx, s.getReadStbl(nil), store, options.Pagination.From, HandleSQLError)
+			if err != nil {
+				return nil, "", err
+			}
+		}
 		return nil, "", storage.ErrNotFound
 	}
 
 	return changes, ulid, nil
 }
 
+// CompactChangeLog see [storage.ChangelogBackend].CompactChangeLog.
+func (s *Datastore) CompactChangeLog(ctx context.Context, store string, olderThan time.Time) error {
+	ctx, span := startTrace(ctx, "CompactChangeLog")
+	defer span.End()
+
+	return sqlcommon.CompactChangeLog(ctx, s.primaryDBInfo, store, olderThan, "ON CONFLICT (store) DO UPDATE SET ulid = GREATEST(changelog_horizon.ulid, EXCLUDED.ulid)")
+}
+
 // IsReady see [sqlcommon.IsReady].
 func (s *Datastore) IsReady(ctx context.Context) (storage.ReadinessStatus, error) {
-	primaryStatus, err := sqlcommon.IsReady(ctx, s.versionReady, s.primaryDB)
+	primaryStatus, err := sqlcommon.IsReady(ctx, s.versionReady, s.primaryDB, MinimumSupportedSchemaRevision)
 	if err != nil {
 		return primaryStatus, err
 	}
@@ -862,7 +961,7 @@ func (s *Datastore) IsReady(ctx context.Context) (storage.ReadinessStatus, error
 	}
 
 	// check if secondary is ready
-	secondaryStatus, err := sqlcommon.IsReady(ctx, s.versionReady, s.secondaryDB)
+	secondaryStatus, err := sqlcommon.IsReady(ctx, s.versionReady, s.secondaryDB, MinimumSupportedSchemaRevision)
 	if err != nil {
 		secondaryStatus.Message = err.Error()
 		secondaryStatus.IsReady = false
@@ -876,12 +975,25 @@ func (s *Datastore) IsReady(ctx context.Context) (storage.ReadinessStatus, error
 	messageTpl := "primary: %s, secondary: %s"
 	multipleReadyStatus.IsReady = primaryStatus.IsReady && secondaryStatus.IsReady
 	multipleReadyStatus.Message = fmt.Sprintf(messageTpl, primaryStatus.Message, secondaryStatus.Message)
+	multipleReadyStatus.Latency = max(primaryStatus.Latency, secondaryStatus.Latency)
 
 	s.versionReady = multipleReadyStatus.IsReady
 
 	return multipleReadyStatus, nil
 }
 
+// IsTransientError see [storage.TransientErrorClassifier].IsTransientError. Deadlocks (40P01), serialization
+// failures (40001) and connection exceptions (class 08) are transient, as well as the other errors of the
+// connection.
+func (s *Datastore) IsTransientError(err error) bool {
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) {
+		return pgErr.Code == "40P01" || pgErr.Code == "40001" || strings.HasPrefix(pgErr.Code, "08")
+	}
+
+	return sqlcommon.IsTransientConnError(err)
+}
+
 // HandleSQLError processes an SQL error and converts it into a more
 // specific error type based on the nature of the SQL error.
 func HandleSQLError(err error, args ...interface{}) error {
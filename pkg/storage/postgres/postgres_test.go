@@ -3,10 +3,12 @@ package postgres
 import (
 	"context"
 	"database/sql"
+	"database/sql/driver"
 	"errors"
 	"testing"
 	"time"
 
+	"github.com/jackc/pgx/v5/pgconn"
 	"github.com/oklog/ulid/v2"
 	"github.com/stretchr/testify/require"
 	"google.golang.org/protobuf/proto"
@@ -621,3 +623,67 @@ func TestHandleSQLError(t *testing.T) {
 		require.ErrorIs(t, err, storage.ErrNotFound)
 	})
 }
+
+func TestOperationTimeouts(t *testing.T) {
+	testDatastore := storagefixtures.RunDatastoreTestContainer(t, "postgres")
+
+	uri := testDatastore.GetConnectionURI(true)
+	ds, err := New(uri, sqlcommon.NewConfig(
+		sqlcommon.WithReadTimeout(100*time.Millisecond),
+		sqlcommon.WithWriteTimeout(10*time.Second),
+	))
+	require.NoError(t, err)
+	defer ds.Close()
+
+	ctx := context.Background()
+	store := ulid.Make().String()
+	tk := tuple.NewTupleKey("doc:1", "viewer", "user:jon")
+	require.NoError(t, ds.Write(ctx, store, nil, []*openfgav1.TupleKey{tk}))
+
+	// hold an exclusive lock on the tuple table so that every read and write blocks on it
+	tx, err := ds.primaryDB.BeginTx(ctx, nil)
+	require.NoError(t, err)
+	defer func() { _ = tx.Rollback() }()
+	_, err = tx.ExecContext(ctx, "LOCK TABLE tuple IN ACCESS EXCLUSIVE MODE")
+	require.NoError(t, err)
+
+	t.Run("slow_read_trips_the_read_timeout", func(t *testing.T) {
+		_, err := ds.ReadUserTuple(ctx, store, tk, storage.ReadUserTupleOptions{})
+		var timeoutErr *sqlcommon.OperationTimeoutError
+		require.ErrorAs(t, err, &timeoutErr)
+		require.Equal(t, "ReadUserTuple", timeoutErr.Operation)
+		require.Equal(t, sqlcommon.ReadOperation, timeoutErr.Kind)
+		require.ErrorIs(t, err, context.DeadlineExceeded)
+
+		iter, err := ds.ReadUsersetTuples(ctx, store, storage.ReadUsersetTuplesFilter{Object: "doc:1"}, storage.ReadUsersetTuplesOptions{})
+		require.NoError(t, err)
+		defer iter.Stop()
+		_, err = iter.Next(ctx)
+		require.ErrorAs(t, err, &timeoutErr)
+		require.Equal(t, "ReadUsersetTuples", timeoutErr.Operation)
+	})
+
+	t.Run("write_under_its_own_timeout_succeeds", func(t *testing.T) {
+		go func() {
+			// release the lock after the read timeout but well before the write timeout
+			time.Sleep(500 * time.Millisecond)
+			_ = tx.Commit()
+		}()
+
+		err := ds.Write(ctx, store, nil, []*openfgav1.TupleKey{tuple.NewTupleKey("doc:2", "viewer", "user:jon")})
+		require.NoError(t, err)
+	})
+}
+
+func TestIsTransientError(t *testing.T) {
+	ds := &Datastore{}
+
+	require.True(t, ds.IsTransientError(HandleSQLError(&pgconn.PgError{Code: "40P01"})))
+	require.True(t, ds.IsTransientError(&pgconn.PgError{Code: "40001"}))
+	require.True(t, ds.IsTransientError(&pgconn.PgError{Code: "08006"}))
+	require.True(t, ds.IsTransientError(HandleSQLError(driver.ErrBadConn)))
+
+	require.False(t, ds.IsTransientError(&pgconn.PgError{Code: "23505"}))
+	require.False(t, ds.IsTransientError(HandleSQLError(sql.ErrNoRows)))
+	require.False(t, ds.IsTransientError(context.DeadlineExceeded))
+}
@@ -0,0 +1,119 @@
+package sqlcommon
+
+import (
+	"database/sql"
+	"sync"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/openfga/openfga/internal/build"
+)
+
+var (
+	poolLabels = []string{"datastore", "pool"}
+
+	poolAcquiredConnectionsDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(build.ProjectName, "datastore_pool", "acquired_connections"),
+		"The number of connections of the datastore connection pool that are in use.",
+		poolLabels, nil,
+	)
+
+	poolIdleConnectionsDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(build.ProjectName, "datastore_pool", "idle_connections"),
+		"The number of idle connections of the datastore connection pool.",
+		poolLabels, nil,
+	)
+
+	poolTotalConnectionsDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(build.ProjectName, "datastore_pool", "total_connections"),
+		"The number of established connections of the datastore connection pool, both in use and idle.",
+		poolLabels, nil,
+	)
+
+	poolWaitCountDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(build.ProjectName, "datastore_pool", "wait_count"),
+		"The number of connections waited for because the datastore connection pool was exhausted, since the pool was opened.",
+		poolLabels, nil,
+	)
+
+	poolWaitDurationDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(build.ProjectName, "datastore_pool", "wait_duration_seconds"),
+		"The time spent waiting for a connection of the datastore connection pool, since the pool was opened.",
+		poolLabels, nil,
+	)
+
+	poolStats = &poolStatsCollector{pools: map[*sql.DB]poolKey{}}
+
+	registerPoolStats sync.Once
+)
+
+// poolKey identifies the connection pools whose statistics are exported together, e.g. the pool of the
+// primary database of a Postgres datastore.
+type poolKey struct {
+	datastore string
+	pool      string
+}
+
+// poolStatsCollector samples the statistics of the registered connection pools when it is collected.
+// The pools with the same key, e.g. those of several datastores of a test, are exported as one. All the
+// statistics are gauges: the wait count and duration of a key drop when one of its pools is closed.
+type poolStatsCollector struct {
+	mu    sync.Mutex
+	pools map[*sql.DB]poolKey
+}
+
+var _ prometheus.Collector = (*poolStatsCollector)(nil)
+
+// Describe see [prometheus.Collector].Describe.
+func (c *poolStatsCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- poolAcquiredConnectionsDesc
+	ch <- poolIdleConnectionsDesc
+	ch <- poolTotalConnectionsDesc
+	ch <- poolWaitCountDesc
+	ch <- poolWaitDurationDesc
+}
+
+// Collect see [prometheus.Collector].Collect.
+func (c *poolStatsCollector) Collect(ch chan<- prometheus.Metric) {
+	c.mu.Lock()
+	stats := make(map[poolKey]sql.DBStats, len(c.pools))
+	for db, key := range c.pools {
+		dbStats := db.Stats()
+		total := stats[key]
+		total.InUse += dbStats.InUse
+		total.Idle += dbStats.Idle
+		total.OpenConnections += dbStats.OpenConnections
+		total.WaitCount += dbStats.WaitCount
+		total.WaitDuration += dbStats.WaitDuration
+		stats[key] = total
+	}
+	c.mu.Unlock()
+
+	for key, s := range stats {
+		ch <- prometheus.MustNewConstMetric(poolAcquiredConnectionsDesc, prometheus.GaugeValue, float64(s.InUse), key.datastore, key.pool)
+		ch <- prometheus.MustNewConstMetric(poolIdleConnectionsDesc, prometheus.GaugeValue, float64(s.Idle), key.datastore, key.pool)
+		ch <- prometheus.MustNewConstMetric(poolTotalConnectionsDesc, prometheus.GaugeValue, float64(s.OpenConnections), key.datastore, key.pool)
+		ch <- prometheus.MustNewConstMetric(poolWaitCountDesc, prometheus.GaugeValue, float64(s.WaitCount), key.datastore, key.pool)
+		ch <- prometheus.MustNewConstMetric(poolWaitDurationDesc, prometheus.GaugeValue, s.WaitDuration.Seconds(), key.datastore, key.pool)
+	}
+}
+
+// RegisterPoolMetrics exports the statistics of the connection pool of the database under the openfga_datastore_pool
+// metrics, labeled with the datastore, e.g. 'postgres', and the pool, e.g. 'primary'. It returns the function that
+// stops exporting them, which must be called when the database is closed. The SQL datastores register their pools
+// with Config.ExportMetrics.
+func RegisterPoolMetrics(db *sql.DB, datastore, pool string) (unregister func()) {
+	registerPoolStats.Do(func() {
+		prometheus.MustRegister(poolStats)
+	})
+
+	poolStats.mu.Lock()
+	poolStats.pools[db] = poolKey{datastore: datastore, pool: pool}
+	poolStats.mu.Unlock()
+
+	return func() {
+		poolStats.mu.Lock()
+		delete(poolStats.pools, db)
+		poolStats.mu.Unlock()
+	}
+}
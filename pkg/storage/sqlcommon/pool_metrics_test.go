@@ -0,0 +1,71 @@
+package sqlcommon
+
+import (
+	"context"
+	"database/sql"
+	"strings"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/require"
+	_ "modernc.org/sqlite" // SQLite driver.
+)
+
+func TestRegisterPoolMetrics(t *testing.T) {
+	open := func(t *testing.T) *sql.DB {
+		db, err := sql.Open("sqlite", ":memory:")
+		require.NoError(t, err)
+		t.Cleanup(func() {
+			_ = db.Close()
+		})
+		return db
+	}
+
+	primary1, primary2, secondary := open(t), open(t), open(t)
+
+	unregisterPrimary1 := RegisterPoolMetrics(primary1, "postgres", "primary")
+	unregisterPrimary2 := RegisterPoolMetrics(primary2, "postgres", "primary")
+	unregisterSecondary := RegisterPoolMetrics(secondary, "postgres", "secondary")
+
+	// each pool of the primary holds a connection in use, the pool of the secondary an idle one
+	ctx := context.Background()
+	for _, db := range []*sql.DB{primary1, primary2} {
+		conn, err := db.Conn(ctx)
+		require.NoError(t, err)
+		t.Cleanup(func() {
+			_ = conn.Close()
+		})
+	}
+	require.NoError(t, secondary.PingContext(ctx))
+
+	expected := `
+		# HELP openfga_datastore_pool_acquired_connections The number of connections of the datastore connection pool that are in use.
+		# TYPE openfga_datastore_pool_acquired_connections gauge
+		openfga_datastore_pool_acquired_connections{datastore="postgres",pool="primary"} 2
+		openfga_datastore_pool_acquired_connections{datastore="postgres",pool="secondary"} 0
+		# HELP openfga_datastore_pool_idle_connections The number of idle connections of the datastore connection pool.
+		# TYPE openfga_datastore_pool_idle_connections gauge
+		openfga_datastore_pool_idle_connections{datastore="postgres",pool="primary"} 0
+		openfga_datastore_pool_idle_connections{datastore="postgres",pool="secondary"} 1
+		# HELP openfga_datastore_pool_total_connections The number of established connections of the datastore connection pool, both in use and idle.
+		# TYPE openfga_datastore_pool_total_connections gauge
+		openfga_datastore_pool_total_connections{datastore="postgres",pool="primary"} 2
+		openfga_datastore_pool_total_connections{datastore="postgres",pool="secondary"} 1
+		# HELP openfga_datastore_pool_wait_count The number of connections waited for because the datastore connection pool was exhausted, since the pool was opened.
+		# TYPE openfga_datastore_pool_wait_count gauge
+		openfga_datastore_pool_wait_count{datastore="postgres",pool="primary"} 0
+		openfga_datastore_pool_wait_count{datastore="postgres",pool="secondary"} 0
+	`
+	metrics := []string{
+		"openfga_datastore_pool_acquired_connections",
+		"openfga_datastore_pool_idle_connections",
+		"openfga_datastore_pool_total_connections",
+		"openfga_datastore_pool_wait_count",
+	}
+	require.NoError(t, testutil.CollectAndCompare(poolStats, strings.NewReader(expected), metrics...))
+
+	unregisterPrimary1()
+	unregisterPrimary2()
+	unregisterSecondary()
+	require.Zero(t, testutil.CollectAndCount(poolStats))
+}
@@ -3,12 +3,15 @@ package sqlcommon
 import (
 	"context"
 	"database/sql"
+	"database/sql/driver"
 	"encoding/json"
 	"errors"
+	"io"
 	"sort"
 	"strconv"
 	"strings"
 	"sync"
+	"syscall"
 	"time"
 
 	sq "github.com/Masterminds/squirrel"
@@ -21,7 +24,6 @@ import (
 
 	openfgav1 "github.com/openfga/api/proto/openfga/v1"
 
-	"github.com/openfga/openfga/internal/build"
 	"github.com/openfga/openfga/pkg/encoder"
 	"github.com/openfga/openfga/pkg/logger"
 	"github.com/openfga/openfga/pkg/storage"
@@ -47,6 +49,22 @@ type Config struct {
 	ConnMaxIdleTime time.Duration
 	ConnMaxLifetime time.Duration
 
+	// ReadTimeout bounds each call to ReadUserTuple, ReadUsersetTuples and ReadStartingWithUser.
+	// WriteTimeout bounds each call to Write. A zero timeout doesn't bound the calls.
+	// They are honored by the Postgres and MySQL datastores.
+	ReadTimeout  time.Duration
+	WriteTimeout time.Duration
+
+	// ReplicaURIs are the URIs of the read replicas of the primary database, to which the tuple and
+	// authorization model reads are routed, except those with HIGHER_CONSISTENCY, which may not be
+	// served stale data. They are honored by the MySQL datastore.
+	ReplicaURIs []string
+	// ReplicaFallbackToPrimary routes the reads to the primary database while every replica is unhealthy,
+	// instead of failing them.
+	ReplicaFallbackToPrimary bool
+
+	// ExportMetrics exports the metrics of the connection pools of the datastore, both the database/sql
+	// collector ones and the openfga_datastore_pool ones, see RegisterPoolMetrics.
 	ExportMetrics bool
 }
 
@@ -152,6 +170,38 @@ func WithMetrics() DatastoreOption {
 	}
 }
 
+// WithReadTimeout returns a DatastoreOption that sets
+// the timeout of each tuple read operation in the Config.
+func WithReadTimeout(d time.Duration) DatastoreOption {
+	return func(cfg *Config) {
+		cfg.ReadTimeout = d
+	}
+}
+
+// WithWriteTimeout returns a DatastoreOption that sets
+// the timeout of each tuple write operation in the Config.
+func WithWriteTimeout(d time.Duration) DatastoreOption {
+	return func(cfg *Config) {
+		cfg.WriteTimeout = d
+	}
+}
+
+// WithReplicaURIs returns a DatastoreOption that sets
+// the URIs of the read replicas in the Config.
+func WithReplicaURIs(uris ...string) DatastoreOption {
+	return func(cfg *Config) {
+		cfg.ReplicaURIs = uris
+	}
+}
+
+// WithReplicaFallbackToPrimary returns a DatastoreOption that sets whether
+// reads fall back to the primary database while every replica is unhealthy in the Config.
+func WithReplicaFallbackToPrimary(enabled bool) DatastoreOption {
+	return func(cfg *Config) {
+		cfg.ReplicaFallbackToPrimary = enabled
+	}
+}
+
 // NewConfig creates a new Config instance with default values
 // and applies any provided DatastoreOption modifications.
 func NewConfig(opts ...DatastoreOption) *Config {
@@ -227,6 +277,22 @@ type SQLTupleIterator struct {
 	// will use this item instead. Otherwise, the first item will be lost.
 	firstRow *storage.TupleRecord // GUARDED_BY(mu)
 	mu       sync.Mutex
+
+	timeout OperationTimeout
+	// queryCtx is the context of the query, bounded by the timeout.
+	queryCtx    context.Context    // GUARDED_BY(mu)
+	cancelQuery context.CancelFunc // GUARDED_BY(mu)
+}
+
+// SQLTupleIteratorOption defines a function type used for configuring a SQLTupleIterator.
+type SQLTupleIteratorOption func(*SQLTupleIterator)
+
+// WithSQLTupleIteratorTimeout bounds the query of the iterator, from the moment it is sent until the
+// iterator is stopped, by the given timeout.
+func WithSQLTupleIteratorTimeout(timeout OperationTimeout) SQLTupleIteratorOption {
+	return func(t *SQLTupleIterator) {
+		t.timeout = timeout
+	}
 }
 
 // Ensures that SQLTupleIterator implements the TupleIterator interface.
@@ -251,23 +317,37 @@ func SQLIteratorColumns() []string {
 }
 
 // NewSQLTupleIterator returns a SQL tuple iterator.
-func NewSQLTupleIterator(sb sq.SelectBuilder, errHandler errorHandlerFn) *SQLTupleIterator {
-	return &SQLTupleIterator{
+func NewSQLTupleIterator(sb sq.SelectBuilder, errHandler errorHandlerFn, opts ...SQLTupleIteratorOption) *SQLTupleIterator {
+	iter := &SQLTupleIterator{
 		sb:             sb,
 		rows:           nil,
 		handleSQLError: errHandler,
 		firstRow:       nil,
 		mu:             sync.Mutex{},
 	}
+
+	for _, opt := range opts {
+		opt(iter)
+	}
+
+	return iter
+}
+
+// handleError translates an error of the query, reporting if it was caused by the iterator timeout.
+func (t *SQLTupleIterator) handleError(err error) error {
+	return t.timeout.HandleError(t.queryCtx, t.handleSQLError(err))
 }
 
 func (t *SQLTupleIterator) fetchBuffer(ctx context.Context) error {
 	ctx, span := tracer.Start(ctx, "sqlcommon.fetchBuffer", trace.WithAttributes())
 	defer span.End()
 	ctx = context.WithoutCancel(ctx)
-	rows, err := t.sb.QueryContext(ctx)
+	t.queryCtx, t.cancelQuery = t.timeout.WithContext(ctx)
+	rows, err := t.sb.QueryContext(t.queryCtx)
 	if err != nil {
-		return t.handleSQLError(err)
+		err = t.handleError(err)
+		t.cancelQuery()
+		return err
 	}
 	t.rows = rows
 	return nil
@@ -303,7 +383,7 @@ func (t *SQLTupleIterator) next(ctx context.Context) (*storage.TupleRecord, erro
 		err := t.rows.Err()
 		t.mu.Unlock()
 		if err != nil {
-			return nil, t.handleSQLError(err)
+			return nil, t.handleError(err)
 		}
 		return nil, storage.ErrIteratorDone
 	}
@@ -325,7 +405,7 @@ func (t *SQLTupleIterator) next(ctx context.Context) (*storage.TupleRecord, erro
 	t.mu.Unlock()
 
 	if err != nil {
-		return nil, t.handleSQLError(err)
+		return nil, t.handleError(err)
 	}
 
 	record.ConditionName = conditionName.String
@@ -367,7 +447,7 @@ func (t *SQLTupleIterator) head(ctx context.Context) (*storage.TupleRecord, erro
 
 	if !t.rows.Next() {
 		if err := t.rows.Err(); err != nil {
-			return nil, t.handleSQLError(err)
+			return nil, t.handleError(err)
 		}
 		return nil, storage.ErrIteratorDone
 	}
@@ -387,7 +467,7 @@ func (t *SQLTupleIterator) head(ctx context.Context) (*storage.TupleRecord, erro
 		&record.InsertedAt,
 	)
 	if err != nil {
-		return nil, t.handleSQLError(err)
+		return nil, t.handleError(err)
 	}
 
 	record.ConditionName = conditionName.String
@@ -470,6 +550,9 @@ func (t *SQLTupleIterator) Stop() {
 	if t.rows != nil {
 		_ = t.rows.Close()
 	}
+	if t.cancelQuery != nil {
+		t.cancelQuery()
+	}
 }
 
 // DBInfo encapsulates DB information for use in common method.
@@ -606,6 +689,37 @@ func selectExistingRowsForWrite(ctx context.Context, dbInfo *DBInfo, store strin
 	return nil
 }
 
+// WriteBestEffort provides the common method for writing tuples without a transaction spanning all of them
+// across sql storage, see [storage.BestEffortTupleWriter]. The tuples are written in a single transaction
+// first, as most writes succeed as a whole, and otherwise each in its own transaction.
+func WriteBestEffort(
+	ctx context.Context,
+	dbInfo *DBInfo,
+	store string,
+	writes storage.Writes,
+	now time.Time,
+) ([]storage.TupleWriteResult, error) {
+	opts := storage.NewTupleWriteOptions()
+
+	results := make([]storage.TupleWriteResult, 0, len(writes))
+	if err := Write(ctx, dbInfo, store, nil, writes, opts, now); err == nil {
+		for _, tk := range writes {
+			results = append(results, storage.NewTupleWriteResult(tk, nil))
+		}
+		return results, nil
+	}
+
+	for _, tk := range writes {
+		if err := ctx.Err(); err != nil {
+			return results, err
+		}
+
+		err := Write(ctx, dbInfo, store, nil, storage.Writes{tk}, opts, now)
+		results = append(results, storage.NewTupleWriteResult(tk, err))
+	}
+	return results, nil
+}
+
 // Write provides the common method for writing to database across sql storage.
 func Write(
 	ctx context.Context,
@@ -716,7 +830,9 @@ func Write(
 	// - Based on the results from step 3.a, which identified and locked existing rows, the system will compare values to the ones we’re trying to insert
 	// - On conflict ( values not identical ) - return an error 409 Conflict
 	// - For rows that DO NOT exist in DB - create both INSERT tuple & INSERT changelog statements
-	// c. Execute INSERTs as a single statement
+	// c. If on_duplicate: upsert
+	// - Identical rows are a no-op, rows with a different condition are DELETEd and INSERTed again
+	// d. Execute INSERTs as a single statement
 	//   On error, return 409 Conflict
 	for _, tk := range writes {
 		if existingTuple, ok := existing[tupleUtils.TupleKeyToString(tk)]; ok {
@@ -730,6 +846,19 @@ func Write(
 				}
 				// If tuple conditions are different, we throw an error.
 				return storage.TupleConditionConflictError(tk)
+			case storage.OnDuplicateInsertUpsert:
+				if proto.Equal(existingTuple.GetKey().GetCondition(), tk.GetCondition()) {
+					continue
+				}
+				// Replace the existing tuple, deletes are executed before the inserts.
+				objectType, objectID := tupleUtils.SplitObject(tk.GetObject())
+				deleteConditions = append(deleteConditions, sq.Eq{
+					"object_type": objectType,
+					"object_id":   objectID,
+					"relation":    tk.GetRelation(),
+					"_user":       tk.GetUser(),
+					"user_type":   tupleUtils.GetUserTypeFromUser(tk.GetUser()),
+				})
 			case storage.OnDuplicateInsertError:
 				fallthrough
 			default:
@@ -915,6 +1044,191 @@ func WriteAuthorizationModel(
 	return nil
 }
 
+// changelogCompactionBatchSize is the maximum number of changes that CompactChangeLog removes per
+// statement, so that the compaction of a large changelog doesn't hold long locks on the changelog.
+const changelogCompactionBatchSize = 1000
+
+// CompactChangeLog removes the changes of a store that occurred before olderThan and records the
+// compaction horizon, see [storage.ChangelogBackend].CompactChangeLog. The upsertHorizonSuffix is the
+// dialect-specific suffix that turns the insert of the horizon into an upsert that never moves the
+// horizon backwards.
+//
+// The horizon is recorded before any change is removed, so that reading changes from before the
+// horizon fails with [storage.ErrChangelogCompacted] rather than silently skipping the changes of an
+// ongoing compaction, while continuation tokens past the horizon stay valid. The changes are then
+// removed in batches of changelogCompactionBatchSize, each in its own statement. A compaction that
+// fails midway can be resumed by compacting again.
+func CompactChangeLog(
+	ctx context.Context,
+	dbInfo *DBInfo,
+	store string,
+	olderThan time.Time,
+	upsertHorizonSuffix string,
+) error {
+	horizon, err := ulid.New(ulid.Timestamp(olderThan), nil)
+	if err != nil {
+		return storage.ErrInvalidStartTime
+	}
+
+	_, err = dbInfo.stbl.
+		Insert("changelog_horizon").
+		Columns("store", "ulid").
+		Values(store, horizon.String()).
+		Suffix(upsertHorizonSuffix).
+		ExecContext(ctx)
+	if err != nil {
+		return dbInfo.HandleSQLError(err)
+	}
+
+	for {
+		// the changes of a batch are the ones up to the ulid of its last change, which isn't portable
+		// as a DELETE with a LIMIT
+		var last string
+		err := dbInfo.stbl.
+			Select("ulid").
+			From("changelog").
+			Where(sq.Eq{"store": store}).
+			Where(sq.Lt{"ulid": horizon.String()}).
+			OrderBy("ulid").
+			Limit(1).
+			Offset(changelogCompactionBatchSize - 1).
+			QueryRowContext(ctx).
+			Scan(&last)
+		if err != nil && !errors.Is(err, sql.ErrNoRows) {
+			return dbInfo.HandleSQLError(err)
+		}
+
+		batch := sq.And{sq.Eq{"store": store}, sq.Lt{"ulid": horizon.String()}}
+		if last != "" {
+			batch = append(batch, sq.LtOrEq{"ulid": last})
+		}
+
+		_, err = dbInfo.stbl.
+			Delete("changelog").
+			Where(batch).
+			ExecContext(ctx)
+		if err != nil {
+			return dbInfo.HandleSQLError(err)
+		}
+
+		if last == "" {
+			// the last batch had fewer than changelogCompactionBatchSize changes
+			return nil
+		}
+	}
+}
+
+// AddChangelogHorizonCheck filters out every change of a read of the changelog of the store that starts
+// before its compaction horizon, see CompactChangeLog, so that the horizon is checked within the query of
+// the changes. A read that returns no changes tells a compacted changelog apart from an empty one with
+// CheckChangelogHorizon.
+func AddChangelogHorizonCheck(sb sq.SelectBuilder, store string, fromUlid string) sq.SelectBuilder {
+	return sb.Where(sq.Expr(
+		"NOT EXISTS (SELECT 1 FROM changelog_horizon WHERE changelog_horizon.store = ? AND changelog_horizon.ulid > ?)",
+		store, fromUlid,
+	))
+}
+
+// CheckChangelogHorizon returns [storage.ErrChangelogCompacted] if changes after the fromUlid
+// may have been removed from the changelog of the store by CompactChangeLog.
+func CheckChangelogHorizon(
+	ctx context.Context,
+	stbl sq.StatementBuilderType,
+	store string,
+	fromUlid string,
+	handleSQLError errorHandlerFn,
+) error {
+	var horizon string
+	err := stbl.
+		Select("ulid").
+		From("changelog_horizon").
+		Where(sq.Eq{"store": store}).
+		QueryRowContext(ctx).
+		Scan(&horizon)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil
+		}
+		return handleSQLError(err)
+	}
+
+	if fromUlid < horizon {
+		return storage.ErrChangelogCompacted
+	}
+
+	return nil
+}
+
+// WriteStoreMetadata replaces the metadata of a store, see [storage.StoresBackend].WriteStoreMetadata.
+func WriteStoreMetadata(ctx context.Context, dbInfo *DBInfo, store string, metadata *storage.StoreMetadata) error {
+	marshalledMetadata, err := json.Marshal(metadata)
+	if err != nil {
+		return err
+	}
+
+	res, err := dbInfo.stbl.
+		Update("store").
+		Set("metadata", marshalledMetadata).
+		Where(sq.Eq{
+			"id":         store,
+			"deleted_at": nil,
+		}).
+		ExecContext(ctx)
+	if err != nil {
+		return dbInfo.HandleSQLError(err)
+	}
+
+	rowsAffected, err := res.RowsAffected()
+	if err != nil {
+		return dbInfo.HandleSQLError(err)
+	}
+
+	if rowsAffected == 0 {
+		// some databases don't count the rows whose values didn't change, so no affected rows
+		// doesn't necessarily mean that the store doesn't exist
+		_, err = ReadStoreMetadata(ctx, dbInfo.stbl, store, dbInfo.HandleSQLError)
+		return err
+	}
+
+	return nil
+}
+
+// ReadStoreMetadata returns the metadata of a store, see [storage.StoresBackend].ReadStoreMetadata.
+func ReadStoreMetadata(
+	ctx context.Context,
+	stbl sq.StatementBuilderType,
+	store string,
+	handleSQLError errorHandlerFn,
+) (*storage.StoreMetadata, error) {
+	var marshalledMetadata []byte
+	err := stbl.
+		Select("metadata").
+		From("store").
+		Where(sq.Eq{
+			"id":         store,
+			"deleted_at": nil,
+		}).
+		QueryRowContext(ctx).
+		Scan(&marshalledMetadata)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, storage.ErrNotFound
+		}
+		return nil, handleSQLError(err)
+	}
+
+	var metadata storage.StoreMetadata
+	if len(marshalledMetadata) == 0 {
+		return &metadata, nil
+	}
+
+	if err := json.Unmarshal(marshalledMetadata, &metadata); err != nil {
+		return nil, err
+	}
+
+	return &metadata, nil
+}
+
 // constructAuthorizationModelFromSQLRows tries first to read and return a model that was written in one row (the new format).
 // If it can't find one, it will then look for a model that was written across multiple rows (the old format).
 func constructAuthorizationModelFromSQLRows(rows *sql.Rows) (*openfgav1.AuthorizationModel, error) {
@@ -1036,8 +1350,8 @@ func ReadAuthorizationModel(
 }
 
 // IsReady returns true if connection to datastore is successful AND
-// (the datastore has the latest migration applied OR skipVersionCheck).
-func IsReady(ctx context.Context, skipVersionCheck bool, db *sql.DB) (storage.ReadinessStatus, error) {
+// (the datastore has the minimumRevision migration applied OR skipVersionCheck).
+func IsReady(ctx context.Context, skipVersionCheck bool, db *sql.DB, minimumRevision int64) (storage.ReadinessStatus, error) {
 	ctx, cancel := context.WithTimeout(ctx, 2*time.Second)
 	defer cancel()
 
@@ -1047,9 +1361,18 @@ func IsReady(ctx context.Context, skipVersionCheck bool, db *sql.DB) (storage.Re
 		return storage.ReadinessStatus{}, pingErr
 	}
 
+	// a ping may be answered by the driver without a round trip, the query exercises the database
+	start := time.Now()
+	var one int
+	if err := db.QueryRowContext(ctx, "SELECT 1").Scan(&one); err != nil {
+		return storage.ReadinessStatus{}, err
+	}
+	latency := time.Since(start)
+
 	if skipVersionCheck {
 		return storage.ReadinessStatus{
 			IsReady: true,
+			Latency: latency,
 		}, nil
 	}
 
@@ -1058,21 +1381,32 @@ func IsReady(ctx context.Context, skipVersionCheck bool, db *sql.DB) (storage.Re
 		return storage.ReadinessStatus{}, err
 	}
 
-	if revision < build.MinimumSupportedDatastoreSchemaRevision {
+	if revision < minimumRevision {
 		return storage.ReadinessStatus{
 			Message: "datastore requires migrations: at revision '" +
 				strconv.FormatInt(revision, 10) +
 				"', but requires '" +
-				strconv.FormatInt(build.MinimumSupportedDatastoreSchemaRevision, 10) +
+				strconv.FormatInt(minimumRevision, 10) +
 				"'. Run 'openfga migrate'.",
 			IsReady: false,
+			Latency: latency,
 		}, nil
 	}
 	return storage.ReadinessStatus{
 		IsReady: true,
+		Latency: latency,
 	}, nil
 }
 
+// IsTransientConnError reports whether err is an error of the connection to the database that a new
+// connection may not have, e.g. a connection that was reset or closed by the database.
+func IsTransientConnError(err error) bool {
+	return errors.Is(err, driver.ErrBadConn) ||
+		errors.Is(err, io.ErrUnexpectedEOF) ||
+		errors.Is(err, syscall.ECONNRESET) ||
+		errors.Is(err, syscall.ECONNREFUSED)
+}
+
 func AddFromUlid(sb sq.SelectBuilder, fromUlid string, sortDescending bool) sq.SelectBuilder {
 	if sortDescending {
 		return sb.Where(sq.Lt{"ulid": fromUlid})
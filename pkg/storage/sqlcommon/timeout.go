@@ -0,0 +1,81 @@
+package sqlcommon
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// OperationKind is the kind of a datastore operation, which determines the timeout applied to it.
+type OperationKind string
+
+const (
+	ReadOperation  OperationKind = "read"
+	WriteOperation OperationKind = "write"
+)
+
+// OperationTimeoutError is returned when a datastore operation doesn't complete within the timeout
+// configured for its kind of operation. It wraps context.DeadlineExceeded.
+type OperationTimeoutError struct {
+	// Operation is the datastore method that timed out, e.g. 'ReadUserTuple'.
+	Operation string
+	// Kind is the kind of the timeout that fired.
+	Kind    OperationKind
+	Timeout time.Duration
+}
+
+func (e *OperationTimeoutError) Error() string {
+	return fmt.Sprintf("%s exceeded the datastore %s timeout of %s", e.Operation, e.Kind, e.Timeout)
+}
+
+func (e *OperationTimeoutError) Unwrap() error {
+	return context.DeadlineExceeded
+}
+
+// OperationTimeout bounds a single datastore operation by the timeout configured for its kind.
+// The zero value, or a zero Timeout, doesn't bound the operation.
+type OperationTimeout struct {
+	Operation string
+	Kind      OperationKind
+	Timeout   time.Duration
+}
+
+// NewReadTimeout returns the OperationTimeout of the read operation.
+func NewReadTimeout(operation string, timeout time.Duration) OperationTimeout {
+	return OperationTimeout{Operation: operation, Kind: ReadOperation, Timeout: timeout}
+}
+
+// NewWriteTimeout returns the OperationTimeout of the write operation.
+func NewWriteTimeout(operation string, timeout time.Duration) OperationTimeout {
+	return OperationTimeout{Operation: operation, Kind: WriteOperation, Timeout: timeout}
+}
+
+// WithContext returns a copy of ctx that is cancelled when the timeout elapses.
+// Callers must call the returned cancel function once the operation completes.
+func (o OperationTimeout) WithContext(ctx context.Context) (context.Context, context.CancelFunc) {
+	if o.Timeout <= 0 {
+		return ctx, func() {}
+	}
+
+	return context.WithTimeoutCause(ctx, o.Timeout, &OperationTimeoutError{
+		Operation: o.Operation,
+		Kind:      o.Kind,
+		Timeout:   o.Timeout,
+	})
+}
+
+// HandleError returns an OperationTimeoutError if err was caused by the timeout of ctx, which must
+// have been returned by WithContext. Otherwise, it returns err as is.
+func (o OperationTimeout) HandleError(ctx context.Context, err error) error {
+	if err == nil || ctx == nil {
+		return err
+	}
+
+	var timeoutErr *OperationTimeoutError
+	if errors.As(context.Cause(ctx), &timeoutErr) {
+		return timeoutErr
+	}
+
+	return err
+}
@@ -30,6 +30,10 @@ import (
 	tupleUtils "github.com/openfga/openfga/pkg/tuple"
 )
 
+// MinimumSupportedSchemaRevision is the schema revision that is required to run this build of OpenFGA with
+// a SQLite datastore, i.e. the latest of the `assets/migrations/sqlite` migrations, which the queries depend on.
+const MinimumSupportedSchemaRevision int64 = 7
+
 var tracer = otel.Tracer("openfga/pkg/storage/sqlite")
 
 func startTrace(ctx context.Context, name string) (context.Context, trace.Span) {
@@ -52,6 +56,8 @@ type Datastore struct {
 	maxTuplesPerWriteField int
 	maxTypesPerModelField  int
 	versionReady           bool
+	// unregisterPoolMetrics stops exporting the pool metrics of the database, see sqlcommon.RegisterPoolMetrics.
+	unregisterPoolMetrics func()
 }
 
 // Ensures that SQLite implements the OpenFGADatastore interface.
@@ -116,12 +122,16 @@ func New(uri string, cfg *sqlcommon.Config) (*Datastore, error) {
 
 // NewWithDB creates a new [Datastore] storage with the provided database connection.
 func NewWithDB(db *sql.DB, cfg *sqlcommon.Config) (*Datastore, error) {
-	var collector prometheus.Collector
+	var (
+		collector             prometheus.Collector
+		unregisterPoolMetrics func()
+	)
 	if cfg.ExportMetrics {
 		collector = collectors.NewDBStatsCollector(db, "openfga")
 		if err := prometheus.Register(collector); err != nil {
 			return nil, fmt.Errorf("initialize metrics: %w", err)
 		}
+		unregisterPoolMetrics = sqlcommon.RegisterPoolMetrics(db, "sqlite", "primary")
 	}
 
 	stbl := sq.StatementBuilder.RunWith(db)
@@ -133,6 +143,7 @@ func NewWithDB(db *sql.DB, cfg *sqlcommon.Config) (*Datastore, error) {
 		dbInfo:                 dbInfo,
 		logger:                 cfg.Logger,
 		dbStatsCollector:       collector,
+		unregisterPoolMetrics:  unregisterPoolMetrics,
 		maxTuplesPerWriteField: cfg.MaxTuplesPerWriteField,
 		maxTypesPerModelField:  cfg.MaxTypesPerModelField,
 		versionReady:           false,
@@ -144,6 +155,9 @@ func (s *Datastore) Close() {
 	if s.dbStatsCollector != nil {
 		prometheus.Unregister(s.dbStatsCollector)
 	}
+	if s.unregisterPoolMetrics != nil {
+		s.unregisterPoolMetrics()
+	}
 	_ = s.db.Close()
 }
 
@@ -486,7 +500,9 @@ func (s *Datastore) write(
 	// - Based on the results from step 3.a, which identified and locked existing rows, the system will compare values to the ones we’re trying to insert
 	// - On conflict ( values not identical ) - return an error 409 Conflict
 	// - For rows that DO NOT exist in DB - create both INSERT tuple & INSERT changelog statements
-	// c. Execute INSERTs as a single statement
+	// c. If on_duplicate: upsert
+	// - Identical rows are a no-op, rows with a different condition are DELETEd and INSERTed again
+	// d. Execute INSERTs as a single statement
 	//   On error, return 409 Conflict
 	for _, tk := range writes {
 		if existingTuple, ok := existing[tupleUtils.TupleKeyToString(tk)]; ok {
@@ -500,6 +516,22 @@ func (s *Datastore) write(
 				}
 				// If tuple conditions are different, we throw an error.
 				return storage.TupleConditionConflictError(tk)
+			case storage.OnDuplicateInsertUpsert:
+				if proto.Equal(existingTuple.GetKey().GetCondition(), tk.GetCondition()) {
+					continue
+				}
+				// Replace the existing tuple, deletes are executed before the inserts.
+				objectType, objectID := tupleUtils.SplitObject(tk.GetObject())
+				userObjectType, userObjectID, userRelation := tupleUtils.ToUserParts(tk.GetUser())
+				deleteConditions = append(deleteConditions, sq.Eq{
+					"object_type":      objectType,
+					"object_id":        objectID,
+					"relation":         tk.GetRelation(),
+					"user_object_type": userObjectType,
+					"user_object_id":   userObjectID,
+					"user_relation":    userRelation,
+					"user_type":        tupleUtils.GetUserTypeFromUser(tk.GetUser()),
+				})
 			case storage.OnDuplicateInsertError:
 				fallthrough
 			default:
@@ -1064,6 +1096,10 @@ func (s *Datastore) ListStores(ctx context.Context, options storage.ListStoresOp
 		whereClause = append(whereClause, sq.Eq{"name": options.Name})
 	}
 
+	if options.NameContains != "" {
+		whereClause = append(whereClause, sq.Expr("instr(name, ?) > 0", options.NameContains))
+	}
+
 	if options.Pagination.From != "" {
 		whereClause = append(whereClause, sq.GtOrEq{"id": options.Pagination.From})
 	}
@@ -1127,9 +1163,34 @@ func (s *Datastore) DeleteStore(ctx context.Context, id string) error {
 		return HandleSQLError(err)
 	}
 
+	// the changelog compaction horizon of the store isn't kept once it's deleted
+	_, err = s.stbl.
+		Delete("changelog_horizon").
+		Where(sq.Eq{"store": id}).
+		ExecContext(ctx)
+	if err != nil {
+		return HandleSQLError(err)
+	}
+
 	return nil
 }
 
+// WriteStoreMetadata see [storage.StoresBackend].WriteStoreMetadata.
+func (s *Datastore) WriteStoreMetadata(ctx context.Context, store string, metadata *storage.StoreMetadata) error {
+	ctx, span := startTrace(ctx, "WriteStoreMetadata")
+	defer span.End()
+
+	return sqlcommon.WriteStoreMetadata(ctx, s.dbInfo, store, metadata)
+}
+
+// ReadStoreMetadata see [storage.StoresBackend].ReadStoreMetadata.
+func (s *Datastore) ReadStoreMetadata(ctx context.Context, store string) (*storage.StoreMetadata, error) {
+	ctx, span := startTrace(ctx, "ReadStoreMetadata")
+	defer span.End()
+
+	return sqlcommon.ReadStoreMetadata(ctx, s.stbl, store, HandleSQLError)
+}
+
 // WriteAssertions see [storage.AssertionsBackend].WriteAssertions.
 func (s *Datastore) WriteAssertions(ctx context.Context, store, modelID string, assertions []*openfgav1.Assertion) error {
 	ctx, span := startTrace(ctx, "WriteAssertions")
@@ -1217,6 +1278,7 @@ func (s *Datastore) ReadChanges(ctx context.Context, store string, filter storag
 	}
 	if options.Pagination.From != "" {
 		sb = sqlcommon.AddFromUlid(sb, options.Pagination.From, options.SortDesc)
+		sb = sqlcommon.AddChangelogHorizonCheck(sb, store, options.Pagination.From)
 	}
 	if options.Pagination.PageSize > 0 {
 		sb = sb.Limit(uint64(options.Pagination.PageSize)) // + 1 is NOT used here as we always return a continuation token.
@@ -1279,15 +1341,29 @@ func (s *Datastore) ReadChanges(ctx context.Context, store string, filter storag
 	}
 
 	if len(changes) == 0 {
+		if options.Pagination.From != "" {
+			err := sqlcommon.CheckChangelogHorizon(ctx, s.stbl, store, options.Pagination.From, HandleSQLError)
+			if err != nil {
+				return nil, "", err
+			}
+		}
 		return nil, "", storage.ErrNotFound
 	}
 
 	return changes, ulid, nil
 }
 
+// CompactChangeLog see [storage.ChangelogBackend].CompactChangeLog.
+func (s *Datastore) CompactChangeLog(ctx context.Context, store string, olderThan time.Time) error {
+	ctx, span := startTrace(ctx, "CompactChangeLog")
+	defer span.End()
+
+	return sqlcommon.CompactChangeLog(ctx, s.dbInfo, store, olderThan, "ON CONFLICT (store) DO UPDATE SET ulid = MAX(ulid, excluded.ulid)")
+}
+
 // IsReady see [sqlcommon.IsReady].
 func (s *Datastore) IsReady(ctx context.Context) (storage.ReadinessStatus, error) {
-	versionReady, err := sqlcommon.IsReady(ctx, s.versionReady, s.db)
+	versionReady, err := sqlcommon.IsReady(ctx, s.versionReady, s.db, MinimumSupportedSchemaRevision)
 	if err != nil {
 		return versionReady, err
 	}
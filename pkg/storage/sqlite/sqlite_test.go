@@ -5,6 +5,8 @@ import (
 	"testing"
 	"time"
 
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
 	"github.com/stretchr/testify/require"
 
 	openfgav1 "github.com/openfga/api/proto/openfga/v1"
@@ -50,6 +52,23 @@ func TestSQLiteDatastoreAfterCloseIsNotReady(t *testing.T) {
 }
 
 // TestReadEnsureNoOrder asserts that the read response is not ordered by ulid.
+func TestSQLiteDatastorePoolMetrics(t *testing.T) {
+	testDatastore := storagefixtures.RunDatastoreTestContainer(t, "sqlite")
+
+	uri := testDatastore.GetConnectionURI(true)
+	ds, err := New(uri, sqlcommon.NewConfig(sqlcommon.WithMetrics()))
+	require.NoError(t, err)
+
+	count, err := testutil.GatherAndCount(prometheus.DefaultGatherer, "openfga_datastore_pool_total_connections")
+	require.NoError(t, err)
+	require.Equal(t, 1, count)
+
+	ds.Close()
+	count, err = testutil.GatherAndCount(prometheus.DefaultGatherer, "openfga_datastore_pool_total_connections")
+	require.NoError(t, err)
+	require.Zero(t, count)
+}
+
 func TestReadEnsureNoOrder(t *testing.T) {
 	tests := []struct {
 		name  string
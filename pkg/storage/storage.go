@@ -4,6 +4,8 @@ package storage
 
 import (
 	"context"
+	"errors"
+	"slices"
 	"time"
 
 	openfgav1 "github.com/openfga/api/proto/openfga/v1"
@@ -31,8 +33,22 @@ const (
 	DefaultPageSize = 50
 
 	relationshipTupleReaderCtxKey ctxKey = "relationship-tuple-reader-context-key"
+	consistencyCtxKey             ctxKey = "consistency-context-key"
 )
 
+// ContextWithConsistency sets the consistency preference of the request in the context, for the reads whose
+// options don't carry one, e.g. the reads of the authorization models.
+func ContextWithConsistency(parent context.Context, consistency openfgav1.ConsistencyPreference) context.Context {
+	return context.WithValue(parent, consistencyCtxKey, consistency)
+}
+
+// ConsistencyFromContext returns the consistency preference set with ContextWithConsistency, or
+// ConsistencyPreference_UNSPECIFIED if there is none.
+func ConsistencyFromContext(ctx context.Context) openfgav1.ConsistencyPreference {
+	consistency, _ := ctx.Value(consistencyCtxKey).(openfgav1.ConsistencyPreference)
+	return consistency
+}
+
 // ContextWithRelationshipTupleReader sets the provided [[RelationshipTupleReader]]
 // in the context. The context returned is a new context derived from the parent
 // context provided.
@@ -88,8 +104,60 @@ type ListStoresOptions struct {
 	// IDs is a list of store IDs to filter the results.
 	IDs []string
 	// Name is used to filter the results. If left empty no filter is applied.
-	Name       string
-	Pagination PaginationOptions
+	Name string
+	// NameContains filters the results to the stores whose name contains it, case-sensitively. If left empty no
+	// filter is applied.
+	NameContains string
+	Pagination   PaginationOptions
+}
+
+// StoreMetadata is the configuration of a store that governs how the store may be used.
+type StoreMetadata struct {
+	// ObjectTypeAllowlist restricts the object types of the tuples that may be written to the store.
+	// An empty allowlist allows any object type.
+	ObjectTypeAllowlist []string `json:"object_type_allowlist,omitempty"`
+	// CycleGuardedRelations are the 'objectType#relation' pairs, e.g. 'group#member', whose tuples are
+	// rejected when writing them would introduce a cycle among the objects of the relation.
+	CycleGuardedRelations []string `json:"cycle_guarded_relations,omitempty"`
+	// DefaultConsistency is the consistency preference of the Checks of the store that leave it unspecified.
+	// The consistency preference of a request always wins.
+	DefaultConsistency openfgav1.ConsistencyPreference `json:"default_consistency,omitempty"`
+}
+
+// GetObjectTypeAllowlist returns the object type allowlist, or nil if the metadata is nil.
+func (m *StoreMetadata) GetObjectTypeAllowlist() []string {
+	if m == nil {
+		return nil
+	}
+	return m.ObjectTypeAllowlist
+}
+
+// IsObjectTypeAllowed returns true if tuples of the object type may be written to the store.
+func (m *StoreMetadata) IsObjectTypeAllowed(objectType string) bool {
+	allowlist := m.GetObjectTypeAllowlist()
+	return len(allowlist) == 0 || slices.Contains(allowlist, objectType)
+}
+
+// GetCycleGuardedRelations returns the cycle guarded relations, or nil if the metadata is nil.
+func (m *StoreMetadata) GetCycleGuardedRelations() []string {
+	if m == nil {
+		return nil
+	}
+	return m.CycleGuardedRelations
+}
+
+// IsCycleGuarded returns true if writes of the relation of the object type must not introduce cycles.
+func (m *StoreMetadata) IsCycleGuarded(objectType, relation string) bool {
+	return slices.Contains(m.GetCycleGuardedRelations(), objectType+"#"+relation)
+}
+
+// GetDefaultConsistency returns the default consistency preference, or
+// CONSISTENCY_PREFERENCE_UNSPECIFIED if the metadata is nil.
+func (m *StoreMetadata) GetDefaultConsistency() openfgav1.ConsistencyPreference {
+	if m == nil {
+		return openfgav1.ConsistencyPreference_UNSPECIFIED
+	}
+	return m.DefaultConsistency
 }
 
 // ReadChangesOptions represents the options that can
@@ -137,6 +205,13 @@ type ReadStartingWithUserOptions struct {
 	WithResultsSortedAscending bool
 }
 
+// ReadStartingWithUserPageOptions represents the options that can
+// be used with a paginated variant of the ReadStartingWithUser method.
+type ReadStartingWithUserPageOptions struct {
+	Pagination  PaginationOptions
+	Consistency ConsistencyOptions
+}
+
 // Writes is a typesafe alias for Write arguments.
 type Writes = []*openfgav1.TupleKey
 
@@ -235,6 +310,11 @@ const (
 	// OnDuplicateInsertIgnore indicates that if an insert operation is attempted on a tuple that already exists,
 	// it should be ignored as a no-op and no error should be returned.
 	OnDuplicateInsertIgnore OnDuplicateInsert = 1
+
+	// OnDuplicateInsertUpsert indicates that if an insert operation is attempted on a tuple that already exists,
+	// the condition of the existing tuple should be replaced by the condition of the inserted one. Inserting a
+	// tuple identical to the existing one is a no-op.
+	OnDuplicateInsertUpsert OnDuplicateInsert = 2
 )
 
 // TupleWriteOptions defines the options that can be used when writing tuples.
@@ -286,6 +366,69 @@ type RelationshipTupleWriter interface {
 	MaxTuplesPerWrite() int
 }
 
+// TupleWriteStatus is the outcome of writing a tuple with [BestEffortTupleWriter].WriteBestEffort.
+type TupleWriteStatus int
+
+const (
+	// TupleWriteStatusWritten indicates that the tuple was written.
+	TupleWriteStatusWritten TupleWriteStatus = iota
+
+	// TupleWriteStatusAlreadyExists indicates that the tuple wasn't written because it already existed.
+	TupleWriteStatusAlreadyExists
+
+	// TupleWriteStatusError indicates that the tuple wasn't written because of Err.
+	TupleWriteStatusError
+)
+
+// TupleWriteResult is the outcome of writing a tuple with [BestEffortTupleWriter].WriteBestEffort.
+type TupleWriteResult struct {
+	TupleKey *openfgav1.TupleKey
+	Status   TupleWriteStatus
+	// Err is the reason the tuple wasn't written, set if the Status is TupleWriteStatusError.
+	Err error
+}
+
+// NewTupleWriteResult returns the outcome of writing the tuple on its own with [RelationshipTupleWriter].Write,
+// with the default options, which failed with err if err isn't nil.
+func NewTupleWriteResult(tk *openfgav1.TupleKey, err error) TupleWriteResult {
+	switch {
+	case err == nil:
+		return TupleWriteResult{TupleKey: tk, Status: TupleWriteStatusWritten}
+	case errors.Is(err, ErrInvalidWriteInput):
+		return TupleWriteResult{TupleKey: tk, Status: TupleWriteStatusAlreadyExists}
+	default:
+		return TupleWriteResult{TupleKey: tk, Status: TupleWriteStatusError, Err: err}
+	}
+}
+
+// BestEffortTupleWriter is implemented by the datastores that can write tuples without a transaction spanning
+// all of them. It is optional, see WriteBestEffort.
+type BestEffortTupleWriter interface {
+	// WriteBestEffort writes each of the tuples independently of the others, so that the tuples that can't be
+	// written, e.g. because they already exist, don't prevent the others from being written. It returns the
+	// outcome of each tuple in the order of `writes`, and only returns an error if the write as a whole failed,
+	// in which case some of the tuples may have been written.
+	// It must also write to the changelog.
+	WriteBestEffort(ctx context.Context, store string, writes Writes) ([]TupleWriteResult, error)
+}
+
+// TransientErrorClassifier is implemented by the datastores that can tell the errors of their backend that are
+// transient, e.g. a deadlock or a connection reset, so that the operation that failed with them can be retried.
+type TransientErrorClassifier interface {
+	// IsTransientError reports whether the operation that failed with err may succeed if it is retried.
+	IsTransientError(err error) bool
+}
+
+// WriteBestEffort writes the tuples with [BestEffortTupleWriter].WriteBestEffort if the datastore implements it,
+// and returns ErrBestEffortWriteNotSupported otherwise.
+func WriteBestEffort(ctx context.Context, ds RelationshipTupleWriter, store string, writes Writes) ([]TupleWriteResult, error) {
+	writer, ok := ds.(BestEffortTupleWriter)
+	if !ok {
+		return nil, ErrBestEffortWriteNotSupported
+	}
+	return writer.WriteBestEffort(ctx, store, writes)
+}
+
 // ReadStartingWithUserFilter specifies the filter options that will be used
 // to constrain the [RelationshipTupleReader.ReadStartingWithUser] query.
 type ReadStartingWithUserFilter struct {
@@ -355,6 +498,14 @@ type StoresBackend interface {
 	// In addition to the stores, it returns a continuation token that can be used to fetch the next page of results.
 	// If no stores are found, it is expected to return an empty list and an empty continuation token.
 	ListStores(ctx context.Context, options ListStoresOptions) ([]*openfgav1.Store, string, error)
+
+	// WriteStoreMetadata replaces the metadata of a store.
+	// It must return ErrNotFound if the store is not found or its DeletedAt is set.
+	WriteStoreMetadata(ctx context.Context, store string, metadata *StoreMetadata) error
+
+	// ReadStoreMetadata returns the metadata of a store. If no metadata was ever written, it must return
+	// empty metadata. It must return ErrNotFound if the store is not found or its DeletedAt is set.
+	ReadStoreMetadata(ctx context.Context, store string) (*StoreMetadata, error)
 }
 
 // AssertionsBackend is an interface that defines the set of methods for reading and writing assertions.
@@ -382,6 +533,11 @@ type ChangelogBackend interface {
 	// if no changes are found, it should return storage.ErrNotFound and an empty continuation token.
 	// It's important that the continuation token is a ULID, so it could be generated from timestamp.
 	ReadChanges(ctx context.Context, store string, filter ReadChangesFilter, options ReadChangesOptions) ([]*openfgav1.TupleChange, string, error)
+
+	// CompactChangeLog removes the changes of a store that occurred before olderThan. The current
+	// tuples of the store are not affected. Once compacted, reading changes from a continuation token
+	// before the compaction horizon returns ErrChangelogCompacted.
+	CompactChangeLog(ctx context.Context, store string, olderThan time.Time) error
 }
 
 // OpenFGADatastore is an interface that defines a set of methods for interacting
@@ -406,4 +562,8 @@ type ReadinessStatus struct {
 	Message string
 
 	IsReady bool
+
+	// Latency is the round trip time of the query made to check the readiness of the datastore, zero for the
+	// datastores that are not accessed over a connection.
+	Latency time.Duration
 }
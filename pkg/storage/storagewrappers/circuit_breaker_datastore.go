@@ -0,0 +1,550 @@
+package storagewrappers
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+
+	openfgav1 "github.com/openfga/api/proto/openfga/v1"
+
+	"github.com/openfga/openfga/internal/build"
+	"github.com/openfga/openfga/internal/clock"
+	"github.com/openfga/openfga/pkg/storage"
+)
+
+var (
+	_ storage.OpenFGADatastore         = (*CircuitBreakerDatastore)(nil)
+	_ storage.TransientErrorClassifier = (*CircuitBreakerDatastore)(nil)
+
+	datastoreCircuitBreakerState = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: build.ProjectName,
+		Name:      "datastore_circuit_breaker_state",
+		Help:      "The state of the datastore circuit breakers: 0 if closed, 1 if half-open and 2 if open.",
+	}, []string{"datastore", "breaker"})
+)
+
+// defaultCircuitBreakerDatastoreName is the "datastore" label of the datastore_circuit_breaker_state gauge of a
+// CircuitBreakerDatastore, unless set by WithCircuitBreakerDatastoreName.
+const defaultCircuitBreakerDatastoreName = "default"
+
+// The names of the breakers of a CircuitBreakerDatastore, as set in storage.CircuitOpenError.Breaker and in the
+// "breaker" label of the datastore_circuit_breaker_state gauge.
+const (
+	readBreaker  = "read"
+	writeBreaker = "write"
+)
+
+// circuitState is the state of a circuitBreaker. Its value is the one of the datastore_circuit_breaker_state gauge.
+type circuitState int
+
+const (
+	circuitClosed circuitState = iota
+	circuitHalfOpen
+	circuitOpen
+)
+
+// CircuitBreakerPolicy defines when the breakers of a [CircuitBreakerDatastore] open and close.
+type CircuitBreakerPolicy struct {
+	// FailureRateThreshold is the rate of failed operations, between 0 and 1, over a window at which the
+	// breaker opens.
+	FailureRateThreshold float64
+	// MinRequests is the minimum number of operations in a window for the breaker to open, so that a few
+	// failures on an idle server don't open it.
+	MinRequests int
+	// Window is the duration over which the failure rate is computed.
+	Window time.Duration
+	// Cooldown is the time for which an open breaker fails the operations fast before it half-opens.
+	Cooldown time.Duration
+	// HalfOpenProbes is the number of operations that a half-open breaker lets through to test whether the
+	// datastore recovered. The breaker closes once they all succeed, and opens again as soon as one fails.
+	HalfOpenProbes int
+}
+
+// DefaultCircuitBreakerPolicy returns the CircuitBreakerPolicy that opens when half of at least 20 operations
+// fail within 10 seconds, for 5 seconds.
+func DefaultCircuitBreakerPolicy() CircuitBreakerPolicy {
+	return CircuitBreakerPolicy{
+		FailureRateThreshold: 0.5,
+		MinRequests:          20,
+		Window:               10 * time.Second,
+		Cooldown:             5 * time.Second,
+		HalfOpenProbes:       3,
+	}
+}
+
+// CircuitBreakerDatastore is a wrapper for a datastore that fails the operations fast with a
+// [storage.CircuitOpenError] while the datastore is failing, instead of piling them up on it until they time out.
+//
+// The reads and the writes have separate breakers, so that e.g. a datastore whose primary is down can still serve
+// the reads. A breaker opens when the rate of failed operations over a window exceeds the threshold of the
+// policy. It then fails the operations fast for a cooldown, after which it half-opens and lets a few operations
+// through to test whether the datastore recovered.
+//
+// The operations that fail because their context is done, or with an error of the domain of the datastore, e.g.
+// [storage.ErrNotFound], aren't counted as failures. The reads that return an iterator are counted once the
+// iterator is done: as a failure if creating the iterator or iterating failed, as the SQL datastores query
+// lazily while iterating, and as a success otherwise. The compaction of the changelog isn't guarded.
+//
+// It composes with [RetryingDatastore]: wrapped by it, the retries of a read count as separate operations and
+// aren't attempted while the breaker is open; wrapping it, an operation and its retries count as one.
+type CircuitBreakerDatastore struct {
+	storage.OpenFGADatastore
+	reads      *circuitBreaker
+	writes     *circuitBreaker
+	classifier storage.TransientErrorClassifier
+}
+
+type circuitBreakerDatastoreOptions struct {
+	name string
+}
+
+type CircuitBreakerDatastoreOpt func(*circuitBreakerDatastoreOptions)
+
+// WithCircuitBreakerDatastoreName sets the name of the datastore, which labels the state of its breakers in the
+// datastore_circuit_breaker_state gauge, so that the breakers of the datastores of a process are told apart.
+func WithCircuitBreakerDatastoreName(name string) CircuitBreakerDatastoreOpt {
+	return func(o *circuitBreakerDatastoreOptions) {
+		o.name = name
+	}
+}
+
+// NewCircuitBreakerDatastore returns a wrapper over the datastore that guards its reads and its writes with
+// separate circuit breakers according to the policy.
+func NewCircuitBreakerDatastore(inner storage.OpenFGADatastore, policy CircuitBreakerPolicy, opts ...CircuitBreakerDatastoreOpt) *CircuitBreakerDatastore {
+	return newCircuitBreakerDatastore(inner, policy, clock.New(), opts...)
+}
+
+func newCircuitBreakerDatastore(inner storage.OpenFGADatastore, policy CircuitBreakerPolicy, c clock.Clock, opts ...CircuitBreakerDatastoreOpt) *CircuitBreakerDatastore {
+	options := circuitBreakerDatastoreOptions{name: defaultCircuitBreakerDatastoreName}
+	for _, opt := range opts {
+		opt(&options)
+	}
+
+	classifier, _ := inner.(storage.TransientErrorClassifier)
+	return &CircuitBreakerDatastore{
+		OpenFGADatastore: inner,
+		reads:            newCircuitBreaker(readBreaker, options.name, policy, c),
+		writes:           newCircuitBreaker(writeBreaker, options.name, policy, c),
+		classifier:       classifier,
+	}
+}
+
+// IsTransientError see [storage.TransientErrorClassifier].IsTransientError. The operations failed fast by an open
+// breaker aren't transient, so that a wrapping [RetryingDatastore] doesn't retry them; the other errors are
+// classified by the wrapped datastore.
+func (c *CircuitBreakerDatastore) IsTransientError(err error) bool {
+	if errors.Is(err, storage.ErrCircuitOpen) || c.classifier == nil {
+		return false
+	}
+	return c.classifier.IsTransientError(err)
+}
+
+// circuitBreaker is the breaker of the reads or of the writes of a CircuitBreakerDatastore.
+type circuitBreaker struct {
+	name   string
+	policy CircuitBreakerPolicy
+	clock  clock.Clock
+	// stateGauge is the datastore_circuit_breaker_state gauge of the breaker.
+	stateGauge prometheus.Gauge
+
+	mu    sync.Mutex
+	state circuitState
+	// generation is incremented on every change of state, so that the outcome of an operation admitted in a
+	// previous state is ignored.
+	generation uint64
+	// the operations and the failures of the current window, while closed.
+	windowStart time.Time
+	requests    int
+	failures    int
+	// when the breaker opened, while open.
+	openedAt time.Time
+	// when the breaker half-opened, and the probes admitted and those that succeeded, while half-open.
+	halfOpenedAt   time.Time
+	probes         int
+	probeSuccesses int
+}
+
+func newCircuitBreaker(name, datastore string, policy CircuitBreakerPolicy, c clock.Clock) *circuitBreaker {
+	policy.MinRequests = max(policy.MinRequests, 1)
+	policy.HalfOpenProbes = max(policy.HalfOpenProbes, 1)
+
+	stateGauge := datastoreCircuitBreakerState.WithLabelValues(datastore, name)
+	stateGauge.Set(float64(circuitClosed))
+	return &circuitBreaker{
+		name:        name,
+		policy:      policy,
+		clock:       c,
+		stateGauge:  stateGauge,
+		windowStart: c.Now(),
+	}
+}
+
+// setState changes the state of the breaker. The caller must hold the lock.
+func (b *circuitBreaker) setState(state circuitState, now time.Time) {
+	b.state = state
+	b.generation++
+	switch state {
+	case circuitClosed:
+		b.windowStart, b.requests, b.failures = now, 0, 0
+	case circuitOpen:
+		b.openedAt = now
+	case circuitHalfOpen:
+		b.halfOpenedAt, b.probes, b.probeSuccesses = now, 0, 0
+	}
+	b.stateGauge.Set(float64(state))
+}
+
+// allow returns the generation in which an operation is admitted, or a storage.CircuitOpenError if it must fail
+// fast. The outcome of an admitted operation must be reported with done.
+func (b *circuitBreaker) allow() (uint64, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	now := b.clock.Now()
+	switch b.state {
+	case circuitClosed:
+		if now.Sub(b.windowStart) >= b.policy.Window {
+			b.windowStart, b.requests, b.failures = now, 0, 0
+		}
+	case circuitOpen:
+		if remaining := b.policy.Cooldown - now.Sub(b.openedAt); remaining > 0 {
+			return 0, &storage.CircuitOpenError{Breaker: b.name, RetryAfter: remaining}
+		}
+		b.setState(circuitHalfOpen, now)
+		fallthrough
+	case circuitHalfOpen:
+		if b.probes >= b.policy.HalfOpenProbes {
+			// the probes are expected to complete within a cooldown, after which they are likely to be hanging
+			// on the datastore, so the operations are retried after another cooldown
+			retryAfter := b.policy.Cooldown - now.Sub(b.halfOpenedAt)
+			if retryAfter <= 0 {
+				retryAfter = b.policy.Cooldown
+			}
+			return 0, &storage.CircuitOpenError{Breaker: b.name, RetryAfter: retryAfter}
+		}
+		b.probes++
+	}
+	return b.generation, nil
+}
+
+// done reports the outcome of an operation admitted by allow in the generation.
+func (b *circuitBreaker) done(ctx context.Context, generation uint64, err error) {
+	failed := err != nil && !isDatastoreDomainError(err)
+	// the operations cancelled by the caller tell nothing about the datastore
+	ignored := err != nil && ctx.Err() != nil
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if generation != b.generation {
+		return
+	}
+
+	now := b.clock.Now()
+	switch b.state {
+	case circuitClosed:
+		if ignored {
+			return
+		}
+		b.requests++
+		if failed {
+			b.failures++
+		}
+		if b.requests >= b.policy.MinRequests &&
+			float64(b.failures)/float64(b.requests) >= b.policy.FailureRateThreshold {
+			b.setState(circuitOpen, now)
+		}
+	case circuitHalfOpen:
+		switch {
+		case ignored:
+			// let another probe through instead
+			b.probes--
+		case failed:
+			b.setState(circuitOpen, now)
+		default:
+			b.probeSuccesses++
+			if b.probeSuccesses >= b.policy.HalfOpenProbes {
+				b.setState(circuitClosed, now)
+			}
+		}
+	}
+}
+
+// guard calls fn if the breaker admits it, and reports its outcome to the breaker.
+func (b *circuitBreaker) guard(ctx context.Context, fn func() error) error {
+	generation, err := b.allow()
+	if err != nil {
+		return err
+	}
+	err = fn()
+	b.done(ctx, generation, err)
+	return err
+}
+
+// guardIterator calls fn if the breaker admits it. The outcome of an iterator it returns is reported to the
+// breaker once the iterator is done, see circuitBreakerTupleIterator.
+func (b *circuitBreaker) guardIterator(ctx context.Context, fn func() (storage.TupleIterator, error)) (storage.TupleIterator, error) {
+	generation, err := b.allow()
+	if err != nil {
+		return nil, err
+	}
+	iter, err := fn()
+	if err != nil {
+		b.done(ctx, generation, err)
+		return nil, err
+	}
+	return &circuitBreakerTupleIterator{TupleIterator: iter, breaker: b, generation: generation}, nil
+}
+
+// circuitBreakerTupleIterator reports the outcome of the read that returned the iterator to the breaker: a
+// failure on the first error of Next or Head, and a success once it's exhausted or stopped.
+type circuitBreakerTupleIterator struct {
+	storage.TupleIterator
+	breaker    *circuitBreaker
+	generation uint64
+	reported   sync.Once
+}
+
+var _ storage.TupleIterator = (*circuitBreakerTupleIterator)(nil)
+
+func (i *circuitBreakerTupleIterator) report(ctx context.Context, err error) {
+	if errors.Is(err, storage.ErrIteratorDone) {
+		err = nil
+	}
+	i.reported.Do(func() {
+		i.breaker.done(ctx, i.generation, err)
+	})
+}
+
+func (i *circuitBreakerTupleIterator) Next(ctx context.Context) (*openfgav1.Tuple, error) {
+	t, err := i.TupleIterator.Next(ctx)
+	if err != nil {
+		i.report(ctx, err)
+	}
+	return t, err
+}
+
+func (i *circuitBreakerTupleIterator) Head(ctx context.Context) (*openfgav1.Tuple, error) {
+	t, err := i.TupleIterator.Head(ctx)
+	if err != nil && !errors.Is(err, storage.ErrIteratorDone) {
+		i.report(ctx, err)
+	}
+	return t, err
+}
+
+func (i *circuitBreakerTupleIterator) Stop() {
+	i.TupleIterator.Stop()
+	i.report(context.Background(), nil)
+}
+
+// isDatastoreDomainError reports whether the error is an answer of the datastore rather than a failure of it.
+func isDatastoreDomainError(err error) bool {
+	for _, target := range []error{
+		storage.ErrNotFound,
+		storage.ErrCollision,
+		storage.ErrInvalidWriteInput,
+		storage.ErrTransactionalWriteFailed,
+		storage.ErrInvalidContinuationToken,
+		storage.ErrInvalidStartTime,
+		storage.ErrChangelogCompacted,
+		storage.ErrBestEffortWriteNotSupported,
+		storage.ErrCircuitOpen,
+	} {
+		if errors.Is(err, target) {
+			return true
+		}
+	}
+	return false
+}
+
+// Read see [storage.RelationshipTupleReader].Read.
+func (c *CircuitBreakerDatastore) Read(ctx context.Context, store string, tupleKey *openfgav1.TupleKey, options storage.ReadOptions) (storage.TupleIterator, error) {
+	return c.reads.guardIterator(ctx, func() (storage.TupleIterator, error) {
+		return c.OpenFGADatastore.Read(ctx, store, tupleKey, options)
+	})
+}
+
+// ReadPage see [storage.RelationshipTupleReader].ReadPage.
+func (c *CircuitBreakerDatastore) ReadPage(ctx context.Context, store string, tupleKey *openfgav1.TupleKey, options storage.ReadPageOptions) ([]*openfgav1.Tuple, string, error) {
+	var (
+		tuples            []*openfgav1.Tuple
+		continuationToken string
+	)
+	err := c.reads.guard(ctx, func() (err error) {
+		tuples, continuationToken, err = c.OpenFGADatastore.ReadPage(ctx, store, tupleKey, options)
+		return err
+	})
+	return tuples, continuationToken, err
+}
+
+// ReadUserTuple see [storage.RelationshipTupleReader].ReadUserTuple.
+func (c *CircuitBreakerDatastore) ReadUserTuple(ctx context.Context, store string, tupleKey *openfgav1.TupleKey, options storage.ReadUserTupleOptions) (*openfgav1.Tuple, error) {
+	var t *openfgav1.Tuple
+	err := c.reads.guard(ctx, func() (err error) {
+		t, err = c.OpenFGADatastore.ReadUserTuple(ctx, store, tupleKey, options)
+		return err
+	})
+	return t, err
+}
+
+// ReadUsersetTuples see [storage.RelationshipTupleReader].ReadUsersetTuples.
+func (c *CircuitBreakerDatastore) ReadUsersetTuples(ctx context.Context, store string, filter storage.ReadUsersetTuplesFilter, options storage.ReadUsersetTuplesOptions) (storage.TupleIterator, error) {
+	return c.reads.guardIterator(ctx, func() (storage.TupleIterator, error) {
+		return c.OpenFGADatastore.ReadUsersetTuples(ctx, store, filter, options)
+	})
+}
+
+// ReadStartingWithUser see [storage.RelationshipTupleReader].ReadStartingWithUser.
+func (c *CircuitBreakerDatastore) ReadStartingWithUser(ctx context.Context, store string, filter storage.ReadStartingWithUserFilter, options storage.ReadStartingWithUserOptions) (storage.TupleIterator, error) {
+	return c.reads.guardIterator(ctx, func() (storage.TupleIterator, error) {
+		return c.OpenFGADatastore.ReadStartingWithUser(ctx, store, filter, options)
+	})
+}
+
+// ReadAuthorizationModel see [storage.AuthorizationModelReadBackend].ReadAuthorizationModel.
+func (c *CircuitBreakerDatastore) ReadAuthorizationModel(ctx context.Context, store string, id string) (*openfgav1.AuthorizationModel, error) {
+	var model *openfgav1.AuthorizationModel
+	err := c.reads.guard(ctx, func() (err error) {
+		model, err = c.OpenFGADatastore.ReadAuthorizationModel(ctx, store, id)
+		return err
+	})
+	return model, err
+}
+
+// ReadAuthorizationModels see [storage.AuthorizationModelReadBackend].ReadAuthorizationModels.
+func (c *CircuitBreakerDatastore) ReadAuthorizationModels(ctx context.Context, store string, options storage.ReadAuthorizationModelsOptions) ([]*openfgav1.AuthorizationModel, string, error) {
+	var (
+		models            []*openfgav1.AuthorizationModel
+		continuationToken string
+	)
+	err := c.reads.guard(ctx, func() (err error) {
+		models, continuationToken, err = c.OpenFGADatastore.ReadAuthorizationModels(ctx, store, options)
+		return err
+	})
+	return models, continuationToken, err
+}
+
+// FindLatestAuthorizationModel see [storage.AuthorizationModelReadBackend].FindLatestAuthorizationModel.
+func (c *CircuitBreakerDatastore) FindLatestAuthorizationModel(ctx context.Context, store string) (*openfgav1.AuthorizationModel, error) {
+	var model *openfgav1.AuthorizationModel
+	err := c.reads.guard(ctx, func() (err error) {
+		model, err = c.OpenFGADatastore.FindLatestAuthorizationModel(ctx, store)
+		return err
+	})
+	return model, err
+}
+
+// GetStore see [storage.StoresBackend].GetStore.
+func (c *CircuitBreakerDatastore) GetStore(ctx context.Context, id string) (*openfgav1.Store, error) {
+	var store *openfgav1.Store
+	err := c.reads.guard(ctx, func() (err error) {
+		store, err = c.OpenFGADatastore.GetStore(ctx, id)
+		return err
+	})
+	return store, err
+}
+
+// ListStores see [storage.StoresBackend].ListStores.
+func (c *CircuitBreakerDatastore) ListStores(ctx context.Context, options storage.ListStoresOptions) ([]*openfgav1.Store, string, error) {
+	var (
+		stores            []*openfgav1.Store
+		continuationToken string
+	)
+	err := c.reads.guard(ctx, func() (err error) {
+		stores, continuationToken, err = c.OpenFGADatastore.ListStores(ctx, options)
+		return err
+	})
+	return stores, continuationToken, err
+}
+
+// ReadStoreMetadata see [storage.StoresBackend].ReadStoreMetadata.
+func (c *CircuitBreakerDatastore) ReadStoreMetadata(ctx context.Context, store string) (*storage.StoreMetadata, error) {
+	var metadata *storage.StoreMetadata
+	err := c.reads.guard(ctx, func() (err error) {
+		metadata, err = c.OpenFGADatastore.ReadStoreMetadata(ctx, store)
+		return err
+	})
+	return metadata, err
+}
+
+// ReadAssertions see [storage.AssertionsBackend].ReadAssertions.
+func (c *CircuitBreakerDatastore) ReadAssertions(ctx context.Context, store, modelID string) ([]*openfgav1.Assertion, error) {
+	var assertions []*openfgav1.Assertion
+	err := c.reads.guard(ctx, func() (err error) {
+		assertions, err = c.OpenFGADatastore.ReadAssertions(ctx, store, modelID)
+		return err
+	})
+	return assertions, err
+}
+
+// ReadChanges see [storage.ChangelogBackend].ReadChanges.
+func (c *CircuitBreakerDatastore) ReadChanges(ctx context.Context, store string, filter storage.ReadChangesFilter, options storage.ReadChangesOptions) ([]*openfgav1.TupleChange, string, error) {
+	var (
+		changes           []*openfgav1.TupleChange
+		continuationToken string
+	)
+	err := c.reads.guard(ctx, func() (err error) {
+		changes, continuationToken, err = c.OpenFGADatastore.ReadChanges(ctx, store, filter, options)
+		return err
+	})
+	return changes, continuationToken, err
+}
+
+// Write see [storage.RelationshipTupleWriter].Write.
+func (c *CircuitBreakerDatastore) Write(ctx context.Context, store string, deletes storage.Deletes, writes storage.Writes, opts ...storage.TupleWriteOption) error {
+	return c.writes.guard(ctx, func() error {
+		return c.OpenFGADatastore.Write(ctx, store, deletes, writes, opts...)
+	})
+}
+
+// WriteBestEffort see [storage.BestEffortTupleWriter].WriteBestEffort.
+func (c *CircuitBreakerDatastore) WriteBestEffort(ctx context.Context, store string, writes storage.Writes) ([]storage.TupleWriteResult, error) {
+	var results []storage.TupleWriteResult
+	err := c.writes.guard(ctx, func() (err error) {
+		results, err = storage.WriteBestEffort(ctx, c.OpenFGADatastore, store, writes)
+		return err
+	})
+	return results, err
+}
+
+// WriteAuthorizationModel see [storage.TypeDefinitionWriteBackend].WriteAuthorizationModel.
+func (c *CircuitBreakerDatastore) WriteAuthorizationModel(ctx context.Context, store string, model *openfgav1.AuthorizationModel) error {
+	return c.writes.guard(ctx, func() error {
+		return c.OpenFGADatastore.WriteAuthorizationModel(ctx, store, model)
+	})
+}
+
+// WriteAssertions see [storage.AssertionsBackend].WriteAssertions.
+func (c *CircuitBreakerDatastore) WriteAssertions(ctx context.Context, store, modelID string, assertions []*openfgav1.Assertion) error {
+	return c.writes.guard(ctx, func() error {
+		return c.OpenFGADatastore.WriteAssertions(ctx, store, modelID, assertions)
+	})
+}
+
+// WriteStoreMetadata see [storage.StoresBackend].WriteStoreMetadata.
+func (c *CircuitBreakerDatastore) WriteStoreMetadata(ctx context.Context, store string, metadata *storage.StoreMetadata) error {
+	return c.writes.guard(ctx, func() error {
+		return c.OpenFGADatastore.WriteStoreMetadata(ctx, store, metadata)
+	})
+}
+
+// CreateStore see [storage.StoresBackend].CreateStore.
+func (c *CircuitBreakerDatastore) CreateStore(ctx context.Context, store *openfgav1.Store) (*openfgav1.Store, error) {
+	var created *openfgav1.Store
+	err := c.writes.guard(ctx, func() (err error) {
+		created, err = c.OpenFGADatastore.CreateStore(ctx, store)
+		return err
+	})
+	return created, err
+}
+
+// DeleteStore see [storage.StoresBackend].DeleteStore.
+func (c *CircuitBreakerDatastore) DeleteStore(ctx context.Context, id string) error {
+	return c.writes.guard(ctx, func() error {
+		return c.OpenFGADatastore.DeleteStore(ctx, id)
+	})
+}
@@ -0,0 +1,274 @@
+package storagewrappers
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/goleak"
+	"go.uber.org/mock/gomock"
+
+	openfgav1 "github.com/openfga/api/proto/openfga/v1"
+
+	"github.com/openfga/openfga/internal/clock"
+	"github.com/openfga/openfga/internal/mocks"
+	"github.com/openfga/openfga/pkg/storage"
+	"github.com/openfga/openfga/pkg/tuple"
+)
+
+func TestCircuitBreakerDatastore(t *testing.T) {
+	t.Cleanup(func() {
+		goleak.VerifyNone(t)
+	})
+
+	const storeID = "01JCC8Z5S039R3X661KQGTNAFG"
+	tk := tuple.NewTupleKey("document:1", "viewer", "user:anne")
+	expected := &openfgav1.Tuple{Key: tk}
+	errUnavailable := errors.New("connection refused")
+
+	policy := CircuitBreakerPolicy{
+		FailureRateThreshold: 0.5,
+		MinRequests:          4,
+		Window:               time.Minute,
+		Cooldown:             10 * time.Second,
+		HalfOpenProbes:       2,
+	}
+
+	newDatastore := func(t *testing.T) (*mocks.MockOpenFGADatastore, *CircuitBreakerDatastore, *clock.Fake) {
+		mockController := gomock.NewController(t)
+		mockDatastore := mocks.NewMockOpenFGADatastore(mockController)
+		fakeClock := clock.NewFake(time.Now())
+		return mockDatastore, newCircuitBreakerDatastore(classifyingDatastore{mockDatastore}, policy, fakeClock), fakeClock
+	}
+
+	read := func(ds storage.OpenFGADatastore) error {
+		_, err := ds.ReadUserTuple(context.Background(), storeID, tk, storage.ReadUserTupleOptions{})
+		return err
+	}
+
+	// open fails the reads until the read breaker opens
+	open := func(t *testing.T, mockDatastore *mocks.MockOpenFGADatastore, ds *CircuitBreakerDatastore) {
+		mockDatastore.EXPECT().ReadUserTuple(gomock.Any(), storeID, tk, gomock.Any()).Times(2).Return(expected, nil)
+		mockDatastore.EXPECT().ReadUserTuple(gomock.Any(), storeID, tk, gomock.Any()).Times(2).Return(nil, errUnavailable)
+		for range 4 {
+			_ = read(ds)
+		}
+		require.InDelta(t, float64(circuitOpen), testutil.ToFloat64(datastoreCircuitBreakerState.WithLabelValues(defaultCircuitBreakerDatastoreName, readBreaker)), 0)
+	}
+
+	t.Run("opens_at_the_failure_rate_and_fails_fast", func(t *testing.T) {
+		mockDatastore, ds, fakeClock := newDatastore(t)
+		open(t, mockDatastore, ds)
+
+		// the datastore isn't called anymore
+		fakeClock.Advance(4 * time.Second)
+		err := read(ds)
+		require.ErrorIs(t, err, storage.ErrCircuitOpen)
+
+		var circuitOpenErr *storage.CircuitOpenError
+		require.ErrorAs(t, err, &circuitOpenErr)
+		require.Equal(t, readBreaker, circuitOpenErr.Breaker)
+		require.Equal(t, 6*time.Second, circuitOpenErr.RetryAfter)
+	})
+
+	t.Run("stays_closed_below_the_minimum_of_requests", func(t *testing.T) {
+		mockDatastore, ds, _ := newDatastore(t)
+		mockDatastore.EXPECT().ReadUserTuple(gomock.Any(), storeID, tk, gomock.Any()).Times(4).Return(nil, errUnavailable)
+		for range 3 {
+			require.ErrorIs(t, read(ds), errUnavailable)
+		}
+		require.ErrorIs(t, read(ds), errUnavailable)
+		require.ErrorIs(t, read(ds), storage.ErrCircuitOpen)
+	})
+
+	t.Run("the_window_is_reset", func(t *testing.T) {
+		mockDatastore, ds, fakeClock := newDatastore(t)
+		mockDatastore.EXPECT().ReadUserTuple(gomock.Any(), storeID, tk, gomock.Any()).Times(5).Return(nil, errUnavailable)
+		for range 3 {
+			require.ErrorIs(t, read(ds), errUnavailable)
+		}
+
+		fakeClock.Advance(time.Minute)
+		require.ErrorIs(t, read(ds), errUnavailable)
+		require.ErrorIs(t, read(ds), errUnavailable)
+	})
+
+	t.Run("half_opens_after_the_cooldown_and_closes", func(t *testing.T) {
+		mockDatastore, ds, fakeClock := newDatastore(t)
+		open(t, mockDatastore, ds)
+
+		fakeClock.Advance(policy.Cooldown)
+		mockDatastore.EXPECT().ReadUserTuple(gomock.Any(), storeID, tk, gomock.Any()).Times(2).Return(expected, nil)
+		require.NoError(t, read(ds))
+		require.InDelta(t, float64(circuitHalfOpen), testutil.ToFloat64(datastoreCircuitBreakerState.WithLabelValues(defaultCircuitBreakerDatastoreName, readBreaker)), 0)
+		require.NoError(t, read(ds))
+		require.InDelta(t, float64(circuitClosed), testutil.ToFloat64(datastoreCircuitBreakerState.WithLabelValues(defaultCircuitBreakerDatastoreName, readBreaker)), 0)
+
+		// a closed breaker starts a new window
+		mockDatastore.EXPECT().ReadUserTuple(gomock.Any(), storeID, tk, gomock.Any()).Return(nil, errUnavailable)
+		require.ErrorIs(t, read(ds), errUnavailable)
+	})
+
+	t.Run("reopens_when_a_probe_fails", func(t *testing.T) {
+		mockDatastore, ds, fakeClock := newDatastore(t)
+		open(t, mockDatastore, ds)
+
+		fakeClock.Advance(policy.Cooldown)
+		mockDatastore.EXPECT().ReadUserTuple(gomock.Any(), storeID, tk, gomock.Any()).Return(nil, errUnavailable)
+		require.ErrorIs(t, read(ds), errUnavailable)
+
+		err := read(ds)
+		var circuitOpenErr *storage.CircuitOpenError
+		require.ErrorAs(t, err, &circuitOpenErr)
+		require.Equal(t, policy.Cooldown, circuitOpenErr.RetryAfter)
+	})
+
+	t.Run("limits_the_probes", func(t *testing.T) {
+		mockDatastore, ds, fakeClock := newDatastore(t)
+		open(t, mockDatastore, ds)
+		fakeClock.Advance(policy.Cooldown)
+
+		// the probes are still in flight when the next read is attempted
+		release := make(chan struct{})
+		started := make(chan struct{}, 2)
+		mockDatastore.EXPECT().ReadUserTuple(gomock.Any(), storeID, tk, gomock.Any()).Times(2).DoAndReturn(
+			func(context.Context, string, *openfgav1.TupleKey, storage.ReadUserTupleOptions) (*openfgav1.Tuple, error) {
+				started <- struct{}{}
+				<-release
+				return expected, nil
+			})
+
+		errs := make(chan error, 2)
+		for range 2 {
+			go func() {
+				errs <- read(ds)
+			}()
+		}
+		<-started
+		<-started
+		fakeClock.Advance(4 * time.Second)
+		err := read(ds)
+		var circuitOpenErr *storage.CircuitOpenError
+		require.ErrorAs(t, err, &circuitOpenErr)
+		require.Equal(t, 6*time.Second, circuitOpenErr.RetryAfter)
+
+		close(release)
+		require.NoError(t, <-errs)
+		require.NoError(t, <-errs)
+	})
+
+	t.Run("iteration_failures_are_failures", func(t *testing.T) {
+		mockDatastore, ds, _ := newDatastore(t)
+		mockDatastore.EXPECT().Read(gomock.Any(), storeID, tk, gomock.Any()).Times(2).DoAndReturn(
+			func(context.Context, string, *openfgav1.TupleKey, storage.ReadOptions) (storage.TupleIterator, error) {
+				return storage.NewStaticTupleIterator([]*openfgav1.Tuple{expected}), nil
+			})
+		mockDatastore.EXPECT().Read(gomock.Any(), storeID, tk, gomock.Any()).Times(2).Return(failingTupleIterator{errUnavailable}, nil)
+
+		for range 4 {
+			iter, err := ds.Read(context.Background(), storeID, tk, storage.ReadOptions{})
+			require.NoError(t, err)
+			for {
+				if _, err = iter.Next(context.Background()); err != nil {
+					break
+				}
+			}
+			iter.Stop()
+		}
+		require.InDelta(t, float64(circuitOpen), testutil.ToFloat64(datastoreCircuitBreakerState.WithLabelValues(defaultCircuitBreakerDatastoreName, readBreaker)), 0)
+
+		_, err := ds.Read(context.Background(), storeID, tk, storage.ReadOptions{})
+		require.ErrorIs(t, err, storage.ErrCircuitOpen)
+	})
+
+	t.Run("the_writes_have_a_separate_breaker", func(t *testing.T) {
+		mockDatastore, ds, _ := newDatastore(t)
+		open(t, mockDatastore, ds)
+
+		mockDatastore.EXPECT().Write(gomock.Any(), storeID, gomock.Any(), gomock.Any()).Times(4).Return(errUnavailable)
+		for range 4 {
+			require.ErrorIs(t, ds.Write(context.Background(), storeID, nil, storage.Writes{tk}), errUnavailable)
+		}
+
+		err := ds.Write(context.Background(), storeID, nil, storage.Writes{tk})
+		var circuitOpenErr *storage.CircuitOpenError
+		require.ErrorAs(t, err, &circuitOpenErr)
+		require.Equal(t, writeBreaker, circuitOpenErr.Breaker)
+		require.InDelta(t, float64(circuitOpen), testutil.ToFloat64(datastoreCircuitBreakerState.WithLabelValues(defaultCircuitBreakerDatastoreName, writeBreaker)), 0)
+	})
+
+	t.Run("the_datastores_have_separate_states", func(t *testing.T) {
+		mockController := gomock.NewController(t)
+		primary := mocks.NewMockOpenFGADatastore(mockController)
+		fakeClock := clock.NewFake(time.Now())
+
+		ds := newCircuitBreakerDatastore(classifyingDatastore{primary}, policy, fakeClock, WithCircuitBreakerDatastoreName("primary"))
+		primary.EXPECT().ReadUserTuple(gomock.Any(), storeID, tk, gomock.Any()).Times(4).Return(nil, errUnavailable)
+		for range 4 {
+			_ = read(ds)
+		}
+		require.InDelta(t, float64(circuitOpen), testutil.ToFloat64(datastoreCircuitBreakerState.WithLabelValues("primary", readBreaker)), 0)
+
+		// the breakers of another datastore don't reset the state of the open one
+		_ = newCircuitBreakerDatastore(classifyingDatastore{mocks.NewMockOpenFGADatastore(mockController)}, policy, fakeClock, WithCircuitBreakerDatastoreName("secondary"))
+		require.InDelta(t, float64(circuitClosed), testutil.ToFloat64(datastoreCircuitBreakerState.WithLabelValues("secondary", readBreaker)), 0)
+		require.InDelta(t, float64(circuitOpen), testutil.ToFloat64(datastoreCircuitBreakerState.WithLabelValues("primary", readBreaker)), 0)
+	})
+
+	t.Run("domain_errors_and_cancellations_are_not_failures", func(t *testing.T) {
+		mockDatastore, ds, _ := newDatastore(t)
+		mockDatastore.EXPECT().ReadUserTuple(gomock.Any(), storeID, tk, gomock.Any()).Times(4).Return(nil, storage.ErrNotFound)
+		for range 4 {
+			require.ErrorIs(t, read(ds), storage.ErrNotFound)
+		}
+
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		mockDatastore.EXPECT().ReadUserTuple(gomock.Any(), storeID, tk, gomock.Any()).Times(4).Return(nil, context.Canceled)
+		for range 4 {
+			_, err := ds.ReadUserTuple(ctx, storeID, tk, storage.ReadUserTupleOptions{})
+			require.ErrorIs(t, err, context.Canceled)
+		}
+
+		mockDatastore.EXPECT().ReadUserTuple(gomock.Any(), storeID, tk, gomock.Any()).Return(expected, nil)
+		require.NoError(t, read(ds))
+	})
+
+	t.Run("composes_with_the_retrying_datastore", func(t *testing.T) {
+		mockDatastore, ds, _ := newDatastore(t)
+		retrying := NewRetryingDatastore(ds, RetryPolicy{
+			MaxAttempts:    3,
+			InitialBackoff: time.Millisecond,
+			MaxBackoff:     time.Millisecond,
+		})
+
+		// the retries count as separate operations, and aren't attempted once the breaker is open
+		mockDatastore.EXPECT().ReadUserTuple(gomock.Any(), storeID, tk, gomock.Any()).Times(2).Return(expected, nil)
+		require.NoError(t, read(retrying))
+		require.NoError(t, read(retrying))
+		mockDatastore.EXPECT().ReadUserTuple(gomock.Any(), storeID, tk, gomock.Any()).Times(2).Return(nil, errTransient)
+		require.ErrorIs(t, read(retrying), storage.ErrCircuitOpen)
+
+		require.True(t, ds.IsTransientError(errTransient))
+		require.False(t, ds.IsTransientError(&storage.CircuitOpenError{Breaker: readBreaker}))
+	})
+}
+
+// failingTupleIterator is a storage.TupleIterator whose reads fail, as those of a SQL iterator that fails to
+// query the datastore.
+type failingTupleIterator struct {
+	err error
+}
+
+func (i failingTupleIterator) Next(context.Context) (*openfgav1.Tuple, error) {
+	return nil, i.err
+}
+
+func (i failingTupleIterator) Head(context.Context) (*openfgav1.Tuple, error) {
+	return nil, i.err
+}
+
+func (i failingTupleIterator) Stop() {}
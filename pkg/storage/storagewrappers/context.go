@@ -16,7 +16,10 @@ type ContextTracerWrapper struct {
 	storage.OpenFGADatastore
 }
 
-var _ storage.OpenFGADatastore = (*ContextTracerWrapper)(nil)
+var (
+	_ storage.OpenFGADatastore         = (*ContextTracerWrapper)(nil)
+	_ storage.TransientErrorClassifier = (*ContextTracerWrapper)(nil)
+)
 
 // NewContextWrapper creates a new instance of [ContextTracerWrapper], wrapping the specified datastore. It is crucial
 // for [ContextTracerWrapper] to be the first wrapper around the datastore for traces to function correctly.
@@ -35,6 +38,13 @@ func (c *ContextTracerWrapper) Close() {
 	c.OpenFGADatastore.Close()
 }
 
+// IsTransientError see [storage.TransientErrorClassifier].IsTransientError. The errors are classified by the
+// wrapped datastore, and are never transient if it doesn't classify them.
+func (c *ContextTracerWrapper) IsTransientError(err error) bool {
+	classifier, ok := c.OpenFGADatastore.(storage.TransientErrorClassifier)
+	return ok && classifier.IsTransientError(err)
+}
+
 // Read see [storage.RelationshipTupleReader.ReadUserTuple].
 func (c *ContextTracerWrapper) Read(ctx context.Context, store string, tupleKey *openfgav1.TupleKey, options storage.ReadOptions) (storage.TupleIterator, error) {
 	queryCtx := queryContext(ctx)
@@ -69,3 +79,9 @@ func (c *ContextTracerWrapper) ReadStartingWithUser(ctx context.Context, store s
 
 	return c.OpenFGADatastore.ReadStartingWithUser(queryCtx, store, opts, options)
 }
+
+// WriteBestEffort see [storage.BestEffortTupleWriter].WriteBestEffort. It returns
+// [storage.ErrBestEffortWriteNotSupported] if the underlying datastore doesn't support it.
+func (c *ContextTracerWrapper) WriteBestEffort(ctx context.Context, store string, writes storage.Writes) ([]storage.TupleWriteResult, error) {
+	return storage.WriteBestEffort(ctx, c.OpenFGADatastore, store, writes)
+}
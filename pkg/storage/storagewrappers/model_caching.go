@@ -3,7 +3,6 @@ package storagewrappers
 import (
 	"context"
 	"fmt"
-	"time"
 
 	"golang.org/x/sync/singleflight"
 
@@ -12,48 +11,47 @@ import (
 	"github.com/openfga/openfga/pkg/storage"
 )
 
-const ttl = time.Hour * 168
-
-var (
-	_ storage.OpenFGADatastore = (*cachedOpenFGADatastore)(nil)
-	_ storage.CacheItem        = (*cachedAuthorizationModel)(nil)
-)
-
-type cachedAuthorizationModel struct {
-	*openfgav1.AuthorizationModel
-}
-
-func (c *cachedAuthorizationModel) CacheEntityType() string {
-	return "authz_model"
-}
+var _ storage.OpenFGADatastore = (*cachedOpenFGADatastore)(nil)
 
 type cachedOpenFGADatastore struct {
 	storage.OpenFGADatastore
 	lookupGroup singleflight.Group
-	cache       storage.InMemoryCache[*cachedAuthorizationModel]
+	cache       storage.ModelCache
+
+	// ownedCache is the cache created by the datastore, which it stops when it's closed. It's nil if the
+	// cache was provided, since it may be shared with other datastores.
+	ownedCache *storage.InMemoryModelCache
 }
 
 // NewCachedOpenFGADatastore returns a wrapper over a datastore that caches up to maxSize
 // [*openfgav1.AuthorizationModel] on every call to storage.ReadAuthorizationModel.
 // It caches with unlimited TTL because models are immutable. It uses LRU for eviction.
 func NewCachedOpenFGADatastore(inner storage.OpenFGADatastore, maxSize int) (*cachedOpenFGADatastore, error) {
-	cache, err := storage.NewInMemoryLRUCache[*cachedAuthorizationModel](storage.WithMaxCacheSize[*cachedAuthorizationModel](int64(maxSize)))
+	cache, err := storage.NewInMemoryModelCache(maxSize)
 	if err != nil {
 		return nil, err
 	}
 	return &cachedOpenFGADatastore{
 		OpenFGADatastore: inner,
-		cache:            *cache,
+		cache:            cache,
+		ownedCache:       cache,
 	}, nil
 }
 
+// NewCachedOpenFGADatastoreWithModelCache returns a wrapper over a datastore that caches the models in the
+// provided cache, which may be shared with other datastores. The cache is not stopped when the datastore is
+// closed.
+func NewCachedOpenFGADatastoreWithModelCache(inner storage.OpenFGADatastore, cache storage.ModelCache) *cachedOpenFGADatastore {
+	return &cachedOpenFGADatastore{
+		OpenFGADatastore: inner,
+		cache:            cache,
+	}
+}
+
 // ReadAuthorizationModel reads the model corresponding to store and model ID.
 func (c *cachedOpenFGADatastore) ReadAuthorizationModel(ctx context.Context, storeID, modelID string) (*openfgav1.AuthorizationModel, error) {
-	cacheKey := fmt.Sprintf("%s:%s", storeID, modelID)
-	cachedEntry := c.cache.Get(cacheKey)
-
-	if cachedEntry != nil {
-		return cachedEntry.AuthorizationModel, nil
+	if model, ok := c.cache.Get(storeID, modelID); ok {
+		return model, nil
 	}
 
 	model, err := c.OpenFGADatastore.ReadAuthorizationModel(ctx, storeID, modelID)
@@ -61,24 +59,54 @@ func (c *cachedOpenFGADatastore) ReadAuthorizationModel(ctx context.Context, sto
 		return nil, err
 	}
 
-	c.cache.Set(cacheKey, &cachedAuthorizationModel{model}, ttl) // These are immutable, once created, there cannot be edits, therefore they can be cached without ttl.
+	c.cache.Set(storeID, modelID, model) // These are immutable, once created, there cannot be edits, therefore they can be cached without ttl.
 
 	return model, nil
 }
 
 // FindLatestAuthorizationModel see [storage.AuthorizationModelReadBackend].FindLatestAuthorizationModel.
 func (c *cachedOpenFGADatastore) FindLatestAuthorizationModel(ctx context.Context, storeID string) (*openfgav1.AuthorizationModel, error) {
-	v, err, _ := c.lookupGroup.Do("FindLatestAuthorizationModel:"+storeID, func() (interface{}, error) {
+	// the reads with HIGHER_CONSISTENCY don't share the reads of the replicas
+	key := "FindLatestAuthorizationModel:" + storeID
+	if consistency := storage.ConsistencyFromContext(ctx); consistency == openfgav1.ConsistencyPreference_HIGHER_CONSISTENCY {
+		key += ":" + consistency.String()
+	}
+	v, err, _ := c.lookupGroup.Do(key, func() (interface{}, error) {
 		return c.OpenFGADatastore.FindLatestAuthorizationModel(ctx, storeID)
 	})
 	if err != nil {
 		return nil, err
 	}
-	return v.(*openfgav1.AuthorizationModel), nil
+
+	// the latest model changes as models are written, but the model itself is immutable
+	model := v.(*openfgav1.AuthorizationModel)
+	if model != nil {
+		c.cache.Set(storeID, model.GetId(), model)
+	}
+	return model, nil
+}
+
+// IsReady reports whether the cache of models is operational and the underlying datastore is ready.
+func (c *cachedOpenFGADatastore) IsReady(ctx context.Context) (storage.ReadinessStatus, error) {
+	if c.ownedCache != nil {
+		if err := c.ownedCache.IsReady(); err != nil {
+			return storage.ReadinessStatus{Message: fmt.Sprintf("authorization model cache: %s", err)}, nil
+		}
+	}
+
+	return c.OpenFGADatastore.IsReady(ctx)
 }
 
 // Close closes the datastore and cleans up any residual resources.
 func (c *cachedOpenFGADatastore) Close() {
-	c.cache.Stop()
+	if c.ownedCache != nil {
+		c.ownedCache.Stop()
+	}
 	c.OpenFGADatastore.Close()
 }
+
+// WriteBestEffort see [storage.BestEffortTupleWriter].WriteBestEffort. It returns
+// [storage.ErrBestEffortWriteNotSupported] if the underlying datastore doesn't support it.
+func (c *cachedOpenFGADatastore) WriteBestEffort(ctx context.Context, store string, writes storage.Writes) ([]storage.TupleWriteResult, error) {
+	return storage.WriteBestEffort(ctx, c.OpenFGADatastore, store, writes)
+}
@@ -2,7 +2,6 @@ package storagewrappers
 
 import (
 	"context"
-	"fmt"
 	"testing"
 	"time"
 
@@ -15,6 +14,7 @@ import (
 	openfgav1 "github.com/openfga/api/proto/openfga/v1"
 
 	"github.com/openfga/openfga/internal/mocks"
+	"github.com/openfga/openfga/pkg/storage"
 	"github.com/openfga/openfga/pkg/typesystem"
 )
 
@@ -59,10 +59,9 @@ func TestReadAuthorizationModel(t *testing.T) {
 	require.Equal(t, model, gotModel)
 
 	// Check what's stored inside the cache.
-	modelKey := fmt.Sprintf("%s:%s", storeID, model.GetId())
-	cachedModel := cachingBackend.cache.Get(modelKey)
-	require.NotNil(t, cachedModel)
-	require.Equal(t, model, cachedModel.AuthorizationModel)
+	cachedModel, ok := cachingBackend.cache.Get(storeID, model.GetId())
+	require.True(t, ok)
+	require.Equal(t, model, cachedModel)
 
 	// Check that second hit to cache -> hit.
 	gotModel, err = cachingBackend.ReadAuthorizationModel(ctx, storeID, model.GetId())
@@ -126,3 +125,62 @@ func TestSingleFlightFindLatestAuthorizationModel(t *testing.T) {
 	err = wg.Wait()
 	require.NoError(t, err)
 }
+
+func TestCachedDatastoreIsReady(t *testing.T) {
+	t.Cleanup(func() {
+		goleak.VerifyNone(t)
+	})
+	mockController := gomock.NewController(t)
+	defer mockController.Finish()
+
+	mockDatastore := mocks.NewMockOpenFGADatastore(mockController)
+	cachingBackend, err := NewCachedOpenFGADatastore(mockDatastore, 5)
+	require.NoError(t, err)
+
+	expected := storage.ReadinessStatus{IsReady: true, Latency: time.Millisecond}
+	mockDatastore.EXPECT().IsReady(gomock.Any()).Return(expected, nil)
+	mockDatastore.EXPECT().Close()
+
+	status, err := cachingBackend.IsReady(context.Background())
+	require.NoError(t, err)
+	require.Equal(t, expected, status)
+
+	cachingBackend.Close()
+}
+
+func TestCachedDatastoreWithModelCache(t *testing.T) {
+	t.Cleanup(func() {
+		goleak.VerifyNone(t)
+	})
+	mockController := gomock.NewController(t)
+	defer mockController.Finish()
+
+	modelCache, err := storage.NewInMemoryModelCache(5)
+	require.NoError(t, err)
+	t.Cleanup(modelCache.Stop)
+
+	mockDatastore := mocks.NewMockOpenFGADatastore(mockController)
+	cachingBackend := NewCachedOpenFGADatastoreWithModelCache(mockDatastore, modelCache)
+
+	model := &openfgav1.AuthorizationModel{
+		Id:            ulid.Make().String(),
+		SchemaVersion: typesystem.SchemaVersion1_1,
+	}
+	storeID := ulid.Make().String()
+	mockDatastore.EXPECT().FindLatestAuthorizationModel(gomock.Any(), storeID).Times(1).Return(model, nil)
+	mockDatastore.EXPECT().Close()
+
+	// the latest model is cached by its ID, so reading it doesn't hit the datastore
+	latestModel, err := cachingBackend.FindLatestAuthorizationModel(context.Background(), storeID)
+	require.NoError(t, err)
+	require.Equal(t, model, latestModel)
+
+	gotModel, err := cachingBackend.ReadAuthorizationModel(context.Background(), storeID, model.GetId())
+	require.NoError(t, err)
+	require.Equal(t, model, gotModel)
+
+	// the provided cache outlives the datastore
+	cachingBackend.Close()
+	_, ok := modelCache.Get(storeID, model.GetId())
+	require.True(t, ok)
+}
@@ -0,0 +1,95 @@
+package storagewrappers
+
+import (
+	"context"
+	"slices"
+
+	openfgav1 "github.com/openfga/api/proto/openfga/v1"
+
+	"github.com/openfga/openfga/pkg/storage"
+	"github.com/openfga/openfga/pkg/tuple"
+)
+
+var _ storage.RelationshipTupleReader = (*PrimaryReadTupleReader)(nil)
+
+// PrimaryReadTupleReader is a wrapper over a datastore that reads the tuples of some relations with
+// HIGHER_CONSISTENCY, regardless of the consistency of the read. Datastores that route the reads of lower
+// consistency to a replica then read the tuples of these relations from the primary, so that a tuple that
+// was just written isn't missed because the replica lags behind. The reads of the other relations are left as is.
+type PrimaryReadTupleReader struct {
+	storage.RelationshipTupleReader
+	// 'objectType#relation' pairs whose tuples are read from the primary
+	relations []string
+}
+
+// NewPrimaryReadTupleReader returns a wrapper over a datastore that reads the tuples of the
+// 'objectType#relation' pairs, e.g. 'document#viewer', with HIGHER_CONSISTENCY.
+func NewPrimaryReadTupleReader(wrapped storage.RelationshipTupleReader, relations []string) *PrimaryReadTupleReader {
+	return &PrimaryReadTupleReader{
+		RelationshipTupleReader: wrapped,
+		relations:               relations,
+	}
+}
+
+// consistency returns the consistency of a read of the tuples of the relation of the object type.
+func (p *PrimaryReadTupleReader) consistency(objectType, relation string, options storage.ConsistencyOptions) storage.ConsistencyOptions {
+	if slices.Contains(p.relations, tuple.ToObjectRelationString(objectType, relation)) {
+		return storage.ConsistencyOptions{Preference: openfgav1.ConsistencyPreference_HIGHER_CONSISTENCY}
+	}
+	return options
+}
+
+// Read see [storage.RelationshipTupleReader].Read.
+func (p *PrimaryReadTupleReader) Read(
+	ctx context.Context,
+	store string,
+	tupleKey *openfgav1.TupleKey,
+	options storage.ReadOptions,
+) (storage.TupleIterator, error) {
+	options.Consistency = p.consistency(tuple.GetType(tupleKey.GetObject()), tupleKey.GetRelation(), options.Consistency)
+	return p.RelationshipTupleReader.Read(ctx, store, tupleKey, options)
+}
+
+// ReadPage see [storage.RelationshipTupleReader].ReadPage.
+func (p *PrimaryReadTupleReader) ReadPage(
+	ctx context.Context,
+	store string,
+	tupleKey *openfgav1.TupleKey,
+	options storage.ReadPageOptions,
+) ([]*openfgav1.Tuple, string, error) {
+	options.Consistency = p.consistency(tuple.GetType(tupleKey.GetObject()), tupleKey.GetRelation(), options.Consistency)
+	return p.RelationshipTupleReader.ReadPage(ctx, store, tupleKey, options)
+}
+
+// ReadUserTuple see [storage.RelationshipTupleReader].ReadUserTuple.
+func (p *PrimaryReadTupleReader) ReadUserTuple(
+	ctx context.Context,
+	store string,
+	tupleKey *openfgav1.TupleKey,
+	options storage.ReadUserTupleOptions,
+) (*openfgav1.Tuple, error) {
+	options.Consistency = p.consistency(tuple.GetType(tupleKey.GetObject()), tupleKey.GetRelation(), options.Consistency)
+	return p.RelationshipTupleReader.ReadUserTuple(ctx, store, tupleKey, options)
+}
+
+// ReadUsersetTuples see [storage.RelationshipTupleReader].ReadUsersetTuples.
+func (p *PrimaryReadTupleReader) ReadUsersetTuples(
+	ctx context.Context,
+	store string,
+	filter storage.ReadUsersetTuplesFilter,
+	options storage.ReadUsersetTuplesOptions,
+) (storage.TupleIterator, error) {
+	options.Consistency = p.consistency(tuple.GetType(filter.Object), filter.Relation, options.Consistency)
+	return p.RelationshipTupleReader.ReadUsersetTuples(ctx, store, filter, options)
+}
+
+// ReadStartingWithUser see [storage.RelationshipTupleReader].ReadStartingWithUser.
+func (p *PrimaryReadTupleReader) ReadStartingWithUser(
+	ctx context.Context,
+	store string,
+	filter storage.ReadStartingWithUserFilter,
+	options storage.ReadStartingWithUserOptions,
+) (storage.TupleIterator, error) {
+	options.Consistency = p.consistency(filter.ObjectType, filter.Relation, options.Consistency)
+	return p.RelationshipTupleReader.ReadStartingWithUser(ctx, store, filter, options)
+}
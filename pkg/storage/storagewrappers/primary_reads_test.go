@@ -0,0 +1,63 @@
+package storagewrappers
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+
+	openfgav1 "github.com/openfga/api/proto/openfga/v1"
+
+	"github.com/openfga/openfga/internal/mocks"
+	"github.com/openfga/openfga/pkg/storage"
+	"github.com/openfga/openfga/pkg/tuple"
+)
+
+func TestPrimaryReadTupleReader(t *testing.T) {
+	const store = "01JCC8Z5S039R3X661KQGTNAFG"
+
+	higher := storage.ConsistencyOptions{Preference: openfgav1.ConsistencyPreference_HIGHER_CONSISTENCY}
+	minimize := storage.ConsistencyOptions{Preference: openfgav1.ConsistencyPreference_MINIMIZE_LATENCY}
+
+	mockController := gomock.NewController(t)
+	defer mockController.Finish()
+	mockDatastore := mocks.NewMockRelationshipTupleReader(mockController)
+
+	reader := NewPrimaryReadTupleReader(mockDatastore, []string{"document#viewer"})
+	ctx := context.Background()
+
+	t.Run("designated_relation_reads_with_higher_consistency", func(t *testing.T) {
+		tk := tuple.NewTupleKey("document:1", "viewer", "user:anne")
+
+		mockDatastore.EXPECT().Read(gomock.Any(), store, tk, storage.ReadOptions{Consistency: higher}).Return(nil, nil)
+		_, err := reader.Read(ctx, store, tk, storage.ReadOptions{Consistency: minimize})
+		require.NoError(t, err)
+
+		mockDatastore.EXPECT().ReadUserTuple(gomock.Any(), store, tk, storage.ReadUserTupleOptions{Consistency: higher}).Return(nil, nil)
+		_, err = reader.ReadUserTuple(ctx, store, tk, storage.ReadUserTupleOptions{Consistency: minimize})
+		require.NoError(t, err)
+
+		usersetFilter := storage.ReadUsersetTuplesFilter{Object: "document:1", Relation: "viewer"}
+		mockDatastore.EXPECT().ReadUsersetTuples(gomock.Any(), store, usersetFilter, storage.ReadUsersetTuplesOptions{Consistency: higher}).Return(nil, nil)
+		_, err = reader.ReadUsersetTuples(ctx, store, usersetFilter, storage.ReadUsersetTuplesOptions{})
+		require.NoError(t, err)
+
+		userFilter := storage.ReadStartingWithUserFilter{ObjectType: "document", Relation: "viewer"}
+		mockDatastore.EXPECT().ReadStartingWithUser(gomock.Any(), store, userFilter, storage.ReadStartingWithUserOptions{Consistency: higher}).Return(nil, nil)
+		_, err = reader.ReadStartingWithUser(ctx, store, userFilter, storage.ReadStartingWithUserOptions{})
+		require.NoError(t, err)
+	})
+
+	t.Run("other_relation_keeps_its_consistency", func(t *testing.T) {
+		tk := tuple.NewTupleKey("document:1", "editor", "user:anne")
+
+		mockDatastore.EXPECT().Read(gomock.Any(), store, tk, storage.ReadOptions{Consistency: minimize}).Return(nil, nil)
+		_, err := reader.Read(ctx, store, tk, storage.ReadOptions{Consistency: minimize})
+		require.NoError(t, err)
+
+		mockDatastore.EXPECT().ReadUserTuple(gomock.Any(), store, tk, storage.ReadUserTupleOptions{}).Return(nil, nil)
+		_, err = reader.ReadUserTuple(ctx, store, tk, storage.ReadUserTupleOptions{})
+		require.NoError(t, err)
+	})
+}
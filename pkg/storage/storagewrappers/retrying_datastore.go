@@ -0,0 +1,371 @@
+package storagewrappers
+
+import (
+	"context"
+	"time"
+
+	"github.com/cenkalti/backoff/v4"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+
+	openfgav1 "github.com/openfga/api/proto/openfga/v1"
+
+	"github.com/openfga/openfga/internal/build"
+	"github.com/openfga/openfga/pkg/storage"
+)
+
+var (
+	_ storage.OpenFGADatastore = (*RetryingDatastore)(nil)
+
+	datastoreRetryCounter = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: build.ProjectName,
+		Name:      "datastore_retry_count",
+		Help:      "The total number of datastore operations retried after a transient error.",
+	}, []string{"method"})
+)
+
+// RetryPolicy defines how the operations of a [RetryingDatastore] that fail with a transient error are retried.
+type RetryPolicy struct {
+	// MaxAttempts is the maximum number of attempts of an operation, including the first one.
+	MaxAttempts int
+	// InitialBackoff is the time waited before the first retry. It grows exponentially for the next ones, with
+	// a random jitter.
+	InitialBackoff time.Duration
+	// MaxBackoff is the maximum time waited before a retry.
+	MaxBackoff time.Duration
+	// RetryWrites enables retrying the writes. A write that failed because of the connection may have been
+	// committed, so it is only retried if the caller opts in.
+	RetryWrites bool
+}
+
+// DefaultRetryPolicy returns the RetryPolicy that retries the reads up to twice.
+func DefaultRetryPolicy() RetryPolicy {
+	return RetryPolicy{
+		MaxAttempts:    3,
+		InitialBackoff: 10 * time.Millisecond,
+		MaxBackoff:     200 * time.Millisecond,
+	}
+}
+
+// RetryingDatastore is a wrapper for a datastore that retries the operations that fail with a transient error,
+// as classified by the datastore if it implements [storage.TransientErrorClassifier]. The operations of the
+// datastores that don't implement it are never retried.
+//
+// The reads are retried, and the writes only if RetryPolicy.RetryWrites is set. The creation and deletion of
+// stores and the compaction of the changelog are never retried. The reads that return an iterator are retried
+// when the iterator can't be created or when its first Next or Head fails, as the SQL datastores only query
+// once iterating, but not when it fails after a tuple was returned. The retries are abandoned when the context
+// is done, or when its deadline would be exceeded before the next attempt.
+type RetryingDatastore struct {
+	storage.OpenFGADatastore
+	policy     RetryPolicy
+	classifier storage.TransientErrorClassifier
+}
+
+// NewRetryingDatastore returns a wrapper over the datastore that retries its operations according to the policy.
+func NewRetryingDatastore(inner storage.OpenFGADatastore, policy RetryPolicy) *RetryingDatastore {
+	classifier, _ := inner.(storage.TransientErrorClassifier)
+	return &RetryingDatastore{
+		OpenFGADatastore: inner,
+		policy:           policy,
+		classifier:       classifier,
+	}
+}
+
+// retry calls fn until it succeeds, fails with an error that isn't transient, or the attempts are exhausted,
+// and returns its last error.
+func (r *RetryingDatastore) retry(ctx context.Context, method string, write bool, fn func() error) error {
+	if r.classifier == nil || (write && !r.policy.RetryWrites) {
+		return fn()
+	}
+
+	policy := backoff.NewExponentialBackOff(
+		backoff.WithInitialInterval(r.policy.InitialBackoff),
+		backoff.WithMaxInterval(r.policy.MaxBackoff),
+		backoff.WithMaxElapsedTime(0),
+	)
+
+	for attempt := 1; ; attempt++ {
+		err := fn()
+		if err == nil || attempt >= r.policy.MaxAttempts || !r.classifier.IsTransientError(err) {
+			return err
+		}
+
+		wait := policy.NextBackOff()
+		if deadline, ok := ctx.Deadline(); ok && time.Until(deadline) < wait {
+			return err
+		}
+
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return err
+		case <-timer.C:
+		}
+
+		datastoreRetryCounter.WithLabelValues(method).Inc()
+	}
+}
+
+// readIterator creates an iterator with open, retrying its creation and its first Next or Head.
+func (r *RetryingDatastore) readIterator(ctx context.Context, method string, open func() (storage.TupleIterator, error)) (storage.TupleIterator, error) {
+	var iter storage.TupleIterator
+	err := r.retry(ctx, method, false, func() (err error) {
+		iter, err = open()
+		return err
+	})
+	if err != nil || r.classifier == nil {
+		return iter, err
+	}
+	return &retryingTupleIterator{datastore: r, method: method, open: open, iter: iter}, nil
+}
+
+// retryingTupleIterator is an iterator that is recreated when its first Next or Head fails with a transient
+// error. Once a tuple was returned, its errors are returned as is, as a new iterator would return the same
+// tuples again.
+type retryingTupleIterator struct {
+	datastore *RetryingDatastore
+	method    string
+	open      func() (storage.TupleIterator, error)
+	started   bool
+
+	// iter is nil if it couldn't be recreated, in which case err is the error of its recreation.
+	iter storage.TupleIterator
+	err  error
+}
+
+var _ storage.TupleIterator = (*retryingTupleIterator)(nil)
+
+// first calls fn with the iterator, recreating the iterator and calling fn again while it fails with a
+// transient error.
+func (r *retryingTupleIterator) first(ctx context.Context, fn func(iter storage.TupleIterator) error) error {
+	r.started = true
+
+	attempt := 0
+	return r.datastore.retry(ctx, r.method, false, func() error {
+		attempt++
+		if attempt > 1 {
+			if r.iter != nil {
+				r.iter.Stop()
+			}
+			r.iter, r.err = nil, nil
+
+			iter, err := r.open()
+			if err != nil {
+				r.err = err
+				return err
+			}
+			r.iter = iter
+		}
+		return fn(r.iter)
+	})
+}
+
+func (r *retryingTupleIterator) Next(ctx context.Context) (*openfgav1.Tuple, error) {
+	if r.iter == nil {
+		return nil, r.err
+	}
+	if r.started {
+		return r.iter.Next(ctx)
+	}
+
+	var t *openfgav1.Tuple
+	err := r.first(ctx, func(iter storage.TupleIterator) (err error) {
+		t, err = iter.Next(ctx)
+		return err
+	})
+	return t, err
+}
+
+func (r *retryingTupleIterator) Head(ctx context.Context) (*openfgav1.Tuple, error) {
+	if r.iter == nil {
+		return nil, r.err
+	}
+	if r.started {
+		return r.iter.Head(ctx)
+	}
+
+	var t *openfgav1.Tuple
+	err := r.first(ctx, func(iter storage.TupleIterator) (err error) {
+		t, err = iter.Head(ctx)
+		return err
+	})
+	return t, err
+}
+
+func (r *retryingTupleIterator) Stop() {
+	if r.iter != nil {
+		r.iter.Stop()
+	}
+}
+
+// Read see [storage.RelationshipTupleReader].Read.
+func (r *RetryingDatastore) Read(ctx context.Context, store string, tupleKey *openfgav1.TupleKey, options storage.ReadOptions) (storage.TupleIterator, error) {
+	return r.readIterator(ctx, "Read", func() (storage.TupleIterator, error) {
+		return r.OpenFGADatastore.Read(ctx, store, tupleKey, options)
+	})
+}
+
+// ReadPage see [storage.RelationshipTupleReader].ReadPage.
+func (r *RetryingDatastore) ReadPage(ctx context.Context, store string, tupleKey *openfgav1.TupleKey, options storage.ReadPageOptions) ([]*openfgav1.Tuple, string, error) {
+	var (
+		tuples            []*openfgav1.Tuple
+		continuationToken string
+	)
+	err := r.retry(ctx, "ReadPage", false, func() (err error) {
+		tuples, continuationToken, err = r.OpenFGADatastore.ReadPage(ctx, store, tupleKey, options)
+		return err
+	})
+	return tuples, continuationToken, err
+}
+
+// ReadUserTuple see [storage.RelationshipTupleReader].ReadUserTuple.
+func (r *RetryingDatastore) ReadUserTuple(ctx context.Context, store string, tupleKey *openfgav1.TupleKey, options storage.ReadUserTupleOptions) (*openfgav1.Tuple, error) {
+	var t *openfgav1.Tuple
+	err := r.retry(ctx, "ReadUserTuple", false, func() (err error) {
+		t, err = r.OpenFGADatastore.ReadUserTuple(ctx, store, tupleKey, options)
+		return err
+	})
+	return t, err
+}
+
+// ReadUsersetTuples see [storage.RelationshipTupleReader].ReadUsersetTuples.
+func (r *RetryingDatastore) ReadUsersetTuples(ctx context.Context, store string, filter storage.ReadUsersetTuplesFilter, options storage.ReadUsersetTuplesOptions) (storage.TupleIterator, error) {
+	return r.readIterator(ctx, "ReadUsersetTuples", func() (storage.TupleIterator, error) {
+		return r.OpenFGADatastore.ReadUsersetTuples(ctx, store, filter, options)
+	})
+}
+
+// ReadStartingWithUser see [storage.RelationshipTupleReader].ReadStartingWithUser.
+func (r *RetryingDatastore) ReadStartingWithUser(ctx context.Context, store string, filter storage.ReadStartingWithUserFilter, options storage.ReadStartingWithUserOptions) (storage.TupleIterator, error) {
+	return r.readIterator(ctx, "ReadStartingWithUser", func() (storage.TupleIterator, error) {
+		return r.OpenFGADatastore.ReadStartingWithUser(ctx, store, filter, options)
+	})
+}
+
+// ReadAuthorizationModel see [storage.AuthorizationModelReadBackend].ReadAuthorizationModel.
+func (r *RetryingDatastore) ReadAuthorizationModel(ctx context.Context, store string, id string) (*openfgav1.AuthorizationModel, error) {
+	var model *openfgav1.AuthorizationModel
+	err := r.retry(ctx, "ReadAuthorizationModel", false, func() (err error) {
+		model, err = r.OpenFGADatastore.ReadAuthorizationModel(ctx, store, id)
+		return err
+	})
+	return model, err
+}
+
+// ReadAuthorizationModels see [storage.AuthorizationModelReadBackend].ReadAuthorizationModels.
+func (r *RetryingDatastore) ReadAuthorizationModels(ctx context.Context, store string, options storage.ReadAuthorizationModelsOptions) ([]*openfgav1.AuthorizationModel, string, error) {
+	var (
+		models            []*openfgav1.AuthorizationModel
+		continuationToken string
+	)
+	err := r.retry(ctx, "ReadAuthorizationModels", false, func() (err error) {
+		models, continuationToken, err = r.OpenFGADatastore.ReadAuthorizationModels(ctx, store, options)
+		return err
+	})
+	return models, continuationToken, err
+}
+
+// FindLatestAuthorizationModel see [storage.AuthorizationModelReadBackend].FindLatestAuthorizationModel.
+func (r *RetryingDatastore) FindLatestAuthorizationModel(ctx context.Context, store string) (*openfgav1.AuthorizationModel, error) {
+	var model *openfgav1.AuthorizationModel
+	err := r.retry(ctx, "FindLatestAuthorizationModel", false, func() (err error) {
+		model, err = r.OpenFGADatastore.FindLatestAuthorizationModel(ctx, store)
+		return err
+	})
+	return model, err
+}
+
+// GetStore see [storage.StoresBackend].GetStore.
+func (r *RetryingDatastore) GetStore(ctx context.Context, id string) (*openfgav1.Store, error) {
+	var store *openfgav1.Store
+	err := r.retry(ctx, "GetStore", false, func() (err error) {
+		store, err = r.OpenFGADatastore.GetStore(ctx, id)
+		return err
+	})
+	return store, err
+}
+
+// ListStores see [storage.StoresBackend].ListStores.
+func (r *RetryingDatastore) ListStores(ctx context.Context, options storage.ListStoresOptions) ([]*openfgav1.Store, string, error) {
+	var (
+		stores            []*openfgav1.Store
+		continuationToken string
+	)
+	err := r.retry(ctx, "ListStores", false, func() (err error) {
+		stores, continuationToken, err = r.OpenFGADatastore.ListStores(ctx, options)
+		return err
+	})
+	return stores, continuationToken, err
+}
+
+// ReadStoreMetadata see [storage.StoresBackend].ReadStoreMetadata.
+func (r *RetryingDatastore) ReadStoreMetadata(ctx context.Context, store string) (*storage.StoreMetadata, error) {
+	var metadata *storage.StoreMetadata
+	err := r.retry(ctx, "ReadStoreMetadata", false, func() (err error) {
+		metadata, err = r.OpenFGADatastore.ReadStoreMetadata(ctx, store)
+		return err
+	})
+	return metadata, err
+}
+
+// ReadAssertions see [storage.AssertionsBackend].ReadAssertions.
+func (r *RetryingDatastore) ReadAssertions(ctx context.Context, store, modelID string) ([]*openfgav1.Assertion, error) {
+	var assertions []*openfgav1.Assertion
+	err := r.retry(ctx, "ReadAssertions", false, func() (err error) {
+		assertions, err = r.OpenFGADatastore.ReadAssertions(ctx, store, modelID)
+		return err
+	})
+	return assertions, err
+}
+
+// ReadChanges see [storage.ChangelogBackend].ReadChanges.
+func (r *RetryingDatastore) ReadChanges(ctx context.Context, store string, filter storage.ReadChangesFilter, options storage.ReadChangesOptions) ([]*openfgav1.TupleChange, string, error) {
+	var (
+		changes           []*openfgav1.TupleChange
+		continuationToken string
+	)
+	err := r.retry(ctx, "ReadChanges", false, func() (err error) {
+		changes, continuationToken, err = r.OpenFGADatastore.ReadChanges(ctx, store, filter, options)
+		return err
+	})
+	return changes, continuationToken, err
+}
+
+// Write see [storage.RelationshipTupleWriter].Write. It is only retried if RetryPolicy.RetryWrites is set.
+func (r *RetryingDatastore) Write(ctx context.Context, store string, deletes storage.Deletes, writes storage.Writes, opts ...storage.TupleWriteOption) error {
+	return r.retry(ctx, "Write", true, func() error {
+		return r.OpenFGADatastore.Write(ctx, store, deletes, writes, opts...)
+	})
+}
+
+// WriteAuthorizationModel see [storage.TypeDefinitionWriteBackend].WriteAuthorizationModel. It is only retried
+// if RetryPolicy.RetryWrites is set.
+func (r *RetryingDatastore) WriteAuthorizationModel(ctx context.Context, store string, model *openfgav1.AuthorizationModel) error {
+	return r.retry(ctx, "WriteAuthorizationModel", true, func() error {
+		return r.OpenFGADatastore.WriteAuthorizationModel(ctx, store, model)
+	})
+}
+
+// WriteAssertions see [storage.AssertionsBackend].WriteAssertions. It is only retried if RetryPolicy.RetryWrites
+// is set.
+func (r *RetryingDatastore) WriteAssertions(ctx context.Context, store, modelID string, assertions []*openfgav1.Assertion) error {
+	return r.retry(ctx, "WriteAssertions", true, func() error {
+		return r.OpenFGADatastore.WriteAssertions(ctx, store, modelID, assertions)
+	})
+}
+
+// WriteStoreMetadata see [storage.StoresBackend].WriteStoreMetadata. It is only retried if RetryPolicy.RetryWrites
+// is set.
+func (r *RetryingDatastore) WriteStoreMetadata(ctx context.Context, store string, metadata *storage.StoreMetadata) error {
+	return r.retry(ctx, "WriteStoreMetadata", true, func() error {
+		return r.OpenFGADatastore.WriteStoreMetadata(ctx, store, metadata)
+	})
+}
+
+// WriteBestEffort see [storage.BestEffortTupleWriter].WriteBestEffort. It isn't retried, as the tuples that
+// could be written are reported as such.
+func (r *RetryingDatastore) WriteBestEffort(ctx context.Context, store string, writes storage.Writes) ([]storage.TupleWriteResult, error) {
+	return storage.WriteBestEffort(ctx, r.OpenFGADatastore, store, writes)
+}
@@ -0,0 +1,213 @@
+package storagewrappers
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"go.uber.org/goleak"
+	"go.uber.org/mock/gomock"
+
+	openfgav1 "github.com/openfga/api/proto/openfga/v1"
+
+	"github.com/openfga/openfga/internal/mocks"
+	"github.com/openfga/openfga/pkg/storage"
+	"github.com/openfga/openfga/pkg/tuple"
+)
+
+var errTransient = errors.New("transient")
+
+// classifyingDatastore is a datastore whose only transient error is errTransient.
+type classifyingDatastore struct {
+	storage.OpenFGADatastore
+}
+
+func (classifyingDatastore) IsTransientError(err error) bool {
+	return errors.Is(err, errTransient)
+}
+
+func TestRetryingDatastore(t *testing.T) {
+	t.Cleanup(func() {
+		goleak.VerifyNone(t)
+	})
+
+	const storeID = "01JCC8Z5S039R3X661KQGTNAFG"
+	tk := tuple.NewTupleKey("document:1", "viewer", "user:anne")
+	expected := &openfgav1.Tuple{Key: tk}
+
+	policy := RetryPolicy{
+		MaxAttempts:    3,
+		InitialBackoff: time.Millisecond,
+		MaxBackoff:     time.Millisecond,
+	}
+
+	newDatastore := func(t *testing.T, policy RetryPolicy) (*mocks.MockOpenFGADatastore, *RetryingDatastore) {
+		mockController := gomock.NewController(t)
+		mockDatastore := mocks.NewMockOpenFGADatastore(mockController)
+		return mockDatastore, NewRetryingDatastore(classifyingDatastore{mockDatastore}, policy)
+	}
+
+	t.Run("retries_transient_read_errors", func(t *testing.T) {
+		mockDatastore, ds := newDatastore(t, policy)
+		gomock.InOrder(
+			mockDatastore.EXPECT().ReadUserTuple(gomock.Any(), storeID, tk, gomock.Any()).Times(2).Return(nil, errTransient),
+			mockDatastore.EXPECT().ReadUserTuple(gomock.Any(), storeID, tk, gomock.Any()).Return(expected, nil),
+		)
+
+		actual, err := ds.ReadUserTuple(context.Background(), storeID, tk, storage.ReadUserTupleOptions{})
+		require.NoError(t, err)
+		require.Equal(t, expected, actual)
+	})
+
+	t.Run("attempts_are_exhausted", func(t *testing.T) {
+		mockDatastore, ds := newDatastore(t, policy)
+		mockDatastore.EXPECT().ReadUserTuple(gomock.Any(), storeID, tk, gomock.Any()).Times(3).Return(nil, errTransient)
+
+		_, err := ds.ReadUserTuple(context.Background(), storeID, tk, storage.ReadUserTupleOptions{})
+		require.ErrorIs(t, err, errTransient)
+	})
+
+	t.Run("other_errors_are_not_retried", func(t *testing.T) {
+		mockDatastore, ds := newDatastore(t, policy)
+		mockDatastore.EXPECT().ReadUserTuple(gomock.Any(), storeID, tk, gomock.Any()).Return(nil, storage.ErrNotFound)
+
+		_, err := ds.ReadUserTuple(context.Background(), storeID, tk, storage.ReadUserTupleOptions{})
+		require.ErrorIs(t, err, storage.ErrNotFound)
+	})
+
+	t.Run("writes_are_not_retried_by_default", func(t *testing.T) {
+		mockDatastore, ds := newDatastore(t, policy)
+		mockDatastore.EXPECT().Write(gomock.Any(), storeID, nil, gomock.Any()).Return(errTransient)
+
+		err := ds.Write(context.Background(), storeID, nil, storage.Writes{tk})
+		require.ErrorIs(t, err, errTransient)
+	})
+
+	t.Run("writes_are_retried_if_enabled", func(t *testing.T) {
+		policy := policy
+		policy.RetryWrites = true
+
+		mockDatastore, ds := newDatastore(t, policy)
+		gomock.InOrder(
+			mockDatastore.EXPECT().Write(gomock.Any(), storeID, nil, gomock.Any()).Return(errTransient),
+			mockDatastore.EXPECT().Write(gomock.Any(), storeID, nil, gomock.Any()).Return(nil),
+		)
+
+		err := ds.Write(context.Background(), storeID, nil, storage.Writes{tk})
+		require.NoError(t, err)
+	})
+
+	t.Run("deadline_before_the_next_attempt", func(t *testing.T) {
+		policy := policy
+		policy.InitialBackoff = time.Minute
+		policy.MaxBackoff = time.Minute
+
+		mockDatastore, ds := newDatastore(t, policy)
+		mockDatastore.EXPECT().ReadUserTuple(gomock.Any(), storeID, tk, gomock.Any()).Return(nil, errTransient)
+
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+
+		start := time.Now()
+		_, err := ds.ReadUserTuple(ctx, storeID, tk, storage.ReadUserTupleOptions{})
+		require.ErrorIs(t, err, errTransient)
+		require.Less(t, time.Since(start), time.Second)
+	})
+
+	t.Run("deadline_through_the_context_wrapper", func(t *testing.T) {
+		policy := policy
+		policy.InitialBackoff = time.Minute
+		policy.MaxBackoff = time.Minute
+
+		mockController := gomock.NewController(t)
+		mockDatastore := mocks.NewMockOpenFGADatastore(mockController)
+		mockDatastore.EXPECT().ReadUserTuple(gomock.Any(), storeID, tk, gomock.Any()).Return(nil, errTransient)
+		ds := NewRetryingDatastore(NewContextWrapper(classifyingDatastore{mockDatastore}), policy)
+
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+
+		start := time.Now()
+		_, err := ds.ReadUserTuple(ctx, storeID, tk, storage.ReadUserTupleOptions{})
+		require.ErrorIs(t, err, errTransient)
+		require.Less(t, time.Since(start), time.Second)
+	})
+
+	t.Run("retries_the_first_next_of_iterators", func(t *testing.T) {
+		mockDatastore, ds := newDatastore(t, policy)
+		gomock.InOrder(
+			mockDatastore.EXPECT().Read(gomock.Any(), storeID, tk, gomock.Any()).Return(failingTupleIterator{errTransient}, nil),
+			mockDatastore.EXPECT().Read(gomock.Any(), storeID, tk, gomock.Any()).Return(storage.NewStaticTupleIterator([]*openfgav1.Tuple{expected}), nil),
+		)
+
+		iter, err := ds.Read(context.Background(), storeID, tk, storage.ReadOptions{})
+		require.NoError(t, err)
+		defer iter.Stop()
+
+		actual, err := iter.Next(context.Background())
+		require.NoError(t, err)
+		require.Equal(t, expected, actual)
+
+		_, err = iter.Next(context.Background())
+		require.ErrorIs(t, err, storage.ErrIteratorDone)
+	})
+
+	t.Run("retries_the_first_head_of_iterators", func(t *testing.T) {
+		mockDatastore, ds := newDatastore(t, policy)
+		gomock.InOrder(
+			mockDatastore.EXPECT().ReadStartingWithUser(gomock.Any(), storeID, gomock.Any(), gomock.Any()).Return(failingTupleIterator{errTransient}, nil),
+			mockDatastore.EXPECT().ReadStartingWithUser(gomock.Any(), storeID, gomock.Any(), gomock.Any()).Return(storage.NewStaticTupleIterator([]*openfgav1.Tuple{expected}), nil),
+		)
+
+		iter, err := ds.ReadStartingWithUser(context.Background(), storeID, storage.ReadStartingWithUserFilter{}, storage.ReadStartingWithUserOptions{})
+		require.NoError(t, err)
+		defer iter.Stop()
+
+		actual, err := iter.Head(context.Background())
+		require.NoError(t, err)
+		require.Equal(t, expected, actual)
+	})
+
+	t.Run("iterators_that_cant_be_recreated", func(t *testing.T) {
+		mockDatastore, ds := newDatastore(t, policy)
+		gomock.InOrder(
+			mockDatastore.EXPECT().ReadUsersetTuples(gomock.Any(), storeID, gomock.Any(), gomock.Any()).Return(failingTupleIterator{errTransient}, nil),
+			mockDatastore.EXPECT().ReadUsersetTuples(gomock.Any(), storeID, gomock.Any(), gomock.Any()).Times(2).Return(nil, errTransient),
+		)
+
+		iter, err := ds.ReadUsersetTuples(context.Background(), storeID, storage.ReadUsersetTuplesFilter{}, storage.ReadUsersetTuplesOptions{})
+		require.NoError(t, err)
+		defer iter.Stop()
+
+		_, err = iter.Next(context.Background())
+		require.ErrorIs(t, err, errTransient)
+		_, err = iter.Next(context.Background())
+		require.ErrorIs(t, err, errTransient)
+	})
+
+	t.Run("iterators_are_not_retried_once_started", func(t *testing.T) {
+		mockDatastore, ds := newDatastore(t, policy)
+		mockDatastore.EXPECT().Read(gomock.Any(), storeID, tk, gomock.Any()).Return(
+			storage.NewCombinedIterator(storage.NewStaticTupleIterator([]*openfgav1.Tuple{expected}), failingTupleIterator{errTransient}), nil)
+
+		iter, err := ds.Read(context.Background(), storeID, tk, storage.ReadOptions{})
+		require.NoError(t, err)
+		defer iter.Stop()
+
+		_, err = iter.Next(context.Background())
+		require.NoError(t, err)
+		_, err = iter.Next(context.Background())
+		require.ErrorIs(t, err, errTransient)
+	})
+
+	t.Run("datastore_without_classifier", func(t *testing.T) {
+		mockController := gomock.NewController(t)
+		mockDatastore := mocks.NewMockOpenFGADatastore(mockController)
+		mockDatastore.EXPECT().ReadUserTuple(gomock.Any(), storeID, tk, gomock.Any()).Return(nil, errTransient)
+
+		_, err := NewRetryingDatastore(mockDatastore, policy).ReadUserTuple(context.Background(), storeID, tk, storage.ReadUserTupleOptions{})
+		require.ErrorIs(t, err, errTransient)
+	})
+}
@@ -169,6 +169,65 @@ func StoreTest(t *testing.T, datastore storage.OpenFGADatastore) {
 		verifyStore(t, expected2, gotStores[1])
 	})
 
+	t.Run("list_stores_succeeds_with_name_contains_filter", func(t *testing.T) {
+		prefix := testutils.CreateRandomString(10)
+		expected1 := createStore(prefix + "-team-alpha")
+		createStore(prefix + "-Team-beta")
+		expected2 := createStore(prefix + "-team-gamma")
+
+		gotStores, ct, err := datastore.ListStores(ctx, storage.ListStoresOptions{
+			Pagination:   storage.NewPaginationOptions(1, ""),
+			NameContains: prefix + "-team-",
+		})
+		require.NoError(t, err)
+		require.Len(t, gotStores, 1)
+		require.NotEmpty(t, ct)
+		verifyStore(t, expected1, gotStores[0])
+
+		gotStores, ct, err = datastore.ListStores(ctx, storage.ListStoresOptions{
+			Pagination:   storage.NewPaginationOptions(1, ct),
+			NameContains: prefix + "-team-",
+		})
+		require.NoError(t, err)
+		require.Len(t, gotStores, 1)
+		require.Empty(t, ct)
+		verifyStore(t, expected2, gotStores[0])
+	})
+
+	t.Run("list_stores_pagination_is_stable_under_concurrent_creation", func(t *testing.T) {
+		prefix := testutils.CreateRandomString(10)
+		created := make(map[string]struct{})
+		for i := 0; i < 4; i++ {
+			created[createStore(prefix).GetId()] = struct{}{}
+		}
+
+		seen := make(map[string]struct{})
+		var ct string
+		for {
+			gotStores, nextCt, err := datastore.ListStores(ctx, storage.ListStoresOptions{
+				Pagination:   storage.NewPaginationOptions(2, ct),
+				NameContains: prefix,
+			})
+			require.NoError(t, err)
+			for _, store := range gotStores {
+				require.NotContains(t, seen, store.GetId())
+				seen[store.GetId()] = struct{}{}
+			}
+
+			if nextCt == "" {
+				break
+			}
+			ct = nextCt
+
+			// a store created between two pages neither shifts nor repeats the stores already listed
+			created[createStore(prefix).GetId()] = struct{}{}
+			require.Less(t, len(seen), 20, "the pagination must end")
+		}
+
+		// the stores created while paginating sort after the continuation token, so they are listed too
+		require.Equal(t, created, seen)
+	})
+
 	t.Run("get_store_succeeds", func(t *testing.T) {
 		store := stores[0]
 		gotStore, err := datastore.GetStore(ctx, store.GetId())
@@ -212,4 +271,63 @@ func StoreTest(t *testing.T, datastore storage.OpenFGADatastore) {
 			require.NotEqual(t, store.GetId(), s.GetId())
 		}
 	})
+	t.Run("write_and_read_store_metadata", func(t *testing.T) {
+		store := createStore("metadata")
+
+		metadata, err := datastore.ReadStoreMetadata(ctx, store.GetId())
+		require.NoError(t, err)
+		require.Empty(t, metadata.ObjectTypeAllowlist)
+
+		err = datastore.WriteStoreMetadata(ctx, store.GetId(), &storage.StoreMetadata{
+			ObjectTypeAllowlist: []string{"document", "folder"},
+		})
+		require.NoError(t, err)
+
+		// writing the same metadata again succeeds
+		err = datastore.WriteStoreMetadata(ctx, store.GetId(), &storage.StoreMetadata{
+			ObjectTypeAllowlist: []string{"document", "folder"},
+		})
+		require.NoError(t, err)
+
+		metadata, err = datastore.ReadStoreMetadata(ctx, store.GetId())
+		require.NoError(t, err)
+		require.Equal(t, []string{"document", "folder"}, metadata.ObjectTypeAllowlist)
+
+		err = datastore.WriteStoreMetadata(ctx, store.GetId(), &storage.StoreMetadata{
+			CycleGuardedRelations: []string{"group#member"},
+		})
+		require.NoError(t, err)
+
+		metadata, err = datastore.ReadStoreMetadata(ctx, store.GetId())
+		require.NoError(t, err)
+		require.Empty(t, metadata.ObjectTypeAllowlist)
+		require.Equal(t, []string{"group#member"}, metadata.CycleGuardedRelations)
+
+		err = datastore.WriteStoreMetadata(ctx, store.GetId(), &storage.StoreMetadata{
+			DefaultConsistency: openfgav1.ConsistencyPreference_HIGHER_CONSISTENCY,
+		})
+		require.NoError(t, err)
+
+		metadata, err = datastore.ReadStoreMetadata(ctx, store.GetId())
+		require.NoError(t, err)
+		require.Empty(t, metadata.CycleGuardedRelations)
+		require.Equal(t, openfgav1.ConsistencyPreference_HIGHER_CONSISTENCY, metadata.DefaultConsistency)
+
+		err = datastore.WriteStoreMetadata(ctx, store.GetId(), &storage.StoreMetadata{})
+		require.NoError(t, err)
+
+		metadata, err = datastore.ReadStoreMetadata(ctx, store.GetId())
+		require.NoError(t, err)
+		require.Empty(t, metadata.ObjectTypeAllowlist)
+		require.Empty(t, metadata.CycleGuardedRelations)
+		require.Equal(t, openfgav1.ConsistencyPreference_UNSPECIFIED, metadata.DefaultConsistency)
+	})
+
+	t.Run("store_metadata_of_non-existent_store_returns_not_found", func(t *testing.T) {
+		_, err := datastore.ReadStoreMetadata(ctx, "unknown")
+		require.ErrorIs(t, err, storage.ErrNotFound)
+
+		err = datastore.WriteStoreMetadata(ctx, "unknown", &storage.StoreMetadata{})
+		require.ErrorIs(t, err, storage.ErrNotFound)
+	})
 }
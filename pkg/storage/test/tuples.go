@@ -309,6 +309,63 @@ func ReadChangesTest(t *testing.T, datastore storage.OpenFGADatastore) {
 		}
 	})
 
+	t.Run("continuation_token_with_object_type_filter_keeps_reading_that_object_type", func(t *testing.T) {
+		storeID := ulid.Make().String()
+
+		// the changes to folders are interleaved with the changes to documents
+		var expectedChanges []*openfgav1.TupleChange
+		write := func(from, to int) {
+			for i := from; i < to; i++ {
+				folder := tuple.NewTupleKey(fmt.Sprintf("folder:%d", i), "viewer", "user:bob")
+				document := tuple.NewTupleKey(fmt.Sprintf("document:%d", i), "viewer", "user:bob")
+				for _, tk := range []*openfgav1.TupleKey{document, folder} {
+					err := datastore.Write(ctx, storeID, nil, []*openfgav1.TupleKey{tk})
+					require.NoError(t, err)
+				}
+				expectedChanges = append(expectedChanges, &openfgav1.TupleChange{
+					TupleKey:  folder,
+					Operation: openfgav1.TupleOperation_TUPLE_OPERATION_WRITE,
+				})
+			}
+		}
+		write(0, 5)
+
+		filter := storage.ReadChangesFilter{ObjectType: "folder"}
+		readPage := func(token string) ([]*openfgav1.TupleChange, string) {
+			opts := storage.ReadChangesOptions{
+				Pagination: storage.NewPaginationOptions(2, token),
+			}
+			changes, token, err := datastore.ReadChanges(ctx, storeID, filter, opts)
+			require.NoError(t, err)
+			return changes, token
+		}
+
+		var changes []*openfgav1.TupleChange
+		page, token := readPage("")
+		changes = append(changes, page...)
+		page, token = readPage(token)
+		changes = append(changes, page...)
+
+		// the changes written after the token was minted are read when resuming from it
+		write(5, 8)
+		for {
+			opts := storage.ReadChangesOptions{
+				Pagination: storage.NewPaginationOptions(2, token),
+			}
+			page, nextToken, err := datastore.ReadChanges(ctx, storeID, filter, opts)
+			if errors.Is(err, storage.ErrNotFound) {
+				break
+			}
+			require.NoError(t, err)
+			changes = append(changes, page...)
+			token = nextToken
+		}
+
+		if diff := cmp.Diff(expectedChanges, changes, cmpIgnoreTimestamp...); diff != "" {
+			t.Fatalf("mismatch (-want +got):\n%s", diff)
+		}
+	})
+
 	t.Run("read_changes_returns_deterministic_ordering_and_no_duplicates", func(t *testing.T) {
 		storeID := ulid.Make().String()
 
@@ -1036,6 +1093,64 @@ func TupleWritingAndReadingTest(t *testing.T, datastore storage.OpenFGADatastore
 		}
 	})
 
+	t.Run("upsert_insert_duplicate_replaces_condition", func(t *testing.T) {
+		storeID := ulid.Make().String()
+		tk1 := &openfgav1.TupleKey{Object: "doc:readme", Relation: "owner", User: "10", Condition: &openfgav1.RelationshipCondition{
+			Name:    "condition1",
+			Context: testutils.MustNewStruct(t, map[string]interface{}{"param1": "ok"}),
+		}}
+		tk2 := &openfgav1.TupleKey{Object: "doc:readme", Relation: "owner", User: "10", Condition: &openfgav1.RelationshipCondition{
+			Name:    "condition2",
+			Context: testutils.MustNewStruct(t, map[string]interface{}{"param1": "bad"}),
+		}}
+		tk3 := &openfgav1.TupleKey{Object: "doc:readme", Relation: "owner", User: "11"}
+
+		// First write should succeed.
+		err := datastore.Write(ctx, storeID, nil, []*openfgav1.TupleKey{tk1})
+		require.NoError(t, err)
+
+		// Second write of the same tuple replaces its condition, new tuples are inserted.
+		err = datastore.Write(ctx, storeID, nil, []*openfgav1.TupleKey{tk2, tk3},
+			storage.WithOnDuplicateInsert(storage.OnDuplicateInsertUpsert))
+		require.NoError(t, err)
+
+		tp, err := datastore.ReadUserTuple(ctx, storeID, tuple.NewTupleKey("doc:readme", "owner", "10"), storage.ReadUserTupleOptions{})
+		require.NoError(t, err)
+		if diff := cmp.Diff(tk2, tp.GetKey(), protocmp.Transform()); diff != "" {
+			t.Fatalf("mismatch (-want +got):\n%s", diff)
+		}
+
+		// Writing an identical tuple is a no-op.
+		err = datastore.Write(ctx, storeID, nil, []*openfgav1.TupleKey{tk2},
+			storage.WithOnDuplicateInsert(storage.OnDuplicateInsertUpsert))
+		require.NoError(t, err)
+
+		expectedChanges := []*openfgav1.TupleChange{
+			{
+				TupleKey:  tk1,
+				Operation: openfgav1.TupleOperation_TUPLE_OPERATION_WRITE,
+			},
+			{
+				TupleKey:  tk2,
+				Operation: openfgav1.TupleOperation_TUPLE_OPERATION_WRITE,
+			},
+			{
+				TupleKey:  tk3,
+				Operation: openfgav1.TupleOperation_TUPLE_OPERATION_WRITE,
+			},
+		}
+
+		readChangesOpts := storage.ReadChangesOptions{
+			Pagination: storage.NewPaginationOptions(storage.DefaultPageSize, ""),
+		}
+		changes, _, err := datastore.ReadChanges(ctx, storeID, storage.ReadChangesFilter{}, readChangesOpts)
+		require.NoError(t, err)
+
+		if diff := cmp.Diff(expectedChanges, changes, cmpIgnoreTimestamp...); diff != "" {
+			t.Fatalf("mismatch (-want +got):\n%s", diff)
+		}
+	})
+
 	t.Run("inserting_a_tuple_twice_ignore_duplicate_batch", func(t *testing.T) {
 		storeID := ulid.Make().String()
 		tk1 := &openfgav1.TupleKey{Object: "doc:readme", Relation: "owner", User: "10"}
@@ -1614,6 +1729,43 @@ func TupleWritingAndReadingTest(t *testing.T, datastore storage.OpenFGADatastore
 		}
 	})
 
+	t.Run("reading_userset_tuples_with_filter_made_of_conditioned_references_returns_each_tuple_once", func(t *testing.T) {
+		storeID := ulid.Make().String()
+		tks := []*openfgav1.TupleKey{
+			tuple.NewTupleKey("document:1", "viewer", "group:eng#member"),
+			tuple.NewTupleKey("document:1", "viewer", "team:eng#member"),
+		}
+
+		err := datastore.Write(ctx, storeID, nil, tks)
+		require.NoError(t, err)
+
+		// e.g. 'define viewer: [group#member, group#member with x]'
+		gotTuples, err := datastore.ReadUsersetTuples(ctx, storeID, storage.ReadUsersetTuplesFilter{
+			Object:   "document:1",
+			Relation: "viewer",
+			AllowedUserTypeRestrictions: []*openfgav1.RelationReference{
+				typesystem.DirectRelationReference("group", "member"),
+				typesystem.ConditionedRelationReference(typesystem.DirectRelationReference("group", "member"), "x"),
+			},
+		}, storage.ReadUsersetTuplesOptions{})
+		require.NoError(t, err)
+
+		iter := storage.NewTupleKeyIteratorFromTupleIterator(gotTuples)
+		defer iter.Stop()
+
+		got, err := iter.Next(ctx)
+		require.NoError(t, err)
+
+		// the userset of a disallowed type, team#member, is never returned
+		expected := tuple.NewTupleKey("document:1", "viewer", "group:eng#member")
+		if diff := cmp.Diff(expected, got, cmpOpts...); diff != "" {
+			require.FailNowf(t, "mismatch (-want +got):\n%s", diff)
+		}
+
+		_, err = iter.Next(ctx)
+		require.ErrorIs(t, err, storage.ErrIteratorDone)
+	})
+
 	t.Run("tuples_with_nil_condition", func(t *testing.T) {
 		// This test ensures we don't normalize nil conditions to an empty value.
 		storeID := ulid.Make().String()
@@ -2402,6 +2554,181 @@ func iterateThroughAllTuples(t *testing.T, tupleIterator storage.TupleIterator)
 
 // readChanges calls ReadChanges. It reads everything from the store, pageSize changes at a time.
 // Along the way, it makes assertions on the changes seen. It returns all changes seen.
+func CompactChangeLogTest(t *testing.T, datastore storage.OpenFGADatastore) {
+	ctx := context.Background()
+	storeID := ulid.Make().String()
+
+	tkA := tuple.NewTupleKey("document:a", "viewer", "user:jon")
+	tkB := tuple.NewTupleKey("document:b", "viewer", "user:jon")
+	tkC := tuple.NewTupleKey("document:c", "viewer", "user:jon")
+
+	err := datastore.Write(ctx, storeID, nil, []*openfgav1.TupleKey{tkA, tkC})
+	require.NoError(t, err)
+
+	// ulids have millisecond precision
+	time.Sleep(2 * time.Millisecond)
+	cutoff := time.Now()
+	time.Sleep(2 * time.Millisecond)
+
+	err = datastore.Write(ctx, storeID, []*openfgav1.TupleKeyWithoutCondition{tuple.TupleKeyToTupleKeyWithoutCondition(tkC)}, []*openfgav1.TupleKey{tkB})
+	require.NoError(t, err)
+
+	require.Len(t, readChangesWithPageSize(t, datastore, storeID, 10, ""), 4)
+
+	// a cursor past the cutoff, taken before the compaction
+	pastCutoff := ulid.MustNew(ulid.Timestamp(cutoff.Add(time.Millisecond)), nil).String()
+
+	err = datastore.CompactChangeLog(ctx, storeID, cutoff)
+	require.NoError(t, err)
+
+	t.Run("cursors_past_cutoff_stay_valid", func(t *testing.T) {
+		changes, _, err := datastore.ReadChanges(ctx, storeID, storage.ReadChangesFilter{}, storage.ReadChangesOptions{
+			Pagination: storage.PaginationOptions{PageSize: 10, From: pastCutoff},
+		})
+		require.NoError(t, err)
+		require.Len(t, changes, 2)
+	})
+
+	t.Run("removes_changes_before_cutoff", func(t *testing.T) {
+		changes := readChangesWithPageSize(t, datastore, storeID, 10, "")
+		require.Len(t, changes, 2)
+		for _, change := range changes {
+			require.True(t, change.GetTimestamp().AsTime().After(cutoff))
+		}
+	})
+
+	t.Run("current_tuples_are_intact", func(t *testing.T) {
+		iter, err := datastore.Read(ctx, storeID, tuple.NewTupleKey("", "viewer", "user:jon"), storage.ReadOptions{})
+		require.NoError(t, err)
+		defer iter.Stop()
+
+		var objects []string
+		for {
+			tp, err := iter.Next(ctx)
+			if errors.Is(err, storage.ErrIteratorDone) {
+				break
+			}
+			require.NoError(t, err)
+			objects = append(objects, tp.GetKey().GetObject())
+		}
+		require.ElementsMatch(t, []string{"document:a", "document:b"}, objects)
+	})
+
+	t.Run("reading_from_before_horizon_errors", func(t *testing.T) {
+		from := ulid.MustNew(ulid.Timestamp(cutoff.Add(-time.Millisecond)), nil).String()
+		_, _, err := datastore.ReadChanges(ctx, storeID, storage.ReadChangesFilter{}, storage.ReadChangesOptions{
+			Pagination: storage.PaginationOptions{PageSize: 10, From: from},
+		})
+		require.ErrorIs(t, err, storage.ErrChangelogCompacted)
+	})
+
+	t.Run("compacting_with_older_cutoff_keeps_horizon", func(t *testing.T) {
+		err := datastore.CompactChangeLog(ctx, storeID, cutoff.Add(-time.Hour))
+		require.NoError(t, err)
+
+		from := ulid.MustNew(ulid.Timestamp(cutoff.Add(-time.Millisecond)), nil).String()
+		_, _, err = datastore.ReadChanges(ctx, storeID, storage.ReadChangesFilter{}, storage.ReadChangesOptions{
+			Pagination: storage.PaginationOptions{PageSize: 10, From: from},
+		})
+		require.ErrorIs(t, err, storage.ErrChangelogCompacted)
+	})
+
+	t.Run("removes_changes_in_several_batches", func(t *testing.T) {
+		storeID := ulid.Make().String()
+
+		// more changes than a batch of the SQL datastores
+		for i := range 25 {
+			tks := make([]*openfgav1.TupleKey, 0, storage.DefaultMaxTuplesPerWrite)
+			for j := range storage.DefaultMaxTuplesPerWrite {
+				tks = append(tks, tuple.NewTupleKey(fmt.Sprintf("document:%d-%d", i, j), "viewer", "user:jon"))
+			}
+			require.NoError(t, datastore.Write(ctx, storeID, nil, tks))
+		}
+
+		time.Sleep(2 * time.Millisecond)
+		cutoff := time.Now()
+		time.Sleep(2 * time.Millisecond)
+		require.NoError(t, datastore.Write(ctx, storeID, nil, []*openfgav1.TupleKey{tkA}))
+
+		err := datastore.CompactChangeLog(ctx, storeID, cutoff)
+		require.NoError(t, err)
+
+		changes := readChangesWithPageSize(t, datastore, storeID, 100, "")
+		require.Len(t, changes, 1)
+		require.Equal(t, tkA.GetObject(), changes[0].GetTupleKey().GetObject())
+	})
+
+	t.Run("deleting_the_store_removes_the_horizon", func(t *testing.T) {
+		store, err := datastore.CreateStore(ctx, &openfgav1.Store{Id: ulid.Make().String(), Name: "compacted"})
+		require.NoError(t, err)
+		require.NoError(t, datastore.Write(ctx, store.GetId(), nil, []*openfgav1.TupleKey{tkA}))
+
+		time.Sleep(2 * time.Millisecond)
+		cutoff := time.Now()
+		require.NoError(t, datastore.CompactChangeLog(ctx, store.GetId(), cutoff))
+		require.NoError(t, datastore.DeleteStore(ctx, store.GetId()))
+
+		from := ulid.MustNew(ulid.Timestamp(cutoff.Add(-time.Millisecond)), nil).String()
+		_, _, err = datastore.ReadChanges(ctx, store.GetId(), storage.ReadChangesFilter{}, storage.ReadChangesOptions{
+			Pagination: storage.PaginationOptions{PageSize: 10, From: from},
+		})
+		require.ErrorIs(t, err, storage.ErrNotFound)
+	})
+}
+
+func WriteBestEffortTest(t *testing.T, datastore storage.OpenFGADatastore) {
+	writer, ok := datastore.(storage.BestEffortTupleWriter)
+	if !ok {
+		t.Skip("the datastore doesn't support best effort writes")
+	}
+
+	ctx := context.Background()
+
+	tkA := tuple.NewTupleKey("document:a", "viewer", "user:jon")
+	tkB := tuple.NewTupleKey("document:b", "viewer", "user:jon")
+	tkC := tuple.NewTupleKey("document:c", "viewer", "user:jon")
+
+	t.Run("writes_the_tuples_that_do_not_exist", func(t *testing.T) {
+		storeID := ulid.Make().String()
+		require.NoError(t, datastore.Write(ctx, storeID, nil, []*openfgav1.TupleKey{tkA}))
+
+		results, err := writer.WriteBestEffort(ctx, storeID, []*openfgav1.TupleKey{tkB, tkA, tkC})
+		require.NoError(t, err)
+		require.Len(t, results, 3)
+
+		for i, expected := range []storage.TupleWriteStatus{
+			storage.TupleWriteStatusWritten,
+			storage.TupleWriteStatusAlreadyExists,
+			storage.TupleWriteStatusWritten,
+		} {
+			require.Equal(t, expected, results[i].Status)
+			require.NoError(t, results[i].Err)
+		}
+		require.Equal(t, tkA, results[1].TupleKey)
+
+		for _, tk := range []*openfgav1.TupleKey{tkA, tkB, tkC} {
+			_, err := datastore.ReadUserTuple(ctx, storeID, tk, storage.ReadUserTupleOptions{})
+			require.NoError(t, err)
+		}
+
+		// the written tuples are in the changelog, the one that already existed only once
+		require.Len(t, readChangesWithPageSize(t, datastore, storeID, 10, ""), 3)
+	})
+
+	t.Run("writes_all_the_tuples", func(t *testing.T) {
+		storeID := ulid.Make().String()
+
+		results, err := writer.WriteBestEffort(ctx, storeID, []*openfgav1.TupleKey{tkA, tkB})
+		require.NoError(t, err)
+		require.Len(t, results, 2)
+		for _, result := range results {
+			require.Equal(t, storage.TupleWriteStatusWritten, result.Status)
+		}
+
+		require.Len(t, readChangesWithPageSize(t, datastore, storeID, 10, ""), 2)
+	})
+}
+
 func readChangesWithPageSize(t *testing.T, ds storage.OpenFGADatastore, storeID string, pageSize int, objectTypeFilter string) []*openfgav1.TupleChange {
 	t.Helper()
 	var (
@@ -122,6 +122,207 @@ func NewCombinedIterator[T any](iters ...Iterator[T]) Iterator[T] {
 	return &combinedIterator[T]{pending: pending, once: &sync.Once{}, mu: &sync.Mutex{}}
 }
 
+// combinedTupleIteratorConcurrency is the maximum number of iterators that a combined tuple iterator reads at once.
+const combinedTupleIteratorConcurrency = 4
+
+// combinedTupleIterator is a thread-safe iterator that yields the tuples of multiple iterators in order, the ones of the
+// first iterator first, while reading ahead from up to combinedTupleIteratorConcurrency iterators concurrently.
+type combinedTupleIterator struct {
+	iters []TupleIterator
+	// results holds the tuples read from each iterator, it is closed once the iterator is exhausted.
+	results []chan *openfgav1.Tuple
+
+	startOnce sync.Once
+	stopOnce  sync.Once
+	cancel    context.CancelFunc
+	wg        sync.WaitGroup
+	stopped   chan struct{}
+
+	errOnce sync.Once
+	err     error
+	failed  chan struct{} // closed once err is set.
+
+	mu      sync.Mutex
+	current int              // GUARDED_BY(mu)
+	head    *openfgav1.Tuple // GUARDED_BY(mu)
+}
+
+var _ TupleIterator = (*combinedTupleIterator)(nil)
+
+// start reads the iterators in the background, in order, with the values of the context of the first call to
+// Next or Head. The reads outlive that call, so they are only cancelled by Stop or the first error, not by its
+// context. The reads hold a slot of the concurrency limit until their iterator is exhausted, and the slots are
+// taken in order, so the iterator being consumed is always read.
+func (c *combinedTupleIterator) start(ctx context.Context) {
+	c.startOnce.Do(func() {
+		ctx, c.cancel = context.WithCancel(context.WithoutCancel(ctx))
+		slots := make(chan struct{}, combinedTupleIteratorConcurrency)
+
+		c.wg.Add(1)
+		go func() {
+			defer c.wg.Done()
+			for i := range c.iters {
+				select {
+				case slots <- struct{}{}:
+				case <-ctx.Done():
+					return
+				}
+
+				c.wg.Add(1)
+				go func() {
+					defer c.wg.Done()
+					defer func() { <-slots }()
+					c.read(ctx, i)
+				}()
+			}
+		}()
+	})
+}
+
+// read sends the tuples of the iterator at index i to its results until it is exhausted.
+func (c *combinedTupleIterator) read(ctx context.Context, i int) {
+	defer close(c.results[i])
+
+	for {
+		t, err := c.iters[i].Next(ctx)
+		if err != nil {
+			// the errors of the reads cancelled by Stop or another error are not reported
+			if !errors.Is(err, ErrIteratorDone) && ctx.Err() == nil {
+				c.fail(err)
+			}
+			return
+		}
+
+		select {
+		case c.results[i] <- t:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// fail records the first error and cancels the reads of the iterators.
+func (c *combinedTupleIterator) fail(err error) {
+	c.errOnce.Do(func() {
+		c.err = err
+		close(c.failed)
+		c.cancel()
+	})
+}
+
+func (c *combinedTupleIterator) next(ctx context.Context, peek bool) (*openfgav1.Tuple, error) {
+	if ctx.Err() != nil {
+		return nil, ctx.Err()
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.isStopped() {
+		return nil, ErrIteratorDone
+	}
+
+	select {
+	case <-c.failed:
+		return nil, c.err
+	default:
+	}
+
+	if c.head != nil {
+		t := c.head
+		if !peek {
+			c.head = nil
+		}
+		return t, nil
+	}
+
+	c.start(ctx)
+
+	for c.current < len(c.results) {
+		select {
+		case t, ok := <-c.results[c.current]:
+			if !ok {
+				select {
+				case <-c.failed:
+					// the iterator stopped because of the error
+					return nil, c.err
+				default:
+				}
+				c.current++
+				continue
+			}
+			if peek {
+				c.head = t
+			}
+			return t, nil
+		case <-c.failed:
+			return nil, c.err
+		case <-c.stopped:
+			return nil, ErrIteratorDone
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+
+	return nil, ErrIteratorDone
+}
+
+func (c *combinedTupleIterator) isStopped() bool {
+	select {
+	case <-c.stopped:
+		return true
+	default:
+		return false
+	}
+}
+
+// Next see [Iterator.Next].
+func (c *combinedTupleIterator) Next(ctx context.Context) (*openfgav1.Tuple, error) {
+	return c.next(ctx, false)
+}
+
+// Head see [Iterator.Head].
+func (c *combinedTupleIterator) Head(ctx context.Context) (*openfgav1.Tuple, error) {
+	return c.next(ctx, true)
+}
+
+// Stop see [Iterator.Stop]. It waits for the reads in progress to end before it stops the iterators.
+func (c *combinedTupleIterator) Stop() {
+	c.stopOnce.Do(func() {
+		close(c.stopped)
+
+		// no read is started after this point
+		c.startOnce.Do(func() {})
+		if c.cancel != nil {
+			c.cancel()
+		}
+		c.wg.Wait()
+
+		for _, iter := range c.iters {
+			iter.Stop()
+		}
+	})
+}
+
+// NewCombinedTupleIterator returns a thread-safe [TupleIterator] that yields the tuples of all the iterators in order,
+// the ones of the first iterator first, like [NewCombinedIterator], but reads ahead from up to
+// combinedTupleIteratorConcurrency iterators concurrently. The iterators are read with the values of the context of
+// the first call to Next or Head, but aren't cancelled with it, so a later call can resume the iteration. The first error of an iterator ends the iteration and is returned by the subsequent calls.
+// Stop stops all the iterators, it can be called at any point of the iteration.
+func NewCombinedTupleIterator(iters ...TupleIterator) TupleIterator {
+	c := &combinedTupleIterator{
+		stopped: make(chan struct{}),
+		failed:  make(chan struct{}),
+	}
+	for _, iter := range iters {
+		if iter != nil {
+			c.iters = append(c.iters, iter)
+			c.results = append(c.results, make(chan *openfgav1.Tuple, DefaultPageSize))
+		}
+	}
+	return c
+}
+
 // NewStaticTupleIterator returns a [TupleIterator] that iterates over the provided slice.
 func NewStaticTupleIterator(tuples []*openfgav1.Tuple) TupleIterator {
 	iter := &StaticIterator[*openfgav1.Tuple]{
@@ -303,6 +504,77 @@ func NewFilteredTupleKeyIterator(iter TupleKeyIterator, filter TupleKeyFilterFun
 	}
 }
 
+// TupleFilterFunc is a filter function that is used to filter out
+// tuples from a [TupleIterator] that don't meet certain criteria.
+// Implementations should return true if the tuple should be returned
+// and false if it should be filtered out.
+type TupleFilterFunc func(tuple *openfgav1.Tuple) bool
+
+type filteredTupleIterator struct {
+	iter TupleIterator
+	keep TupleFilterFunc
+	once *sync.Once
+}
+
+var _ TupleIterator = (*filteredTupleIterator)(nil)
+
+// Next returns the next most tuple in the underlying iterator that meets
+// the filter function this iterator was constructed with.
+func (f *filteredTupleIterator) Next(ctx context.Context) (*openfgav1.Tuple, error) {
+	for {
+		tuple, err := f.iter.Next(ctx)
+		if err != nil {
+			return nil, err
+		}
+
+		if f.keep(tuple) {
+			return tuple, nil
+		}
+	}
+}
+
+// Stop see [Iterator.Stop].
+func (f *filteredTupleIterator) Stop() {
+	f.once.Do(func() {
+		f.iter.Stop()
+	})
+}
+
+// Head returns the next most tuple in the underlying iterator that meets
+// the filter function this iterator was constructed with.
+// Note: the underlying iterator will advance until the filter is satisfied.
+func (f *filteredTupleIterator) Head(ctx context.Context) (*openfgav1.Tuple, error) {
+	for {
+		tuple, err := f.iter.Head(ctx)
+		if err != nil {
+			return nil, err
+		}
+
+		if f.keep(tuple) {
+			return tuple, nil
+		}
+		_, err = f.iter.Next(ctx)
+		if err != nil {
+			return nil, err
+		}
+	}
+}
+
+// NewFilteredTupleIterator returns a [TupleIterator] that filters out all
+// [*openfgav1.Tuple](s) for which keep returns false, without reading them
+// ahead of the calls to Next and Head. It panics if keep is nil.
+func NewFilteredTupleIterator(iter TupleIterator, keep TupleFilterFunc) TupleIterator {
+	if keep == nil {
+		panic("NewFilteredTupleIterator: nil filter function")
+	}
+
+	return &filteredTupleIterator{
+		iter,
+		keep,
+		&sync.Once{},
+	}
+}
+
 // TupleKeyConditionFilterFunc is a filter function that is used to filter out
 // tuples from a [TupleKeyIterator] that don't meet the tuple the conditions provided by the request.
 // Implementations should return true if the tuple should be returned
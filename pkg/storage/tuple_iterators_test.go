@@ -5,11 +5,13 @@ import (
 	"errors"
 	"fmt"
 	"strconv"
+	"sync/atomic"
 	"testing"
 	"time"
 
 	"github.com/google/go-cmp/cmp"
 	"github.com/stretchr/testify/require"
+	"go.uber.org/goleak"
 	"go.uber.org/mock/gomock"
 	"golang.org/x/sync/errgroup"
 	"google.golang.org/protobuf/testing/protocmp"
@@ -318,6 +320,209 @@ func TestCombinedIterator(t *testing.T) {
 	})
 }
 
+// trackingTupleIterator counts the iterators being read, from their first call to Next until they are exhausted.
+type trackingTupleIterator struct {
+	TupleIterator
+	started   atomic.Bool
+	stopped   atomic.Bool
+	inflight  *atomic.Int32
+	max       *atomic.Int32
+	finalized atomic.Bool
+}
+
+func (c *trackingTupleIterator) Next(ctx context.Context) (*openfgav1.Tuple, error) {
+	if !c.started.Swap(true) {
+		n := c.inflight.Add(1)
+		for m := c.max.Load(); n > m && !c.max.CompareAndSwap(m, n); m = c.max.Load() {
+		}
+	}
+
+	t, err := c.TupleIterator.Next(ctx)
+	if err != nil && !c.finalized.Swap(true) {
+		c.inflight.Add(-1)
+	}
+	return t, err
+}
+
+func (c *trackingTupleIterator) Stop() {
+	c.stopped.Store(true)
+	c.TupleIterator.Stop()
+}
+
+// errorTupleIterator fails every read.
+type errorTupleIterator struct {
+	err     error
+	stopped atomic.Bool
+}
+
+func (e *errorTupleIterator) Next(context.Context) (*openfgav1.Tuple, error) {
+	return nil, e.err
+}
+
+func (e *errorTupleIterator) Head(context.Context) (*openfgav1.Tuple, error) {
+	return nil, e.err
+}
+
+func (e *errorTupleIterator) Stop() {
+	e.stopped.Store(true)
+}
+
+func TestCombinedTupleIterator(t *testing.T) {
+	t.Cleanup(func() {
+		goleak.VerifyNone(t)
+	})
+
+	newTuples := func(iter, n int) []*openfgav1.Tuple {
+		tuples := make([]*openfgav1.Tuple, 0, n)
+		for i := 0; i < n; i++ {
+			tuples = append(tuples, &openfgav1.Tuple{
+				Key: tuple.NewTupleKey(fmt.Sprintf("document:%d-%d", iter, i), "viewer", "user:anne"),
+			})
+		}
+		return tuples
+	}
+
+	newIterators := func(numIters, numTuples int) ([]*trackingTupleIterator, []*openfgav1.Tuple) {
+		var inflight, maxInflight atomic.Int32
+		var iters []*trackingTupleIterator
+		var expected []*openfgav1.Tuple
+		for i := 0; i < numIters; i++ {
+			tuples := newTuples(i, numTuples)
+			expected = append(expected, tuples...)
+			iters = append(iters, &trackingTupleIterator{
+				TupleIterator: NewStaticTupleIterator(tuples),
+				inflight:      &inflight,
+				max:           &maxInflight,
+			})
+		}
+		return iters, expected
+	}
+
+	combine := func(iters []*trackingTupleIterator) TupleIterator {
+		tupleIters := make([]TupleIterator, 0, len(iters))
+		for _, iter := range iters {
+			tupleIters = append(tupleIters, iter)
+		}
+		return NewCombinedTupleIterator(tupleIters...)
+	}
+
+	t.Run("yields_the_tuples_in_order", func(t *testing.T) {
+		iters, expected := newIterators(10, 2*DefaultPageSize)
+		iter := combine(iters)
+		defer iter.Stop()
+
+		var actual []*openfgav1.Tuple
+		for {
+			head, err := iter.Head(context.Background())
+			if errors.Is(err, ErrIteratorDone) {
+				break
+			}
+			require.NoError(t, err)
+
+			tk, err := iter.Next(context.Background())
+			require.NoError(t, err)
+			require.Same(t, head, tk)
+
+			actual = append(actual, tk)
+		}
+		require.Equal(t, expected, actual)
+
+		_, err := iter.Next(context.Background())
+		require.ErrorIs(t, err, ErrIteratorDone)
+
+		// the readers of the iterators are bounded
+		require.LessOrEqual(t, iters[0].max.Load(), int32(combinedTupleIteratorConcurrency))
+	})
+
+	t.Run("empty", func(t *testing.T) {
+		iter := NewCombinedTupleIterator(nil, NewStaticTupleIterator(nil))
+		defer iter.Stop()
+
+		_, err := iter.Head(context.Background())
+		require.ErrorIs(t, err, ErrIteratorDone)
+		_, err = iter.Next(context.Background())
+		require.ErrorIs(t, err, ErrIteratorDone)
+	})
+
+	t.Run("returns_the_first_error", func(t *testing.T) {
+		iters, _ := newIterators(3, 1)
+		failing := &errorTupleIterator{err: errors.New("boom")}
+
+		iter := NewCombinedTupleIterator(iters[0], failing, iters[1], iters[2])
+
+		var err error
+		for err == nil {
+			_, err = iter.Next(context.Background())
+		}
+		require.EqualError(t, err, "boom")
+
+		_, err = iter.Head(context.Background())
+		require.EqualError(t, err, "boom")
+
+		iter.Stop()
+		require.True(t, failing.stopped.Load())
+	})
+
+	t.Run("stop_mid_iteration", func(t *testing.T) {
+		iters, expected := newIterators(10, 2*DefaultPageSize)
+		iter := combine(iters)
+
+		tk, err := iter.Next(context.Background())
+		require.NoError(t, err)
+		require.Equal(t, expected[0], tk)
+
+		iter.Stop()
+		iter.Stop()
+
+		for _, it := range iters {
+			require.True(t, it.stopped.Load())
+		}
+
+		_, err = iter.Next(context.Background())
+		require.ErrorIs(t, err, ErrIteratorDone)
+		_, err = iter.Head(context.Background())
+		require.ErrorIs(t, err, ErrIteratorDone)
+	})
+
+	t.Run("context_of_the_first_call_cancelled", func(t *testing.T) {
+		iters, expected := newIterators(2, 5*DefaultPageSize)
+		iter := combine(iters)
+		defer iter.Stop()
+
+		ctx, cancel := context.WithCancel(context.Background())
+		tk, err := iter.Next(ctx)
+		require.NoError(t, err)
+		cancel()
+
+		_, err = iter.Next(ctx)
+		require.ErrorIs(t, err, context.Canceled)
+
+		// the reads aren't cancelled with the first call, so the iteration resumes without missing tuples
+		actual := []*openfgav1.Tuple{tk}
+		for {
+			tk, err := iter.Next(context.Background())
+			if errors.Is(err, ErrIteratorDone) {
+				break
+			}
+			require.NoError(t, err)
+			actual = append(actual, tk)
+		}
+		require.Equal(t, expected, actual)
+	})
+
+	t.Run("stop_before_iteration", func(t *testing.T) {
+		iters, _ := newIterators(2, 1)
+		iter := combine(iters)
+		iter.Stop()
+
+		for _, it := range iters {
+			require.True(t, it.stopped.Load())
+		}
+		_, err := iter.Next(context.Background())
+		require.ErrorIs(t, err, ErrIteratorDone)
+	})
+}
+
 func TestOrderedCombinedIterator(t *testing.T) {
 	t.Run("Stop", func(t *testing.T) {
 		iter1 := NewStaticTupleIterator([]*openfgav1.Tuple{
@@ -593,6 +798,89 @@ func TestTupleKeyIteratorFromTupleIterator(t *testing.T) {
 	}
 }
 
+func TestFilteredTupleIterator(t *testing.T) {
+	tuples := []*openfgav1.Tuple{
+		{Key: tuple.NewTupleKey("document:doc1", "viewer", "user:jon")},
+		{Key: tuple.NewTupleKey("document:doc1", "editor", "user:elbuo")},
+		{Key: tuple.NewTupleKey("document:doc2", "viewer", "user:elbuo")},
+		{Key: tuple.NewTupleKey("document:doc2", "editor", "user:charlie")},
+		{Key: tuple.NewTupleKey("document:doc3", "viewer", "user:charlie")},
+	}
+	isEditor := func(t *openfgav1.Tuple) bool {
+		return t.GetKey().GetRelation() == "editor"
+	}
+
+	t.Run("next", func(t *testing.T) {
+		iter := NewFilteredTupleIterator(NewStaticTupleIterator(tuples), isEditor)
+		defer iter.Stop()
+
+		var actual []*openfgav1.Tuple
+		for {
+			tk, err := iter.Next(context.Background())
+			if errors.Is(err, ErrIteratorDone) {
+				break
+			}
+			require.NoError(t, err)
+			actual = append(actual, tk)
+		}
+		require.Equal(t, []*openfgav1.Tuple{tuples[1], tuples[3]}, actual)
+	})
+
+	t.Run("head", func(t *testing.T) {
+		iter := NewFilteredTupleIterator(NewStaticTupleIterator(tuples), isEditor)
+		defer iter.Stop()
+
+		for _, expected := range []*openfgav1.Tuple{tuples[1], tuples[3]} {
+			head, err := iter.Head(context.Background())
+			require.NoError(t, err)
+			require.Equal(t, expected, head)
+
+			head, err = iter.Head(context.Background())
+			require.NoError(t, err)
+			require.Equal(t, expected, head)
+
+			next, err := iter.Next(context.Background())
+			require.NoError(t, err)
+			require.Equal(t, expected, next)
+		}
+
+		_, err := iter.Head(context.Background())
+		require.ErrorIs(t, err, ErrIteratorDone)
+		_, err = iter.Next(context.Background())
+		require.ErrorIs(t, err, ErrIteratorDone)
+	})
+
+	t.Run("propagates_errors", func(t *testing.T) {
+		inner := &errorTupleIterator{err: errors.New("boom")}
+		iter := NewFilteredTupleIterator(inner, isEditor)
+
+		_, err := iter.Next(context.Background())
+		require.EqualError(t, err, "boom")
+		_, err = iter.Head(context.Background())
+		require.EqualError(t, err, "boom")
+
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		iter = NewFilteredTupleIterator(NewStaticTupleIterator(tuples), isEditor)
+		defer iter.Stop()
+		_, err = iter.Next(ctx)
+		require.ErrorIs(t, err, context.Canceled)
+	})
+
+	t.Run("stop", func(t *testing.T) {
+		inner := &errorTupleIterator{}
+		iter := NewFilteredTupleIterator(inner, isEditor)
+		iter.Stop()
+		require.True(t, inner.stopped.Load())
+	})
+
+	t.Run("nil_filter_panics", func(t *testing.T) {
+		require.PanicsWithValue(t, "NewFilteredTupleIterator: nil filter function", func() {
+			NewFilteredTupleIterator(NewStaticTupleIterator(tuples), nil)
+		})
+	})
+}
+
 func TestFilteredTupleKeyIterator(t *testing.T) {
 	t.Run("next", func(t *testing.T) {
 		tuples := []*openfgav1.TupleKey{
@@ -0,0 +1,310 @@
+package tuple
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"google.golang.org/protobuf/types/known/structpb"
+
+	openfgav1 "github.com/openfga/api/proto/openfga/v1"
+)
+
+// conditionKeyword separates a tuple key from its condition in the text form.
+const conditionKeyword = " with "
+
+// CSVHeader is the header of tuples in CSV form, the same columns that the OpenFGA CLI reads and writes.
+// The user is split into its type, id and (optional) relation and the object into its type and id.
+// The condition context is a JSON object.
+var CSVHeader = []string{
+	"user_type",
+	"user_id",
+	"user_relation",
+	"relation",
+	"object_type",
+	"object_id",
+	"condition_name",
+	"condition_context",
+}
+
+// FormatTupleKey returns the canonical text form of a tuple key, which extends the string notation
+// 'document:1#viewer@user:jon' with the condition of the tuple, if any:
+//
+//	document:1#viewer@user:jon with in_region {"region":"eu"}
+//
+// The context of the condition is omitted if it's empty. The keys of the context are sorted, so equal
+// tuple keys have the same text form.
+func FormatTupleKey(tk TupleWithCondition) (string, error) {
+	if err := validateTupleKeyFields(tk); err != nil {
+		return "", &InvalidTupleError{Cause: err, TupleKey: tk}
+	}
+
+	s := TupleKeyToString(tk)
+
+	condition := tk.GetCondition()
+	if condition == nil {
+		return s, nil
+	}
+
+	s += conditionKeyword + condition.GetName()
+	if len(condition.GetContext().GetFields()) == 0 {
+		return s, nil
+	}
+
+	contextJSON, err := formatConditionContext(condition.GetContext())
+	if err != nil {
+		return "", err
+	}
+	return s + " " + contextJSON, nil
+}
+
+// ParseTupleKey parses a tuple key from its canonical text form, see FormatTupleKey. The context of a
+// condition without context is empty.
+func ParseTupleKey(s string) (*openfgav1.TupleKey, error) {
+	key, condition, hasCondition := strings.Cut(s, conditionKeyword)
+
+	tk, err := ParseTupleString(key)
+	if err != nil {
+		return nil, &MalformedTupleError{Input: s, Cause: err}
+	}
+
+	if !hasCondition {
+		return tk, nil
+	}
+
+	name, contextJSON, _ := strings.Cut(condition, " ")
+	tk.Condition, err = parseCondition(name, contextJSON)
+	if err != nil {
+		return nil, &MalformedTupleError{Input: s, Cause: err}
+	}
+	return tk, nil
+}
+
+// FormatCSVRecord returns the CSV record of a tuple key, with the columns of CSVHeader.
+func FormatCSVRecord(tk TupleWithCondition) ([]string, error) {
+	if err := validateTupleKeyFields(tk); err != nil {
+		return nil, &InvalidTupleError{Cause: err, TupleKey: tk}
+	}
+
+	userType, userID, userRelation := ToUserParts(tk.GetUser())
+	objectType, objectID := SplitObject(tk.GetObject())
+
+	var conditionName, conditionContext string
+	if condition := tk.GetCondition(); condition != nil {
+		conditionName = condition.GetName()
+		if len(condition.GetContext().GetFields()) > 0 {
+			var err error
+			conditionContext, err = formatConditionContext(condition.GetContext())
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	return []string{
+		userType,
+		userID,
+		userRelation,
+		tk.GetRelation(),
+		objectType,
+		objectID,
+		conditionName,
+		conditionContext,
+	}, nil
+}
+
+// ParseCSVRecord parses a tuple key from a CSV record with the columns of CSVHeader. The condition
+// columns may be omitted from the record.
+func ParseCSVRecord(record []string) (*openfgav1.TupleKey, error) {
+	if len(record) != len(CSVHeader) && len(record) != len(CSVHeader)-2 {
+		return nil, &MalformedTupleError{
+			Input: strings.Join(record, ","),
+			Cause: fmt.Errorf("expected %d columns but got %d", len(CSVHeader), len(record)),
+		}
+	}
+
+	user := FromUserParts(record[0], record[1], record[2])
+	tk := NewTupleKey(BuildObject(record[4], record[5]), record[3], user)
+	if err := validateTupleKeyFields(tk); err != nil {
+		return nil, &MalformedTupleError{Input: strings.Join(record, ","), Cause: err}
+	}
+
+	if len(record) == len(CSVHeader) && (record[6] != "" || record[7] != "") {
+		var err error
+		tk.Condition, err = parseCondition(record[6], record[7])
+		if err != nil {
+			return nil, &MalformedTupleError{Input: strings.Join(record, ","), Cause: err}
+		}
+	}
+	return tk, nil
+}
+
+// ReadCSV reads the tuple keys of CSV input that starts with the header row (see CSVHeader). The
+// columns may be in any order and the condition columns may be omitted. The error of a malformed
+// row is a *MalformedTupleError that reports the line of the row.
+func ReadCSV(r io.Reader) ([]*openfgav1.TupleKey, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+
+	header, err := reader.Read()
+	if err != nil {
+		if errors.Is(err, io.EOF) {
+			return nil, nil
+		}
+		return nil, &MalformedTupleError{Line: 1, Cause: err}
+	}
+
+	columns, err := csvColumns(header)
+	if err != nil {
+		return nil, &MalformedTupleError{Input: strings.Join(header, ","), Line: 1, Cause: err}
+	}
+
+	var tks []*openfgav1.TupleKey
+	for {
+		row, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			return tks, nil
+		}
+
+		line, _ := reader.FieldPos(0)
+		if err != nil {
+			return nil, &MalformedTupleError{Line: line, Cause: err}
+		}
+
+		if len(row) != len(header) {
+			return nil, &MalformedTupleError{
+				Input: strings.Join(row, ","),
+				Line:  line,
+				Cause: fmt.Errorf("expected %d columns but got %d", len(header), len(row)),
+			}
+		}
+
+		record := make([]string, len(CSVHeader))
+		for i, column := range columns {
+			if column >= 0 {
+				record[i] = row[column]
+			}
+		}
+
+		tk, err := ParseCSVRecord(record)
+		if err != nil {
+			var malformed *MalformedTupleError
+			if errors.As(err, &malformed) {
+				malformed.Input = strings.Join(row, ",")
+				malformed.Line = line
+			}
+			return nil, err
+		}
+		tks = append(tks, tk)
+	}
+}
+
+// WriteCSV writes the tuple keys as CSV, starting with the header row (see CSVHeader).
+func WriteCSV(w io.Writer, tks []*openfgav1.TupleKey) error {
+	writer := csv.NewWriter(w)
+	if err := writer.Write(CSVHeader); err != nil {
+		return err
+	}
+
+	for _, tk := range tks {
+		record, err := FormatCSVRecord(tk)
+		if err != nil {
+			return err
+		}
+		if err := writer.Write(record); err != nil {
+			return err
+		}
+	}
+
+	writer.Flush()
+	return writer.Error()
+}
+
+// csvColumns maps the columns of CSVHeader to their index in the header, or -1 if the header
+// doesn't have the column.
+func csvColumns(header []string) ([]int, error) {
+	columns := make([]int, len(CSVHeader))
+	for i := range columns {
+		columns[i] = -1
+	}
+
+	for i, name := range header {
+		found := false
+		for j, column := range CSVHeader {
+			if strings.TrimSpace(name) == column {
+				if columns[j] >= 0 {
+					return nil, fmt.Errorf("duplicate column '%s'", column)
+				}
+				columns[j] = i
+				found = true
+			}
+		}
+		if !found {
+			return nil, fmt.Errorf("unknown column '%s'", name)
+		}
+	}
+
+	for i, column := range CSVHeader[:len(CSVHeader)-2] {
+		if columns[i] < 0 {
+			return nil, fmt.Errorf("missing column '%s'", column)
+		}
+	}
+	return columns, nil
+}
+
+// validateTupleKeyFields validates the object, relation and user of a tuple key like ParseTupleString.
+func validateTupleKeyFields(tk TupleWithoutCondition) error {
+	if !IsValidObject(tk.GetObject()) {
+		return fmt.Errorf("invalid tuple 'object' field format")
+	}
+
+	if !IsValidRelation(tk.GetRelation()) {
+		return fmt.Errorf("invalid tuple 'relation' field format")
+	}
+
+	if !IsValidUser(tk.GetUser()) {
+		return fmt.Errorf("invalid tuple 'user' field format")
+	}
+	return nil
+}
+
+func formatConditionContext(context *structpb.Struct) (string, error) {
+	// encoding/json sorts the keys of maps, unlike protojson, whose output isn't stable
+	b, err := json.Marshal(context.AsMap())
+	if err != nil {
+		return "", fmt.Errorf("invalid condition context: %w", err)
+	}
+	return string(b), nil
+}
+
+func parseCondition(name, contextJSON string) (*openfgav1.RelationshipCondition, error) {
+	condition := NewRelationshipCondition(name, nil)
+	if condition == nil {
+		return nil, errors.New("missing condition name")
+	}
+
+	if err := condition.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid condition name '%s'", name)
+	}
+
+	if contextJSON == "" {
+		return condition, nil
+	}
+
+	var contextMap map[string]any
+	if err := json.Unmarshal([]byte(contextJSON), &contextMap); err != nil {
+		return nil, fmt.Errorf("invalid condition context: %w", err)
+	}
+
+	if contextMap != nil {
+		context, err := structpb.NewStruct(contextMap)
+		if err != nil {
+			return nil, fmt.Errorf("invalid condition context: %w", err)
+		}
+		condition.Context = context
+	}
+	return condition, nil
+}
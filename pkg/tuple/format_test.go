@@ -0,0 +1,241 @@
+package tuple
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/structpb"
+
+	openfgav1 "github.com/openfga/api/proto/openfga/v1"
+)
+
+func TestFormatTupleKey(t *testing.T) {
+	var tests = []struct {
+		name     string
+		tupleKey *openfgav1.TupleKey
+		expected string
+	}{
+		{
+			name:     "user",
+			tupleKey: NewTupleKey("document:1", "viewer", "user:jon"),
+			expected: "document:1#viewer@user:jon",
+		},
+		{
+			name:     "userset",
+			tupleKey: NewTupleKey("document:1", "viewer", "group:eng#member"),
+			expected: "document:1#viewer@group:eng#member",
+		},
+		{
+			name:     "wildcard",
+			tupleKey: NewTupleKey("document:1", "viewer", "user:*"),
+			expected: "document:1#viewer@user:*",
+		},
+		{
+			name:     "condition_without_context",
+			tupleKey: NewTupleKeyWithCondition("document:1", "viewer", "user:jon", "in_region", nil),
+			expected: "document:1#viewer@user:jon with in_region",
+		},
+		{
+			name: "condition_with_context",
+			tupleKey: NewTupleKeyWithCondition("document:1", "viewer", "user:*", "in_region", MustNewStruct(map[string]any{
+				"region":  "eu west",
+				"allowed": []any{"a", "b"},
+				"limit":   10,
+			})),
+			expected: `document:1#viewer@user:* with in_region {"allowed":["a","b"],"limit":10,"region":"eu west"}`,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			s, err := FormatTupleKey(test.tupleKey)
+			require.NoError(t, err)
+			require.Equal(t, test.expected, s)
+
+			tk, err := ParseTupleKey(s)
+			require.NoError(t, err)
+			require.True(t, proto.Equal(test.tupleKey, tk), "got %v", tk)
+		})
+	}
+}
+
+func TestParseTupleKeyErrors(t *testing.T) {
+	var tests = []struct {
+		name  string
+		input string
+		cause string
+	}{
+		{
+			name:  "missing_relation",
+			input: "document:1@user:jon",
+			cause: "expected at least one '#' separating the object and relation",
+		},
+		{
+			name:  "invalid_user",
+			input: "document:1#viewer@group:e:eng#member",
+			cause: "invalid tuple 'user' field format",
+		},
+		{
+			name:  "missing_condition_name",
+			input: "document:1#viewer@user:jon with ",
+			cause: "missing condition name",
+		},
+		{
+			name:  "invalid_condition_name",
+			input: "document:1#viewer@user:jon with x",
+			cause: "invalid condition name 'x'",
+		},
+		{
+			name:  "invalid_condition_context",
+			input: "document:1#viewer@user:jon with in_region {region}",
+			cause: "invalid condition context",
+		},
+		{
+			name:  "condition_context_not_an_object",
+			input: `document:1#viewer@user:jon with in_region ["eu"]`,
+			cause: "invalid condition context",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			_, err := ParseTupleKey(test.input)
+			require.ErrorIs(t, err, &MalformedTupleError{})
+			require.ErrorContains(t, err, test.cause)
+			require.ErrorContains(t, err, test.input)
+		})
+	}
+}
+
+func TestCSV(t *testing.T) {
+	tupleKeys := []*openfgav1.TupleKey{
+		NewTupleKey("document:1", "viewer", "user:jon"),
+		NewTupleKey("document:1", "viewer", "group:eng#member"),
+		NewTupleKey("document:1", "viewer", "user:*"),
+		NewTupleKeyWithCondition("document:1", "viewer", "user:maria", "in_region", nil),
+		NewTupleKeyWithCondition("document:1", "viewer", "user:maria", "in_region", MustNewStruct(map[string]any{
+			"region": "eu, west",
+		})),
+	}
+
+	t.Run("round_trip", func(t *testing.T) {
+		var buf bytes.Buffer
+		require.NoError(t, WriteCSV(&buf, tupleKeys))
+		require.Equal(t, `user_type,user_id,user_relation,relation,object_type,object_id,condition_name,condition_context
+user,jon,,viewer,document,1,,
+group,eng,member,viewer,document,1,,
+user,*,,viewer,document,1,,
+user,maria,,viewer,document,1,in_region,
+user,maria,,viewer,document,1,in_region,"{""region"":""eu, west""}"
+`, buf.String())
+
+		got, err := ReadCSV(&buf)
+		require.NoError(t, err)
+		require.Len(t, got, len(tupleKeys))
+		for i := range tupleKeys {
+			require.True(t, proto.Equal(tupleKeys[i], got[i]), "got %v", got[i])
+		}
+	})
+
+	t.Run("columns_in_any_order_without_conditions", func(t *testing.T) {
+		got, err := ReadCSV(strings.NewReader("object_type,object_id,relation,user_type,user_id,user_relation\ndocument,1,viewer,group,eng,member\n"))
+		require.NoError(t, err)
+		require.Len(t, got, 1)
+		require.True(t, proto.Equal(tupleKeys[1], got[0]), "got %v", got[0])
+	})
+
+	t.Run("empty_input", func(t *testing.T) {
+		got, err := ReadCSV(strings.NewReader(""))
+		require.NoError(t, err)
+		require.Empty(t, got)
+	})
+
+	var errorTests = []struct {
+		name  string
+		input string
+		cause string
+	}{
+		{
+			name:  "unknown_column",
+			input: "user_type,user_id,user_relation,relation,object_type,object_id,comment\n",
+			cause: "malformed tuple 'user_type,user_id,user_relation,relation,object_type,object_id,comment' on line 1. Reason: unknown column 'comment'",
+		},
+		{
+			name:  "missing_column",
+			input: "user_type,user_id,relation,object_type,object_id\n",
+			cause: "missing column 'user_relation'",
+		},
+		{
+			name:  "wrong_number_of_columns",
+			input: "user_type,user_id,user_relation,relation,object_type,object_id\nuser,jon,,viewer,document,1\nuser,jon,viewer\n",
+			cause: "malformed tuple 'user,jon,viewer' on line 3. Reason: expected 6 columns but got 3",
+		},
+		{
+			name:  "invalid_object",
+			input: "user_type,user_id,user_relation,relation,object_type,object_id\nuser,jon,,viewer,,1\n",
+			cause: "malformed tuple 'user,jon,,viewer,,1' on line 2. Reason: invalid tuple 'object' field format",
+		},
+		{
+			name:  "invalid_condition_context",
+			input: "user_type,user_id,user_relation,relation,object_type,object_id,condition_name,condition_context\nuser,jon,,viewer,document,1,in_region,{\n",
+			cause: "on line 2. Reason: invalid condition context",
+		},
+		{
+			name:  "context_without_condition_name",
+			input: "user_type,user_id,user_relation,relation,object_type,object_id,condition_name,condition_context\nuser,jon,,viewer,document,1,,{}\n",
+			cause: "on line 2. Reason: missing condition name",
+		},
+	}
+
+	for _, test := range errorTests {
+		t.Run(test.name, func(t *testing.T) {
+			_, err := ReadCSV(strings.NewReader(test.input))
+			require.ErrorIs(t, err, &MalformedTupleError{})
+			require.ErrorContains(t, err, test.cause)
+		})
+	}
+}
+
+func FuzzTupleKeyRoundTrip(f *testing.F) {
+	f.Add("document:1", "viewer", "user:jon", "", "", "")
+	f.Add("document:1", "viewer", "group:eng#member", "in_region", "region", "eu")
+	f.Add("document:1", "viewer", "user:*", "in_region", "", "")
+	f.Add("document:1 with", "viewer", "user:\"x\"", "c,d", "a b", "{\"}")
+
+	f.Fuzz(func(t *testing.T, object, relation, user, conditionName, contextKey, contextValue string) {
+		tupleKey := NewTupleKey(object, relation, user)
+		if validateTupleKeyFields(tupleKey) != nil {
+			t.Skip()
+		}
+
+		if conditionName != "" {
+			var context map[string]any
+			if contextKey != "" {
+				context = map[string]any{contextKey: contextValue}
+			}
+			contextStruct, err := structpb.NewStruct(context)
+			if err != nil {
+				t.Skip()
+			}
+			tupleKey = NewTupleKeyWithCondition(object, relation, user, conditionName, contextStruct)
+			if tupleKey.GetCondition().Validate() != nil {
+				t.Skip()
+			}
+		}
+
+		s, err := FormatTupleKey(tupleKey)
+		require.NoError(t, err)
+		parsed, err := ParseTupleKey(s)
+		require.NoError(t, err, s)
+		require.True(t, proto.Equal(tupleKey, parsed), "%s: got %v, want %v", s, parsed, tupleKey)
+
+		record, err := FormatCSVRecord(tupleKey)
+		require.NoError(t, err)
+		parsed, err = ParseCSVRecord(record)
+		require.NoError(t, err, record)
+		require.True(t, proto.Equal(tupleKey, parsed), "%v: got %v, want %v", record, parsed, tupleKey)
+	})
+}
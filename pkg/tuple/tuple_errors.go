@@ -68,3 +68,28 @@ func (i *RelationNotFoundError) Is(target error) bool {
 	_, ok := target.(*RelationNotFoundError)
 	return ok
 }
+
+// MalformedTupleError is returned if a serialized tuple can't be parsed.
+type MalformedTupleError struct {
+	Cause error
+	Input string
+	// Line is the line of the input, if known.
+	Line int
+}
+
+func (i *MalformedTupleError) Error() string {
+	msg := fmt.Sprintf("malformed tuple '%s'", i.Input)
+	if i.Line > 0 {
+		msg += fmt.Sprintf(" on line %d", i.Line)
+	}
+	return msg + fmt.Sprintf(". Reason: %s", i.Cause)
+}
+
+func (i *MalformedTupleError) Unwrap() error {
+	return i.Cause
+}
+
+func (i *MalformedTupleError) Is(target error) bool {
+	_, ok := target.(*MalformedTupleError)
+	return ok
+}
@@ -0,0 +1,45 @@
+package tuple
+
+import "fmt"
+
+// AnonymousUserID is the ID of the anonymous principal of a type, e.g. 'user:$anonymous', which stands for the
+// unauthenticated callers of the type. Whether typed wildcards apply to it is determined by the WildcardPolicy.
+const AnonymousUserID = "$anonymous"
+
+// IsAnonymousUser returns true if the user is the anonymous principal of its type, e.g. 'user:$anonymous'.
+func IsAnonymousUser(user string) bool {
+	if IsObjectRelation(user) {
+		return false
+	}
+	_, id := SplitObject(user)
+	return id == AnonymousUserID
+}
+
+// WildcardPolicy determines the users of a type that a typed wildcard, e.g. 'user:*', applies to.
+type WildcardPolicy int
+
+const (
+	// WildcardPolicyIncludeAnonymous applies typed wildcards to all the users of the type, including the
+	// anonymous principal. This is the default, under which the anonymous principal is a user like any other.
+	WildcardPolicyIncludeAnonymous WildcardPolicy = iota
+
+	// WildcardPolicyAuthenticated applies typed wildcards to all the users of the type except for the
+	// anonymous principal.
+	WildcardPolicyAuthenticated
+)
+
+// AppliesTo returns true if a typed wildcard of the type of the user applies to the user under the policy.
+func (p WildcardPolicy) AppliesTo(user string) bool {
+	return p != WildcardPolicyAuthenticated || !IsAnonymousUser(user)
+}
+
+func (p WildcardPolicy) String() string {
+	switch p {
+	case WildcardPolicyIncludeAnonymous:
+		return "include_anonymous"
+	case WildcardPolicyAuthenticated:
+		return "authenticated"
+	default:
+		return fmt.Sprintf("WildcardPolicy(%d)", int(p))
+	}
+}
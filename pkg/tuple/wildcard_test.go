@@ -0,0 +1,21 @@
+package tuple
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestIsAnonymousUser(t *testing.T) {
+	require.True(t, IsAnonymousUser("user:$anonymous"))
+	require.False(t, IsAnonymousUser("user:jon"))
+	require.False(t, IsAnonymousUser("user:*"))
+	require.False(t, IsAnonymousUser("group:$anonymous#member"))
+}
+
+func TestWildcardPolicyAppliesTo(t *testing.T) {
+	require.True(t, WildcardPolicyAuthenticated.AppliesTo("user:jon"))
+	require.False(t, WildcardPolicyAuthenticated.AppliesTo("user:$anonymous"))
+	require.True(t, WildcardPolicyIncludeAnonymous.AppliesTo("user:jon"))
+	require.True(t, WildcardPolicyIncludeAnonymous.AppliesTo("user:$anonymous"))
+}
@@ -3,6 +3,8 @@ package typesystem
 import (
 	"errors"
 	"fmt"
+	"slices"
+	"strings"
 
 	"github.com/openfga/openfga/pkg/tuple"
 )
@@ -82,6 +84,29 @@ func (e *InvalidRelationError) Unwrap() error {
 	return e.Cause
 }
 
+// CycleError represents an error indicating a cycle of computed relationships in an authorization model.
+// It matches ErrCycle.
+type CycleError struct {
+	cycle []string
+}
+
+// Error implements the error interface for CycleError.
+func (e *CycleError) Error() string {
+	path := append(slices.Clone(e.cycle), e.cycle[0])
+	return fmt.Sprintf("%s: %s", ErrCycle, strings.Join(path, " -> "))
+}
+
+// Cycle returns the ordered `objectType#relation` nodes that form the cycle, each one rewritten to the next
+// and the last one to the first.
+func (e *CycleError) Cycle() []string {
+	return slices.Clone(e.cycle)
+}
+
+// Unwrap returns the underlying cause of the error.
+func (e *CycleError) Unwrap() error {
+	return ErrCycle
+}
+
 // ObjectTypeUndefinedError represents an error indicating an undefined object type.
 type ObjectTypeUndefinedError struct {
 	ObjectType string
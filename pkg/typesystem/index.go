@@ -0,0 +1,123 @@
+package typesystem
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	openfgav1 "github.com/openfga/api/proto/openfga/v1"
+
+	"github.com/openfga/openfga/pkg/tuple"
+)
+
+// IndexVersion is the version of the serialized Index format.
+const IndexVersion = "1"
+
+// Index is the reachability map of an authorization model, i.e. whether PathExists from every user type and userset
+// type of the model to every relation of the model. The pairs without a path are statically impossible, their
+// Checks are always denied. It's precomputed so that it can be built offline, e.g. when the model is published, and
+// loaded at startup, so that the Checks look the reachability up rather than analyze the model.
+//
+// An Index is immutable and safe for concurrent use.
+type Index struct {
+	modelID string
+	// [userType or userType#relation objectType#relation] => whether a path exists, see pathExistsKey.
+	reachability map[string]bool
+}
+
+// indexJSON is the serialized form of an Index.
+type indexJSON struct {
+	Version              string          `json:"version"`
+	AuthorizationModelID string          `json:"authorization_model_id"`
+	Reachability         map[string]bool `json:"reachability"`
+}
+
+// BuildIndex builds the Index of the model. The model must have an ID, since the indexes are looked up by model
+// ID, and it's assumed to be valid, like with New.
+func BuildIndex(model *openfgav1.AuthorizationModel) (*Index, error) {
+	if model.GetId() == "" {
+		return nil, errors.New("the authorization model of an index must have an ID")
+	}
+
+	t, err := newTypeSystem(model)
+	if err != nil {
+		return nil, err
+	}
+
+	idx := &Index{
+		modelID:      model.GetId(),
+		reachability: make(map[string]bool),
+	}
+
+	var sources []string
+	for userType, relations := range t.relations {
+		sources = append(sources, userType)
+		for relation := range relations {
+			sources = append(sources, tuple.ToObjectRelationString(userType, relation))
+		}
+	}
+
+	for objectType, relations := range t.relations {
+		for relation := range relations {
+			toLabel := tuple.ToObjectRelationString(objectType, relation)
+			for _, source := range sources {
+				userType, userRelation := tuple.SplitObjectRelation(source)
+				exists, err := t.pathExistsFrom(userType, source, userRelation != "", toLabel)
+				if err != nil {
+					return nil, err
+				}
+				idx.reachability[pathExistsKey(source, toLabel)] = exists
+			}
+		}
+	}
+
+	return idx, nil
+}
+
+// AuthorizationModelID returns the ID of the model of the index.
+func (idx *Index) AuthorizationModelID() string {
+	return idx.modelID
+}
+
+// PathExists returns the result of TypeSystem.PathExists for the model of the index, and whether the index holds
+// it. The index doesn't hold the results for the types or relations that the model doesn't define.
+func (idx *Index) PathExists(user, relation, objectType string) (exists bool, ok bool) {
+	_, userTypeRelation, _ := pathExistsSource(user)
+	exists, ok = idx.reachability[pathExistsKey(userTypeRelation, tuple.ToObjectRelationString(objectType, relation))]
+	return exists, ok
+}
+
+// Marshal serializes the index. The serialized form is deterministic, so the index of a model can be compared
+// or content-addressed.
+func (idx *Index) Marshal() ([]byte, error) {
+	return json.Marshal(indexJSON{
+		Version:              IndexVersion,
+		AuthorizationModelID: idx.modelID,
+		Reachability:         idx.reachability,
+	})
+}
+
+// UnmarshalIndex deserializes an index serialized with Index.Marshal.
+func UnmarshalIndex(data []byte) (*Index, error) {
+	var raw indexJSON
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return nil, err
+	}
+
+	if raw.Version != IndexVersion {
+		return nil, fmt.Errorf("unsupported index version '%s'", raw.Version)
+	}
+
+	if raw.AuthorizationModelID == "" {
+		return nil, errors.New("the index has no authorization model ID")
+	}
+
+	idx := &Index{
+		modelID:      raw.AuthorizationModelID,
+		reachability: raw.Reachability,
+	}
+	if idx.reachability == nil {
+		idx.reachability = map[string]bool{}
+	}
+	return idx, nil
+}
@@ -0,0 +1,97 @@
+package typesystem
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/openfga/openfga/pkg/testutils"
+)
+
+// githubModel is the github sample model.
+const githubModel = `
+	model
+		schema 1.1
+	type user
+	type team
+		relations
+			define member: [user, team#member]
+	type organization
+		relations
+			define owner: [user]
+			define member: [user] or owner
+			define repo_admin: [user, organization#member]
+			define repo_reader: [user, organization#member]
+			define repo_writer: [user, organization#member]
+	type repo
+		relations
+			define owner: [organization]
+			define admin: [user, team#member] or repo_admin from owner
+			define maintainer: [user, team#member] or admin
+			define writer: [user, team#member] or maintainer or repo_writer from owner
+			define triager: [user, team#member] or writer
+			define reader: [user, team#member, user:*] or triager or repo_reader from owner
+			define can_read: reader`
+
+func TestIndex(t *testing.T) {
+	model := testutils.MustTransformDSLToProtoWithID(githubModel)
+	typesys, err := New(model)
+	require.NoError(t, err)
+
+	idx, err := BuildIndex(model)
+	require.NoError(t, err)
+	require.Equal(t, model.GetId(), idx.AuthorizationModelID())
+
+	data, err := idx.Marshal()
+	require.NoError(t, err)
+
+	loaded, err := UnmarshalIndex(data)
+	require.NoError(t, err)
+	require.Equal(t, idx, loaded)
+
+	// the serialized form is deterministic
+	reserialized, err := loaded.Marshal()
+	require.NoError(t, err)
+	require.Equal(t, data, reserialized)
+
+	t.Run("reachability_matches_the_typesystem", func(t *testing.T) {
+		users := []string{
+			"user:anne", "user:*", "team:core", "team:core#member", "organization:openfga",
+			"organization:openfga#member", "organization:openfga#owner", "repo:openfga/openfga#admin",
+		}
+		for objectType, relations := range typesys.GetAllRelations() {
+			for relation := range relations {
+				for _, user := range users {
+					expected, err := typesys.PathExists(user, relation, objectType)
+					require.NoError(t, err)
+
+					exists, ok := loaded.PathExists(user, relation, objectType)
+					require.True(t, ok, "%s %s#%s", user, objectType, relation)
+					require.Equal(t, expected, exists, "%s %s#%s", user, objectType, relation)
+				}
+			}
+		}
+
+		// statically impossible pairs
+		exists, ok := loaded.PathExists("organization:openfga", "reader", "repo")
+		require.True(t, ok)
+		require.False(t, exists)
+
+		// the types that the model doesn't define
+		_, ok = loaded.PathExists("employee:anne", "reader", "repo")
+		require.False(t, ok)
+	})
+
+	t.Run("errors", func(t *testing.T) {
+		model := testutils.MustTransformDSLToProtoWithID(githubModel)
+		model.Id = ""
+		_, err := BuildIndex(model)
+		require.Error(t, err)
+
+		_, err = UnmarshalIndex([]byte(`{"version":"0","authorization_model_id":"01JCFZH1V1EBH3W1JX0C7Z8E7P"}`))
+		require.EqualError(t, err, "unsupported index version '0'")
+
+		_, err = UnmarshalIndex([]byte(`{"version":"1"}`))
+		require.Error(t, err)
+	})
+}
@@ -0,0 +1,42 @@
+package typesystem
+
+import (
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+
+	openfgav1 "github.com/openfga/api/proto/openfga/v1"
+
+	"github.com/openfga/openfga/internal/build"
+	"github.com/openfga/openfga/internal/utils"
+)
+
+// modelSizeBuckets are the buckets of the number of relations of a model that label the build duration.
+var modelSizeBuckets = []uint{10, 50, 100, 500, 1000}
+
+var buildDurationHistogram = promauto.NewHistogramVec(prometheus.HistogramOpts{
+	Namespace:                       build.ProjectName,
+	Name:                            "typesystem_build_duration_ms",
+	Help:                            "The time (in ms) it takes to build a typesystem from an authorization model, including its validation if requested, labeled by a bucket of the number of relations of the model.",
+	Buckets:                         []float64{0.1, 0.5, 1, 5, 10, 25, 50, 100, 250, 500, 1000},
+	NativeHistogramBucketFactor:     1.1,
+	NativeHistogramMaxBucketNumber:  100,
+	NativeHistogramMinResetDuration: time.Hour,
+}, []string{"model_size"})
+
+// modelSizeBucket returns the label of the bucket of the number of relations of the model.
+func modelSizeBucket(model *openfgav1.AuthorizationModel) string {
+	var numRelations uint
+	for _, td := range model.GetTypeDefinitions() {
+		numRelations += uint(len(td.GetRelations()))
+	}
+	return utils.Bucketize(numRelations, modelSizeBuckets)
+}
+
+// observeBuildDuration records the time spent building a typesystem for the model since start.
+func observeBuildDuration(model *openfgav1.AuthorizationModel, start time.Time) {
+	buildDurationHistogram.
+		WithLabelValues(modelSizeBucket(model)).
+		Observe(float64(time.Since(start).Microseconds()) / 1000)
+}
@@ -0,0 +1,99 @@
+package typesystem
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/require"
+
+	openfgav1 "github.com/openfga/api/proto/openfga/v1"
+
+	"github.com/openfga/openfga/pkg/testutils"
+)
+
+// buildDurationSampleCount returns the number of build durations recorded for the model size bucket.
+func buildDurationSampleCount(t *testing.T, modelSize string) uint64 {
+	t.Helper()
+
+	families, err := prometheus.DefaultGatherer.Gather()
+	require.NoError(t, err)
+
+	for _, family := range families {
+		if family.GetName() != "openfga_typesystem_build_duration_ms" {
+			continue
+		}
+		for _, metric := range family.GetMetric() {
+			for _, label := range metric.GetLabel() {
+				if label.GetName() == "model_size" && label.GetValue() == modelSize {
+					return metric.GetHistogram().GetSampleCount()
+				}
+			}
+		}
+	}
+
+	return 0
+}
+
+func TestBuildDurationHistogram(t *testing.T) {
+	model := testutils.MustTransformDSLToProtoWithID(`
+		model
+			schema 1.1
+		type user
+		type document
+			relations
+				define owner: [user]
+				define viewer: [user] or owner`)
+
+	bucket := modelSizeBucket(model)
+	require.Equal(t, "10", bucket)
+
+	t.Run("new_records_a_sample", func(t *testing.T) {
+		before := buildDurationSampleCount(t, bucket)
+
+		_, err := New(model)
+		require.NoError(t, err)
+
+		require.GreaterOrEqual(t, buildDurationSampleCount(t, bucket), before+1)
+	})
+
+	t.Run("new_and_validate_records_a_sample", func(t *testing.T) {
+		before := buildDurationSampleCount(t, bucket)
+
+		_, err := NewAndValidate(context.Background(), model)
+		require.NoError(t, err)
+
+		require.GreaterOrEqual(t, buildDurationSampleCount(t, bucket), before+1)
+	})
+}
+
+func TestModelSizeBucket(t *testing.T) {
+	relations := func(n int) map[string]*openfgav1.Userset {
+		rels := make(map[string]*openfgav1.Userset, n)
+		for i := 0; i < n; i++ {
+			rels[fmt.Sprintf("relation%d", i)] = &openfgav1.Userset{}
+		}
+		return rels
+	}
+
+	tests := []struct {
+		numRelations int
+		expected     string
+	}{
+		{0, "10"},
+		{10, "10"},
+		{11, "50"},
+		{500, "500"},
+		{1001, "+Inf"},
+	}
+	for _, test := range tests {
+		model := &openfgav1.AuthorizationModel{
+			TypeDefinitions: []*openfgav1.TypeDefinition{
+				{Type: "user"},
+				{Type: "document", Relations: relations(test.numRelations)},
+			},
+		}
+		require.Equal(t, test.expected, modelSizeBucket(model), "%d relations", test.numRelations)
+	}
+}
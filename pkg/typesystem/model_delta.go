@@ -0,0 +1,96 @@
+package typesystem
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+
+	"google.golang.org/protobuf/proto"
+
+	openfgav1 "github.com/openfga/api/proto/openfga/v1"
+)
+
+// ModelDelta is a set of changes that is layered over a base authorization model, e.g. to try out
+// changes to a published model without writing a new one.
+type ModelDelta struct {
+	// TypeDefinitions are merged into the base model. The relations (and their metadata) of a type
+	// that is defined in the base model are added to the base type definition, replacing the relations
+	// of the same name. Types that are not defined in the base model are added to it.
+	TypeDefinitions []*openfgav1.TypeDefinition
+	// Conditions are added to the base model, replacing the conditions of the same name.
+	Conditions map[string]*openfgav1.Condition
+}
+
+// IsEmpty returns true if the delta doesn't change the model.
+func (d *ModelDelta) IsEmpty() bool {
+	return d == nil || (len(d.TypeDefinitions) == 0 && len(d.Conditions) == 0)
+}
+
+// Fingerprint returns a hash that identifies the changes of the delta.
+func (d *ModelDelta) Fingerprint() (string, error) {
+	if d.IsEmpty() {
+		return "", nil
+	}
+
+	b, err := proto.MarshalOptions{Deterministic: true}.Marshal(&openfgav1.AuthorizationModel{
+		TypeDefinitions: d.TypeDefinitions,
+		Conditions:      d.Conditions,
+	})
+	if err != nil {
+		return "", err
+	}
+
+	sum := sha256.Sum256(b)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// ApplyModelDelta returns a new model that is the result of merging the delta into the base model.
+// The base model is not modified. The merged model keeps the ID and schema version of the base model,
+// and it must be validated before use.
+func ApplyModelDelta(base *openfgav1.AuthorizationModel, delta *ModelDelta) *openfgav1.AuthorizationModel {
+	merged := proto.Clone(base).(*openfgav1.AuthorizationModel)
+	if delta.IsEmpty() {
+		return merged
+	}
+
+	typeDefinitions := make(map[string]*openfgav1.TypeDefinition, len(merged.GetTypeDefinitions()))
+	for _, td := range merged.GetTypeDefinitions() {
+		typeDefinitions[td.GetType()] = td
+	}
+
+	for _, deltaTD := range delta.TypeDefinitions {
+		deltaTD = proto.Clone(deltaTD).(*openfgav1.TypeDefinition)
+
+		td, ok := typeDefinitions[deltaTD.GetType()]
+		if !ok {
+			typeDefinitions[deltaTD.GetType()] = deltaTD
+			merged.TypeDefinitions = append(merged.TypeDefinitions, deltaTD)
+			continue
+		}
+
+		for name, relation := range deltaTD.GetRelations() {
+			if td.Relations == nil {
+				td.Relations = make(map[string]*openfgav1.Userset, len(deltaTD.GetRelations()))
+			}
+			td.Relations[name] = relation
+		}
+
+		for name, metadata := range deltaTD.GetMetadata().GetRelations() {
+			if td.Metadata == nil {
+				td.Metadata = &openfgav1.Metadata{}
+			}
+			if td.Metadata.Relations == nil {
+				td.Metadata.Relations = make(map[string]*openfgav1.RelationMetadata, len(deltaTD.GetMetadata().GetRelations()))
+			}
+			td.Metadata.Relations[name] = metadata
+		}
+	}
+
+	for name, condition := range delta.Conditions {
+		if merged.Conditions == nil {
+			merged.Conditions = make(map[string]*openfgav1.Condition, len(delta.Conditions))
+		}
+		merged.Conditions[name] = proto.Clone(condition).(*openfgav1.Condition)
+	}
+
+	return merged
+}
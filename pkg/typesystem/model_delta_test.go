@@ -0,0 +1,82 @@
+package typesystem
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/proto"
+
+	openfgav1 "github.com/openfga/api/proto/openfga/v1"
+
+	"github.com/openfga/openfga/pkg/testutils"
+)
+
+func TestApplyModelDelta(t *testing.T) {
+	base := testutils.MustTransformDSLToProtoWithID(`
+		model
+			schema 1.1
+		type user
+		type doc
+			relations
+				define editor: [user]
+				define viewer: [user]`)
+	original := proto.Clone(base).(*openfgav1.AuthorizationModel)
+
+	deltaModel := testutils.MustTransformDSLToProtoWithID(`
+		model
+			schema 1.1
+		type user
+		type folder
+			relations
+				define viewer: [user]
+		type doc
+			relations
+				define viewer: [user] or editor
+				define owner: [user]`)
+
+	delta := &ModelDelta{}
+	for _, td := range deltaModel.GetTypeDefinitions() {
+		if td.GetType() != "user" {
+			delta.TypeDefinitions = append(delta.TypeDefinitions, td)
+		}
+	}
+
+	merged := ApplyModelDelta(base, delta)
+	require.Equal(t, base.GetId(), merged.GetId())
+	require.True(t, proto.Equal(original, base), "the base model must not be modified")
+
+	typesys, err := NewAndValidate(context.Background(), merged)
+	require.NoError(t, err)
+
+	_, err = typesys.GetRelation("folder", "viewer")
+	require.NoError(t, err)
+
+	_, err = typesys.GetRelation("doc", "owner")
+	require.NoError(t, err)
+
+	_, err = typesys.GetRelation("doc", "editor")
+	require.NoError(t, err, "relations not in the delta are kept")
+
+	viewer, err := typesys.GetRelation("doc", "viewer")
+	require.NoError(t, err)
+	require.NotNil(t, viewer.GetRewrite().GetUnion(), "relations in the delta replace the base relations")
+
+	t.Run("fingerprint", func(t *testing.T) {
+		fingerprint, err := delta.Fingerprint()
+		require.NoError(t, err)
+		require.NotEmpty(t, fingerprint)
+
+		again, err := delta.Fingerprint()
+		require.NoError(t, err)
+		require.Equal(t, fingerprint, again)
+
+		other, err := (&ModelDelta{TypeDefinitions: delta.TypeDefinitions[:1]}).Fingerprint()
+		require.NoError(t, err)
+		require.NotEqual(t, fingerprint, other)
+
+		empty, err := (&ModelDelta{}).Fingerprint()
+		require.NoError(t, err)
+		require.Empty(t, empty)
+	})
+}
@@ -0,0 +1,205 @@
+package typesystem
+
+import (
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+
+	"google.golang.org/protobuf/proto"
+
+	openfgav1 "github.com/openfga/api/proto/openfga/v1"
+
+	"github.com/openfga/openfga/pkg/tuple"
+)
+
+// ModelChangeKind is the kind of a change between two authorization models.
+type ModelChangeKind string
+
+const (
+	TypeAdded                       ModelChangeKind = "type_added"
+	TypeRemoved                     ModelChangeKind = "type_removed"
+	RelationAdded                   ModelChangeKind = "relation_added"
+	RelationRemoved                 ModelChangeKind = "relation_removed"
+	RewriteChanged                  ModelChangeKind = "rewrite_changed"
+	DirectlyRelatedUserTypesChanged ModelChangeKind = "directly_related_user_types_changed"
+)
+
+// ModelChange is a change of a type, or of a relation of a type, between two authorization models.
+type ModelChange struct {
+	Kind     ModelChangeKind
+	Type     string
+	Relation string // empty for the changes of a type.
+
+	// Added and Removed are the directly related user types that were added to and removed from the
+	// relation, e.g. "user", "user:*" or "group#member", for a DirectlyRelatedUserTypesChanged change.
+	Added   []string
+	Removed []string
+
+	// Breaking is true if the change can invalidate the tuples or the requests that were valid for the
+	// old model, e.g. removing a relation that existing tuples reference.
+	Breaking bool
+}
+
+func (c ModelChange) String() string {
+	s := fmt.Sprintf("%s %s", c.Kind, c.Type)
+	if c.Relation != "" {
+		s += "#" + c.Relation
+	}
+	if len(c.Added) > 0 {
+		s += fmt.Sprintf(" added %v", c.Added)
+	}
+	if len(c.Removed) > 0 {
+		s += fmt.Sprintf(" removed %v", c.Removed)
+	}
+	return s
+}
+
+// ModelDiff is the list of changes between two authorization models, ordered by type and relation.
+type ModelDiff struct {
+	Changes []ModelChange
+}
+
+// IsBreaking returns true if any of the changes is breaking.
+func (d ModelDiff) IsBreaking() bool {
+	return slices.ContainsFunc(d.Changes, func(c ModelChange) bool { return c.Breaking })
+}
+
+// BreakingChanges returns the changes that are breaking.
+func (d ModelDiff) BreakingChanges() []ModelChange {
+	var breaking []ModelChange
+	for _, c := range d.Changes {
+		if c.Breaking {
+			breaking = append(breaking, c)
+		}
+	}
+	return breaking
+}
+
+// Diff returns the changes of the types and relations from the old to the new authorization model. A
+// change is breaking if the tuples or requests that were valid for the old model may not be valid for the
+// new one:
+//   - removing a type or a relation is breaking, since tuples and requests reference them;
+//   - removing a directly related user type is breaking, since tuples of that user type are rejected;
+//   - adding types, relations and directly related user types, or changing the rewrite of a relation, is
+//     not, although the latter changes the outcome of the queries.
+func Diff(oldModel, newModel *openfgav1.AuthorizationModel) (ModelDiff, error) {
+	oldTypesys, err := New(oldModel)
+	if err != nil {
+		return ModelDiff{}, fmt.Errorf("old model: %w", err)
+	}
+	newTypesys, err := New(newModel)
+	if err != nil {
+		return ModelDiff{}, fmt.Errorf("new model: %w", err)
+	}
+
+	oldRelations := oldTypesys.GetAllRelations()
+	newRelations := newTypesys.GetAllRelations()
+
+	var diff ModelDiff
+	for _, objectType := range sortedKeys(oldRelations, newRelations) {
+		oldTypeRelations, inOld := oldRelations[objectType]
+		newTypeRelations, inNew := newRelations[objectType]
+		switch {
+		case !inNew:
+			diff.Changes = append(diff.Changes, ModelChange{Kind: TypeRemoved, Type: objectType, Breaking: true})
+			continue
+		case !inOld:
+			diff.Changes = append(diff.Changes, ModelChange{Kind: TypeAdded, Type: objectType})
+			continue
+		}
+
+		for _, relation := range sortedKeys(oldTypeRelations, newTypeRelations) {
+			oldRelation, inOld := oldTypeRelations[relation]
+			newRelation, inNew := newTypeRelations[relation]
+			switch {
+			case !inNew:
+				diff.Changes = append(diff.Changes, ModelChange{Kind: RelationRemoved, Type: objectType, Relation: relation, Breaking: true})
+				continue
+			case !inOld:
+				diff.Changes = append(diff.Changes, ModelChange{Kind: RelationAdded, Type: objectType, Relation: relation})
+				continue
+			}
+
+			if !proto.Equal(oldRelation.GetRewrite(), newRelation.GetRewrite()) {
+				diff.Changes = append(diff.Changes, ModelChange{Kind: RewriteChanged, Type: objectType, Relation: relation})
+			}
+
+			added, removed := diffDirectlyRelatedUserTypes(
+				oldRelation.GetTypeInfo().GetDirectlyRelatedUserTypes(),
+				newRelation.GetTypeInfo().GetDirectlyRelatedUserTypes(),
+			)
+			if len(added) > 0 || len(removed) > 0 {
+				diff.Changes = append(diff.Changes, ModelChange{
+					Kind:     DirectlyRelatedUserTypesChanged,
+					Type:     objectType,
+					Relation: relation,
+					Added:    added,
+					Removed:  removed,
+					Breaking: len(removed) > 0,
+				})
+			}
+		}
+	}
+
+	return diff, nil
+}
+
+// diffDirectlyRelatedUserTypes returns the user types that were added and removed, sorted.
+func diffDirectlyRelatedUserTypes(oldRefs, newRefs []*openfgav1.RelationReference) ([]string, []string) {
+	oldTypes := make(map[string]struct{}, len(oldRefs))
+	for _, ref := range oldRefs {
+		oldTypes[relationReferenceKey(ref)] = struct{}{}
+	}
+	newTypes := make(map[string]struct{}, len(newRefs))
+	for _, ref := range newRefs {
+		newTypes[relationReferenceKey(ref)] = struct{}{}
+	}
+
+	var added, removed []string
+	for key := range newTypes {
+		if _, ok := oldTypes[key]; !ok {
+			added = append(added, key)
+		}
+	}
+	for key := range oldTypes {
+		if _, ok := newTypes[key]; !ok {
+			removed = append(removed, key)
+		}
+	}
+	sort.Strings(added)
+	sort.Strings(removed)
+	return added, removed
+}
+
+// relationReferenceKey returns the reference as written in the DSL, e.g. "user", "user:*",
+// "group#member" or "user with condition".
+func relationReferenceKey(ref *openfgav1.RelationReference) string {
+	var b strings.Builder
+	switch ref.GetRelationOrWildcard().(type) {
+	case *openfgav1.RelationReference_Relation:
+		b.WriteString(tuple.ToObjectRelationString(ref.GetType(), ref.GetRelation()))
+	case *openfgav1.RelationReference_Wildcard:
+		b.WriteString(tuple.TypedPublicWildcard(ref.GetType()))
+	default:
+		b.WriteString(ref.GetType())
+	}
+	if ref.GetCondition() != "" {
+		b.WriteString(" with " + ref.GetCondition())
+	}
+	return b.String()
+}
+
+func sortedKeys[V any](a, b map[string]V) []string {
+	keys := make([]string, 0, len(a)+len(b))
+	for k := range a {
+		keys = append(keys, k)
+	}
+	for k := range b {
+		if _, ok := a[k]; !ok {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
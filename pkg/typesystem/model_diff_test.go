@@ -0,0 +1,143 @@
+package typesystem
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/openfga/openfga/pkg/testutils"
+)
+
+func TestDiff(t *testing.T) {
+	github := testutils.MustTransformDSLToProtoWithID(`
+		model
+			schema 1.1
+		type user
+		type team
+			relations
+				define member: [user, team#member]
+		type organization
+			relations
+				define owner: [user]
+				define member: [user] or owner
+				define repo_admin: [user, organization#member]
+				define repo_reader: [user, organization#member]
+				define repo_writer: [user, organization#member]
+		type repo
+			relations
+				define owner: [organization]
+				define admin: [user, team#member] or repo_admin from owner
+				define maintainer: [user, team#member] or admin
+				define writer: [user, team#member] or maintainer or repo_writer from owner
+				define triager: [user, team#member] or writer
+				define reader: [user, team#member] or triager or repo_reader from owner`)
+
+	t.Run("same_model", func(t *testing.T) {
+		diff, err := Diff(github, github)
+		require.NoError(t, err)
+		require.Empty(t, diff.Changes)
+		require.False(t, diff.IsBreaking())
+	})
+
+	t.Run("non_breaking_changes", func(t *testing.T) {
+		modified := testutils.MustTransformDSLToProtoWithID(`
+			model
+				schema 1.1
+			type user
+			type app
+			type team
+				relations
+					define member: [user, user:*, team#member]
+			type organization
+				relations
+					define owner: [user]
+					define member: [user] or owner
+					define repo_admin: [user, organization#member]
+					define repo_reader: [user, organization#member]
+					define repo_writer: [user, organization#member]
+			type repo
+				relations
+					define owner: [organization]
+					define admin: [user, team#member] or repo_admin from owner
+					define maintainer: [user, team#member] or admin
+					define writer: [user, team#member] or maintainer or repo_writer from owner
+					define triager: [user, team#member] or writer
+					define reader: [user, team#member] or writer or repo_reader from owner
+					define security_manager: [user, team#member]`)
+
+		diff, err := Diff(github, modified)
+		require.NoError(t, err)
+		require.Equal(t, []ModelChange{
+			{Kind: TypeAdded, Type: "app"},
+			{Kind: RewriteChanged, Type: "repo", Relation: "reader"},
+			{Kind: RelationAdded, Type: "repo", Relation: "security_manager"},
+			{Kind: DirectlyRelatedUserTypesChanged, Type: "team", Relation: "member", Added: []string{"user:*"}},
+		}, diff.Changes)
+		require.False(t, diff.IsBreaking())
+		require.Empty(t, diff.BreakingChanges())
+	})
+
+	t.Run("breaking_changes", func(t *testing.T) {
+		modified := testutils.MustTransformDSLToProtoWithID(`
+			model
+				schema 1.1
+			type user
+			type organization
+				relations
+					define owner: [user]
+					define member: [user] or owner
+					define repo_admin: [user, organization#member]
+					define repo_reader: [user, organization#member]
+					define repo_writer: [user, organization#member]
+			type repo
+				relations
+					define owner: [organization]
+					define admin: [user] or repo_admin from owner
+					define maintainer: [user] or admin
+					define writer: [user] or maintainer or repo_writer from owner
+					define reader: [user] or writer or repo_reader from owner`)
+
+		diff, err := Diff(github, modified)
+		require.NoError(t, err)
+		require.True(t, diff.IsBreaking())
+		require.Equal(t, []ModelChange{
+			{Kind: DirectlyRelatedUserTypesChanged, Type: "repo", Relation: "admin", Removed: []string{"team#member"}, Breaking: true},
+			{Kind: DirectlyRelatedUserTypesChanged, Type: "repo", Relation: "maintainer", Removed: []string{"team#member"}, Breaking: true},
+			{Kind: RewriteChanged, Type: "repo", Relation: "reader"},
+			{Kind: DirectlyRelatedUserTypesChanged, Type: "repo", Relation: "reader", Removed: []string{"team#member"}, Breaking: true},
+			{Kind: RelationRemoved, Type: "repo", Relation: "triager", Breaking: true},
+			{Kind: DirectlyRelatedUserTypesChanged, Type: "repo", Relation: "writer", Removed: []string{"team#member"}, Breaking: true},
+			{Kind: TypeRemoved, Type: "team", Breaking: true},
+		}, diff.Changes)
+		require.Len(t, diff.BreakingChanges(), 6)
+	})
+
+	t.Run("conditions_of_directly_related_user_types", func(t *testing.T) {
+		old := testutils.MustTransformDSLToProtoWithID(`
+			model
+				schema 1.1
+			type user
+			type document
+				relations
+					define viewer: [user, user with in_office]
+			condition in_office(ip: ipaddress) {
+				ip.in_cidr("10.0.0.0/8")
+			}`)
+		modified := testutils.MustTransformDSLToProtoWithID(`
+			model
+				schema 1.1
+			type user
+			type document
+				relations
+					define viewer: [user]
+			condition in_office(ip: ipaddress) {
+				ip.in_cidr("10.0.0.0/8")
+			}`)
+
+		diff, err := Diff(old, modified)
+		require.NoError(t, err)
+		require.Equal(t, []ModelChange{
+			{Kind: DirectlyRelatedUserTypesChanged, Type: "document", Relation: "viewer", Removed: []string{"user with in_office"}, Breaking: true},
+		}, diff.Changes)
+	})
+}
@@ -0,0 +1,179 @@
+package typesystem
+
+import (
+	"cmp"
+	"slices"
+
+	openfgav1 "github.com/openfga/api/proto/openfga/v1"
+)
+
+// RelationGraphEdgeType describes how a relation references another relation.
+type RelationGraphEdgeType string
+
+const (
+	// RelationGraphEdgeDirect is a reference through a directly related userset type restriction,
+	// e.g. `define viewer: [group#member]`.
+	RelationGraphEdgeDirect RelationGraphEdgeType = "direct"
+	// RelationGraphEdgeComputed is a reference through a computed userset, e.g. `define viewer: editor`.
+	RelationGraphEdgeComputed RelationGraphEdgeType = "computed"
+	// RelationGraphEdgeTupleToUserset is a reference through a tuple to userset rewrite,
+	// e.g. `define viewer: viewer from parent`.
+	RelationGraphEdgeTupleToUserset RelationGraphEdgeType = "tuple_to_userset"
+)
+
+// RelationGraphOperator is the set operation of the rewrite that contains a reference.
+type RelationGraphOperator string
+
+const (
+	RelationGraphOperatorUnion        RelationGraphOperator = "union"
+	RelationGraphOperatorIntersection RelationGraphOperator = "intersection"
+	RelationGraphOperatorDifference   RelationGraphOperator = "difference"
+)
+
+// RelationGraphNode is a relation defined on a type.
+type RelationGraphNode struct {
+	Type     string `json:"type"`
+	Relation string `json:"relation"`
+}
+
+func (n RelationGraphNode) String() string {
+	return n.Type + "#" + n.Relation
+}
+
+// RelationGraphEdge is a reference from the rewrite of one relation to another relation.
+type RelationGraphEdge struct {
+	From RelationGraphNode     `json:"from"`
+	To   RelationGraphNode     `json:"to"`
+	Type RelationGraphEdgeType `json:"type"`
+	// Operator is the innermost set operation containing the reference. It is empty when the
+	// reference is the whole rewrite of the relation.
+	Operator RelationGraphOperator `json:"operator,omitempty"`
+	// Subtracted is true when the reference is part of the subtracted operand of a difference.
+	Subtracted bool `json:"subtracted,omitempty"`
+	// Tupleset is the relation used to find the related objects of a tuple to userset reference.
+	Tupleset string `json:"tupleset,omitempty"`
+}
+
+// RelationGraph is the static dependency graph between the relations of a model. Nodes and edges
+// are sorted so that the graph of a given model always serializes identically.
+type RelationGraph struct {
+	Nodes []RelationGraphNode `json:"nodes"`
+	Edges []RelationGraphEdge `json:"edges"`
+}
+
+// GetRelationGraph returns the dependency graph between the relations of the model. There is a node
+// for every relation of every type, and an edge for every relation referenced by its rewrite.
+func (t *TypeSystem) GetRelationGraph() *RelationGraph {
+	g := &RelationGraph{
+		Nodes: []RelationGraphNode{},
+		Edges: []RelationGraphEdge{},
+	}
+
+	seen := make(map[RelationGraphEdge]struct{})
+	addEdge := func(e RelationGraphEdge) {
+		if _, ok := seen[e]; ok {
+			return
+		}
+		seen[e] = struct{}{}
+		g.Edges = append(g.Edges, e)
+	}
+
+	for objectType, relations := range t.relations {
+		for relationName, relation := range relations {
+			from := RelationGraphNode{Type: objectType, Relation: relationName}
+			g.Nodes = append(g.Nodes, from)
+			t.addRelationGraphEdges(from, relation.GetRewrite(), "", false, addEdge)
+		}
+	}
+
+	slices.SortFunc(g.Nodes, compareRelationGraphNodes)
+	slices.SortFunc(g.Edges, func(a, b RelationGraphEdge) int {
+		return cmp.Or(
+			compareRelationGraphNodes(a.From, b.From),
+			compareRelationGraphNodes(a.To, b.To),
+			cmp.Compare(a.Type, b.Type),
+			cmp.Compare(a.Tupleset, b.Tupleset),
+			cmp.Compare(a.Operator, b.Operator),
+			compareBool(a.Subtracted, b.Subtracted),
+		)
+	})
+
+	return g
+}
+
+func (t *TypeSystem) addRelationGraphEdges(
+	from RelationGraphNode,
+	rewrite *openfgav1.Userset,
+	operator RelationGraphOperator,
+	subtracted bool,
+	addEdge func(RelationGraphEdge),
+) {
+	switch rw := rewrite.GetUserset().(type) {
+	case *openfgav1.Userset_This:
+		directlyRelatedTypes, _ := t.GetDirectlyRelatedUserTypes(from.Type, from.Relation)
+		for _, ref := range directlyRelatedTypes {
+			if ref.GetRelation() == "" {
+				continue
+			}
+			addEdge(RelationGraphEdge{
+				From:       from,
+				To:         RelationGraphNode{Type: ref.GetType(), Relation: ref.GetRelation()},
+				Type:       RelationGraphEdgeDirect,
+				Operator:   operator,
+				Subtracted: subtracted,
+			})
+		}
+	case *openfgav1.Userset_ComputedUserset:
+		addEdge(RelationGraphEdge{
+			From:       from,
+			To:         RelationGraphNode{Type: from.Type, Relation: rw.ComputedUserset.GetRelation()},
+			Type:       RelationGraphEdgeComputed,
+			Operator:   operator,
+			Subtracted: subtracted,
+		})
+	case *openfgav1.Userset_TupleToUserset:
+		tupleset := rw.TupleToUserset.GetTupleset().GetRelation()
+		computedRelation := rw.TupleToUserset.GetComputedUserset().GetRelation()
+
+		tuplesetTypes, _ := t.GetDirectlyRelatedUserTypes(from.Type, tupleset)
+		for _, ref := range tuplesetTypes {
+			if _, err := t.GetRelation(ref.GetType(), computedRelation); err != nil {
+				continue
+			}
+			addEdge(RelationGraphEdge{
+				From:       from,
+				To:         RelationGraphNode{Type: ref.GetType(), Relation: computedRelation},
+				Type:       RelationGraphEdgeTupleToUserset,
+				Operator:   operator,
+				Subtracted: subtracted,
+				Tupleset:   tupleset,
+			})
+		}
+	case *openfgav1.Userset_Union:
+		for _, child := range rw.Union.GetChild() {
+			t.addRelationGraphEdges(from, child, RelationGraphOperatorUnion, subtracted, addEdge)
+		}
+	case *openfgav1.Userset_Intersection:
+		for _, child := range rw.Intersection.GetChild() {
+			t.addRelationGraphEdges(from, child, RelationGraphOperatorIntersection, subtracted, addEdge)
+		}
+	case *openfgav1.Userset_Difference:
+		t.addRelationGraphEdges(from, rw.Difference.GetBase(), RelationGraphOperatorDifference, subtracted, addEdge)
+		t.addRelationGraphEdges(from, rw.Difference.GetSubtract(), RelationGraphOperatorDifference, true, addEdge)
+	}
+}
+
+func compareRelationGraphNodes(a, b RelationGraphNode) int {
+	return cmp.Or(cmp.Compare(a.Type, b.Type), cmp.Compare(a.Relation, b.Relation))
+}
+
+func compareBool(a, b bool) int {
+	switch {
+	case a == b:
+		return 0
+	case !a:
+		return -1
+	default:
+		return 1
+	}
+}
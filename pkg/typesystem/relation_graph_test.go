@@ -0,0 +1,85 @@
+package typesystem
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/openfga/openfga/pkg/testutils"
+)
+
+func TestGetRelationGraph(t *testing.T) {
+	// the model of the GitHub sample store
+	model := testutils.MustTransformDSLToProtoWithID(`
+		model
+			schema 1.1
+		type user
+		type team
+			relations
+				define member: [user, team#member]
+		type organization
+			relations
+				define owner: [user]
+				define member: [user] or owner
+				define repo_admin: [user, organization#member]
+		type repo
+			relations
+				define owner: [organization]
+				define admin: [user, team#member] or repo_admin from owner
+				define maintainer: [user, team#member] or admin
+				define writer: [user, team#member] or maintainer
+				define reader: [user, team#member] or writer
+				define banned: [user]
+				define can_view: reader but not banned`)
+
+	typesys, err := New(model)
+	require.NoError(t, err)
+
+	graph := typesys.GetRelationGraph()
+
+	node := func(objectType, relation string) RelationGraphNode {
+		return RelationGraphNode{Type: objectType, Relation: relation}
+	}
+
+	require.Equal(t, []RelationGraphNode{
+		node("organization", "member"),
+		node("organization", "owner"),
+		node("organization", "repo_admin"),
+		node("repo", "admin"),
+		node("repo", "banned"),
+		node("repo", "can_view"),
+		node("repo", "maintainer"),
+		node("repo", "owner"),
+		node("repo", "reader"),
+		node("repo", "writer"),
+		node("team", "member"),
+	}, graph.Nodes)
+
+	require.Equal(t, []RelationGraphEdge{
+		{From: node("organization", "member"), To: node("organization", "owner"), Type: RelationGraphEdgeComputed, Operator: RelationGraphOperatorUnion},
+		{From: node("organization", "repo_admin"), To: node("organization", "member"), Type: RelationGraphEdgeDirect},
+		{From: node("repo", "admin"), To: node("organization", "repo_admin"), Type: RelationGraphEdgeTupleToUserset, Operator: RelationGraphOperatorUnion, Tupleset: "owner"},
+		{From: node("repo", "admin"), To: node("team", "member"), Type: RelationGraphEdgeDirect, Operator: RelationGraphOperatorUnion},
+		{From: node("repo", "can_view"), To: node("repo", "banned"), Type: RelationGraphEdgeComputed, Operator: RelationGraphOperatorDifference, Subtracted: true},
+		{From: node("repo", "can_view"), To: node("repo", "reader"), Type: RelationGraphEdgeComputed, Operator: RelationGraphOperatorDifference},
+		{From: node("repo", "maintainer"), To: node("repo", "admin"), Type: RelationGraphEdgeComputed, Operator: RelationGraphOperatorUnion},
+		{From: node("repo", "maintainer"), To: node("team", "member"), Type: RelationGraphEdgeDirect, Operator: RelationGraphOperatorUnion},
+		{From: node("repo", "reader"), To: node("repo", "writer"), Type: RelationGraphEdgeComputed, Operator: RelationGraphOperatorUnion},
+		{From: node("repo", "reader"), To: node("team", "member"), Type: RelationGraphEdgeDirect, Operator: RelationGraphOperatorUnion},
+		{From: node("repo", "writer"), To: node("repo", "maintainer"), Type: RelationGraphEdgeComputed, Operator: RelationGraphOperatorUnion},
+		{From: node("repo", "writer"), To: node("team", "member"), Type: RelationGraphEdgeDirect, Operator: RelationGraphOperatorUnion},
+		{From: node("team", "member"), To: node("team", "member"), Type: RelationGraphEdgeDirect},
+	}, graph.Edges)
+
+	t.Run("serialization_is_stable", func(t *testing.T) {
+		expected, err := json.Marshal(graph)
+		require.NoError(t, err)
+
+		for range 10 {
+			actual, err := json.Marshal(typesys.GetRelationGraph())
+			require.NoError(t, err)
+			require.Equal(t, expected, actual)
+		}
+	})
+}
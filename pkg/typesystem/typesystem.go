@@ -10,6 +10,7 @@ import (
 	"sort"
 	"strings"
 	"sync"
+	"time"
 
 	"github.com/emirpasic/gods/sets/hashset"
 	"go.opentelemetry.io/otel"
@@ -177,6 +178,8 @@ type TypeSystem struct {
 	ttuRelations map[string]map[string][]*openfgav1.TupleToUserset
 
 	computedRelations sync.Map
+	// [userType or userType#relation objectType#relation] => whether a path exists, see PathExists.
+	pathExists sync.Map
 
 	modelID                 string
 	schemaVersion           string
@@ -187,6 +190,19 @@ type TypeSystem struct {
 // New creates a *TypeSystem from an *openfgav1.AuthorizationModel.
 // It assumes that the input model is valid. If you need to run validations, use NewAndValidate.
 func New(model *openfgav1.AuthorizationModel) (*TypeSystem, error) {
+	start := time.Now()
+
+	t, err := newTypeSystem(model)
+	if err != nil {
+		return nil, err
+	}
+
+	observeBuildDuration(model, start)
+	return t, nil
+}
+
+// newTypeSystem creates a *TypeSystem from an *openfgav1.AuthorizationModel without recording its build duration.
+func newTypeSystem(model *openfgav1.AuthorizationModel) (*TypeSystem, error) {
 	tds := make(map[string]*openfgav1.TypeDefinition, len(model.GetTypeDefinitions()))
 	relations := make(map[string]map[string]*openfgav1.Relation, len(model.GetTypeDefinitions()))
 	ttuRelations := make(map[string]map[string][]*openfgav1.TupleToUserset, len(model.GetTypeDefinitions()))
@@ -693,17 +709,47 @@ func (t *TypeSystem) UsersetUseRecursiveResolver(objectType, relation, userType
 // - the `user` type is a subject e.g. `user`, and there is a path from `user` to `objectType#relation`, or there is a path from `user:*` to `objectType#relation`
 // or
 // - the `user` type is a userset e.g. `group#member`, and there is a path from `group#member` to `objectType#relation`.
+//
+// The answer only depends on the type of the user, so it is resolved from a cache for the subsequent calls with
+// a user of the same type.
 func (t *TypeSystem) PathExists(user, relation, objectType string) (bool, error) {
+	userType, userTypeRelation, isUserset := pathExistsSource(user)
+	toLabel := tuple.ToObjectRelationString(objectType, relation)
+
+	memoizeKey := pathExistsKey(userTypeRelation, toLabel)
+	if val, ok := t.pathExists.Load(memoizeKey); ok {
+		return val.(bool), nil
+	}
+
+	exists, err := t.pathExistsFrom(userType, userTypeRelation, isUserset, toLabel)
+	if err != nil {
+		return false, err
+	}
+	t.pathExists.Store(memoizeKey, exists)
+	return exists, nil
+}
+
+// pathExistsSource returns the type of the user, and its type, e.g. 'user', or its userset type, e.g.
+// 'group#member', from which the paths of PathExists start.
+func pathExistsSource(user string) (userType, userTypeRelation string, isUserset bool) {
 	userType, _, userRelation := tuple.ToUserParts(user)
-	isUserset := userRelation != ""
-	userTypeRelation := userType
-	if isUserset {
-		userTypeRelation = tuple.ToObjectRelationString(userType, userRelation)
+	if userRelation != "" {
+		return userType, tuple.ToObjectRelationString(userType, userRelation), true
 	}
+	return userType, userType, false
+}
+
+// pathExistsKey returns the key of the result of PathExists from the user type, or userset type, to the toLabel
+// relation. Type names can't have spaces, so the key is unambiguous.
+func pathExistsKey(userTypeRelation, toLabel string) string {
+	return userTypeRelation + " " + toLabel
+}
 
+// pathExistsFrom returns true if there is a path from the user type, or userset, to the toLabel relation, see
+// PathExists.
+func (t *TypeSystem) pathExistsFrom(userType, userTypeRelation string, isUserset bool, toLabel string) (bool, error) {
 	// first check
 	fromLabel := userTypeRelation
-	toLabel := tuple.ToObjectRelationString(objectType, relation)
 	normalPathExists, err := t.authorizationModelGraph.PathExists(fromLabel, toLabel)
 	if err != nil {
 		return false, err
@@ -1111,7 +1157,9 @@ func NewAndValidate(ctx context.Context, model *openfgav1.AuthorizationModel) (*
 	_, span := tracer.Start(ctx, "typesystem.NewAndValidate")
 	defer span.End()
 
-	t, err := New(model)
+	start := time.Now()
+
+	t, err := newTypeSystem(model)
 	if err != nil {
 		return nil, err
 	}
@@ -1163,6 +1211,7 @@ func NewAndValidate(ctx context.Context, model *openfgav1.AuthorizationModel) (*
 		return nil, err
 	}
 
+	observeBuildDuration(model, start)
 	return t, nil
 }
 
@@ -1201,16 +1250,16 @@ func (t *TypeSystem) validateRelation(typeName, relationName string, relationMap
 		}
 	}
 
-	hasCycle, err := t.HasCycle(typeName, relationName)
+	cycle, err := t.FindCycle(typeName, relationName)
 	if err != nil {
 		return err
 	}
 
-	if hasCycle {
+	if cycle != nil {
 		return &InvalidRelationError{
 			ObjectType: typeName,
 			Relation:   relationName,
-			Cause:      ErrCycle,
+			Cause:      &CycleError{cycle: cycle},
 		}
 	}
 
@@ -1428,33 +1477,32 @@ func RewriteContainsSelf(rewrite *openfgav1.Userset) bool {
 	return result != nil && result.(bool) // Type-cast matches the return from the WalkRelationshipRewriteHandler above.
 }
 
-func (t *TypeSystem) hasCycle(
+// findCycle returns the ordered `objectType#relation` nodes of the first cycle reached from the rewrite of the last
+// node of the path through computed relationships, or nil if there is none.
+func (t *TypeSystem) findCycle(
 	objectType, relationName string,
 	rewrite *openfgav1.Userset,
-	visited map[string]struct{},
-) (bool, error) {
-	visited[fmt.Sprintf("%s#%s", objectType, relationName)] = struct{}{}
-
-	visitedCopy := maps.Clone(visited)
-
+	path []string,
+) ([]string, error) {
 	var children []*openfgav1.Userset
 
 	switch rw := rewrite.GetUserset().(type) {
 	case *openfgav1.Userset_This, *openfgav1.Userset_TupleToUserset:
-		return false, nil
+		return nil, nil
 	case *openfgav1.Userset_ComputedUserset:
 		rewrittenRelation := rw.ComputedUserset.GetRelation()
+		node := tuple.ToObjectRelationString(objectType, rewrittenRelation)
 
-		if _, ok := visited[fmt.Sprintf("%s#%s", objectType, rewrittenRelation)]; ok {
-			return true, nil
+		if i := slices.Index(path, node); i >= 0 {
+			return slices.Clone(path[i:]), nil
 		}
 
 		rewrittenRewrite, err := t.GetRelation(objectType, rewrittenRelation)
 		if err != nil {
-			return false, err
+			return nil, err
 		}
 
-		return t.hasCycle(objectType, rewrittenRelation, rewrittenRewrite.GetRewrite(), visitedCopy)
+		return t.findCycle(objectType, rewrittenRelation, rewrittenRewrite.GetRewrite(), append(slices.Clip(path), node))
 	case *openfgav1.Userset_Union:
 		children = append(children, rw.Union.GetChild()...)
 	case *openfgav1.Userset_Intersection:
@@ -1464,30 +1512,41 @@ func (t *TypeSystem) hasCycle(
 	}
 
 	for _, child := range children {
-		hasCycle, err := t.hasCycle(objectType, relationName, child, visitedCopy)
+		cycle, err := t.findCycle(objectType, relationName, child, path)
 		if err != nil {
-			return false, err
+			return nil, err
 		}
 
-		if hasCycle {
-			return true, nil
+		if cycle != nil {
+			return cycle, nil
 		}
 	}
 
-	return false, nil
+	return nil, nil
+}
+
+// FindCycle runs a cycle detection test on the provided `objectType#relation` and returns the ordered
+// `objectType#relation` nodes of the first cycle reached from it through computed relationships, or nil if
+// there is none. A relation that rewrites to itself yields a cycle of a single node.
+func (t *TypeSystem) FindCycle(objectType, relationName string) ([]string, error) {
+	relation, err := t.GetRelation(objectType, relationName)
+	if err != nil {
+		return nil, err
+	}
+
+	path := []string{tuple.ToObjectRelationString(objectType, relationName)}
+	return t.findCycle(objectType, relationName, relation.GetRewrite(), path)
 }
 
 // HasCycle runs a cycle detection test on the provided `objectType#relation` to see if the relation
 // defines a rewrite rule that is self-referencing in any way (through computed relationships).
 func (t *TypeSystem) HasCycle(objectType, relationName string) (bool, error) {
-	visited := map[string]struct{}{}
-
-	relation, err := t.GetRelation(objectType, relationName)
+	cycle, err := t.FindCycle(objectType, relationName)
 	if err != nil {
 		return false, err
 	}
 
-	return t.hasCycle(objectType, relationName, relation.GetRewrite(), visited)
+	return cycle != nil, nil
 }
 
 // IsTuplesetRelation returns a boolean indicating if the provided relation is defined under a
@@ -1662,3 +1721,26 @@ func WalkUsersetRewrite(rewrite *openfgav1.Userset, handler WalkUsersetRewriteHa
 
 	return nil, nil
 }
+
+// RewriteBranchCount returns the number of branches of the userset rewrite, which is the number of
+// direct, computed userset and tuple to userset operands within the rewrite tree.
+func RewriteBranchCount(rewrite *openfgav1.Userset) int {
+	var children []*openfgav1.Userset
+
+	switch t := rewrite.GetUserset().(type) {
+	case *openfgav1.Userset_Union:
+		children = t.Union.GetChild()
+	case *openfgav1.Userset_Intersection:
+		children = t.Intersection.GetChild()
+	case *openfgav1.Userset_Difference:
+		children = append(children, t.Difference.GetBase(), t.Difference.GetSubtract())
+	default:
+		return 1
+	}
+
+	count := 0
+	for _, child := range children {
+		count += RewriteBranchCount(child)
+	}
+	return count
+}
@@ -1265,6 +1265,128 @@ func TestHasCycle(t *testing.T) {
 	}
 }
 
+func TestFindCycle(t *testing.T) {
+	tests := []struct {
+		name       string
+		model      string
+		objectType string
+		relation   string
+		expected   []string
+	}{
+		{
+			name: "no_cycle",
+			model: `
+				model
+					schema 1.1
+				type user
+				type document
+					relations
+						define editor: [user]
+						define viewer: [user] or editor`,
+			objectType: "document",
+			relation:   "viewer",
+			expected:   nil,
+		},
+		{
+			name: "three_relations",
+			model: `
+				model
+					schema 1.1
+				type document
+					relations
+						define x: y
+						define y: z
+						define z: x`,
+			objectType: "document",
+			relation:   "y",
+			expected:   []string{"document#y", "document#z", "document#x"},
+		},
+		{
+			// the validation of the model rejects such a relation before looking for cycles
+			name: "self_referencing_relation",
+			model: `
+				model
+					schema 1.1
+				type user
+				type document
+					relations
+						define viewer: [user] or viewer`,
+			objectType: "document",
+			relation:   "viewer",
+			expected:   []string{"document#viewer"},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			typesys, err := New(testutils.MustTransformDSLToProtoWithID(test.model))
+			require.NoError(t, err)
+
+			cycle, err := typesys.FindCycle(test.objectType, test.relation)
+			require.NoError(t, err)
+			require.Equal(t, test.expected, cycle)
+		})
+	}
+}
+
+func TestNewAndValidateCycleError(t *testing.T) {
+	tests := []struct {
+		name             string
+		model            string
+		expectedRelation string
+		expectedCycle    []string
+		expectedError    string
+	}{
+		{
+			name: "three_relations",
+			model: `
+				model
+					schema 1.1
+				type user
+				type document
+					relations
+						define a: [user] but not b
+						define b: [user] but not c
+						define c: [user] or a`,
+			expectedRelation: "a",
+			expectedCycle:    []string{"document#a", "document#b", "document#c"},
+			expectedError:    "the definition of relation 'a' in object type 'document' is invalid: an authorization model cannot contain a cycle: document#a -> document#b -> document#c -> document#a",
+		},
+		{
+			name: "cycle_reached_from_another_relation",
+			model: `
+				model
+					schema 1.1
+				type user
+				type document
+					relations
+						define a: [user] or b
+						define b: [user] or c
+						define c: b`,
+			expectedRelation: "a",
+			expectedCycle:    []string{"document#b", "document#c"},
+			expectedError:    "the definition of relation 'a' in object type 'document' is invalid: an authorization model cannot contain a cycle: document#b -> document#c -> document#b",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			_, err := NewAndValidate(context.Background(), testutils.MustTransformDSLToProtoWithID(test.model))
+			require.ErrorIs(t, err, ErrCycle)
+			require.EqualError(t, err, test.expectedError)
+
+			var relationErr *InvalidRelationError
+			require.ErrorAs(t, err, &relationErr)
+			require.Equal(t, "document", relationErr.ObjectType)
+			require.Equal(t, test.expectedRelation, relationErr.Relation)
+
+			var cycleErr *CycleError
+			require.ErrorAs(t, err, &cycleErr)
+			require.Equal(t, test.expectedCycle, cycleErr.Cycle())
+		})
+	}
+}
+
 func TestNewAndValidate(t *testing.T) {
 	tests := []struct {
 		name          string
@@ -6125,6 +6247,52 @@ func TestPathExists(t *testing.T) {
 	}
 }
 
+func TestPathExistsIsMemoized(t *testing.T) {
+	model := testutils.MustTransformDSLToProtoWithID(`
+		model
+			schema 1.1
+		type user
+		type employee
+		type group
+			relations
+				define member: [employee]
+		type document
+			relations
+				define owner: [employee, group#member]
+				define editor: owner
+				define viewer: [user] or editor`)
+
+	typesys, err := New(model)
+	require.NoError(t, err)
+
+	// a user can be a viewer of a document, but never an owner through any rewrite
+	exists, err := typesys.PathExists("user:anne", "owner", "document")
+	require.NoError(t, err)
+	require.False(t, exists)
+
+	cached, ok := typesys.pathExists.Load("user document#owner")
+	require.True(t, ok)
+	require.Equal(t, false, cached)
+
+	// the users of the same type are answered from the cache
+	typesys.pathExists.Store("user document#owner", true)
+	exists, err = typesys.PathExists("user:bob", "owner", "document")
+	require.NoError(t, err)
+	require.True(t, exists)
+
+	exists, err = typesys.PathExists("group:eng#member", "owner", "document")
+	require.NoError(t, err)
+	require.True(t, exists)
+	_, ok = typesys.pathExists.Load("group#member document#owner")
+	require.True(t, ok)
+
+	// errors are not cached
+	_, err = typesys.PathExists("unknown:a", "owner", "document")
+	require.ErrorIs(t, err, graph.ErrQueryingGraph)
+	_, ok = typesys.pathExists.Load("unknown document#owner")
+	require.False(t, ok)
+}
+
 func TestGetEdgesFromWeightedGraph(t *testing.T) {
 	t.Run("returns_error_when_weighted_graph_is_nil", func(t *testing.T) {
 		typeSystem := &TypeSystem{
@@ -0,0 +1,65 @@
+package typesystem
+
+import (
+	"sort"
+
+	"google.golang.org/protobuf/proto"
+
+	openfgav1 "github.com/openfga/api/proto/openfga/v1"
+
+	"github.com/openfga/openfga/pkg/tuple"
+)
+
+// UntypedUserTypes returns the sorted types that an untyped user, e.g. 'anne' or 'eng#member', could be of
+// to be related to objectType#relation, i.e. the types T such that there is a path from 'T:anne' or
+// 'T:eng#member' to objectType#relation. It returns nil if the user is typed.
+func (t *TypeSystem) UntypedUserTypes(user, objectType, relation string) []string {
+	userObject, userRelation := tuple.SplitObjectRelation(user)
+	if user == "" || tuple.GetType(userObject) != "" {
+		return nil
+	}
+
+	var userTypes []string
+	for userType := range t.GetAllRelations() {
+		candidate := tuple.BuildObject(userType, "x")
+		if userRelation != "" {
+			candidate = tuple.ToObjectRelationString(candidate, userRelation)
+		}
+		if ok, _ := t.PathExists(candidate, relation, objectType); ok {
+			userTypes = append(userTypes, userType)
+		}
+	}
+	sort.Strings(userTypes)
+	return userTypes
+}
+
+// NormalizeUser returns the canonical form of the user of a tuple of objectType#relation, so that the
+// equivalent forms of a user resolve, and are cached, as one. In schema 1.1 and later, users are typed, and an
+// untyped user, e.g. 'anne' as sent by older clients, is prefixed with the only type of user that can be
+// related to objectType#relation, e.g. 'user:anne'. The user is returned as is if it is typed, if it is
+// the untyped wildcard, or if no type or several types of user can be related. In schema 1.0, users are
+// opaque, so 'anne' and 'user:anne' are distinct users and the user is always returned as is.
+func (t *TypeSystem) NormalizeUser(user, objectType, relation string) string {
+	if !IsSchemaVersionSupported(t.GetSchemaVersion()) || user == tuple.Wildcard {
+		return user
+	}
+
+	userTypes := t.UntypedUserTypes(user, objectType, relation)
+	if len(userTypes) != 1 {
+		return user
+	}
+	return userTypes[0] + ":" + user
+}
+
+// NormalizeTupleKey returns the tuple key with its user normalized, see NormalizeUser. The tuple key is
+// returned as is if its user is already canonical, and cloned otherwise.
+func (t *TypeSystem) NormalizeTupleKey(tk *openfgav1.TupleKey) *openfgav1.TupleKey {
+	user := t.NormalizeUser(tk.GetUser(), tuple.GetType(tk.GetObject()), tk.GetRelation())
+	if user == tk.GetUser() {
+		return tk
+	}
+
+	normalized := proto.Clone(tk).(*openfgav1.TupleKey)
+	normalized.User = user
+	return normalized
+}
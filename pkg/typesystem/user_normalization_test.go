@@ -0,0 +1,90 @@
+package typesystem
+
+import (
+	"testing"
+
+	"github.com/oklog/ulid/v2"
+	"github.com/stretchr/testify/require"
+
+	openfgav1 "github.com/openfga/api/proto/openfga/v1"
+
+	"github.com/openfga/openfga/pkg/testutils"
+	"github.com/openfga/openfga/pkg/tuple"
+)
+
+func TestNormalizeUser(t *testing.T) {
+	t.Run("schema_1.1", func(t *testing.T) {
+		typesys, err := New(testutils.MustTransformDSLToProtoWithID(`
+			model
+				schema 1.1
+			type user
+			type employee
+			type group
+				relations
+					define member: [user, employee]
+			type document
+				relations
+					define owner: [user]
+					define editor: [group#member]
+					define viewer: [user:*, group#member] or owner`))
+		require.NoError(t, err)
+
+		tests := []struct {
+			name     string
+			user     string
+			relation string
+			expected string
+		}{
+			{name: "untyped_user_of_a_single_type", user: "anne", relation: "owner", expected: "user:anne"},
+			{name: "untyped_userset_of_a_single_type", user: "eng#member", relation: "editor", expected: "group:eng#member"},
+			{name: "typed_user", user: "user:anne", relation: "owner", expected: "user:anne"},
+			{name: "untyped_user_of_several_types", user: "anne", relation: "viewer", expected: "anne"},
+			{name: "untyped_user_of_no_type", user: "anne", relation: "editor", expected: "anne"},
+			{name: "untyped_wildcard", user: "*", relation: "owner", expected: "*"},
+		}
+		for _, test := range tests {
+			t.Run(test.name, func(t *testing.T) {
+				require.Equal(t, test.expected, typesys.NormalizeUser(test.user, "document", test.relation))
+			})
+		}
+	})
+
+	t.Run("schema_1.0", func(t *testing.T) {
+		typesys, err := New(&openfgav1.AuthorizationModel{
+			Id:            ulid.Make().String(),
+			SchemaVersion: SchemaVersion1_0,
+			TypeDefinitions: []*openfgav1.TypeDefinition{
+				{Type: "user"},
+				{
+					Type: "document",
+					Relations: map[string]*openfgav1.Userset{
+						"viewer": This(),
+					},
+				},
+			},
+		})
+		require.NoError(t, err)
+
+		// the users are opaque, 'anne' and 'user:anne' are distinct users
+		require.Equal(t, "anne", typesys.NormalizeUser("anne", "document", "viewer"))
+		require.Equal(t, "user:anne", typesys.NormalizeUser("user:anne", "document", "viewer"))
+	})
+}
+
+func TestNormalizeTupleKey(t *testing.T) {
+	typesys, err := New(testutils.MustTransformDSLToProtoWithID(`
+		model
+			schema 1.1
+		type user
+		type document
+			relations
+				define viewer: [user]`))
+	require.NoError(t, err)
+
+	canonical := tuple.NewTupleKey("document:1", "viewer", "user:anne")
+	require.Same(t, canonical, typesys.NormalizeTupleKey(canonical))
+
+	untyped := tuple.NewTupleKey("document:1", "viewer", "anne")
+	require.Equal(t, "user:anne", typesys.NormalizeTupleKey(untyped).GetUser())
+	require.Equal(t, "anne", untyped.GetUser())
+}